@@ -15,12 +15,15 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"math/big"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"os/signal"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -36,8 +39,13 @@ import (
 	"golang.org/x/crypto/argon2"
 
 	"gamehub/auth-service/internal/config"
+	"gamehub/auth-service/internal/kyc"
 	"gamehub/auth-service/internal/mailer"
+	"gamehub/auth-service/internal/ratelimit"
+	"gamehub/auth-service/internal/sms"
 	"gamehub/auth-service/internal/token"
+	"gamehub/auth-service/internal/totp"
+	"gamehub/pkg/authn/rbac"
 )
 
 var (
@@ -46,6 +54,10 @@ var (
 	rdb        *redis.Client
 	jwtManager *token.Manager
 	mailClient *mailer.Client
+	smsSender  sms.Sender
+	kycStore   kyc.Store
+	limiter    *ratelimit.Limiter
+	budgets    authBudgets
 
 	usernameSanitizer  = regexp.MustCompile(`[^a-z0-9]+`)
 	googleHTTPClient   = &http.Client{Timeout: 10 * time.Second}
@@ -79,8 +91,9 @@ type ctxKey string
 const ctxUserKey ctxKey = "auth_user"
 
 type refreshSession struct {
-	UserID string `json:"userId"`
-	Role   string `json:"role"`
+	UserID    string `json:"userId"`
+	Role      string `json:"role"`
+	SessionID string `json:"sessionId,omitempty"`
 }
 
 type firebasePublicKeyCache struct {
@@ -95,6 +108,14 @@ func main() {
 	cfg = config.Load()
 	configureAllowedOrigins(cfg.AllowedOrigins)
 	mailClient = mailer.New(cfg.ResendAPIKey, cfg.EmailFrom, cfg.PasswordResetURL)
+	var err error
+	if smsSender, err = sms.New(cfg); err != nil {
+		log.Fatalf("sms sender init: %v", err)
+	}
+	log.Printf("SMS provider: %s", smsSender.Name())
+	if kycStore, err = kyc.NewStore(cfg.KYCStorageBackend, cfg.KYCStorageDir); err != nil {
+		log.Fatalf("kyc store init: %v", err)
+	}
 
 	// --- MongoDB ---
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -110,6 +131,7 @@ func main() {
 	db = client.Database("gamehub")
 
 	setupIndexes()
+	bootstrapAdmins(cfg.BootstrapAdmins)
 
 	// --- Redis (refresh tokens, rate limiting, etc.) ---
 	rdb = redis.NewClient(&redis.Options{
@@ -121,11 +143,25 @@ func main() {
 	}
 	defer rdb.Close()
 
+	// --- Rate limiting (per-IP/phone/email budgets + failure lockout) ---
+	limiter = ratelimit.New(rdb, cfg.RateLimitPrefix, ratelimit.Lockout{
+		Threshold: cfg.LockoutThreshold,
+		Base:      cfg.LockoutBase,
+		Max:       cfg.LockoutMax,
+		Memory:    24 * time.Hour,
+	})
+	budgets = loadAuthBudgets(cfg)
+
 	// --- JWT Manager ---
-	jwtManager, err = token.NewManager(cfg.JWTPrivateKey, cfg.JWTPublicKey, cfg.JWTIssuer, cfg.AccessTTL)
+	jwtManager, err = token.NewManager(cfg.JWTPrivateKey, cfg.JWTPublicKey, cfg.JWTIssuer, cfg.AccessTTL, token.KeyOptions{
+		KeyID:     cfg.JWTKeyID,
+		Dir:       cfg.JWTKeysDir,
+		ActiveKID: cfg.JWTActiveKID,
+	})
 	if err != nil {
 		log.Fatalf("token manager init: %v", err)
 	}
+	log.Printf("🔑 Signing access tokens with kid %s", jwtManager.KeyID())
 
 	// --- HTTP Router ---
 	mux := http.NewServeMux()
@@ -164,12 +200,25 @@ func handleHealth(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, 200, map[string]string{"status": "ok", "service": "auth-service"})
 }
 
+// handleJWKS publishes the access-token verification keys so services can
+// pick up a rotated key without a redeploy.
+func handleJWKS(w http.ResponseWriter, r *http.Request) {
+	if jwtManager == nil {
+		respondError(w, 503, "signing keys unavailable")
+		return
+	}
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	respondJSON(w, 200, jwtManager.JWKS())
+}
+
 func registerRoutes(mux *http.ServeMux) {
 	registerRoute(mux, http.MethodGet, "/health", handleHealth)
+	registerRoute(mux, http.MethodGet, "/.well-known/jwks.json", handleJWKS)
 
 	// Phone + OTP
 	registerRoute(mux, http.MethodPost, "/api/v1/auth/phone/request-otp", handleRequestOTP)
 	registerRoute(mux, http.MethodPost, "/api/v1/auth/phone/verify-otp", handleVerifyOTP)
+	registerRoute(mux, http.MethodPost, "/api/v1/auth/phone/sms-status", handleSMSStatus)
 
 	// Firebase Auth token exchange
 	registerRoute(mux, http.MethodPost, "/api/v1/auth/firebase/login", handleFirebaseLogin)
@@ -185,14 +234,51 @@ func registerRoutes(mux *http.ServeMux) {
 
 	// Guest
 	registerRoute(mux, http.MethodPost, "/api/v1/auth/guest/start", handleGuestStart)
+	registerRoute(mux, http.MethodPost, "/api/v1/auth/guest/upgrade", requireAuth(handleGuestUpgrade))
+
+	// Linked identities
+	registerRoute(mux, http.MethodGet, "/api/v1/auth/identities", requireAuth(handleListIdentities))
+	registerRoute(mux, http.MethodPost, "/api/v1/auth/identities/email/verify", requireAuth(handleStartEmailVerification))
+	registerRoute(mux, http.MethodPost, "/api/v1/auth/identities/link", requireAuth(handleLinkIdentity))
+	registerRoute(mux, http.MethodPost, "/api/v1/auth/identities/unlink", requireAuth(handleUnlinkIdentity))
 
 	// Token management
 	registerRoute(mux, http.MethodPost, "/api/v1/auth/refresh", handleRefreshToken)
 	registerRoute(mux, http.MethodPost, "/api/v1/auth/logout", handleLogout)
+	registerRoute(mux, http.MethodPost, "/api/v1/auth/logout-all", requireAuth(handleLogoutAll))
+	registerRoute(mux, http.MethodGet, "/api/v1/auth/sessions", requireAuth(handleListSessions))
+	registerRoute(mux, http.MethodPost, "/api/v1/auth/sessions/revoke", requireAuth(handleRevokeSession))
 
 	// Profile (requires auth header)
 	registerRoute(mux, http.MethodGet, "/api/v1/auth/me", requireAuth(handleGetProfile))
+
+	// KYC
+	registerRoute(mux, http.MethodGet, "/api/v1/auth/kyc", requireAuth(handleKYCStatus))
 	registerRoute(mux, http.MethodPost, "/api/v1/auth/kyc/initiate", requireAuth(handleKYCInitiate))
+	registerRoute(mux, http.MethodPost, "/api/v1/auth/kyc/documents", requireAuth(handleKYCUploadDocument))
+	registerRoute(mux, http.MethodPost, "/api/v1/auth/kyc/submit", requireAuth(handleKYCSubmit))
+	registerRoute(mux, http.MethodGet, "/api/v1/auth/admin/kyc", requireScope(rbac.ScopeKYCReview, handleKYCQueue))
+	registerRoute(mux, http.MethodGet, "/api/v1/auth/admin/kyc/request", requireScope(rbac.ScopeKYCReview, handleKYCGetRequest))
+	registerRoute(mux, http.MethodGet, "/api/v1/auth/admin/kyc/document", requireScope(rbac.ScopeKYCReview, handleKYCDocument))
+	registerRoute(mux, http.MethodPost, "/api/v1/auth/admin/kyc/approve", requireScope(rbac.ScopeKYCReview, handleKYCApprove))
+	registerRoute(mux, http.MethodPost, "/api/v1/auth/admin/kyc/reject", requireScope(rbac.ScopeKYCReview, handleKYCReject))
+	registerRoute(mux, http.MethodPost, "/api/v1/auth/admin/kyc/request-info", requireScope(rbac.ScopeKYCReview, handleKYCRequestInfo))
+
+	// Admin
+	registerRoute(mux, http.MethodGet, "/api/v1/auth/admin/rate-limits", requireScope(rbac.ScopeAuthLocks, handleGetRateLimits))
+	registerRoute(mux, http.MethodPost, "/api/v1/auth/admin/rate-limits/clear", requireScope(rbac.ScopeAuthLocks, handleClearRateLimits))
+
+	// Two-factor auth
+	registerRoute(mux, http.MethodGet, "/api/v1/auth/2fa", requireAuth(handle2FAStatus))
+	registerRoute(mux, http.MethodPost, "/api/v1/auth/2fa/totp/enroll", requireAuth(handleTOTPEnroll))
+	registerRoute(mux, http.MethodPost, "/api/v1/auth/2fa/totp/enable", requireAuth(handleTOTPEnable))
+	registerRoute(mux, http.MethodPost, "/api/v1/auth/2fa/totp/disable", requireAuth(handleTOTPDisable))
+	registerRoute(mux, http.MethodPost, "/api/v1/auth/2fa/backup-codes", requireAuth(handleRegenerateBackupCodes))
+	registerRoute(mux, http.MethodPost, "/api/v1/auth/2fa/step-up", requireAuth(handleStepUp))
+
+	// Role management
+	registerRoute(mux, http.MethodGet, "/api/v1/auth/admin/roles", requireScope(rbac.ScopeRolesManage, handleGetRoles))
+	registerRoute(mux, http.MethodPost, "/api/v1/auth/admin/roles/update", requireScope(rbac.ScopeRolesManage, handleSetRoles))
 }
 
 // ============================================================
@@ -202,12 +288,19 @@ func registerRoutes(mux *http.ServeMux) {
 func handleRequestOTP(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	var body struct {
-		Phone string `json:"phone"`
+		Phone  string `json:"phone"`
+		Locale string `json:"locale"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Phone == "" {
 		respondError(w, 400, "phone is required")
 		return
 	}
+	if !enforceBudgets(w, r,
+		budgetCheck{"otp_request_ip", clientIP(r), budgets.OTPRequestIP},
+		budgetCheck{"otp_request_phone", body.Phone, budgets.OTPRequestPhone},
+	) {
+		return
+	}
 
 	// Generate 6-digit OTP
 	code := generateOTP(otpLength)
@@ -217,14 +310,34 @@ func handleRequestOTP(w http.ResponseWriter, r *http.Request) {
 	if err != nil || ttl <= 0 {
 		ttl = 5 * time.Minute
 	}
+
+	locale := body.Locale
+	if locale == "" {
+		locale = r.Header.Get("Accept-Language")
+	}
+	if locale == "" {
+		locale = cfg.SMSDefaultLocale
+	}
+	text, locale, err := sms.Render(sms.TemplateOTP, locale, sms.OTPData{Code: code, TTLMinutes: int(ttl.Minutes())})
+	if err != nil {
+		respondError(w, 500, "could not prepare OTP")
+		return
+	}
+
+	reference := primitive.NewObjectID().Hex()
 	_, err = db.Collection("otps").ReplaceOne(
 		ctx,
 		bson.M{"phone": body.Phone},
 		bson.M{
-			"phone":     body.Phone,
-			"code":      hashOTP(code),
-			"expiresAt": time.Now().Add(ttl),
-			"used":      false,
+			"phone":          body.Phone,
+			"code":           hashOTP(code),
+			"expiresAt":      time.Now().Add(ttl),
+			"used":           false,
+			"reference":      reference,
+			"locale":         locale,
+			"provider":       smsSender.Name(),
+			"deliveryStatus": sms.StatusPending,
+			"createdAt":      time.Now(),
 		},
 		options.Replace().SetUpsert(true),
 	)
@@ -233,7 +346,9 @@ func handleRequestOTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// In development: log instead of actually calling Hubtel SMS
+	sendErr := deliverOTP(ctx, body.Phone, reference, text)
+
+	// In development: also return the code so flows work without a phone
 	if cfg.AppEnv == "development" {
 		log.Printf("🔑 OTP for %s: %s", body.Phone, code)
 		respondJSON(w, 200, map[string]interface{}{
@@ -242,13 +357,76 @@ func handleRequestOTP(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
-
-	// TODO: Call Hubtel SMS API to deliver the code
-	// hubtelSMS.Send(body.Phone, "Your Glory Grid OTP: "+code)
+	if sendErr != nil {
+		respondError(w, 502, "could not deliver OTP, please try again")
+		return
+	}
 
 	respondJSON(w, 200, map[string]string{"message": "OTP sent to " + body.Phone})
 }
 
+// deliverOTP sends the OTP text and records the provider's verdict on the
+// otps document identified by reference.
+func deliverOTP(ctx context.Context, phone, reference, text string) error {
+	sendCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	result, err := smsSender.Send(sendCtx, sms.Message{To: phone, Body: text, Reference: reference})
+	cancel()
+
+	update := bson.M{"deliveryUpdatedAt": time.Now()}
+	if err != nil {
+		log.Printf("⚠️  OTP delivery to %s via %s failed: %v", phone, smsSender.Name(), err)
+		update["deliveryStatus"] = sms.StatusFailed
+		update["deliveryError"] = err.Error()
+	} else {
+		update["deliveryStatus"] = result.Status
+		update["providerMessageId"] = result.MessageID
+		update["sentAt"] = time.Now()
+	}
+	_, _ = db.Collection("otps").UpdateOne(ctx,
+		bson.M{"phone": phone, "reference": reference},
+		bson.M{"$set": update},
+	)
+	return err
+}
+
+// handleSMSStatus records provider delivery reports against the OTP they
+// belong to. Providers authenticate with SMS_STATUS_WEBHOOK_SECRET, sent as
+// X-SMS-Webhook-Secret.
+func handleSMSStatus(w http.ResponseWriter, r *http.Request) {
+	secret := r.Header.Get("X-SMS-Webhook-Secret")
+	if cfg.SMSStatusSecret == "" || subtle.ConstantTimeCompare([]byte(secret), []byte(cfg.SMSStatusSecret)) != 1 {
+		respondError(w, 403, "forbidden")
+		return
+	}
+	raw, err := io.ReadAll(io.LimitReader(r.Body, 64*1024))
+	if err != nil {
+		respondError(w, 400, "invalid request")
+		return
+	}
+	report, ok := sms.ParseDeliveryReport(raw)
+	if !ok {
+		respondError(w, 400, "messageId and status are required")
+		return
+	}
+
+	res, err := db.Collection("otps").UpdateOne(r.Context(),
+		bson.M{"$or": []bson.M{
+			{"providerMessageId": report.MessageID},
+			{"reference": report.MessageID},
+		}},
+		bson.M{"$set": bson.M{
+			"deliveryStatus":    report.Status,
+			"deliveryDetail":    report.Detail,
+			"deliveryUpdatedAt": time.Now(),
+		}},
+	)
+	if err != nil {
+		respondError(w, 500, "could not record delivery status")
+		return
+	}
+	respondJSON(w, 200, map[string]interface{}{"matched": res.MatchedCount > 0})
+}
+
 func handleVerifyOTP(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	var body struct {
@@ -259,30 +437,10 @@ func handleVerifyOTP(w http.ResponseWriter, r *http.Request) {
 		respondError(w, 400, "invalid request")
 		return
 	}
-
-	var otpDoc bson.M
-	err := db.Collection("otps").FindOne(ctx, bson.M{
-		"phone":     body.Phone,
-		"used":      false,
-		"expiresAt": bson.M{"$gt": time.Now()},
-	}).Decode(&otpDoc)
-	if err != nil {
-		respondError(w, 401, "OTP not found or expired")
-		return
-	}
-
-	storedHash, ok := otpDoc["code"].(string)
-	if !ok || storedHash != hashOTP(body.Code) {
-		respondError(w, 401, "invalid OTP")
+	if !consumeOTP(w, r, body.Phone, body.Code) {
 		return
 	}
 
-	// Mark OTP as used
-	_, _ = db.Collection("otps").UpdateOne(ctx,
-		bson.M{"phone": body.Phone},
-		bson.M{"$set": bson.M{"used": true}},
-	)
-
 	// Upsert user
 	user, err := upsertUserByPhone(ctx, body.Phone)
 	if err != nil {
@@ -299,7 +457,7 @@ func handleVerifyOTP(w http.ResponseWriter, r *http.Request) {
 	triggerWalletGeneration(userID)
 
 	// Issue tokens
-	accessToken, refreshToken, err := issueTokenPair(ctx, userID, "user")
+	accessToken, refreshToken, err := issueTokenPair(ctx, userID, "user", deviceFromRequest(r))
 	if err != nil {
 		respondError(w, 500, "failed to issue tokens")
 		return
@@ -312,6 +470,48 @@ func handleVerifyOTP(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// consumeOTP checks code against the phone's live OTP and marks it used. On
+// failure it has already written the response.
+func consumeOTP(w http.ResponseWriter, r *http.Request, phone, code string) bool {
+	ctx := r.Context()
+	if !enforceBudgets(w, r, budgetCheck{"otp_verify_ip", clientIP(r), budgets.OTPVerifyIP}) {
+		return false
+	}
+	if !enforceLockout(w, r, lockScopeOTP, phone) {
+		return false
+	}
+
+	var otpDoc bson.M
+	err := db.Collection("otps").FindOne(ctx, bson.M{
+		"phone":     phone,
+		"used":      false,
+		"expiresAt": bson.M{"$gt": time.Now()},
+	}).Decode(&otpDoc)
+	if err != nil {
+		respondError(w, 401, "OTP not found or expired")
+		return false
+	}
+
+	storedHash, ok := otpDoc["code"].(string)
+	if !ok || storedHash != hashOTP(code) {
+		recordAuthFailure(ctx, lockScopeOTP, phone)
+		if exhausted := recordOTPMiss(ctx, otpDoc); exhausted {
+			respondError(w, 401, "too many incorrect attempts, request a new code")
+			return false
+		}
+		respondError(w, 401, "invalid OTP")
+		return false
+	}
+	resetAuthFailures(ctx, lockScopeOTP, phone)
+
+	// Mark OTP as used
+	_, _ = db.Collection("otps").UpdateOne(ctx,
+		bson.M{"phone": phone},
+		bson.M{"$set": bson.M{"used": true}},
+	)
+	return true
+}
+
 // ============================================================
 // FIREBASE AUTH
 // ============================================================
@@ -389,7 +589,7 @@ func handleFirebaseLogin(w http.ResponseWriter, r *http.Request) {
 		triggerWalletGeneration(userID)
 	}
 
-	accessToken, refreshToken, err := issueTokenPair(ctx, userID, role)
+	accessToken, refreshToken, err := issueTokenPair(ctx, userID, role, deviceFromRequest(r))
 	if err != nil {
 		respondError(w, 500, "failed to issue tokens")
 		return
@@ -458,7 +658,7 @@ func handleGoogleLogin(w http.ResponseWriter, r *http.Request) {
 		triggerWalletGeneration(userID)
 	}
 
-	accessToken, refreshToken, err := issueTokenPair(ctx, userID, "user")
+	accessToken, refreshToken, err := issueTokenPair(ctx, userID, "user", deviceFromRequest(r))
 	if err != nil {
 		respondError(w, 500, "failed to issue tokens")
 		return
@@ -522,7 +722,7 @@ func handleEmailRegister(w http.ResponseWriter, r *http.Request) {
 	// Trigger async JIT wallet generation (BTC, ETH, USDT)
 	triggerWalletGeneration(userID)
 
-	accessToken, refreshToken, err := issueTokenPair(ctx, userID, "user")
+	accessToken, refreshToken, err := issueTokenPair(ctx, userID, "user", deviceFromRequest(r))
 	if err != nil {
 		respondError(w, 500, "failed to issue tokens")
 		return
@@ -547,25 +747,39 @@ func handleEmailLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	emailKey := strings.TrimSpace(strings.ToLower(body.Email))
+	if !enforceBudgets(w, r,
+		budgetCheck{"login_ip", clientIP(r), budgets.LoginIP},
+		budgetCheck{"login_email", emailKey, budgets.LoginEmail},
+	) {
+		return
+	}
+	if !enforceLockout(w, r, lockScopeLogin, emailKey) {
+		return
+	}
+
 	var user bson.M
 	err := db.Collection("users").FindOne(ctx, bson.M{"email": body.Email}).Decode(&user)
 	if err != nil {
+		recordAuthFailure(ctx, lockScopeLogin, emailKey)
 		respondError(w, 401, "invalid email or password")
 		return
 	}
 
 	storedHash, _ := user["passwordHash"].(string)
 	if !verifyPassword(body.Password, storedHash) {
+		recordAuthFailure(ctx, lockScopeLogin, emailKey)
 		respondError(w, 401, "invalid email or password")
 		return
 	}
+	resetAuthFailures(ctx, lockScopeLogin, emailKey)
 
 	userID, err := extractUserID(user)
 	if err != nil {
 		respondError(w, 500, "user id missing")
 		return
 	}
-	accessToken, refreshToken, err := issueTokenPair(ctx, userID, "user")
+	accessToken, refreshToken, err := issueTokenPair(ctx, userID, "user", deviceFromRequest(r))
 	if err != nil {
 		respondError(w, 500, "failed to issue tokens")
 		return
@@ -592,6 +806,12 @@ func handleEmailForgotPassword(w http.ResponseWriter, r *http.Request) {
 		respondError(w, 400, "email is required")
 		return
 	}
+	if !enforceBudgets(w, r,
+		budgetCheck{"forgot_ip", clientIP(r), budgets.ForgotIP},
+		budgetCheck{"forgot_email", email, budgets.ForgotEmail},
+	) {
+		return
+	}
 
 	var user bson.M
 	err := db.Collection("users").FindOne(ctx, bson.M{"email": email}).Decode(&user)
@@ -677,153 +897,2130 @@ func handleEmailResetPassword(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// ============================================================
-// GUEST
-// ============================================================
-
-func handleGuestStart(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-	now := time.Now()
-	userID := primitive.NewObjectID()
-	displayName := generateGuestDisplayName()
-	doc := bson.M{
-		"_id":           userID,
-		"username":      guestUsername(displayName),
-		"fullName":      displayName,
-		"isGuest":       true,
-		"authProviders": []string{"guest"},
-		"kycStatus":     "NONE",
-		"tier":          "BRONZE",
-		"createdAt":     now,
-		"updatedAt":     now,
-		"expiresAt":     now.Add(24 * time.Hour),
+// ============================================================
+// GUEST
+// ============================================================
+
+func handleGuestStart(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	now := time.Now()
+	userID := primitive.NewObjectID()
+	displayName := generateGuestDisplayName()
+	doc := bson.M{
+		"_id":           userID,
+		"username":      guestUsername(displayName),
+		"fullName":      displayName,
+		"isGuest":       true,
+		"authProviders": []string{"guest"},
+		"kycStatus":     "NONE",
+		"tier":          "BRONZE",
+		"createdAt":     now,
+		"updatedAt":     now,
+		"expiresAt":     now.Add(24 * time.Hour),
+	}
+	if _, err := db.Collection("users").InsertOne(ctx, doc); err != nil {
+		respondError(w, 500, "failed to create guest user")
+		return
+	}
+	triggerWalletGeneration(userID.Hex())
+	accessToken, refreshToken, err := issueTokenPair(ctx, userID.Hex(), "guest", deviceFromRequest(r))
+	if err != nil {
+		respondError(w, 500, "failed to issue tokens")
+		return
+	}
+
+	respondJSON(w, 201, map[string]interface{}{
+		"accessToken":  accessToken,
+		"refreshToken": refreshToken,
+		"user":         sanitizeUser(doc),
+	})
+}
+
+// identityRequest carries a credential to attach to the caller's account.
+// Method is email, phone, google or firebase. For guest upgrades OnConflict
+// decides what happens when the identity already belongs to another account:
+// "reject" (default) or "merge" the guest into it.
+type identityRequest struct {
+	Method     string `json:"method"`
+	Email      string `json:"email"`
+	Password   string `json:"password"`
+	Username   string `json:"username"`
+	Phone      string `json:"phone"`
+	Code       string `json:"code"`
+	IDToken    string `json:"idToken"`
+	OnConflict string `json:"onConflict"`
+}
+
+// linkedIdentity is a verified credential ready to attach to an account.
+type linkedIdentity struct {
+	Providers []string
+	// Match finds another account already holding the identity.
+	Match []bson.M
+	Set   bson.M
+	// Owns reports whether the credential also proves ownership of the
+	// account found by Match, which a merge requires.
+	Owns func(existing bson.M) bool
+}
+
+var errGuestFundsReserved = errors.New("guest account has reserved funds")
+
+func handleGuestUpgrade(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, ok := authClaimsFromContext(ctx)
+	if !ok {
+		respondError(w, 401, "unauthorized")
+		return
+	}
+	oid, err := primitive.ObjectIDFromHex(claims.UserID)
+	if err != nil {
+		respondError(w, 400, "invalid user id")
+		return
+	}
+	var guest bson.M
+	if err := db.Collection("users").FindOne(ctx, bson.M{"_id": oid}).Decode(&guest); err != nil {
+		respondError(w, 404, "user not found")
+		return
+	}
+	if isGuest, _ := guest["isGuest"].(bool); !isGuest {
+		respondError(w, 409, "account is already upgraded")
+		return
+	}
+	if _, merged := guest["mergedInto"]; merged {
+		respondError(w, 409, "guest account was already merged")
+		return
+	}
+
+	var body identityRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		respondError(w, 400, "invalid request")
+		return
+	}
+	onConflict := strings.ToLower(strings.TrimSpace(body.OnConflict))
+	if onConflict != "" && onConflict != "reject" && onConflict != "merge" {
+		respondError(w, 400, "onConflict must be reject or merge")
+		return
+	}
+	identity, ok := resolveLinkedIdentity(w, r, &body)
+	if !ok {
+		return
+	}
+
+	var existing bson.M
+	err = db.Collection("users").FindOne(ctx, bson.M{
+		"_id": bson.M{"$ne": oid},
+		"$or": identity.Match,
+	}).Decode(&existing)
+	if err != nil && err != mongo.ErrNoDocuments {
+		respondError(w, 500, "failed to look up identity")
+		return
+	}
+	if err == mongo.ErrNoDocuments {
+		linkGuestIdentity(w, r, guest, identity)
+		return
+	}
+	if onConflict != "merge" {
+		respondIdentityInUse(w)
+		return
+	}
+	if !identity.Owns(existing) {
+		if body.Method == "email" {
+			recordAuthFailure(ctx, lockScopeLogin, body.Email)
+		}
+		respondError(w, 401, "credentials do not match the existing account")
+		return
+	}
+	if body.Method == "email" {
+		resetAuthFailures(ctx, lockScopeLogin, body.Email)
+	}
+	mergeGuestAccount(w, r, claims.UserID, existing)
+}
+
+// resolveLinkedIdentity verifies the credential named by body.Method. On
+// failure it has already written the response.
+func resolveLinkedIdentity(w http.ResponseWriter, r *http.Request, body *identityRequest) (*linkedIdentity, bool) {
+	ctx := r.Context()
+	body.Method = strings.ToLower(strings.TrimSpace(body.Method))
+	var identity *linkedIdentity
+
+	switch body.Method {
+	case "email":
+		body.Email = strings.ToLower(strings.TrimSpace(body.Email))
+		if body.Email == "" || body.Password == "" {
+			respondError(w, 400, "email and password are required")
+			return nil, false
+		}
+		if !enforceLockout(w, r, lockScopeLogin, body.Email) {
+			return nil, false
+		}
+		hash, err := hashPassword(body.Password)
+		if err != nil {
+			respondError(w, 500, "failed to hash password")
+			return nil, false
+		}
+		password := body.Password
+		identity = &linkedIdentity{
+			Providers: []string{"email"},
+			Match:     []bson.M{{"email": body.Email}},
+			Set:       bson.M{"email": body.Email, "passwordHash": hash},
+			Owns: func(existing bson.M) bool {
+				stored, _ := existing["passwordHash"].(string)
+				return verifyPassword(password, stored)
+			},
+		}
+
+	case "phone":
+		body.Phone = strings.TrimSpace(body.Phone)
+		if body.Phone == "" || body.Code == "" {
+			respondError(w, 400, "phone and code are required")
+			return nil, false
+		}
+		if !consumeOTP(w, r, body.Phone, body.Code) {
+			return nil, false
+		}
+		identity = &linkedIdentity{
+			Providers: []string{"phone"},
+			Match:     []bson.M{{"phone": body.Phone}},
+			Set:       bson.M{"phone": body.Phone},
+			Owns:      func(bson.M) bool { return true },
+		}
+
+	case "google":
+		if len(cfg.GoogleClientIDs) == 0 {
+			respondError(w, 503, "google sign-in is not configured")
+			return nil, false
+		}
+		if strings.TrimSpace(body.IDToken) == "" {
+			respondError(w, 400, "idToken is required")
+			return nil, false
+		}
+		claims, err := verifyGoogleIDToken(ctx, strings.TrimSpace(body.IDToken))
+		if err != nil {
+			log.Printf("google token validation failed: %v", err)
+			respondError(w, 401, "invalid google identity token")
+			return nil, false
+		}
+		identity = &linkedIdentity{
+			Providers: []string{"google"},
+			Match:     []bson.M{{"googleId": claims.Subject}},
+			Set:       bson.M{"googleId": claims.Subject},
+			Owns:      func(bson.M) bool { return true },
+		}
+		// verifyGoogleIDToken only passes verified emails.
+		if claims.Email != "" {
+			identity.Match = append(identity.Match, bson.M{"email": claims.Email})
+			identity.Set["email"] = claims.Email
+		}
+		if claims.FullName != "" {
+			identity.Set["fullName"] = claims.FullName
+		}
+		if claims.AvatarURL != "" {
+			identity.Set["avatarUrl"] = claims.AvatarURL
+		}
+
+	case "firebase":
+		if strings.TrimSpace(cfg.FirebaseProjectID) == "" {
+			respondError(w, 503, "firebase auth is not configured")
+			return nil, false
+		}
+		if strings.TrimSpace(body.IDToken) == "" {
+			respondError(w, 400, "idToken is required")
+			return nil, false
+		}
+		claims, err := verifyFirebaseIDToken(ctx, strings.TrimSpace(body.IDToken))
+		if err != nil {
+			log.Printf("firebase token validation failed: %v", err)
+			respondError(w, 401, "invalid firebase identity token")
+			return nil, false
+		}
+		provider := normalizeFirebaseProvider(claims.Firebase.SignInProvider)
+		if provider == "guest" {
+			respondError(w, 400, "an anonymous firebase identity cannot upgrade a guest")
+			return nil, false
+		}
+		uid := strings.TrimSpace(claims.Subject)
+		identity = &linkedIdentity{
+			Providers: []string{"firebase", provider},
+			Match:     []bson.M{{"firebaseUid": uid}},
+			Set:       bson.M{"firebaseUid": uid},
+			Owns:      func(bson.M) bool { return true },
+		}
+		// Only a verified email is proof enough to claim an existing account.
+		if email := strings.ToLower(strings.TrimSpace(claims.Email)); email != "" && claims.EmailVerified {
+			identity.Match = append(identity.Match, bson.M{"email": email})
+			identity.Set["email"] = email
+		}
+		if name := strings.TrimSpace(claims.Name); name != "" {
+			identity.Set["fullName"] = name
+		}
+		if avatar := strings.TrimSpace(claims.Picture); avatar != "" {
+			identity.Set["avatarUrl"] = avatar
+		}
+
+	default:
+		respondError(w, 400, "method must be email, phone, google or firebase")
+		return nil, false
+	}
+
+	if username := strings.TrimSpace(body.Username); username != "" {
+		identity.Set["username"] = username
+	}
+	return identity, true
+}
+
+// linkGuestIdentity turns the guest into a full account in place, so its
+// wallet, ledger and game history stay keyed by the same user ID.
+func linkGuestIdentity(w http.ResponseWriter, r *http.Request, guest bson.M, identity *linkedIdentity) {
+	ctx := r.Context()
+	userID, err := extractUserID(guest)
+	if err != nil {
+		respondError(w, 500, "user id missing")
+		return
+	}
+	now := time.Now()
+	set := bson.M{
+		"isGuest":       false,
+		"authProviders": upgradedProviders(guest["authProviders"], identity.Providers),
+		"upgradedAt":    now,
+		"updatedAt":     now,
+	}
+	for k, v := range identity.Set {
+		set[k] = v
+	}
+	res, err := db.Collection("users").UpdateOne(ctx,
+		bson.M{"_id": guest["_id"], "isGuest": true},
+		bson.M{"$set": set, "$unset": bson.M{"expiresAt": ""}},
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		respondIdentityInUse(w)
+		return
+	}
+	if err != nil {
+		respondError(w, 500, "failed to upgrade account")
+		return
+	}
+	if res.MatchedCount == 0 {
+		respondError(w, 409, "account is already upgraded")
+		return
+	}
+
+	// Existing refresh sessions would keep minting guest tokens.
+	revokeAllSessions(ctx, userID)
+	accessToken, refreshToken, err := issueTokenPair(ctx, userID, "user", deviceFromRequest(r))
+	if err != nil {
+		respondError(w, 500, "failed to issue tokens")
+		return
+	}
+
+	var user bson.M
+	if err := db.Collection("users").FindOne(ctx, bson.M{"_id": guest["_id"]}).Decode(&user); err != nil {
+		respondError(w, 500, "failed to load user")
+		return
+	}
+	log.Printf("👤 Guest %s upgraded via %s", userID, strings.Join(identity.Providers, ","))
+	respondJSON(w, 200, map[string]interface{}{
+		"accessToken":  accessToken,
+		"refreshToken": refreshToken,
+		"user":         sanitizeUser(user),
+		"merged":       false,
+	})
+}
+
+// mergeGuestAccount folds the guest's balance and game history into the
+// account that already owns the credential, retires the guest and signs the
+// caller in as the existing account. Both internal calls are idempotent, so a
+// failed merge can simply be retried.
+func mergeGuestAccount(w http.ResponseWriter, r *http.Request, guestID string, target bson.M) {
+	ctx := r.Context()
+	targetID, err := extractUserID(target)
+	if err != nil {
+		respondError(w, 500, "user id missing")
+		return
+	}
+	if err := mergeGuestData(ctx, guestID, targetID); err != nil {
+		if errors.Is(err, errGuestFundsReserved) {
+			respondError(w, 409, "finish open bets and withdrawals before merging")
+			return
+		}
+		log.Printf("⚠️  merge guest %s into %s: %v", guestID, targetID, err)
+		respondError(w, 502, "could not merge guest account")
+		return
+	}
+
+	guestOID, _ := primitive.ObjectIDFromHex(guestID)
+	now := time.Now()
+	_, err = db.Collection("users").UpdateOne(ctx, bson.M{"_id": guestOID}, bson.M{"$set": bson.M{
+		"mergedInto": targetID,
+		"mergedAt":   now,
+		"expiresAt":  now,
+		"updatedAt":  now,
+	}})
+	if err != nil {
+		respondError(w, 500, "failed to retire guest account")
+		return
+	}
+	revokeAllSessions(ctx, guestID)
+
+	accessToken, refreshToken, err := issueTokenPair(ctx, targetID, "user", deviceFromRequest(r))
+	if err != nil {
+		respondError(w, 500, "failed to issue tokens")
+		return
+	}
+	log.Printf("👤 Guest %s merged into %s", guestID, targetID)
+	respondJSON(w, 200, map[string]interface{}{
+		"accessToken":  accessToken,
+		"refreshToken": refreshToken,
+		"user":         sanitizeUser(target),
+		"merged":       true,
+		"mergedFrom":   guestID,
+	})
+}
+
+// mergeGuestData moves the wallet first: it refuses while the guest has funds
+// on hold, and nothing else has changed at that point.
+func mergeGuestData(ctx context.Context, guestID, targetID string) error {
+	payload := map[string]string{"fromUserId": guestID, "toUserId": targetID}
+	status, err := callInternal(ctx, cfg.WalletServiceURL, "/internal/ledger/merge-accounts", payload)
+	if status == http.StatusConflict {
+		return errGuestFundsReserved
+	}
+	if err != nil {
+		return fmt.Errorf("wallet merge: %w", err)
+	}
+	if _, err := callInternal(ctx, cfg.GameSessionURL, "/internal/sessions/reassign", payload); err != nil {
+		return fmt.Errorf("game history: %w", err)
+	}
+	return nil
+}
+
+// callInternal POSTs payload to another service's internal API. Any non-2xx
+// status is an error; the status is returned either way.
+func callInternal(ctx context.Context, baseURL, path string, payload interface{}) (int, error) {
+	if strings.TrimSpace(baseURL) == "" || cfg.InternalServiceKey == "" {
+		return 0, errors.New("internal service call not configured")
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return 0, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(baseURL, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Internal-Key", cfg.InternalServiceKey)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return resp.StatusCode, fmt.Errorf("%s returned %d: %s", path, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return resp.StatusCode, nil
+}
+
+// upgradedProviders drops "guest" from the current auth providers and adds
+// the newly linked ones.
+func upgradedProviders(current interface{}, added []string) []string {
+	var existing []string
+	switch v := current.(type) {
+	case primitive.A:
+		for _, p := range v {
+			if s, ok := p.(string); ok {
+				existing = append(existing, s)
+			}
+		}
+	case []string:
+		existing = v
+	}
+	out := make([]string, 0, len(existing)+len(added))
+	for _, p := range append(existing, added...) {
+		if p == "" || p == "guest" || containsString(out, p) {
+			continue
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+func respondIdentityInUse(w http.ResponseWriter) {
+	respondJSON(w, 409, map[string]string{
+		"error": "this identity already belongs to another account",
+		"code":  "IDENTITY_IN_USE",
+	})
+}
+
+// ============================================================
+// LINKED IDENTITIES
+// ============================================================
+
+// identityFields maps each linkable provider to the user field holding its
+// identifier.
+var identityFields = map[string]string{
+	"email":    "email",
+	"phone":    "phone",
+	"google":   "googleId",
+	"firebase": "firebaseUid",
+}
+
+// loginProviders is the order identities are listed in.
+var loginProviders = []string{"email", "phone", "google", "firebase"}
+
+const emailVerifyTTL = 15 * time.Minute
+
+func handleListIdentities(w http.ResponseWriter, r *http.Request) {
+	user, ok := loadCallerUser(w, r)
+	if !ok {
+		return
+	}
+	identities := make([]map[string]string, 0, len(loginProviders))
+	for _, provider := range loginMethods(user) {
+		value, _ := user[identityFields[provider]].(string)
+		identities = append(identities, map[string]string{"provider": provider, "identifier": value})
+	}
+	respondJSON(w, 200, map[string]interface{}{
+		"identities":    identities,
+		"authProviders": user["authProviders"],
+	})
+}
+
+// handleStartEmailVerification emails a code that proves ownership of an
+// address before it can be linked with method "email".
+func handleStartEmailVerification(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, ok := authClaimsFromContext(ctx)
+	if !ok {
+		respondError(w, 401, "unauthorized")
+		return
+	}
+	var body struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		respondError(w, 400, "invalid request")
+		return
+	}
+	email := strings.ToLower(strings.TrimSpace(body.Email))
+	if email == "" || !strings.Contains(email, "@") {
+		respondError(w, 400, "a valid email is required")
+		return
+	}
+	if !enforceBudgets(w, r,
+		budgetCheck{"verify_email_ip", clientIP(r), budgets.ForgotIP},
+		budgetCheck{"verify_email", email, budgets.ForgotEmail},
+	) {
+		return
+	}
+
+	code := generateOTP(otpLength)
+	key := emailVerifyKey(claims.UserID)
+	pipe := rdb.TxPipeline()
+	pipe.Del(ctx, key)
+	pipe.HSet(ctx, key, "email", email, "code", hashOTP(code), "attempts", 0)
+	pipe.Expire(ctx, key, emailVerifyTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		respondError(w, 500, "failed to start verification")
+		return
+	}
+	if err := mailClient.SendVerificationCode(ctx, email, code, int(emailVerifyTTL.Minutes())); err != nil {
+		log.Printf("⚠️  verification email to %s: %v", email, err)
+		respondError(w, 502, "failed to send verification email")
+		return
+	}
+	respondJSON(w, 202, map[string]interface{}{
+		"message":   "Verification code sent.",
+		"expiresIn": int(emailVerifyTTL.Seconds()),
+	})
+}
+
+// consumeEmailCode checks code against the pending verification for email
+// and clears it on success. Wrong guesses burn the code after OTPMaxAttempts.
+func consumeEmailCode(ctx context.Context, userID, email, code string) bool {
+	key := emailVerifyKey(userID)
+	pending, err := rdb.HGetAll(ctx, key).Result()
+	if err != nil || pending["email"] == "" || pending["email"] != email {
+		return false
+	}
+	if subtle.ConstantTimeCompare([]byte(pending["code"]), []byte(hashOTP(code))) != 1 {
+		attempts, _ := rdb.HIncrBy(ctx, key, "attempts", 1).Result()
+		if cfg.OTPMaxAttempts > 0 && attempts >= int64(cfg.OTPMaxAttempts) {
+			_ = rdb.Del(ctx, key).Err()
+		}
+		return false
+	}
+	_ = rdb.Del(ctx, key).Err()
+	return true
+}
+
+// handleLinkIdentity attaches another sign-in method to the caller's account.
+// The body is an identityRequest; every method proves ownership: an emailed
+// code for email, an SMS OTP for phone, a signed ID token for Google and
+// Firebase.
+func handleLinkIdentity(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, ok := loadCallerUser(w, r)
+	if !ok {
+		return
+	}
+	if isGuest, _ := user["isGuest"].(bool); isGuest {
+		respondError(w, 409, "guest accounts link identities through /guest/upgrade")
+		return
+	}
+	userID, err := extractUserID(user)
+	if err != nil {
+		respondError(w, 500, "user id missing")
+		return
+	}
+
+	var body identityRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		respondError(w, 400, "invalid request")
+		return
+	}
+	if strings.EqualFold(strings.TrimSpace(body.Method), "email") {
+		email := strings.ToLower(strings.TrimSpace(body.Email))
+		if body.Code == "" || !consumeEmailCode(ctx, userID, email, body.Code) {
+			respondError(w, 401, "invalid or expired email verification code")
+			return
+		}
+	}
+	identity, ok := resolveLinkedIdentity(w, r, &body)
+	if !ok {
+		return
+	}
+
+	field := identityFields[body.Method]
+	value, _ := identity.Set[field].(string)
+	if current, _ := user[field].(string); current != "" {
+		_, hasPassword := user["passwordHash"]
+		if current != value {
+			respondError(w, 409, fmt.Sprintf("a different %s is already linked; unlink it first", body.Method))
+			return
+		}
+		if body.Method != "email" || hasPassword {
+			respondError(w, 409, fmt.Sprintf("this %s is already linked", body.Method))
+			return
+		}
+	}
+
+	// The identifier and password are written; profile fields carried by the
+	// credential only fill blanks.
+	set := bson.M{}
+	for k, v := range identity.Set {
+		if k == field || k == "passwordHash" {
+			set[k] = v
+			continue
+		}
+		if existing, _ := user[k].(string); existing == "" {
+			set[k] = v
+		}
+	}
+	var taken []bson.M
+	for _, f := range identityFields {
+		if v, ok := set[f]; ok {
+			taken = append(taken, bson.M{f: v})
+		}
+	}
+	count, err := db.Collection("users").CountDocuments(ctx, bson.M{"_id": bson.M{"$ne": user["_id"]}, "$or": taken})
+	if err != nil {
+		respondError(w, 500, "failed to look up identity")
+		return
+	}
+	if count > 0 {
+		respondIdentityInUse(w)
+		return
+	}
+
+	set["updatedAt"] = time.Now()
+	_, err = db.Collection("users").UpdateOne(ctx, bson.M{"_id": user["_id"]}, bson.M{
+		"$set":      set,
+		"$addToSet": bson.M{"authProviders": bson.M{"$each": identity.Providers}},
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		respondIdentityInUse(w)
+		return
+	}
+	if err != nil {
+		respondError(w, 500, "failed to link identity")
+		return
+	}
+	log.Printf("🔗 User %s linked %s", userID, body.Method)
+	respondLinkedUser(w, r, user["_id"])
+}
+
+// handleUnlinkIdentity removes a sign-in method. The last one can't be
+// removed, and accounts with 2FA need a step-up token.
+func handleUnlinkIdentity(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, _ := authClaimsFromContext(ctx)
+	user, ok := loadCallerUser(w, r)
+	if !ok {
+		return
+	}
+	var body struct {
+		Provider string `json:"provider"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		respondError(w, 400, "invalid request")
+		return
+	}
+	provider := strings.ToLower(strings.TrimSpace(body.Provider))
+	field, known := identityFields[provider]
+	if !known {
+		respondError(w, 400, "provider must be email, phone, google or firebase")
+		return
+	}
+	methods := loginMethods(user)
+	if !containsString(methods, provider) {
+		respondError(w, 404, fmt.Sprintf("%s is not linked", provider))
+		return
+	}
+	if len(methods) == 1 {
+		respondJSON(w, 409, map[string]string{
+			"error": "you can't remove your only sign-in method",
+			"code":  "LAST_LOGIN_METHOD",
+		})
+		return
+	}
+	if totpState, _ := user["totp"].(bson.M); totpState != nil {
+		if enabled, _ := totpState["enabled"].(bool); enabled && !claims.Elevated {
+			respondJSON(w, 403, map[string]string{
+				"error": "step-up authentication required",
+				"code":  "STEP_UP_REQUIRED",
+			})
+			return
+		}
+	}
+
+	unset := bson.M{field: ""}
+	if provider == "email" {
+		unset["passwordHash"] = ""
+	}
+	_, err := db.Collection("users").UpdateOne(ctx, bson.M{"_id": user["_id"]}, bson.M{
+		"$unset": unset,
+		"$set": bson.M{
+			"authProviders": unlinkedProviders(user["authProviders"], provider),
+			"updatedAt":     time.Now(),
+		},
+	})
+	if err != nil {
+		respondError(w, 500, "failed to unlink identity")
+		return
+	}
+	log.Printf("🔗 User %s unlinked %s", claims.UserID, provider)
+	respondLinkedUser(w, r, user["_id"])
+}
+
+func respondLinkedUser(w http.ResponseWriter, r *http.Request, id interface{}) {
+	var updated bson.M
+	if err := db.Collection("users").FindOne(r.Context(), bson.M{"_id": id}).Decode(&updated); err != nil {
+		respondError(w, 500, "failed to load user")
+		return
+	}
+	respondJSON(w, 200, map[string]interface{}{
+		"user":       sanitizeUser(updated),
+		"identities": loginMethods(updated),
+	})
+}
+
+// loadCallerUser loads the authenticated user's document, responding on
+// failure.
+func loadCallerUser(w http.ResponseWriter, r *http.Request) (bson.M, bool) {
+	claims, ok := authClaimsFromContext(r.Context())
+	if !ok {
+		respondError(w, 401, "unauthorized")
+		return nil, false
+	}
+	oid, err := primitive.ObjectIDFromHex(claims.UserID)
+	if err != nil {
+		respondError(w, 400, "invalid user id")
+		return nil, false
+	}
+	var user bson.M
+	if err := db.Collection("users").FindOne(r.Context(), bson.M{"_id": oid}).Decode(&user); err != nil {
+		respondError(w, 404, "user not found")
+		return nil, false
+	}
+	return user, true
+}
+
+// loginMethods lists the providers the user can sign in with. An email only
+// counts once it has a password.
+func loginMethods(user bson.M) []string {
+	methods := make([]string, 0, len(loginProviders))
+	for _, provider := range loginProviders {
+		if value, _ := user[identityFields[provider]].(string); value == "" {
+			continue
+		}
+		if provider == "email" {
+			if hash, _ := user["passwordHash"].(string); hash == "" {
+				continue
+			}
+		}
+		methods = append(methods, provider)
+	}
+	return methods
+}
+
+// unlinkedProviders drops provider from the auth providers. Unlinking
+// Firebase also drops the sign-in providers it brought (apple, x, ...).
+func unlinkedProviders(current interface{}, provider string) []string {
+	out := []string{}
+	for _, p := range upgradedProviders(current, nil) {
+		if p == provider {
+			continue
+		}
+		if provider == "firebase" {
+			if _, core := identityFields[p]; !core {
+				continue
+			}
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+func emailVerifyKey(userID string) string {
+	return "email_verify:" + userID
+}
+
+// ============================================================
+// TOKEN MANAGEMENT
+// ============================================================
+
+func handleRefreshToken(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var body struct {
+		RefreshToken string `json:"refreshToken"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		respondError(w, 400, "invalid request")
+		return
+	}
+
+	newAccess, newRefresh, err := rotateRefreshToken(ctx, body.RefreshToken, r)
+	if err != nil {
+		if errors.Is(err, errRefreshTokenReused) {
+			respondError(w, 401, "refresh token already used; session revoked")
+			return
+		}
+		if errors.Is(err, errInvalidRefreshToken) {
+			respondError(w, 401, "invalid or expired refresh token")
+			return
+		}
+		respondError(w, 500, "failed to issue new tokens")
+		return
+	}
+
+	respondJSON(w, 200, map[string]string{
+		"accessToken":  newAccess,
+		"refreshToken": newRefresh,
+	})
+}
+
+func handleLogout(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		RefreshToken string `json:"refreshToken"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&body)
+	if body.RefreshToken != "" {
+		if sessionID := sessionIDForToken(r.Context(), body.RefreshToken); sessionID != "" {
+			revokeSession(r.Context(), sessionID)
+		} else {
+			deleteRefreshToken(r.Context(), body.RefreshToken)
+		}
+	}
+	respondJSON(w, 200, map[string]string{"message": "logged out"})
+}
+
+// ============================================================
+// SESSIONS
+// ============================================================
+
+func handleListSessions(w http.ResponseWriter, r *http.Request) {
+	claims, ok := authClaimsFromContext(r.Context())
+	if !ok {
+		respondError(w, 401, "unauthorized")
+		return
+	}
+	sessions, err := listSessions(r.Context(), claims.UserID)
+	if err != nil {
+		respondError(w, 500, "could not load sessions")
+		return
+	}
+	items := make([]map[string]interface{}, 0, len(sessions))
+	for _, sess := range sessions {
+		items = append(items, map[string]interface{}{
+			"sessionId":  sess.SessionID,
+			"deviceName": sess.DeviceName,
+			"platform":   sess.Platform,
+			"userAgent":  sess.UserAgent,
+			"ip":         sess.IP,
+			"createdAt":  sess.CreatedAt,
+			"lastUsedAt": sess.LastUsedAt,
+		})
+	}
+	respondJSON(w, 200, map[string]interface{}{"sessions": items})
+}
+
+func handleRevokeSession(w http.ResponseWriter, r *http.Request) {
+	claims, ok := authClaimsFromContext(r.Context())
+	if !ok {
+		respondError(w, 401, "unauthorized")
+		return
+	}
+	var body struct {
+		SessionID string `json:"sessionId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.SessionID == "" {
+		respondError(w, 400, "sessionId is required")
+		return
+	}
+	sess, err := loadSession(r.Context(), body.SessionID)
+	if err != nil || sess.UserID != claims.UserID {
+		respondError(w, 404, "session not found")
+		return
+	}
+	revokeSession(r.Context(), body.SessionID)
+	respondJSON(w, 200, map[string]string{"message": "session revoked"})
+}
+
+func handleLogoutAll(w http.ResponseWriter, r *http.Request) {
+	claims, ok := authClaimsFromContext(r.Context())
+	if !ok {
+		respondError(w, 401, "unauthorized")
+		return
+	}
+	revoked := revokeAllSessions(r.Context(), claims.UserID)
+	respondJSON(w, 200, map[string]interface{}{
+		"message": "logged out everywhere",
+		"revoked": revoked,
+	})
+}
+
+func handleGetProfile(w http.ResponseWriter, r *http.Request) {
+	claims, ok := authClaimsFromContext(r.Context())
+	if !ok {
+		respondError(w, 401, "unauthorized")
+		return
+	}
+	oid, err := primitive.ObjectIDFromHex(claims.UserID)
+	if err != nil {
+		respondError(w, 400, "invalid user id")
+		return
+	}
+	var user bson.M
+	if err := db.Collection("users").FindOne(r.Context(), bson.M{"_id": oid}).Decode(&user); err != nil {
+		respondError(w, 404, "user not found")
+		return
+	}
+	respondJSON(w, 200, sanitizeUser(user))
+}
+
+// ============================================================
+// KYC
+// ============================================================
+
+// kycDocument is one uploaded file on a KYC request. Key locates the blob in
+// kycStore and never leaves the service.
+type kycDocument struct {
+	ID          string    `bson:"id" json:"id"`
+	Kind        string    `bson:"kind" json:"kind"`
+	Key         string    `bson:"key" json:"-"`
+	ContentType string    `bson:"contentType" json:"contentType"`
+	Size        int64     `bson:"size" json:"size"`
+	UploadedAt  time.Time `bson:"uploadedAt" json:"uploadedAt"`
+}
+
+type kycRequest struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID         string             `bson:"userId" json:"userId"`
+	DocumentType   string             `bson:"documentType" json:"documentType"`
+	DocumentNumber string             `bson:"documentNumber" json:"documentNumber"`
+	Status         string             `bson:"status" json:"status"`
+	Documents      []kycDocument      `bson:"documents" json:"documents"`
+	Level          int                `bson:"level,omitempty" json:"level,omitempty"`
+	ReviewNote     string             `bson:"reviewNote,omitempty" json:"reviewNote,omitempty"`
+	ReviewedBy     string             `bson:"reviewedBy,omitempty" json:"reviewedBy,omitempty"`
+	ReviewedAt     *time.Time         `bson:"reviewedAt,omitempty" json:"reviewedAt,omitempty"`
+	SubmittedAt    *time.Time         `bson:"submittedAt,omitempty" json:"submittedAt,omitempty"`
+	CreatedAt      time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt      time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
+func (req *kycRequest) documentKinds() []string {
+	kinds := make([]string, 0, len(req.Documents))
+	for _, doc := range req.Documents {
+		kinds = append(kinds, doc.Kind)
+	}
+	return kinds
+}
+
+func handleKYCStatus(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, ok := authClaimsFromContext(ctx)
+	if !ok {
+		respondError(w, 401, "unauthorized")
+		return
+	}
+	oid, err := primitive.ObjectIDFromHex(claims.UserID)
+	if err != nil {
+		respondError(w, 400, "invalid user id")
+		return
+	}
+	var user struct {
+		KYCStatus string `bson:"kycStatus"`
+		KYCLevel  int    `bson:"kycLevel"`
+	}
+	if err := db.Collection("users").FindOne(ctx, bson.M{"_id": oid}).Decode(&user); err != nil {
+		respondError(w, 404, "user not found")
+		return
+	}
+	if user.KYCStatus == "" {
+		user.KYCStatus = kyc.StatusNone
+	}
+	out := map[string]interface{}{
+		"kycStatus": user.KYCStatus,
+		"kycLevel":  user.KYCLevel,
+	}
+	var latest kycRequest
+	err = db.Collection("kyc_requests").FindOne(ctx,
+		bson.M{"userId": claims.UserID},
+		options.FindOne().SetSort(bson.D{{Key: "createdAt", Value: -1}}),
+	).Decode(&latest)
+	if err == nil {
+		out["request"] = latest
+		out["missingDocuments"] = kyc.Missing(latest.documentKinds(), kyc.LevelIdentity)
+	}
+	respondJSON(w, 200, out)
+}
+
+// handleKYCInitiate opens a verification request, or returns the caller's
+// request that is still collecting documents.
+func handleKYCInitiate(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, ok := authClaimsFromContext(ctx)
+	if !ok {
+		respondError(w, 401, "unauthorized")
+		return
+	}
+
+	var body struct {
+		DocumentType   string `json:"documentType"`
+		DocumentNumber string `json:"documentNumber"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.DocumentType == "" || body.DocumentNumber == "" {
+		respondError(w, 400, "documentType and documentNumber are required")
+		return
+	}
+
+	oid, err := primitive.ObjectIDFromHex(claims.UserID)
+	if err != nil {
+		respondError(w, 400, "invalid user id")
+		return
+	}
+
+	var current kycRequest
+	err = db.Collection("kyc_requests").FindOne(ctx, bson.M{
+		"userId": claims.UserID,
+		"status": bson.M{"$in": []string{kyc.StatusAwaitingDocuments, kyc.StatusMoreInfo, kyc.StatusPending}},
+	}).Decode(&current)
+	if err == nil {
+		if current.Status == kyc.StatusPending {
+			respondError(w, 409, "a verification request is already under review")
+			return
+		}
+		respondJSON(w, 200, map[string]interface{}{
+			"requestId":        current.ID.Hex(),
+			"status":           current.Status,
+			"missingDocuments": kyc.Missing(current.documentKinds(), kyc.LevelIdentity),
+		})
+		return
+	}
+	if err != mongo.ErrNoDocuments {
+		respondError(w, 500, "failed to load KYC request")
+		return
+	}
+
+	now := time.Now()
+	request := kycRequest{
+		ID:             primitive.NewObjectID(),
+		UserID:         claims.UserID,
+		DocumentType:   strings.TrimSpace(body.DocumentType),
+		DocumentNumber: strings.TrimSpace(body.DocumentNumber),
+		Status:         kyc.StatusAwaitingDocuments,
+		Documents:      []kycDocument{},
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if _, err := db.Collection("kyc_requests").InsertOne(ctx, request); err != nil {
+		respondError(w, 500, "failed to start KYC review")
+		return
+	}
+	_, _ = db.Collection("users").UpdateByID(ctx, oid, bson.M{"$set": bson.M{"kycStatus": kyc.StatusAwaitingDocuments}})
+
+	respondJSON(w, 201, map[string]interface{}{
+		"requestId":        request.ID.Hex(),
+		"status":           request.Status,
+		"missingDocuments": kyc.Missing(nil, kyc.LevelIdentity),
+		"message":          "Upload your documents, then submit the request for review.",
+	})
+}
+
+// handleKYCUploadDocument stores one document (?kind=id_front|id_back|selfie|
+// proof_of_address) on the caller's open request. The file is the raw body or
+// the "file" part of a multipart form; uploading a kind again replaces it.
+func handleKYCUploadDocument(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, ok := authClaimsFromContext(ctx)
+	if !ok {
+		respondError(w, 401, "unauthorized")
+		return
+	}
+	kind := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("kind")))
+	if !kyc.IsDocumentKind(kind) {
+		respondError(w, 400, "kind must be id_front, id_back, selfie or proof_of_address")
+		return
+	}
+	request, err := openKYCRequest(ctx, claims.UserID)
+	if err == mongo.ErrNoDocuments {
+		respondError(w, 409, "no open verification request; call /kyc/initiate first")
+		return
+	}
+	if err != nil {
+		respondError(w, 500, "failed to load KYC request")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, cfg.KYCMaxUploadBytes)
+	file := io.Reader(r.Body)
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		part, _, err := r.FormFile("file")
+		if err != nil {
+			respondError(w, 400, "multipart upload needs a file field")
+			return
+		}
+		defer part.Close()
+		file = part
+	}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		respondError(w, 400, "empty or unreadable upload")
+		return
+	}
+	head = head[:n]
+	contentType, ext, ok := kyc.SniffContentType(head)
+	if !ok {
+		respondError(w, 415, "documents must be JPEG, PNG or PDF")
+		return
+	}
+
+	docID := primitive.NewObjectID().Hex()
+	key := fmt.Sprintf("%s/%s/%s%s", claims.UserID, request.ID.Hex(), docID, ext)
+	size, err := kycStore.Put(ctx, key, io.MultiReader(bytes.NewReader(head), file))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			respondError(w, 413, fmt.Sprintf("documents are limited to %d MB", cfg.KYCMaxUploadBytes>>20))
+			return
+		}
+		log.Printf("⚠️  KYC upload for %s: %v", claims.UserID, err)
+		respondError(w, 500, "failed to store document")
+		return
+	}
+
+	doc := kycDocument{
+		ID:          docID,
+		Kind:        kind,
+		Key:         key,
+		ContentType: contentType,
+		Size:        size,
+		UploadedAt:  time.Now(),
+	}
+	openFilter := bson.M{"_id": request.ID, "status": bson.M{"$in": []string{kyc.StatusAwaitingDocuments, kyc.StatusMoreInfo}}}
+	requests := db.Collection("kyc_requests")
+	_, err = requests.UpdateOne(ctx, openFilter, bson.M{"$pull": bson.M{"documents": bson.M{"kind": kind}}})
+	if err == nil {
+		var res *mongo.UpdateResult
+		res, err = requests.UpdateOne(ctx, openFilter, bson.M{
+			"$push": bson.M{"documents": doc},
+			"$set":  bson.M{"updatedAt": doc.UploadedAt},
+		})
+		if err == nil && res.MatchedCount == 0 {
+			err = errors.New("request is no longer open")
+		}
+	}
+	if err != nil {
+		_ = kycStore.Delete(ctx, key)
+		respondError(w, 409, "verification request is no longer accepting documents")
+		return
+	}
+	for _, old := range request.Documents {
+		if old.Kind == kind {
+			_ = kycStore.Delete(ctx, old.Key)
+		}
+	}
+
+	kinds := append(request.documentKinds(), kind)
+	respondJSON(w, 201, map[string]interface{}{
+		"document":         doc,
+		"missingDocuments": kyc.Missing(kinds, kyc.LevelIdentity),
+	})
+}
+
+// handleKYCSubmit puts the caller's open request in the review queue once it
+// has the documents for the first verification level.
+func handleKYCSubmit(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, ok := authClaimsFromContext(ctx)
+	if !ok {
+		respondError(w, 401, "unauthorized")
+		return
+	}
+	request, err := openKYCRequest(ctx, claims.UserID)
+	if err == mongo.ErrNoDocuments {
+		respondError(w, 409, "no open verification request")
+		return
+	}
+	if err != nil {
+		respondError(w, 500, "failed to load KYC request")
+		return
+	}
+	if missing := kyc.Missing(request.documentKinds(), kyc.LevelIdentity); len(missing) > 0 {
+		respondJSON(w, 400, map[string]interface{}{
+			"error":            "required documents are missing",
+			"missingDocuments": missing,
+		})
+		return
+	}
+
+	now := time.Now()
+	res, err := db.Collection("kyc_requests").UpdateOne(ctx,
+		bson.M{"_id": request.ID, "status": request.Status},
+		bson.M{"$set": bson.M{"status": kyc.StatusPending, "submittedAt": now, "updatedAt": now}},
+	)
+	if err != nil || res.MatchedCount == 0 {
+		respondError(w, 409, "verification request changed, please retry")
+		return
+	}
+	oid, _ := primitive.ObjectIDFromHex(claims.UserID)
+	_, _ = db.Collection("users").UpdateByID(ctx, oid, bson.M{"$set": bson.M{"kycStatus": kyc.StatusPending}})
+
+	respondJSON(w, 202, map[string]interface{}{
+		"requestId": request.ID.Hex(),
+		"status":    kyc.StatusPending,
+		"message":   "KYC review initiated. You will be notified once verification is complete.",
+	})
+}
+
+func openKYCRequest(ctx context.Context, userID string) (*kycRequest, error) {
+	var request kycRequest
+	err := db.Collection("kyc_requests").FindOne(ctx,
+		bson.M{"userId": userID, "status": bson.M{"$in": []string{kyc.StatusAwaitingDocuments, kyc.StatusMoreInfo}}},
+		options.FindOne().SetSort(bson.D{{Key: "createdAt", Value: -1}}),
+	).Decode(&request)
+	if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+// handleKYCQueue lists requests for reviewers, oldest submission first.
+// ?status= defaults to PENDING.
+func handleKYCQueue(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	status := strings.ToUpper(strings.TrimSpace(r.URL.Query().Get("status")))
+	if status == "" {
+		status = kyc.StatusPending
+	}
+	limit, _ := strconv.ParseInt(r.URL.Query().Get("limit"), 10, 64)
+	if limit <= 0 || limit > 100 {
+		limit = 50
+	}
+	cursor, err := db.Collection("kyc_requests").Find(ctx,
+		bson.M{"status": status},
+		options.Find().SetSort(bson.D{{Key: "submittedAt", Value: 1}, {Key: "createdAt", Value: 1}}).SetLimit(limit),
+	)
+	if err != nil {
+		respondError(w, 500, "failed to load KYC queue")
+		return
+	}
+	requests := []kycRequest{}
+	if err := cursor.All(ctx, &requests); err != nil {
+		respondError(w, 500, "failed to load KYC queue")
+		return
+	}
+	respondJSON(w, 200, map[string]interface{}{"status": status, "requests": requests})
+}
+
+func handleKYCGetRequest(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	request, ok := loadKYCRequest(w, r, r.URL.Query().Get("id"))
+	if !ok {
+		return
+	}
+	out := map[string]interface{}{
+		"request":  request,
+		"maxLevel": kyc.MaxLevel(request.documentKinds()),
+	}
+	if oid, err := primitive.ObjectIDFromHex(request.UserID); err == nil {
+		var user bson.M
+		if err := db.Collection("users").FindOne(ctx, bson.M{"_id": oid}).Decode(&user); err == nil {
+			out["user"] = sanitizeUser(user)
+		}
+	}
+	respondJSON(w, 200, out)
+}
+
+// handleKYCDocument streams one uploaded document to a reviewer.
+func handleKYCDocument(w http.ResponseWriter, r *http.Request) {
+	claims, _ := authClaimsFromContext(r.Context())
+	request, ok := loadKYCRequest(w, r, r.URL.Query().Get("requestId"))
+	if !ok {
+		return
+	}
+	documentID := r.URL.Query().Get("documentId")
+	var doc *kycDocument
+	for i := range request.Documents {
+		if request.Documents[i].ID == documentID {
+			doc = &request.Documents[i]
+			break
+		}
+	}
+	if doc == nil {
+		respondError(w, 404, "document not found")
+		return
+	}
+	rc, err := kycStore.Open(r.Context(), doc.Key)
+	if err != nil {
+		respondError(w, 404, "document not found")
+		return
+	}
+	defer rc.Close()
+	log.Printf("🪪 KYC document %s of request %s viewed by %s", doc.ID, request.ID.Hex(), claims.UserID)
+	w.Header().Set("Content-Type", doc.ContentType)
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Disposition", "inline")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(200)
+	_, _ = io.Copy(w, rc)
+}
+
+func handleKYCApprove(w http.ResponseWriter, r *http.Request) {
+	reviewKYCRequest(w, r, kyc.StatusApproved)
+}
+
+func handleKYCReject(w http.ResponseWriter, r *http.Request) {
+	reviewKYCRequest(w, r, kyc.StatusRejected)
+}
+
+func handleKYCRequestInfo(w http.ResponseWriter, r *http.Request) {
+	reviewKYCRequest(w, r, kyc.StatusMoreInfo)
+}
+
+// reviewKYCRequest moves a PENDING request to status. Approval grants
+// ?level (default: the highest the documents support); rejection and
+// requests for more information need a reason the user will see.
+func reviewKYCRequest(w http.ResponseWriter, r *http.Request, status string) {
+	ctx := r.Context()
+	claims, ok := authClaimsFromContext(ctx)
+	if !ok {
+		respondError(w, 401, "unauthorized")
+		return
+	}
+	var body struct {
+		RequestID string `json:"requestId"`
+		Level     int    `json:"level"`
+		Reason    string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.RequestID == "" {
+		respondError(w, 400, "requestId is required")
+		return
+	}
+	body.Reason = truncate(strings.TrimSpace(body.Reason), 500)
+	if status != kyc.StatusApproved && body.Reason == "" {
+		respondError(w, 400, "reason is required")
+		return
+	}
+	request, ok := loadKYCRequest(w, r, body.RequestID)
+	if !ok {
+		return
+	}
+	if !kyc.CanTransition(request.Status, status) {
+		respondError(w, 409, fmt.Sprintf("request is %s", request.Status))
+		return
+	}
+
+	now := time.Now()
+	set := bson.M{
+		"status":     status,
+		"reviewNote": body.Reason,
+		"reviewedBy": claims.UserID,
+		"reviewedAt": now,
+		"updatedAt":  now,
+	}
+	if status == kyc.StatusApproved {
+		maxLevel := kyc.MaxLevel(request.documentKinds())
+		if body.Level == 0 {
+			body.Level = maxLevel
+		}
+		if body.Level < kyc.LevelIdentity || body.Level > maxLevel {
+			respondError(w, 400, fmt.Sprintf("documents support at most level %d", maxLevel))
+			return
+		}
+		set["level"] = body.Level
+	}
+	res, err := db.Collection("kyc_requests").UpdateOne(ctx,
+		bson.M{"_id": request.ID, "status": request.Status},
+		bson.M{"$set": set},
+	)
+	if err != nil {
+		respondError(w, 500, "failed to update request")
+		return
+	}
+	if res.MatchedCount == 0 {
+		respondError(w, 409, "request was reviewed by someone else")
+		return
+	}
+
+	userUpdate := bson.M{"$set": bson.M{"kycStatus": status, "updatedAt": now}}
+	if status == kyc.StatusApproved {
+		userUpdate["$set"].(bson.M)["kycVerifiedAt"] = now
+		userUpdate["$max"] = bson.M{"kycLevel": body.Level}
+	}
+	if oid, err := primitive.ObjectIDFromHex(request.UserID); err == nil {
+		if _, err := db.Collection("users").UpdateByID(ctx, oid, userUpdate); err != nil {
+			log.Printf("⚠️  KYC status for user %s: %v", request.UserID, err)
+		}
+	}
+	log.Printf("🪪 KYC request %s %s by %s", request.ID.Hex(), status, claims.UserID)
+	go notifyKYCStatus(request.UserID, status, body.Reason)
+
+	respondJSON(w, 200, map[string]interface{}{
+		"requestId": request.ID.Hex(),
+		"status":    status,
+		"level":     body.Level,
+	})
+}
+
+func loadKYCRequest(w http.ResponseWriter, r *http.Request, id string) (*kycRequest, bool) {
+	oid, err := primitive.ObjectIDFromHex(strings.TrimSpace(id))
+	if err != nil {
+		respondError(w, 400, "invalid request id")
+		return nil, false
+	}
+	var request kycRequest
+	if err := db.Collection("kyc_requests").FindOne(r.Context(), bson.M{"_id": oid}).Decode(&request); err != nil {
+		respondError(w, 404, "request not found")
+		return nil, false
+	}
+	return &request, true
+}
+
+// notifyKYCStatus tells the user about a review decision by email, or by SMS
+// for phone-only accounts. Failures are logged; the decision stands.
+func notifyKYCStatus(userID, status, reason string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	oid, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return
+	}
+	var user struct {
+		Email  string `bson:"email"`
+		Phone  string `bson:"phone"`
+		Locale string `bson:"locale"`
+	}
+	if err := db.Collection("users").FindOne(ctx, bson.M{"_id": oid}).Decode(&user); err != nil {
+		log.Printf("⚠️  KYC notice for %s: %v", userID, err)
+		return
+	}
+	switch {
+	case user.Email != "" && mailClient != nil:
+		err = mailClient.SendKYCStatus(ctx, user.Email, status, reason)
+	case user.Phone != "" && smsSender != nil:
+		locale := user.Locale
+		if locale == "" {
+			locale = cfg.SMSDefaultLocale
+		}
+		var text string
+		text, _, err = sms.Render(sms.TemplateKYCStatus, locale, sms.KYCStatusData{Status: status})
+		if err == nil {
+			_, err = smsSender.Send(ctx, sms.Message{To: user.Phone, Body: text, Reference: "kyc-" + userID + "-" + strconv.FormatInt(time.Now().Unix(), 10)})
+		}
+	default:
+		return
+	}
+	if err != nil {
+		log.Printf("⚠️  KYC notice for %s: %v", userID, err)
+	}
+}
+
+// ============================================================
+// TWO-FACTOR AUTH
+// ============================================================
+
+const backupCodeCount = 10
+
+// twoFactorState is the `totp` sub-document on a user.
+type twoFactorState struct {
+	Secret        string    `bson:"secret,omitempty"`
+	Enabled       bool      `bson:"enabled"`
+	EnabledAt     time.Time `bson:"enabledAt,omitempty"`
+	LastStep      int64     `bson:"lastStep,omitempty"`
+	PendingSecret string    `bson:"pendingSecret,omitempty"`
+}
+
+type twoFactorUser struct {
+	ID          primitive.ObjectID `bson:"_id"`
+	Email       string             `bson:"email"`
+	Phone       string             `bson:"phone"`
+	Username    string             `bson:"username"`
+	IsGuest     bool               `bson:"isGuest"`
+	TOTP        twoFactorState     `bson:"totp"`
+	BackupCodes []string           `bson:"backupCodes"`
+}
+
+// loadTwoFactorUser loads the caller's 2FA state, responding on failure.
+func loadTwoFactorUser(w http.ResponseWriter, r *http.Request) (*token.Claims, *twoFactorUser, bool) {
+	claims, ok := authClaimsFromContext(r.Context())
+	if !ok {
+		respondError(w, 401, "unauthorized")
+		return nil, nil, false
+	}
+	oid, err := primitive.ObjectIDFromHex(claims.UserID)
+	if err != nil {
+		respondError(w, 400, "invalid user id")
+		return nil, nil, false
+	}
+	var user twoFactorUser
+	if err := db.Collection("users").FindOne(r.Context(), bson.M{"_id": oid}).Decode(&user); err != nil {
+		respondError(w, 404, "user not found")
+		return nil, nil, false
+	}
+	return claims, &user, true
+}
+
+// verifySecondFactor accepts a current TOTP code or an unused backup code.
+// Both are consumed atomically: a TOTP step can't be replayed and a backup
+// code works once.
+func verifySecondFactor(ctx context.Context, user *twoFactorUser, code string) (string, bool) {
+	if !user.TOTP.Enabled || strings.TrimSpace(code) == "" {
+		return "", false
+	}
+	if step, ok := totp.Verify(user.TOTP.Secret, code, time.Now()); ok {
+		res, err := db.Collection("users").UpdateOne(ctx,
+			bson.M{"_id": user.ID, "totp.lastStep": bson.M{"$not": bson.M{"$gte": step}}},
+			bson.M{"$set": bson.M{"totp.lastStep": step}},
+		)
+		return "totp", err == nil && res.ModifiedCount == 1
+	}
+	hash := totp.HashBackupCode(code)
+	res, err := db.Collection("users").UpdateOne(ctx,
+		bson.M{"_id": user.ID, "backupCodes": hash},
+		bson.M{"$pull": bson.M{"backupCodes": hash}},
+	)
+	return "backup_code", err == nil && res.ModifiedCount == 1
+}
+
+func readCode(r *http.Request) string {
+	var body struct {
+		Code string `json:"code"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&body)
+	return strings.TrimSpace(body.Code)
+}
+
+func handle2FAStatus(w http.ResponseWriter, r *http.Request) {
+	_, user, ok := loadTwoFactorUser(w, r)
+	if !ok {
+		return
+	}
+	out := map[string]interface{}{
+		"totpEnabled":          user.TOTP.Enabled,
+		"backupCodesRemaining": len(user.BackupCodes),
+	}
+	if user.TOTP.Enabled {
+		out["enabledAt"] = user.TOTP.EnabledAt
+	}
+	respondJSON(w, 200, out)
+}
+
+// handleTOTPEnroll starts enrolment with a fresh pending secret. 2FA stays off
+// until the user proves their app works via /totp/enable.
+func handleTOTPEnroll(w http.ResponseWriter, r *http.Request) {
+	_, user, ok := loadTwoFactorUser(w, r)
+	if !ok {
+		return
+	}
+	if user.IsGuest {
+		respondError(w, 403, "guest accounts cannot enable two-factor auth")
+		return
+	}
+	if user.TOTP.Enabled {
+		respondError(w, 409, "two-factor auth is already enabled")
+		return
+	}
+	secret, err := totp.NewSecret()
+	if err != nil {
+		respondError(w, 500, "could not generate secret")
+		return
+	}
+	if _, err := db.Collection("users").UpdateOne(r.Context(), bson.M{"_id": user.ID}, bson.M{"$set": bson.M{
+		"totp.pendingSecret": secret,
+		"totp.pendingAt":     time.Now().UTC(),
+	}}); err != nil {
+		respondError(w, 500, "could not start enrolment")
+		return
+	}
+
+	account := user.Email
+	if account == "" {
+		account = user.Phone
+	}
+	if account == "" {
+		account = user.Username
+	}
+	respondJSON(w, 200, map[string]string{
+		"secret":     secret,
+		"otpauthUri": totp.URI(cfg.TOTPIssuer, account, secret),
+	})
+}
+
+func handleTOTPEnable(w http.ResponseWriter, r *http.Request) {
+	_, user, ok := loadTwoFactorUser(w, r)
+	if !ok {
+		return
+	}
+	if user.TOTP.Enabled {
+		respondError(w, 409, "two-factor auth is already enabled")
+		return
+	}
+	if user.TOTP.PendingSecret == "" {
+		respondError(w, 400, "start enrolment first")
+		return
+	}
+	key := user.ID.Hex()
+	if !enforceLockout(w, r, lockScope2FA, key) {
+		return
+	}
+	step, valid := totp.Verify(user.TOTP.PendingSecret, readCode(r), time.Now())
+	if !valid {
+		recordAuthFailure(r.Context(), lockScope2FA, key)
+		respondError(w, 401, "invalid code")
+		return
+	}
+	codes, hashes, err := totp.NewBackupCodes(backupCodeCount)
+	if err != nil {
+		respondError(w, 500, "could not generate backup codes")
+		return
+	}
+	_, err = db.Collection("users").UpdateOne(r.Context(), bson.M{"_id": user.ID}, bson.M{
+		"$set": bson.M{
+			"totp.secret":    user.TOTP.PendingSecret,
+			"totp.enabled":   true,
+			"totp.enabledAt": time.Now().UTC(),
+			"totp.lastStep":  step,
+			"backupCodes":    hashes,
+		},
+		"$unset": bson.M{"totp.pendingSecret": "", "totp.pendingAt": ""},
+	})
+	if err != nil {
+		respondError(w, 500, "could not enable two-factor auth")
+		return
+	}
+	resetAuthFailures(r.Context(), lockScope2FA, key)
+	respondJSON(w, 200, map[string]interface{}{
+		"message":     "two-factor auth enabled",
+		"backupCodes": codes,
+	})
+}
+
+func handleTOTPDisable(w http.ResponseWriter, r *http.Request) {
+	_, user, ok := loadTwoFactorUser(w, r)
+	if !ok {
+		return
+	}
+	if !user.TOTP.Enabled {
+		respondError(w, 400, "two-factor auth is not enabled")
+		return
+	}
+	key := user.ID.Hex()
+	if !enforceLockout(w, r, lockScope2FA, key) {
+		return
+	}
+	if _, valid := verifySecondFactor(r.Context(), user, readCode(r)); !valid {
+		recordAuthFailure(r.Context(), lockScope2FA, key)
+		respondError(w, 401, "invalid code")
+		return
+	}
+	if _, err := db.Collection("users").UpdateOne(r.Context(), bson.M{"_id": user.ID}, bson.M{
+		"$unset": bson.M{"totp": "", "backupCodes": ""},
+	}); err != nil {
+		respondError(w, 500, "could not disable two-factor auth")
+		return
+	}
+	resetAuthFailures(r.Context(), lockScope2FA, key)
+	respondJSON(w, 200, map[string]string{"message": "two-factor auth disabled"})
+}
+
+func handleRegenerateBackupCodes(w http.ResponseWriter, r *http.Request) {
+	_, user, ok := loadTwoFactorUser(w, r)
+	if !ok {
+		return
+	}
+	key := user.ID.Hex()
+	if !enforceLockout(w, r, lockScope2FA, key) {
+		return
+	}
+	if _, valid := verifySecondFactor(r.Context(), user, readCode(r)); !valid {
+		recordAuthFailure(r.Context(), lockScope2FA, key)
+		respondError(w, 401, "invalid code")
+		return
+	}
+	codes, hashes, err := totp.NewBackupCodes(backupCodeCount)
+	if err != nil {
+		respondError(w, 500, "could not generate backup codes")
+		return
+	}
+	if _, err := db.Collection("users").UpdateOne(r.Context(), bson.M{"_id": user.ID}, bson.M{
+		"$set": bson.M{"backupCodes": hashes},
+	}); err != nil {
+		respondError(w, 500, "could not store backup codes")
+		return
+	}
+	resetAuthFailures(r.Context(), lockScope2FA, key)
+	respondJSON(w, 200, map[string]interface{}{"backupCodes": codes})
+}
+
+// handleStepUp exchanges a second factor for a short-lived elevated access
+// token, required by payment-gateway for withdrawals.
+func handleStepUp(w http.ResponseWriter, r *http.Request) {
+	claims, user, ok := loadTwoFactorUser(w, r)
+	if !ok {
+		return
+	}
+	if !user.TOTP.Enabled {
+		respondError(w, 412, "enable two-factor auth first")
+		return
+	}
+	key := user.ID.Hex()
+	if !enforceLockout(w, r, lockScope2FA, key) {
+		return
+	}
+	method, valid := verifySecondFactor(r.Context(), user, readCode(r))
+	if !valid {
+		recordAuthFailure(r.Context(), lockScope2FA, key)
+		respondError(w, 401, "invalid code")
+		return
+	}
+	resetAuthFailures(r.Context(), lockScope2FA, key)
+
+	elevated, err := jwtManager.IssueElevatedToken(claims.UserID, claims.Role, staffRoles(r.Context(), claims.UserID), cfg.StepUpTTL)
+	if err != nil {
+		respondError(w, 500, "could not issue token")
+		return
+	}
+	log.Printf("🔐 step-up for %s via %s", claims.UserID, method)
+	respondJSON(w, 200, map[string]interface{}{
+		"accessToken": elevated,
+		"elevated":    true,
+		"expiresIn":   int(cfg.StepUpTTL.Seconds()),
+	})
+}
+
+// ============================================================
+// ROLES
+// ============================================================
+
+// staffRoles loads the staff roles granted to userID. Lookup failures issue a
+// token without staff roles rather than failing the login.
+func staffRoles(ctx context.Context, userID string) []string {
+	oid, err := primitive.ObjectIDFromHex(userID)
+	if err != nil || db == nil {
+		return nil
+	}
+	var user struct {
+		Roles []string `bson:"roles"`
+	}
+	err = db.Collection("users").FindOne(ctx, bson.M{"_id": oid}, options.FindOne().SetProjection(bson.M{"roles": 1})).Decode(&user)
+	if err != nil {
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			log.Printf("⚠️  load roles for %s: %v", userID, err)
+		}
+		return nil
+	}
+	return normalizeRoles(user.Roles)
+}
+
+// normalizeRoles keeps known staff roles, deduplicated and sorted.
+func normalizeRoles(roles []string) []string {
+	seen := make(map[string]struct{}, len(roles))
+	out := make([]string, 0, len(roles))
+	for _, role := range roles {
+		role = strings.ToLower(strings.TrimSpace(role))
+		if !rbac.IsStaffRole(role) {
+			continue
+		}
+		if _, dup := seen[role]; dup {
+			continue
+		}
+		seen[role] = struct{}{}
+		out = append(out, role)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// bootstrapAdmins grants the admin role to the configured user ids / emails.
+func bootstrapAdmins(raw string) {
+	var ids []primitive.ObjectID
+	var emails []string
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if oid, err := primitive.ObjectIDFromHex(entry); err == nil {
+			ids = append(ids, oid)
+		} else {
+			emails = append(emails, strings.ToLower(entry))
+		}
+	}
+	if len(ids) == 0 && len(emails) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	res, err := db.Collection("users").UpdateMany(ctx,
+		bson.M{"$or": []bson.M{{"_id": bson.M{"$in": ids}}, {"email": bson.M{"$in": emails}}}},
+		bson.M{"$addToSet": bson.M{"roles": rbac.RoleAdmin}},
+	)
+	if err != nil {
+		log.Printf("⚠️  bootstrap admins: %v", err)
+		return
+	}
+	log.Printf("👑 Bootstrap admins: %d matched, %d granted", res.MatchedCount, res.ModifiedCount)
+}
+
+func handleGetRoles(w http.ResponseWriter, r *http.Request) {
+	oid, err := primitive.ObjectIDFromHex(strings.TrimSpace(r.URL.Query().Get("userId")))
+	if err != nil {
+		respondError(w, 400, "valid userId is required")
+		return
 	}
-	if _, err := db.Collection("users").InsertOne(ctx, doc); err != nil {
-		respondError(w, 500, "failed to create guest user")
+	var user bson.M
+	err = db.Collection("users").FindOne(r.Context(), bson.M{"_id": oid}).Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		respondError(w, 404, "user not found")
 		return
 	}
-	triggerWalletGeneration(userID.Hex())
-	accessToken, refreshToken, err := issueTokenPair(ctx, userID.Hex(), "guest")
 	if err != nil {
-		respondError(w, 500, "failed to issue tokens")
+		respondError(w, 500, "could not load user")
 		return
 	}
-
-	respondJSON(w, 201, map[string]interface{}{
-		"accessToken":  accessToken,
-		"refreshToken": refreshToken,
-		"user":         sanitizeUser(doc),
+	roles := staffRoles(r.Context(), oid.Hex())
+	respondJSON(w, 200, map[string]interface{}{
+		"userId":    oid.Hex(),
+		"roles":     roles,
+		"scopes":    rbac.ScopesFor(roles...),
+		"available": rbac.StaffRoles,
+		"updatedAt": user["rolesUpdatedAt"],
+		"updatedBy": user["rolesUpdatedBy"],
 	})
 }
 
-// ============================================================
-// TOKEN MANAGEMENT
-// ============================================================
-
-func handleRefreshToken(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+// handleSetRoles replaces a user's staff roles. Changes reach access tokens
+// on the user's next refresh.
+func handleSetRoles(w http.ResponseWriter, r *http.Request) {
+	claims, _ := authClaimsFromContext(r.Context())
 	var body struct {
-		RefreshToken string `json:"refreshToken"`
+		UserID string   `json:"userId"`
+		Roles  []string `json:"roles"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		respondError(w, 400, "invalid request")
 		return
 	}
-
-	session, err := getRefreshSession(ctx, body.RefreshToken)
+	oid, err := primitive.ObjectIDFromHex(strings.TrimSpace(body.UserID))
 	if err != nil {
-		if errors.Is(err, errInvalidRefreshToken) {
-			respondError(w, 401, "invalid or expired refresh token")
+		respondError(w, 400, "valid userId is required")
+		return
+	}
+	for _, role := range body.Roles {
+		if !rbac.IsStaffRole(strings.ToLower(strings.TrimSpace(role))) {
+			respondError(w, 400, "unknown role: "+role)
 			return
 		}
-		respondError(w, 500, "refresh lookup failed")
+	}
+	roles := normalizeRoles(body.Roles)
+	if oid.Hex() == claims.UserID && !containsString(roles, rbac.RoleAdmin) {
+		respondError(w, 400, "admins cannot remove their own admin role")
 		return
 	}
 
-	deleteRefreshToken(ctx, body.RefreshToken)
-	newAccess, newRefresh, err := issueTokenPair(ctx, session.UserID, session.Role)
+	res, err := db.Collection("users").UpdateOne(r.Context(), bson.M{"_id": oid}, bson.M{"$set": bson.M{
+		"roles":          roles,
+		"rolesUpdatedAt": time.Now().UTC(),
+		"rolesUpdatedBy": claims.UserID,
+	}})
 	if err != nil {
-		respondError(w, 500, "failed to issue new tokens")
+		respondError(w, 500, "could not update roles")
 		return
 	}
-
-	respondJSON(w, 200, map[string]string{
-		"accessToken":  newAccess,
-		"refreshToken": newRefresh,
+	if res.MatchedCount == 0 {
+		respondError(w, 404, "user not found")
+		return
+	}
+	log.Printf("👑 roles for %s set to %v by %s", oid.Hex(), roles, claims.UserID)
+	respondJSON(w, 200, map[string]interface{}{
+		"userId": oid.Hex(),
+		"roles":  roles,
+		"scopes": rbac.ScopesFor(roles...),
 	})
 }
 
-func handleLogout(w http.ResponseWriter, r *http.Request) {
-	var body struct {
-		RefreshToken string `json:"refreshToken"`
+func containsString(values []string, target string) bool {
+	for _, v := range values {
+		if v == target {
+			return true
+		}
 	}
-	_ = json.NewDecoder(r.Body).Decode(&body)
-	if body.RefreshToken != "" {
-		deleteRefreshToken(r.Context(), body.RefreshToken)
+	return false
+}
+
+// ============================================================
+// RATE LIMITING
+// ============================================================
+
+const (
+	lockScopeOTP   = "otp"
+	lockScopeLogin = "login"
+	lockScope2FA   = "2fa"
+)
+
+type authBudgets struct {
+	OTPRequestPhone ratelimit.Budget
+	OTPRequestIP    ratelimit.Budget
+	OTPVerifyIP     ratelimit.Budget
+	LoginIP         ratelimit.Budget
+	LoginEmail      ratelimit.Budget
+	ForgotEmail     ratelimit.Budget
+	ForgotIP        ratelimit.Budget
+}
+
+func loadAuthBudgets(cfg *config.Config) authBudgets {
+	return authBudgets{
+		OTPRequestPhone: ratelimit.ParseBudget(cfg.RateLimitOTPRequestPhone, ratelimit.Budget{Limit: 3, Window: 15 * time.Minute}),
+		OTPRequestIP:    ratelimit.ParseBudget(cfg.RateLimitOTPRequestIP, ratelimit.Budget{Limit: 10, Window: 15 * time.Minute}),
+		OTPVerifyIP:     ratelimit.ParseBudget(cfg.RateLimitOTPVerifyIP, ratelimit.Budget{Limit: 30, Window: 15 * time.Minute}),
+		LoginIP:         ratelimit.ParseBudget(cfg.RateLimitLoginIP, ratelimit.Budget{Limit: 20, Window: 15 * time.Minute}),
+		LoginEmail:      ratelimit.ParseBudget(cfg.RateLimitLoginEmail, ratelimit.Budget{Limit: 10, Window: 15 * time.Minute}),
+		ForgotEmail:     ratelimit.ParseBudget(cfg.RateLimitForgotEmail, ratelimit.Budget{Limit: 3, Window: time.Hour}),
+		ForgotIP:        ratelimit.ParseBudget(cfg.RateLimitForgotIP, ratelimit.Budget{Limit: 10, Window: time.Hour}),
 	}
-	respondJSON(w, 200, map[string]string{"message": "logged out"})
 }
 
-func handleGetProfile(w http.ResponseWriter, r *http.Request) {
-	claims, ok := authClaimsFromContext(r.Context())
-	if !ok {
-		respondError(w, 401, "unauthorized")
-		return
+// rateLimitScopes maps the admin endpoint's ?scope= to the lockout scope and
+// the budget names keyed by that identity.
+var rateLimitScopes = map[string]struct {
+	lockScope string
+	names     []string
+}{
+	"ip":    {"ip", []string{"otp_request_ip", "otp_verify_ip", "login_ip", "forgot_ip"}},
+	"phone": {lockScopeOTP, []string{"otp_request_phone"}},
+	"email": {lockScopeLogin, []string{"login_email", "forgot_email"}},
+}
+
+type budgetCheck struct {
+	name   string
+	key    string
+	budget ratelimit.Budget
+}
+
+// enforceBudgets counts a hit against every budget and responds 429 when one
+// is exhausted. Redis errors fail open so an outage does not block logins.
+func enforceBudgets(w http.ResponseWriter, r *http.Request, checks ...budgetCheck) bool {
+	if limiter == nil {
+		return true
 	}
-	oid, err := primitive.ObjectIDFromHex(claims.UserID)
+	for _, check := range checks {
+		decision, err := limiter.Allow(r.Context(), check.name, check.key, check.budget)
+		if err != nil {
+			log.Printf("⚠️  %v", err)
+			continue
+		}
+		if !decision.Allowed {
+			log.Printf("rate limit %s exceeded for %s (%s)", check.name, check.key, check.budget)
+			respondTooManyRequests(w, decision.RetryAfter, "too many requests, please try again later")
+			return false
+		}
+	}
+	return true
+}
+
+// enforceLockout rejects identities locked after repeated failures.
+func enforceLockout(w http.ResponseWriter, r *http.Request, scope, key string) bool {
+	if limiter == nil || key == "" {
+		return true
+	}
+	locked, err := limiter.Locked(r.Context(), scope, key)
 	if err != nil {
-		respondError(w, 400, "invalid user id")
+		log.Printf("⚠️  lockout check %s: %v", scope, err)
+		return true
+	}
+	if locked > 0 {
+		respondTooManyRequests(w, locked, "too many failed attempts, try again later")
+		return false
+	}
+	return true
+}
+
+func recordAuthFailure(ctx context.Context, scope, key string) {
+	if limiter == nil || key == "" {
 		return
 	}
-	var user bson.M
-	if err := db.Collection("users").FindOne(r.Context(), bson.M{"_id": oid}).Decode(&user); err != nil {
-		respondError(w, 404, "user not found")
+	lock, err := limiter.RecordFailure(ctx, scope, key)
+	if err != nil {
+		log.Printf("⚠️  record %s failure: %v", scope, err)
 		return
 	}
-	respondJSON(w, 200, sanitizeUser(user))
+	if lock > 0 {
+		log.Printf("🔒 %s locked for %s after repeated failures (%s)", key, lock, scope)
+	}
 }
 
-func handleKYCInitiate(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-	claims, ok := authClaimsFromContext(ctx)
-	if !ok {
-		respondError(w, 401, "unauthorized")
+func resetAuthFailures(ctx context.Context, scope, key string) {
+	if limiter == nil || key == "" {
 		return
 	}
+	if err := limiter.Reset(ctx, scope, key); err != nil {
+		log.Printf("⚠️  reset %s failures: %v", scope, err)
+	}
+}
 
-	var body struct {
-		DocumentType   string `json:"documentType"`
-		DocumentNumber string `json:"documentNumber"`
+// recordOTPMiss counts a wrong guess on the OTP document and burns the code
+// once OTPMaxAttempts is reached. It reports whether the code was burned.
+func recordOTPMiss(ctx context.Context, otpDoc bson.M) bool {
+	res := db.Collection("otps").FindOneAndUpdate(ctx,
+		bson.M{"_id": otpDoc["_id"]},
+		bson.M{"$inc": bson.M{"attempts": 1}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	)
+	var updated struct {
+		Attempts int `bson:"attempts"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.DocumentType == "" || body.DocumentNumber == "" {
-		respondError(w, 400, "documentType and documentNumber are required")
-		return
+	if err := res.Decode(&updated); err != nil {
+		return false
+	}
+	if cfg.OTPMaxAttempts <= 0 || updated.Attempts < cfg.OTPMaxAttempts {
+		return false
 	}
+	_, _ = db.Collection("otps").UpdateOne(ctx,
+		bson.M{"_id": otpDoc["_id"]},
+		bson.M{"$set": bson.M{"used": true, "invalidatedAt": time.Now()}},
+	)
+	return true
+}
 
-	oid, err := primitive.ObjectIDFromHex(claims.UserID)
+func respondTooManyRequests(w http.ResponseWriter, retryAfter time.Duration, message string) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	respondJSON(w, http.StatusTooManyRequests, map[string]interface{}{
+		"error":             message,
+		"retryAfterSeconds": seconds,
+	})
+}
+
+// clientIP returns the address that reached the first trusted proxy. Each
+// proxy appends its peer to X-Forwarded-For, so the list is read from the
+// right, skipping trusted proxies; anything further left was written by
+// the client and can't be believed. Requests not from a trusted proxy are
+// keyed on their own address.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
 	if err != nil {
-		respondError(w, 400, "invalid user id")
-		return
+		host = r.RemoteAddr
+	}
+	if !trustedProxy(host) {
+		return host
+	}
+	hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		host = hop
+		if !trustedProxy(hop) {
+			break
+		}
 	}
+	return host
+}
 
-	request := bson.M{
-		"userId":         claims.UserID,
-		"documentType":   body.DocumentType,
-		"documentNumber": body.DocumentNumber,
-		"status":         "PENDING",
-		"createdAt":      time.Now(),
-		"updatedAt":      time.Now(),
+func trustedProxy(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil || cfg == nil {
+		return false
 	}
-	if _, err := db.Collection("kyc_requests").InsertOne(ctx, request); err != nil {
-		respondError(w, 500, "failed to start KYC review")
+	for _, network := range cfg.TrustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// handleGetRateLimits shows counters, failures and lock state for one
+// identity: ?scope=ip|phone|email&key=...
+func handleGetRateLimits(w http.ResponseWriter, r *http.Request) {
+	scope, key := r.URL.Query().Get("scope"), strings.TrimSpace(r.URL.Query().Get("key"))
+	def, ok := rateLimitScopes[scope]
+	if !ok || key == "" {
+		respondError(w, 400, "scope (ip, phone or email) and key are required")
+		return
+	}
+	if scope == "email" {
+		key = strings.ToLower(key)
+	}
+	state, err := limiter.Inspect(r.Context(), def.lockScope, key, def.names)
+	if err != nil {
+		respondError(w, 500, "could not load rate limit state")
 		return
 	}
-	_, _ = db.Collection("users").UpdateByID(ctx, oid, bson.M{"$set": bson.M{"kycStatus": "PENDING"}})
+	respondJSON(w, 200, state)
+}
 
-	respondJSON(w, 202, map[string]interface{}{
-		"status":  "PENDING",
-		"message": "KYC review initiated. You will be notified once verification is complete.",
-	})
+// handleClearRateLimits lifts limits and lockouts for one identity.
+func handleClearRateLimits(w http.ResponseWriter, r *http.Request) {
+	scope, key := r.URL.Query().Get("scope"), strings.TrimSpace(r.URL.Query().Get("key"))
+	def, ok := rateLimitScopes[scope]
+	if !ok || key == "" {
+		respondError(w, 400, "scope (ip, phone or email) and key are required")
+		return
+	}
+	if scope == "email" {
+		key = strings.ToLower(key)
+	}
+	if err := limiter.Clear(r.Context(), def.lockScope, key, def.names); err != nil {
+		respondError(w, 500, "could not clear rate limit state")
+		return
+	}
+	claims, _ := authClaimsFromContext(r.Context())
+	if claims != nil {
+		log.Printf("admin %s cleared rate limits for %s=%s", claims.UserID, scope, key)
+	}
+	respondJSON(w, 200, map[string]string{"message": "rate limits cleared"})
 }
 
 // ============================================================
@@ -1333,14 +3530,16 @@ func setupIndexes() {
 		{Keys: bson.D{{Key: "googleId", Value: 1}}, Options: options.Index().SetUnique(true).SetSparse(true)},
 		{Keys: bson.D{{Key: "facebookId", Value: 1}}, Options: options.Index().SetUnique(true).SetSparse(true)},
 		{Keys: bson.D{{Key: "appleId", Value: 1}}, Options: options.Index().SetUnique(true).SetSparse(true)},
+		{Keys: bson.D{{Key: "roles", Value: 1}}, Options: options.Index().SetSparse(true)},
 	})
-	db.Collection("otps").Indexes().CreateOne(ctx, mongo.IndexModel{
-		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
-		Options: options.Index().SetExpireAfterSeconds(0),
+	db.Collection("otps").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		{Keys: bson.D{{Key: "providerMessageId", Value: 1}}, Options: options.Index().SetSparse(true)},
+		{Keys: bson.D{{Key: "reference", Value: 1}}, Options: options.Index().SetSparse(true)},
 	})
 	db.Collection("kyc_requests").Indexes().CreateMany(ctx, []mongo.IndexModel{
 		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: -1}}},
-		{Keys: bson.D{{Key: "status", Value: 1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "submittedAt", Value: 1}}},
 	})
 	db.Collection("password_resets").Indexes().CreateMany(ctx, []mongo.IndexModel{
 		{Keys: bson.D{{Key: "tokenHash", Value: 1}}, Options: options.Index().SetUnique(true)},
@@ -1349,11 +3548,11 @@ func setupIndexes() {
 	log.Println("MongoDB indexes ensured")
 }
 
-func issueTokenPair(ctx context.Context, userID, role string) (string, string, error) {
+func issueTokenPair(ctx context.Context, userID, role string, device deviceSession) (string, string, error) {
 	if jwtManager == nil {
 		return "", "", errors.New("token manager not initialised")
 	}
-	access, err := jwtManager.IssueAccessToken(userID, role)
+	access, err := jwtManager.IssueAccessToken(userID, role, staffRoles(ctx, userID))
 	if err != nil {
 		return "", "", err
 	}
@@ -1361,53 +3560,220 @@ func issueTokenPair(ctx context.Context, userID, role string) (string, string, e
 	if err != nil {
 		return "", "", err
 	}
-	if err := storeRefreshToken(ctx, userID, role, refresh); err != nil {
+	now := time.Now().UTC()
+	device.SessionID = primitive.NewObjectID().Hex()
+	device.UserID = userID
+	device.Role = role
+	device.CreatedAt = now
+	device.LastUsedAt = now
+	if err := storeSession(ctx, &device, refresh); err != nil {
 		return "", "", err
 	}
 	return access, refresh, nil
 }
 
-var errInvalidRefreshToken = errors.New("invalid refresh token")
+var (
+	errInvalidRefreshToken = errors.New("invalid refresh token")
+	errRefreshTokenReused  = errors.New("refresh token reuse detected")
+)
+
+// deviceSession is one signed-in device. Every refresh token belongs to
+// exactly one session; rotating the token keeps the session.
+type deviceSession struct {
+	SessionID  string    `json:"sessionId"`
+	UserID     string    `json:"userId"`
+	Role       string    `json:"role"`
+	DeviceName string    `json:"deviceName,omitempty"`
+	Platform   string    `json:"platform,omitempty"`
+	UserAgent  string    `json:"userAgent,omitempty"`
+	IP         string    `json:"ip,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastUsedAt time.Time `json:"lastUsedAt"`
+	TokenHash  string    `json:"tokenHash"`
+}
+
+// deviceFromRequest reads the optional X-Device-Name / X-Device-Platform
+// headers sent by clients alongside the caller's IP and user agent.
+func deviceFromRequest(r *http.Request) deviceSession {
+	return deviceSession{
+		DeviceName: truncate(strings.TrimSpace(r.Header.Get("X-Device-Name")), 80),
+		Platform:   truncate(strings.ToLower(strings.TrimSpace(r.Header.Get("X-Device-Platform"))), 32),
+		UserAgent:  truncate(r.UserAgent(), 256),
+		IP:         clientIP(r),
+	}
+}
+
+func truncate(value string, max int) string {
+	if len(value) > max {
+		return value[:max]
+	}
+	return value
+}
 
-func storeRefreshToken(ctx context.Context, userID, role, token string) error {
+// storeSession saves the session record, indexes it under the user and points
+// token at it. The session expires with its newest refresh token.
+func storeSession(ctx context.Context, sess *deviceSession, token string) error {
 	if rdb == nil {
 		return errors.New("redis client not initialised")
 	}
 	if token == "" {
 		return errors.New("refresh token empty")
 	}
-	session := refreshSession{UserID: userID, Role: role}
-	payload, err := json.Marshal(session)
+	sess.TokenHash = hashToken(token)
+	pointer, err := json.Marshal(refreshSession{UserID: sess.UserID, Role: sess.Role, SessionID: sess.SessionID})
+	if err != nil {
+		return err
+	}
+	record, err := json.Marshal(sess)
 	if err != nil {
 		return err
 	}
-	key := refreshKey(hashToken(token))
-	return rdb.Set(ctx, key, payload, cfg.RefreshTTL).Err()
+	pipe := rdb.TxPipeline()
+	pipe.Set(ctx, refreshKey(sess.TokenHash), pointer, cfg.RefreshTTL)
+	pipe.Set(ctx, sessionKey(sess.SessionID), record, cfg.RefreshTTL)
+	pipe.SAdd(ctx, userSessionsKey(sess.UserID), sess.SessionID)
+	pipe.Expire(ctx, userSessionsKey(sess.UserID), cfg.RefreshTTL)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func loadSession(ctx context.Context, sessionID string) (*deviceSession, error) {
+	raw, err := rdb.Get(ctx, sessionKey(sessionID)).Bytes()
+	if err != nil {
+		return nil, err
+	}
+	var sess deviceSession
+	if err := json.Unmarshal(raw, &sess); err != nil {
+		return nil, err
+	}
+	return &sess, nil
 }
 
-func getRefreshSession(ctx context.Context, token string) (*refreshSession, error) {
+// rotateRefreshToken consumes token and issues a new pair on the same session.
+// Presenting a token that was already rotated revokes the whole session, since
+// either the client or an attacker holds a stolen copy.
+func rotateRefreshToken(ctx context.Context, token string, r *http.Request) (string, string, error) {
 	if rdb == nil {
-		return nil, errors.New("redis client not initialised")
+		return "", "", errors.New("redis client not initialised")
 	}
 	if token == "" {
-		return nil, errInvalidRefreshToken
+		return "", "", errInvalidRefreshToken
+	}
+	hash := hashToken(token)
+	raw, err := rdb.GetDel(ctx, refreshKey(hash)).Result()
+	if errors.Is(err, redis.Nil) {
+		if sessionID, usedErr := rdb.Get(ctx, refreshUsedKey(hash)).Result(); usedErr == nil {
+			log.Printf("⚠️  refresh token reuse on session %s — revoking", sessionID)
+			revokeSession(ctx, sessionID)
+			return "", "", errRefreshTokenReused
+		}
+		return "", "", errInvalidRefreshToken
 	}
-	key := refreshKey(hashToken(token))
-	raw, err := rdb.Get(ctx, key).Result()
+	if err != nil {
+		return "", "", err
+	}
+	var pointer refreshSession
+	if err := json.Unmarshal([]byte(raw), &pointer); err != nil || pointer.UserID == "" {
+		return "", "", errInvalidRefreshToken
+	}
+
+	// Tokens issued before sessions were tracked start a fresh session.
+	if pointer.SessionID == "" {
+		return issueTokenPair(ctx, pointer.UserID, pointer.Role, deviceFromRequest(r))
+	}
+	_ = rdb.Set(ctx, refreshUsedKey(hash), pointer.SessionID, cfg.RefreshTTL).Err()
+
+	sess, err := loadSession(ctx, pointer.SessionID)
 	if err != nil {
 		if errors.Is(err, redis.Nil) {
-			return nil, errInvalidRefreshToken
+			return "", "", errInvalidRefreshToken
 		}
+		return "", "", err
+	}
+	access, err := jwtManager.IssueAccessToken(sess.UserID, sess.Role, staffRoles(ctx, sess.UserID))
+	if err != nil {
+		return "", "", err
+	}
+	refresh, err := generateSecureToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	current := deviceFromRequest(r)
+	sess.LastUsedAt = time.Now().UTC()
+	sess.IP = current.IP
+	if current.UserAgent != "" {
+		sess.UserAgent = current.UserAgent
+	}
+	if err := storeSession(ctx, sess, refresh); err != nil {
+		return "", "", err
+	}
+	return access, refresh, nil
+}
+
+// revokeSession deletes a session and its live refresh token.
+func revokeSession(ctx context.Context, sessionID string) {
+	sess, err := loadSession(ctx, sessionID)
+	if err != nil {
+		return
+	}
+	pipe := rdb.TxPipeline()
+	pipe.Del(ctx, refreshKey(sess.TokenHash), sessionKey(sessionID))
+	pipe.SRem(ctx, userSessionsKey(sess.UserID), sessionID)
+	_, _ = pipe.Exec(ctx)
+}
+
+// revokeAllSessions signs the user out on every device.
+func revokeAllSessions(ctx context.Context, userID string) int {
+	ids, err := rdb.SMembers(ctx, userSessionsKey(userID)).Result()
+	if err != nil {
+		return 0
+	}
+	for _, id := range ids {
+		revokeSession(ctx, id)
+	}
+	_ = rdb.Del(ctx, userSessionsKey(userID)).Err()
+	return len(ids)
+}
+
+// listSessions returns the user's live sessions, newest activity first, and
+// prunes index entries whose session has expired.
+func listSessions(ctx context.Context, userID string) ([]deviceSession, error) {
+	ids, err := rdb.SMembers(ctx, userSessionsKey(userID)).Result()
+	if err != nil {
 		return nil, err
 	}
-	var session refreshSession
-	if err := json.Unmarshal([]byte(raw), &session); err != nil {
-		return nil, errInvalidRefreshToken
+	sessions := make([]deviceSession, 0, len(ids))
+	for _, id := range ids {
+		sess, err := loadSession(ctx, id)
+		if errors.Is(err, redis.Nil) {
+			rdb.SRem(ctx, userSessionsKey(userID), id)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, *sess)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt)
+	})
+	return sessions, nil
+}
+
+// sessionIDForToken resolves the session a refresh token belongs to.
+func sessionIDForToken(ctx context.Context, token string) string {
+	if rdb == nil || token == "" {
+		return ""
+	}
+	raw, err := rdb.Get(ctx, refreshKey(hashToken(token))).Result()
+	if err != nil {
+		return ""
 	}
-	if session.UserID == "" {
-		return nil, errInvalidRefreshToken
+	var pointer refreshSession
+	if json.Unmarshal([]byte(raw), &pointer) != nil {
+		return ""
 	}
-	return &session, nil
+	return pointer.SessionID
 }
 
 func deleteRefreshToken(ctx context.Context, token string) {
@@ -1421,6 +3787,18 @@ func refreshKey(hash string) string {
 	return "refresh:" + hash
 }
 
+func refreshUsedKey(hash string) string {
+	return "refresh_used:" + hash
+}
+
+func sessionKey(sessionID string) string {
+	return "session:" + sessionID
+}
+
+func userSessionsKey(userID string) string {
+	return "user_sessions:" + userID
+}
+
 func hashToken(token string) string {
 	h := sha256.Sum256([]byte(token))
 	return hex.EncodeToString(h[:])
@@ -1508,7 +3886,7 @@ func sanitizeUser(user bson.M) map[string]interface{} {
 	if id, err := extractUserID(user); err == nil {
 		out["id"] = id
 	}
-	for _, k := range []string{"username", "fullName", "email", "phone", "avatarUrl", "tier", "kycStatus", "isGuest", "authProviders"} {
+	for _, k := range []string{"username", "fullName", "email", "phone", "avatarUrl", "tier", "kycStatus", "kycLevel", "isGuest", "authProviders", "roles"} {
 		if v, ok := user[k]; ok {
 			out[k] = v
 		}
@@ -1689,7 +4067,7 @@ func corsMiddleware(next http.Handler) http.Handler {
 			}
 		}
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Internal-Key")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Internal-Key, X-Device-Name, X-Device-Platform")
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusNoContent)
 			return
@@ -1720,6 +4098,22 @@ func requireAuth(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+// requireScope is requireAuth restricted to tokens granting scope.
+func requireScope(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return requireAuth(checkScope(scope, next))
+}
+
+func checkScope(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := authClaimsFromContext(r.Context())
+		if !ok || !claims.HasScope(scope) {
+			respondError(w, 403, "missing permission: "+scope)
+			return
+		}
+		next(w, r)
+	}
+}
+
 func respondJSON(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -1,12 +1,19 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
 	"gamehub/auth-service/internal/config"
+	"gamehub/auth-service/internal/token"
 )
 
 func TestRegisterRoutes_FirebaseLoginRouteExists(t *testing.T) {
@@ -86,3 +93,203 @@ func TestCorsMiddleware_BlocksDisallowedOrigins(t *testing.T) {
 		t.Fatalf("expected 403 for disallowed origin, got %d", rr.Code)
 	}
 }
+
+func TestClientIP_TrustsOnlyProxyHops(t *testing.T) {
+	originalCfg := cfg
+	defer func() { cfg = originalCfg }()
+
+	_, private, _ := net.ParseCIDR("10.0.0.0/8")
+	cfg = &config.Config{TrustedProxies: []*net.IPNet{private}}
+
+	cases := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		want       string
+	}{
+		{"direct client ignores header", "203.0.113.9:5000", "1.2.3.4", "203.0.113.9"},
+		{"gateway appends the client", "10.0.0.2:5000", "198.51.100.7", "198.51.100.7"},
+		{"spoofed left-most entry", "10.0.0.2:5000", "1.2.3.4, 198.51.100.7", "198.51.100.7"},
+		{"load balancer behind gateway", "10.0.0.2:5000", "1.2.3.4, 198.51.100.7, 10.0.0.9", "198.51.100.7"},
+		{"garbage stops the walk", "10.0.0.2:5000", "198.51.100.7, not-an-ip", "10.0.0.2"},
+		{"no header", "10.0.0.2:5000", "", "10.0.0.2"},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/email/login", nil)
+		req.RemoteAddr = tc.remoteAddr
+		if tc.forwarded != "" {
+			req.Header.Set("X-Forwarded-For", tc.forwarded)
+		}
+		if got := clientIP(req); got != tc.want {
+			t.Errorf("%s: clientIP = %s, want %s", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestRateLimitAdminRouteRequiresAuth(t *testing.T) {
+	originalCfg := cfg
+	defer func() { cfg = originalCfg }()
+	cfg = &config.Config{AllowedOrigins: "*"}
+
+	mux := http.NewServeMux()
+	registerRoutes(mux)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/auth/admin/rate-limits?scope=ip&key=1.2.3.4", nil)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without token, got %d", rr.Code)
+	}
+}
+
+func TestSessionRoutesRequireAuth(t *testing.T) {
+	originalCfg := cfg
+	defer func() { cfg = originalCfg }()
+	cfg = &config.Config{AllowedOrigins: "*"}
+
+	mux := http.NewServeMux()
+	registerRoutes(mux)
+
+	for _, tc := range []struct{ method, path string }{
+		{http.MethodGet, "/api/v1/auth/sessions"},
+		{http.MethodPost, "/api/v1/auth/sessions/revoke"},
+		{http.MethodPost, "/api/v1/auth/logout-all"},
+		{http.MethodPost, "/api/v1/auth/guest/upgrade"},
+		{http.MethodGet, "/api/v1/auth/identities"},
+		{http.MethodPost, "/api/v1/auth/identities/email/verify"},
+		{http.MethodPost, "/api/v1/auth/identities/link"},
+		{http.MethodPost, "/api/v1/auth/identities/unlink"},
+	} {
+		req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(`{}`))
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		if rr.Code != http.StatusUnauthorized {
+			t.Fatalf("%s %s: expected 401 without token, got %d", tc.method, tc.path, rr.Code)
+		}
+	}
+}
+
+func TestDeviceFromRequest(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/email/login", nil)
+	req.RemoteAddr = "10.0.0.5:4123"
+	req.Header.Set("X-Device-Name", "  Ama's Pixel  ")
+	req.Header.Set("X-Device-Platform", "Android")
+	req.Header.Set("User-Agent", "GloryGrid/1.4")
+
+	device := deviceFromRequest(req)
+	if device.DeviceName != "Ama's Pixel" || device.Platform != "android" {
+		t.Fatalf("unexpected device fields: %+v", device)
+	}
+	if device.IP != "10.0.0.5" || device.UserAgent != "GloryGrid/1.4" {
+		t.Fatalf("unexpected request fields: %+v", device)
+	}
+}
+
+func TestCheckScope(t *testing.T) {
+	handler := checkScope("roles:manage", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	for _, tc := range []struct {
+		claims *token.Claims
+		want   int
+	}{
+		{&token.Claims{UserID: "u1", Role: "user", Roles: []string{"support"}, Scopes: []string{"users:read"}}, http.StatusForbidden},
+		{&token.Claims{UserID: "u2", Role: "user", Roles: []string{"admin"}, Scopes: []string{"roles:manage"}}, http.StatusNoContent},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/auth/admin/roles", nil)
+		req = req.WithContext(context.WithValue(req.Context(), ctxUserKey, tc.claims))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != tc.want {
+			t.Fatalf("%v: expected %d, got %d", tc.claims.Roles, tc.want, rr.Code)
+		}
+	}
+}
+
+func TestNormalizeRoles(t *testing.T) {
+	got := normalizeRoles([]string{" Finance", "admin", "root", "finance", "user"})
+	if len(got) != 2 || got[0] != "admin" || got[1] != "finance" {
+		t.Fatalf("unexpected roles: %v", got)
+	}
+}
+
+func TestUpgradedProviders(t *testing.T) {
+	got := upgradedProviders(primitive.A{"guest", "firebase"}, []string{"firebase", "google"})
+	if len(got) != 2 || got[0] != "firebase" || got[1] != "google" {
+		t.Fatalf("unexpected providers: %v", got)
+	}
+}
+
+func TestLoginMethods(t *testing.T) {
+	user := bson.M{"email": "a@b.c", "phone": "+233200000000", "firebaseUid": "fb1"}
+	got := loginMethods(user)
+	if len(got) != 2 || got[0] != "phone" || got[1] != "firebase" {
+		t.Fatalf("email without a password must not count: %v", got)
+	}
+	user["passwordHash"] = "hash"
+	if got := loginMethods(user); len(got) != 3 || got[0] != "email" {
+		t.Fatalf("unexpected methods: %v", got)
+	}
+}
+
+func TestUnlinkedProviders(t *testing.T) {
+	current := primitive.A{"email", "firebase", "apple.com", "google"}
+	if got := unlinkedProviders(current, "google"); len(got) != 3 || got[2] != "apple.com" {
+		t.Fatalf("unexpected providers after google unlink: %v", got)
+	}
+	if got := unlinkedProviders(current, "firebase"); len(got) != 2 || got[0] != "email" || got[1] != "google" {
+		t.Fatalf("firebase unlink must drop its sign-in providers: %v", got)
+	}
+}
+
+func TestMergeGuestDataStopsOnReservedFunds(t *testing.T) {
+	var gameCalls int
+	wallet := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Internal-Key") != "k" || r.URL.Path != "/internal/ledger/merge-accounts" {
+			t.Errorf("unexpected wallet call %s key=%q", r.URL.Path, r.Header.Get("X-Internal-Key"))
+		}
+		w.WriteHeader(http.StatusConflict)
+	}))
+	defer wallet.Close()
+	games := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gameCalls++
+	}))
+	defer games.Close()
+
+	originalCfg := cfg
+	defer func() { cfg = originalCfg }()
+	cfg = &config.Config{InternalServiceKey: "k", WalletServiceURL: wallet.URL, GameSessionURL: games.URL}
+
+	err := mergeGuestData(context.Background(), "guest1", "user1")
+	if !errors.Is(err, errGuestFundsReserved) {
+		t.Fatalf("expected errGuestFundsReserved, got %v", err)
+	}
+	if gameCalls != 0 {
+		t.Fatalf("game history must not move when the wallet merge is refused")
+	}
+}
+
+func TestKYCRoutesRequireAuth(t *testing.T) {
+	originalCfg := cfg
+	defer func() { cfg = originalCfg }()
+	cfg = &config.Config{AllowedOrigins: "*"}
+
+	mux := http.NewServeMux()
+	registerRoutes(mux)
+
+	for _, tc := range []struct{ method, path string }{
+		{http.MethodGet, "/api/v1/auth/kyc"},
+		{http.MethodPost, "/api/v1/auth/kyc/documents?kind=selfie"},
+		{http.MethodPost, "/api/v1/auth/kyc/submit"},
+		{http.MethodGet, "/api/v1/auth/admin/kyc"},
+		{http.MethodPost, "/api/v1/auth/admin/kyc/approve"},
+	} {
+		req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(`{}`))
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		if rr.Code != http.StatusUnauthorized {
+			t.Fatalf("%s %s: expected 401 without token, got %d", tc.method, tc.path, rr.Code)
+		}
+	}
+}
@@ -25,12 +25,55 @@ type Config struct {
 	AllowedOrigins string
 	JWTIssuer      string
 
+	// Signing-key rotation: JWTKeysDir holds extra <kid>.pem keys published
+	// in the JWKS; JWTActiveKID picks which one signs (empty = primary key).
+	JWTKeyID     string
+	JWTKeysDir   string
+	JWTActiveKID string
+
 	// Hubtel SMS (OTP delivery)
 	HubtelSMSClientID     string
 	HubtelSMSClientSecret string
 	HubtelSMSFrom         string
+	HubtelSMSBaseURL      string
 	OTPTTLMinutes         string
 
+	// SMS delivery: SMSProvider is hubtel, webhook, file or console (empty =
+	// hubtel when its credentials are set, console otherwise).
+	SMSProvider      string
+	SMSWebhookURL    string
+	SMSWebhookToken  string
+	SMSFilePath      string
+	SMSDefaultLocale string
+	// SMSStatusSecret authenticates provider delivery-report callbacks.
+	SMSStatusSecret string
+
+	// Rate limiting. Budgets are "limit/window", e.g. "3/15m".
+	RateLimitPrefix          string
+	RateLimitOTPRequestPhone string
+	RateLimitOTPRequestIP    string
+	RateLimitOTPVerifyIP     string
+	RateLimitLoginIP         string
+	RateLimitLoginEmail      string
+	RateLimitForgotEmail     string
+	RateLimitForgotIP        string
+	// OTPMaxAttempts invalidates a code after this many wrong guesses.
+	OTPMaxAttempts int
+	// After LockoutThreshold failed verifications a phone/email is locked for
+	// LockoutBase, doubling per further failure up to LockoutMax.
+	LockoutThreshold int
+	LockoutBase      time.Duration
+	LockoutMax       time.Duration
+	// TrustedProxies are the networks whose X-Forwarded-For entries are
+	// believed (the gateway's, by default any private address). Requests
+	// from elsewhere are keyed on their own address.
+	TrustedProxies []*net.IPNet
+
+	// Two-factor auth: TOTPIssuer labels the authenticator entry; StepUpTTL
+	// bounds elevated tokens minted by /2fa/step-up.
+	TOTPIssuer string
+	StepUpTTL  time.Duration
+
 	// Google & Apple OAuth
 	GoogleClientID  string
 	GoogleClientIDs []string
@@ -42,6 +85,10 @@ type Config struct {
 	// Internal service key (for calls to wallet-service, etc.)
 	InternalServiceKey string
 
+	// RBAC: comma-separated user ids or emails granted the admin role at
+	// startup, so the first admin can manage everyone else's roles.
+	BootstrapAdmins string
+
 	// Email + password reset
 	ResendAPIKey            string
 	EmailFrom               string
@@ -49,7 +96,15 @@ type Config struct {
 	PasswordResetTTLMinutes string
 	PasswordResetTTL        time.Duration
 
+	// KYC document uploads: KYCStorageBackend is "local" (files under
+	// KYCStorageDir); uploads above KYCMaxUploadBytes are refused.
+	KYCStorageBackend string
+	KYCStorageDir     string
+	KYCMaxUploadBytes int64
+
 	PaymentGatewayURL string // e.g. http://payment-gateway:8003
+	WalletServiceURL  string // e.g. http://wallet-service:8004
+	GameSessionURL    string // e.g. http://game-session-service:8002
 
 	AppEnv   string
 	LogLevel string
@@ -70,23 +125,55 @@ func Load() *Config {
 		AllowedOrigins: getEnv("CORS_ALLOWED_ORIGINS", "*"),
 		JWTIssuer:      getEnv("JWT_ISSUER", "gamehub-auth"),
 
+		JWTKeyID:     getEnv("JWT_KEY_ID", ""),
+		JWTKeysDir:   getEnv("JWT_KEYS_DIR", ""),
+		JWTActiveKID: getEnv("JWT_ACTIVE_KID", ""),
+
 		HubtelSMSClientID:     getEnv("HUBTEL_SMS_CLIENT_ID", ""),
 		HubtelSMSClientSecret: getEnv("HUBTEL_SMS_CLIENT_SECRET", ""),
 		HubtelSMSFrom:         getEnv("HUBTEL_SMS_FROM", "Glory Grid"),
+		HubtelSMSBaseURL:      getEnv("HUBTEL_SMS_BASE_URL", "https://smsc.hubtel.com"),
 		OTPTTLMinutes:         getEnv("OTP_TTL_MINUTES", "5"),
 
+		SMSProvider:      getEnv("SMS_PROVIDER", ""),
+		SMSWebhookURL:    getEnv("SMS_WEBHOOK_URL", ""),
+		SMSWebhookToken:  getEnv("SMS_WEBHOOK_TOKEN", ""),
+		SMSFilePath:      getEnv("SMS_FILE_PATH", "sms-outbox.jsonl"),
+		SMSDefaultLocale: getEnv("SMS_DEFAULT_LOCALE", "en"),
+		SMSStatusSecret:  getEnv("SMS_STATUS_WEBHOOK_SECRET", ""),
+
+		RateLimitPrefix:          getEnv("RATE_LIMIT_PREFIX", "ratelimit"),
+		RateLimitOTPRequestPhone: getEnv("RATE_LIMIT_OTP_REQUEST_PHONE", "3/15m"),
+		RateLimitOTPRequestIP:    getEnv("RATE_LIMIT_OTP_REQUEST_IP", "10/15m"),
+		RateLimitOTPVerifyIP:     getEnv("RATE_LIMIT_OTP_VERIFY_IP", "30/15m"),
+		RateLimitLoginIP:         getEnv("RATE_LIMIT_LOGIN_IP", "20/15m"),
+		RateLimitLoginEmail:      getEnv("RATE_LIMIT_LOGIN_EMAIL", "10/15m"),
+		RateLimitForgotEmail:     getEnv("RATE_LIMIT_FORGOT_EMAIL", "3/1h"),
+		RateLimitForgotIP:        getEnv("RATE_LIMIT_FORGOT_IP", "10/1h"),
+		OTPMaxAttempts:           getEnvInt("OTP_MAX_ATTEMPTS", 5),
+		LockoutThreshold:         getEnvInt("AUTH_LOCKOUT_THRESHOLD", 5),
+
+		TOTPIssuer: getEnv("TOTP_ISSUER", "Glory Grid"),
+
 		GoogleClientID:    getEnv("GOOGLE_CLIENT_ID", ""),
 		AppleClientID:     getEnv("APPLE_CLIENT_ID", ""),
 		FirebaseProjectID: getEnv("FIREBASE_PROJECT_ID", ""),
 
 		InternalServiceKey: getEnv("INTERNAL_SERVICE_KEY", "dev-internal-key"),
+		BootstrapAdmins:    getEnv("RBAC_BOOTSTRAP_ADMINS", ""),
 
 		ResendAPIKey:            getEnv("RESEND_API_KEY", ""),
 		EmailFrom:               getEnv("EMAIL_FROM", "Glory Grid Support <support@glorygrid.local>"),
 		PasswordResetURL:        getEnv("PASSWORD_RESET_URL", "https://glorygrid.local/reset-password"),
 		PasswordResetTTLMinutes: getEnv("PASSWORD_RESET_TTL_MINUTES", "30"),
 
+		KYCStorageBackend: getEnv("KYC_STORAGE_BACKEND", "local"),
+		KYCStorageDir:     getEnv("KYC_STORAGE_DIR", "data/kyc"),
+		KYCMaxUploadBytes: int64(getEnvInt("KYC_MAX_UPLOAD_MB", 10)) << 20,
+
 		PaymentGatewayURL: getEnv("PAYMENT_GATEWAY_URL", "http://127.0.0.1:8003"),
+		WalletServiceURL:  getEnv("WALLET_SERVICE_URL", "http://127.0.0.1:8004"),
+		GameSessionURL:    getEnv("GAME_SESSION_URL", "http://127.0.0.1:8002"),
 
 		AppEnv:   getEnv("APP_ENV", "development"),
 		LogLevel: getEnv("LOG_LEVEL", "info"),
@@ -94,7 +181,11 @@ func Load() *Config {
 	cfg.AccessTTL = parseMinutes(cfg.AccessTTLMin, 15)
 	cfg.RefreshTTL = parseDays(cfg.RefreshTTLDays, 7)
 	cfg.PasswordResetTTL = parseMinutes(cfg.PasswordResetTTLMinutes, 30)
+	cfg.LockoutBase = parseMinutes(getEnv("AUTH_LOCKOUT_BASE_MINUTES", "1"), 1)
+	cfg.LockoutMax = parseMinutes(getEnv("AUTH_LOCKOUT_MAX_MINUTES", "60"), 60)
+	cfg.StepUpTTL = parseMinutes(getEnv("STEP_UP_TTL_MINUTES", "5"), 5)
 	cfg.GoogleClientIDs = splitCSV(getEnv("GOOGLE_CLIENT_IDS", cfg.GoogleClientID))
+	cfg.TrustedProxies = parseCIDRs(getEnv("TRUSTED_PROXY_CIDRS", "127.0.0.0/8,::1/128,10.0.0.0/8,172.16.0.0/12,192.168.0.0/16,fc00::/7"))
 	return cfg
 }
 
@@ -113,6 +204,15 @@ func getEnv(key, fallback string) string {
 	return fallback
 }
 
+func getEnvInt(key string, fallback int) int {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+	}
+	return fallback
+}
+
 func parseMinutes(raw string, fallback int) time.Duration {
 	if raw == "" {
 		return time.Duration(fallback) * time.Minute
@@ -137,6 +237,19 @@ func parseDays(raw string, fallback int) time.Duration {
 	return time.Duration(days) * 24 * time.Hour
 }
 
+func parseCIDRs(raw string) []*net.IPNet {
+	var out []*net.IPNet
+	for _, cidr := range splitCSV(raw) {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			log.Printf("⚠️ invalid CIDR %q ignored", cidr)
+			continue
+		}
+		out = append(out, network)
+	}
+	return out
+}
+
 func resolveRedisAddr() string {
 	if addr, _, ok := redisFromURL(os.Getenv("REDIS_URL")); ok {
 		return addr
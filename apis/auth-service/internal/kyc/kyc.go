@@ -0,0 +1,125 @@
+// Package kyc holds the identity-verification rules: request statuses, the
+// documents each verification level needs, and where uploaded files live.
+package kyc
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Request statuses. A request collects documents while AWAITING_DOCUMENTS,
+// waits in the review queue while PENDING, and goes back to the user when a
+// reviewer asks for MORE_INFO_REQUIRED.
+const (
+	StatusNone              = "NONE"
+	StatusAwaitingDocuments = "AWAITING_DOCUMENTS"
+	StatusPending           = "PENDING"
+	StatusMoreInfo          = "MORE_INFO_REQUIRED"
+	StatusApproved          = "APPROVED"
+	StatusRejected          = "REJECTED"
+)
+
+// Document kinds.
+const (
+	DocIDFront        = "id_front"
+	DocIDBack         = "id_back"
+	DocSelfie         = "selfie"
+	DocProofOfAddress = "proof_of_address"
+)
+
+// Verification levels. Payment limits are keyed by level.
+const (
+	LevelNone     = 0
+	LevelIdentity = 1 // government ID + selfie
+	LevelAddress  = 2 // identity + proof of address
+)
+
+var documentKinds = []string{DocIDFront, DocIDBack, DocSelfie, DocProofOfAddress}
+
+// levelDocuments lists what each level needs on top of the one below it.
+var levelDocuments = map[int][]string{
+	LevelIdentity: {DocIDFront, DocSelfie},
+	LevelAddress:  {DocProofOfAddress},
+}
+
+// allowedContentTypes are the sniffed types accepted for uploads.
+var allowedContentTypes = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"application/pdf": ".pdf",
+}
+
+// IsDocumentKind reports whether kind is a known document kind.
+func IsDocumentKind(kind string) bool {
+	for _, k := range documentKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// MaxLevel returns the highest level the uploaded document kinds support.
+func MaxLevel(kinds []string) int {
+	have := make(map[string]bool, len(kinds))
+	for _, k := range kinds {
+		have[k] = true
+	}
+	level := LevelNone
+	for next := LevelIdentity; next <= LevelAddress; next++ {
+		for _, required := range levelDocuments[next] {
+			if !have[required] {
+				return level
+			}
+		}
+		level = next
+	}
+	return level
+}
+
+// Missing lists the document kinds still needed to reach level.
+func Missing(kinds []string, level int) []string {
+	have := make(map[string]bool, len(kinds))
+	for _, k := range kinds {
+		have[k] = true
+	}
+	var missing []string
+	for l := LevelIdentity; l <= level; l++ {
+		for _, required := range levelDocuments[l] {
+			if !have[required] {
+				missing = append(missing, required)
+			}
+		}
+	}
+	return missing
+}
+
+// CanTransition reports whether a request may move from one status to
+// another.
+func CanTransition(from, to string) bool {
+	switch from {
+	case StatusAwaitingDocuments, StatusMoreInfo:
+		return to == StatusPending
+	case StatusPending:
+		return to == StatusApproved || to == StatusRejected || to == StatusMoreInfo
+	default:
+		return false
+	}
+}
+
+// IsOpen reports whether a request in status still accepts documents.
+func IsOpen(status string) bool {
+	return status == StatusAwaitingDocuments || status == StatusMoreInfo
+}
+
+// SniffContentType detects the type of an upload from its first bytes and
+// returns it with the file extension to store it under. ok is false for
+// anything but JPEG, PNG or PDF.
+func SniffContentType(head []byte) (contentType, ext string, ok bool) {
+	contentType = http.DetectContentType(head)
+	if i := strings.IndexByte(contentType, ';'); i >= 0 {
+		contentType = contentType[:i]
+	}
+	ext, ok = allowedContentTypes[contentType]
+	return contentType, ext, ok
+}
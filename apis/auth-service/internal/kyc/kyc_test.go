@@ -0,0 +1,92 @@
+package kyc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMaxLevelAndMissing(t *testing.T) {
+	if got := MaxLevel([]string{DocIDFront}); got != LevelNone {
+		t.Fatalf("ID without selfie: got level %d", got)
+	}
+	if got := MaxLevel([]string{DocSelfie, DocIDFront, DocIDBack}); got != LevelIdentity {
+		t.Fatalf("ID + selfie: got level %d", got)
+	}
+	if got := MaxLevel([]string{DocIDFront, DocSelfie, DocProofOfAddress}); got != LevelAddress {
+		t.Fatalf("full set: got level %d", got)
+	}
+	// Proof of address alone doesn't skip the identity level.
+	if got := MaxLevel([]string{DocProofOfAddress}); got != LevelNone {
+		t.Fatalf("address only: got level %d", got)
+	}
+	if got := Missing([]string{DocSelfie}, LevelAddress); !reflect.DeepEqual(got, []string{DocIDFront, DocProofOfAddress}) {
+		t.Fatalf("unexpected missing docs: %v", got)
+	}
+}
+
+func TestCanTransition(t *testing.T) {
+	for _, tc := range []struct {
+		from, to string
+		want     bool
+	}{
+		{StatusAwaitingDocuments, StatusPending, true},
+		{StatusAwaitingDocuments, StatusApproved, false},
+		{StatusPending, StatusApproved, true},
+		{StatusPending, StatusMoreInfo, true},
+		{StatusMoreInfo, StatusPending, true},
+		{StatusApproved, StatusRejected, false},
+		{StatusRejected, StatusPending, false},
+	} {
+		if got := CanTransition(tc.from, tc.to); got != tc.want {
+			t.Errorf("%s -> %s: got %v", tc.from, tc.to, got)
+		}
+	}
+}
+
+func TestSniffContentType(t *testing.T) {
+	if ct, ext, ok := SniffContentType([]byte("\x89PNG\r\n\x1a\n0000")); !ok || ct != "image/png" || ext != ".png" {
+		t.Fatalf("png: %q %q %v", ct, ext, ok)
+	}
+	if _, _, ok := SniffContentType([]byte("<html><script>")); ok {
+		t.Fatal("html must be refused")
+	}
+}
+
+func TestLocalStore(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewStore("local", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := store.Put(ctx, "u1/r1/doc.pdf", strings.NewReader("%PDF-1.4"))
+	if err != nil || n != 8 {
+		t.Fatalf("put: n=%d err=%v", n, err)
+	}
+	rc, err := store.Open(ctx, "u1/r1/doc.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(data) != "%PDF-1.4" {
+		t.Fatalf("unexpected content %q", data)
+	}
+	if err := store.Delete(ctx, "u1/r1/doc.pdf"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Open(ctx, "u1/r1/doc.pdf"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	for _, key := range []string{"../escape", "/etc/passwd", "", "u1/../../x"} {
+		if _, err := store.Put(ctx, key, strings.NewReader("x")); err == nil {
+			t.Errorf("key %q should be refused", key)
+		}
+	}
+	if _, err := NewStore("s3", t.TempDir()); err == nil {
+		t.Fatal("unknown backend should fail")
+	}
+}
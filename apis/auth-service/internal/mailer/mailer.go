@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"html"
 	"log"
 	"net/http"
 	"strings"
@@ -43,20 +44,85 @@ func (c *Client) SendPasswordReset(ctx context.Context, email, token string) err
 		return nil
 	}
 
-	payload := map[string]interface{}{
-		"from":    c.from,
-		"to":      email,
-		"subject": "Reset your Glory Grid password",
-		"html": fmt.Sprintf(
+	return c.send(ctx, email,
+		"Reset your Glory Grid password",
+		fmt.Sprintf(
 			`<p>We received a request to reset your Glory Grid password.</p>
 <p><a href="%s" style="display:inline-block;padding:10px 18px;background:#0ea5e9;color:#fff;border-radius:6px;text-decoration:none;">Reset Password</a></p>
 <p>This link expires in 30 minutes. If you did not request this, you can ignore this email.</p>`,
 			resetLink,
 		),
-		"text": fmt.Sprintf(
+		fmt.Sprintf(
 			"Use the link below to reset your Glory Grid password (expires in 30 minutes):\n%s\nIf you did not request this change you can ignore this email.",
 			resetLink,
 		),
+	)
+}
+
+// SendVerificationCode emails a one-time code proving the user owns email.
+func (c *Client) SendVerificationCode(ctx context.Context, email, code string, ttlMinutes int) error {
+	if email == "" || code == "" {
+		return fmt.Errorf("email and code required")
+	}
+	if c.apiKey == "" || c.from == "" {
+		log.Printf("⚠️  Email verification code for %s: %s", email, code)
+		return nil
+	}
+	return c.send(ctx, email,
+		"Your Glory Grid verification code",
+		fmt.Sprintf(
+			`<p>Use this code to link this email address to your Glory Grid account:</p>
+<p style="font-size:24px;letter-spacing:4px;"><strong>%s</strong></p>
+<p>The code expires in %d minutes. If you did not request this, you can ignore this email.</p>`,
+			code, ttlMinutes,
+		),
+		fmt.Sprintf(
+			"Your Glory Grid verification code is %s. It expires in %d minutes. If you did not request this you can ignore this email.",
+			code, ttlMinutes,
+		),
+	)
+}
+
+// kycSubjects maps a KYC status to the notification subject and lead line.
+var kycSubjects = map[string][2]string{
+	"APPROVED":           {"Your Glory Grid account is verified", "Your identity verification was approved. Your higher deposit and withdrawal limits are now active."},
+	"REJECTED":           {"We couldn't verify your Glory Grid account", "Your identity verification was not approved."},
+	"MORE_INFO_REQUIRED": {"We need more information to verify your account", "Our team needs a little more from you to finish verifying your identity."},
+}
+
+// SendKYCStatus tells the user their verification request changed status.
+// reason is the reviewer's note and may be empty.
+func (c *Client) SendKYCStatus(ctx context.Context, email, status, reason string) error {
+	if email == "" {
+		return fmt.Errorf("email required")
+	}
+	subject, ok := kycSubjects[status]
+	if !ok {
+		return fmt.Errorf("no KYC notification for status %q", status)
+	}
+	text := subject[1]
+	if reason != "" {
+		text += "\n\nReviewer note: " + reason
+	}
+	if c.apiKey == "" || c.from == "" {
+		log.Printf("⚠️  KYC %s notice for %s: %s", status, email, text)
+		return nil
+	}
+	htmlBody := "<p>" + html.EscapeString(subject[1]) + "</p>"
+	if reason != "" {
+		htmlBody += "<p><strong>Reviewer note:</strong> " + html.EscapeString(reason) + "</p>"
+	}
+	return c.send(ctx, email, subject[0], htmlBody, text)
+}
+
+// send posts one message to the Resend API.
+func (c *Client) send(ctx context.Context, to, subject, htmlBody, text string) error {
+	payload := map[string]interface{}{
+		"from":    c.from,
+		"to":      to,
+		"subject": subject,
+		"html":    htmlBody,
+		"text":    text,
 	}
 
 	body, err := json.Marshal(payload)
@@ -0,0 +1,214 @@
+// Package ratelimit throttles auth endpoints with Redis fixed-window counters
+// and locks out identities after repeated failed verifications.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Budget allows Limit hits per Window.
+type Budget struct {
+	Limit  int
+	Window time.Duration
+}
+
+func (b Budget) String() string {
+	return fmt.Sprintf("%d/%s", b.Limit, b.Window)
+}
+
+// ParseBudget reads "limit/window", e.g. "5/15m". It returns fallback when raw
+// is empty or malformed.
+func ParseBudget(raw string, fallback Budget) Budget {
+	limitRaw, windowRaw, ok := strings.Cut(strings.TrimSpace(raw), "/")
+	if !ok {
+		return fallback
+	}
+	var limit int
+	if _, err := fmt.Sscanf(strings.TrimSpace(limitRaw), "%d", &limit); err != nil || limit <= 0 {
+		return fallback
+	}
+	window, err := time.ParseDuration(strings.TrimSpace(windowRaw))
+	if err != nil || window <= 0 {
+		return fallback
+	}
+	return Budget{Limit: limit, Window: window}
+}
+
+// Lockout configures exponential lockout: after Threshold failures the
+// identity is locked for Base, doubling with every further failure up to Max.
+// Failures are forgotten after Memory without a new one.
+type Lockout struct {
+	Threshold int
+	Base      time.Duration
+	Max       time.Duration
+	Memory    time.Duration
+}
+
+// Duration returns the lock length after failures consecutive misses.
+func (l Lockout) Duration(failures int) time.Duration {
+	if l.Threshold <= 0 || failures < l.Threshold {
+		return 0
+	}
+	d := float64(l.Base) * math.Pow(2, float64(failures-l.Threshold))
+	if l.Max > 0 && d > float64(l.Max) {
+		return l.Max
+	}
+	if d > math.MaxInt64 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(d)
+}
+
+// Decision is the outcome of a budget check.
+type Decision struct {
+	Allowed    bool
+	Count      int64
+	Remaining  int64
+	RetryAfter time.Duration
+}
+
+// State describes one identity for the admin endpoint.
+type State struct {
+	Scope       string           `json:"scope"`
+	Key         string           `json:"key"`
+	Counters    map[string]int64 `json:"counters"`
+	Failures    int64            `json:"failures"`
+	LockedFor   float64          `json:"lockedForSeconds"`
+	LockedUntil *time.Time       `json:"lockedUntil,omitempty"`
+}
+
+type Limiter struct {
+	rdb     *redis.Client
+	prefix  string
+	lockout Lockout
+}
+
+func New(rdb *redis.Client, prefix string, lockout Lockout) *Limiter {
+	if prefix == "" {
+		prefix = "ratelimit"
+	}
+	return &Limiter{rdb: rdb, prefix: prefix, lockout: lockout}
+}
+
+// hitScript increments a window counter, starting its expiry on first hit.
+var hitScript = redis.NewScript(`
+local n = redis.call('INCR', KEYS[1])
+if n == 1 then
+  redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return {n, redis.call('PTTL', KEYS[1])}
+`)
+
+// Allow counts a hit against budget name for key (an IP, phone or email).
+func (l *Limiter) Allow(ctx context.Context, name, key string, budget Budget) (Decision, error) {
+	if budget.Limit <= 0 || key == "" {
+		return Decision{Allowed: true}, nil
+	}
+	res, err := hitScript.Run(ctx, l.rdb, []string{l.counterKey(name, key)}, budget.Window.Milliseconds()).Int64Slice()
+	if err != nil {
+		return Decision{Allowed: true}, fmt.Errorf("rate limit %s: %w", name, err)
+	}
+	count, ttl := res[0], res[1]
+	d := Decision{Count: count, Remaining: int64(budget.Limit) - count}
+	if d.Remaining < 0 {
+		d.Remaining = 0
+	}
+	if count <= int64(budget.Limit) {
+		d.Allowed = true
+		return d, nil
+	}
+	d.RetryAfter = time.Duration(ttl) * time.Millisecond
+	return d, nil
+}
+
+// Locked reports how long key stays locked in scope (0 when not locked).
+func (l *Limiter) Locked(ctx context.Context, scope, key string) (time.Duration, error) {
+	ttl, err := l.rdb.PTTL(ctx, l.lockKey(scope, key)).Result()
+	if err != nil {
+		return 0, err
+	}
+	if ttl < 0 {
+		return 0, nil
+	}
+	return ttl, nil
+}
+
+// RecordFailure counts a failed verification and applies the lockout once
+// the threshold is reached. It returns the new lock length (0 if none).
+func (l *Limiter) RecordFailure(ctx context.Context, scope, key string) (time.Duration, error) {
+	failKey := l.failKey(scope, key)
+	pipe := l.rdb.TxPipeline()
+	incr := pipe.Incr(ctx, failKey)
+	pipe.Expire(ctx, failKey, l.lockout.Memory)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	lock := l.lockout.Duration(int(incr.Val()))
+	if lock <= 0 {
+		return 0, nil
+	}
+	if err := l.rdb.Set(ctx, l.lockKey(scope, key), incr.Val(), lock).Err(); err != nil {
+		return 0, err
+	}
+	return lock, nil
+}
+
+// Reset clears failures and any lock for key, e.g. after a successful login.
+func (l *Limiter) Reset(ctx context.Context, scope, key string) error {
+	return l.rdb.Del(ctx, l.failKey(scope, key), l.lockKey(scope, key)).Err()
+}
+
+// Inspect returns the current counters, failures and lock for key across the
+// given budget names.
+func (l *Limiter) Inspect(ctx context.Context, scope, key string, names []string) (*State, error) {
+	state := &State{Scope: scope, Key: key, Counters: make(map[string]int64)}
+	for _, name := range names {
+		n, err := l.rdb.Get(ctx, l.counterKey(name, key)).Int64()
+		if err != nil && err != redis.Nil {
+			return nil, err
+		}
+		state.Counters[name] = n
+	}
+	failures, err := l.rdb.Get(ctx, l.failKey(scope, key)).Int64()
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+	state.Failures = failures
+	lock, err := l.Locked(ctx, scope, key)
+	if err != nil {
+		return nil, err
+	}
+	if lock > 0 {
+		until := time.Now().Add(lock).UTC()
+		state.LockedFor = lock.Seconds()
+		state.LockedUntil = &until
+	}
+	return state, nil
+}
+
+// Clear removes counters, failures and lock for key.
+func (l *Limiter) Clear(ctx context.Context, scope, key string, names []string) error {
+	keys := []string{l.failKey(scope, key), l.lockKey(scope, key)}
+	for _, name := range names {
+		keys = append(keys, l.counterKey(name, key))
+	}
+	return l.rdb.Del(ctx, keys...).Err()
+}
+
+func (l *Limiter) counterKey(name, key string) string {
+	return l.prefix + ":hits:" + name + ":" + key
+}
+
+func (l *Limiter) failKey(scope, key string) string {
+	return l.prefix + ":fail:" + scope + ":" + key
+}
+
+func (l *Limiter) lockKey(scope, key string) string {
+	return l.prefix + ":lock:" + scope + ":" + key
+}
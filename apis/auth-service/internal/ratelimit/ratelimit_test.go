@@ -0,0 +1,43 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseBudget(t *testing.T) {
+	fallback := Budget{Limit: 1, Window: time.Minute}
+	cases := map[string]Budget{
+		"5/15m":  {Limit: 5, Window: 15 * time.Minute},
+		" 3/1h ": {Limit: 3, Window: time.Hour},
+		"":       fallback,
+		"5":      fallback,
+		"0/1m":   fallback,
+		"x/1m":   fallback,
+		"5/soon": fallback,
+	}
+	for raw, want := range cases {
+		if got := ParseBudget(raw, fallback); got != want {
+			t.Errorf("ParseBudget(%q) = %v, want %v", raw, got, want)
+		}
+	}
+}
+
+func TestLockoutDurationDoublesUpToMax(t *testing.T) {
+	l := Lockout{Threshold: 3, Base: time.Minute, Max: 10 * time.Minute}
+	want := map[int]time.Duration{
+		1:  0,
+		2:  0,
+		3:  time.Minute,
+		4:  2 * time.Minute,
+		5:  4 * time.Minute,
+		6:  8 * time.Minute,
+		7:  10 * time.Minute,
+		50: 10 * time.Minute,
+	}
+	for failures, d := range want {
+		if got := l.Duration(failures); got != d {
+			t.Errorf("Duration(%d) = %s, want %s", failures, got, d)
+		}
+	}
+}
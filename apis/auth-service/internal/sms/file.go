@@ -0,0 +1,56 @@
+package sms
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// File appends each message as a JSON line to path, or logs it when path is
+// empty. Messages count as delivered immediately.
+type File struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFile(path string) *File {
+	return &File{path: path}
+}
+
+func (f *File) Name() string {
+	if f.path == "" {
+		return "console"
+	}
+	return "file"
+}
+
+func (f *File) Send(ctx context.Context, msg Message) (*Result, error) {
+	if f.path == "" {
+		log.Printf("📱 SMS to %s: %s", msg.To, msg.Body)
+		return &Result{Provider: f.Name(), MessageID: msg.Reference, Status: StatusDelivered}, nil
+	}
+
+	line, err := json.Marshal(map[string]interface{}{
+		"to":        msg.To,
+		"body":      msg.Body,
+		"reference": msg.Reference,
+		"sentAt":    time.Now().UTC(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return nil, err
+	}
+	return &Result{Provider: f.Name(), MessageID: msg.Reference, Status: StatusDelivered}, nil
+}
@@ -0,0 +1,84 @@
+package sms
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const defaultHubtelBaseURL = "https://smsc.hubtel.com"
+
+// Hubtel sends through the Hubtel SMS API using basic auth.
+type Hubtel struct {
+	baseURL      string
+	clientID     string
+	clientSecret string
+	from         string
+	httpClient   *http.Client
+}
+
+func NewHubtel(baseURL, clientID, clientSecret, from string) *Hubtel {
+	if strings.TrimSpace(baseURL) == "" {
+		baseURL = defaultHubtelBaseURL
+	}
+	return &Hubtel{
+		baseURL:      strings.TrimRight(baseURL, "/"),
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		from:         from,
+		httpClient:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (h *Hubtel) Name() string {
+	return "hubtel"
+}
+
+func (h *Hubtel) Send(ctx context.Context, msg Message) (*Result, error) {
+	payload := map[string]interface{}{
+		"from":    h.from,
+		"to":      msg.To,
+		"content": msg.Body,
+	}
+	if msg.Reference != "" {
+		payload["clientReference"] = msg.Reference
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.baseURL+"/v1/messages/send", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth(h.clientID, h.clientSecret)
+
+	resp, err := h.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	raw, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("hubtel returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(raw)))
+	}
+	var out struct {
+		MessageID string `json:"messageId"`
+		Status    int    `json:"status"`
+	}
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return nil, fmt.Errorf("hubtel response: %w", err)
+	}
+	// Hubtel reports 0 for messages accepted for delivery.
+	if out.Status != 0 {
+		return nil, fmt.Errorf("hubtel rejected message (status %d)", out.Status)
+	}
+	return &Result{Provider: h.Name(), MessageID: out.MessageID, Status: StatusSent}, nil
+}
@@ -0,0 +1,80 @@
+// Package sms delivers text messages (phone OTPs) through a pluggable
+// provider. Hubtel is used in production; the webhook sender forwards to any
+// HTTP gateway, and the file/console sender keeps local development offline.
+package sms
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"gamehub/auth-service/internal/config"
+)
+
+// Delivery statuses recorded on otps documents.
+const (
+	StatusPending     = "PENDING"
+	StatusSent        = "SENT"
+	StatusDelivered   = "DELIVERED"
+	StatusUndelivered = "UNDELIVERED"
+	StatusFailed      = "FAILED"
+)
+
+// Message is a single outbound SMS. Reference is echoed back by providers
+// that support it so delivery reports can be matched to the OTP.
+type Message struct {
+	To        string
+	Body      string
+	Reference string
+}
+
+// Result identifies an accepted message at the provider.
+type Result struct {
+	Provider  string
+	MessageID string
+	Status    string
+}
+
+// Sender delivers a Message.
+type Sender interface {
+	Name() string
+	Send(ctx context.Context, msg Message) (*Result, error)
+}
+
+// New picks the sender named by SMS_PROVIDER. When it is unset, Hubtel is
+// used if its credentials are configured. Otherwise messages go to the
+// console in development and New fails elsewhere: the console sender only
+// logs OTPs, so it must be asked for explicitly outside development.
+func New(cfg *config.Config) (Sender, error) {
+	provider := strings.ToLower(strings.TrimSpace(cfg.SMSProvider))
+	if provider == "" {
+		switch {
+		case cfg.HubtelSMSClientID != "" && cfg.HubtelSMSClientSecret != "":
+			provider = "hubtel"
+		case cfg.AppEnv == "development":
+			provider = "console"
+		default:
+			return nil, fmt.Errorf("sms: no provider configured for %s; set SMS_PROVIDER (console only logs messages)", cfg.AppEnv)
+		}
+	}
+	switch provider {
+	case "hubtel":
+		if cfg.HubtelSMSClientID == "" || cfg.HubtelSMSClientSecret == "" {
+			return nil, fmt.Errorf("sms: hubtel requires HUBTEL_SMS_CLIENT_ID and HUBTEL_SMS_CLIENT_SECRET")
+		}
+		return NewHubtel(cfg.HubtelSMSBaseURL, cfg.HubtelSMSClientID, cfg.HubtelSMSClientSecret, cfg.HubtelSMSFrom), nil
+	case "webhook":
+		if cfg.SMSWebhookURL == "" {
+			return nil, fmt.Errorf("sms: webhook requires SMS_WEBHOOK_URL")
+		}
+		return NewWebhook(cfg.SMSWebhookURL, cfg.SMSWebhookToken), nil
+	case "file":
+		return NewFile(cfg.SMSFilePath), nil
+	case "console":
+		log.Println("⚠️  SMS provider is console — OTP messages are only logged")
+		return NewFile(""), nil
+	default:
+		return nil, fmt.Errorf("sms: unknown provider %q", provider)
+	}
+}
@@ -0,0 +1,128 @@
+package sms
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gamehub/auth-service/internal/config"
+)
+
+func TestRenderFallsBackToDefaultLocale(t *testing.T) {
+	body, locale, err := Render(TemplateOTP, "fr-CA,fr;q=0.9", OTPData{Code: "123456", TTLMinutes: 5})
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if locale != "fr" || !strings.Contains(body, "123456") || !strings.Contains(body, "expire dans 5 minutes") {
+		t.Fatalf("unexpected fr render %q (%s)", body, locale)
+	}
+
+	body, locale, err = Render(TemplateOTP, "de", OTPData{Code: "654321", TTLMinutes: 10})
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if locale != DefaultLocale || !strings.Contains(body, "654321") {
+		t.Fatalf("expected english fallback, got %q (%s)", body, locale)
+	}
+}
+
+func TestRenderKYCStatus(t *testing.T) {
+	body, _, err := Render(TemplateKYCStatus, "en", KYCStatusData{Status: "APPROVED"})
+	if err != nil || !strings.Contains(body, "now verified") {
+		t.Fatalf("approved: %q %v", body, err)
+	}
+	body, _, err = Render(TemplateKYCStatus, "fr", KYCStatusData{Status: "MORE_INFO_REQUIRED"})
+	if err != nil || !strings.Contains(body, "plus d'informations") {
+		t.Fatalf("more info: %q %v", body, err)
+	}
+}
+
+func TestHubtelSend(t *testing.T) {
+	var got map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "id" || pass != "secret" || r.URL.Path != "/v1/messages/send" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		_, _ = w.Write([]byte(`{"messageId":"msg-1","status":0}`))
+	}))
+	defer srv.Close()
+
+	sender := NewHubtel(srv.URL, "id", "secret", "Glory Grid")
+	res, err := sender.Send(context.Background(), Message{To: "+233200000000", Body: "hi", Reference: "ref-1"})
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if res.MessageID != "msg-1" || res.Status != StatusSent {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	if got["to"] != "+233200000000" || got["from"] != "Glory Grid" || got["clientReference"] != "ref-1" {
+		t.Fatalf("unexpected payload %v", got)
+	}
+
+	if _, err := NewHubtel(srv.URL, "id", "wrong", "x").Send(context.Background(), Message{To: "1", Body: "x"}); err == nil {
+		t.Fatal("expected error on 401")
+	}
+}
+
+func TestFileSenderAppendsLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.jsonl")
+	sender := NewFile(path)
+	for _, to := range []string{"+1", "+2"} {
+		if _, err := sender.Send(context.Background(), Message{To: to, Body: "code"}); err != nil {
+			t.Fatalf("send: %v", err)
+		}
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read outbox: %v", err)
+	}
+	if lines := strings.Count(string(raw), "\n"); lines != 2 {
+		t.Fatalf("expected 2 lines, got %d", lines)
+	}
+}
+
+func TestNewSelectsProvider(t *testing.T) {
+	cases := []struct {
+		cfg  config.Config
+		want string
+	}{
+		{config.Config{AppEnv: "development"}, "console"},
+		{config.Config{AppEnv: "production", SMSProvider: "console"}, "console"},
+		{config.Config{HubtelSMSClientID: "id", HubtelSMSClientSecret: "s"}, "hubtel"},
+		{config.Config{SMSProvider: "webhook", SMSWebhookURL: "http://sms.local"}, "webhook"},
+		{config.Config{SMSProvider: "file", SMSFilePath: "out.jsonl"}, "file"},
+	}
+	for _, tc := range cases {
+		sender, err := New(&tc.cfg)
+		if err != nil {
+			t.Fatalf("new(%s): %v", tc.want, err)
+		}
+		if sender.Name() != tc.want {
+			t.Fatalf("provider = %s, want %s", sender.Name(), tc.want)
+		}
+	}
+	if _, err := New(&config.Config{SMSProvider: "webhook"}); err == nil {
+		t.Fatal("expected error for webhook without url")
+	}
+	if _, err := New(&config.Config{AppEnv: "production"}); err == nil {
+		t.Fatal("expected error instead of a console fallback in production")
+	}
+}
+
+func TestParseDeliveryReport(t *testing.T) {
+	report, ok := ParseDeliveryReport([]byte(`{"MessageId":"msg-1","Status":"Delivered"}`))
+	if !ok || report.MessageID != "msg-1" || report.Status != StatusDelivered {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if _, ok := ParseDeliveryReport([]byte(`{"status":"delivered"}`)); ok {
+		t.Fatal("expected report without id to be rejected")
+	}
+}
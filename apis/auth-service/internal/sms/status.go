@@ -0,0 +1,50 @@
+package sms
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// DeliveryReport is a provider callback normalised to our statuses.
+type DeliveryReport struct {
+	MessageID string
+	Status    string
+	Detail    string
+}
+
+// ParseDeliveryReport accepts Hubtel callbacks ({"MessageId","Status"}) and
+// the webhook format ({"messageId","status"}).
+func ParseDeliveryReport(body []byte) (*DeliveryReport, bool) {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, false
+	}
+	lookup := func(keys ...string) string {
+		for _, key := range keys {
+			if v, ok := raw[key].(string); ok && v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+	report := &DeliveryReport{
+		MessageID: lookup("messageId", "MessageId", "clientReference", "ClientReference"),
+		Detail:    lookup("status", "Status"),
+	}
+	if report.MessageID == "" || report.Detail == "" {
+		return nil, false
+	}
+	report.Status = normalizeStatus(report.Detail)
+	return report, true
+}
+
+func normalizeStatus(raw string) string {
+	switch strings.ToLower(strings.TrimSpace(raw)) {
+	case "delivered", "delivrd", "success":
+		return StatusDelivered
+	case "undelivered", "undeliv", "rejected", "expired", "failed":
+		return StatusUndelivered
+	default:
+		return StatusSent
+	}
+}
@@ -0,0 +1,78 @@
+package sms
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// Template names.
+const (
+	TemplateOTP       = "otp"
+	TemplateKYCStatus = "kyc_status"
+)
+
+// DefaultLocale is used when a requested locale has no translation.
+const DefaultLocale = "en"
+
+var templates = map[string]map[string]*template.Template{
+	"en": {
+		TemplateOTP: template.Must(template.New("otp.en").Parse(
+			"Your Glory Grid code is {{.Code}}. It expires in {{.TTLMinutes}} minutes. Never share this code.")),
+		TemplateKYCStatus: template.Must(template.New("kyc_status.en").Parse(
+			`Glory Grid: {{if eq .Status "APPROVED"}}your account is now verified.{{else if eq .Status "REJECTED"}}we could not verify your identity.{{else}}we need more information to verify your identity.{{end}} Open the app for details.`)),
+	},
+	"fr": {
+		TemplateOTP: template.Must(template.New("otp.fr").Parse(
+			"Votre code Glory Grid est {{.Code}}. Il expire dans {{.TTLMinutes}} minutes. Ne le partagez jamais.")),
+		TemplateKYCStatus: template.Must(template.New("kyc_status.fr").Parse(
+			`Glory Grid : {{if eq .Status "APPROVED"}}votre compte est maintenant vérifié.{{else if eq .Status "REJECTED"}}nous n'avons pas pu vérifier votre identité.{{else}}nous avons besoin de plus d'informations pour vérifier votre identité.{{end}} Ouvrez l'application pour plus de détails.`)),
+	},
+}
+
+// OTPData fills TemplateOTP.
+type OTPData struct {
+	Code       string
+	TTLMinutes int
+}
+
+// KYCStatusData fills TemplateKYCStatus.
+type KYCStatusData struct {
+	Status string
+}
+
+// Render executes the named template in locale, falling back to
+// DefaultLocale. It returns the locale actually used.
+func Render(name, locale string, data interface{}) (string, string, error) {
+	locale = NormalizeLocale(locale)
+	tmpl, ok := templates[locale][name]
+	if !ok {
+		locale = DefaultLocale
+		tmpl, ok = templates[locale][name]
+	}
+	if !ok {
+		return "", "", fmt.Errorf("sms: unknown template %q", name)
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", "", err
+	}
+	return sb.String(), locale, nil
+}
+
+// NormalizeLocale reduces "fr-FR" or an Accept-Language value such as
+// "fr-CA,fr;q=0.9" to its primary language tag.
+func NormalizeLocale(raw string) string {
+	raw = strings.TrimSpace(raw)
+	if i := strings.IndexAny(raw, ",;"); i >= 0 {
+		raw = raw[:i]
+	}
+	if i := strings.IndexAny(raw, "-_"); i >= 0 {
+		raw = raw[:i]
+	}
+	raw = strings.ToLower(strings.TrimSpace(raw))
+	if raw == "" {
+		return DefaultLocale
+	}
+	return raw
+}
@@ -0,0 +1,70 @@
+package sms
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Webhook POSTs {to, body, reference} as JSON to a generic SMS gateway. The
+// gateway may answer with {"messageId": "..."} to enable delivery reports.
+type Webhook struct {
+	url        string
+	token      string
+	httpClient *http.Client
+}
+
+func NewWebhook(url, token string) *Webhook {
+	return &Webhook{
+		url:        strings.TrimSpace(url),
+		token:      strings.TrimSpace(token),
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (w *Webhook) Name() string {
+	return "webhook"
+}
+
+func (w *Webhook) Send(ctx context.Context, msg Message) (*Result, error) {
+	body, err := json.Marshal(map[string]string{
+		"to":        msg.To,
+		"body":      msg.Body,
+		"reference": msg.Reference,
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.token != "" {
+		req.Header.Set("Authorization", "Bearer "+w.token)
+	}
+
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	raw, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("sms webhook returned status %d", resp.StatusCode)
+	}
+	var out struct {
+		MessageID string `json:"messageId"`
+	}
+	_ = json.Unmarshal(raw, &out)
+	if out.MessageID == "" {
+		out.MessageID = msg.Reference
+	}
+	return &Result{Provider: w.Name(), MessageID: out.MessageID, Status: StatusSent}, nil
+}
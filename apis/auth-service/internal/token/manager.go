@@ -2,32 +2,72 @@ package token
 
 import (
 	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
 	"fmt"
+	"math/big"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+
+	"gamehub/pkg/authn/rbac"
 )
 
 // Claims represents the JWT payload issued by the auth service.
 type Claims struct {
-	UserID string `json:"uid"`
-	Role   string `json:"role"`
+	UserID string   `json:"uid"`
+	Role   string   `json:"role"`
+	Roles  []string `json:"roles,omitempty"`
+	Scopes []string `json:"scopes,omitempty"`
+	// Elevated marks a short-lived token minted after a second-factor
+	// step-up; sensitive actions such as withdrawals require it.
+	Elevated bool `json:"elevated,omitempty"`
 	jwt.RegisteredClaims
 }
 
-// Manager is responsible for issuing and validating JWT access tokens.
+// HasScope reports whether the token grants scope.
+func (c *Claims) HasScope(scope string) bool {
+	for _, granted := range c.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Manager is responsible for issuing and validating JWT access tokens. It
+// signs with the active key and accepts every key in its ring, so retiring
+// keys keep verifying until the tokens they signed have expired.
 type Manager struct {
-	privateKey *rsa.PrivateKey
-	publicKey  *rsa.PublicKey
+	keys       map[string]*signingKey
+	activeKID  string
+	primaryKID string
 	issuer     string
 	accessTTL  time.Duration
 }
 
+type signingKey struct {
+	kid        string
+	privateKey *rsa.PrivateKey // nil for verification-only keys
+	publicKey  *rsa.PublicKey
+}
+
+// KeyOptions controls key IDs and rotation.
+type KeyOptions struct {
+	// KeyID names the primary key; defaults to its RFC 7638 thumbprint.
+	KeyID string
+	// Dir holds extra keys as <kid>.pem, either private or public.
+	Dir string
+	// ActiveKID selects the signing key; defaults to the primary key.
+	ActiveKID string
+}
+
 // NewManager loads RSA keys from disk and returns a configured Manager.
-func NewManager(privateKeyPath, publicKeyPath, issuer string, accessTTL time.Duration) (*Manager, error) {
+func NewManager(privateKeyPath, publicKeyPath, issuer string, accessTTL time.Duration, opts KeyOptions) (*Manager, error) {
 	priv, err := loadPrivateKey(privateKeyPath)
 	if err != nil {
 		return nil, fmt.Errorf("load private key: %w", err)
@@ -42,35 +82,131 @@ func NewManager(privateKeyPath, publicKeyPath, issuer string, accessTTL time.Dur
 	if accessTTL <= 0 {
 		accessTTL = 15 * time.Minute
 	}
-	return &Manager{
-		privateKey: priv,
-		publicKey:  pub,
+
+	primaryKID := strings.TrimSpace(opts.KeyID)
+	if primaryKID == "" {
+		primaryKID = Thumbprint(pub)
+	}
+	m := &Manager{
+		keys:       map[string]*signingKey{primaryKID: {kid: primaryKID, privateKey: priv, publicKey: pub}},
+		activeKID:  primaryKID,
+		primaryKID: primaryKID,
 		issuer:     issuer,
 		accessTTL:  accessTTL,
-	}, nil
+	}
+	if err := m.loadKeyDir(opts.Dir); err != nil {
+		return nil, fmt.Errorf("load key dir: %w", err)
+	}
+	if active := strings.TrimSpace(opts.ActiveKID); active != "" {
+		key, ok := m.keys[active]
+		if !ok {
+			return nil, fmt.Errorf("active key %q not found", active)
+		}
+		if key.privateKey == nil {
+			return nil, fmt.Errorf("active key %q has no private key", active)
+		}
+		m.activeKID = active
+	}
+	return m, nil
 }
 
-// IssueAccessToken signs and returns a JWT for the given user and role.
-func (m *Manager) IssueAccessToken(userID, role string) (string, error) {
+// loadKeyDir adds every <kid>.pem in dir to the ring.
+func (m *Manager) loadKeyDir(dir string) error {
+	dir = strings.TrimSpace(dir)
+	if dir == "" {
+		return nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(strings.ToLower(name), ".pem") {
+			continue
+		}
+		kid := strings.TrimSuffix(name, filepath.Ext(name))
+		if _, exists := m.keys[kid]; exists {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return err
+		}
+		key, err := parseKeyPEM(kid, data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		m.keys[kid] = key
+	}
+	return nil
+}
+
+func parseKeyPEM(kid string, data []byte) (*signingKey, error) {
+	if priv, err := jwt.ParseRSAPrivateKeyFromPEM(data); err == nil {
+		return &signingKey{kid: kid, privateKey: priv, publicKey: &priv.PublicKey}, nil
+	}
+	pub, err := jwt.ParseRSAPublicKeyFromPEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("not an RSA private or public key")
+	}
+	return &signingKey{kid: kid, publicKey: pub}, nil
+}
+
+// KeyID returns the kid of the key currently signing tokens.
+func (m *Manager) KeyID() string {
+	return m.activeKID
+}
+
+// IssueAccessToken signs and returns a JWT for the given user and role. Staff
+// roles and the scopes they grant are embedded alongside the base role.
+func (m *Manager) IssueAccessToken(userID, role string, roles []string) (string, error) {
+	return m.issue(userID, role, roles, m.accessTTL, false)
+}
+
+// IssueElevatedToken signs an access token carrying the elevated claim. Its
+// lifetime is ttl, capped at the normal access-token TTL.
+func (m *Manager) IssueElevatedToken(userID, role string, roles []string, ttl time.Duration) (string, error) {
+	if ttl <= 0 || ttl > m.accessTTL {
+		ttl = m.accessTTL
+	}
+	return m.issue(userID, role, roles, ttl, true)
+}
+
+func (m *Manager) issue(userID, role string, roles []string, ttl time.Duration, elevated bool) (string, error) {
 	now := time.Now()
 	claims := Claims{
-		UserID: userID,
-		Role:   role,
+		UserID:   userID,
+		Role:     role,
+		Roles:    roles,
+		Scopes:   rbac.ScopesFor(append([]string{role}, roles...)...),
+		Elevated: elevated,
 		RegisteredClaims: jwt.RegisteredClaims{
 			Subject:   userID,
 			Issuer:    m.issuer,
 			IssuedAt:  jwt.NewNumericDate(now),
-			ExpiresAt: jwt.NewNumericDate(now.Add(m.accessTTL)),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
 		},
 	}
+	active := m.keys[m.activeKID]
 	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
-	return token.SignedString(m.privateKey)
+	token.Header["kid"] = active.kid
+	return token.SignedString(active.privateKey)
 }
 
 // Parse validates an incoming JWT string and returns the embedded claims.
+// Tokens without a kid predate rotation and are checked against the primary key.
 func (m *Manager) Parse(tokenString string) (*Claims, error) {
 	parsed, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(t *jwt.Token) (interface{}, error) {
-		return m.publicKey, nil
+		kid, _ := t.Header["kid"].(string)
+		if kid == "" {
+			kid = m.primaryKID
+		}
+		key, ok := m.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		return key.publicKey, nil
 	}, jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}))
 	if err != nil {
 		return nil, err
@@ -82,6 +218,61 @@ func (m *Manager) Parse(tokenString string) (*Claims, error) {
 	return claims, nil
 }
 
+// JWK is the public half of an RSA signing key (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// JWKS is the document served at /.well-known/jwks.json.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS publishes every key in the ring, the active key first.
+func (m *Manager) JWKS() JWKS {
+	kids := make([]string, 0, len(m.keys))
+	for kid := range m.keys {
+		if kid != m.activeKID {
+			kids = append(kids, kid)
+		}
+	}
+	sort.Strings(kids)
+	kids = append([]string{m.activeKID}, kids...)
+
+	set := JWKS{Keys: make([]JWK, 0, len(kids))}
+	for _, kid := range kids {
+		n, e := encodePublicKey(m.keys[kid].publicKey)
+		set.Keys = append(set.Keys, JWK{
+			Kty: "RSA",
+			Use: "sig",
+			Alg: jwt.SigningMethodRS256.Alg(),
+			Kid: kid,
+			N:   n,
+			E:   e,
+		})
+	}
+	return set
+}
+
+// Thumbprint returns the RFC 7638 SHA-256 thumbprint of pub, used as the
+// default kid so every instance derives the same id from the same key.
+func Thumbprint(pub *rsa.PublicKey) string {
+	n, e := encodePublicKey(pub)
+	sum := sha256.Sum256([]byte(`{"e":"` + e + `","kty":"RSA","n":"` + n + `"}`))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func encodePublicKey(pub *rsa.PublicKey) (string, string) {
+	n := base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+	e := base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	return n, e
+}
+
 func loadPrivateKey(path string) (*rsa.PrivateKey, error) {
 	if pem := pemFromEnv("JWT_PRIVATE_KEY_PEM"); pem != "" {
 		return jwt.ParseRSAPrivateKeyFromPEM([]byte(pem))
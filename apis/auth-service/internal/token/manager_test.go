@@ -1,9 +1,16 @@
 package token
 
 import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
 )
 
 func TestReadPEMFileFromRailwayStyleDirectoryMount(t *testing.T) {
@@ -27,3 +34,108 @@ func TestReadPEMFileFromRailwayStyleDirectoryMount(t *testing.T) {
 		t.Fatalf("unexpected content: got %q want %q", got, want)
 	}
 }
+
+func writeKeyPair(t *testing.T, dir, name string) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	privPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	pubDER, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("marshal public key: %v", err)
+	}
+	pubPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER})
+	if err := os.WriteFile(filepath.Join(dir, name+"_private.pem"), privPEM, 0o600); err != nil {
+		t.Fatalf("write private key: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name+"_public.pem"), pubPEM, 0o644); err != nil {
+		t.Fatalf("write public key: %v", err)
+	}
+	return key
+}
+
+func TestManagerRotatesSigningKeys(t *testing.T) {
+	root := t.TempDir()
+	primary := writeKeyPair(t, root, "primary")
+
+	old, err := NewManager(filepath.Join(root, "primary_private.pem"), filepath.Join(root, "primary_public.pem"), "gamehub-auth", time.Minute, KeyOptions{})
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	if old.KeyID() != Thumbprint(&primary.PublicKey) {
+		t.Fatalf("expected thumbprint kid, got %q", old.KeyID())
+	}
+	oldToken, err := old.IssueAccessToken("user-1", "user", nil)
+	if err != nil {
+		t.Fatalf("issue: %v", err)
+	}
+
+	keysDir := filepath.Join(root, "keys")
+	if err := os.Mkdir(keysDir, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	writeKeyPair(t, keysDir, "2026-10")
+	if err := os.Rename(filepath.Join(keysDir, "2026-10_private.pem"), filepath.Join(keysDir, "2026-10.pem")); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+	_ = os.Remove(filepath.Join(keysDir, "2026-10_public.pem"))
+
+	rotated, err := NewManager(filepath.Join(root, "primary_private.pem"), filepath.Join(root, "primary_public.pem"), "gamehub-auth", time.Minute, KeyOptions{
+		Dir:       keysDir,
+		ActiveKID: "2026-10",
+	})
+	if err != nil {
+		t.Fatalf("NewManager rotated: %v", err)
+	}
+	newToken, err := rotated.IssueAccessToken("user-2", "user", []string{"finance"})
+	if err != nil {
+		t.Fatalf("issue rotated: %v", err)
+	}
+	parsed, _, err := jwt.NewParser().ParseUnverified(newToken, &Claims{})
+	if err != nil {
+		t.Fatalf("parse header: %v", err)
+	}
+	if parsed.Header["kid"] != "2026-10" {
+		t.Fatalf("expected kid 2026-10, got %v", parsed.Header["kid"])
+	}
+
+	// Tokens from the retiring key still verify after rotation.
+	for _, tok := range []string{oldToken, newToken} {
+		if _, err := rotated.Parse(tok); err != nil {
+			t.Fatalf("Parse after rotation: %v", err)
+		}
+	}
+	claims, _ := rotated.Parse(newToken)
+	if !claims.HasScope("withdrawals:review") || claims.HasScope("roles:manage") {
+		t.Fatalf("unexpected finance scopes: %v", claims.Scopes)
+	}
+
+	set := rotated.JWKS()
+	if len(set.Keys) != 2 || set.Keys[0].Kid != "2026-10" || set.Keys[1].Kid != old.KeyID() {
+		t.Fatalf("unexpected JWKS: %+v", set.Keys)
+	}
+}
+
+func TestManagerRejectsVerificationOnlyActiveKey(t *testing.T) {
+	root := t.TempDir()
+	writeKeyPair(t, root, "primary")
+	keysDir := filepath.Join(root, "keys")
+	if err := os.Mkdir(keysDir, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	writeKeyPair(t, keysDir, "old")
+	_ = os.Remove(filepath.Join(keysDir, "old_private.pem"))
+	if err := os.Rename(filepath.Join(keysDir, "old_public.pem"), filepath.Join(keysDir, "old.pem")); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+
+	_, err := NewManager(filepath.Join(root, "primary_private.pem"), filepath.Join(root, "primary_public.pem"), "", 0, KeyOptions{
+		Dir:       keysDir,
+		ActiveKID: "old",
+	})
+	if err == nil {
+		t.Fatal("expected error when the active key has no private half")
+	}
+}
@@ -0,0 +1,70 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// RFC 6238 appendix B, SHA-1 seed, truncated to 6 digits.
+func TestCodeMatchesRFC6238Vectors(t *testing.T) {
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+	cases := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1111111111: "050471",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+	for unix, want := range cases {
+		got, err := Code(secret, Step(time.Unix(unix, 0)))
+		if err != nil {
+			t.Fatalf("Code: %v", err)
+		}
+		if got != want {
+			t.Errorf("t=%d: got %s want %s", unix, got, want)
+		}
+	}
+}
+
+func TestVerifyAcceptsSkewAndReportsStep(t *testing.T) {
+	secret, err := NewSecret()
+	if err != nil {
+		t.Fatalf("NewSecret: %v", err)
+	}
+	now := time.Unix(1_700_000_000, 0)
+	prev, _ := Code(secret, Step(now)-1)
+	step, ok := Verify(secret, prev, now)
+	if !ok || step != Step(now)-1 {
+		t.Fatalf("expected previous step to verify, got ok=%v step=%d", ok, step)
+	}
+	old, _ := Code(secret, Step(now)-3)
+	if _, ok := Verify(secret, old, now); ok {
+		t.Fatal("expected code three steps old to be rejected")
+	}
+	if _, ok := Verify(secret, "12345", now); ok {
+		t.Fatal("expected short code to be rejected")
+	}
+}
+
+func TestBackupCodesHashNormalised(t *testing.T) {
+	codes, hashes, err := NewBackupCodes(3)
+	if err != nil {
+		t.Fatalf("NewBackupCodes: %v", err)
+	}
+	if len(codes) != 3 || len(hashes) != 3 {
+		t.Fatalf("expected 3 codes, got %d/%d", len(codes), len(hashes))
+	}
+	typed := strings.ToUpper(strings.ReplaceAll(codes[0], "-", ""))
+	if HashBackupCode(typed) != hashes[0] {
+		t.Fatal("expected hash to ignore case and dashes")
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("Glory Grid", "ama@example.com", "JBSWY3DPEHPK3PXP")
+	if !strings.HasPrefix(uri, "otpauth://totp/Glory%20Grid:ama@example.com?") || !strings.Contains(uri, "secret=JBSWY3DPEHPK3PXP") {
+		t.Fatalf("unexpected uri: %s", uri)
+	}
+}
@@ -18,12 +18,14 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 
-	"gamehub/game-session-service/internal/auth"
 	"gamehub/game-session-service/internal/config"
 	"gamehub/game-session-service/internal/handler"
-	"gamehub/game-session-service/internal/middleware"
+	"gamehub/game-session-service/internal/risk"
 	"gamehub/game-session-service/internal/session"
+	"gamehub/game-session-service/internal/trader"
 	"gamehub/game-session-service/internal/wallet"
+	"gamehub/pkg/authn"
+	"gamehub/pkg/authn/rbac"
 )
 
 func main() {
@@ -31,7 +33,7 @@ func main() {
 
 	cfg := config.Load()
 
-	tokenValidator, err := auth.NewValidator(cfg.JWTPublicKeyPath, cfg.JWTIssuer)
+	tokenValidator, err := authn.NewValidator(cfg.JWTPublicKeyPath, cfg.JWKSURL, cfg.JWTIssuer)
 	if err != nil {
 		log.Fatalf("JWT validator init failed: %v", err)
 	}
@@ -58,15 +60,23 @@ func main() {
 	// --- Wallet client (direct HTTP for balance reservation) ---
 	walletClient := wallet.NewHTTPClient(cfg.WalletServiceURL, cfg.InternalKey)
 
+	// --- Trader-pool client (GET_QUOTE contract previews) ---
+	traderClient := trader.NewHTTPClient(cfg.TraderPoolURL, cfg.InternalKey)
+
+	// --- Risk engine (stake bounds + open exposure caps, shared via Redis) ---
+	riskEngine := risk.NewEngine(rdb, cfg)
+
 	// --- Session Manager ---
 	// Manages bet lifecycle: validate → reserve balance → call trader-pool → await result
 	// Results arrive via Redis PubSub published by trader-pool
-	mgr := session.NewManager(db, rdb, walletClient, cfg)
+	mgr := session.NewManager(db, rdb, walletClient, traderClient, riskEngine, cfg)
 
 	// Subscribe to game outcome channel from trader-pool
 	// trader-pool publishes: PUBLISH game:outcome:{sessionId} {outcome:"WIN",payoutUsd:95}
 	go mgr.SubscribeToOutcomes(context.Background())
 	go mgr.StartStaleSweeper(context.Background())
+	// Relay the trader-pool tick feed (ticks:{symbol}) to SUBSCRIBE_TICKS clients
+	go mgr.RelayTicks(context.Background())
 
 	// --- Fiber App ---
 	app := fiber.New(fiber.Config{
@@ -85,12 +95,20 @@ func main() {
 	})
 
 	// REST
-	v1 := app.Group("/api/v1/games", middleware.RequireAuth(tokenValidator))
+	v1 := app.Group("/api/v1/games", authn.RequireAuth(tokenValidator))
 	v1.Get("/history", h.GetHistory)
 	v1.Get("/session/:id", h.GetSession)
 
+	// Staff views (scope-checked)
+	admin := v1.Group("/admin", authn.RequireScope(rbac.ScopeGamesRead))
+	admin.Get("/session/:id", h.AdminGetSession)
+
+	// Internal (service key) — called by auth-service when a guest account is merged
+	internal := app.Group("/internal", authn.RequireInternalKey(cfg.InternalKey))
+	internal.Post("/sessions/reassign", h.InternalReassignSessions)
+
 	// WebSocket — full game session lifecycle
-	app.Use("/ws", middleware.UpgradeWS(tokenValidator))
+	app.Use("/ws", authn.UpgradeWS(tokenValidator))
 	app.Get("/ws", websocket.New(h.HandleWebSocket))
 
 	// --- Graceful Shutdown ---
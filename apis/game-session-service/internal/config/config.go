@@ -9,20 +9,55 @@ import (
 	"strings"
 )
 
+// StakeRange bounds the stake for one game type (0 disables a bound).
+type StakeRange struct {
+	Min float64
+	Max float64
+}
+
 type Config struct {
 	Port             string
 	MongoURI         string
 	RedisAddr        string
 	RedisPassword    string
 	WalletServiceURL string
+	TraderPoolURL    string
 	InternalKey      string
 	OrderQueue       string
 	OutcomePrefix    string
 	AppEnv           string
 	JWTPublicKeyPath string
 	JWTIssuer        string
+	JWKSURL          string // auth-service JWKS endpoint for rotated keys
 	StaleSweepSec    int
 	StaleRefundSec   int
+
+	// QuotePrefix:<quoteId> holds GET_QUOTE previews written by trader-pool
+	// (TRADE_QUOTE_PREFIX must match trader-pool).
+	QuotePrefix string
+
+	// Risk limits (0 disables a limit)
+	// MinStakeUsd/MaxStakeUsd apply to every game unless StakeLimits has an
+	// entry for its GameType, e.g. RISK_STAKE_LIMITS=DIGIT_DASH=0.5:200.
+	MinStakeUsd float64
+	MaxStakeUsd float64
+	StakeLimits map[string]StakeRange
+	// MaxOpenSessions and MaxUserExposureUsd cap a single user's pending bets;
+	// MaxGlobalExposureUsd caps pending stakes across the platform.
+	MaxOpenSessions      int
+	MaxUserExposureUsd   float64
+	MaxGlobalExposureUsd float64
+	// MaxSymbolLiabilityUsd caps the potential payout owed on one Deriv
+	// symbol. Unquoted bets count as stake × LiabilityMultiplier.
+	MaxSymbolLiabilityUsd float64
+	LiabilityMultiplier   float64
+	DefaultSymbol         string
+	RiskPrefix            string
+
+	// Live ticks relayed from the trader-pool feed (TICK_* match trader-pool).
+	TickSymbols       []string
+	TickChannelPrefix string
+	TickHistorySize   int
 }
 
 func Load() *Config {
@@ -32,14 +67,33 @@ func Load() *Config {
 		RedisAddr:        resolveRedisAddr(),
 		RedisPassword:    resolveRedisPassword(),
 		WalletServiceURL: getEnv("WALLET_SERVICE_URL", "http://127.0.0.1:8004"),
+		TraderPoolURL:    getEnv("TRADER_POOL_URL", "http://127.0.0.1:8005"),
 		InternalKey:      getEnv("INTERNAL_SERVICE_KEY", "dev-internal-key"),
 		OrderQueue:       getEnv("TRADE_ORDER_QUEUE", "trade:orders"),
 		OutcomePrefix:    getEnv("GAME_OUTCOME_PREFIX", "game:outcome"),
 		AppEnv:           getEnv("APP_ENV", "development"),
 		JWTPublicKeyPath: getEnv("JWT_PUBLIC_KEY_PATH", ""),
 		JWTIssuer:        getEnv("JWT_ISSUER", "gamehub-auth"),
+		JWKSURL:          getEnv("AUTH_JWKS_URL", "http://127.0.0.1:"+getEnv("AUTH_SERVICE_PORT", "8001")+"/.well-known/jwks.json"),
 		StaleSweepSec:    getEnvInt("GAME_STALE_SWEEP_INTERVAL_SECONDS", 20),
 		StaleRefundSec:   getEnvInt("GAME_STALE_REFUND_SECONDS", 90),
+
+		QuotePrefix: getEnv("TRADE_QUOTE_PREFIX", "trade:quote"),
+
+		MinStakeUsd:           getEnvFloat("RISK_MIN_STAKE_USD", 0.1),
+		MaxStakeUsd:           getEnvFloat("RISK_MAX_STAKE_USD", 1000),
+		StakeLimits:           parseStakeLimits(os.Getenv("RISK_STAKE_LIMITS")),
+		MaxOpenSessions:       getEnvInt("RISK_MAX_OPEN_SESSIONS", 5),
+		MaxUserExposureUsd:    getEnvFloat("RISK_MAX_USER_EXPOSURE_USD", 2000),
+		MaxGlobalExposureUsd:  getEnvFloat("RISK_MAX_GLOBAL_EXPOSURE_USD", 50000),
+		MaxSymbolLiabilityUsd: getEnvFloat("RISK_MAX_SYMBOL_LIABILITY_USD", 100000),
+		LiabilityMultiplier:   getEnvFloat("PAYOUT_MULTIPLIER", 1.9),
+		DefaultSymbol:         getEnv("DERIV_SYMBOL", "R_50"),
+		RiskPrefix:            getEnv("RISK_KEY_PREFIX", "risk"),
+
+		TickSymbols:       parseList(getEnv("TICK_SYMBOLS", getEnv("DERIV_SYMBOL", "R_50"))),
+		TickChannelPrefix: getEnv("TICK_CHANNEL_PREFIX", "ticks"),
+		TickHistorySize:   getEnvInt("TICK_HISTORY_SIZE", 300),
 	}
 }
 
@@ -67,6 +121,61 @@ func getEnvInt(key string, fallback int) int {
 	return fallback
 }
 
+func getEnvFloat(key string, fallback float64) float64 {
+	if v := os.Getenv(key); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f
+		}
+	}
+	return fallback
+}
+
+// parseStakeLimits reads comma-separated GAME=min:max entries, ignoring
+// malformed ones. Either bound may be left empty.
+func parseStakeLimits(raw string) map[string]StakeRange {
+	limits := make(map[string]StakeRange)
+	for _, entry := range strings.Split(raw, ",") {
+		game, bounds, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		game = strings.ToUpper(strings.TrimSpace(game))
+		if !ok || game == "" {
+			continue
+		}
+		minRaw, maxRaw, ok := strings.Cut(bounds, ":")
+		if !ok {
+			continue
+		}
+		var r StakeRange
+		var err error
+		if minRaw = strings.TrimSpace(minRaw); minRaw != "" {
+			if r.Min, err = strconv.ParseFloat(minRaw, 64); err != nil {
+				continue
+			}
+		}
+		if maxRaw = strings.TrimSpace(maxRaw); maxRaw != "" {
+			if r.Max, err = strconv.ParseFloat(maxRaw, 64); err != nil {
+				continue
+			}
+		}
+		limits[game] = r
+	}
+	return limits
+}
+
+// parseList splits a comma-separated value, dropping blanks and duplicates.
+func parseList(raw string) []string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(raw, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" || seen[item] {
+			continue
+		}
+		seen[item] = true
+		out = append(out, item)
+	}
+	return out
+}
+
 func resolveRedisAddr() string {
 	if addr, _, ok := redisFromURL(os.Getenv("REDIS_URL")); ok {
 		return addr
@@ -3,6 +3,7 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"strconv"
 	"time"
 
@@ -13,6 +14,7 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"gamehub/game-session-service/internal/config"
+	"gamehub/game-session-service/internal/risk"
 	"gamehub/game-session-service/internal/session"
 )
 
@@ -65,13 +67,20 @@ func (h *Handler) GetHistory(c *fiber.Ctx) error {
 
 func (h *Handler) GetSession(c *fiber.Ctx) error {
 	userID := c.Locals("userId").(string)
-	sessionID := c.Params("id")
+	return h.session(c, bson.M{"sessionId": c.Params("id"), "userId": userID})
+}
+
+// AdminGetSession returns any session for support staff.
+func (h *Handler) AdminGetSession(c *fiber.Ctx) error {
+	return h.session(c, bson.M{"sessionId": c.Params("id")})
+}
 
+func (h *Handler) session(c *fiber.Ctx, filter bson.M) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	var doc bson.M
-	err := h.db.Collection("game_sessions").FindOne(ctx, bson.M{"sessionId": sessionID, "userId": userID}).Decode(&doc)
+	err := h.db.Collection("game_sessions").FindOne(ctx, filter).Decode(&doc)
 	if err == mongo.ErrNoDocuments {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "session not found"})
 	}
@@ -81,6 +90,34 @@ func (h *Handler) GetSession(c *fiber.Ctx) error {
 	return c.JSON(doc)
 }
 
+// InternalReassignSessions moves a guest's game history onto the account it
+// was merged into. Repeating the call is a no-op.
+func (h *Handler) InternalReassignSessions(c *fiber.Ctx) error {
+	var body struct {
+		FromUserID string `json:"fromUserId"`
+		ToUserID   string `json:"toUserId"`
+	}
+	if err := c.BodyParser(&body); err != nil || body.FromUserID == "" || body.ToUserID == "" || body.FromUserID == body.ToUserID {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid payload"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	res, err := h.db.Collection("game_sessions").UpdateMany(ctx,
+		bson.M{"userId": body.FromUserID},
+		bson.M{"$set": bson.M{
+			"userId":           body.ToUserID,
+			"mergedFromUserId": body.FromUserID,
+			"updatedAt":        time.Now(),
+		}},
+	)
+	if err != nil {
+		return fiberErr(c, err)
+	}
+	return c.JSON(fiber.Map{"reassigned": res.ModifiedCount})
+}
+
 func (h *Handler) HandleWebSocket(conn *websocket.Conn) {
 	userID, _ := conn.Locals("userId").(string)
 	if userID == "" {
@@ -140,6 +177,38 @@ func (h *Handler) HandleWebSocket(conn *websocket.Conn) {
 		}
 
 		switch envelope.Type {
+		case "GET_QUOTE":
+			var req session.GetQuoteRequest
+			if err := json.Unmarshal(data, &req); err != nil {
+				conn.WriteJSON(fiber.Map{"type": "ERROR", "message": "bad quote payload"})
+				continue
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			quote, err := h.mgr.GetQuote(ctx, userID, req)
+			cancel()
+			if err != nil {
+				conn.WriteJSON(fiber.Map{
+					"type":    "QUOTE_REJECTED",
+					"reason":  err.Error(),
+					"traceId": req.TraceID,
+				})
+				continue
+			}
+			conn.WriteJSON(fiber.Map{
+				"type":         "QUOTE",
+				"quoteId":      quote.QuoteID,
+				"gameType":     quote.GameType,
+				"stakeUsd":     quote.StakeUsd,
+				"askPrice":     quote.AskPrice,
+				"payoutUsd":    quote.PayoutUsd,
+				"spot":         quote.Spot,
+				"barrier":      quote.Barrier,
+				"barrier2":     quote.Barrier2,
+				"contractType": quote.ContractType,
+				"longcode":     quote.Longcode,
+				"expiresAt":    quote.ExpiresAt,
+				"traceId":      req.TraceID,
+			})
 		case "PLACE_BET":
 			var req session.PlaceBetRequest
 			if err := json.Unmarshal(data, &req); err != nil {
@@ -153,6 +222,7 @@ func (h *Handler) HandleWebSocket(conn *websocket.Conn) {
 				conn.WriteJSON(fiber.Map{
 					"type":    "BET_REJECTED",
 					"reason":  err.Error(),
+					"code":    rejectionCode(err),
 					"traceId": req.TraceID,
 				})
 				continue
@@ -273,6 +343,15 @@ func (h *Handler) HandleWebSocket(conn *websocket.Conn) {
 			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 			payload, err := h.mgr.StartRoomRound(ctx, userID, req.StakeUsd)
 			cancel()
+			var limitErr *risk.LimitError
+			if errors.As(err, &limitErr) {
+				conn.WriteJSON(fiber.Map{
+					"type":   "BET_REJECTED",
+					"reason": err.Error(),
+					"code":   limitErr.Code,
+				})
+				continue
+			}
 			if err != nil {
 				conn.WriteJSON(fiber.Map{"type": "ROOM_ERROR", "message": err.Error()})
 				continue
@@ -326,6 +405,25 @@ func (h *Handler) HandleWebSocket(conn *websocket.Conn) {
 			}
 			conn.WriteJSON(fiber.Map{"type": "ROOM_PLAYER_KICKED"})
 			conn.WriteJSON(fiber.Map{"type": "ROOM_STATE", "payload": snapshot})
+		case "SUBSCRIBE_TICKS":
+			var req session.SubscribeTicksRequest
+			if err := json.Unmarshal(data, &req); err != nil {
+				conn.WriteJSON(fiber.Map{"type": "ERROR", "message": "bad ticks payload"})
+				continue
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			history, err := h.mgr.SubscribeTicks(ctx, events, req)
+			cancel()
+			if err != nil {
+				conn.WriteJSON(fiber.Map{"type": "ERROR", "message": err.Error()})
+				continue
+			}
+			conn.WriteJSON(fiber.Map{"type": "TICK_HISTORY", "payload": history})
+		case "UNSUBSCRIBE_TICKS":
+			var req session.SubscribeTicksRequest
+			_ = json.Unmarshal(data, &req)
+			h.mgr.UnsubscribeTicks(events, req.Symbol)
+			conn.WriteJSON(fiber.Map{"type": "TICKS_UNSUBSCRIBED", "symbol": req.Symbol})
 		case "PING":
 			conn.WriteJSON(fiber.Map{"type": "PONG"})
 		case "VIEW_GAME":
@@ -357,3 +455,21 @@ func parseInt(val string, fallback int) int {
 func fiberErr(c *fiber.Ctx, err error) error {
 	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 }
+
+// rejectionCode classifies a PlaceBet error for BET_REJECTED so clients can
+// react without parsing the reason text.
+func rejectionCode(err error) string {
+	var limitErr *risk.LimitError
+	switch {
+	case errors.As(err, &limitErr):
+		return limitErr.Code
+	case errors.Is(err, session.ErrInvalidStake):
+		return "INVALID_STAKE"
+	case errors.Is(err, session.ErrQuoteExpired):
+		return "QUOTE_EXPIRED"
+	case errors.Is(err, session.ErrQuoteMismatch):
+		return "QUOTE_MISMATCH"
+	default:
+		return "BET_FAILED"
+	}
+}
@@ -0,0 +1,248 @@
+// Package risk caps how much money is at stake on open bets. Open exposure is
+// tracked in Redis so every game-session replica enforces the same limits.
+package risk
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"gamehub/game-session-service/internal/config"
+)
+
+// Rejection codes surfaced to clients in BET_REJECTED.
+const (
+	CodeStakeTooLow     = "STAKE_TOO_LOW"
+	CodeStakeTooHigh    = "STAKE_TOO_HIGH"
+	CodeMaxOpenSessions = "MAX_OPEN_SESSIONS"
+	CodeUserExposure    = "USER_EXPOSURE_LIMIT"
+	CodeGlobalExposure  = "GLOBAL_EXPOSURE_LIMIT"
+	CodeSymbolLiability = "SYMBOL_LIABILITY_LIMIT"
+)
+
+// openSessionRetention bounds how long a reservation survives if its session
+// is never settled, e.g. when its outcome was lost or a rollback failed.
+// Unsettled sessions are refunded after seconds, so anything older is a leak.
+const openSessionRetention = time.Hour
+
+// LimitError explains why a bet was refused.
+type LimitError struct {
+	Code   string
+	Reason string
+}
+
+func (e *LimitError) Error() string {
+	return e.Reason
+}
+
+// Exposure describes one open bet. Symbol is empty for bets with no market
+// liability (e.g. player-vs-player rooms).
+type Exposure struct {
+	UserID       string
+	SessionID    string
+	GameType     string
+	Symbol       string
+	StakeUsd     float64
+	LiabilityUsd float64
+}
+
+type Engine struct {
+	rdb *redis.Client
+	cfg *config.Config
+}
+
+func NewEngine(rdb *redis.Client, cfg *config.Config) *Engine {
+	return &Engine{rdb: rdb, cfg: cfg}
+}
+
+// reserveScript checks every limit and records the exposure atomically.
+// KEYS: user open hash, global exposure, symbol liability, session record,
+// open sessions by expiry.
+// ARGV: sessionId, stake, liability, maxOpen, maxUser, maxGlobal, maxSymbol,
+// userId, symbol, retention seconds, expiry (unix seconds).
+var reserveScript = redis.NewScript(`
+local stake = tonumber(ARGV[2])
+local liability = tonumber(ARGV[3])
+local maxOpen = tonumber(ARGV[4])
+local maxUser = tonumber(ARGV[5])
+local maxGlobal = tonumber(ARGV[6])
+local maxSymbol = tonumber(ARGV[7])
+
+local open = redis.call('HVALS', KEYS[1])
+if maxOpen > 0 and #open >= maxOpen then
+  return {'` + CodeMaxOpenSessions + `', tostring(#open)}
+end
+local userExposure = 0
+for _, v in ipairs(open) do userExposure = userExposure + tonumber(v) end
+if maxUser > 0 and userExposure + stake > maxUser then
+  return {'` + CodeUserExposure + `', tostring(userExposure)}
+end
+local global = tonumber(redis.call('GET', KEYS[2]) or '0')
+if maxGlobal > 0 and global + stake > maxGlobal then
+  return {'` + CodeGlobalExposure + `', tostring(global)}
+end
+if ARGV[9] ~= '' then
+  local symbol = tonumber(redis.call('GET', KEYS[3]) or '0')
+  if maxSymbol > 0 and symbol + liability > maxSymbol then
+    return {'` + CodeSymbolLiability + `', tostring(symbol)}
+  end
+  redis.call('INCRBYFLOAT', KEYS[3], liability)
+end
+
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+redis.call('EXPIRE', KEYS[1], ARGV[10])
+redis.call('INCRBYFLOAT', KEYS[2], stake)
+redis.call('HSET', KEYS[4], 'userId', ARGV[8], 'stake', ARGV[2], 'symbol', ARGV[9], 'liability', ARGV[3])
+redis.call('EXPIRE', KEYS[4], 2 * tonumber(ARGV[10]))
+redis.call('ZADD', KEYS[5], ARGV[11], ARGV[1])
+return {'OK', ''}
+`)
+
+// releaseScript undoes a reservation once. KEYS as reserveScript; ARGV:
+// sessionId, stake, liability, symbol.
+var releaseScript = redis.NewScript(`
+redis.call('ZREM', KEYS[5], ARGV[1])
+if redis.call('DEL', KEYS[4]) == 0 then
+  return 0
+end
+redis.call('HDEL', KEYS[1], ARGV[1])
+redis.call('INCRBYFLOAT', KEYS[2], -tonumber(ARGV[2]))
+if ARGV[4] ~= '' then
+  redis.call('INCRBYFLOAT', KEYS[3], -tonumber(ARGV[3]))
+end
+return 1
+`)
+
+// Reserve records exposure for a new bet, or returns a *LimitError when it
+// would breach a limit. Call it before reserving funds in the wallet.
+func (e *Engine) Reserve(ctx context.Context, exp Exposure) error {
+	if err := e.CheckStake(exp.GameType, exp.StakeUsd); err != nil {
+		return err
+	}
+	res, err := reserveScript.Run(ctx, e.rdb, e.keys(exp.UserID, exp.SessionID, exp.Symbol),
+		exp.SessionID,
+		formatUsd(exp.StakeUsd),
+		formatUsd(exp.LiabilityUsd),
+		e.cfg.MaxOpenSessions,
+		formatUsd(e.cfg.MaxUserExposureUsd),
+		formatUsd(e.cfg.MaxGlobalExposureUsd),
+		formatUsd(e.cfg.MaxSymbolLiabilityUsd),
+		exp.UserID,
+		exp.Symbol,
+		int(openSessionRetention.Seconds()),
+		time.Now().Add(openSessionRetention).Unix(),
+	).StringSlice()
+	if err != nil {
+		return fmt.Errorf("risk reserve: %w", err)
+	}
+	if len(res) != 2 || res[0] == "OK" {
+		return nil
+	}
+	return e.limitError(res[0], exp)
+}
+
+// Release frees the exposure held by sessionID. It is safe to call more than
+// once and for sessions that never reserved.
+func (e *Engine) Release(ctx context.Context, sessionID string) error {
+	rec, err := e.rdb.HGetAll(ctx, e.sessionKey(sessionID)).Result()
+	if err != nil {
+		return fmt.Errorf("risk release: %w", err)
+	}
+	if len(rec) == 0 {
+		return e.rdb.ZRem(ctx, e.openKey(), sessionID).Err()
+	}
+	err = releaseScript.Run(ctx, e.rdb, e.keys(rec["userId"], sessionID, rec["symbol"]),
+		sessionID, rec["stake"], rec["liability"], rec["symbol"],
+	).Err()
+	if err != nil {
+		return fmt.Errorf("risk release: %w", err)
+	}
+	return nil
+}
+
+// Reap releases reservations older than openSessionRetention, so exposure
+// leaked by a session that was never settled stops counting against the
+// limits. It returns how many it released.
+func (e *Engine) Reap(ctx context.Context) (int, error) {
+	expired, err := e.rdb.ZRangeByScore(ctx, e.openKey(), &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(time.Now().Unix(), 10),
+		Count: 100,
+	}).Result()
+	if err != nil {
+		return 0, fmt.Errorf("risk reap: %w", err)
+	}
+	for i, sessionID := range expired {
+		if err := e.Release(ctx, sessionID); err != nil {
+			return i, err
+		}
+	}
+	return len(expired), nil
+}
+
+// CheckStake applies the min/max stake for gameType.
+func (e *Engine) CheckStake(gameType string, stake float64) error {
+	r := config.StakeRange{Min: e.cfg.MinStakeUsd, Max: e.cfg.MaxStakeUsd}
+	if override, ok := e.cfg.StakeLimits[strings.ToUpper(gameType)]; ok {
+		r = override
+	}
+	if r.Min > 0 && stake < r.Min {
+		return &LimitError{Code: CodeStakeTooLow, Reason: fmt.Sprintf("minimum stake for %s is %.2f USD", gameType, r.Min)}
+	}
+	if r.Max > 0 && stake > r.Max {
+		return &LimitError{Code: CodeStakeTooHigh, Reason: fmt.Sprintf("maximum stake for %s is %.2f USD", gameType, r.Max)}
+	}
+	return nil
+}
+
+// Liability is the payout owed if the bet wins. quotedPayout wins when set.
+func (e *Engine) Liability(stake, quotedPayout float64) float64 {
+	if quotedPayout > 0 {
+		return quotedPayout
+	}
+	return stake * e.cfg.LiabilityMultiplier
+}
+
+func (e *Engine) limitError(code string, exp Exposure) *LimitError {
+	var reason string
+	switch code {
+	case CodeMaxOpenSessions:
+		reason = fmt.Sprintf("too many open bets (max %d)", e.cfg.MaxOpenSessions)
+	case CodeUserExposure:
+		reason = fmt.Sprintf("open stakes would exceed %.2f USD", e.cfg.MaxUserExposureUsd)
+	case CodeGlobalExposure:
+		reason = "platform exposure limit reached, try again shortly"
+	case CodeSymbolLiability:
+		reason = fmt.Sprintf("%s is at its liability limit, try a smaller stake", exp.Symbol)
+	default:
+		reason = "bet exceeds risk limits"
+	}
+	return &LimitError{Code: code, Reason: reason}
+}
+
+func (e *Engine) keys(userID, sessionID, symbol string) []string {
+	prefix := e.cfg.RiskPrefix
+	return []string{
+		prefix + ":user:" + userID + ":open",
+		prefix + ":global:exposure",
+		prefix + ":symbol:" + symbol + ":liability",
+		e.sessionKey(sessionID),
+		e.openKey(),
+	}
+}
+
+func (e *Engine) openKey() string {
+	return e.cfg.RiskPrefix + ":open"
+}
+
+func (e *Engine) sessionKey(sessionID string) string {
+	return e.cfg.RiskPrefix + ":session:" + sessionID
+}
+
+func formatUsd(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,48 @@
+package risk
+
+import (
+	"errors"
+	"testing"
+
+	"gamehub/game-session-service/internal/config"
+)
+
+func TestCheckStake(t *testing.T) {
+	engine := NewEngine(nil, &config.Config{
+		MinStakeUsd: 1,
+		MaxStakeUsd: 100,
+		StakeLimits: map[string]config.StakeRange{"DIGIT_DASH": {Min: 0.5, Max: 20}},
+	})
+
+	cases := []struct {
+		game  string
+		stake float64
+		code  string
+	}{
+		{"NEON_PERIMETER", 0.5, CodeStakeTooLow},
+		{"NEON_PERIMETER", 50, ""},
+		{"NEON_PERIMETER", 150, CodeStakeTooHigh},
+		{"DIGIT_DASH", 0.5, ""},
+		{"digit_dash", 25, CodeStakeTooHigh},
+	}
+	for _, tc := range cases {
+		err := engine.CheckStake(tc.game, tc.stake)
+		var limitErr *LimitError
+		switch {
+		case tc.code == "" && err != nil:
+			t.Errorf("%s/%.2f: unexpected error %v", tc.game, tc.stake, err)
+		case tc.code != "" && (!errors.As(err, &limitErr) || limitErr.Code != tc.code):
+			t.Errorf("%s/%.2f: got %v, want %s", tc.game, tc.stake, err, tc.code)
+		}
+	}
+}
+
+func TestLiabilityPrefersQuotedPayout(t *testing.T) {
+	engine := NewEngine(nil, &config.Config{LiabilityMultiplier: 1.9})
+	if got := engine.Liability(10, 0); got != 19 {
+		t.Fatalf("unquoted liability = %.2f, want 19", got)
+	}
+	if got := engine.Liability(10, 18.4); got != 18.4 {
+		t.Fatalf("quoted liability = %.2f, want 18.4", got)
+	}
+}
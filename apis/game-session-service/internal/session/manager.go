@@ -16,6 +16,8 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"gamehub/game-session-service/internal/config"
+	"gamehub/game-session-service/internal/risk"
+	"gamehub/game-session-service/internal/trader"
 	"gamehub/game-session-service/internal/wallet"
 )
 
@@ -27,6 +29,8 @@ type Manager struct {
 	db     *mongo.Database
 	rdb    *redis.Client
 	wallet *wallet.Client
+	trader *trader.Client
+	risk   *risk.Engine
 	cfg    *config.Config
 
 	mu          sync.RWMutex
@@ -38,6 +42,9 @@ type Manager struct {
 
 	viewingMu   sync.RWMutex
 	viewingGame map[string]string // map[userID]gameKey
+
+	tickMu   sync.RWMutex
+	tickSubs map[string]map[chan []byte]struct{} // map[symbol]set[subscriber channel]
 }
 
 type PlaceBetRequest struct {
@@ -45,6 +52,8 @@ type PlaceBetRequest struct {
 	StakeUsd   float64                `json:"stakeUsd"`
 	Prediction map[string]interface{} `json:"prediction"`
 	TraceID    string                 `json:"traceId,omitempty"`
+	// QuoteID places the bet on terms previewed with GET_QUOTE.
+	QuoteID string `json:"quoteId,omitempty"`
 }
 
 type CashOutBetRequest struct {
@@ -73,16 +82,19 @@ type SessionOutcome struct {
 	ContractID   string  `json:"derivContractId,omitempty"`
 }
 
-func NewManager(db *mongo.Database, rdb *redis.Client, walletClient *wallet.Client, cfg *config.Config) *Manager {
+func NewManager(db *mongo.Database, rdb *redis.Client, walletClient *wallet.Client, traderClient *trader.Client, riskEngine *risk.Engine, cfg *config.Config) *Manager {
 	return &Manager{
 		db:          db,
 		rdb:         rdb,
 		wallet:      walletClient,
+		trader:      traderClient,
+		risk:        riskEngine,
 		cfg:         cfg,
 		subscribers: make(map[string]map[chan []byte]struct{}),
 		rooms:       make(map[string]*multiplayerRoom),
 		userRooms:   make(map[string]string),
 		viewingGame: make(map[string]string),
+		tickSubs:    make(map[string]map[chan []byte]struct{}),
 	}
 }
 
@@ -95,10 +107,57 @@ func (m *Manager) PlaceBet(ctx context.Context, userID string, req PlaceBetReque
 		traceID = uuid.NewString()
 	}
 	sessionID := primitive.NewObjectID().Hex()
+
+	// A quote is only used up once the bet passes the risk checks, and is
+	// put back if the wallet then refuses the stake.
+	var quote *trader.Quote
+	var rawQuote string
+	if req.QuoteID != "" {
+		q, raw, err := m.peekQuote(ctx, userID, req)
+		if err != nil {
+			log.Printf("[trace=%s] quote %s rejected user=%s err=%v", traceID, req.QuoteID, userID, err)
+			return nil, err
+		}
+		quote, rawQuote = q, raw
+		req.GameType = q.GameType
+		req.Prediction = q.Prediction
+	}
 	if req.GameType == "" {
 		req.GameType = "NEON_PERIMETER"
 	}
 
+	exposure := risk.Exposure{
+		UserID:       userID,
+		SessionID:    sessionID,
+		GameType:     req.GameType,
+		Symbol:       m.cfg.DefaultSymbol,
+		StakeUsd:     req.StakeUsd,
+		LiabilityUsd: m.risk.Liability(req.StakeUsd, 0),
+	}
+	if symbol, ok := req.Prediction["symbol"].(string); ok && symbol != "" {
+		exposure.Symbol = symbol
+	}
+	if quote != nil {
+		if quote.Symbol != "" {
+			exposure.Symbol = quote.Symbol
+		}
+		exposure.LiabilityUsd = m.risk.Liability(req.StakeUsd, quote.PayoutUsd)
+	}
+	if err := m.risk.Reserve(ctx, exposure); err != nil {
+		log.Printf("[trace=%s] risk rejected bet user=%s game=%s stake=%.2f err=%v", traceID, userID, req.GameType, req.StakeUsd, err)
+		return nil, err
+	}
+	var quoteTTL time.Duration
+	if quote != nil {
+		ttl, err := m.claimQuote(ctx, req.QuoteID, rawQuote)
+		if err != nil {
+			log.Printf("[trace=%s] quote %s rejected user=%s err=%v", traceID, req.QuoteID, userID, err)
+			m.releaseExposure(sessionID)
+			return nil, err
+		}
+		quoteTTL = ttl
+	}
+
 	bal, err := m.wallet.ReserveBet(ctx, wallet.ReserveBetRequest{
 		UserID:    userID,
 		SessionID: sessionID,
@@ -108,6 +167,10 @@ func (m *Manager) PlaceBet(ctx context.Context, userID string, req PlaceBetReque
 	})
 	if err != nil {
 		log.Printf("[trace=%s] reserve bet failed user=%s err=%v", traceID, userID, err)
+		m.releaseExposure(sessionID)
+		if quote != nil {
+			m.restoreQuote(ctx, req.QuoteID, rawQuote, quoteTTL)
+		}
 		return nil, err
 	}
 
@@ -123,8 +186,17 @@ func (m *Manager) PlaceBet(ctx context.Context, userID string, req PlaceBetReque
 		"createdAt":  now,
 		"updatedAt":  now,
 	}
+	if quote != nil {
+		doc["quoteId"] = quote.QuoteID
+		doc["quotedPayoutUsd"] = quote.PayoutUsd
+	}
 	if _, err := m.db.Collection("game_sessions").InsertOne(ctx, doc); err != nil {
 		log.Printf("[trace=%s] failed to insert session %s: %v", traceID, sessionID, err)
+		m.refundStake(userID, sessionID, req.StakeUsd, traceID)
+		m.releaseExposure(sessionID)
+		if quote != nil {
+			m.restoreQuote(ctx, req.QuoteID, rawQuote, quoteTTL)
+		}
 		return nil, err
 	}
 
@@ -137,9 +209,31 @@ func (m *Manager) PlaceBet(ctx context.Context, userID string, req PlaceBetReque
 		"traceId":    traceID,
 		"createdAt":  now.UnixMilli(),
 	}
+	if quote != nil {
+		order["quote"] = quote
+	}
 	payload, _ := json.Marshal(order)
 	if err := m.rdb.RPush(ctx, m.cfg.OrderQueue, payload).Err(); err != nil {
 		log.Printf("[trace=%s] failed to enqueue session %s: %v", traceID, sessionID, err)
+		if bal, rerr := m.refundStake(userID, sessionID, req.StakeUsd, traceID); rerr == nil {
+			m.persistOutcome(context.Background(), SessionOutcome{
+				SessionID:  sessionID,
+				UserID:     userID,
+				GameType:   req.GameType,
+				Outcome:    "REFUND",
+				PayoutUsd:  req.StakeUsd,
+				StakeUsd:   req.StakeUsd,
+				NewBalance: bal.AvailableUsd,
+				TraceID:    traceID,
+				ContractID: "REFUND",
+			})
+		} else {
+			// The session stays PENDING for the stale sweeper to refund.
+			m.releaseExposure(sessionID)
+		}
+		if quote != nil {
+			m.restoreQuote(ctx, req.QuoteID, rawQuote, quoteTTL)
+		}
 		return nil, err
 	}
 	m.rdb.Expire(ctx, m.cfg.OrderQueue, 12*time.Hour)
@@ -208,6 +302,7 @@ func (m *Manager) Subscribe(userID string) (chan []byte, func()) {
 	m.subscribers[userID][ch] = struct{}{}
 
 	unsubscribe := func() {
+		m.UnsubscribeTicks(ch, "")
 		m.mu.Lock()
 		defer m.mu.Unlock()
 		if subs, ok := m.subscribers[userID]; ok {
@@ -271,6 +366,7 @@ func (m *Manager) SubscribeToOutcomes(ctx context.Context) {
 }
 
 func (m *Manager) persistOutcome(ctx context.Context, outcome SessionOutcome) {
+	m.releaseExposure(outcome.SessionID)
 	_, _ = m.db.Collection("game_sessions").UpdateOne(
 		ctx,
 		bson.M{"sessionId": outcome.SessionID},
@@ -288,6 +384,45 @@ func (m *Manager) persistOutcome(ctx context.Context, outcome SessionOutcome) {
 	)
 }
 
+// reapExposure frees risk reservations whose sessions were never settled.
+func (m *Manager) reapExposure(ctx context.Context) {
+	n, err := m.risk.Reap(ctx)
+	if err != nil {
+		log.Printf("⚠️  reap exposure: %v", err)
+	}
+	if n > 0 {
+		log.Printf("released %d expired risk reservations", n)
+	}
+}
+
+// refundStake hands back the stake ReserveBet held for a bet that never
+// reached the trader.
+func (m *Manager) refundStake(userID, sessionID string, stake float64, traceID string) (*wallet.Balance, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	bal, err := m.wallet.SettleGame(ctx, wallet.SettleGameRequest{
+		UserID:    userID,
+		SessionID: sessionID,
+		Outcome:   "REFUND",
+		StakeUsd:  stake,
+		PayoutUsd: stake,
+		TraceID:   traceID,
+	})
+	if err != nil {
+		log.Printf("[trace=%s] refund of unplaced session=%s failed: %v", traceID, sessionID, err)
+	}
+	return bal, err
+}
+
+// releaseExposure frees the risk reservation of a settled or abandoned session.
+func (m *Manager) releaseExposure(sessionID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := m.risk.Release(ctx, sessionID); err != nil {
+		log.Printf("⚠️  release exposure session=%s: %v", sessionID, err)
+	}
+}
+
 func wsMessage(messageType string, payload interface{}) map[string]interface{} {
 	return map[string]interface{}{
 		"type":    messageType,
@@ -310,6 +445,7 @@ func (m *Manager) StartStaleSweeper(ctx context.Context) {
 		case <-ticker.C:
 			sweepCtx, cancel := context.WithTimeout(context.Background(), 8*time.Second)
 			m.refundStaleSessions(sweepCtx)
+			m.reapExposure(sweepCtx)
 			cancel()
 		}
 	}
@@ -0,0 +1,220 @@
+package session
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+
+	"gamehub/game-session-service/internal/config"
+	"gamehub/game-session-service/internal/risk"
+	"gamehub/game-session-service/internal/wallet"
+)
+
+// fakeRedis answers just enough of the Redis protocol for PlaceBet: risk
+// scripts succeed, risk lookups find nothing, and RPUSH fails when
+// failPush is set. It records every command it receives.
+type fakeRedis struct {
+	failPush bool
+
+	mu       sync.Mutex
+	commands [][]string
+}
+
+func newFakeRedis(t *testing.T, failPush bool) (*fakeRedis, *redis.Client) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeRedis{failPush: failPush}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	rdb := redis.NewClient(&redis.Options{Addr: ln.Addr().String(), DisableIndentity: true})
+	t.Cleanup(func() {
+		rdb.Close()
+		ln.Close()
+	})
+	return f, rdb
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		f.commands = append(f.commands, args)
+		f.mu.Unlock()
+
+		var reply string
+		switch strings.ToUpper(args[0]) {
+		case "EVALSHA", "EVAL":
+			reply = "*2\r\n$2\r\nOK\r\n$0\r\n\r\n"
+		case "HGETALL":
+			reply = "*0\r\n"
+		case "RPUSH":
+			if f.failPush {
+				reply = "-ERR out of memory\r\n"
+			} else {
+				reply = ":1\r\n"
+			}
+		case "HELLO":
+			reply = "-ERR unknown command 'HELLO'\r\n"
+		case "ZREM", "EXPIRE":
+			reply = ":1\r\n"
+		default:
+			reply = "+OK\r\n"
+		}
+		if _, err := io.WriteString(conn, reply); err != nil {
+			return
+		}
+	}
+}
+
+// called reports whether a command named name was sent with arg.
+func (f *fakeRedis) called(name, arg string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, cmd := range f.commands {
+		if !strings.EqualFold(cmd[0], name) {
+			continue
+		}
+		for _, a := range cmd[1:] {
+			if strings.Contains(a, arg) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil {
+		return nil, fmt.Errorf("bad array header %q", line)
+	}
+	args := make([]string, n)
+	for i := range args {
+		if _, err := r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		arg, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		args[i] = strings.TrimSuffix(arg, "\r\n")
+	}
+	return args, nil
+}
+
+// walletRecorder stands in for the wallet service, recording the
+// settlements it receives.
+type walletRecorder struct {
+	mu      sync.Mutex
+	settles []wallet.SettleGameRequest
+}
+
+func (w *walletRecorder) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/internal/ledger/settle-game" {
+		var req wallet.SettleGameRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		w.mu.Lock()
+		w.settles = append(w.settles, req)
+		w.mu.Unlock()
+	}
+	json.NewEncoder(rw).Encode(wallet.Balance{UserID: "user-1", AvailableUsd: 100})
+}
+
+func (w *walletRecorder) refunds() []wallet.SettleGameRequest {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]wallet.SettleGameRequest(nil), w.settles...)
+}
+
+func TestPlaceBetUnwindsWhenTheBetIsNotQueued(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	newManager := func(mt *mtest.T, failPush bool) (*Manager, *fakeRedis, *walletRecorder) {
+		cfg := &config.Config{OrderQueue: "trade:orders", DefaultSymbol: "R_50", LiabilityMultiplier: 2}
+		f, rdb := newFakeRedis(mt.T, failPush)
+		recorder := &walletRecorder{}
+		ws := httptest.NewServer(recorder)
+		mt.T.Cleanup(ws.Close)
+		return NewManager(mt.DB, rdb, wallet.NewHTTPClient(ws.URL, "internal"), nil, risk.NewEngine(rdb, cfg), cfg), f, recorder
+	}
+	// checkRefunded asserts the stake went back to the wallet and the risk
+	// reservation was released.
+	checkRefunded := func(mt *mtest.T, f *fakeRedis, recorder *walletRecorder) string {
+		refunds := recorder.refunds()
+		if len(refunds) != 1 || refunds[0].Outcome != "REFUND" || refunds[0].StakeUsd != 10 || refunds[0].PayoutUsd != 10 {
+			mt.Fatalf("wallet settlements %+v", refunds)
+		}
+		if !f.called("HGETALL", refunds[0].SessionID) {
+			mt.Fatal("risk exposure not released")
+		}
+		return refunds[0].SessionID
+	}
+
+	mt.Run("insert fails", func(mt *mtest.T) {
+		m, f, recorder := newManager(mt, false)
+		mt.AddMockResponses(mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 91, Message: "shutting down"}))
+		if _, err := m.PlaceBet(context.Background(), "user-1", PlaceBetRequest{GameType: "DIGIT_DASH", StakeUsd: 10}); err == nil {
+			mt.Fatal("expected the insert error")
+		}
+		checkRefunded(mt, f, recorder)
+		if f.called("RPUSH", "trade:orders") {
+			mt.Fatal("an unrecorded bet was queued")
+		}
+	})
+	mt.Run("enqueue fails", func(mt *mtest.T) {
+		m, f, recorder := newManager(mt, true)
+		// Session insert, then its REFUND outcome.
+		mt.AddMockResponses(mtest.CreateSuccessResponse(), mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}))
+		if _, err := m.PlaceBet(context.Background(), "user-1", PlaceBetRequest{GameType: "DIGIT_DASH", StakeUsd: 10}); err == nil {
+			mt.Fatal("expected the enqueue error")
+		}
+		sessionID := checkRefunded(mt, f, recorder)
+		var update bson.Raw
+		for _, e := range mt.GetAllStartedEvents() {
+			if e.CommandName == "update" {
+				update = e.Command
+			}
+		}
+		if update == nil {
+			mt.Fatal("session not closed")
+		}
+		stmt := update.Lookup("updates").Array().Index(0).Value().Document()
+		if id := stmt.Lookup("q", "sessionId").StringValue(); id != sessionID {
+			mt.Fatalf("closed session %s, want %s", id, sessionID)
+		}
+		if status := stmt.Lookup("u", "$set", "status").StringValue(); status != "REFUND" {
+			mt.Fatalf("session status %s", status)
+		}
+	})
+}
@@ -15,6 +15,7 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 
+	"gamehub/game-session-service/internal/risk"
 	"gamehub/game-session-service/internal/wallet"
 )
 
@@ -814,13 +815,25 @@ func (m *Manager) StartRoomRound(ctx context.Context, userID string, nextStakeUs
 	for uid, participant := range participants {
 		sessionID := primitive.NewObjectID().Hex()
 		traceID := uuid.NewString()
-		if _, err := m.wallet.ReserveBet(ctx, wallet.ReserveBetRequest{
+		err := m.risk.Reserve(ctx, risk.Exposure{
 			UserID:    uid,
 			SessionID: sessionID,
 			GameType:  "MULTI_" + gameKey,
-			AmountUsd: participant.StakeUsd,
-			TraceID:   traceID,
-		}); err != nil {
+			StakeUsd:  participant.StakeUsd,
+		})
+		if err == nil {
+			_, err = m.wallet.ReserveBet(ctx, wallet.ReserveBetRequest{
+				UserID:    uid,
+				SessionID: sessionID,
+				GameType:  "MULTI_" + gameKey,
+				AmountUsd: participant.StakeUsd,
+				TraceID:   traceID,
+			})
+			if err != nil {
+				m.releaseExposure(sessionID)
+			}
+		}
+		if err != nil {
 			for _, refund := range reserved {
 				refundTrace := uuid.NewString()
 				bal, settleErr := m.wallet.SettleGame(context.Background(), wallet.SettleGameRequest{
@@ -6,7 +6,7 @@ import (
 )
 
 func TestCreateRoomAllowsMultipleRoomsForSameGame(t *testing.T) {
-	manager := NewManager(nil, nil, nil, nil)
+	manager := NewManager(nil, nil, nil, nil, nil, nil)
 	req := CreateRoomRequest{
 		GameKey:    "RPS_CLASH",
 		Visibility: roomVisibilityPublic,
@@ -34,7 +34,7 @@ func TestCreateRoomAllowsMultipleRoomsForSameGame(t *testing.T) {
 }
 
 func TestCreateRoomStartsHostUnready(t *testing.T) {
-	manager := NewManager(nil, nil, nil, nil)
+	manager := NewManager(nil, nil, nil, nil, nil, nil)
 	room, err := manager.CreateRoom(context.Background(), "host", CreateRoomRequest{
 		GameKey:    "RPS_CLASH",
 		Visibility: roomVisibilityPublic,
@@ -54,7 +54,7 @@ func TestCreateRoomStartsHostUnready(t *testing.T) {
 }
 
 func TestCreateRoomMovesCreatorToFreshRoom(t *testing.T) {
-	manager := NewManager(nil, nil, nil, nil)
+	manager := NewManager(nil, nil, nil, nil, nil, nil)
 	req := CreateRoomRequest{
 		GameKey:    "RPS_CLASH",
 		Visibility: roomVisibilityPublic,
@@ -93,7 +93,7 @@ func TestCreateRoomMovesCreatorToFreshRoom(t *testing.T) {
 }
 
 func TestJoinRoomMovesUserBetweenWaitingRooms(t *testing.T) {
-	manager := NewManager(nil, nil, nil, nil)
+	manager := NewManager(nil, nil, nil, nil, nil, nil)
 	req := CreateRoomRequest{
 		GameKey:    "RPS_CLASH",
 		Visibility: roomVisibilityPublic,
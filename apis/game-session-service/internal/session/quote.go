@@ -0,0 +1,117 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"gamehub/game-session-service/internal/trader"
+)
+
+var (
+	ErrQuoteExpired  = errors.New("quote expired or already used")
+	ErrQuoteMismatch = errors.New("bet does not match quote")
+)
+
+type GetQuoteRequest struct {
+	GameType   string                 `json:"gameType"`
+	StakeUsd   float64                `json:"stakeUsd"`
+	Prediction map[string]interface{} `json:"prediction"`
+	TraceID    string                 `json:"traceId,omitempty"`
+}
+
+// GetQuote asks trader-pool to price a bet without placing it. The returned
+// QuoteID can be passed to PLACE_BET until the quote expires.
+func (m *Manager) GetQuote(ctx context.Context, userID string, req GetQuoteRequest) (*trader.Quote, error) {
+	if req.StakeUsd <= 0 {
+		return nil, ErrInvalidStake
+	}
+	if req.GameType == "" {
+		req.GameType = "NEON_PERIMETER"
+	}
+	return m.trader.Quote(ctx, trader.QuoteRequest{
+		UserID:     userID,
+		GameType:   req.GameType,
+		StakeUsd:   req.StakeUsd,
+		Prediction: req.Prediction,
+		TraceID:    req.TraceID,
+	})
+}
+
+// peekQuote reads a quote without using it up, and checks it was issued to
+// userID for the same stake and game. raw is what claimQuote compares
+// against.
+func (m *Manager) peekQuote(ctx context.Context, userID string, req PlaceBetRequest) (quote *trader.Quote, raw string, err error) {
+	raw, err = m.rdb.Get(ctx, m.quoteKey(req.QuoteID)).Result()
+	if err == redis.Nil {
+		return nil, "", ErrQuoteExpired
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	quote = &trader.Quote{}
+	if err := json.Unmarshal([]byte(raw), quote); err != nil {
+		return nil, "", err
+	}
+	if err := matchQuote(quote, userID, req); err != nil {
+		return nil, "", err
+	}
+	return quote, raw, nil
+}
+
+// claimQuoteScript deletes a quote only if it is still the one peeked at,
+// returning its remaining lifetime in ms, or -2 when another bet took it.
+var claimQuoteScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) ~= ARGV[1] then
+  return -2
+end
+local ttl = redis.call('PTTL', KEYS[1])
+redis.call('DEL', KEYS[1])
+return ttl
+`)
+
+// claimQuote uses up a quote peeked as raw so it backs at most one bet. It
+// returns the quote's remaining lifetime for restoreQuote.
+func (m *Manager) claimQuote(ctx context.Context, quoteID, raw string) (time.Duration, error) {
+	ttl, err := claimQuoteScript.Run(ctx, m.rdb, []string{m.quoteKey(quoteID)}, raw).Int64()
+	if err != nil {
+		return 0, err
+	}
+	if ttl == -2 {
+		return 0, ErrQuoteExpired
+	}
+	return time.Duration(ttl) * time.Millisecond, nil
+}
+
+// restoreQuote puts back a quote claimed by a bet that failed before any
+// money moved, so the user can retry with it until it expires.
+func (m *Manager) restoreQuote(ctx context.Context, quoteID, raw string, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	if err := m.rdb.SetNX(ctx, m.quoteKey(quoteID), raw, ttl).Err(); err != nil {
+		log.Printf("⚠️  restore quote %s: %v", quoteID, err)
+	}
+}
+
+func (m *Manager) quoteKey(quoteID string) string {
+	return m.cfg.QuotePrefix + ":" + quoteID
+}
+
+func matchQuote(quote *trader.Quote, userID string, req PlaceBetRequest) error {
+	if quote.UserID != userID {
+		return ErrQuoteExpired
+	}
+	if req.GameType != "" && req.GameType != quote.GameType {
+		return ErrQuoteMismatch
+	}
+	if math.Abs(req.StakeUsd-quote.StakeUsd) > 0.005 {
+		return ErrQuoteMismatch
+	}
+	return nil
+}
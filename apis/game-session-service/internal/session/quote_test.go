@@ -0,0 +1,29 @@
+package session
+
+import (
+	"testing"
+
+	"gamehub/game-session-service/internal/trader"
+)
+
+func TestMatchQuote(t *testing.T) {
+	quote := &trader.Quote{QuoteID: "q-1", UserID: "user-1", GameType: "DIGIT_DASH", StakeUsd: 10}
+
+	cases := []struct {
+		name   string
+		userID string
+		req    PlaceBetRequest
+		want   error
+	}{
+		{"match", "user-1", PlaceBetRequest{StakeUsd: 10, GameType: "DIGIT_DASH"}, nil},
+		{"game type from quote", "user-1", PlaceBetRequest{StakeUsd: 10}, nil},
+		{"other user", "user-2", PlaceBetRequest{StakeUsd: 10}, ErrQuoteExpired},
+		{"stake changed", "user-1", PlaceBetRequest{StakeUsd: 12}, ErrQuoteMismatch},
+		{"game changed", "user-1", PlaceBetRequest{StakeUsd: 10, GameType: "NEON_PERIMETER"}, ErrQuoteMismatch},
+	}
+	for _, tc := range cases {
+		if got := matchQuote(quote, tc.userID, tc.req); got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+)
+
+var (
+	ErrUnknownSymbol = errors.New("unknown tick symbol")
+)
+
+type SubscribeTicksRequest struct {
+	Symbol string `json:"symbol"`
+	Count  int    `json:"count,omitempty"`
+}
+
+type TickHistoryPayload struct {
+	Symbol string            `json:"symbol"`
+	Ticks  []json.RawMessage `json:"ticks"`
+}
+
+// SubscribeTicks registers events, a connection's channel from Subscribe,
+// for live TICK messages on a symbol and returns the recent history
+// (oldest first) kept by the trader-pool feed.
+func (m *Manager) SubscribeTicks(ctx context.Context, events chan []byte, req SubscribeTicksRequest) (*TickHistoryPayload, error) {
+	symbol := strings.TrimSpace(req.Symbol)
+	if symbol == "" && len(m.cfg.TickSymbols) > 0 {
+		symbol = m.cfg.TickSymbols[0]
+	}
+	if !m.tickSymbolAllowed(symbol) {
+		return nil, ErrUnknownSymbol
+	}
+	count := req.Count
+	if count <= 0 || count > m.cfg.TickHistorySize {
+		count = m.cfg.TickHistorySize
+	}
+
+	raw, err := m.rdb.LRange(ctx, m.cfg.TickChannelPrefix+":"+symbol+":history", 0, int64(count-1)).Result()
+	if err != nil {
+		return nil, err
+	}
+	history := make([]json.RawMessage, 0, len(raw))
+	for i := len(raw) - 1; i >= 0; i-- {
+		history = append(history, json.RawMessage(raw[i]))
+	}
+
+	m.tickMu.Lock()
+	if _, ok := m.tickSubs[symbol]; !ok {
+		m.tickSubs[symbol] = make(map[chan []byte]struct{})
+	}
+	m.tickSubs[symbol][events] = struct{}{}
+	m.tickMu.Unlock()
+
+	return &TickHistoryPayload{Symbol: symbol, Ticks: history}, nil
+}
+
+// UnsubscribeTicks stops TICK messages to events for symbol, or for every
+// symbol when symbol is empty. The user's other connections keep theirs.
+func (m *Manager) UnsubscribeTicks(events chan []byte, symbol string) {
+	m.tickMu.Lock()
+	defer m.tickMu.Unlock()
+	for sym, subs := range m.tickSubs {
+		if symbol != "" && sym != symbol {
+			continue
+		}
+		delete(subs, events)
+		if len(subs) == 0 {
+			delete(m.tickSubs, sym)
+		}
+	}
+}
+
+// RelayTicks forwards ticks published by the trader-pool feed to every
+// connection subscribed to the symbol.
+func (m *Manager) RelayTicks(ctx context.Context) {
+	prefix := m.cfg.TickChannelPrefix + ":"
+	pubsub := m.rdb.PSubscribe(ctx, prefix+"*")
+	defer pubsub.Close()
+
+	for {
+		msg, err := pubsub.ReceiveMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+		symbol := strings.TrimPrefix(msg.Channel, prefix)
+		data, err := json.Marshal(wsMessage("TICK", json.RawMessage(msg.Payload)))
+		if err != nil {
+			log.Printf("[ticks][%s] invalid tick payload: %v", symbol, err)
+			continue
+		}
+		m.fanOutTick(symbol, data)
+	}
+}
+
+// fanOutTick sends data to the connections subscribed to symbol. It holds
+// tickMu throughout: Subscribe's unsubscribe drops a channel's ticks
+// before closing it.
+func (m *Manager) fanOutTick(symbol string, data []byte) {
+	m.tickMu.RLock()
+	defer m.tickMu.RUnlock()
+	for ch := range m.tickSubs[symbol] {
+		select {
+		case ch <- data:
+		default:
+		}
+	}
+}
+
+func (m *Manager) tickSymbolAllowed(symbol string) bool {
+	for _, allowed := range m.cfg.TickSymbols {
+		if allowed == symbol {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package session
+
+import (
+	"context"
+	"testing"
+
+	"gamehub/game-session-service/internal/config"
+)
+
+func TestSubscribeTicksRejectsUnknownSymbol(t *testing.T) {
+	manager := NewManager(nil, nil, nil, nil, nil, &config.Config{
+		TickSymbols:       []string{"R_50"},
+		TickChannelPrefix: "ticks",
+		TickHistorySize:   300,
+	})
+	if _, err := manager.SubscribeTicks(context.Background(), make(chan []byte, 1), SubscribeTicksRequest{Symbol: "FRXEURUSD"}); err != ErrUnknownSymbol {
+		t.Fatalf("expected ErrUnknownSymbol, got %v", err)
+	}
+}
+
+func TestFanOutTickReachesOnlySubscribers(t *testing.T) {
+	manager := NewManager(nil, nil, nil, nil, nil, &config.Config{TickSymbols: []string{"R_50", "R_100"}})
+	subscribed, unsubscribeA := manager.Subscribe("alice")
+	defer unsubscribeA()
+	other, unsubscribeB := manager.Subscribe("bob")
+	defer unsubscribeB()
+
+	manager.tickSubs["R_50"] = map[chan []byte]struct{}{subscribed: {}}
+	manager.tickSubs["R_100"] = map[chan []byte]struct{}{other: {}}
+
+	manager.fanOutTick("R_50", []byte(`{"type":"TICK"}`))
+	select {
+	case msg := <-subscribed:
+		if string(msg) != `{"type":"TICK"}` {
+			t.Fatalf("unexpected message %s", msg)
+		}
+	default:
+		t.Fatal("expected subscriber to receive the tick")
+	}
+	select {
+	case msg := <-other:
+		t.Fatalf("unexpected tick for non-subscriber: %s", msg)
+	default:
+	}
+
+	manager.UnsubscribeTicks(subscribed, "")
+	manager.fanOutTick("R_50", []byte(`{"type":"TICK"}`))
+	select {
+	case msg := <-subscribed:
+		t.Fatalf("unexpected tick after unsubscribe: %s", msg)
+	default:
+	}
+	if _, ok := manager.tickSubs["R_100"][other]; !ok {
+		t.Fatal("expected other subscriptions to survive")
+	}
+}
+
+func TestTickSubscriptionsArePerConnection(t *testing.T) {
+	manager := NewManager(nil, nil, nil, nil, nil, &config.Config{TickSymbols: []string{"R_50"}})
+	desktop, closeDesktop := manager.Subscribe("alice")
+	phone, closePhone := manager.Subscribe("alice")
+	defer closePhone()
+
+	manager.tickSubs["R_50"] = map[chan []byte]struct{}{desktop: {}}
+	manager.fanOutTick("R_50", []byte(`{"type":"TICK"}`))
+	select {
+	case <-desktop:
+	default:
+		t.Fatal("expected the subscribed connection to receive the tick")
+	}
+	select {
+	case msg := <-phone:
+		t.Fatalf("unexpected tick on the user's other connection: %s", msg)
+	default:
+	}
+
+	manager.tickSubs["R_50"][phone] = struct{}{}
+	closeDesktop()
+	if _, ok := manager.tickSubs["R_50"][desktop]; ok {
+		t.Fatal("expected the closed connection to be unsubscribed")
+	}
+	manager.fanOutTick("R_50", []byte(`{"type":"TICK"}`))
+	select {
+	case <-phone:
+	default:
+		t.Fatal("expected the open connection to keep its subscription")
+	}
+}
@@ -0,0 +1,99 @@
+package trader
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+type Client struct {
+	baseURL     string
+	internalKey string
+	httpClient  *http.Client
+}
+
+func NewHTTPClient(baseURL string, internalKey string) *Client {
+	return &Client{
+		baseURL:     baseURL,
+		internalKey: internalKey,
+		httpClient:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type QuoteRequest struct {
+	UserID     string                 `json:"userId"`
+	GameType   string                 `json:"gameType"`
+	StakeUsd   float64                `json:"stakeUsd"`
+	Prediction map[string]interface{} `json:"prediction"`
+	TraceID    string                 `json:"traceId,omitempty"`
+}
+
+// Quote mirrors pool.Quote in trader-pool.
+type Quote struct {
+	QuoteID      string                 `json:"quoteId"`
+	UserID       string                 `json:"userId"`
+	GameType     string                 `json:"gameType"`
+	StakeUsd     float64                `json:"stakeUsd"`
+	Prediction   map[string]interface{} `json:"prediction,omitempty"`
+	Provider     string                 `json:"provider"`
+	ContractType string                 `json:"contractType,omitempty"`
+	Symbol       string                 `json:"symbol,omitempty"`
+	AskPrice     float64                `json:"askPrice"`
+	PayoutUsd    float64                `json:"payoutUsd"`
+	Spot         float64                `json:"spot,omitempty"`
+	Barrier      string                 `json:"barrier,omitempty"`
+	Barrier2     string                 `json:"barrier2,omitempty"`
+	Longcode     string                 `json:"longcode,omitempty"`
+	ExpiresAt    int64                  `json:"expiresAt"`
+}
+
+func (c *Client) Quote(ctx context.Context, req QuoteRequest) (*Quote, error) {
+	var quote Quote
+	err := c.post(ctx, "/internal/quotes", req, &quote)
+	return &quote, err
+}
+
+func (c *Client) post(ctx context.Context, path string, payload interface{}, out interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.internalKey != "" {
+		req.Header.Set("X-Internal-Key", c.internalKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		if out == nil {
+			io.Copy(io.Discard, resp.Body)
+			return nil
+		}
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	respBody, _ := io.ReadAll(resp.Body)
+	// Client errors carry a user-facing reason; pass it through unwrapped.
+	if resp.StatusCode < 500 {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(respBody, &apiErr) == nil && apiErr.Error != "" {
+			return errors.New(apiErr.Error)
+		}
+	}
+	return fmt.Errorf("trader pool %s: %s", resp.Status, string(respBody))
+}
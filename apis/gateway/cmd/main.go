@@ -46,6 +46,7 @@ func main() {
 
 	routeTable := []route{
 		{prefix: "/api/v1/auth/", upstream: upstreams[0]},
+		{prefix: "/.well-known/jwks.json", upstream: upstreams[0]},
 		{prefix: "/api/v1/games/", upstream: upstreams[1]},
 		{prefix: "/ws/payments", upstream: upstreams[2]},
 		{prefix: "/api/v1/payments/", upstream: upstreams[2]},
@@ -237,7 +238,7 @@ func corsMiddleware(next http.Handler, policy corsPolicy) http.Handler {
 			appendVaryHeader(w, "Access-Control-Request-Headers")
 			w.Header().Set("Access-Control-Allow-Origin", allowedOrigin)
 			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Internal-Key")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Internal-Key, X-Device-Name, X-Device-Platform")
 			w.Header().Set("Access-Control-Max-Age", "600")
 		}
 
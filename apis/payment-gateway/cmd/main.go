@@ -2,10 +2,12 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -18,13 +20,17 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 
-	"gamehub/payment-gateway/internal/auth"
 	"gamehub/payment-gateway/internal/config"
+	"gamehub/payment-gateway/internal/derivation"
 	"gamehub/payment-gateway/internal/flutterwave"
 	"gamehub/payment-gateway/internal/handler"
+	"gamehub/payment-gateway/internal/limits"
 	"gamehub/payment-gateway/internal/middleware"
+	"gamehub/payment-gateway/internal/secrets"
 	"gamehub/payment-gateway/internal/tatum"
 	"gamehub/payment-gateway/internal/wallet"
+	"gamehub/pkg/authn"
+	"gamehub/pkg/authn/rbac"
 )
 
 func main() {
@@ -32,7 +38,7 @@ func main() {
 
 	cfg := config.Load()
 
-	tokenValidator, err := auth.NewValidator(cfg.JWTPublicKeyPath, cfg.JWTIssuer)
+	tokenValidator, err := authn.NewValidator(cfg.JWTPublicKeyPath, cfg.JWKSURL, cfg.JWTIssuer)
 	if err != nil {
 		log.Fatalf("JWT validator init failed: %v", err)
 	}
@@ -54,23 +60,54 @@ func main() {
 	db.Collection("withdrawals").Indexes().CreateMany(context.Background(), []mongo.IndexModel{
 		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "status", Value: 1}}},
 		{Keys: bson.D{{Key: "hubtelRef", Value: 1}}, Options: options.Index().SetSparse(true)},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "createdAt", Value: 1}}},
+	})
+	// Wallet command outbox: the relay polls for due pending messages
+	db.Collection("outbox").Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys: bson.D{{Key: "status", Value: 1}, {Key: "nextAttemptAt", Value: 1}},
+	})
+	db.Collection("withdrawal_audit").Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys: bson.D{{Key: "withdrawalId", Value: 1}, {Key: "createdAt", Value: 1}},
 	})
 
 	// Crypto deposit tracking indexes
 	db.Collection("crypto_deposits").Indexes().CreateMany(context.Background(), []mongo.IndexModel{
 		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: -1}}},
 		{Keys: bson.D{{Key: "status", Value: 1}}},
+		{Keys: bson.D{{Key: "chain", Value: 1}, {Key: "status", Value: 1}, {Key: "blockNumber", Value: 1}}},
 	})
 	db.Collection("crypto_wallets").Indexes().CreateMany(context.Background(), []mongo.IndexModel{
 		{Keys: bson.D{{Key: "address", Value: 1}}, Options: options.Index().SetUnique(true)},
-		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "coin", Value: 1}}, Options: options.Index().SetSparse(true)},
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "coin", Value: 1}, {Key: "network", Value: 1}}, Options: options.Index().SetSparse(true)},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "lastActivityAt", Value: -1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "subscriptionId", Value: 1}}},
+	})
+	// HD derivation index registry: an index is never handed out twice
+	if err := derivation.NewRegistry(db).EnsureIndexes(context.Background()); err != nil {
+		log.Printf("⚠️ derivation_indexes indexes: %v", err)
+	}
+	// Deposit sweeps: one active sweep per address; deposit detection
+	// looks transactions up by hash to skip our own
+	db.Collection("sweeps").Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "fromAddress", Value: 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"active": true}),
+		},
+		{Keys: bson.D{{Key: "txId", Value: 1}}, Options: options.Index().SetSparse(true)},
+		{Keys: bson.D{{Key: "topUpTxId", Value: 1}}, Options: options.Index().SetSparse(true)},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "createdAt", Value: -1}}},
 	})
 
-	// --- Redis (idempotency fast-path + webhook dedup) ---
+	// --- Redis (idempotency fast-path + webhook dedup + limit counters) ---
 	rdb := redis.NewClient(&redis.Options{
 		Addr:     cfg.RedisAddr,
 		Password: cfg.RedisPassword,
 	})
+	// Limit counters: Mongo copy of the Redis totals, dropped after their period
+	if err := limits.NewStore(rdb, db.Collection("limit_counters")).EnsureIndexes(context.Background()); err != nil {
+		log.Printf("⚠️ limit_counters indexes: %v", err)
+	}
 
 	// --- External Clients ---
 	// Flutterwave: Mobile Money deposits + withdrawals
@@ -90,16 +127,58 @@ func main() {
 		cfg.TatumTestnet,
 	)
 
-	// Wallet service client: called directly over HTTP after payment confirms
+	// Wallet service client: credits and releases go through the outbox relay
 	walletClient := wallet.NewHTTPClient(cfg.WalletServiceURL, cfg.InternalServiceKey)
 
+	// --- Secrets: master keys sealing stored mnemonics ---
+	// Development creates a key file on first run; production must mount
+	// one (see cmd/secretsctl).
+	var kms secrets.KMS
+	keyFile, err := secrets.OpenKeyFile(cfg.SecretsKeyFile)
+	if errors.Is(err, os.ErrNotExist) && !strings.EqualFold(cfg.AppEnv, "production") {
+		log.Printf("⚠️ %s not found; creating a development key file", cfg.SecretsKeyFile)
+		keyFile, err = secrets.CreateKeyFile(cfg.SecretsKeyFile)
+	}
+	if err != nil {
+		log.Printf("⚠️ secret storage unavailable, mnemonics can't be stored or used: %v", err)
+	} else {
+		kms = keyFile
+	}
+
 	// --- Handler ---
-	h := handler.New(db, rdb, flutterwaveClient, tatumClient, walletClient, cfg)
+	h := handler.New(db, rdb, flutterwaveClient, tatumClient, walletClient, kms, cfg)
 
 	// --- Background: Poll Flutterwave for pending payment statuses ---
 	// Webhooks can occasionally be delayed — this ensures we don't miss confirmations
 	go h.RunMoMoStatusPoller(context.Background())
 
+	// --- Crypto assets: built-ins plus crypto_assets, reloaded every minute ---
+	if err := h.LoadCryptoAssets(context.Background()); err != nil {
+		log.Printf("⚠️ crypto assets not loaded, using built-ins: %v", err)
+	}
+	go h.RunCryptoAssetReloader(context.Background())
+
+	// --- Crypto deposits: confirm from chain heads, backfill missed webhooks ---
+	go h.RunChainHeadWatcher(context.Background())
+	go h.RunCryptoDepositWatcher(context.Background())
+
+	// --- Background: Deliver queued wallet credits and releases ---
+	go h.RunOutboxRelay(context.Background())
+
+	// --- Crypto payouts: hot-wallet keys, then settle broadcast payouts ---
+	if err := h.LoadPayoutKeys(context.Background()); err != nil {
+		log.Printf("⚠️ payout keys not loaded: %v", err)
+	}
+	go h.RunCryptoPayoutWatcher(context.Background())
+
+	// --- Background: Consolidate deposit addresses into treasury ---
+	if cfg.CryptoSweepEnabled {
+		go h.RunDepositSweeper(context.Background())
+	}
+
+	// --- Background: Cancel withdrawals stuck past the review SLA ---
+	go h.RunWithdrawalSLASweeper(context.Background())
+
 	// --- Fiber App ---
 	app := fiber.New(fiber.Config{
 		AppName:      "Glory Grid Payment Gateway",
@@ -117,7 +196,13 @@ func main() {
 	// ==========================================================================
 	// PUBLIC ROUTES (require user JWT)
 	// ==========================================================================
-	v1 := app.Group("/api/v1/payments", middleware.RequireAuth(tokenValidator))
+	v1 := app.Group("/api/v1/payments", authn.RequireAuth(tokenValidator))
+
+	// Withdrawals and payout-destination changes need a 2FA step-up token.
+	stepUp := func(c *fiber.Ctx) error { return c.Next() }
+	if cfg.RequireStepUp {
+		stepUp = authn.RequireElevated()
+	}
 
 	// --- Mobile Money (Flutterwave) ---
 	// Initiate a deposit: triggers Flutterwave charge → MoMo prompt on phone
@@ -125,12 +210,14 @@ func main() {
 	// Check status of a specific payment by client reference
 	v1.Get("/momo/status/:reference", h.GetMoMoStatus)
 	// Initiate a withdrawal to mobile money
-	v1.Post("/momo/withdraw", h.InitiateMoMoWithdrawal)
+	v1.Post("/momo/withdraw", stepUp, h.InitiateMoMoWithdrawal)
 
 	// --- Crypto ---
-	// Initiate a withdrawal to a user's crypto wallet (MANUAL processing)
-	v1.Post("/crypto/withdraw", h.InitiateCryptoWithdrawal)
-	// Generate a deposit address for a given coin (BTC, ETH, USDT)
+	// Initiate a withdrawal to a user's crypto wallet (paid from the hot wallet once approved)
+	v1.Post("/crypto/withdraw", stepUp, h.InitiateCryptoWithdrawal)
+	// Coins and networks accepted for deposit
+	v1.Get("/crypto/assets", h.ListCryptoAssets)
+	// Generate a deposit address for a coin on one of its networks
 	v1.Post("/crypto/address", h.GenerateCryptoAddress)
 	// Check status of a crypto deposit by tx hash
 	v1.Get("/crypto/status/:txHash", h.GetCryptoDepositStatus)
@@ -140,6 +227,22 @@ func main() {
 	// --- General History ---
 	v1.Get("/history", h.GetPaymentHistory)
 	v1.Get("/withdrawals", h.GetWithdrawals)
+	// Single-transaction caps for the caller's KYC level
+	v1.Get("/limits", h.GetLimits)
+
+	// --- Staff views (scope-checked) ---
+	admin := v1.Group("/admin", authn.RequireScope(rbac.ScopePaymentsRead))
+	admin.Get("/withdrawals/pending/:userId", h.GetPendingWithdrawals)
+	admin.Get("/outbox", h.GetOutboxStats)
+	admin.Get("/sweeps", h.ListSweeps)
+	// Withdrawal review queue; decisions need withdrawals:review
+	reviewer := authn.RequireScope(rbac.ScopeWithdrawalsReview)
+	admin.Get("/withdrawals", h.ListWithdrawalQueue)
+	admin.Get("/withdrawals/:id", h.GetWithdrawalReview)
+	admin.Post("/withdrawals/:id/approve", reviewer, h.ApproveWithdrawal)
+	admin.Post("/withdrawals/:id/reject", reviewer, h.RejectWithdrawal)
+	admin.Post("/withdrawals/:id/mark-paid", reviewer, h.MarkWithdrawalPaid)
+	admin.Post("/withdrawals/:id/abandon-payout", reviewer, h.AbandonWithdrawalPayout)
 
 	// ==========================================================================
 	// WEBHOOK ROUTES (provider → our server; no user JWT, HMAC-verified)
@@ -159,31 +262,47 @@ func main() {
 		h.FlutterwaveWithdrawalCallback,
 	)
 
-	// Tatum fires this when a crypto tx reaches required confirmations
+	// Tatum fires this when a crypto tx reaches a deposit address
 	webhooks.Post("/crypto",
 		middleware.VerifyTatumHMAC(cfg),
 		h.CryptoDepositCallback,
 	)
+	// New blocks advance the confirmations of open deposits
+	webhooks.Post("/crypto/block",
+		middleware.VerifyTatumHMAC(cfg),
+		h.CryptoBlockCallback,
+	)
 
 	// ==========================================================================
 	// INTERNAL ROUTES (called by other services inside the Docker network)
 	// ==========================================================================
-	internal := app.Group("/internal", middleware.RequireInternalKey(cfg))
+	internal := app.Group("/internal", authn.RequireInternalKey(cfg.InternalServiceKey))
 
 	// Wallet service calls this after a game win to check pending withdrawals
 	internal.Get("/withdrawals/pending/:userId", h.GetPendingWithdrawals)
 
+	// Wallet command outbox: backlog metrics and dead-message requeue
+	internal.Get("/outbox/stats", h.GetOutboxStats)
+	internal.Post("/outbox/:id/requeue", h.RequeueOutboxMessage)
+
 	// Crypto master wallet config (store/retrieve xpubs + mnemonics)
 	internal.Post("/crypto/wallets/config", h.SaveCryptoWalletConfig)
 	internal.Get("/crypto/wallets/config", h.GetCryptoWalletConfig)
 
+	// Crypto asset registry: add a token or chain without a deploy
+	internal.Get("/crypto/assets", h.ListAllCryptoAssets)
+	internal.Put("/crypto/assets", h.SaveCryptoAsset)
+
 	// JIT Wallet Generation for new users (called by auth-service)
 	internal.Post("/crypto/wallets/generate-all", h.GenerateAllCryptoWallets)
 
+	// Deposit sweeps: run a pass now (ops / cron)
+	internal.Post("/crypto/sweeps/run", h.RunDepositSweeps)
+
 	// ==========================================================================
 	// WEBSOCKET — real-time payment status updates to connected Flutter clients
 	// ==========================================================================
-	app.Use("/ws/payments", middleware.UpgradeWS(tokenValidator))
+	app.Use("/ws/payments", authn.UpgradeWS(tokenValidator))
 	app.Get("/ws/payments", websocket.New(h.PaymentStatusWebSocket))
 	// Client subscribes to their userId channel; receives push when payment settles
 
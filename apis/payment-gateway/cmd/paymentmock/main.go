@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"gamehub/payment-gateway/internal/flutterwavemock"
+	"gamehub/payment-gateway/internal/tatummock"
+)
+
+// Standalone Flutterwave and Tatum stand-ins for local development. Point
+// payment-gateway at them with FLUTTERWAVE_BASE_URL=http://127.0.0.1:8096,
+// TATUM_BASE_URL=http://127.0.0.1:8097 and any non-empty
+// FLUTTERWAVE_SECRET_KEY / TATUM_API_KEY and xpubs.
+func main() {
+	log.SetOutput(os.Stdout)
+
+	flwAddr := flag.String("flutterwave-addr", envOr("FLUTTERWAVE_MOCK_ADDR", ":8096"), "Flutterwave listen address")
+	tatumAddr := flag.String("tatum-addr", envOr("TATUM_MOCK_ADDR", ":8097"), "Tatum listen address")
+	gatewayURL := flag.String("gateway-url", envOr("PAYMENT_GATEWAY_URL", "http://127.0.0.1:8003"), "payment-gateway base URL for Flutterwave webhooks")
+	flwSecret := flag.String("flutterwave-webhook-secret", os.Getenv("FLUTTERWAVE_WEBHOOK_SECRET"), "verif-hash sent with Flutterwave webhooks")
+	tatumSecret := flag.String("tatum-webhook-secret", os.Getenv("TATUM_WEBHOOK_SECRET"), "HMAC key for X-Tatum-Signature")
+	settleAfter := flag.Duration("settle-after", 3*time.Second, "settle charges and transfers after this delay (0 waits for /_mock/ triggers)")
+	outcome := flag.String("outcome", "successful", "status automatic settlements end in: successful or failed")
+	blockInterval := flag.Duration("block-interval", 5*time.Second, "mine a Tatum block this often (0 mines only on /_mock/blocks)")
+	confirmations := flag.Int("target-confirmations", 12, "stop confirmation webhooks once a transaction has this many")
+	blockWebhooks := flag.Bool("block-webhooks", true, "notify payment-gateway of every mined Tatum block")
+	trc20Contract := flag.String("trc20-contract", os.Getenv("CRYPTO_USDT_TRC20_CONTRACT"), "contract reported for TRON deposits (mainnet USDT when empty)")
+	flag.Parse()
+
+	base := strings.TrimRight(*gatewayURL, "/")
+	flw := flutterwavemock.New(flutterwavemock.Config{
+		WebhookSecret:      *flwSecret,
+		ChargeWebhookURL:   base + "/webhooks/payment/flutterwave",
+		TransferWebhookURL: base + "/webhooks/payment/flutterwave/withdrawal",
+		SettleAfter:        *settleAfter,
+		Outcome:            *outcome,
+	})
+	blockWebhookURL := ""
+	if *blockWebhooks {
+		blockWebhookURL = base + "/webhooks/payment/crypto/block"
+	}
+	chain := tatummock.New(tatummock.Config{
+		WebhookSecret:       *tatumSecret,
+		BlockInterval:       *blockInterval,
+		TargetConfirmations: *confirmations,
+		TRC20Contract:       *trc20Contract,
+		BlockWebhookURL:     blockWebhookURL,
+	})
+	defer chain.Close()
+
+	go func() {
+		log.Printf("Tatum mock on %s (POST /_mock/deposits, /_mock/blocks, /_mock/reorg, /_mock/faults to script)", *tatumAddr)
+		if err := http.ListenAndServe(*tatumAddr, chain); err != nil {
+			log.Fatalf("Tatum mock error: %v", err)
+		}
+	}()
+	log.Printf("Flutterwave mock on %s (POST /_mock/charges/complete, /_mock/transfers/complete, /_mock/faults to script)", *flwAddr)
+	if err := http.ListenAndServe(*flwAddr, flw); err != nil {
+		log.Fatalf("Flutterwave mock error: %v", err)
+	}
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"gamehub/payment-gateway/internal/secrets"
+)
+
+// Manages the master keys sealing stored secrets.
+//
+//	secretsctl keygen              add a master key version (creating the file) and make it current
+//	secretsctl rotate [-dry-run]   rewrap every sealed value under the current key and seal plaintext leftovers
+//
+// Rotation never decrypts the secrets themselves. Restart payment-gateway
+// after keygen so it loads the new key, and keep old versions in the file
+// until rotate reports nothing left to rewrap.
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		usage()
+	}
+	cmd, args := os.Args[1], os.Args[2:]
+
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	keyPath := fs.String("key-file", envOr("SECRETS_KEY_FILE", "secrets.key"), "master key file")
+	mongoURI := fs.String("mongo-uri", os.Getenv("MONGO_URI"), "MongoDB URI (rotate)")
+	dryRun := fs.Bool("dry-run", false, "count what rotate would change without writing")
+	fs.Parse(args)
+
+	switch cmd {
+	case "keygen":
+		keygen(*keyPath)
+	case "rotate":
+		rotate(*keyPath, *mongoURI, *dryRun)
+	default:
+		usage()
+	}
+}
+
+func keygen(path string) {
+	keyFile, err := secrets.OpenKeyFile(path)
+	if os.IsNotExist(err) {
+		if _, err := secrets.CreateKeyFile(path); err != nil {
+			log.Fatalf("create %s: %v", path, err)
+		}
+		fmt.Printf("created %s with key v1\n", path)
+		return
+	}
+	if err != nil {
+		log.Fatalf("open %s: %v", path, err)
+	}
+	id, err := keyFile.AddKey()
+	if err != nil {
+		log.Fatalf("add key: %v", err)
+	}
+	fmt.Printf("added key %s to %s; run rotate to move existing secrets to it\n", id, path)
+}
+
+func rotate(path, mongoURI string, dryRun bool) {
+	if mongoURI == "" {
+		log.Fatal("MONGO_URI or -mongo-uri is required")
+	}
+	keyFile, err := secrets.OpenKeyFile(path)
+	if err != nil {
+		log.Fatalf("open %s: %v", path, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		log.Fatalf("MongoDB connect error: %v", err)
+	}
+	defer client.Disconnect(context.Background())
+	db := client.Database("gamehub")
+
+	for _, field := range secrets.Fields {
+		result, err := secrets.Rotate(ctx, db, field, keyFile, dryRun)
+		if err != nil {
+			log.Fatalf("%s.%s: %v", field.Collection, field.Name, err)
+		}
+		fmt.Printf("%s.%s: rewrapped %d, sealed %d plaintext (current key %s)\n",
+			field.Collection, field.Name, result.Rewrapped, result.Sealed, keyFile.CurrentKey())
+	}
+	if dryRun {
+		fmt.Println("dry run: nothing written")
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: secretsctl keygen|rotate [-key-file path] [-mongo-uri uri] [-dry-run]")
+	os.Exit(2)
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
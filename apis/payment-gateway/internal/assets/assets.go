@@ -0,0 +1,323 @@
+// Package assets is the registry of crypto assets accepted for deposit:
+// each coin on each network, with the chain it lives on, its token
+// contract, decimals, the confirmations a deposit needs, the minimum
+// credited and an explorer link. Built-in entries cover what the gateway
+// always supported (BTC, ETH and USDT on TRC-20); documents in the
+// crypto_assets collection add to or override them, so accepting another
+// token or chain is a data change.
+package assets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Chain families. Addresses in a family derive from the same master key;
+// every chain other than bitcoin and tron is EVM.
+const (
+	FamilyBitcoin = "bitcoin"
+	FamilyTron    = "tron"
+	FamilyEVM     = "evm"
+)
+
+// ErrUnknown is returned by Lookup for a coin or network not in the
+// registry.
+var ErrUnknown = errors.New("unsupported crypto asset")
+
+// Asset is a coin on one network.
+type Asset struct {
+	// ID is COIN/NETWORK, e.g. USDC/POLYGON.
+	ID      string `bson:"_id" json:"id"`
+	Coin    string `bson:"coin" json:"coin"`
+	Network string `bson:"network" json:"network"`
+	// Chain is the Tatum chain path: bitcoin, ethereum, tron, bsc,
+	// polygon, ...
+	Chain string `bson:"chain" json:"chain"`
+	// Contract is the token contract; empty for the chain's native coin.
+	Contract      string `bson:"contract,omitempty" json:"contract,omitempty"`
+	Decimals      int    `bson:"decimals" json:"decimals"`
+	Confirmations int    `bson:"confirmations" json:"confirmations"`
+	// Finality is the depth after which a credited deposit is no longer
+	// watched for reorgs. Defaults to Confirmations.
+	Finality int `bson:"finality" json:"finality"`
+	// MinDeposit is the smallest deposit credited, in whole coins.
+	MinDeposit float64 `bson:"minDeposit" json:"minDeposit"`
+	// ExplorerURL links to a transaction, with {tx} standing for its hash.
+	ExplorerURL string `bson:"explorerUrl,omitempty" json:"explorerUrl,omitempty"`
+	// Default marks the coin's network when a request names none.
+	Default bool `bson:"default" json:"default"`
+	// Disabled assets get no new deposit addresses; deposits to existing
+	// ones are still credited.
+	Disabled bool `bson:"disabled" json:"disabled"`
+}
+
+// Family returns the chain family of a Tatum chain path.
+func Family(chain string) string {
+	switch strings.ToLower(chain) {
+	case FamilyBitcoin:
+		return FamilyBitcoin
+	case FamilyTron:
+		return FamilyTron
+	default:
+		return FamilyEVM
+	}
+}
+
+// Family returns the asset's chain family.
+func (a Asset) Family() string {
+	return Family(a.Chain)
+}
+
+// Sequence names the derivation index sequence the asset's deposit
+// addresses are drawn from. Assets sharing a master key share a sequence,
+// so no two wallets get the same address; the names are the coins each
+// key served before the registry existed.
+func (a Asset) Sequence() string {
+	switch a.Family() {
+	case FamilyBitcoin:
+		return "BTC"
+	case FamilyTron:
+		return "USDT"
+	default:
+		return "ETH"
+	}
+}
+
+// TxURL returns the explorer link for a transaction hash, or "".
+func (a Asset) TxURL(hash string) string {
+	if a.ExplorerURL == "" || hash == "" {
+		return ""
+	}
+	return strings.ReplaceAll(a.ExplorerURL, "{tx}", hash)
+}
+
+// Validate normalizes the asset's names and checks its fields.
+func (a *Asset) Validate() error {
+	a.Coin = strings.ToUpper(strings.TrimSpace(a.Coin))
+	a.Network = strings.ToUpper(strings.TrimSpace(a.Network))
+	a.Chain = strings.ToLower(strings.TrimSpace(a.Chain))
+	a.Contract = strings.TrimSpace(a.Contract)
+	a.ID = key(a.Coin, a.Network)
+	if a.Finality == 0 {
+		a.Finality = a.Confirmations
+	}
+	switch {
+	case a.Coin == "" || a.Network == "" || a.Chain == "":
+		return errors.New("coin, network and chain are required")
+	case strings.Contains(a.Coin, "/") || strings.Contains(a.Network, "/"):
+		return errors.New("coin and network must not contain /")
+	case a.Decimals < 0 || a.Decimals > 36:
+		return errors.New("decimals must be between 0 and 36")
+	case a.Confirmations < 1:
+		return errors.New("confirmations must be at least 1")
+	case a.Finality < a.Confirmations:
+		return errors.New("finality must not be below confirmations")
+	case a.MinDeposit < 0:
+		return errors.New("minDeposit must not be negative")
+	case a.ExplorerURL != "" && !strings.Contains(a.ExplorerURL, "{tx}"):
+		return errors.New("explorerUrl must contain {tx}")
+	case a.Family() == FamilyBitcoin && a.Contract != "":
+		return errors.New("bitcoin has no tokens")
+	}
+	return nil
+}
+
+func key(coin, network string) string {
+	return coin + "/" + network
+}
+
+// Builtin returns the assets supported before the registry existed. On
+// testnet the explorer links point at test networks and usdtTRC20 should
+// be set, as the mainnet USDT contract is used when it is empty.
+func Builtin(testnet bool, usdtTRC20 string) []Asset {
+	if usdtTRC20 == "" {
+		usdtTRC20 = "TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6t"
+	}
+	btc, eth, tron := "https://mempool.space/tx/{tx}", "https://etherscan.io/tx/{tx}", "https://tronscan.org/#/transaction/{tx}"
+	if testnet {
+		btc, eth, tron = "https://mempool.space/testnet/tx/{tx}", "https://sepolia.etherscan.io/tx/{tx}", "https://nile.tronscan.org/#/transaction/{tx}"
+	}
+	return []Asset{
+		{ID: "BTC/BTC", Coin: "BTC", Network: "BTC", Chain: "bitcoin", Decimals: 8, Confirmations: 3, Finality: 6, ExplorerURL: btc, Default: true},
+		{ID: "ETH/ERC20", Coin: "ETH", Network: "ERC20", Chain: "ethereum", Decimals: 18, Confirmations: 12, Finality: 64, ExplorerURL: eth, Default: true},
+		{ID: "USDT/TRC20", Coin: "USDT", Network: "TRC20", Chain: "tron", Contract: usdtTRC20, Decimals: 6, Confirmations: 1, Finality: 19, ExplorerURL: tron, Default: true},
+	}
+}
+
+// Registry serves assets from memory, refreshed from the crypto_assets
+// collection by Load.
+type Registry struct {
+	coll    *mongo.Collection
+	builtin []Asset
+
+	mu     sync.RWMutex
+	assets map[string]Asset
+}
+
+// NewRegistry returns a registry holding builtin until the first Load.
+func NewRegistry(coll *mongo.Collection, builtin []Asset) *Registry {
+	return &Registry{coll: coll, builtin: builtin, assets: merge(builtin, nil)}
+}
+
+// Load replaces the registry's assets with builtin overlaid by the
+// collection. Invalid documents are logged and skipped.
+func (r *Registry) Load(ctx context.Context) error {
+	cursor, err := r.coll.Find(ctx, bson.M{})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var stored []Asset
+	for cursor.Next(ctx) {
+		var a Asset
+		if err := cursor.Decode(&a); err != nil {
+			log.Printf("[assets] skipping %v: %v", cursor.Current.Lookup("_id"), err)
+			continue
+		}
+		if err := a.Validate(); err != nil {
+			log.Printf("[assets] skipping %s: %v", a.ID, err)
+			continue
+		}
+		stored = append(stored, a)
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+	merged := merge(r.builtin, stored)
+	r.mu.Lock()
+	r.assets = merged
+	r.mu.Unlock()
+	return nil
+}
+
+// Run reloads the registry every interval so other instances pick up
+// saved changes.
+func (r *Registry) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Load(ctx); err != nil {
+				log.Printf("[assets] reload error: %v", err)
+			}
+		}
+	}
+}
+
+// Save validates a and stores it, replacing any asset with the same coin
+// and network. Marking it Default takes the flag from the coin's other
+// networks.
+func (r *Registry) Save(ctx context.Context, a Asset) (Asset, error) {
+	if err := a.Validate(); err != nil {
+		return a, err
+	}
+	if _, err := r.coll.ReplaceOne(ctx, bson.M{"_id": a.ID}, a, options.Replace().SetUpsert(true)); err != nil {
+		return a, err
+	}
+	if a.Default {
+		if _, err := r.coll.UpdateMany(ctx,
+			bson.M{"coin": a.Coin, "_id": bson.M{"$ne": a.ID}},
+			bson.M{"$set": bson.M{"default": false}}); err != nil {
+			return a, err
+		}
+	}
+	return a, r.Load(ctx)
+}
+
+// Lookup returns the asset for coin on network. An empty network means
+// the coin's default, or its only network.
+func (r *Registry) Lookup(coin, network string) (Asset, error) {
+	coin = strings.ToUpper(strings.TrimSpace(coin))
+	network = strings.ToUpper(strings.TrimSpace(network))
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if network != "" {
+		if a, ok := r.assets[key(coin, network)]; ok {
+			return a, nil
+		}
+		return Asset{}, fmt.Errorf("%w: %s on %s", ErrUnknown, coin, network)
+	}
+	var only []Asset
+	for _, a := range r.assets {
+		if a.Coin != coin {
+			continue
+		}
+		if a.Default {
+			return a, nil
+		}
+		if !a.Disabled {
+			only = append(only, a)
+		}
+	}
+	if len(only) == 1 {
+		return only[0], nil
+	}
+	if len(only) > 1 {
+		return Asset{}, fmt.Errorf("%w: %s needs a network", ErrUnknown, coin)
+	}
+	return Asset{}, fmt.Errorf("%w: %s", ErrUnknown, coin)
+}
+
+// List returns every asset, disabled ones included, by ID.
+func (r *Registry) List() []Asset {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return sorted(r.assets, func(Asset) bool { return true })
+}
+
+// Defaults returns each coin's enabled default asset, by ID.
+func (r *Registry) Defaults() []Asset {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return sorted(r.assets, func(a Asset) bool { return a.Default && !a.Disabled })
+}
+
+func sorted(assets map[string]Asset, keep func(Asset) bool) []Asset {
+	out := make([]Asset, 0, len(assets))
+	for _, a := range assets {
+		if keep(a) {
+			out = append(out, a)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// merge overlays stored on builtin. A coin whose stored assets name a
+// default loses its built-in default, so Save can move it.
+func merge(builtin, stored []Asset) map[string]Asset {
+	out := make(map[string]Asset, len(builtin)+len(stored))
+	for _, a := range builtin {
+		out[a.ID] = a
+	}
+	storedDefault := map[string]bool{}
+	for _, a := range stored {
+		out[a.ID] = a
+		if a.Default {
+			storedDefault[a.Coin] = true
+		}
+	}
+	for _, b := range builtin {
+		a := out[b.ID]
+		if storedDefault[a.Coin] && a == b {
+			a.Default = false
+			out[a.ID] = a
+		}
+	}
+	return out
+}
@@ -0,0 +1,109 @@
+package assets
+
+import (
+	"errors"
+	"testing"
+)
+
+func testRegistry(stored ...Asset) *Registry {
+	r := NewRegistry(nil, Builtin(false, ""))
+	for i := range stored {
+		if err := stored[i].Validate(); err != nil {
+			panic(err)
+		}
+	}
+	r.assets = merge(r.builtin, stored)
+	return r
+}
+
+func TestLookupDefaults(t *testing.T) {
+	r := testRegistry()
+	for coin, network := range map[string]string{"BTC": "BTC", "eth": "ERC20", "USDT": "TRC20"} {
+		a, err := r.Lookup(coin, "")
+		if err != nil || a.Network != network {
+			t.Fatalf("%s: %+v %v", coin, a, err)
+		}
+	}
+	if a, _ := r.Lookup("USDT", "trc20"); a.Contract != "TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6t" || a.Confirmations != 1 {
+		t.Fatalf("USDT/TRC20: %+v", a)
+	}
+	if _, err := r.Lookup("DOGE", ""); !errors.Is(err, ErrUnknown) {
+		t.Fatalf("DOGE: %v", err)
+	}
+	if _, err := r.Lookup("USDT", "BEP20"); !errors.Is(err, ErrUnknown) {
+		t.Fatalf("USDT/BEP20: %v", err)
+	}
+}
+
+func TestStoredAssetsExtendAndOverride(t *testing.T) {
+	r := testRegistry(
+		Asset{Coin: "usdc", Network: "polygon", Chain: "Polygon", Contract: "0x3c499c542cEF5E3811e1192ce70d8cC03d5c3359", Decimals: 6, Confirmations: 64, MinDeposit: 5, ExplorerURL: "https://polygonscan.com/tx/{tx}"},
+		Asset{Coin: "USDT", Network: "BEP20", Chain: "bsc", Contract: "0x55d398326f99059fF775485246999027B3197955", Decimals: 18, Confirmations: 15, Default: true},
+		Asset{Coin: "ETH", Network: "ERC20", Chain: "ethereum", Decimals: 18, Confirmations: 20, Default: true},
+	)
+
+	usdc, err := r.Lookup("USDC", "")
+	if err != nil || usdc.ID != "USDC/POLYGON" || usdc.Chain != "polygon" || usdc.Sequence() != "ETH" {
+		t.Fatalf("USDC: %+v %v", usdc, err)
+	}
+	if got := usdc.TxURL("0xabc"); got != "https://polygonscan.com/tx/0xabc" {
+		t.Fatalf("explorer link %q", got)
+	}
+	if usdt, _ := r.Lookup("USDT", ""); usdt.Network != "BEP20" {
+		t.Fatalf("stored default must replace the built-in one, got %+v", usdt)
+	}
+	if trc, _ := r.Lookup("USDT", "TRC20"); trc.Default || trc.Sequence() != "USDT" {
+		t.Fatalf("USDT/TRC20: %+v", trc)
+	}
+	if eth, _ := r.Lookup("ETH", ""); eth.Confirmations != 20 {
+		t.Fatalf("override ignored: %+v", eth)
+	}
+
+	var ids []string
+	for _, a := range r.Defaults() {
+		ids = append(ids, a.ID)
+	}
+	if len(ids) != 3 || ids[0] != "BTC/BTC" || ids[1] != "ETH/ERC20" || ids[2] != "USDT/BEP20" {
+		t.Fatalf("defaults %v", ids)
+	}
+	if len(r.List()) != 5 {
+		t.Fatalf("list %+v", r.List())
+	}
+}
+
+func TestLookupWithoutDefault(t *testing.T) {
+	r := testRegistry(
+		Asset{Coin: "USDC", Network: "POLYGON", Chain: "polygon", Contract: "0x1", Decimals: 6, Confirmations: 64},
+		Asset{Coin: "USDC", Network: "BEP20", Chain: "bsc", Contract: "0x2", Decimals: 18, Confirmations: 15},
+	)
+	if _, err := r.Lookup("USDC", ""); !errors.Is(err, ErrUnknown) {
+		t.Fatalf("two networks and no default must need a network, got %v", err)
+	}
+	r = testRegistry(
+		Asset{Coin: "USDC", Network: "POLYGON", Chain: "polygon", Contract: "0x1", Decimals: 6, Confirmations: 64},
+		Asset{Coin: "USDC", Network: "BEP20", Chain: "bsc", Contract: "0x2", Decimals: 18, Confirmations: 15, Disabled: true},
+	)
+	if a, err := r.Lookup("USDC", ""); err != nil || a.Network != "POLYGON" {
+		t.Fatalf("only enabled network: %+v %v", a, err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	bad := []Asset{
+		{Coin: "USDC", Network: "POLYGON", Decimals: 6, Confirmations: 1},
+		{Coin: "USDC", Network: "POLYGON", Chain: "polygon", Decimals: 6},
+		{Coin: "USDC", Network: "POLYGON", Chain: "polygon", Decimals: 6, Confirmations: 1, ExplorerURL: "https://polygonscan.com/tx/"},
+		{Coin: "BTC", Network: "BTC", Chain: "bitcoin", Contract: "x", Decimals: 8, Confirmations: 1},
+		{Coin: "USDC", Network: "POLY/GON", Chain: "polygon", Decimals: 6, Confirmations: 1},
+		{Coin: "USDC", Network: "POLYGON", Chain: "polygon", Decimals: 6, Confirmations: 64, Finality: 12},
+	}
+	for _, a := range bad {
+		if err := a.Validate(); err == nil {
+			t.Fatalf("%+v must be rejected", a)
+		}
+	}
+	ok := Asset{Coin: "usdc", Network: "polygon", Chain: "polygon", Decimals: 6, Confirmations: 64}
+	if err := ok.Validate(); err != nil || ok.Finality != 64 || ok.ID != "USDC/POLYGON" {
+		t.Fatalf("%+v %v", ok, err)
+	}
+}
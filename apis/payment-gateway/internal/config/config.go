@@ -2,11 +2,19 @@ package config
 
 import (
 	"log"
+	"math"
 	"net"
 	"net/url"
 	"os"
 	"strconv"
 	"strings"
+	"time"
+
+	"gamehub/payment-gateway/internal/kyc"
+	"gamehub/payment-gateway/internal/limits"
+	"gamehub/payment-gateway/internal/outbox"
+	"gamehub/payment-gateway/internal/payout"
+	"gamehub/payment-gateway/internal/withdrawal"
 )
 
 type Config struct {
@@ -43,6 +51,7 @@ type Config struct {
 
 	JWTPublicKeyPath string
 	JWTIssuer        string
+	JWKSURL          string // auth-service JWKS endpoint for rotated keys
 	AppEnv           string
 
 	// Deposit fee: fraction of each deposit kept by the house before crediting.
@@ -54,6 +63,67 @@ type Config struct {
 	// e.g. 0.05 = 5% fee. Applies to MoMo withdrawals.
 	// Set to 0.0 to disable.
 	WithdrawalFeeRate float64
+
+	// MoMoAutoPayout sends approved mobile money withdrawals through a
+	// Flutterwave transfer unless the reviewer says otherwise.
+	MoMoAutoPayout bool
+
+	// CryptoAutoPayout signs and broadcasts approved crypto withdrawals
+	// from the hot wallet unless the reviewer says otherwise.
+	CryptoAutoPayout bool
+	// CryptoUSDRates converts USD withdrawal amounts to coins
+	// (CRYPTO_PAYOUT_USD_RATES, e.g. "BTC=65000,ETH=3200"). USDT is 1:1;
+	// coins without a rate are not paid out automatically.
+	CryptoUSDRates map[string]float64
+	// Payout holds chain parameters for crypto payouts and sweeps,
+	// including the hot wallet's address index (CRYPTO_HOT_WALLET_INDEX).
+	Payout payout.Config
+
+	// CryptoSweepEnabled consolidates user deposit addresses into treasury
+	// every CryptoSweepInterval.
+	CryptoSweepEnabled  bool
+	CryptoSweepInterval time.Duration
+	// CryptoSweepMin is the smallest balance per coin worth sweeping
+	// (CRYPTO_SWEEP_MIN, e.g. "BTC=0.0005,ETH=0.005,USDT=5").
+	CryptoSweepMin map[string]float64
+	// CryptoTreasury maps networks to the address sweeps are sent to
+	// (CRYPTO_TREASURY_ADDRESSES, e.g. "BTC=bc1...,ERC20=0x...,TRC20=T...").
+	// Networks without one sweep into the hot wallet.
+	CryptoTreasury map[string]string
+
+	// CryptoHeadPollInterval polls chain heads for open deposits when
+	// block notifications don't arrive; zero disables it.
+	CryptoHeadPollInterval time.Duration
+	// The deposit backfill polls at most CryptoBackfillLimit addresses
+	// active within CryptoBackfillWindow, every CryptoWatcherInterval.
+	CryptoBackfillWindow time.Duration
+	CryptoBackfillLimit  int64
+
+	// SecretsKeyFile holds the master keys sealing stored secrets such as
+	// wallet mnemonics (see cmd/secretsctl).
+	SecretsKeyFile string
+
+	// WithdrawalPolicy sets auto-approval, dual approval and the review SLA
+	// (WITHDRAWAL_AUTO_APPROVE_MAX, WITHDRAWAL_AUTO_APPROVE_KYC_LEVEL,
+	// WITHDRAWAL_DUAL_APPROVAL_ABOVE, WITHDRAWAL_SLA_HOURS).
+	WithdrawalPolicy withdrawal.Policy
+
+	// Outbox tunes the relay that delivers wallet credits and releases
+	// (OUTBOX_MAX_ATTEMPTS, OUTBOX_STUCK_AFTER_MINUTES).
+	Outbox outbox.RelayConfig
+
+	// RequireStepUp makes withdrawals demand an elevated token from the
+	// auth-service 2FA step-up (users without 2FA cannot withdraw).
+	RequireStepUp bool
+
+	// KYCLimits caps single deposits and withdrawals by the user's KYC level
+	// (KYC_TIER_LIMITS, e.g. "0=200/50,1=2000/1000,2=*/*").
+	KYCLimits kyc.Limits
+
+	// Limits bounds single transactions and caps daily, weekly and monthly
+	// totals per user and channel (LIMITS_PER_TRANSACTION, LIMITS_VELOCITY,
+	// LIMITS_GLOBAL_DAILY_OUTFLOW). Its KYC ceilings are KYCLimits.
+	Limits limits.Policy
 }
 
 func Load() *Config {
@@ -98,7 +168,7 @@ func Load() *Config {
 	baseURL := getEnv("FLUTTERWAVE_BASE_URL", "https://api.flutterwave.com")
 	transferBaseURL := getEnv("FLUTTERWAVE_TRANSFERS_BASE_URL", baseURL)
 
-	return &Config{
+	cfg := &Config{
 		Port:                        getEnv("PORT", "8003"),
 		MongoURI:                    mustGetEnv("MONGO_URI"),
 		RedisAddr:                   resolveRedisAddr(),
@@ -127,10 +197,111 @@ func Load() *Config {
 		InternalServiceKey:          getEnv("INTERNAL_SERVICE_KEY", "dev-internal-key"),
 		JWTPublicKeyPath:            getEnv("JWT_PUBLIC_KEY_PATH", ""),
 		JWTIssuer:                   getEnv("JWT_ISSUER", "gamehub-auth"),
+		JWKSURL:                     getEnv("AUTH_JWKS_URL", "http://127.0.0.1:"+getEnv("AUTH_SERVICE_PORT", "8001")+"/.well-known/jwks.json"),
 		AppEnv:                      appEnv,
 		DepositFeeRate:              getFloatEnv("DEPOSIT_FEE_RATE", 0.0),
 		WithdrawalFeeRate:           getFloatEnv("WITHDRAWAL_FEE_RATE", 0.0),
+		MoMoAutoPayout:              strings.EqualFold(getEnv("MOMO_AUTO_PAYOUT", "false"), "true"),
+		CryptoAutoPayout:            strings.EqualFold(getEnv("CRYPTO_AUTO_PAYOUT", "false"), "true"),
+		CryptoUSDRates:              parseRates(getEnv("CRYPTO_PAYOUT_USD_RATES", "")),
+		SecretsKeyFile:              getEnv("SECRETS_KEY_FILE", "secrets.key"),
+		CryptoSweepEnabled:          strings.EqualFold(getEnv("CRYPTO_SWEEP_ENABLED", "false"), "true"),
+		CryptoSweepInterval:         time.Duration(getIntEnv("CRYPTO_SWEEP_INTERVAL_MINUTES", 60)) * time.Minute,
+		CryptoSweepMin:              parseCoinAmounts(getEnv("CRYPTO_SWEEP_MIN", "BTC=0.0005,ETH=0.005,USDT=5"), "sweep minimum"),
+		CryptoTreasury:              parseTreasury(getEnv("CRYPTO_TREASURY_ADDRESSES", "")),
+		CryptoHeadPollInterval:      time.Duration(getIntEnv("CRYPTO_HEAD_POLL_SECONDS", 30)) * time.Second,
+		CryptoBackfillWindow:        time.Duration(getIntEnv("CRYPTO_BACKFILL_WINDOW_HOURS", 24)) * time.Hour,
+		CryptoBackfillLimit:         int64(getIntEnv("CRYPTO_BACKFILL_LIMIT", 200)),
+		Payout: payout.Config{
+			Testnet:           strings.EqualFold(getEnv("TATUM_TESTNET", "false"), "true"),
+			HotWalletIndex:    uint32(getIntEnv("CRYPTO_HOT_WALLET_INDEX", 0)),
+			USDTTRC20Contract: getEnv("CRYPTO_USDT_TRC20_CONTRACT", ""),
+			USDTERC20Contract: getEnv("CRYPTO_USDT_ERC20_CONTRACT", ""),
+			ERC20GasLimit:     uint64(getIntEnv("CRYPTO_ERC20_GAS_LIMIT", 100000)),
+			TronFeeLimit:      int64(getIntEnv("CRYPTO_TRON_FEE_LIMIT_SUN", 20_000_000)),
+			BitcoinFeeRate:    int64(getIntEnv("CRYPTO_BTC_FEE_RATE", 10)),
+		},
+		WithdrawalPolicy: withdrawal.Policy{
+			AutoApproveMax:      getFloatEnv("WITHDRAWAL_AUTO_APPROVE_MAX", 0),
+			AutoApproveKYCLevel: getIntEnv("WITHDRAWAL_AUTO_APPROVE_KYC_LEVEL", 0),
+			DualApprovalAbove:   getFloatEnv("WITHDRAWAL_DUAL_APPROVAL_ABOVE", 0),
+			SLA:                 time.Duration(getIntEnv("WITHDRAWAL_SLA_HOURS", 120)) * time.Hour,
+		},
+		Outbox: outbox.RelayConfig{
+			MaxAttempts: getIntEnv("OUTBOX_MAX_ATTEMPTS", 20),
+			StuckAfter:  time.Duration(getIntEnv("OUTBOX_STUCK_AFTER_MINUTES", 5)) * time.Minute,
+		},
+		RequireStepUp: !strings.EqualFold(getEnv("WITHDRAWAL_REQUIRE_STEP_UP", "true"), "false"),
+		KYCLimits:     loadKYCLimits(getEnv("KYC_TIER_LIMITS", kyc.DefaultLimits)),
+	}
+	cfg.Limits = loadLimits(cfg.KYCLimits)
+	return cfg
+}
+
+func loadKYCLimits(raw string) kyc.Limits {
+	limits, err := kyc.ParseLimits(raw)
+	if err != nil {
+		log.Printf("⚠️ %v; using %s", err, kyc.DefaultLimits)
+		limits, _ = kyc.ParseLimits(kyc.DefaultLimits)
+	}
+	return limits
+}
+
+func loadLimits(kycLimits kyc.Limits) limits.Policy {
+	tx, err := limits.ParseTransactionRules(getEnv("LIMITS_PER_TRANSACTION", limits.DefaultTransaction))
+	if err != nil {
+		log.Printf("⚠️ LIMITS_PER_TRANSACTION: %v; using %s", err, limits.DefaultTransaction)
+		tx, _ = limits.ParseTransactionRules(limits.DefaultTransaction)
+	}
+	velocity, err := limits.ParseVelocityRules(getEnv("LIMITS_VELOCITY", limits.DefaultVelocity))
+	if err != nil {
+		log.Printf("⚠️ LIMITS_VELOCITY: %v; using %s", err, limits.DefaultVelocity)
+		velocity, _ = limits.ParseVelocityRules(limits.DefaultVelocity)
+	}
+	outflow, err := limits.ParseAmount(getEnv("LIMITS_GLOBAL_DAILY_OUTFLOW", "*"))
+	if err != nil {
+		log.Printf("⚠️ LIMITS_GLOBAL_DAILY_OUTFLOW: %v; not capping outflow", err)
+		outflow = math.Inf(1)
+	}
+	return limits.Policy{Transaction: tx, Velocity: velocity, KYC: kycLimits, GlobalDailyOutflow: outflow}
+}
+
+// parseRates reads "COIN=rate" pairs. USDT defaults to 1.
+func parseRates(raw string) map[string]float64 {
+	rates := parseCoinAmounts(raw, "crypto rate")
+	if _, ok := rates["USDT"]; !ok {
+		rates["USDT"] = 1
+	}
+	return rates
+}
+
+// parseCoinAmounts reads "COIN=amount" pairs with positive amounts.
+func parseCoinAmounts(raw, what string) map[string]float64 {
+	amounts := map[string]float64{}
+	for _, pair := range splitAndTrim(raw) {
+		coin, value, _ := strings.Cut(pair, "=")
+		amount, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || amount <= 0 {
+			log.Printf("⚠️ ignoring %s %q", what, pair)
+			continue
+		}
+		amounts[strings.ToUpper(strings.TrimSpace(coin))] = amount
+	}
+	return amounts
+}
+
+// parseTreasury reads "NETWORK=address" pairs.
+func parseTreasury(raw string) map[string]string {
+	addresses := map[string]string{}
+	for _, pair := range splitAndTrim(raw) {
+		network, address, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(address) == "" {
+			log.Printf("⚠️ ignoring treasury address %q", pair)
+			continue
+		}
+		addresses[payout.NormalizeNetwork(network)] = strings.TrimSpace(address)
 	}
+	return addresses
 }
 
 func selectFlutterwaveValue(override, mode, testVal, liveVal string) string {
@@ -0,0 +1,203 @@
+// Package derivation hands out HD wallet address indexes for deposit
+// addresses. An index is first reserved for an owner and only becomes
+// theirs when Commit runs in the same transaction as the wallet insert,
+// so an index is never given to two owners, and one whose wallet was
+// never created goes back to the pool instead of being skipped.
+package derivation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Entry statuses.
+const (
+	Reserved = "RESERVED"
+	Assigned = "ASSIGNED"
+)
+
+// reservationTTL is how long a reservation is held for its owner. After
+// that another owner may take the index, provided it was never committed.
+const reservationTTL = 10 * time.Minute
+
+// ErrNotReserved is returned by Commit when the index is no longer
+// reserved for the owner: it expired and was taken, or was committed.
+var ErrNotReserved = errors.New("derivation index not reserved for owner")
+
+// Entry is an index in the registry. Coin names the sequence the index
+// belongs to; assets derived from the same master key share one.
+type Entry struct {
+	ID         string    `bson:"_id" json:"id"`
+	Coin       string    `bson:"coin" json:"coin"`
+	Index      int64     `bson:"index" json:"index"`
+	Status     string    `bson:"status" json:"status"`
+	Owner      string    `bson:"owner" json:"owner"`
+	Address    string    `bson:"address,omitempty" json:"address,omitempty"`
+	ReservedAt time.Time `bson:"reservedAt" json:"reservedAt"`
+	ExpiresAt  time.Time `bson:"expiresAt,omitempty" json:"-"`
+	AssignedAt time.Time `bson:"assignedAt,omitempty" json:"assignedAt,omitempty"`
+}
+
+// Registry is the derivation_indexes collection. New indexes come from
+// the per-coin sequence in crypto_counters, which earlier releases bumped
+// directly, so indexes already in use are never issued again.
+type Registry struct {
+	entries  *mongo.Collection
+	counters *mongo.Collection
+	skip     map[int64]bool
+}
+
+// NewRegistry returns the registry in db. Indexes in skip, such as the
+// hot wallet's, are never handed out.
+func NewRegistry(db *mongo.Database, skip ...int64) *Registry {
+	r := &Registry{
+		entries:  db.Collection("derivation_indexes"),
+		counters: db.Collection("crypto_counters"),
+		skip:     map[int64]bool{},
+	}
+	for _, i := range skip {
+		r.skip[i] = true
+	}
+	return r
+}
+
+// EnsureIndexes creates the registry's indexes. The partial unique index
+// keeps an owner to one open reservation per sequence.
+func (r *Registry) EnsureIndexes(ctx context.Context) error {
+	_, err := r.entries.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "coin", Value: 1}, {Key: "index", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{
+			Keys: bson.D{{Key: "coin", Value: 1}, {Key: "owner", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"status": Reserved}),
+		},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "expiresAt", Value: 1}}},
+	})
+	return err
+}
+
+// Reserve returns an index from coin's sequence for owner to derive an
+// address at. An owner asking again gets the same reservation back;
+// otherwise an expired reservation is reused before a new index is drawn.
+func (r *Registry) Reserve(ctx context.Context, coin, owner string) (*Entry, error) {
+	coin = strings.ToUpper(coin)
+	for attempt := 0; attempt < 5; attempt++ {
+		now := time.Now()
+		renew := bson.M{"$set": bson.M{"expiresAt": now.Add(reservationTTL)}}
+		after := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+		var e Entry
+		err := r.entries.FindOneAndUpdate(ctx,
+			bson.M{"coin": coin, "owner": owner, "status": Reserved}, renew, after).Decode(&e)
+		if err == nil {
+			return &e, nil
+		}
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, err
+		}
+
+		err = r.entries.FindOneAndUpdate(ctx,
+			bson.M{"coin": coin, "status": Reserved, "expiresAt": bson.M{"$lt": now}},
+			bson.M{"$set": bson.M{"owner": owner, "reservedAt": now, "expiresAt": now.Add(reservationTTL)}},
+			after.SetSort(bson.D{{Key: "index", Value: 1}})).Decode(&e)
+		if err == nil {
+			return &e, nil
+		}
+		if mongo.IsDuplicateKeyError(err) {
+			continue // owner reserved concurrently
+		}
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, err
+		}
+
+		entry, err := r.reserveNext(ctx, coin, owner, now)
+		if mongo.IsDuplicateKeyError(err) {
+			continue
+		}
+		return entry, err
+	}
+	return nil, fmt.Errorf("derivation: could not reserve a %s index for %s", coin, owner)
+}
+
+// reserveNext draws the next index from the sequence and reserves it in
+// one transaction, so a drawn index always has an entry.
+func (r *Registry) reserveNext(ctx context.Context, coin, owner string, now time.Time) (*Entry, error) {
+	var e *Entry
+	err := withTransaction(ctx, r.entries.Database().Client(), func(sc mongo.SessionContext) error {
+		var counter struct {
+			Seq int64 `bson:"seq"`
+		}
+		for {
+			err := r.counters.FindOneAndUpdate(sc,
+				bson.M{"_id": "crypto_" + strings.ToLower(coin)},
+				bson.M{"$inc": bson.M{"seq": int64(1)}},
+				options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+			).Decode(&counter)
+			if err != nil {
+				return err
+			}
+			if !r.skip[counter.Seq] {
+				break
+			}
+		}
+		e = &Entry{
+			ID:         fmt.Sprintf("%s:%d", coin, counter.Seq),
+			Coin:       coin,
+			Index:      counter.Seq,
+			Status:     Reserved,
+			Owner:      owner,
+			ReservedAt: now,
+			ExpiresAt:  now.Add(reservationTTL),
+		}
+		_, err := r.entries.InsertOne(sc, e)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Commit assigns a reserved index to its owner for good. Pass the
+// mongo.SessionContext of the transaction that stores the address so
+// both commit together.
+func (r *Registry) Commit(ctx context.Context, e *Entry, address string) error {
+	res, err := r.entries.UpdateOne(ctx,
+		bson.M{"_id": e.ID, "owner": e.Owner, "status": Reserved},
+		bson.M{
+			"$set":   bson.M{"status": Assigned, "address": address, "assignedAt": time.Now()},
+			"$unset": bson.M{"expiresAt": ""},
+		})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("%w: %s", ErrNotReserved, e.ID)
+	}
+	return nil
+}
+
+func withTransaction(ctx context.Context, client *mongo.Client, fn func(mongo.SessionContext) error) error {
+	session, err := client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+	return mongo.WithSession(ctx, session, func(sc mongo.SessionContext) error {
+		if err := sc.StartTransaction(); err != nil {
+			return err
+		}
+		if err := fn(sc); err != nil {
+			_ = sc.AbortTransaction(sc)
+			return err
+		}
+		return sc.CommitTransaction(sc)
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -15,6 +16,15 @@ import (
 
 const maxRetries = 2
 
+// ErrRejected marks a request Flutterwave answered and refused. Other
+// errors (timeouts, network failures, 5xx after retries) leave the outcome
+// unknown: a transfer may have been created anyway.
+var ErrRejected = errors.New("flutterwave rejected the request")
+
+// ErrTransferNotFound is returned by GetTransferByReference when
+// Flutterwave has no transfer under the reference.
+var ErrTransferNotFound = errors.New("transfer not found")
+
 // Client wraps the subset of Flutterwave's API surface needed for
 // mobile money deposits and withdrawals.
 type Client struct {
@@ -238,14 +248,14 @@ func (c *Client) GetTransferByReference(ctx context.Context, reference string, t
 		return nil, err
 	}
 	if len(envelope.Data) == 0 {
-		return nil, fmt.Errorf("transfer not found for %s", reference)
+		return nil, fmt.Errorf("%w for %s", ErrTransferNotFound, reference)
 	}
 	var transfers []TransferResponse
 	if err := json.Unmarshal(envelope.Data, &transfers); err != nil {
 		return nil, err
 	}
 	if len(transfers) == 0 {
-		return nil, fmt.Errorf("transfer not found for %s", reference)
+		return nil, fmt.Errorf("%w for %s", ErrTransferNotFound, reference)
 	}
 	return &transfers[0], nil
 }
@@ -329,13 +339,13 @@ func (c *Client) do(ctx context.Context, method, endpoint string, payload interf
 		if resp.StatusCode >= 500 {
 			return &retryableError{status: resp.StatusCode, body: string(respBody)}
 		}
-		return fmt.Errorf("flutterwave http %s: %s", resp.Status, string(respBody))
+		return fmt.Errorf("%w: http %s: %s", ErrRejected, resp.Status, string(respBody))
 	}
 	if err := json.Unmarshal(respBody, envelope); err != nil {
 		return err
 	}
 	if strings.ToLower(envelope.Status) != "success" {
-		return fmt.Errorf("flutterwave error: %s", envelope.Message)
+		return fmt.Errorf("%w: %s", ErrRejected, envelope.Message)
 	}
 	return nil
 }
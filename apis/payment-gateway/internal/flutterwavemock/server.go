@@ -0,0 +1,493 @@
+// Package flutterwavemock is a local stand-in for the Flutterwave v3 API. It
+// serves the charge, verify-by-reference, transfer and transfer-lookup
+// endpoints used by flutterwave.Client and sends webhooks signed with
+// verif-hash back to payment-gateway, either after a delay or on demand.
+package flutterwavemock
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config controls authentication and automatic settlement.
+type Config struct {
+	// SecretKey is the accepted bearer token. Empty accepts any token.
+	SecretKey string
+	// WebhookSecret is sent in the verif-hash header of every webhook.
+	WebhookSecret string
+	// ChargeWebhookURL receives charge webhooks (Flutterwave sends these to
+	// the dashboard URL, not the charge's callback_url).
+	ChargeWebhookURL string
+	// TransferWebhookURL receives transfer webhooks when the transfer has
+	// no callback_url.
+	TransferWebhookURL string
+	// SettleAfter settles charges and transfers automatically after this
+	// delay. 0 leaves them pending until CompleteCharge/CompleteTransfer.
+	SettleAfter time.Duration
+	// Outcome is the status automatic settlements end in: "successful"
+	// (default) or "failed".
+	Outcome string
+	// Latency is added before every response.
+	Latency time.Duration
+}
+
+// Charge is a mobile money charge as Flutterwave reports it.
+type Charge struct {
+	ID       int64   `json:"id"`
+	TxRef    string  `json:"tx_ref"`
+	FlwRef   string  `json:"flw_ref"`
+	Amount   float64 `json:"amount"`
+	Currency string  `json:"currency"`
+	Status   string  `json:"status"`
+	Phone    string  `json:"phone_number,omitempty"`
+	Network  string  `json:"network,omitempty"`
+}
+
+// Transfer is a payout as Flutterwave reports it.
+type Transfer struct {
+	ID            int64   `json:"id"`
+	Reference     string  `json:"reference"`
+	FlwRef        string  `json:"flw_ref"`
+	Amount        float64 `json:"amount"`
+	Currency      string  `json:"currency"`
+	Status        string  `json:"status"`
+	AccountBank   string  `json:"bank_code"`
+	AccountNumber string  `json:"account_number"`
+	CallbackURL   string  `json:"-"`
+}
+
+type fault struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
+
+// Route names used by FailNext and Requests.
+const (
+	RouteCharge         = "charge"
+	RouteVerify         = "verify"
+	RouteTransfer       = "transfer"
+	RouteTransferLookup = "transfer_lookup"
+)
+
+// Server implements http.Handler. /v3/ paths speak the Flutterwave API;
+// POST /_mock/ paths settle payments and script faults.
+type Server struct {
+	cfg    Config
+	client *http.Client
+
+	mu        sync.Mutex
+	nextID    int64
+	charges   map[string]*Charge
+	transfers map[string]*Transfer
+	faults    map[string][]fault
+	requests  map[string][]map[string]interface{}
+}
+
+// New creates a Server, filling unset config fields with defaults.
+func New(cfg Config) *Server {
+	if cfg.Outcome == "" {
+		cfg.Outcome = "successful"
+	}
+	return &Server{
+		cfg:       cfg,
+		client:    &http.Client{Timeout: 10 * time.Second},
+		nextID:    1000,
+		charges:   make(map[string]*Charge),
+		transfers: make(map[string]*Transfer),
+		faults:    make(map[string][]fault),
+		requests:  make(map[string][]map[string]interface{}),
+	}
+}
+
+// FailNext makes the next request to route answer with HTTP status and an
+// error envelope. 5xx statuses exercise the client's retries.
+func (s *Server) FailNext(route string, status int, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults[route] = append(s.faults[route], fault{Status: status, Message: message})
+}
+
+// Requests returns the payloads received on route, oldest first.
+func (s *Server) Requests(route string) []map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]map[string]interface{}, len(s.requests[route]))
+	copy(out, s.requests[route])
+	return out
+}
+
+// Charge returns the charge with tx_ref, if any.
+func (s *Server) Charge(txRef string) (Charge, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ch, ok := s.charges[txRef]
+	if !ok {
+		return Charge{}, false
+	}
+	return *ch, true
+}
+
+// Transfer returns the transfer with reference, if any.
+func (s *Server) Transfer(reference string) (Transfer, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tr, ok := s.transfers[reference]
+	if !ok {
+		return Transfer{}, false
+	}
+	return *tr, true
+}
+
+// CompleteCharge settles a pending charge and delivers its webhook. It
+// returns the webhook receiver's HTTP status (0 when no URL is set).
+func (s *Server) CompleteCharge(txRef string, success bool) (int, error) {
+	s.mu.Lock()
+	ch, ok := s.charges[txRef]
+	if !ok {
+		s.mu.Unlock()
+		return 0, fmt.Errorf("flutterwavemock: no charge %q", txRef)
+	}
+	ch.Status = outcome(success)
+	snapshot := *ch
+	s.mu.Unlock()
+
+	event := map[string]interface{}{
+		"event": "charge.completed",
+		"data": map[string]interface{}{
+			"id":       snapshot.ID,
+			"tx_ref":   snapshot.TxRef,
+			"flw_ref":  snapshot.FlwRef,
+			"amount":   snapshot.Amount,
+			"currency": snapshot.Currency,
+			"status":   snapshot.Status,
+		},
+	}
+	return s.deliver(s.cfg.ChargeWebhookURL, event)
+}
+
+// CompleteTransfer settles a transfer and delivers its webhook to the
+// transfer's callback_url, or TransferWebhookURL.
+func (s *Server) CompleteTransfer(reference string, success bool) (int, error) {
+	s.mu.Lock()
+	tr, ok := s.transfers[reference]
+	if !ok {
+		s.mu.Unlock()
+		return 0, fmt.Errorf("flutterwavemock: no transfer %q", reference)
+	}
+	tr.Status = strings.ToUpper(outcome(success))
+	snapshot := *tr
+	s.mu.Unlock()
+
+	event := map[string]interface{}{
+		"event": "transfer.completed",
+		"data": map[string]interface{}{
+			"id":             snapshot.ID,
+			"reference":      snapshot.Reference,
+			"flw_ref":        snapshot.FlwRef,
+			"amount":         snapshot.Amount,
+			"currency":       snapshot.Currency,
+			"status":         snapshot.Status,
+			"account_number": snapshot.AccountNumber,
+			"bank_code":      snapshot.AccountBank,
+		},
+	}
+	url := snapshot.CallbackURL
+	if url == "" {
+		url = s.cfg.TransferWebhookURL
+	}
+	return s.deliver(url, event)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, "/_mock/") {
+		s.serveAdmin(w, r)
+		return
+	}
+	if s.cfg.SecretKey != "" && r.Header.Get("Authorization") != "Bearer "+s.cfg.SecretKey {
+		writeError(w, http.StatusUnauthorized, "Invalid authorization key")
+		return
+	}
+
+	var route string
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/v3/charges":
+		route = RouteCharge
+	case r.Method == http.MethodGet && r.URL.Path == "/v3/transactions/verify_by_reference":
+		route = RouteVerify
+	case r.Method == http.MethodPost && r.URL.Path == "/v3/transfers":
+		route = RouteTransfer
+	case r.Method == http.MethodGet && r.URL.Path == "/v3/transfers":
+		route = RouteTransferLookup
+	default:
+		writeError(w, http.StatusNotFound, "Route not found")
+		return
+	}
+
+	body := map[string]interface{}{}
+	if r.Method == http.MethodPost {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid JSON body")
+			return
+		}
+	}
+	for key := range r.URL.Query() {
+		body["query."+key] = r.URL.Query().Get(key)
+	}
+	f, latency := s.record(route, body)
+	if latency > 0 {
+		time.Sleep(latency)
+	}
+	if f != nil {
+		writeError(w, f.Status, f.Message)
+		return
+	}
+
+	switch route {
+	case RouteCharge:
+		s.handleCharge(w, r, body)
+	case RouteVerify:
+		s.handleVerify(w, r)
+	case RouteTransfer:
+		s.handleTransfer(w, body)
+	case RouteTransferLookup:
+		s.handleTransferLookup(w, r)
+	}
+}
+
+func (s *Server) handleCharge(w http.ResponseWriter, r *http.Request, body map[string]interface{}) {
+	if r.URL.Query().Get("type") == "" {
+		writeError(w, http.StatusBadRequest, "type is required")
+		return
+	}
+	txRef := readString(body, "tx_ref")
+	amount := readFloat(body, "amount")
+	if txRef == "" || amount <= 0 {
+		writeError(w, http.StatusBadRequest, "tx_ref and amount are required")
+		return
+	}
+
+	s.mu.Lock()
+	if _, exists := s.charges[txRef]; exists {
+		s.mu.Unlock()
+		writeError(w, http.StatusBadRequest, "Duplicate transaction reference")
+		return
+	}
+	s.nextID++
+	ch := &Charge{
+		ID:       s.nextID,
+		TxRef:    txRef,
+		FlwRef:   fmt.Sprintf("FLW-MOCK-%d", s.nextID),
+		Amount:   amount,
+		Currency: readString(body, "currency"),
+		Status:   "pending",
+		Phone:    readString(body, "phone_number"),
+		Network:  readString(body, "network"),
+	}
+	s.charges[txRef] = ch
+	snapshot := *ch
+	s.mu.Unlock()
+
+	if s.cfg.SettleAfter > 0 {
+		time.AfterFunc(s.cfg.SettleAfter, func() {
+			if _, err := s.CompleteCharge(txRef, s.cfg.Outcome != "failed"); err != nil {
+				log.Printf("flutterwavemock: charge %s webhook: %v", txRef, err)
+			}
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Charge initiated",
+		"data":    snapshot,
+		"meta": map[string]interface{}{
+			"authorization": map[string]interface{}{
+				"mode":                  "otp",
+				"validate_instructions": "Approve the prompt on your phone.",
+			},
+		},
+	})
+}
+
+func (s *Server) handleVerify(w http.ResponseWriter, r *http.Request) {
+	ch, ok := s.Charge(r.URL.Query().Get("tx_ref"))
+	if !ok {
+		writeError(w, http.StatusBadRequest, "No transaction was found for this id")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Transaction fetched successfully",
+		"data":    ch,
+	})
+}
+
+func (s *Server) handleTransfer(w http.ResponseWriter, body map[string]interface{}) {
+	reference := readString(body, "reference")
+	amount := readFloat(body, "amount")
+	if reference == "" || amount <= 0 || readString(body, "account_number") == "" {
+		writeError(w, http.StatusBadRequest, "reference, amount and account_number are required")
+		return
+	}
+
+	s.mu.Lock()
+	if _, exists := s.transfers[reference]; exists {
+		s.mu.Unlock()
+		writeError(w, http.StatusBadRequest, "Duplicate reference")
+		return
+	}
+	s.nextID++
+	tr := &Transfer{
+		ID:            s.nextID,
+		Reference:     reference,
+		FlwRef:        fmt.Sprintf("FLW-MOCK-TRF-%d", s.nextID),
+		Amount:        amount,
+		Currency:      readString(body, "currency"),
+		Status:        "NEW",
+		AccountBank:   readString(body, "account_bank"),
+		AccountNumber: readString(body, "account_number"),
+		CallbackURL:   readString(body, "callback_url"),
+	}
+	s.transfers[reference] = tr
+	snapshot := *tr
+	s.mu.Unlock()
+
+	if s.cfg.SettleAfter > 0 {
+		time.AfterFunc(s.cfg.SettleAfter, func() {
+			if _, err := s.CompleteTransfer(reference, s.cfg.Outcome != "failed"); err != nil {
+				log.Printf("flutterwavemock: transfer %s webhook: %v", reference, err)
+			}
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Transfer Queued Successfully",
+		"data":    snapshot,
+	})
+}
+
+func (s *Server) handleTransferLookup(w http.ResponseWriter, r *http.Request) {
+	items := []Transfer{}
+	if tr, ok := s.Transfer(r.URL.Query().Get("reference")); ok {
+		items = append(items, tr)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":  "success",
+		"message": "Transfers fetched",
+		"data":    items,
+	})
+}
+
+func (s *Server) serveAdmin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		Reference string `json:"reference"`
+		Status    string `json:"status"`
+		Route     string `json:"route"`
+		Code      int    `json:"code"`
+		Message   string `json:"message"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	success := !strings.EqualFold(req.Status, "failed")
+
+	var (
+		code int
+		err  error
+	)
+	switch r.URL.Path {
+	case "/_mock/charges/complete":
+		code, err = s.CompleteCharge(req.Reference, success)
+	case "/_mock/transfers/complete":
+		code, err = s.CompleteTransfer(req.Reference, success)
+	case "/_mock/faults":
+		if req.Route == "" || req.Code == 0 {
+			http.Error(w, "route and code are required", http.StatusBadRequest)
+			return
+		}
+		s.FailNext(req.Route, req.Code, req.Message)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"webhookStatus": code})
+}
+
+// deliver posts event to url with the verif-hash header.
+func (s *Server) deliver(url string, event map[string]interface{}) (int, error) {
+	if url == "" {
+		return 0, nil
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.cfg.WebhookSecret != "" {
+		req.Header.Set("verif-hash", s.cfg.WebhookSecret)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// record stores the request and pops any queued fault for route.
+func (s *Server) record(route string, body map[string]interface{}) (*fault, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests[route] = append(s.requests[route], body)
+	queue := s.faults[route]
+	if len(queue) == 0 {
+		return nil, s.cfg.Latency
+	}
+	f := queue[0]
+	s.faults[route] = queue[1:]
+	return &f, s.cfg.Latency
+}
+
+func outcome(success bool) string {
+	if success {
+		return "successful"
+	}
+	return "failed"
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]interface{}{"status": "error", "message": message, "data": nil})
+}
+
+func readString(m map[string]interface{}, key string) string {
+	s, _ := m[key].(string)
+	return strings.TrimSpace(s)
+}
+
+func readFloat(m map[string]interface{}, key string) float64 {
+	f, _ := m[key].(float64)
+	return f
+}
@@ -0,0 +1,177 @@
+package flutterwavemock_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"gamehub/payment-gateway/internal/config"
+	"gamehub/payment-gateway/internal/flutterwave"
+	"gamehub/payment-gateway/internal/flutterwavemock"
+	"gamehub/payment-gateway/internal/middleware"
+)
+
+type webhook struct {
+	Event string `json:"event"`
+	Data  struct {
+		TxRef     string `json:"tx_ref"`
+		Reference string `json:"reference"`
+		Status    string `json:"status"`
+	} `json:"data"`
+}
+
+// receiver serves payment-gateway's webhook routes behind the real
+// verif-hash middleware and forwards accepted events.
+func receiver(t *testing.T, secret string) (string, <-chan webhook) {
+	t.Helper()
+	events := make(chan webhook, 8)
+	cfg := &config.Config{FlutterwaveWebhookSecret: secret}
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	accept := func(c *fiber.Ctx) error {
+		var evt webhook
+		if err := json.Unmarshal(c.Body(), &evt); err != nil {
+			return c.SendStatus(http.StatusBadRequest)
+		}
+		events <- evt
+		return c.SendStatus(http.StatusOK)
+	}
+	app.Post("/webhooks/payment/flutterwave", middleware.VerifyFlutterwaveHMAC(cfg), accept)
+	app.Post("/webhooks/payment/flutterwave/withdrawal", middleware.VerifyFlutterwaveHMAC(cfg), accept)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go app.Listener(ln)
+	t.Cleanup(func() { app.Shutdown() })
+	return "http://" + ln.Addr().String(), events
+}
+
+func TestChargeSettlesWithSignedWebhook(t *testing.T) {
+	base, events := receiver(t, "whsec")
+	mock, url := flutterwavemock.NewTestServer(t, flutterwavemock.Config{
+		SecretKey:        "sk_test",
+		WebhookSecret:    "whsec",
+		ChargeWebhookURL: base + "/webhooks/payment/flutterwave",
+	})
+	client := flutterwave.NewClient("sk_test", url, "")
+	ctx := context.Background()
+
+	charge, err := client.ChargeMobileMoney(ctx, flutterwave.MobileMoneyChargeRequest{
+		Reference: "DEP-1", Amount: 10, Currency: "GHS", PhoneNumber: "0240000000", Network: "MTN",
+	}, "t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if charge.Status != "pending" || charge.TxRef != "DEP-1" || charge.Authorization == nil {
+		t.Fatalf("unexpected charge %+v", charge)
+	}
+
+	if code, err := mock.CompleteCharge("DEP-1", true); err != nil || code != http.StatusOK {
+		t.Fatalf("webhook delivery: code=%d err=%v", code, err)
+	}
+	evt := <-events
+	if evt.Event != "charge.completed" || evt.Data.TxRef != "DEP-1" || evt.Data.Status != "successful" {
+		t.Fatalf("unexpected webhook %+v", evt)
+	}
+	tx, err := client.VerifyTransactionByReference(ctx, "DEP-1", "t")
+	if err != nil || tx.Status != "successful" {
+		t.Fatalf("verify: %+v %v", tx, err)
+	}
+}
+
+func TestWebhookWithWrongSecretIsRejected(t *testing.T) {
+	base, _ := receiver(t, "whsec")
+	mock, url := flutterwavemock.NewTestServer(t, flutterwavemock.Config{
+		WebhookSecret:    "not-the-secret",
+		ChargeWebhookURL: base + "/webhooks/payment/flutterwave",
+	})
+	client := flutterwave.NewClient("sk_test", url, "")
+	if _, err := client.ChargeMobileMoney(context.Background(), flutterwave.MobileMoneyChargeRequest{
+		Reference: "DEP-2", Amount: 5, Currency: "GHS",
+	}, "t"); err != nil {
+		t.Fatal(err)
+	}
+	if code, _ := mock.CompleteCharge("DEP-2", true); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 from the verif-hash check, got %d", code)
+	}
+}
+
+func TestTransferFailureReachesCallbackURL(t *testing.T) {
+	base, events := receiver(t, "whsec")
+	mock, url := flutterwavemock.NewTestServer(t, flutterwavemock.Config{WebhookSecret: "whsec"})
+	client := flutterwave.NewClient("sk_test", url, url)
+	ctx := context.Background()
+
+	transfer, err := client.InitiateTransfer(ctx, flutterwave.TransferRequest{
+		Reference: "WIT-1", Amount: 20, Currency: "GHS", AccountBank: "MTN", AccountNumber: "0240000000",
+		CallbackURL: base + "/webhooks/payment/flutterwave/withdrawal",
+	}, "t")
+	if err != nil || transfer.Status != "NEW" {
+		t.Fatalf("transfer: %+v %v", transfer, err)
+	}
+
+	if _, err := mock.CompleteTransfer("WIT-1", false); err != nil {
+		t.Fatal(err)
+	}
+	evt := <-events
+	if evt.Event != "transfer.completed" || evt.Data.Reference != "WIT-1" || evt.Data.Status != "FAILED" {
+		t.Fatalf("unexpected webhook %+v", evt)
+	}
+	got, err := client.GetTransferByReference(ctx, "WIT-1", "t")
+	if err != nil || got.Status != "FAILED" {
+		t.Fatalf("lookup: %+v %v", got, err)
+	}
+}
+
+func TestAutomaticSettlement(t *testing.T) {
+	base, events := receiver(t, "")
+	_, url := flutterwavemock.NewTestServer(t, flutterwavemock.Config{
+		ChargeWebhookURL: base + "/webhooks/payment/flutterwave",
+		SettleAfter:      10 * time.Millisecond,
+		Outcome:          "failed",
+	})
+	client := flutterwave.NewClient("sk_test", url, "")
+	if _, err := client.ChargeMobileMoney(context.Background(), flutterwave.MobileMoneyChargeRequest{
+		Reference: "DEP-3", Amount: 1, Currency: "GHS",
+	}, "t"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case evt := <-events:
+		if evt.Data.TxRef != "DEP-3" || evt.Data.Status != "failed" {
+			t.Fatalf("unexpected webhook %+v", evt)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no webhook after SettleAfter")
+	}
+}
+
+func TestClientRetriesServerErrors(t *testing.T) {
+	mock, url := flutterwavemock.NewTestServer(t, flutterwavemock.Config{})
+	client := flutterwave.NewClient("sk_test", url, "")
+	ctx := context.Background()
+	if _, err := client.ChargeMobileMoney(ctx, flutterwave.MobileMoneyChargeRequest{
+		Reference: "DEP-4", Amount: 1, Currency: "GHS",
+	}, "t"); err != nil {
+		t.Fatal(err)
+	}
+
+	mock.FailNext(flutterwavemock.RouteVerify, http.StatusBadGateway, "upstream timeout")
+	if _, err := client.VerifyTransactionByReference(ctx, "DEP-4", "t"); err != nil {
+		t.Fatalf("verify should succeed after a retry: %v", err)
+	}
+	if n := len(mock.Requests(flutterwavemock.RouteVerify)); n != 2 {
+		t.Fatalf("expected 2 verify requests, got %d", n)
+	}
+
+	mock.FailNext(flutterwavemock.RouteVerify, http.StatusBadRequest, "bad request")
+	if _, err := client.VerifyTransactionByReference(ctx, "DEP-4", "t"); err == nil {
+		t.Fatal("4xx must not be retried")
+	}
+}
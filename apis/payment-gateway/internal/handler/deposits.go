@@ -0,0 +1,705 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"gamehub/payment-gateway/internal/assets"
+	"gamehub/payment-gateway/internal/outbox"
+	"gamehub/payment-gateway/internal/tatum"
+	"gamehub/payment-gateway/internal/wallet"
+)
+
+// =============================================================================
+// CRYPTO DEPOSIT TRACKING
+// =============================================================================
+//
+// Deposits arrive through Tatum's address webhooks and are recorded with
+// the block that mined them. Their confirmations then advance from block
+// notifications (POST /webhooks/payment/crypto/block, or the chain head
+// poller standing in for them), with no explorer call per deposit. A
+// deposit is checked on chain before it is credited, before it is
+// finalized and whenever a report shows fewer confirmations than before;
+// a transaction gone from the chain was reorged out. Unconfirmed deposits
+// are then REORGED and credited ones REVERSED: the credit is cancelled if
+// still queued, or taken back from the wallet. The explorer poller only
+// backfills addresses with recent activity, for missed webhooks.
+
+// Deposit statuses. A CONFIRMED deposit has been credited and is watched
+// for reorgs until Final, at the asset's finality depth.
+const (
+	depositPending      = "PENDING"
+	depositConfirmed    = "CONFIRMED"
+	depositBelowMinimum = "BELOW_MINIMUM"
+	depositReorged      = "REORGED"
+	depositReversed     = "REVERSED"
+)
+
+// subscriptionRetryAfter spaces out attempts to subscribe an address
+// whose webhook subscription failed.
+const subscriptionRetryAfter = time.Hour
+
+type cryptoDeposit struct {
+	ID            string    `bson:"_id"`
+	UserID        string    `bson:"userId"`
+	Coin          string    `bson:"coin"`
+	Network       string    `bson:"network"`
+	Chain         string    `bson:"chain"`
+	Address       string    `bson:"address"`
+	AmountCrypto  float64   `bson:"amountCrypto"`
+	AmountUsd     float64   `bson:"amountUsd,omitempty"`
+	BlockNumber   int64     `bson:"blockNumber,omitempty"`
+	Confirmations int       `bson:"confirmations"`
+	Status        string    `bson:"status"`
+	Final         bool      `bson:"final,omitempty"`
+	ExplorerURL   string    `bson:"explorerUrl,omitempty"`
+	CreatedAt     time.Time `bson:"createdAt"`
+	UpdatedAt     time.Time `bson:"updatedAt"`
+}
+
+// depositSighting is one report of a deposit, from a webhook or the
+// explorer.
+type depositSighting struct {
+	Hash          string
+	Amount        float64
+	AmountUsd     float64
+	BlockNumber   int64
+	Confirmations int
+}
+
+// openDeposits matches deposits still moving with the chain: pending, or
+// credited but not yet final.
+func openDeposits() bson.M {
+	return bson.M{"$or": bson.A{
+		bson.M{"status": depositPending},
+		bson.M{"status": depositConfirmed, "final": bson.M{"$ne": true}},
+	}}
+}
+
+// CryptoBlockCallback receives new block notifications and advances the
+// confirmations of open deposits on the block's chain.
+// POST /webhooks/payment/crypto/block
+func (h *Handler) CryptoBlockCallback(c *fiber.Ctx) error {
+	var payload struct {
+		Chain       string `json:"chain"`
+		BlockNumber int64  `json:"blockNumber"`
+	}
+	if err := c.BodyParser(&payload); err != nil || payload.Chain == "" || payload.BlockNumber <= 0 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid payload"})
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := h.advanceChain(ctx, tatumChain(payload.Chain), payload.BlockNumber); err != nil {
+		return httpError(c, err)
+	}
+	return c.SendStatus(http.StatusOK)
+}
+
+// tatumChain maps a chain named in a notification to its Tatum path.
+func tatumChain(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	switch name {
+	case "btc":
+		return "bitcoin"
+	case "eth":
+		return "ethereum"
+	case "trx":
+		return "tron"
+	case "matic":
+		return "polygon"
+	}
+	return name
+}
+
+// RunChainHeadWatcher polls the head of each chain with open deposits
+// and advances them, one Tatum call per chain per tick. It stands in for
+// block notifications and is harmless alongside them.
+func (h *Handler) RunChainHeadWatcher(ctx context.Context) {
+	interval := h.cfg.CryptoHeadPollInterval
+	if interval <= 0 || h.tatumClient.ApiKey == "" {
+		return
+	}
+	log.Printf("[crypto-heads] starting with interval=%v", interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.pollChainHeads(ctx)
+		}
+	}
+}
+
+func (h *Handler) pollChainHeads(ctx context.Context) {
+	filter := openDeposits()
+	filter["blockNumber"] = bson.M{"$gt": 0}
+	chains, err := h.db.Collection("crypto_deposits").Distinct(ctx, "chain", filter)
+	if err != nil {
+		log.Printf("[crypto-heads] query error: %v", err)
+		return
+	}
+	for _, v := range chains {
+		chain, _ := v.(string)
+		if chain == "" {
+			continue
+		}
+		head, err := h.tatumClient.CurrentBlock(ctx, chain)
+		if err != nil {
+			log.Printf("[crypto-heads] %s head error: %v", chain, err)
+			continue
+		}
+		if err := h.advanceChain(ctx, chain, head); err != nil {
+			log.Printf("[crypto-heads] %s advance error: %v", chain, err)
+		}
+	}
+}
+
+// advanceChain records head as chain's latest block and moves every open
+// deposit mined on it forward.
+func (h *Handler) advanceChain(ctx context.Context, chain string, head int64) error {
+	_, err := h.db.Collection("chain_heads").UpdateByID(ctx, chain,
+		bson.M{"$max": bson.M{"height": head}, "$set": bson.M{"updatedAt": time.Now()}},
+		options.Update().SetUpsert(true))
+	if err != nil {
+		return err
+	}
+
+	filter := openDeposits()
+	filter["chain"] = chain
+	filter["blockNumber"] = bson.M{"$gt": 0, "$lte": head}
+	cursor, err := h.db.Collection("crypto_deposits").Find(ctx, filter)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var d cryptoDeposit
+		if err := cursor.Decode(&d); err != nil {
+			log.Printf("[crypto-heads] decode deposit: %v", err)
+			continue
+		}
+		confirmations := int(head-d.BlockNumber) + 1
+		if confirmations <= d.Confirmations {
+			continue // a late notification
+		}
+		asset, err := h.assets.Lookup(d.Coin, d.Network)
+		if err != nil {
+			log.Printf("[crypto-heads] deposit %s: %v", d.ID, err)
+			continue
+		}
+		if err := h.advanceDeposit(ctx, &d, asset, confirmations); err != nil {
+			log.Printf("[crypto-heads] deposit %s: %v", d.ID, err)
+		}
+	}
+	return cursor.Err()
+}
+
+// chainHead returns the latest block recorded for chain, or 0.
+func (h *Handler) chainHead(ctx context.Context, chain string) int64 {
+	var doc struct {
+		Height int64 `bson:"height"`
+	}
+	if err := h.db.Collection("chain_heads").FindOne(ctx, bson.M{"_id": chain}).Decode(&doc); err != nil {
+		return 0
+	}
+	return doc.Height
+}
+
+// recordDeposit stores a sighting of a deposit to address and moves the
+// deposit on with it. Settled deposits (final, below the minimum or
+// reversed) are left alone; a REORGED one seen again was mined anew and
+// is pending once more.
+func (h *Handler) recordDeposit(ctx context.Context, userID string, asset assets.Asset, address string, s depositSighting) error {
+	coll := h.db.Collection("crypto_deposits")
+	var prev cryptoDeposit
+	err := coll.FindOne(ctx, bson.M{"_id": s.Hash}).Decode(&prev)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return err
+	}
+	found := err == nil
+	switch {
+	case found && prev.Status == depositReversed:
+		log.Printf("[crypto-deposit] ⚠️ reversed deposit %s seen on chain again (user=%s); review manually", s.Hash, userID)
+		return nil
+	case found && (prev.Final || prev.Status == depositBelowMinimum):
+		return nil
+	}
+
+	confirmations := s.Confirmations
+	if s.BlockNumber > 0 {
+		if head := h.chainHead(ctx, asset.Chain); head >= s.BlockNumber && int(head-s.BlockNumber)+1 > confirmations {
+			confirmations = int(head-s.BlockNumber) + 1
+		}
+	}
+	// Fewer confirmations than last reported, or another block, means the
+	// chain reorganized under the deposit.
+	suspect := found && prev.Status != depositReorged &&
+		((s.Confirmations > 0 && s.Confirmations < prev.Confirmations) ||
+			(s.BlockNumber > 0 && prev.BlockNumber > 0 && s.BlockNumber != prev.BlockNumber))
+	if found && prev.Status != depositReorged && !suspect && prev.Confirmations > confirmations {
+		confirmations = prev.Confirmations
+	}
+
+	now := time.Now()
+	set := bson.M{
+		"userId":       userID,
+		"coin":         asset.Coin,
+		"network":      asset.Network,
+		"chain":        asset.Chain,
+		"address":      address,
+		"amountCrypto": s.Amount,
+		"explorerUrl":  asset.TxURL(s.Hash),
+		"updatedAt":    now,
+	}
+	if s.AmountUsd > 0 {
+		set["amountUsd"] = s.AmountUsd
+	}
+	if !suspect && s.BlockNumber > 0 {
+		set["blockNumber"] = s.BlockNumber
+	}
+	if !found || prev.Status == depositReorged {
+		set["status"] = depositPending
+		set["confirmations"] = 0
+		if s.Amount < asset.MinDeposit {
+			set["status"] = depositBelowMinimum
+			set["confirmations"] = confirmations
+		}
+	}
+	var d cryptoDeposit
+	err = coll.FindOneAndUpdate(ctx,
+		bson.M{"_id": s.Hash},
+		bson.M{"$set": set, "$setOnInsert": bson.M{"createdAt": now}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&d)
+	if err != nil {
+		return err
+	}
+	if !found || prev.Status == depositReorged {
+		h.touchDepositWallet(ctx, address)
+	}
+	if d.Status == depositBelowMinimum {
+		return nil
+	}
+
+	if suspect {
+		onChain, err := h.depositOnChain(ctx, &d)
+		if err != nil || !onChain {
+			if err == nil {
+				err = h.dropDeposit(ctx, &d)
+			}
+			return err
+		}
+		if head := h.chainHead(ctx, d.Chain); head >= d.BlockNumber {
+			confirmations = int(head-d.BlockNumber) + 1
+		} else {
+			confirmations = s.Confirmations
+		}
+	}
+	return h.advanceDeposit(ctx, &d, asset, confirmations)
+}
+
+// advanceDeposit moves d to confirmations. The transaction is checked on
+// chain before it is credited, at the asset's confirmations, and before
+// it is finalized.
+func (h *Handler) advanceDeposit(ctx context.Context, d *cryptoDeposit, asset assets.Asset, confirmations int) error {
+	coll := h.db.Collection("crypto_deposits")
+	credit := d.Status == depositPending && confirmations >= asset.Confirmations
+	final := !d.Final && confirmations >= asset.Finality
+	if !credit && !final {
+		_, err := coll.UpdateOne(ctx,
+			bson.M{"_id": d.ID, "status": d.Status},
+			bson.M{"$set": bson.M{"confirmations": confirmations, "updatedAt": time.Now()}})
+		return err
+	}
+
+	block := d.BlockNumber
+	onChain, err := h.depositOnChain(ctx, d)
+	if err != nil {
+		return err
+	}
+	if !onChain {
+		return h.dropDeposit(ctx, d)
+	}
+	if block > 0 && d.BlockNumber != block {
+		// Re-mined in another block: count again from there.
+		return nil
+	}
+
+	if credit {
+		if err := h.creditDeposit(ctx, d, confirmations); err != nil {
+			return err
+		}
+	}
+	if final {
+		_, err := coll.UpdateOne(ctx,
+			bson.M{"_id": d.ID, "status": depositConfirmed},
+			bson.M{"$set": bson.M{"final": true, "confirmations": confirmations, "finalAt": time.Now(), "updatedAt": time.Now()}})
+		return err
+	}
+	return nil
+}
+
+// depositOnChain reports whether d's transaction is still mined. One
+// mined in another block is still there: d's block is moved and its
+// confirmations recounted from the recorded chain head.
+func (h *Handler) depositOnChain(ctx context.Context, d *cryptoDeposit) (bool, error) {
+	if h.tatumClient.ApiKey == "" {
+		return true, nil // simulation mode has no chain to ask
+	}
+	chain := d.Chain
+	if chain == "" {
+		a, err := h.assets.Lookup(d.Coin, d.Network)
+		if err != nil {
+			return false, err
+		}
+		chain = a.Chain
+	}
+	info, err := h.tatumClient.TransactionInfo(ctx, chain, d.ID)
+	if err != nil {
+		return false, err
+	}
+	if !info.Found || info.Failed || info.BlockNumber == 0 {
+		return false, nil
+	}
+	if info.BlockNumber != d.BlockNumber {
+		confirmations := 0
+		if head := h.chainHead(ctx, chain); head >= info.BlockNumber {
+			confirmations = int(head-info.BlockNumber) + 1
+		}
+		if _, err := h.db.Collection("crypto_deposits").UpdateByID(ctx, d.ID, bson.M{"$set": bson.M{
+			"chain":         chain,
+			"blockNumber":   info.BlockNumber,
+			"confirmations": confirmations,
+			"updatedAt":     time.Now(),
+		}}); err != nil {
+			return false, err
+		}
+		if d.BlockNumber > 0 {
+			log.Printf("[crypto-deposit] %s moved from block %d to %d", d.ID, d.BlockNumber, info.BlockNumber)
+		}
+		d.BlockNumber, d.Confirmations = info.BlockNumber, confirmations
+	}
+	return true, nil
+}
+
+// creditDeposit marks a pending deposit CONFIRMED and queues its credit
+// in the same transaction, so a deposit is credited once.
+func (h *Handler) creditDeposit(ctx context.Context, d *cryptoDeposit, confirmations int) error {
+	amountUsd := d.AmountUsd
+	if amountUsd <= 0 {
+		amountUsd = d.AmountCrypto // In production, convert via price feed
+	}
+	// Apply deposit fee (house cut) before crediting.
+	fee := amountUsd * h.cfg.DepositFeeRate
+	amountUsd -= fee
+
+	msgID := "credit:" + d.ID
+	credited := false
+	err := h.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		credited = false
+		res, err := h.db.Collection("crypto_deposits").UpdateOne(sc,
+			bson.M{"_id": d.ID, "status": depositPending},
+			bson.M{"$set": bson.M{
+				"status":        depositConfirmed,
+				"confirmations": confirmations,
+				"creditedUsd":   amountUsd,
+				"confirmedAt":   time.Now(),
+				"updatedAt":     time.Now(),
+			}})
+		if err != nil || res.ModifiedCount == 0 {
+			return err // confirmed by another webhook or poll
+		}
+		credited = true
+		return h.enqueueWalletCommand(sc, msgID, topicWalletCredit, walletCommand{
+			Credit: &wallet.CreditRequest{
+				UserID:    d.UserID,
+				AmountUsd: amountUsd,
+				Source:    fmt.Sprintf("CRYPTO_%s", d.Coin),
+				Reference: d.ID,
+			},
+			Notify: bson.M{
+				"type":         "CRYPTO_DEPOSIT_CONFIRMED",
+				"amountCrypto": d.AmountCrypto,
+				"amountUsd":    amountUsd,
+				"coin":         d.Coin,
+				"txHash":       d.ID,
+			},
+		})
+	})
+	if err != nil || !credited {
+		return err
+	}
+	d.Status = depositConfirmed
+	if fee > 0 {
+		log.Printf("[deposit-fee] gross=%.6f fee=%.6f(%.0f%%) net=%.6f user=%s",
+			amountUsd+fee, fee, h.cfg.DepositFeeRate*100, amountUsd, d.UserID)
+	}
+	h.outbox.Dispatch(ctx, msgID)
+	log.Printf("[crypto-deposit] ✓ confirmed user=%s coin=%s amount=%.8f tx=%s (credit queued)", d.UserID, d.Coin, amountUsd, d.ID)
+	return nil
+}
+
+// dropDeposit handles a deposit whose transaction left the chain. A
+// pending one is REORGED. A credited one is REVERSED: its credit is
+// cancelled if it hasn't reached the wallet, or reversed there.
+func (h *Handler) dropDeposit(ctx context.Context, d *cryptoDeposit) error {
+	coll := h.db.Collection("crypto_deposits")
+	now := time.Now()
+	if d.Status == depositPending {
+		res, err := coll.UpdateOne(ctx,
+			bson.M{"_id": d.ID, "status": depositPending},
+			bson.M{"$set": bson.M{"status": depositReorged, "reorgedAt": now, "updatedAt": now}})
+		if err == nil && res.ModifiedCount == 1 {
+			log.Printf("[crypto-deposit] ⚠️ %s left the chain before confirming (user=%s)", d.ID, d.UserID)
+		}
+		return err
+	}
+
+	var msgID string
+	reversed := false
+	err := h.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		msgID, reversed = "", false
+		res, err := coll.UpdateOne(sc,
+			bson.M{"_id": d.ID, "status": depositConfirmed, "final": bson.M{"$ne": true}},
+			bson.M{"$set": bson.M{"status": depositReversed, "reversedAt": now, "updatedAt": now}})
+		if err != nil || res.ModifiedCount == 0 {
+			return err
+		}
+		reversed = true
+		cancelled, err := outbox.Cancel(sc, h.db.Collection("outbox"), "credit:"+d.ID)
+		if err != nil || cancelled {
+			return err
+		}
+		msgID = "reverse:" + d.ID
+		return h.enqueueWalletCommand(sc, msgID, topicWalletReverse, walletCommand{
+			Reverse: &wallet.ReversalRequest{
+				UserID:    d.UserID,
+				Reference: d.ID,
+				Reason:    "chain reorganization",
+			},
+			Notify: bson.M{
+				"type":         "CRYPTO_DEPOSIT_REVERSED",
+				"amountCrypto": d.AmountCrypto,
+				"coin":         d.Coin,
+				"txHash":       d.ID,
+			},
+		})
+	})
+	if err != nil || !reversed {
+		return err
+	}
+	if msgID == "" {
+		log.Printf("[crypto-deposit] ⚠️ %s left the chain; queued credit cancelled (user=%s)", d.ID, d.UserID)
+		return nil
+	}
+	h.outbox.Dispatch(ctx, msgID)
+	log.Printf("[crypto-deposit] ⚠️ %s left the chain after crediting; reversal queued (user=%s)", d.ID, d.UserID)
+	return nil
+}
+
+// touchDepositWallet marks address as recently active, keeping it in the
+// backfill.
+func (h *Handler) touchDepositWallet(ctx context.Context, address string) {
+	if _, err := h.db.Collection("crypto_wallets").UpdateOne(ctx,
+		bson.M{"address": address},
+		bson.M{"$set": bson.M{"lastActivityAt": time.Now()}}); err != nil {
+		log.Printf("[crypto-deposit] touch %s: %v", address, err)
+	}
+}
+
+// subscribeDepositAddress registers address for Tatum's deposit webhook
+// and stores the subscription on the wallet.
+func (h *Handler) subscribeDepositAddress(ctx context.Context, a assets.Asset, address string) error {
+	subID, err := h.tatumClient.CreateAddressSubscription(ctx, a.Chain, address, h.cfg.CryptoWebhookURL)
+	if err != nil {
+		h.db.Collection("crypto_wallets").UpdateOne(ctx,
+			bson.M{"address": address},
+			bson.M{"$set": bson.M{"subscriptionAttemptAt": time.Now(), "subscriptionError": err.Error()}})
+		return err
+	}
+	_, err = h.db.Collection("crypto_wallets").UpdateOne(ctx,
+		bson.M{"address": address},
+		bson.M{
+			"$set":   bson.M{"subscriptionId": subID},
+			"$unset": bson.M{"subscriptionAttemptAt": "", "subscriptionError": ""},
+		})
+	log.Printf("[crypto][address] webhook subscription created for %s/%s → %s", a.ID, address, subID)
+	return err
+}
+
+// =============================================================================
+// BACKGROUND: Crypto Deposit Backfill (polls blockchain explorer)
+// =============================================================================
+
+// RunCryptoDepositWatcher backfills deposits whose webhooks were missed.
+// Each tick it polls the explorer for at most CryptoBackfillLimit
+// addresses, those created or active within CryptoBackfillWindow, and
+// retries webhook subscriptions that failed.
+func (h *Handler) RunCryptoDepositWatcher(ctx context.Context) {
+	interval := time.Duration(h.cfg.CryptoWatcherInterval) * time.Second
+	if interval < 10*time.Second {
+		interval = 60 * time.Second
+	}
+	log.Printf("[crypto-watcher] starting with interval=%v window=%v limit=%d",
+		interval, h.cfg.CryptoBackfillWindow, h.cfg.CryptoBackfillLimit)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.pollCryptoDeposits(ctx)
+			h.resubscribeDepositAddresses(ctx)
+		}
+	}
+}
+
+func (h *Handler) pollCryptoDeposits(ctx context.Context) {
+	since := time.Now().Add(-h.cfg.CryptoBackfillWindow)
+	cursor, err := h.db.Collection("crypto_wallets").Find(ctx,
+		bson.M{
+			"status": "ACTIVE",
+			"$or": bson.A{
+				bson.M{"lastActivityAt": bson.M{"$gte": since}},
+				bson.M{"createdAt": bson.M{"$gte": since}},
+			},
+		},
+		options.Find().
+			SetSort(bson.D{{Key: "lastActivityAt", Value: -1}, {Key: "createdAt", Value: -1}}).
+			SetLimit(h.cfg.CryptoBackfillLimit),
+	)
+	if err != nil {
+		log.Printf("[crypto-watcher] query error: %v", err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var walletDoc bson.M
+		if err := cursor.Decode(&walletDoc); err != nil {
+			continue
+		}
+
+		address, _ := walletDoc["address"].(string)
+		coin, _ := walletDoc["coin"].(string)
+		network, _ := walletDoc["network"].(string)
+		userID := stringID(walletDoc["userId"])
+		if address == "" || coin == "" || userID == "" {
+			continue
+		}
+		asset, err := h.assets.Lookup(coin, network)
+		if err != nil {
+			log.Printf("[crypto-watcher] %s: %v", address, err)
+			continue
+		}
+
+		txs, err := h.tatumClient.GetTransactionsByAddress(ctx, tatumAsset(asset), address)
+		if err != nil {
+			log.Printf("[crypto-watcher] %s/%s tx lookup error: %v", asset.ID, address[:8], err)
+			continue
+		}
+
+		for _, tx := range txs {
+			if tx.Hash == "" {
+				continue
+			}
+			h.processCryptoTx(ctx, userID, asset, address, tx)
+		}
+	}
+}
+
+// resubscribeDepositAddresses subscribes a batch of active addresses that
+// have no deposit webhook, so every address is eventually watched by
+// webhook rather than the backfill.
+func (h *Handler) resubscribeDepositAddresses(ctx context.Context) {
+	if h.cfg.CryptoWebhookURL == "" || h.tatumClient.ApiKey == "" {
+		return
+	}
+	cursor, err := h.db.Collection("crypto_wallets").Find(ctx,
+		bson.M{
+			"status":         "ACTIVE",
+			"subscriptionId": bson.M{"$exists": false},
+			"$or": bson.A{
+				bson.M{"subscriptionAttemptAt": bson.M{"$exists": false}},
+				bson.M{"subscriptionAttemptAt": bson.M{"$lt": time.Now().Add(-subscriptionRetryAfter)}},
+			},
+		},
+		options.Find().SetLimit(50),
+	)
+	if err != nil {
+		log.Printf("[crypto-watcher] subscription query error: %v", err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var walletDoc bson.M
+		if err := cursor.Decode(&walletDoc); err != nil {
+			continue
+		}
+		address, _ := walletDoc["address"].(string)
+		coin, _ := walletDoc["coin"].(string)
+		network, _ := walletDoc["network"].(string)
+		asset, err := h.assets.Lookup(coin, network)
+		if address == "" || err != nil {
+			continue
+		}
+		if err := h.subscribeDepositAddress(ctx, asset, address); err != nil {
+			log.Printf("[crypto-watcher] webhook subscription for %s/%s failed: %v", asset.ID, address, err)
+		}
+	}
+}
+
+func (h *Handler) processCryptoTx(ctx context.Context, userID string, asset assets.Asset, address string, tx tatum.Transaction) {
+	// Sweeps out of the address and gas top-ups into it are not deposits.
+	if tx.From != "" && strings.EqualFold(tx.From, address) {
+		return
+	}
+	if !txAssetMatches(asset, tx) {
+		return
+	}
+	if sweep, err := h.isSweepTx(ctx, tx.Hash); err != nil || sweep {
+		if err != nil {
+			log.Printf("[crypto-watcher] sweep lookup %s error: %v", tx.Hash, err)
+		}
+		return
+	}
+
+	// Parse amount from the transaction
+	amountStr := tx.Amount
+	if amountStr == "" {
+		amountStr = tx.Value
+	}
+	amountCrypto, _ := strconv.ParseFloat(amountStr, 64)
+	if amountCrypto <= 0 {
+		return
+	}
+
+	err := h.recordDeposit(ctx, userID, asset, address, depositSighting{
+		Hash:          tx.Hash,
+		Amount:        amountCrypto,
+		BlockNumber:   tx.BlockNumber,
+		Confirmations: tx.Confirmations,
+	})
+	if err != nil {
+		log.Printf("[crypto-watcher] record deposit %s error: %v", tx.Hash, err)
+	}
+}
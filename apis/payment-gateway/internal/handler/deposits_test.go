@@ -0,0 +1,176 @@
+package handler
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+
+	"gamehub/payment-gateway/internal/tatum"
+	"gamehub/payment-gateway/internal/tatummock"
+)
+
+func ethDeposit(id, status string) *cryptoDeposit {
+	return &cryptoDeposit{
+		ID: id, UserID: "u1", Coin: "ETH", Network: "ERC20", Chain: "ethereum",
+		AmountCrypto: 0.05, AmountUsd: 100, Status: status,
+	}
+}
+
+func TestCreditDepositQueuesCreditInTransaction(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("credit", func(mt *mtest.T) {
+		e := newTestEnv(mt)
+		d := ethDeposit("0xabc", depositPending)
+		mt.AddMockResponses(updated(1), updated(1), ok(), nothingDue())
+
+		if err := e.h.creditDeposit(context.Background(), d, 12); err != nil {
+			mt.Fatal(err)
+		}
+		if d.Status != depositConfirmed {
+			mt.Fatalf("status %s", d.Status)
+		}
+		cmds := sent(mt)
+		confirm := find(cmds, "update", "crypto_deposits")
+		if str(confirm.statement().Lookup("q", "status")) != depositPending {
+			mt.Fatalf("only a pending deposit may be credited: %s", confirm.statement())
+		}
+		msg, cmd := enqueued(mt.T, cmds, "credit:0xabc")
+		sameTransaction(mt.T, cmds, confirm, msg)
+
+		// The house keeps DepositFeeRate before crediting.
+		if cmd.Credit == nil || cmd.Credit.AmountUsd != 95 || cmd.Credit.Reference != "0xabc" {
+			mt.Fatalf("queued %+v", cmd.Credit)
+		}
+		e.deliverQueued(mt.T, "credit:0xabc", cmd)
+		if calls := e.wallet.Calls(); len(calls) != 1 || calls[0].Path != "/internal/ledger/credit" || calls[0].Body["amountUsd"] != 95.0 {
+			mt.Fatalf("wallet calls %+v", calls)
+		}
+	})
+	mt.Run("already credited", func(mt *mtest.T) {
+		e := newTestEnv(mt)
+		// Another webhook confirmed it first: nothing matches, nothing is
+		// queued.
+		mt.AddMockResponses(updated(0), ok())
+		if err := e.h.creditDeposit(context.Background(), ethDeposit("0xabc", depositPending), 12); err != nil {
+			mt.Fatal(err)
+		}
+		if find(sent(mt), "update", "outbox") != nil {
+			mt.Fatal("credit queued twice")
+		}
+	})
+}
+
+func TestDropDeposit(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("pending", func(mt *mtest.T) {
+		e := newTestEnv(mt)
+		mt.AddMockResponses(updated(1))
+		if err := e.h.dropDeposit(context.Background(), ethDeposit("0xabc", depositPending)); err != nil {
+			mt.Fatal(err)
+		}
+		cmds := sent(mt)
+		if len(cmds) != 1 || str(cmds[0].set().Lookup("status")) != depositReorged {
+			mt.Fatalf("commands %+v", cmds)
+		}
+	})
+	mt.Run("credit not yet delivered", func(mt *mtest.T) {
+		e := newTestEnv(mt)
+		// Reverse, cancel the queued credit, commit.
+		mt.AddMockResponses(updated(1), updated(1), ok())
+		if err := e.h.dropDeposit(context.Background(), ethDeposit("0xabc", depositConfirmed)); err != nil {
+			mt.Fatal(err)
+		}
+		cmds := sent(mt)
+		cancel := find(cmds, "update", "outbox")
+		if str(cancel.statement().Lookup("q", "_id")) != "credit:0xabc" || str(cancel.set().Lookup("status")) != "CANCELLED" {
+			mt.Fatalf("cancel %s", cancel.statement())
+		}
+		sameTransaction(mt.T, cmds, find(cmds, "update", "crypto_deposits"), cancel)
+		for _, c := range cmds {
+			if c.Coll == "outbox" && str(c.statement().Lookup("q", "_id")) == "reverse:0xabc" {
+				mt.Fatal("reversal queued for a credit that never reached the wallet")
+			}
+		}
+	})
+	mt.Run("credited", func(mt *mtest.T) {
+		e := newTestEnv(mt)
+		// Reverse, cancel (too late: delivered), queue the reversal,
+		// commit, dispatch.
+		mt.AddMockResponses(updated(1), updated(0), updated(1), ok(), nothingDue())
+		if err := e.h.dropDeposit(context.Background(), ethDeposit("0xabc", depositConfirmed)); err != nil {
+			mt.Fatal(err)
+		}
+		cmds := sent(mt)
+		reverse := find(cmds, "update", "crypto_deposits")
+		q := reverse.statement().Lookup("q").Document()
+		if str(q.Lookup("status")) != depositConfirmed || str(reverse.set().Lookup("status")) != depositReversed {
+			mt.Fatalf("reverse %s", reverse.statement())
+		}
+		// A final deposit is never reversed.
+		if _, err := q.LookupErr("final", "$ne"); err != nil {
+			mt.Fatalf("reverse filter %s", q)
+		}
+		msg, cmd := enqueued(mt.T, cmds, "reverse:0xabc")
+		sameTransaction(mt.T, cmds, reverse, msg)
+
+		e.deliverQueued(mt.T, "reverse:0xabc", cmd)
+		calls := e.wallet.Calls()
+		if len(calls) != 1 || calls[0].Path != "/internal/ledger/reverse-credit" || calls[0].Body["reference"] != "0xabc" {
+			mt.Fatalf("wallet calls %+v", calls)
+		}
+	})
+}
+
+func TestReorgedDepositIsReversed(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	// deposit sends a deposit to the mock chain and returns it as the
+	// gateway recorded it, credited but not yet final.
+	deposit := func(mt *mtest.T) (*testEnv, *tatummock.Server, *cryptoDeposit) {
+		e := newTestEnv(mt)
+		chain, url := tatummock.NewTestServer(mt.T, tatummock.Config{})
+		e.h.tatumClient = tatum.NewClient("test", url, "", "", "", false)
+		chain.MineBlocks(20)
+		tx, err := chain.Deposit("ETH", "0x00000000000000000000000000000000000000bb", 0.05, 100, 12)
+		if err != nil {
+			mt.Fatal(err)
+		}
+		d := ethDeposit(tx.Hash, depositConfirmed)
+		d.BlockNumber, d.Confirmations = tx.BlockNumber, tx.Confirmations
+		return e, chain, d
+	}
+
+	mt.Run("reorged", func(mt *mtest.T) {
+		e, chain, d := deposit(mt)
+		asset, _ := e.h.assets.Lookup("ETH", "ERC20")
+		chain.Reorg(d.ID)
+		mt.AddMockResponses(updated(1), updated(0), updated(1), ok(), nothingDue())
+
+		if err := e.h.advanceDeposit(context.Background(), d, asset, asset.Finality); err != nil {
+			mt.Fatal(err)
+		}
+		cmds := sent(mt)
+		if c := find(cmds, "update", "crypto_deposits"); str(c.set().Lookup("status")) != depositReversed {
+			mt.Fatalf("deposit %s", c.statement())
+		}
+		if _, cmd := enqueued(mt.T, cmds, "reverse:"+d.ID); cmd.Reverse == nil || cmd.Reverse.UserID != "u1" {
+			mt.Fatalf("queued %+v", cmd)
+		}
+	})
+	mt.Run("still mined", func(mt *mtest.T) {
+		e, _, d := deposit(mt)
+		asset, _ := e.h.assets.Lookup("ETH", "ERC20")
+		mt.AddMockResponses(updated(1))
+
+		if err := e.h.advanceDeposit(context.Background(), d, asset, asset.Finality); err != nil {
+			mt.Fatal(err)
+		}
+		cmds := sent(mt)
+		if len(cmds) != 1 {
+			mt.Fatalf("commands %+v", cmds)
+		}
+		if final, _ := cmds[0].set().Lookup("final").BooleanOK(); !final {
+			mt.Fatalf("not made final: %s", cmds[0].statement())
+		}
+	})
+}
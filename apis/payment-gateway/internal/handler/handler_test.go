@@ -0,0 +1,274 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+
+	"gamehub/payment-gateway/internal/config"
+	"gamehub/payment-gateway/internal/flutterwave"
+	"gamehub/payment-gateway/internal/kyc"
+	"gamehub/payment-gateway/internal/limits"
+	"gamehub/payment-gateway/internal/outbox"
+	"gamehub/payment-gateway/internal/payout"
+	"gamehub/payment-gateway/internal/tatum"
+	"gamehub/payment-gateway/internal/wallet"
+)
+
+// The handler tests run against the driver's mock deployment. Each test
+// queues the server's replies in the order the handler sends commands,
+// then checks the commands: what was written, and which writes shared a
+// transaction. Chains, Flutterwave and the wallet service are the fakes
+// and mocks the gateway ships with.
+
+// The BIP-39 test mnemonic, used for every payout network.
+const testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+type testEnv struct {
+	h        *Handler
+	mt       *mtest.T
+	chain    *payout.Fake
+	counters memCounters
+	wallet   *walletRecorder
+}
+
+// newTestEnv returns a handler over mt's mock database. Payouts go to a
+// fake chain signed with testMnemonic, limits are counted in memory and
+// Tatum and Flutterwave are in simulation mode until a test points them
+// at a mock.
+func newTestEnv(mt *mtest.T) *testEnv {
+	t := mt.T
+	t.Helper()
+	recorder := &walletRecorder{}
+	ws := httptest.NewServer(recorder)
+	t.Cleanup(ws.Close)
+
+	velocity, err := limits.ParseVelocityRules("0:deposit=10000/50000/100000,0:withdrawal=10000/50000/100000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	kycLimits, err := kyc.ParseLimits("0=*/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{
+		DepositFeeRate: 0.05,
+		CryptoUSDRates: map[string]float64{"ETH": 2000, "USDT": 1},
+		Limits:         limits.Policy{Velocity: velocity, KYC: kycLimits, GlobalDailyOutflow: 1e9},
+	}
+
+	h := New(mt.DB, redis.NewClient(&redis.Options{Addr: "127.0.0.1:1"}),
+		flutterwave.NewClient("", "", ""), tatum.NewClient("", "", "", "", "", false),
+		wallet.NewHTTPClient(ws.URL, "internal"), nil, cfg)
+
+	signer := payout.NewLocalSigner(false, nil)
+	for _, network := range []string{payout.Bitcoin, payout.Ethereum, payout.Tron} {
+		if err := signer.AddMnemonic(network, testMnemonic); err != nil {
+			t.Fatal(err)
+		}
+	}
+	chain := payout.NewFake()
+	h.payoutSigner = signer
+	h.payout = payout.New(signer, chain, chain, payout.Config{})
+
+	counters := memCounters{}
+	h.limits = limits.NewEngine(cfg.Limits, counters)
+	return &testEnv{h: h, mt: mt, chain: chain, counters: counters, wallet: recorder}
+}
+
+// memCounters keeps limit counters in memory.
+type memCounters map[string]int64
+
+func (m memCounters) Add(_ context.Context, keys []limits.Key, delta int64) ([]int64, error) {
+	out := make([]int64, len(keys))
+	for i, k := range keys {
+		m[k.ID] += delta
+		out[i] = m[k.ID]
+	}
+	return out, nil
+}
+
+func (m memCounters) Get(_ context.Context, keys []limits.Key) ([]int64, error) {
+	out := make([]int64, len(keys))
+	for i, k := range keys {
+		out[i] = m[k.ID]
+	}
+	return out, nil
+}
+
+// total sums every counter; zero once everything reserved is released.
+func (m memCounters) total() int64 {
+	var sum int64
+	for _, v := range m {
+		sum += v
+	}
+	return sum
+}
+
+// walletRecorder stands in for wallet-service's internal ledger API.
+type walletRecorder struct {
+	mu    sync.Mutex
+	calls []walletCall
+}
+
+type walletCall struct {
+	Path string
+	Body map[string]interface{}
+}
+
+func (w *walletRecorder) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	var body map[string]interface{}
+	json.NewDecoder(r.Body).Decode(&body)
+	w.mu.Lock()
+	w.calls = append(w.calls, walletCall{Path: r.URL.Path, Body: body})
+	w.mu.Unlock()
+	rw.Write([]byte("{}"))
+}
+
+func (w *walletRecorder) Calls() []walletCall {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]walletCall(nil), w.calls...)
+}
+
+// ---------------------------------------------------------------------------
+// Mock replies
+// ---------------------------------------------------------------------------
+
+func ok() bson.D { return mtest.CreateSuccessResponse() }
+
+func updated(n int) bson.D {
+	return mtest.CreateSuccessResponse(bson.E{Key: "n", Value: n}, bson.E{Key: "nModified", Value: n})
+}
+
+// found answers a find on coll with doc.
+func found(t *testing.T, coll string, doc interface{}) bson.D {
+	t.Helper()
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var d bson.D
+	if err := bson.Unmarshal(raw, &d); err != nil {
+		t.Fatal(err)
+	}
+	return mtest.CreateCursorResponse(0, "test."+coll, mtest.FirstBatch, d)
+}
+
+// nothingDue answers an outbox claim with no message, as when another
+// relay holds the lease. Delivery is then checked by deliverQueued.
+func nothingDue() bson.D {
+	return bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: nil}}
+}
+
+// ---------------------------------------------------------------------------
+// Sent commands
+// ---------------------------------------------------------------------------
+
+type command struct {
+	Name string
+	Coll string
+	Raw  bson.Raw
+}
+
+func sent(mt *mtest.T) []command {
+	var out []command
+	for _, e := range mt.GetAllStartedEvents() {
+		coll, _ := e.Command.Index(0).Value().StringValueOK()
+		out = append(out, command{Name: e.CommandName, Coll: coll, Raw: e.Command})
+	}
+	return out
+}
+
+// find returns the first command named name on coll, or nil.
+func find(cmds []command, name, coll string) *command {
+	for i := range cmds {
+		if cmds[i].Name == name && cmds[i].Coll == coll {
+			return &cmds[i]
+		}
+	}
+	return nil
+}
+
+// statement returns the first statement of an update command.
+func (c *command) statement() bson.Raw {
+	return c.Raw.Lookup("updates").Array().Index(0).Value().Document()
+}
+
+// set returns the $set of an update command's first statement.
+func (c *command) set() bson.Raw {
+	doc, _ := c.statement().Lookup("u", "$set").DocumentOK()
+	return doc
+}
+
+func str(v bson.RawValue) string {
+	s, _ := v.StringValueOK()
+	return s
+}
+
+// enqueued finds the outbox message queued under id and decodes its
+// wallet command.
+func enqueued(t *testing.T, cmds []command, id string) (*command, walletCommand) {
+	t.Helper()
+	for i := range cmds {
+		c := &cmds[i]
+		if c.Name != "update" || c.Coll != "outbox" || str(c.statement().Lookup("q", "_id")) != id {
+			continue
+		}
+		insert := c.statement().Lookup("u", "$setOnInsert").Document()
+		var cmd walletCommand
+		if err := bson.Unmarshal(insert.Lookup("payload").Document(), &cmd); err != nil {
+			t.Fatal(err)
+		}
+		return c, cmd
+	}
+	t.Fatalf("nothing queued as %s", id)
+	return nil, walletCommand{}
+}
+
+// sameTransaction fails unless every write ran in one transaction that
+// was then committed.
+func sameTransaction(t *testing.T, cmds []command, writes ...*command) {
+	t.Helper()
+	txn := func(c *command) string {
+		if autocommit, ok := c.Raw.Lookup("autocommit").BooleanOK(); !ok || autocommit {
+			return ""
+		}
+		return c.Raw.Lookup("lsid").String() + c.Raw.Lookup("txnNumber").String()
+	}
+	want := txn(writes[0])
+	if want == "" {
+		t.Fatalf("%s %s ran outside a transaction", writes[0].Name, writes[0].Coll)
+	}
+	for _, w := range writes[1:] {
+		if txn(w) != want {
+			t.Fatalf("%s %s ran outside the %s %s transaction", w.Name, w.Coll, writes[0].Name, writes[0].Coll)
+		}
+	}
+	for i := range cmds {
+		if cmds[i].Name == "commitTransaction" && txn(&cmds[i]) == want {
+			return
+		}
+	}
+	t.Fatal("transaction was not committed")
+}
+
+// deliverQueued hands a queued wallet command to the relay's delivery
+// function, as the relay would once it claims the message.
+func (e *testEnv) deliverQueued(t *testing.T, id string, cmd walletCommand) {
+	t.Helper()
+	raw, err := bson.Marshal(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.h.deliverWalletCommand(context.Background(), outbox.Message{ID: id, Payload: raw}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"gamehub/payment-gateway/internal/kyc"
+	"gamehub/payment-gateway/internal/limits"
+)
+
+// =============================================================================
+// TRANSACTION LIMITS
+// =============================================================================
+
+// GetLimits returns the caller's KYC level and what they may still deposit
+// and withdraw: single-transaction bounds, the largest amount that would
+// pass now, and usage of each daily, weekly and monthly cap. ?channel=
+// adds that channel's own caps ("crypto" for crypto withdrawals).
+// GET /api/v1/payments/limits
+func (h *Handler) GetLimits(c *fiber.Ctx) error {
+	userID := c.Locals("userId").(string)
+	channel := normalizeChannel(c.Query("channel"))
+	if strings.EqualFold(c.Query("channel"), limitsCryptoChannel) {
+		channel = limitsCryptoChannel
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	level, err := h.kycLevel(ctx, userID)
+	if err != nil {
+		return httpError(c, err)
+	}
+	limit := h.cfg.KYCLimits.For(level)
+	resp := fiber.Map{
+		"kycLevel":      level,
+		"maxDeposit":    capValue(limit.MaxDeposit),
+		"maxWithdrawal": capValue(limit.MaxWithdrawal),
+	}
+	for _, op := range []string{kyc.Deposit, kyc.Withdrawal} {
+		summary, err := h.limits.Remaining(ctx, userID, level, op, channel)
+		if err != nil {
+			log.Printf("[payments][limits] remaining %s for user=%s: %v", op, userID, err)
+			return c.Status(http.StatusServiceUnavailable).JSON(fiber.Map{"error": "could not load account limits"})
+		}
+		windows := make([]fiber.Map, len(summary.Windows))
+		for i, w := range summary.Windows {
+			windows[i] = fiber.Map{
+				"window":    w.Window,
+				"channel":   w.Channel,
+				"limit":     capValue(w.Limit),
+				"used":      w.Used,
+				"remaining": w.Remaining,
+			}
+		}
+		resp[op] = fiber.Map{
+			"min":       summary.Min,
+			"max":       capValue(summary.Max),
+			"available": capValue(summary.Available),
+			"windows":   windows,
+		}
+	}
+	return c.JSON(resp)
+}
+
+// limitsCryptoChannel is the limits channel of crypto withdrawals.
+const limitsCryptoChannel = "crypto"
+
+// reserveLimits counts req towards the caller's limits, answering 400
+// below the minimum, 503 when global outflow is exhausted and 403 for any
+// other broken limit. It reports whether the request was blocked; the
+// returned error is the handler's result in that case. Callers must
+// releaseLimits if the transaction then doesn't go ahead.
+func (h *Handler) reserveLimits(c *fiber.Ctx, req *limits.Request) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	level, err := h.kycLevel(ctx, req.UserID)
+	if err != nil {
+		log.Printf("[payments][limits] level lookup failed for user=%s: %v", req.UserID, err)
+		return true, c.Status(http.StatusServiceUnavailable).JSON(fiber.Map{"error": "could not verify account limits"})
+	}
+	req.KYCLevel = level
+	req.At = time.Now()
+
+	err = h.limits.Reserve(ctx, *req)
+	if err == nil {
+		return false, nil
+	}
+	var v *limits.Violation
+	if !errors.As(err, &v) {
+		log.Printf("[payments][limits] reserve failed for user=%s: %v", req.UserID, err)
+		return true, c.Status(http.StatusServiceUnavailable).JSON(fiber.Map{"error": "could not verify account limits"})
+	}
+	status := http.StatusForbidden
+	switch v.Code {
+	case limits.CodeBelowMinimum:
+		status = http.StatusBadRequest
+	case limits.CodeGlobal:
+		status = http.StatusServiceUnavailable
+	}
+	log.Printf("[payments][limits] blocked %s user=%s level=%d channel=%s amount=%.2f code=%s window=%s", req.Operation, req.UserID, level, req.Channel, req.Amount, v.Code, v.Window)
+	resp := fiber.Map{
+		"error":     v.Error(),
+		"code":      v.Code,
+		"kycLevel":  level,
+		"limit":     capValue(v.Limit),
+		"remaining": capValue(v.Remaining),
+	}
+	if v.Window != "" {
+		resp["window"] = v.Window
+	}
+	return true, c.Status(status).JSON(resp)
+}
+
+// releaseLimits uncounts a reserved transaction that didn't go ahead.
+func (h *Handler) releaseLimits(ctx context.Context, req limits.Request) {
+	if err := h.limits.Release(ctx, req); err != nil {
+		log.Printf("[payments][limits] ⚠️ release %s user=%s amount=%.2f: %v", req.Operation, req.UserID, req.Amount, err)
+	}
+}
+
+// withdrawalLimitsRequest is the limits request a withdrawal was reserved
+// with, dated like the reservation so it is released from the same periods.
+func withdrawalLimitsRequest(rec *withdrawalRecord) limits.Request {
+	channel := rec.Channel
+	if rec.Coin != "" {
+		channel = limitsCryptoChannel
+	}
+	return limits.Request{UserID: rec.UserID, Operation: kyc.Withdrawal, Channel: channel, Amount: rec.Amount, At: limitsAt(rec.LimitsAt, rec.CreatedAt)}
+}
+
+// limitsAt is when a transaction was counted towards the limits. Records
+// from before limitsAt was stored fall back to their creation time.
+func limitsAt(at, createdAt time.Time) time.Time {
+	if at.IsZero() {
+		return createdAt
+	}
+	return at
+}
+
+// kycLevel reads the verification level auth-service stores on the user
+// when a KYC request is approved. Unknown users are unverified.
+func (h *Handler) kycLevel(ctx context.Context, userID string) (int, error) {
+	oid, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return 0, nil
+	}
+	var user struct {
+		KYCLevel int `bson:"kycLevel"`
+	}
+	err = h.db.Collection("users").FindOne(ctx, bson.M{"_id": oid},
+		options.FindOne().SetProjection(bson.M{"kycLevel": 1}),
+	).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return 0, nil
+	}
+	return user.KYCLevel, err
+}
+
+// capValue renders an uncapped limit as null, since JSON has no infinity.
+func capValue(v float64) interface{} {
+	if math.IsInf(v, 1) {
+		return nil
+	}
+	return v
+}
@@ -21,10 +21,18 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 
+	"gamehub/payment-gateway/internal/assets"
 	"gamehub/payment-gateway/internal/config"
+	"gamehub/payment-gateway/internal/derivation"
 	"gamehub/payment-gateway/internal/flutterwave"
+	"gamehub/payment-gateway/internal/kyc"
+	"gamehub/payment-gateway/internal/limits"
+	"gamehub/payment-gateway/internal/outbox"
+	"gamehub/payment-gateway/internal/payout"
+	"gamehub/payment-gateway/internal/secrets"
 	"gamehub/payment-gateway/internal/tatum"
 	"gamehub/payment-gateway/internal/wallet"
+	"gamehub/payment-gateway/internal/withdrawal"
 )
 
 // Active polling trackers
@@ -36,23 +44,39 @@ type Handler struct {
 	flutterClient *flutterwave.Client
 	tatumClient   *tatum.Client
 	walletClient  *wallet.HTTPClient
+	outbox        *outbox.Relay
+	assets        *assets.Registry
+	derivation    *derivation.Registry
+	payout        *payout.Service
+	payoutSigner  *payout.LocalSigner
+	payoutMu      sync.Mutex // one payout built at a time, so nonces don't collide
+	limits        *limits.Engine
+	kms           secrets.KMS
 	cfg           *config.Config
 }
 
-func New(db *mongo.Database, rdb *redis.Client, fc *flutterwave.Client, tc *tatum.Client, wc *wallet.HTTPClient, cfg *config.Config) *Handler {
-	return &Handler{db: db, rdb: rdb, flutterClient: fc, tatumClient: tc, walletClient: wc, cfg: cfg}
-}
-
-var confirmationThreshold = map[string]int{
-	"BTC":  3,
-	"ETH":  12,
-	"USDT": 1,
-}
-
-var defaultNetworks = map[string]string{
-	"BTC":  "BTC",
-	"ETH":  "ERC20",
-	"USDT": "TRC20",
+// New returns the payment handler. kms seals stored secrets; when nil,
+// mnemonics can't be stored and crypto payouts have no keys.
+func New(db *mongo.Database, rdb *redis.Client, fc *flutterwave.Client, tc *tatum.Client, wc *wallet.HTTPClient, kms secrets.KMS, cfg *config.Config) *Handler {
+	h := &Handler{db: db, rdb: rdb, flutterClient: fc, tatumClient: tc, walletClient: wc, kms: kms, cfg: cfg}
+	h.outbox = outbox.NewRelay(db.Collection("outbox"), h.deliverWalletCommand, cfg.Outbox)
+	h.assets = assets.NewRegistry(db.Collection("crypto_assets"), assets.Builtin(cfg.TatumTestnet, cfg.Payout.USDTTRC20Contract))
+	h.derivation = derivation.NewRegistry(db, int64(cfg.Payout.HotWalletIndex))
+	h.limits = limits.NewEngine(cfg.Limits, limits.NewStore(rdb, db.Collection("limit_counters")))
+
+	// Without a Tatum key payouts go to an in-memory chain, like the
+	// client's simulation mode.
+	h.payoutSigner = payout.NewLocalSigner(cfg.Payout.Testnet, kms)
+	payoutCfg := cfg.Payout
+	payoutCfg.Nonces = payout.NewMongoNonces(db.Collection("payout_nonces"))
+	if tc.ApiKey == "" {
+		chain := payout.NewFake()
+		h.payout = payout.New(h.payoutSigner, chain, chain, payoutCfg)
+	} else {
+		chain := payout.NewTatum(tc)
+		h.payout = payout.New(h.payoutSigner, chain, chain, payoutCfg)
+	}
+	return h
 }
 
 type paymentEvent struct {
@@ -66,25 +90,40 @@ type paymentEvent struct {
 	Phone        string    `bson:"phone,omitempty"`
 	Reference    string    `bson:"reference,omitempty"`
 	ProviderTxID string    `bson:"providerTxId,omitempty"`
+	LimitsAt     time.Time `bson:"limitsAt,omitempty"`
 	CreatedAt    time.Time `bson:"createdAt"`
 	UpdatedAt    time.Time `bson:"updatedAt"`
 	SettledAt    time.Time `bson:"settledAt,omitempty"`
 }
 
 type withdrawalRecord struct {
-	ID                string    `bson:"_id"`
-	UserID            string    `bson:"userId"`
-	Phone             string    `bson:"phone"`
-	Channel           string    `bson:"channel"`
-	Amount            float64   `bson:"amount"`
-	Currency          string    `bson:"currency"`
-	ProviderRef       string    `bson:"providerRef,omitempty"`
-	LegacyPaystackRef string    `bson:"paystackRef,omitempty"`
-	TransferCode      string    `bson:"transferCode,omitempty"`
-	Status            string    `bson:"status"`
-	CreatedAt         time.Time `bson:"createdAt"`
-	UpdatedAt         time.Time `bson:"updatedAt"`
-	SettledAt         time.Time `bson:"settledAt,omitempty"`
+	ID                string               `bson:"_id"`
+	UserID            string               `bson:"userId"`
+	Phone             string               `bson:"phone"`
+	Channel           string               `bson:"channel"`
+	Amount            float64              `bson:"amount"`
+	Currency          string               `bson:"currency"`
+	ProviderRef       string               `bson:"providerRef,omitempty"`
+	LegacyPaystackRef string               `bson:"paystackRef,omitempty"`
+	TransferCode      string               `bson:"transferCode,omitempty"`
+	Coin              string               `bson:"coin,omitempty"`
+	Network           string               `bson:"network,omitempty"`
+	Address           string               `bson:"address,omitempty"`
+	FinalAmount       float64              `bson:"finalAmount,omitempty"`
+	PayoutTxID        string               `bson:"payoutTxId,omitempty"`
+	PayoutNetwork     string               `bson:"payoutNetwork,omitempty"`
+	PayoutFrom        string               `bson:"payoutFrom,omitempty"`
+	PayoutRaw         string               `bson:"payoutRaw,omitempty" json:"-"`
+	DispatchedAt      time.Time            `bson:"dispatchedAt,omitempty"`
+	DispatchError     string               `bson:"dispatchError,omitempty"`
+	PayoutStaleAt     time.Time            `bson:"payoutStaleAt,omitempty"`
+	LimitsAt          time.Time            `bson:"limitsAt,omitempty"`
+	Status            string               `bson:"status"`
+	Version           int64                `bson:"version" json:"-"`
+	Approvals         []withdrawalApproval `bson:"approvals,omitempty" json:"-"`
+	CreatedAt         time.Time            `bson:"createdAt"`
+	UpdatedAt         time.Time            `bson:"updatedAt"`
+	SettledAt         time.Time            `bson:"settledAt,omitempty"`
 }
 
 type cryptoDepositPayload struct {
@@ -92,8 +131,10 @@ type cryptoDepositPayload struct {
 	Address       string  `json:"address"`
 	Coin          string  `json:"coin"`
 	Network       string  `json:"network"`
+	Asset         string  `json:"asset"` // token contract, or the coin symbol
 	AmountCrypto  float64 `json:"amount"`
 	AmountUsd     float64 `json:"amountUsd"`
+	BlockNumber   int64   `json:"blockNumber"`
 	Confirmations int     `json:"confirmations"`
 }
 
@@ -143,6 +184,10 @@ func (h *Handler) InitiateMoMoDeposit(c *fiber.Ctx) error {
 	if amount <= 0 {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "amount must be at least 0.01"})
 	}
+	limitsReq := limits.Request{UserID: userID, Operation: kyc.Deposit, Channel: body.Channel, Amount: amount}
+	if blocked, err := h.reserveLimits(c, &limitsReq); blocked {
+		return err
+	}
 
 	clientRef := fmt.Sprintf("DEP-%s-%d", shortID(userID), time.Now().UnixNano())
 	event := bson.M{
@@ -156,11 +201,13 @@ func (h *Handler) InitiateMoMoDeposit(c *fiber.Ctx) error {
 		"currency":  h.cfg.MoMoDefaultCurrency,
 		"proofUrl":  body.ProofURL,
 		"status":    "PENDING",
+		"limitsAt":  limitsReq.At,
 		"createdAt": time.Now(),
 		"updatedAt": time.Now(),
 	}
 	if _, err := h.db.Collection("payment_events").InsertOne(context.Background(), event); err != nil {
 		log.Printf("[payments][deposit][%s] ERROR InsertOne payment_events: %v", clientRef, err)
+		h.releaseLimits(context.Background(), limitsReq)
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "failed to record payment intent"})
 	}
 	log.Printf("[payments][deposit][%s] user=%s channel=%s amount=%.2f", clientRef, userID, body.Channel, amount)
@@ -188,6 +235,7 @@ func (h *Handler) InitiateMoMoDeposit(c *fiber.Ctx) error {
 			bson.M{"$set": bson.M{"status": "FAILED", "error": err.Error(), "updatedAt": time.Now()}},
 		)
 		log.Printf("[payments][deposit][%s] flutterwave charge failed: %v", clientRef, err)
+		h.releaseLimits(context.Background(), limitsReq)
 		return c.Status(http.StatusBadGateway).JSON(fiber.Map{"error": "could not initiate deposit with provider"})
 	}
 
@@ -250,6 +298,10 @@ func (h *Handler) InitiateMoMoWithdrawal(c *fiber.Ctx) error {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "unsupported channel"})
 	}
 	requestedAmount := roundMoney(body.Amount)
+	limitsReq := limits.Request{UserID: userID, Operation: kyc.Withdrawal, Channel: body.Channel, Amount: requestedAmount}
+	if blocked, err := h.reserveLimits(c, &limitsReq); blocked {
+		return err
+	}
 
 	// Calculate fee
 	feeRate := h.cfg.WithdrawalFeeRate
@@ -262,6 +314,7 @@ func (h *Handler) InitiateMoMoWithdrawal(c *fiber.Ctx) error {
 		WithdrawalID: withdrawalID,
 		AmountUsd:    requestedAmount, // Reserve the FULL amount
 	}); err != nil {
+		h.releaseLimits(context.Background(), limitsReq)
 		return c.Status(http.StatusUnprocessableEntity).JSON(fiber.Map{
 			"error": fmt.Sprintf("insufficient balance or reservation failed: %v", err),
 		})
@@ -279,49 +332,30 @@ func (h *Handler) InitiateMoMoWithdrawal(c *fiber.Ctx) error {
 		"currency":    h.cfg.MoMoDefaultCurrency,
 		"providerRef": clientRef,
 		"paystackRef": clientRef, // legacy compatibility
-		"status":      "PENDING",
+		"status":      withdrawal.Pending,
+		"version":     1,
+		"stateTimes":  bson.M{withdrawal.Pending: time.Now()},
+		"limitsAt":    limitsReq.At,
 		"createdAt":   time.Now(),
 		"updatedAt":   time.Now(),
 	}
 	h.db.Collection("withdrawals").InsertOne(context.Background(), doc)
-	log.Printf("[payments][withdraw][%s] user=%s channel=%s requested=%.2f fee=%.2f final=%.2f (MANUAL)", clientRef, userID, body.Channel, requestedAmount, feeAmount, finalAmount)
+	log.Printf("[payments][withdraw][%s] user=%s channel=%s requested=%.2f fee=%.2f final=%.2f", clientRef, userID, body.Channel, requestedAmount, feeAmount, finalAmount)
 
-	/*  // Automating via Flutterwave disabled for manual processing
+	// Payout happens once the withdrawal is approved, by policy or by a
+	// reviewer (see ApproveWithdrawal).
 	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
 	defer cancel()
-
-	network := networkFromChannel(body.Channel)
-	transfer, err := h.flutterClient.InitiateTransfer(ctx, flutterwave.TransferRequest{
-		Reference:     clientRef,
-		Amount:        finalAmount, // send the remaining back
-		Currency:      h.cfg.MoMoDefaultCurrency,
-		DebitCurrency: h.cfg.MoMoDefaultCurrency,
-		AccountBank:   network,
-		AccountNumber: body.Phone,
-		Narration:     "Glory Grid Wallet Withdrawal",
-		CallbackURL:   h.cfg.FlutterwaveTransferCallback,
-		Beneficiary:   fmt.Sprintf("GH %s", shortID(userID)),
-	}, clientRef)
-	if err != nil {
-		h.walletClient.ReleaseWithdrawal(context.Background(), userID, withdrawalID, false)
-		h.db.Collection("withdrawals").UpdateOne(context.Background(),
-			bson.M{"_id": withdrawalID},
-			bson.M{"$set": bson.M{"status": "FAILED", "error": err.Error(), "updatedAt": time.Now()}},
-		)
-		return c.Status(http.StatusBadGateway).JSON(fiber.Map{"error": "could not initiate withdrawal"})
+	status := h.autoApproveWithdrawal(ctx, withdrawalID)
+	message := "Withdrawal is pending manual verification. Funds will arrive within 1-3 business days."
+	if status != withdrawal.Pending {
+		message = "Withdrawal approved. Funds will arrive shortly."
 	}
-
-	h.db.Collection("withdrawals").UpdateOne(context.Background(),
-		bson.M{"_id": withdrawalID},
-		bson.M{"$set": bson.M{"transferCode": transfer.FlwRef}},
-	)
-	*/
-
 	return c.Status(http.StatusAccepted).JSON(fiber.Map{
 		"withdrawalId": withdrawalID,
 		"reference":    clientRef,
-		"status":       "PENDING",
-		"message":      "Withdrawal is pending manual verification. Funds will arrive within 1-3 business days.",
+		"status":       status,
+		"message":      message,
 	})
 }
 
@@ -345,14 +379,25 @@ func (h *Handler) InitiateCryptoWithdrawal(c *fiber.Ctx) error {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "coin, address, and amount are required"})
 	}
 
-	network := strings.ToUpper(strings.TrimSpace(body.Network))
+	network := payout.NormalizeNetwork(body.Network)
 	if network == "" {
-		network = defaultNetworks[body.Coin]
-		if network == "" {
-			network = "L1" // Default for unknown coins
-		}
+		network = h.defaultNetwork(body.Coin)
+	}
+	// Check the destination before any funds are reserved.
+	if !h.payout.Supports(body.Coin, network) {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("%s withdrawals on %s are not supported", body.Coin, firstNonEmpty(network, "this network")),
+			"code":  "UNSUPPORTED_NETWORK",
+		})
+	}
+	if err := payout.ValidateAddress(network, body.Address, h.cfg.Payout.Testnet); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error(), "code": "INVALID_ADDRESS"})
 	}
 	requestedAmount := roundMoney(body.Amount)
+	limitsReq := limits.Request{UserID: userID, Operation: kyc.Withdrawal, Channel: limitsCryptoChannel, Amount: requestedAmount}
+	if blocked, err := h.reserveLimits(c, &limitsReq); blocked {
+		return err
+	}
 
 	// Calculate fee
 	feeRate := h.cfg.WithdrawalFeeRate
@@ -365,6 +410,7 @@ func (h *Handler) InitiateCryptoWithdrawal(c *fiber.Ctx) error {
 		WithdrawalID: withdrawalID,
 		AmountUsd:    requestedAmount, // Reserve the FULL amount
 	}); err != nil {
+		h.releaseLimits(context.Background(), limitsReq)
 		return c.Status(http.StatusUnprocessableEntity).JSON(fiber.Map{
 			"error": fmt.Sprintf("insufficient balance or reservation failed: %v", err),
 		})
@@ -382,26 +428,35 @@ func (h *Handler) InitiateCryptoWithdrawal(c *fiber.Ctx) error {
 		"finalAmount": finalAmount,
 		"currency":    "USD",
 		"providerRef": clientRef,
-		"status":      "PENDING",
+		"status":      withdrawal.Pending,
+		"version":     1,
+		"stateTimes":  bson.M{withdrawal.Pending: time.Now()},
+		"limitsAt":    limitsReq.At,
 		"createdAt":   time.Now(),
 		"updatedAt":   time.Now(),
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
 	defer cancel()
 
 	if _, err := h.db.Collection("withdrawals").InsertOne(ctx, doc); err != nil {
 		h.walletClient.ReleaseWithdrawal(context.Background(), userID, withdrawalID, false)
+		h.releaseLimits(context.Background(), limitsReq)
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "failed to record withdrawal"})
 	}
 
-	log.Printf("[payments][withdraw][%s] crypto user=%s coin=%s address=%s requested=%.2f fee=%.2f final=%.2f (MANUAL)", clientRef, userID, body.Coin, body.Address, requestedAmount, feeAmount, finalAmount)
+	log.Printf("[payments][withdraw][%s] crypto user=%s coin=%s address=%s requested=%.2f fee=%.2f final=%.2f", clientRef, userID, body.Coin, body.Address, requestedAmount, feeAmount, finalAmount)
 
+	status := h.autoApproveWithdrawal(ctx, withdrawalID)
+	message := "Crypto withdrawal is pending manual verification. Funds will arrive shortly after."
+	if status != withdrawal.Pending {
+		message = "Crypto withdrawal approved. Funds will arrive shortly."
+	}
 	return c.Status(http.StatusAccepted).JSON(fiber.Map{
 		"withdrawalId": withdrawalID,
 		"reference":    clientRef,
-		"status":       "PENDING",
-		"message":      "Crypto withdrawal is pending manual verification. Funds will arrive shortly after.",
+		"status":       status,
+		"message":      message,
 	})
 }
 
@@ -430,8 +485,12 @@ func (h *Handler) FlutterwaveDepositCallback(c *fiber.Ctx) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	// charge.completed is also sent for failed charges; data.status decides.
 	status := strings.ToLower(evt.Data.Status)
-	if status == "successful" || strings.EqualFold(evt.Event, "charge.completed") {
+	if status == "" && strings.EqualFold(evt.Event, "charge.completed") {
+		status = "successful"
+	}
+	if status == "successful" {
 		log.Printf("[payments][deposit][%s] webhook success", ref)
 		if err := h.markMoMoDepositConfirmed(ctx, ref, evt.Data.FlwRef); err != nil {
 			log.Printf("flutterwave webhook confirm failed: %v", err)
@@ -467,8 +526,12 @@ func (h *Handler) FlutterwaveWithdrawalCallback(c *fiber.Ctx) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	// transfer.completed is also sent for failed transfers; data.status decides.
 	status := strings.ToLower(evt.Data.Status)
-	success := status == "successful" || strings.EqualFold(evt.Event, "transfer.completed")
+	if status == "" && strings.EqualFold(evt.Event, "transfer.completed") {
+		status = "successful"
+	}
+	success := status == "successful"
 
 	if success {
 		log.Printf("[payments][withdraw][%s] webhook success", ref)
@@ -549,6 +612,7 @@ func (h *Handler) pollPendingWithdrawals(ctx context.Context) {
 	cutoff := time.Now().Add(-2 * time.Minute)
 	cursor, err := h.db.Collection("withdrawals").Find(ctx, bson.M{
 		"status":    "PROCESSING",
+		"coin":      bson.M{"$in": bson.A{nil, ""}}, // crypto payouts are tracked on chain
 		"createdAt": bson.M{"$lt": cutoff},
 	})
 	if err != nil {
@@ -570,6 +634,15 @@ func (h *Handler) pollPendingWithdrawals(ctx context.Context) {
 			continue
 		}
 		transfer, err := h.flutterClient.GetTransferByReference(ctx, ref, ref)
+		if errors.Is(err, flutterwave.ErrTransferNotFound) && record.DispatchError != "" &&
+			time.Since(record.DispatchedAt) > 2*time.Minute {
+			// The dispatch failed with an unknown outcome and Flutterwave
+			// never created the transfer.
+			if err := h.returnUndispatchedWithdrawal(ctx, &record); err != nil {
+				log.Printf("[payments][withdraw-poller][%s] return to APPROVED failed: %v", ref, err)
+			}
+			continue
+		}
 		if err != nil {
 			log.Printf("[payments][withdraw-poller][%s] verify error: %v", ref, err)
 			continue
@@ -591,18 +664,15 @@ func (h *Handler) pollPendingWithdrawals(ctx context.Context) {
 // CRYPTO DEPOSIT WEBHOOK (Tatum)
 // =============================================================================
 
-// CryptoDepositCallback handles Tatum's webhook when a crypto tx is confirmed.
+// CryptoDepositCallback handles Tatum's address webhook. It records the
+// deposit; confirmations then advance from block notifications.
 // POST /webhooks/payment/crypto
 func (h *Handler) CryptoDepositCallback(c *fiber.Ctx) error {
 	var payload cryptoDepositPayload
 	if err := c.BodyParser(&payload); err != nil {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid payload"})
 	}
-	payload.Coin = strings.ToUpper(strings.TrimSpace(payload.Coin))
-	if payload.Coin == "" {
-		payload.Coin = "USDT"
-	}
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
 
 	var walletDoc bson.M
@@ -618,63 +688,38 @@ func (h *Handler) CryptoDepositCallback(c *fiber.Ctx) error {
 	if userID == "" {
 		return c.SendStatus(http.StatusOK)
 	}
-
-	required := confirmationThreshold[payload.Coin]
-	if required == 0 {
-		required = 3
-	}
-	status := "PENDING"
-	if payload.Confirmations >= required {
-		status = "CONFIRMED"
-	}
-
-	var existing bson.M
-	err = h.db.Collection("crypto_deposits").FindOne(ctx, bson.M{"_id": payload.TxID}).Decode(&existing)
-	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+	// Our own sweeps and gas top-ups touch deposit addresses too.
+	if sweep, err := h.isSweepTx(ctx, payload.TxID); err != nil {
 		return httpError(c, err)
+	} else if sweep {
+		return c.SendStatus(http.StatusOK)
 	}
-	alreadyConfirmed := existing["status"] == "CONFIRMED"
 
-	update := bson.M{
-		"$set": bson.M{
-			"userId":        userID,
-			"coin":          payload.Coin,
-			"network":       payload.Network,
-			"amountCrypto":  payload.AmountCrypto,
-			"amountUsd":     payload.AmountUsd,
-			"confirmations": payload.Confirmations,
-			"status":        status,
-			"updatedAt":     time.Now(),
-		},
-		"$setOnInsert": bson.M{
-			"createdAt": time.Now(),
-		},
+	// Only the wallet's own asset is credited: other tokens sent to the
+	// address, look-alikes using its symbol included, are ignored.
+	coin, _ := walletDoc["coin"].(string)
+	network, _ := walletDoc["network"].(string)
+	asset, err := h.assets.Lookup(coin, network)
+	if err != nil {
+		log.Printf("[crypto-webhook] %s: %v", payload.TxID, err)
+		return c.SendStatus(http.StatusOK)
 	}
-	if _, err := h.db.Collection("crypto_deposits").UpdateByID(ctx, payload.TxID, update, options.Update().SetUpsert(true)); err != nil {
-		return httpError(c, err)
+	if !webhookAssetMatches(asset, payload.Asset) {
+		log.Printf("[crypto-webhook] %s: asset %q is not %s, ignored", payload.TxID, payload.Asset, asset.ID)
+		return c.SendStatus(http.StatusOK)
 	}
+	h.touchDepositWallet(ctx, payload.Address)
 
-	if status == "CONFIRMED" && !alreadyConfirmed {
-		amountUsd := payload.AmountUsd
-		if amountUsd <= 0 {
-			amountUsd = payload.AmountCrypto
-		}
-		if err := h.walletClient.CreditDeposit(ctx, wallet.CreditRequest{
-			UserID:    userID,
-			AmountUsd: amountUsd,
-			Source:    fmt.Sprintf("CRYPTO_%s", payload.Coin),
-			Reference: payload.TxID,
-		}); err != nil {
-			return httpError(c, err)
-		}
-		h.publishPaymentEvent(userID, fiber.Map{
-			"type":      "CRYPTO_DEPOSIT_CONFIRMED",
-			"amountUsd": amountUsd,
-			"coin":      payload.Coin,
-			"txHash":    payload.TxID,
-		})
+	err = h.recordDeposit(ctx, userID, asset, payload.Address, depositSighting{
+		Hash:          payload.TxID,
+		Amount:        payload.AmountCrypto,
+		AmountUsd:     payload.AmountUsd,
+		BlockNumber:   payload.BlockNumber,
+		Confirmations: payload.Confirmations,
+	})
+	if err != nil {
+		return httpError(c, err)
 	}
-
 	return c.SendStatus(http.StatusOK)
 }
 
@@ -777,95 +822,72 @@ func (h *Handler) GenerateCryptoAddress(c *fiber.Ctx) error {
 	if body.Coin == "" {
 		body.Coin = "USDT"
 	}
-	network := strings.ToUpper(strings.TrimSpace(body.Network))
-	if network == "" {
-		network = defaultNetworks[body.Coin]
-		if network == "" {
-			network = "L1"
-		}
+	asset, err := h.assets.Lookup(body.Coin, payout.NormalizeNetwork(body.Network))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error(), "code": "UNSUPPORTED_ASSET"})
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	// --- Get-or-Create: check if the user already has an address for this coin ---
+	// --- Get-or-Create: check if the user already has an address for this coin and network ---
 	var existing bson.M
-	err := h.db.Collection("crypto_wallets").FindOne(ctx, bson.M{
-		"userId": userID,
-		"coin":   body.Coin,
+	err = h.db.Collection("crypto_wallets").FindOne(ctx, bson.M{
+		"userId":  userID,
+		"coin":    asset.Coin,
+		"network": asset.Network,
 	}).Decode(&existing)
 	if err == nil {
 		// Already has one — return it
-		return c.JSON(fiber.Map{
-			"address":   existing["address"],
-			"coin":      existing["coin"],
-			"network":   existing["network"],
-			"createdAt": existing["createdAt"],
-		})
+		return c.JSON(depositWalletResponse(existing, asset))
 	}
 	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
 		return httpError(c, err)
 	}
-
-	// --- Derive next HD wallet index via atomic counter ---
-	counterKey := fmt.Sprintf("crypto_%s", strings.ToLower(body.Coin))
-	counterResult := h.db.Collection("crypto_counters").FindOneAndUpdate(
-		ctx,
-		bson.M{"_id": counterKey},
-		bson.M{"$inc": bson.M{"seq": int64(1)}},
-		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
-	)
-	var counterDoc struct {
-		Seq int64 `bson:"seq"`
-	}
-	if err := counterResult.Decode(&counterDoc); err != nil {
-		return httpError(c, fmt.Errorf("failed to get derivation index: %w", err))
-	}
-	derivationIndex := counterDoc.Seq
-
-	// --- Generate address via Tatum ---
-	address, err := h.tatumClient.GenerateAddress(ctx, body.Coin, derivationIndex)
-	if err != nil {
-		return c.Status(http.StatusBadGateway).JSON(fiber.Map{"error": "address generation failed: " + err.Error()})
+	if asset.Disabled {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("%s deposits on %s are disabled", asset.Coin, asset.Network),
+			"code":  "UNSUPPORTED_ASSET",
+		})
 	}
 
-	doc := bson.M{
-		"userId":          userID,
-		"coin":            body.Coin,
-		"network":         network,
-		"address":         address,
-		"derivationIndex": derivationIndex,
-		"status":          "ACTIVE",
-		"createdAt":       time.Now(),
+	// --- Reserve an HD index, derive the address via Tatum, store the wallet ---
+	doc, created, err := h.createDepositWallet(ctx, userID, asset)
+	if errors.Is(err, errAddressGeneration) {
+		return c.Status(http.StatusBadGateway).JSON(fiber.Map{"error": err.Error()})
 	}
-	if _, err := h.db.Collection("crypto_wallets").InsertOne(ctx, doc); err != nil {
+	if err != nil {
 		return httpError(c, err)
 	}
+	address, _ := doc["address"].(string)
 
 	// --- Register Tatum webhook subscription for this address (best-effort) ---
-	if h.cfg.CryptoWebhookURL != "" {
+	// The backfill poller retries failed subscriptions.
+	if created && h.cfg.CryptoWebhookURL != "" {
 		go func() {
 			subCtx, subCancel := context.WithTimeout(context.Background(), 10*time.Second)
 			defer subCancel()
-			subID, err := h.tatumClient.CreateAddressSubscription(subCtx, body.Coin, address, h.cfg.CryptoWebhookURL)
-			if err != nil {
-				log.Printf("[crypto][address] webhook subscription failed for %s/%s: %v", body.Coin, address, err)
-				return
+			if err := h.subscribeDepositAddress(subCtx, asset, address); err != nil {
+				log.Printf("[crypto][address] webhook subscription failed for %s/%s: %v", asset.ID, address, err)
 			}
-			h.db.Collection("crypto_wallets").UpdateOne(context.Background(),
-				bson.M{"address": address},
-				bson.M{"$set": bson.M{"subscriptionId": subID}},
-			)
-			log.Printf("[crypto][address] webhook subscription created for %s/%s → %s", body.Coin, address, subID)
 		}()
 	}
 
-	return c.JSON(fiber.Map{
-		"address":   address,
-		"coin":      body.Coin,
-		"network":   network,
-		"createdAt": doc["createdAt"],
-	})
+	return c.JSON(depositWalletResponse(doc, asset))
+}
+
+// depositWalletResponse describes a deposit wallet and what it accepts.
+func depositWalletResponse(doc bson.M, a assets.Asset) fiber.Map {
+	return fiber.Map{
+		"address":       doc["address"],
+		"coin":          doc["coin"],
+		"network":       doc["network"],
+		"chain":         a.Chain,
+		"contract":      a.Contract,
+		"confirmations": a.Confirmations,
+		"minDeposit":    a.MinDeposit,
+		"createdAt":     doc["createdAt"],
+	}
 }
 
 func (h *Handler) GenerateAllCryptoWallets(c *fiber.Ctx) error {
@@ -876,86 +898,46 @@ func (h *Handler) GenerateAllCryptoWallets(c *fiber.Ctx) error {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid payload or missing userId"})
 	}
 
-	coins := []string{"BTC", "ETH", "USDT"}
 	results := make(map[string]interface{})
 
+	// One wallet per coin, on its default network.
 	// Fire them off synchronously; they are fast enough and happen in background anyway from auth's perspective.
-	for _, coin := range coins {
+	for _, asset := range h.assets.Defaults() {
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
 
-		network := defaultNetworks[coin]
-		if network == "" {
-			network = "L1"
-		}
-
 		// Check if exists
 		var existing bson.M
 		err := h.db.Collection("crypto_wallets").FindOne(ctx, bson.M{
-			"userId": body.UserID,
-			"coin":   coin,
+			"userId":  body.UserID,
+			"coin":    asset.Coin,
+			"network": asset.Network,
 		}).Decode(&existing)
 		if err == nil {
-			results[coin] = existing["address"]
-			continue
-		}
-
-		// Derive next HD index
-		counterKey := fmt.Sprintf("crypto_%s", strings.ToLower(coin))
-		counterResult := h.db.Collection("crypto_counters").FindOneAndUpdate(
-			ctx,
-			bson.M{"_id": counterKey},
-			bson.M{"$inc": bson.M{"seq": int64(1)}},
-			options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
-		)
-		var counterDoc struct {
-			Seq int64 `bson:"seq"`
-		}
-		if err := counterResult.Decode(&counterDoc); err != nil {
-			results[coin] = fmt.Sprintf("error getting derivation index: %v", err)
+			results[asset.Coin] = existing["address"]
 			continue
 		}
-		derivationIndex := counterDoc.Seq
 
-		// Generate via Tatum
-		address, err := h.tatumClient.GenerateAddress(ctx, coin, derivationIndex)
+		// Reserve an HD index, derive via Tatum, store the wallet
+		doc, created, err := h.createDepositWallet(ctx, body.UserID, asset)
 		if err != nil {
-			results[coin] = fmt.Sprintf("tatum error: %v", err)
-			continue
-		}
-
-		doc := bson.M{
-			"userId":          body.UserID,
-			"coin":            coin,
-			"network":         network,
-			"address":         address,
-			"derivationIndex": derivationIndex,
-			"status":          "ACTIVE",
-			"createdAt":       time.Now(),
-		}
-		if _, err := h.db.Collection("crypto_wallets").InsertOne(ctx, doc); err != nil {
-			results[coin] = fmt.Sprintf("db insert error: %v", err)
+			results[asset.Coin] = err.Error()
 			continue
 		}
+		address, _ := doc["address"].(string)
 
 		// Best-effort webhook
-		if h.cfg.CryptoWebhookURL != "" {
-			go func(c string, a string) {
+		if created && h.cfg.CryptoWebhookURL != "" {
+			go func(a assets.Asset, address string) {
 				subCtx, subCancel := context.WithTimeout(context.Background(), 10*time.Second)
 				defer subCancel()
-				subID, err := h.tatumClient.CreateAddressSubscription(subCtx, c, a, h.cfg.CryptoWebhookURL)
-				if err != nil {
-					log.Printf("[crypto][generate-all] webhook sub failed for %s/%s: %v", c, a, err)
-					return
+				if err := h.subscribeDepositAddress(subCtx, a, address); err != nil {
+					log.Printf("[crypto][generate-all] webhook sub failed for %s/%s: %v", a.ID, address, err)
 				}
-				h.db.Collection("crypto_wallets").UpdateOne(context.Background(),
-					bson.M{"address": a},
-					bson.M{"$set": bson.M{"subscriptionId": subID}},
-				)
-			}(coin, address)
+			}(asset, address)
 		}
 
-		results[coin] = address
+		results[asset.Coin] = address
 	}
 
 	return c.JSON(fiber.Map{
@@ -964,6 +946,53 @@ func (h *Handler) GenerateAllCryptoWallets(c *fiber.Ctx) error {
 	})
 }
 
+// errAddressGeneration marks Tatum failures deriving a deposit address.
+var errAddressGeneration = errors.New("address generation failed")
+
+// createDepositWallet reserves a derivation index for the user from the
+// asset's sequence, derives the address at it through Tatum and stores
+// the wallet, committing the index in the same transaction. If a
+// concurrent request stored the user's wallet first, that wallet is
+// returned with created false.
+func (h *Handler) createDepositWallet(ctx context.Context, userID string, a assets.Asset) (bson.M, bool, error) {
+	entry, err := h.derivation.Reserve(ctx, a.Sequence(), userID+"/"+a.ID)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to reserve derivation index: %w", err)
+	}
+	address, err := h.tatumClient.GenerateAddress(ctx, a.Chain, entry.Index)
+	if err != nil {
+		return nil, false, fmt.Errorf("%w: %v", errAddressGeneration, err)
+	}
+
+	doc := bson.M{
+		"userId":          userID,
+		"coin":            a.Coin,
+		"network":         a.Network,
+		"chain":           a.Chain,
+		"address":         address,
+		"derivationIndex": entry.Index,
+		"status":          "ACTIVE",
+		"createdAt":       time.Now(),
+	}
+	err = h.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		if err := h.derivation.Commit(sc, entry, address); err != nil {
+			return err
+		}
+		_, err := h.db.Collection("crypto_wallets").InsertOne(sc, doc)
+		return err
+	})
+	if errors.Is(err, derivation.ErrNotReserved) || mongo.IsDuplicateKeyError(err) {
+		var existing bson.M
+		if h.db.Collection("crypto_wallets").FindOne(ctx, bson.M{"userId": userID, "coin": a.Coin, "network": a.Network}).Decode(&existing) == nil {
+			return existing, false, nil
+		}
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("db insert error: %w", err)
+	}
+	return doc, true, nil
+}
+
 // ManualCryptoCheck allows the frontend to manually ask the backend to scan Tatum for a specific wallet address
 func (h *Handler) ManualCryptoCheck(c *fiber.Ctx) error {
 	var body struct {
@@ -991,7 +1020,13 @@ func (h *Handler) ManualCryptoCheck(c *fiber.Ctx) error {
 	if err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "wallet not found"})
 	}
+	network, _ := walletDoc["network"].(string)
+	asset, err := h.assets.Lookup(body.Coin, network)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error(), "code": "UNSUPPORTED_ASSET"})
+	}
 
+	h.touchDepositWallet(ctx, body.Address)
 	trackingKey := body.Coin + "_" + body.Address
 
 	// 2. Check if already tracking
@@ -1006,7 +1041,7 @@ func (h *Handler) ManualCryptoCheck(c *fiber.Ctx) error {
 	activeCryptoChecks.Store(trackingKey, true)
 
 	// 3. Perform immediate check 0m
-	status, count, err := h.checkAndProcessTxs(userID, body.Coin, body.Address)
+	status, count, err := h.checkAndProcessTxs(userID, asset, body.Address)
 	if err != nil {
 		activeCryptoChecks.Delete(trackingKey)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to contact blockchain explorer"})
@@ -1021,21 +1056,21 @@ func (h *Handler) ManualCryptoCheck(c *fiber.Ctx) error {
 	}
 
 	// 4. Start background polling 1m, 3m
-	go func(coin, address, uid, key string) {
+	go func(asset assets.Asset, address, uid, key string) {
 		defer activeCryptoChecks.Delete(key) // Ensure cleanup when goroutine finishes
 
 		// Wait 1 minute
 		time.Sleep(1 * time.Minute)
-		bgStatus, _, bgErr := h.checkAndProcessTxs(uid, coin, address)
+		bgStatus, _, bgErr := h.checkAndProcessTxs(uid, asset, address)
 		if bgErr == nil && (bgStatus == "CONFIRMED" || bgStatus == "PENDING") {
 			return // Success, terminate early
 		}
 
 		// Wait 3 more minutes (total 4m from start)
 		time.Sleep(3 * time.Minute)
-		_, _, _ = h.checkAndProcessTxs(uid, coin, address)
+		_, _, _ = h.checkAndProcessTxs(uid, asset, address)
 		// Routine exits and defers deletion
-	}(body.Coin, body.Address, userID, trackingKey)
+	}(asset, body.Address, userID, trackingKey)
 
 	// Return to user immediately indicating we are watching it
 	return c.JSON(fiber.Map{
@@ -1044,13 +1079,13 @@ func (h *Handler) ManualCryptoCheck(c *fiber.Ctx) error {
 	})
 }
 
-func (h *Handler) checkAndProcessTxs(userID, coin, address string) (string, int, error) {
+func (h *Handler) checkAndProcessTxs(userID string, asset assets.Asset, address string) (string, int, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	txs, err := h.tatumClient.GetTransactionsByAddress(ctx, coin, address)
+	txs, err := h.tatumClient.GetTransactionsByAddress(ctx, tatumAsset(asset), address)
 	if err != nil {
-		log.Printf("[crypto-check] error checking tatum for %s/%s: %v", coin, address, err)
+		log.Printf("[crypto-check] error checking tatum for %s/%s: %v", asset.ID, address, err)
 		return "NO_TX", 0, err
 	}
 
@@ -1065,13 +1100,9 @@ func (h *Handler) checkAndProcessTxs(userID, coin, address string) (string, int,
 		}
 
 		// Process transaction uniquely
-		h.processCryptoTx(context.Background(), userID, coin, address, tx)
+		h.processCryptoTx(context.Background(), userID, asset, address, tx)
 
-		required := confirmationThreshold[coin]
-		if required == 0 {
-			required = 3
-		}
-		if tx.Confirmations >= required {
+		if tx.Confirmations >= asset.Confirmations {
 			highestStatus = "CONFIRMED"
 		} else if highestStatus != "CONFIRMED" {
 			highestStatus = "PENDING"
@@ -1091,7 +1122,7 @@ func (h *Handler) GetPendingWithdrawals(c *fiber.Ctx) error {
 
 	cursor, err := h.db.Collection("withdrawals").Find(ctx, bson.M{
 		"userId": userID,
-		"status": bson.M{"$in": []string{"PROCESSING", "HELD"}},
+		"status": bson.M{"$in": withdrawal.Open},
 	})
 	if err != nil {
 		return httpError(c, err)
@@ -1142,9 +1173,27 @@ func (h *Handler) isChannelSupported(channel string) bool {
 	return false
 }
 
+// markMoMoDepositConfirmed confirms a pending deposit and queues its wallet
+// credit in one transaction.
 func (h *Handler) markMoMoDepositConfirmed(ctx context.Context, ref, providerTxID string) error {
+	var msgID string
+	err := h.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		var err error
+		msgID, err = h.confirmMoMoDeposit(sc, ref, providerTxID)
+		return err
+	})
+	if err != nil || msgID == "" {
+		return err
+	}
+	h.outbox.Dispatch(ctx, msgID)
+	return nil
+}
+
+// confirmMoMoDeposit runs inside markMoMoDepositConfirmed's transaction
+// and returns the queued message ID, or "" when there is nothing to credit.
+func (h *Handler) confirmMoMoDeposit(sc mongo.SessionContext, ref, providerTxID string) (string, error) {
 	res := h.db.Collection("payment_events").FindOneAndUpdate(
-		ctx,
+		sc,
 		bson.M{"_id": ref, "status": bson.M{"$in": []string{"PENDING", "PROCESSING"}}},
 		bson.M{"$set": bson.M{
 			"status":       "CONFIRMED",
@@ -1157,12 +1206,12 @@ func (h *Handler) markMoMoDepositConfirmed(ctx context.Context, ref, providerTxI
 	var event paymentEvent
 	if err := res.Decode(&event); err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
-			return nil
+			return "", nil
 		}
-		return err
+		return "", err
 	}
 	if event.UserID == "" || event.Amount <= 0 {
-		return nil
+		return "", nil
 	}
 
 	// Apply deposit fee before crediting.
@@ -1174,72 +1223,247 @@ func (h *Handler) markMoMoDepositConfirmed(ctx context.Context, ref, providerTxI
 		creditAmount -= fee
 	}
 
-	if err := h.walletClient.CreditDeposit(ctx, wallet.CreditRequest{
-		UserID:    event.UserID,
-		AmountUsd: creditAmount,
-		Source:    "MOMO_DEPOSIT",
-		Reference: ref,
-	}); err != nil {
-		return err
-	}
-	h.publishPaymentEvent(event.UserID, fiber.Map{
-		"type":      "DEPOSIT_CONFIRMED",
-		"amount":    event.Amount,
-		"reference": ref,
+	msgID := "credit:" + ref
+	return msgID, h.enqueueWalletCommand(sc, msgID, topicWalletCredit, walletCommand{
+		Credit: &wallet.CreditRequest{
+			UserID:    event.UserID,
+			AmountUsd: creditAmount,
+			Source:    "MOMO_DEPOSIT",
+			Reference: ref,
+		},
+		Notify: bson.M{
+			"type":      "DEPOSIT_CONFIRMED",
+			"amount":    event.Amount,
+			"reference": ref,
+		},
 	})
-	return nil
 }
 
+// markMoMoDepositFailed fails a deposit and, if it was still pending,
+// gives its amount back to the user's limits.
 func (h *Handler) markMoMoDepositFailed(ctx context.Context, ref string) error {
-	_, err := h.db.Collection("payment_events").UpdateOne(ctx,
+	var event paymentEvent
+	err := h.db.Collection("payment_events").FindOneAndUpdate(ctx,
 		bson.M{"_id": ref, "status": bson.M{"$ne": "FAILED"}},
 		bson.M{"$set": bson.M{"status": "FAILED", "settledAt": time.Now(), "updatedAt": time.Now()}},
-	)
+	).Decode(&event)
 	if errors.Is(err, mongo.ErrNoDocuments) {
 		return nil
 	}
-	return err
+	if err != nil {
+		return err
+	}
+	if event.Status == "PENDING" {
+		h.releaseLimits(ctx, limits.Request{UserID: event.UserID, Operation: kyc.Deposit, Channel: event.Channel, Amount: event.Amount, At: limitsAt(event.LimitsAt, event.CreatedAt)})
+	}
+	return nil
 }
 
-func (h *Handler) settleWithdrawal(ctx context.Context, providerRef string, success bool) error {
-	filter := bson.M{
-		"$or": []bson.M{
-			{"providerRef": providerRef},
-			{"paystackRef": providerRef},
-		},
+// =============================================================================
+// WALLET COMMAND OUTBOX
+// =============================================================================
+//
+// Credits, their reversals and withdrawal releases are written to the
+// outbox collection in the same transaction as the status change that
+// causes them, then delivered by the relay. The message ID is the
+// idempotency key and matches what the wallet-service dedups on: the
+// ledger reference for credits and reversals and the withdrawal ID for
+// releases.
+
+const (
+	topicWalletCredit  = "wallet.credit"
+	topicWalletRelease = "wallet.release"
+	topicWalletReverse = "wallet.reverse"
+)
+
+type walletCommand struct {
+	Credit  *wallet.CreditRequest   `bson:"credit,omitempty"`
+	Release *walletRelease          `bson:"release,omitempty"`
+	Reverse *wallet.ReversalRequest `bson:"reverse,omitempty"`
+	// Notify is published to the user once the wallet has applied the command.
+	Notify bson.M `bson:"notify,omitempty"`
+}
+
+type walletRelease struct {
+	UserID       string `bson:"userId"`
+	WithdrawalID string `bson:"withdrawalId"`
+	Success      bool   `bson:"success"`
+}
+
+// RunOutboxRelay delivers queued wallet commands until ctx is cancelled.
+func (h *Handler) RunOutboxRelay(ctx context.Context) {
+	h.outbox.Run(ctx)
+}
+
+// GetOutboxStats reports undelivered wallet commands; stuck or dead
+// messages mean money has moved on our side but not in the wallet.
+// GET /internal/outbox/stats
+func (h *Handler) GetOutboxStats(c *fiber.Ctx) error {
+	stats, err := h.outbox.Stats(c.Context())
+	if err != nil {
+		return httpError(c, err)
 	}
-	var rec withdrawalRecord
-	err := h.db.Collection("withdrawals").FindOne(ctx, filter).Decode(&rec)
-	if errors.Is(err, mongo.ErrNoDocuments) {
-		return nil
+	return c.JSON(stats)
+}
+
+// RequeueOutboxMessage retries a dead wallet command from scratch.
+// POST /internal/outbox/:id/requeue
+func (h *Handler) RequeueOutboxMessage(c *fiber.Ctx) error {
+	err := h.outbox.Requeue(c.Context(), c.Params("id"))
+	if errors.Is(err, outbox.ErrNotDead) {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": err.Error()})
 	}
 	if err != nil {
-		return err
-	}
-	if rec.UserID == "" || rec.ID == "" {
-		return nil
+		return httpError(c, err)
 	}
-	if err := h.walletClient.ReleaseWithdrawal(ctx, rec.UserID, rec.ID, success); err != nil {
+	h.outbox.Dispatch(c.Context(), c.Params("id"))
+	return c.JSON(fiber.Map{"id": c.Params("id"), "status": outbox.Pending})
+}
+
+func (h *Handler) enqueueWalletCommand(sc mongo.SessionContext, id, topic string, cmd walletCommand) error {
+	return outbox.Enqueue(sc, h.db.Collection("outbox"), id, topic, cmd)
+}
+
+func (h *Handler) deliverWalletCommand(ctx context.Context, msg outbox.Message) error {
+	var cmd walletCommand
+	if err := msg.Decode(&cmd); err != nil {
 		return err
 	}
-	status := "FAILED"
-	event := "WITHDRAWAL_FAILED"
-	if success {
-		status = "COMPLETED"
-		event = "WITHDRAWAL_COMPLETED"
+	var userID string
+	switch {
+	case cmd.Credit != nil:
+		userID = cmd.Credit.UserID
+		if err := h.walletClient.CreditDeposit(ctx, *cmd.Credit); err != nil {
+			return err
+		}
+	case cmd.Release != nil:
+		userID = cmd.Release.UserID
+		if err := h.walletClient.ReleaseWithdrawal(ctx, userID, cmd.Release.WithdrawalID, cmd.Release.Success); err != nil {
+			return err
+		}
+	case cmd.Reverse != nil:
+		userID = cmd.Reverse.UserID
+		if err := h.walletClient.ReverseCredit(ctx, *cmd.Reverse); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%s: no wallet command in payload", msg.Topic)
 	}
-	_, err = h.db.Collection("withdrawals").UpdateOne(ctx,
-		bson.M{"_id": rec.ID},
-		bson.M{"$set": bson.M{"status": status, "updatedAt": time.Now(), "settledAt": time.Now()}},
-	)
+	if len(cmd.Notify) > 0 {
+		h.publishPaymentEvent(userID, cmd.Notify)
+	}
+	return nil
+}
+
+func (h *Handler) withTransaction(ctx context.Context, fn func(mongo.SessionContext) error) error {
+	session, err := h.db.Client().StartSession()
 	if err != nil {
 		return err
 	}
-	h.publishPaymentEvent(rec.UserID, fiber.Map{
-		"type":   event,
-		"amount": rec.Amount,
+	defer session.EndSession(ctx)
+	return mongo.WithSession(ctx, session, func(sc mongo.SessionContext) error {
+		if err := sc.StartTransaction(); err != nil {
+			return err
+		}
+		if err := fn(sc); err != nil {
+			_ = sc.AbortTransaction(sc)
+			return err
+		}
+		return sc.CommitTransaction(sc)
 	})
-	return nil
+}
+
+// =============================================================================
+// CRYPTO ASSETS
+// =============================================================================
+//
+// The coins and networks accepted for deposit come from the asset
+// registry: built-in BTC, ETH and USDT (TRC-20) plus the crypto_assets
+// collection. Adding a token or chain is a PUT here; deposit addresses,
+// confirmations, minimums and detection follow from the entry.
+
+// LoadCryptoAssets reads the crypto_assets collection into the registry.
+func (h *Handler) LoadCryptoAssets(ctx context.Context) error {
+	return h.assets.Load(ctx)
+}
+
+// RunCryptoAssetReloader reloads the registry every minute, so changes
+// saved through another instance apply here too.
+func (h *Handler) RunCryptoAssetReloader(ctx context.Context) {
+	h.assets.Run(ctx, time.Minute)
+}
+
+// ListCryptoAssets returns the assets deposits are accepted in.
+// GET /api/v1/crypto/assets
+func (h *Handler) ListCryptoAssets(c *fiber.Ctx) error {
+	items := make([]assets.Asset, 0)
+	for _, a := range h.assets.List() {
+		if !a.Disabled {
+			items = append(items, a)
+		}
+	}
+	return c.JSON(fiber.Map{"items": items})
+}
+
+// ListAllCryptoAssets returns every asset, disabled ones included.
+// GET /internal/crypto/assets
+func (h *Handler) ListAllCryptoAssets(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"items": h.assets.List()})
+}
+
+// SaveCryptoAsset adds or replaces an asset, keyed by coin and network.
+// PUT /internal/crypto/assets
+func (h *Handler) SaveCryptoAsset(c *fiber.Ctx) error {
+	var body assets.Asset
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid payload"})
+	}
+	body.Network = payout.NormalizeNetwork(body.Network)
+	if err := body.Validate(); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error(), "code": "INVALID_ASSET"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	saved, err := h.assets.Save(ctx, body)
+	if err != nil {
+		return httpError(c, err)
+	}
+	log.Printf("[assets] saved %s chain=%s contract=%s confirmations=%d disabled=%v",
+		saved.ID, saved.Chain, saved.Contract, saved.Confirmations, saved.Disabled)
+	return c.JSON(saved)
+}
+
+// defaultNetwork returns the network used for coin when a request names
+// none, or "".
+func (h *Handler) defaultNetwork(coin string) string {
+	a, err := h.assets.Lookup(coin, "")
+	if err != nil {
+		return ""
+	}
+	return a.Network
+}
+
+func tatumAsset(a assets.Asset) tatum.Asset {
+	return tatum.Asset{Chain: a.Chain, Contract: a.Contract, Decimals: a.Decimals}
+}
+
+// txAssetMatches reports whether an explorer transaction moves a: the
+// token at its contract, or the native coin when a has none.
+func txAssetMatches(a assets.Asset, tx tatum.Transaction) bool {
+	if a.Contract == "" {
+		return tx.Token == nil || tx.Token.Address == ""
+	}
+	return tx.Token != nil && strings.EqualFold(tx.Token.Address, a.Contract)
+}
+
+// webhookAssetMatches reports whether a webhook's asset field names a.
+// Tatum sends the contract for token transfers and the coin otherwise.
+func webhookAssetMatches(a assets.Asset, field string) bool {
+	if a.Contract != "" {
+		return strings.EqualFold(field, a.Contract)
+	}
+	return field == "" || strings.EqualFold(field, a.Coin)
 }
 
 // =============================================================================
@@ -1267,7 +1491,7 @@ func (h *Handler) SaveCryptoWalletConfig(c *fiber.Ctx) error {
 	}
 
 	if body.Network == "" {
-		body.Network = defaultNetworks[body.Coin]
+		body.Network = h.defaultNetwork(body.Coin)
 		if body.Network == "" {
 			body.Network = "L1"
 		}
@@ -1294,9 +1518,26 @@ func (h *Handler) SaveCryptoWalletConfig(c *fiber.Ctx) error {
 		},
 	}
 
-	// Store mnemonic only if provided
+	// Store mnemonic only if provided, sealed; it is never stored, logged
+	// or returned in plaintext.
 	if body.Mnemonic != "" {
-		update["$set"].(bson.M)["mnemonic"] = body.Mnemonic
+		if h.kms == nil {
+			return c.Status(http.StatusServiceUnavailable).JSON(fiber.Map{
+				"error": "secret storage is not configured",
+				"code":  "SECRETS_UNAVAILABLE",
+			})
+		}
+		// A mistyped mnemonic would seal fine and sign for an empty wallet.
+		if err := payout.ValidateMnemonic(body.Mnemonic); err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error(), "code": "INVALID_MNEMONIC"})
+		}
+		field := secrets.MasterWalletMnemonic
+		sealed, err := secrets.Seal(ctx, h.kms, []byte(body.Mnemonic), field.AAD(body.Coin))
+		if err != nil {
+			return httpError(c, fmt.Errorf("failed to seal mnemonic: %w", err))
+		}
+		update["$set"].(bson.M)[field.SealedName()] = sealed
+		update["$unset"] = bson.M{field.Name: ""}
 	}
 
 	result, err := h.db.Collection("crypto_master_wallets").UpdateByID(
@@ -1314,6 +1555,12 @@ func (h *Handler) SaveCryptoWalletConfig(c *fiber.Ctx) error {
 		action = "created"
 	}
 
+	if body.Mnemonic != "" {
+		if err := h.LoadPayoutKeys(ctx); err != nil {
+			log.Printf("[crypto][config] reloading payout keys failed: %v", err)
+		}
+	}
+
 	log.Printf("[crypto][config] %s master wallet config for %s (xpub=%s...)", action, body.Coin, body.Xpub[:12])
 	return c.JSON(fiber.Map{
 		"status": action,
@@ -1322,13 +1569,21 @@ func (h *Handler) SaveCryptoWalletConfig(c *fiber.Ctx) error {
 }
 
 // GetCryptoWalletConfig retrieves all stored master wallet configs.
-// Mnemonics are masked for safety — only first/last 4 chars shown.
+// Mnemonics are never returned; hasMnemonic and the sealing key version
+// show whether one is stored.
 // GET /internal/crypto/wallets/config
 func (h *Handler) GetCryptoWalletConfig(c *fiber.Ctx) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	cursor, err := h.db.Collection("crypto_master_wallets").Find(ctx, bson.M{})
+	field := secrets.MasterWalletMnemonic
+	sealedName := field.SealedName()
+	cursor, err := h.db.Collection(field.Collection).Find(ctx, bson.M{}, options.Find().SetProjection(bson.M{
+		field.Name:                 0,
+		sealedName + ".wrappedKey": 0,
+		sealedName + ".nonce":      0,
+		sealedName + ".ciphertext": 0,
+	}))
 	if err != nil {
 		return httpError(c, err)
 	}
@@ -1339,15 +1594,8 @@ func (h *Handler) GetCryptoWalletConfig(c *fiber.Ctx) error {
 		return httpError(c, err)
 	}
 
-	// Mask mnemonics for safety
 	for _, cfg := range configs {
-		if mnemonic, ok := cfg["mnemonic"].(string); ok && mnemonic != "" {
-			words := strings.Fields(mnemonic)
-			if len(words) > 4 {
-				cfg["mnemonic"] = fmt.Sprintf("%s %s ... %s %s [%d words]",
-					words[0], words[1], words[len(words)-2], words[len(words)-1], len(words))
-			}
-		}
+		_, cfg["hasMnemonic"] = cfg[sealedName]
 	}
 
 	return c.JSON(fiber.Map{
@@ -1436,169 +1684,3 @@ func firstNonEmpty(values ...string) string {
 	}
 	return ""
 }
-
-// =============================================================================
-// BACKGROUND: Crypto Deposit Watcher (polls blockchain explorer)
-// =============================================================================
-
-// RunCryptoDepositWatcher polls all active crypto wallet addresses for
-// incoming transactions. This is the safety net alongside Tatum webhooks.
-func (h *Handler) RunCryptoDepositWatcher(ctx context.Context) {
-	interval := time.Duration(h.cfg.CryptoWatcherInterval) * time.Second
-	if interval < 10*time.Second {
-		interval = 60 * time.Second
-	}
-	log.Printf("[crypto-watcher] starting with interval=%v", interval)
-
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
-			h.pollCryptoDeposits(ctx)
-		}
-	}
-}
-
-func (h *Handler) pollCryptoDeposits(ctx context.Context) {
-	cursor, err := h.db.Collection("crypto_wallets").Find(ctx, bson.M{
-		"status": "ACTIVE",
-	})
-	if err != nil {
-		log.Printf("[crypto-watcher] query error: %v", err)
-		return
-	}
-	defer cursor.Close(ctx)
-
-	for cursor.Next(ctx) {
-		var walletDoc bson.M
-		if err := cursor.Decode(&walletDoc); err != nil {
-			continue
-		}
-
-		address, _ := walletDoc["address"].(string)
-		coin, _ := walletDoc["coin"].(string)
-		userID := stringID(walletDoc["userId"])
-		if address == "" || coin == "" || userID == "" {
-			continue
-		}
-
-		txs, err := h.tatumClient.GetTransactionsByAddress(ctx, coin, address)
-		if err != nil {
-			log.Printf("[crypto-watcher] %s/%s tx lookup error: %v", coin, address[:8], err)
-			continue
-		}
-
-		for _, tx := range txs {
-			if tx.Hash == "" {
-				continue
-			}
-			h.processCryptoTx(ctx, userID, coin, address, tx)
-		}
-	}
-}
-
-func (h *Handler) processCryptoTx(ctx context.Context, userID, coin, address string, tx tatum.Transaction) {
-	// Parse amount from the transaction
-	amountStr := tx.Amount
-	if amountStr == "" {
-		amountStr = tx.Value
-	}
-	amountCrypto, _ := strconv.ParseFloat(amountStr, 64)
-	if amountCrypto <= 0 {
-		return
-	}
-
-	// Determine confirmation status
-	required := confirmationThreshold[coin]
-	if required == 0 {
-		required = 3
-	}
-	status := "PENDING"
-	if tx.Confirmations >= required {
-		status = "CONFIRMED"
-	}
-
-	update := bson.M{
-		"$set": bson.M{
-			"userId":        userID,
-			"coin":          coin,
-			"address":       address,
-			"amountCrypto":  amountCrypto,
-			"confirmations": tx.Confirmations,
-			"status":        status,
-			"updatedAt":     time.Now(),
-		},
-		"$setOnInsert": bson.M{
-			"createdAt": time.Now(),
-		},
-	}
-
-	if status == "CONFIRMED" {
-		// ATOMIC: FindOneAndUpdate with status guard.
-		// Only matches documents that are NOT yet CONFIRMED.
-		// If another goroutine already confirmed this tx, FindOneAndUpdate
-		// returns ErrNoDocuments and we skip the credit.
-		res := h.db.Collection("crypto_deposits").FindOneAndUpdate(ctx,
-			bson.M{
-				"_id":    tx.Hash,
-				"status": bson.M{"$ne": "CONFIRMED"},
-			},
-			update,
-			options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
-		)
-		var doc bson.M
-		if err := res.Decode(&doc); err != nil {
-			if errors.Is(err, mongo.ErrNoDocuments) {
-				// Already confirmed by another poll cycle or webhook — skip
-				return
-			}
-			log.Printf("[crypto-watcher] atomic upsert deposit %s error: %v", tx.Hash, err)
-			return
-		}
-
-		// Credit the user's wallet — this branch only executes once per tx
-		amountUsd := amountCrypto // In production, convert via price feed
-
-		// Apply deposit fee (house cut) before crediting.
-		if h.cfg.DepositFeeRate > 0 {
-			fee := amountUsd * h.cfg.DepositFeeRate
-			log.Printf("[deposit-fee] gross=%.6f fee=%.6f(%.0f%%) net=%.6f user=%s",
-				amountUsd, fee, h.cfg.DepositFeeRate*100, amountUsd-fee, userID)
-			amountUsd -= fee
-		}
-
-		if err := h.walletClient.CreditDeposit(ctx, wallet.CreditRequest{
-			UserID:    userID,
-			AmountUsd: amountUsd,
-			Source:    fmt.Sprintf("CRYPTO_%s", coin),
-			Reference: tx.Hash,
-		}); err != nil {
-			// Roll back the status so the next poll retries
-			h.db.Collection("crypto_deposits").UpdateByID(ctx, tx.Hash,
-				bson.M{"$set": bson.M{"status": "PENDING", "updatedAt": time.Now()}},
-			)
-			log.Printf("[crypto-watcher] credit deposit %s error (rolled back): %v", tx.Hash, err)
-			return
-		}
-		h.publishPaymentEvent(userID, fiber.Map{
-			"type":         "CRYPTO_DEPOSIT_CONFIRMED",
-			"amountCrypto": amountCrypto,
-			"amountUsd":    amountUsd,
-			"coin":         coin,
-			"txHash":       tx.Hash,
-		})
-		log.Printf("[crypto-watcher] ✓ credited user=%s coin=%s amount=%.8f tx=%s", userID, coin, amountUsd, tx.Hash)
-	} else {
-		// Still pending — just upsert the tracking record
-		if _, err := h.db.Collection("crypto_deposits").UpdateByID(
-			ctx, tx.Hash, update,
-			options.Update().SetUpsert(true),
-		); err != nil {
-			log.Printf("[crypto-watcher] upsert pending deposit %s error: %v", tx.Hash, err)
-		}
-	}
-}
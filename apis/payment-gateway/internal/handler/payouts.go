@@ -0,0 +1,315 @@
+package handler
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"gamehub/payment-gateway/internal/payout"
+	"gamehub/payment-gateway/internal/secrets"
+	"gamehub/payment-gateway/internal/withdrawal"
+)
+
+// =============================================================================
+// CRYPTO PAYOUTS
+// =============================================================================
+//
+// Approved crypto withdrawals are signed with the hot-wallet key derived
+// from the coin's master mnemonic and broadcast through Tatum. The signed
+// transaction is stored on the withdrawal before it is broadcast, so a
+// retry resends the same bytes instead of paying twice. The payout watcher
+// settles the withdrawal once the transaction has enough confirmations.
+
+// payoutStaleAfter flags a payout the chain still doesn't know about for
+// review. Payouts that can no longer be mined are abandoned as soon as the
+// watcher can tell; the rest keep being rebroadcast until a reviewer
+// abandons them.
+const payoutStaleAfter = 6 * time.Hour
+
+// payoutConfirmations is how deep a payout must be before it is final.
+var payoutConfirmations = map[string]int{
+	payout.Bitcoin:  3,
+	payout.Ethereum: 12,
+	payout.Tron:     19,
+}
+
+// canDispatch reports whether rec can be paid out by the gateway rather
+// than by hand.
+func (h *Handler) canDispatch(rec *withdrawalRecord) bool {
+	return rec.Coin == "" || h.payout.Supports(rec.Coin, rec.Network)
+}
+
+// autoPayout reports whether approving rec dispatches it by default.
+func (h *Handler) autoPayout(rec *withdrawalRecord) bool {
+	if rec.Coin == "" {
+		return h.cfg.MoMoAutoPayout
+	}
+	return h.cfg.CryptoAutoPayout && h.canDispatch(rec)
+}
+
+// LoadPayoutKeys hands the sealed mnemonics in crypto_master_wallets to
+// the payout signer, which derives the hot-wallet and deposit address
+// keys. Networks without a mnemonic can't be paid out or swept.
+func (h *Handler) LoadPayoutKeys(ctx context.Context) error {
+	field := secrets.MasterWalletMnemonic
+	coll := h.db.Collection(field.Collection)
+	if n, _ := coll.CountDocuments(ctx, bson.M{field.Name: bson.M{"$exists": true}}); n > 0 {
+		log.Printf("[crypto-payout] %d master wallet mnemonics are stored in plaintext and ignored; run secretsctl rotate", n)
+	}
+	cursor, err := coll.Find(ctx, bson.M{
+		"active":           true,
+		field.SealedName(): bson.M{"$exists": true},
+	})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var doc struct {
+			ID      string          `bson:"_id"`
+			Coin    string          `bson:"coin"`
+			Network string          `bson:"network"`
+			Sealed  *secrets.Sealed `bson:"mnemonicSealed"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			continue
+		}
+		network := payout.NormalizeNetwork(firstNonEmpty(doc.Network, h.defaultNetwork(doc.Coin)))
+		// ERC20 tokens are sent from the ETH wallet.
+		if doc.Coin != "ETH" && network == payout.Ethereum {
+			continue
+		}
+		if err := h.payoutSigner.AddSealedMnemonic(ctx, network, doc.Sealed, field.AAD(doc.ID)); err != nil {
+			log.Printf("[crypto-payout] %s key not loaded: %v", doc.Coin, err)
+			continue
+		}
+		address, _ := h.payout.HotWallet(ctx, network)
+		log.Printf("[crypto-payout] hot wallet %s %s", network, address)
+	}
+	return cursor.Err()
+}
+
+// dispatchCryptoPayout signs rec's transfer, stores it on the withdrawal
+// and broadcasts it. An error means nothing was stored and rec can go back
+// to APPROVED. Once stored, a failed broadcast is left to the payout
+// watcher, which resends the same transaction; its Ethereum nonce stays
+// taken, so later payouts don't sign over it.
+func (h *Handler) dispatchCryptoPayout(ctx context.Context, rec *withdrawalRecord) error {
+	tx, err := storedPayout(rec)
+	if err != nil {
+		return err
+	}
+	if tx == nil {
+		if tx, err = h.signPayout(ctx, rec); err != nil {
+			return err
+		}
+	}
+	if err := h.payout.Broadcast(ctx, tx); err != nil {
+		log.Printf("[payments][withdraw][%s] broadcast %s failed, will retry: %v", rec.ID, tx.TxID, err)
+		h.db.Collection("withdrawals").UpdateOne(ctx, bson.M{"_id": rec.ID}, bson.M{"$set": bson.M{"dispatchError": err.Error()}})
+		return nil
+	}
+	log.Printf("[payments][withdraw][%s] broadcast %s %s to %s", rec.ID, tx.Network, tx.TxID, rec.Address)
+	return nil
+}
+
+// signPayout builds and signs rec's transaction and stores it on rec.
+func (h *Handler) signPayout(ctx context.Context, rec *withdrawalRecord) (*payout.SignedTx, error) {
+	amount := rec.FinalAmount
+	if amount <= 0 {
+		amount = rec.Amount
+	}
+	rate := h.cfg.CryptoUSDRates[rec.Coin]
+	if rate <= 0 {
+		return nil, fmt.Errorf("no USD rate configured for %s", rec.Coin)
+	}
+	if rec.Address == "" {
+		return nil, fmt.Errorf("withdrawal %s is missing payout details", rec.ID)
+	}
+
+	h.payoutMu.Lock()
+	defer h.payoutMu.Unlock()
+	tx, err := h.payout.Build(ctx, payout.Transfer{
+		Reference: rec.ID,
+		Coin:      rec.Coin,
+		Network:   rec.Network,
+		To:        rec.Address,
+		Amount:    amount / rate,
+	})
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	result, err := h.db.Collection("withdrawals").UpdateOne(ctx,
+		bson.M{"_id": rec.ID, "status": withdrawal.Processing, "payoutTxId": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{
+			"payoutTxId":    tx.TxID,
+			"payoutNetwork": tx.Network,
+			"payoutFrom":    tx.From,
+			"payoutRaw":     tx.RawHex(),
+			"payoutAmount":  amount / rate,
+			"payoutRate":    rate,
+			"dispatchedAt":  now,
+		}},
+	)
+	if err != nil {
+		return nil, err
+	}
+	if result.MatchedCount == 0 {
+		return nil, fmt.Errorf("withdrawal %s already has a payout transaction", rec.ID)
+	}
+	rec.PayoutTxID, rec.PayoutNetwork, rec.PayoutFrom, rec.PayoutRaw = tx.TxID, tx.Network, tx.From, tx.RawHex()
+	rec.DispatchedAt = now
+	return tx, nil
+}
+
+// storedPayout returns the transaction already signed for rec, if any.
+func storedPayout(rec *withdrawalRecord) (*payout.SignedTx, error) {
+	if rec.PayoutTxID == "" {
+		return nil, nil
+	}
+	raw, err := hex.DecodeString(rec.PayoutRaw)
+	if err != nil {
+		return nil, fmt.Errorf("withdrawal %s has a corrupt payout transaction: %w", rec.ID, err)
+	}
+	return &payout.SignedTx{Network: rec.PayoutNetwork, From: rec.PayoutFrom, TxID: rec.PayoutTxID, Raw: raw}, nil
+}
+
+// RunCryptoPayoutWatcher follows broadcast payouts until they are final,
+// settling confirmed ones, failing reverted ones and rebroadcasting any
+// the chain doesn't know about.
+func (h *Handler) RunCryptoPayoutWatcher(ctx context.Context) {
+	interval := time.Duration(h.cfg.CryptoWatcherInterval) * time.Second
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.checkCryptoPayouts(ctx)
+		}
+	}
+}
+
+func (h *Handler) checkCryptoPayouts(ctx context.Context) {
+	cursor, err := h.db.Collection("withdrawals").Find(ctx, bson.M{
+		"status":     withdrawal.Processing,
+		"payoutTxId": bson.M{"$exists": true},
+	})
+	if err != nil {
+		log.Printf("[crypto-payout] query error: %v", err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var rec withdrawalRecord
+		if err := cursor.Decode(&rec); err != nil {
+			continue
+		}
+		if err := h.checkCryptoPayout(ctx, &rec); err != nil {
+			log.Printf("[payments][withdraw][%s] payout %s check failed: %v", rec.ID, rec.PayoutTxID, err)
+		}
+	}
+}
+
+func (h *Handler) checkCryptoPayout(ctx context.Context, rec *withdrawalRecord) error {
+	status, err := h.payout.Status(ctx, rec.PayoutNetwork, rec.PayoutTxID)
+	if err != nil {
+		return err
+	}
+	switch {
+	case !status.Found:
+		return h.payoutNotFound(ctx, rec)
+	case status.Failed:
+		reason := "payout transaction failed on chain"
+		return h.closeWithdrawal(ctx, rec, withdrawal.Failed, bson.M{"failureReason": reason},
+			withdrawalAudit{Action: "payout_failed", Actor: "system", Reason: reason, TxHash: rec.PayoutTxID})
+	case status.Confirmations >= payoutConfirmations[rec.PayoutNetwork]:
+		log.Printf("[payments][withdraw][%s] payout %s confirmed (%d)", rec.ID, rec.PayoutTxID, status.Confirmations)
+		return h.closeWithdrawal(ctx, rec, withdrawal.Completed, bson.M{"externalTxHash": rec.PayoutTxID},
+			withdrawalAudit{Action: "payout_confirmed", Actor: "system", TxHash: rec.PayoutTxID})
+	}
+	_, err = h.db.Collection("withdrawals").UpdateOne(ctx,
+		bson.M{"_id": rec.ID},
+		bson.M{"$set": bson.M{"payoutConfirmations": status.Confirmations}},
+	)
+	return err
+}
+
+// payoutNotFound handles a payout the chain doesn't know about. One that
+// can no longer be mined is abandoned; the rest are rebroadcast, and
+// flagged for review once missing for payoutStaleAfter.
+func (h *Handler) payoutNotFound(ctx context.Context, rec *withdrawalRecord) error {
+	tx, err := storedPayout(rec)
+	if err != nil {
+		return err
+	}
+	dropped, err := h.payout.Dropped(ctx, tx)
+	if err != nil {
+		return err
+	}
+	if dropped {
+		return h.abandonPayout(ctx, rec, "system", rec.PayoutTxID+" can no longer be mined")
+	}
+	if rec.PayoutStaleAt.IsZero() && time.Since(rec.DispatchedAt) > payoutStaleAfter {
+		reason := fmt.Sprintf("%s not on chain after %s", rec.PayoutTxID, payoutStaleAfter)
+		log.Printf("[payments][withdraw][%s] ⚠️ payout stale: %s; abandon it once it can't land", rec.ID, reason)
+		if _, err := h.db.Collection("withdrawals").UpdateOne(ctx,
+			bson.M{"_id": rec.ID},
+			bson.M{"$set": bson.M{"payoutStaleAt": time.Now()}},
+		); err != nil {
+			return err
+		}
+		h.recordWithdrawalAudit(ctx, withdrawalAudit{
+			WithdrawalID: rec.ID, Action: "payout_stale", Actor: "system",
+			FromStatus: rec.Status, ToStatus: rec.Status, Reason: reason, TxHash: rec.PayoutTxID,
+		})
+	}
+	log.Printf("[payments][withdraw][%s] payout %s not on chain, rebroadcasting", rec.ID, rec.PayoutTxID)
+	return h.payout.Broadcast(ctx, tx)
+}
+
+// abandonPayout gives up on rec's payout transaction and returns rec to
+// APPROVED, with reason as its dispatch error, to be dispatched again or
+// rejected. The transaction is kept under abandonedPayouts.
+func (h *Handler) abandonPayout(ctx context.Context, rec *withdrawalRecord, actor, reason string) error {
+	from, txID := rec.Status, rec.PayoutTxID
+	err := h.transitionWithdrawal(ctx, rec, withdrawal.Approved, bson.M{"dispatchError": reason}, bson.M{
+		"$unset": bson.M{
+			"payoutTxId": "", "payoutNetwork": "", "payoutFrom": "", "payoutRaw": "",
+			"payoutAmount": "", "payoutRate": "", "payoutConfirmations": "",
+			"payoutStaleAt": "", "dispatchedAt": "",
+		},
+		"$push": bson.M{"abandonedPayouts": bson.M{
+			"txId":    txID,
+			"network": rec.PayoutNetwork,
+			"from":    rec.PayoutFrom,
+			"raw":     rec.PayoutRaw,
+			"reason":  reason,
+			"actor":   actor,
+			"at":      time.Now(),
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	rec.PayoutTxID, rec.PayoutNetwork, rec.PayoutFrom, rec.PayoutRaw = "", "", "", ""
+	rec.DispatchedAt, rec.PayoutStaleAt = time.Time{}, time.Time{}
+	h.recordWithdrawalAudit(ctx, withdrawalAudit{
+		WithdrawalID: rec.ID, Action: "payout_abandoned", Actor: actor,
+		FromStatus: from, ToStatus: rec.Status, Reason: reason, TxHash: txID,
+	})
+	log.Printf("[payments][withdraw][%s] ⚠️ payout %s abandoned by %s: %s", rec.ID, txID, actor, reason)
+	return nil
+}
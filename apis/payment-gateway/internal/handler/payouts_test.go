@@ -0,0 +1,253 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+
+	"gamehub/payment-gateway/internal/payout"
+	"gamehub/payment-gateway/internal/withdrawal"
+)
+
+const payoutAddress = "0x00000000000000000000000000000000000000aa"
+
+func cryptoWithdrawal(status string) *withdrawalRecord {
+	created := time.Now().Add(-time.Hour)
+	return &withdrawalRecord{
+		ID: "w1", UserID: "u1", Coin: "ETH", Network: "ERC20", Address: payoutAddress,
+		Amount: 100, Currency: "USD", Status: status, Version: 2,
+		CreatedAt: created, LimitsAt: created,
+	}
+}
+
+// signedPayout gives rec a payout transaction as signPayout stores it.
+func (e *testEnv) signedPayout(t *testing.T, rec *withdrawalRecord) *payout.SignedTx {
+	t.Helper()
+	tx, err := e.h.payout.Build(context.Background(), payout.Transfer{
+		Reference: rec.ID, Coin: rec.Coin, Network: rec.Network, To: rec.Address, Amount: 0.05,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.PayoutTxID, rec.PayoutNetwork, rec.PayoutFrom, rec.PayoutRaw = tx.TxID, tx.Network, tx.From, tx.RawHex()
+	rec.DispatchedAt = time.Now()
+	return tx
+}
+
+func TestApproveSignsStoresThenBroadcasts(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("dispatch", func(mt *mtest.T) {
+		e := newTestEnv(mt)
+		rec := cryptoWithdrawal(withdrawal.Approved)
+		mt.AddMockResponses(updated(1), ok(), updated(1))
+
+		if err := e.h.approveWithdrawal(context.Background(), rec, "admin", "", true); err != nil {
+			mt.Fatal(err)
+		}
+		if rec.Status != withdrawal.Processing || rec.PayoutTxID == "" {
+			mt.Fatalf("withdrawal %+v", rec)
+		}
+
+		cmds := sent(mt)
+		toProcessing := find(cmds, "update", "withdrawals")
+		if str(toProcessing.set().Lookup("status")) != withdrawal.Processing {
+			mt.Fatalf("first write %s", toProcessing.statement())
+		}
+		// The signed transaction is stored only on a PROCESSING withdrawal
+		// without one, so two dispatches can't both sign.
+		var store *command
+		for i := range cmds {
+			if cmds[i].Coll == "withdrawals" && str(cmds[i].set().Lookup("payoutTxId")) != "" {
+				store = &cmds[i]
+			}
+		}
+		if store == nil {
+			mt.Fatal("payout transaction not stored")
+		}
+		q := store.statement().Lookup("q").Document()
+		if str(q.Lookup("status")) != withdrawal.Processing {
+			mt.Fatalf("store filter %s", q)
+		}
+		if exists, _ := q.Lookup("payoutTxId", "$exists").BooleanOK(); exists {
+			mt.Fatalf("store filter %s", q)
+		}
+
+		broadcasts := e.chain.Broadcasts()
+		if len(broadcasts) != 1 || broadcasts[0].TxID != rec.PayoutTxID {
+			mt.Fatalf("broadcasts %+v", broadcasts)
+		}
+		if hex.EncodeToString(broadcasts[0].Raw) != str(store.set().Lookup("payoutRaw")) {
+			mt.Fatal("broadcast bytes differ from the stored transaction")
+		}
+		if got := store.set().Lookup("payoutAmount").Double(); got != 0.05 {
+			mt.Fatalf("payout amount %v ETH, want 0.05", got)
+		}
+	})
+	mt.Run("already signed elsewhere", func(mt *mtest.T) {
+		e := newTestEnv(mt)
+		rec := cryptoWithdrawal(withdrawal.Approved)
+		// To PROCESSING, audit, store (matches nothing), back to APPROVED,
+		// audit.
+		mt.AddMockResponses(updated(1), ok(), updated(0), updated(1), ok())
+
+		err := e.h.approveWithdrawal(context.Background(), rec, "admin", "", true)
+		if !errors.Is(err, errDispatch) {
+			mt.Fatalf("approve = %v", err)
+		}
+		if len(e.chain.Broadcasts()) != 0 {
+			mt.Fatal("a payout that wasn't stored was broadcast")
+		}
+		if rec.Status != withdrawal.Approved {
+			mt.Fatalf("status %s, want %s", rec.Status, withdrawal.Approved)
+		}
+		var back *command
+		for _, c := range sent(mt) {
+			c := c
+			if c.Coll == "withdrawals" && str(c.set().Lookup("status")) == withdrawal.Approved {
+				back = &c
+			}
+		}
+		if back == nil || str(back.set().Lookup("dispatchError")) == "" {
+			mt.Fatal("dispatch error not recorded on the withdrawal")
+		}
+	})
+}
+
+func TestFailedBroadcastIsResentUnchanged(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("resend", func(mt *mtest.T) {
+		e := newTestEnv(mt)
+		rec := cryptoWithdrawal(withdrawal.Processing)
+		e.chain.FailNext(errors.New("node unavailable"))
+		// Store, then the dispatch error.
+		mt.AddMockResponses(updated(1), updated(1))
+
+		if err := e.h.dispatchCryptoPayout(context.Background(), rec); err != nil {
+			mt.Fatalf("a stored payout must stay PROCESSING: %v", err)
+		}
+		if len(e.chain.Broadcasts()) != 0 {
+			mt.Fatal("failed broadcast recorded")
+		}
+		stored, _ := hex.DecodeString(rec.PayoutRaw)
+
+		// The watcher doesn't find it and resends the same bytes.
+		if err := e.h.checkCryptoPayout(context.Background(), rec); err != nil {
+			mt.Fatal(err)
+		}
+		broadcasts := e.chain.Broadcasts()
+		if len(broadcasts) != 1 || !bytes.Equal(broadcasts[0].Raw, stored) {
+			mt.Fatalf("broadcasts %+v", broadcasts)
+		}
+	})
+}
+
+func TestPayoutWatcherSettles(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	settle := func(mt *mtest.T, confirm func(e *testEnv, txID string)) (*testEnv, *withdrawalRecord) {
+		e := newTestEnv(mt)
+		rec := cryptoWithdrawal(withdrawal.Processing)
+		if err := e.h.limits.Reserve(context.Background(), withdrawalLimitsRequest(rec)); err != nil {
+			mt.Fatal(err)
+		}
+		tx := e.signedPayout(mt.T, rec)
+		if err := e.h.payout.Broadcast(context.Background(), tx); err != nil {
+			mt.Fatal(err)
+		}
+		confirm(e, tx.TxID)
+		// Transition and release in one transaction, commit, audit, and
+		// the release's dispatch.
+		mt.AddMockResponses(updated(1), updated(1), ok(), ok(), nothingDue())
+		if err := e.h.checkCryptoPayout(context.Background(), rec); err != nil {
+			mt.Fatal(err)
+		}
+		return e, rec
+	}
+
+	mt.Run("confirmed", func(mt *mtest.T) {
+		e, rec := settle(mt, func(e *testEnv, txID string) { e.chain.Confirm(txID, payoutConfirmations[payout.Ethereum]) })
+		if rec.Status != withdrawal.Completed {
+			mt.Fatalf("status %s", rec.Status)
+		}
+		cmds := sent(mt)
+		msg, cmd := enqueued(mt.T, cmds, "release:w1")
+		sameTransaction(mt.T, cmds, find(cmds, "update", "withdrawals"), msg)
+		if cmd.Release == nil || !cmd.Release.Success || cmd.Release.UserID != "u1" {
+			mt.Fatalf("queued %+v", cmd.Release)
+		}
+		e.deliverQueued(mt.T, "release:w1", cmd)
+		calls := e.wallet.Calls()
+		if len(calls) != 1 || calls[0].Path != "/internal/ledger/release-withdrawal" || calls[0].Body["success"] != true {
+			mt.Fatalf("wallet calls %+v", calls)
+		}
+		if e.counters.total() == 0 {
+			mt.Fatal("a completed withdrawal gave back its limits")
+		}
+	})
+	mt.Run("reverted", func(mt *mtest.T) {
+		e, rec := settle(mt, func(e *testEnv, txID string) { e.chain.Revert(txID) })
+		if rec.Status != withdrawal.Failed {
+			mt.Fatalf("status %s", rec.Status)
+		}
+		_, cmd := enqueued(mt.T, sent(mt), "release:w1")
+		if cmd.Release == nil || cmd.Release.Success {
+			mt.Fatalf("queued %+v", cmd.Release)
+		}
+		if e.counters.total() != 0 {
+			mt.Fatalf("limits not released: %v", e.counters)
+		}
+	})
+	mt.Run("confirming", func(mt *mtest.T) {
+		e := newTestEnv(mt)
+		rec := cryptoWithdrawal(withdrawal.Processing)
+		tx := e.signedPayout(mt.T, rec)
+		e.h.payout.Broadcast(context.Background(), tx)
+		e.chain.Confirm(tx.TxID, 3)
+		mt.AddMockResponses(updated(1))
+		if err := e.h.checkCryptoPayout(context.Background(), rec); err != nil {
+			mt.Fatal(err)
+		}
+		if rec.Status != withdrawal.Processing || find(sent(mt), "update", "outbox") != nil {
+			mt.Fatal("settled before enough confirmations")
+		}
+	})
+}
+
+func TestDroppedPayoutIsAbandoned(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("abandon", func(mt *mtest.T) {
+		e := newTestEnv(mt)
+		rec := cryptoWithdrawal(withdrawal.Processing)
+		e.signedPayout(mt.T, rec)
+		// Another payout from the hot wallet is mined with a later nonce,
+		// so rec's transaction can never be.
+		other := cryptoWithdrawal(withdrawal.Processing)
+		other.ID = "w2"
+		if err := e.h.payout.Broadcast(context.Background(), e.signedPayout(mt.T, other)); err != nil {
+			mt.Fatal(err)
+		}
+		lost := rec.PayoutTxID
+		mt.AddMockResponses(updated(1), ok())
+
+		if err := e.h.checkCryptoPayout(context.Background(), rec); err != nil {
+			mt.Fatal(err)
+		}
+		if rec.Status != withdrawal.Approved || rec.PayoutTxID != "" {
+			mt.Fatalf("withdrawal %+v", rec)
+		}
+		if len(e.chain.Broadcasts()) != 1 {
+			mt.Fatal("dropped payout was rebroadcast")
+		}
+		update := find(sent(mt), "update", "withdrawals").statement().Lookup("u").Document()
+		if _, err := update.LookupErr("$unset", "payoutRaw"); err != nil {
+			mt.Fatalf("payout kept: %s", update)
+		}
+		if str(update.Lookup("$push", "abandonedPayouts", "txId")) != lost {
+			mt.Fatalf("abandoned payout not kept for the record: %s", update)
+		}
+	})
+}
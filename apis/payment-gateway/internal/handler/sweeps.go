@@ -0,0 +1,357 @@
+package handler
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"gamehub/payment-gateway/internal/payout"
+)
+
+// =============================================================================
+// DEPOSIT SWEEPS
+// =============================================================================
+//
+// User deposit addresses are emptied into the treasury address of their
+// network once they hold more than the coin's sweep minimum. Every sweep
+// is recorded in the sweeps ledger before anything is broadcast, with the
+// signed transaction, so a retry resends the same bytes. Token addresses
+// without gas are first topped up from the hot wallet (status FUNDING)
+// and swept once the top-up confirms. At most one sweep per address is
+// active at a time.
+
+const (
+	sweepFunding   = "FUNDING"
+	sweepBroadcast = "BROADCAST"
+	sweepConfirmed = "CONFIRMED"
+	sweepFailed    = "FAILED"
+)
+
+// sweepStaleAfter gives up on a sweep transaction the chain still doesn't
+// know about. The address is planned afresh on the next run; a late
+// original and its replacement both pay treasury, so at most one lands.
+const sweepStaleAfter = 6 * time.Hour
+
+type sweepRecord struct {
+	ID            string    `bson:"_id" json:"id"`
+	UserID        string    `bson:"userId" json:"userId"`
+	Coin          string    `bson:"coin" json:"coin"`
+	Network       string    `bson:"network" json:"network"`
+	FromAddress   string    `bson:"fromAddress" json:"fromAddress"`
+	Index         int64     `bson:"derivationIndex" json:"derivationIndex"`
+	ToAddress     string    `bson:"toAddress" json:"toAddress"`
+	Amount        float64   `bson:"amount" json:"amount"`
+	Fee           float64   `bson:"fee" json:"fee"`
+	Status        string    `bson:"status" json:"status"`
+	Active        bool      `bson:"active" json:"active"`
+	TxID          string    `bson:"txId,omitempty" json:"txId,omitempty"`
+	Raw           string    `bson:"raw,omitempty" json:"-"`
+	TopUpTxID     string    `bson:"topUpTxId,omitempty" json:"topUpTxId,omitempty"`
+	TopUpRaw      string    `bson:"topUpRaw,omitempty" json:"-"`
+	Confirmations int       `bson:"confirmations" json:"confirmations"`
+	LastError     string    `bson:"lastError,omitempty" json:"lastError,omitempty"`
+	CreatedAt     time.Time `bson:"createdAt" json:"createdAt"`
+	UpdatedAt     time.Time `bson:"updatedAt" json:"updatedAt"`
+	ConfirmedAt   time.Time `bson:"confirmedAt,omitempty" json:"confirmedAt,omitempty"`
+}
+
+// RunDepositSweeper sweeps deposit addresses every CryptoSweepInterval
+// until ctx is cancelled.
+func (h *Handler) RunDepositSweeper(ctx context.Context) {
+	interval := h.cfg.CryptoSweepInterval
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	log.Printf("[crypto-sweep] starting with interval=%v", interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.sweepDeposits(ctx)
+		}
+	}
+}
+
+// RunDepositSweeps runs a sweep pass now and reports what it started.
+func (h *Handler) RunDepositSweeps(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	started := h.sweepDeposits(ctx)
+	return c.JSON(fiber.Map{"started": started})
+}
+
+// ListSweeps returns the sweep ledger, newest first, optionally filtered
+// by status.
+func (h *Handler) ListSweeps(c *fiber.Ctx) error {
+	limit := parseLimit(c.Query("limit"), 50, 200)
+	filter := bson.M{}
+	if status := strings.ToUpper(c.Query("status")); status != "" {
+		filter["status"] = status
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := h.db.Collection("sweeps").Find(ctx, filter,
+		options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}).SetLimit(limit))
+	if err != nil {
+		return httpError(c, err)
+	}
+	defer cursor.Close(ctx)
+
+	var records []sweepRecord
+	if err := cursor.All(ctx, &records); err != nil {
+		return httpError(c, err)
+	}
+	return c.JSON(fiber.Map{"items": records})
+}
+
+// sweepDeposits advances active sweeps, then starts new ones for deposit
+// addresses holding more than the sweep minimum. It returns how many it
+// started.
+func (h *Handler) sweepDeposits(ctx context.Context) int {
+	h.checkSweeps(ctx)
+
+	cursor, err := h.db.Collection("crypto_wallets").Find(ctx, bson.M{
+		"status":          "ACTIVE",
+		"derivationIndex": bson.M{"$gt": 0},
+	})
+	if err != nil {
+		log.Printf("[crypto-sweep] query error: %v", err)
+		return 0
+	}
+	defer cursor.Close(ctx)
+
+	started := 0
+	for cursor.Next(ctx) {
+		var w struct {
+			UserID  interface{} `bson:"userId"`
+			Coin    string      `bson:"coin"`
+			Network string      `bson:"network"`
+			Address string      `bson:"address"`
+			Index   int64       `bson:"derivationIndex"`
+		}
+		if err := cursor.Decode(&w); err != nil {
+			continue
+		}
+		ok, err := h.startSweep(ctx, stringID(w.UserID), w.Coin, w.Network, w.Address, w.Index)
+		if err != nil {
+			log.Printf("[crypto-sweep] %s %s: %v", w.Coin, w.Address, err)
+		}
+		if ok {
+			started++
+		}
+	}
+	return started
+}
+
+// startSweep plans a sweep of one deposit address, records it and
+// broadcasts its first transaction.
+func (h *Handler) startSweep(ctx context.Context, userID, coin, network, address string, index int64) (bool, error) {
+	network = payout.NormalizeNetwork(firstNonEmpty(network, h.defaultNetwork(coin)))
+	if !h.payout.Supports(coin, network) || index <= 0 || index > math.MaxUint32 {
+		return false, nil
+	}
+	n, err := h.db.Collection("sweeps").CountDocuments(ctx, bson.M{"fromAddress": address, "active": true})
+	if err != nil || n > 0 {
+		return false, err
+	}
+	// Deposit addresses come from Tatum's xpub; only sweep those the
+	// signer derives identically, or the keys belong to another wallet.
+	derived, err := h.payout.Address(ctx, network, uint32(index))
+	if err != nil {
+		return false, err
+	}
+	if derived != address {
+		return false, fmt.Errorf("index %d derives %s; xpub and mnemonic don't match", index, derived)
+	}
+	to := h.cfg.CryptoTreasury[network]
+	if to == "" {
+		if to, err = h.payout.HotWallet(ctx, network); err != nil {
+			return false, err
+		}
+	}
+
+	// Top-ups spend from the hot wallet, so they are built one at a time
+	// with payouts.
+	h.payoutMu.Lock()
+	defer h.payoutMu.Unlock()
+	plan, err := h.payout.PlanSweep(ctx, payout.Sweep{
+		Reference: address,
+		Coin:      coin,
+		Network:   network,
+		Index:     uint32(index),
+		To:        to,
+		Min:       h.cfg.CryptoSweepMin[coin],
+	})
+	if errors.Is(err, payout.ErrDust) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	now := time.Now()
+	rec := sweepRecord{
+		ID:          primitive.NewObjectID().Hex(),
+		UserID:      userID,
+		Coin:        coin,
+		Network:     network,
+		FromAddress: address,
+		Index:       index,
+		ToAddress:   to,
+		Amount:      plan.Amount,
+		Fee:         plan.Fee,
+		Active:      true,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	tx := plan.Tx
+	if plan.TopUp != nil {
+		tx = plan.TopUp
+		rec.Status, rec.TopUpTxID, rec.TopUpRaw = sweepFunding, tx.TxID, tx.RawHex()
+	} else {
+		rec.Status, rec.TxID, rec.Raw = sweepBroadcast, tx.TxID, tx.RawHex()
+	}
+	if _, err := h.db.Collection("sweeps").InsertOne(ctx, rec); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return false, nil // another run got there first
+		}
+		return false, err
+	}
+	if err := h.payout.Broadcast(ctx, tx); err != nil {
+		// Recorded; checkSweeps rebroadcasts the same transaction.
+		log.Printf("[crypto-sweep][%s] broadcast %s failed, will retry: %v", rec.ID, tx.TxID, err)
+		h.db.Collection("sweeps").UpdateByID(ctx, rec.ID, bson.M{"$set": bson.M{"lastError": err.Error()}})
+		return true, nil
+	}
+	log.Printf("[crypto-sweep][%s] %s %s %.8f %s from %s to %s", rec.ID, strings.ToLower(rec.Status), coin, plan.Amount, tx.TxID, address, to)
+	return true, nil
+}
+
+func (h *Handler) checkSweeps(ctx context.Context) {
+	cursor, err := h.db.Collection("sweeps").Find(ctx, bson.M{"active": true})
+	if err != nil {
+		log.Printf("[crypto-sweep] query error: %v", err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var rec sweepRecord
+		if err := cursor.Decode(&rec); err != nil {
+			continue
+		}
+		if err := h.checkSweep(ctx, &rec); err != nil {
+			log.Printf("[crypto-sweep][%s] check failed: %v", rec.ID, err)
+			h.db.Collection("sweeps").UpdateByID(ctx, rec.ID, bson.M{"$set": bson.M{"lastError": err.Error()}})
+		}
+	}
+}
+
+// checkSweep follows the sweep's current transaction: the top-up while
+// FUNDING, the sweep itself while BROADCAST.
+func (h *Handler) checkSweep(ctx context.Context, rec *sweepRecord) error {
+	txID, raw := rec.TxID, rec.Raw
+	if rec.Status == sweepFunding {
+		txID, raw = rec.TopUpTxID, rec.TopUpRaw
+	}
+	status, err := h.payout.Status(ctx, rec.Network, txID)
+	if err != nil {
+		return err
+	}
+	switch {
+	case !status.Found && time.Since(rec.UpdatedAt) > sweepStaleAfter:
+		return h.finishSweep(ctx, rec, sweepFailed, txID+" never reached the chain")
+	case !status.Found:
+		tx, err := hex.DecodeString(raw)
+		if err != nil {
+			return err
+		}
+		log.Printf("[crypto-sweep][%s] %s not on chain, rebroadcasting", rec.ID, txID)
+		return h.payout.Broadcast(ctx, &payout.SignedTx{Network: rec.Network, TxID: txID, Raw: tx})
+	case status.Failed:
+		return h.finishSweep(ctx, rec, sweepFailed, txID+" failed on chain")
+	case status.Confirmations < payoutConfirmations[rec.Network]:
+		_, err := h.db.Collection("sweeps").UpdateByID(ctx, rec.ID, bson.M{"$set": bson.M{"confirmations": status.Confirmations}})
+		return err
+	case rec.Status == sweepBroadcast:
+		log.Printf("[crypto-sweep][%s] %s confirmed (%d)", rec.ID, txID, status.Confirmations)
+		return h.finishSweep(ctx, rec, sweepConfirmed, "")
+	}
+
+	// The top-up has confirmed: sweep the tokens.
+	plan, err := h.payout.PlanSweep(ctx, payout.Sweep{
+		Reference: rec.FromAddress,
+		Coin:      rec.Coin,
+		Network:   rec.Network,
+		Index:     uint32(rec.Index),
+		To:        rec.ToAddress,
+		Min:       h.cfg.CryptoSweepMin[rec.Coin],
+	})
+	if errors.Is(err, payout.ErrDust) {
+		return h.finishSweep(ctx, rec, sweepFailed, "balance fell below the sweep minimum after top-up")
+	}
+	if err != nil {
+		return err
+	}
+	if plan.Tx == nil {
+		return errors.New("still short of gas after top-up")
+	}
+	res, err := h.db.Collection("sweeps").UpdateOne(ctx,
+		bson.M{"_id": rec.ID, "status": sweepFunding},
+		bson.M{"$set": bson.M{
+			"status":        sweepBroadcast,
+			"txId":          plan.Tx.TxID,
+			"raw":           plan.Tx.RawHex(),
+			"amount":        plan.Amount,
+			"confirmations": 0,
+			"updatedAt":     time.Now(),
+		}})
+	if err != nil || res.MatchedCount == 0 {
+		return err
+	}
+	if err := h.payout.Broadcast(ctx, plan.Tx); err != nil {
+		log.Printf("[crypto-sweep][%s] broadcast %s failed, will retry: %v", rec.ID, plan.Tx.TxID, err)
+		return nil
+	}
+	log.Printf("[crypto-sweep][%s] broadcast %s %.8f %s to %s", rec.ID, rec.Coin, plan.Amount, plan.Tx.TxID, rec.ToAddress)
+	return nil
+}
+
+// finishSweep closes a sweep, freeing its address for the next one.
+func (h *Handler) finishSweep(ctx context.Context, rec *sweepRecord, status, reason string) error {
+	set := bson.M{"status": status, "active": false, "updatedAt": time.Now()}
+	if status == sweepConfirmed {
+		set["confirmedAt"] = time.Now()
+	} else {
+		set["lastError"] = reason
+		log.Printf("[crypto-sweep][%s] ⚠️ %s", rec.ID, reason)
+	}
+	_, err := h.db.Collection("sweeps").UpdateOne(ctx, bson.M{"_id": rec.ID, "active": true}, bson.M{"$set": set})
+	return err
+}
+
+// isSweepTx reports whether hash is one of our sweeps or gas top-ups,
+// which move funds between our own addresses and are never deposits.
+func (h *Handler) isSweepTx(ctx context.Context, hash string) (bool, error) {
+	n, err := h.db.Collection("sweeps").CountDocuments(ctx, bson.M{"$or": bson.A{
+		bson.M{"txId": hash},
+		bson.M{"topUpTxId": hash},
+	}}, options.Count().SetLimit(1))
+	return n > 0, err
+}
@@ -0,0 +1,738 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"gamehub/payment-gateway/internal/flutterwave"
+	"gamehub/payment-gateway/internal/withdrawal"
+)
+
+// =============================================================================
+// ADMIN — WITHDRAWAL REVIEW
+// =============================================================================
+//
+// Withdrawals follow the state machine in package withdrawal. They start
+// PENDING and are approved automatically when the policy allows it;
+// otherwise one reviewer (two distinct ones above the dual-approval
+// threshold) approves them. An approved withdrawal waits for a manual
+// payout, or is PROCESSING once a Flutterwave transfer or on-chain payout
+// is dispatched.
+// Every status change checks the document's version so concurrent
+// reviewers can't both act on the same withdrawal, and every decision is
+// written to withdrawal_audit.
+
+var (
+	errWithdrawalNotFound     = errors.New("withdrawal not found")
+	errWithdrawalStateChanged = errors.New("withdrawal changed since it was read; reload and retry")
+	errIllegalTransition      = errors.New("withdrawal can't move to that status")
+	errSecondApprover         = errors.New("a different reviewer must give the second approval")
+	errDispatch               = errors.New("could not dispatch transfer; the withdrawal stays approved")
+	errTransferLookup         = errors.New("could not confirm with Flutterwave that no transfer went out; try again")
+)
+
+type withdrawalApproval struct {
+	By   string    `bson:"by" json:"by"`
+	At   time.Time `bson:"at" json:"at"`
+	Note string    `bson:"note,omitempty" json:"note,omitempty"`
+}
+
+type withdrawalAudit struct {
+	WithdrawalID string    `bson:"withdrawalId" json:"withdrawalId"`
+	Action       string    `bson:"action" json:"action"`
+	Actor        string    `bson:"actor" json:"actor"`
+	FromStatus   string    `bson:"fromStatus" json:"fromStatus"`
+	ToStatus     string    `bson:"toStatus" json:"toStatus"`
+	Reason       string    `bson:"reason,omitempty" json:"reason,omitempty"`
+	TxHash       string    `bson:"txHash,omitempty" json:"txHash,omitempty"`
+	CreatedAt    time.Time `bson:"createdAt" json:"createdAt"`
+}
+
+// ListWithdrawalQueue lists withdrawals for review, oldest first. Without
+// a status it also lists approved withdrawals whose dispatch failed, each
+// with its dispatchError.
+// GET /api/v1/payments/admin/withdrawals?status=PENDING,APPROVED&method=momo|crypto&userId=&from=&to=&limit=
+func (h *Handler) ListWithdrawalQueue(c *fiber.Ctx) error {
+	filter, err := withdrawalQueueFilter(c.Query)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	limit := parseLimit(c.Query("limit"), 50, 200)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := h.db.Collection("withdrawals").Find(ctx, filter,
+		options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}).SetLimit(limit),
+	)
+	if err != nil {
+		return httpError(c, err)
+	}
+	defer cursor.Close(ctx)
+
+	items := []bson.M{}
+	if err := cursor.All(ctx, &items); err != nil {
+		return httpError(c, err)
+	}
+	return c.JSON(fiber.Map{"items": items, "count": len(items)})
+}
+
+// GetWithdrawalReview returns one withdrawal with its audit trail.
+// GET /api/v1/payments/admin/withdrawals/:id
+func (h *Handler) GetWithdrawalReview(c *fiber.Ctx) error {
+	id := c.Params("id")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var record bson.M
+	err := h.db.Collection("withdrawals").FindOne(ctx, bson.M{"_id": id}).Decode(&record)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": errWithdrawalNotFound.Error()})
+	}
+	if err != nil {
+		return httpError(c, err)
+	}
+
+	cursor, err := h.db.Collection("withdrawal_audit").Find(ctx, bson.M{"withdrawalId": id},
+		options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}),
+	)
+	if err != nil {
+		return httpError(c, err)
+	}
+	defer cursor.Close(ctx)
+	audit := []withdrawalAudit{}
+	if err := cursor.All(ctx, &audit); err != nil {
+		return httpError(c, err)
+	}
+	return c.JSON(fiber.Map{
+		"withdrawal":        record,
+		"audit":             audit,
+		"approvalsRequired": h.cfg.WithdrawalPolicy.ApprovalsRequired(floatOf(record["amount"])),
+	})
+}
+
+// ApproveWithdrawal records the caller's approval. Mobile money withdrawals
+// are sent through Flutterwave on final approval when dispatch is set
+// (default MOMO_AUTO_PAYOUT); an approved one whose dispatch failed can be
+// approved again with dispatch to retry.
+// POST /api/v1/payments/admin/withdrawals/:id/approve
+func (h *Handler) ApproveWithdrawal(c *fiber.Ctx) error {
+	actor := c.Locals("userId").(string)
+	var body struct {
+		Dispatch *bool  `json:"dispatch"`
+		Note     string `json:"note"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&body); err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid request body"})
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	rec, err := h.loadWithdrawal(ctx, bson.M{"_id": c.Params("id")})
+	if err != nil {
+		return h.reviewError(c, err)
+	}
+	dispatch := h.autoPayout(rec)
+	if body.Dispatch != nil {
+		if *body.Dispatch && !h.canDispatch(rec) {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "this withdrawal can't be paid out automatically"})
+		}
+		dispatch = *body.Dispatch
+	}
+
+	if err := h.approveWithdrawal(ctx, rec, actor, body.Note, dispatch); err != nil {
+		return h.reviewError(c, err)
+	}
+	resp := fiber.Map{
+		"withdrawalId": rec.ID,
+		"status":       rec.Status,
+		"approvals":    len(rec.Approvals),
+	}
+	if rec.DispatchError != "" {
+		resp["dispatchError"] = rec.DispatchError
+	}
+	return c.JSON(resp)
+}
+
+// RejectWithdrawal rejects a withdrawal that hasn't been paid out and
+// returns the reserved funds to the user. A mobile money withdrawal that
+// was dispatched before is only rejected once Flutterwave confirms no
+// transfer for it can still pay out.
+// POST /api/v1/payments/admin/withdrawals/:id/reject
+func (h *Handler) RejectWithdrawal(c *fiber.Ctx) error {
+	actor := c.Locals("userId").(string)
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid request body"})
+	}
+	body.Reason = strings.TrimSpace(body.Reason)
+	if body.Reason == "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "reason is required"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rec, err := h.loadWithdrawal(ctx, bson.M{"_id": c.Params("id")})
+	if err != nil {
+		return h.reviewError(c, err)
+	}
+	if err := h.confirmNotDispatched(ctx, rec); err != nil {
+		return h.reviewError(c, err)
+	}
+	err = h.closeWithdrawal(ctx, rec, withdrawal.Rejected, bson.M{
+		"rejectedBy":      actor,
+		"rejectionReason": body.Reason,
+	}, withdrawalAudit{Action: "reject", Actor: actor, Reason: body.Reason})
+	if err != nil {
+		return h.reviewError(c, err)
+	}
+	log.Printf("[payments][withdraw][%s] rejected by %s: %s", rec.ID, actor, body.Reason)
+	return c.JSON(fiber.Map{"withdrawalId": rec.ID, "status": rec.Status})
+}
+
+// MarkWithdrawalPaid completes an approved withdrawal that was paid outside
+// the platform, recording the external transaction hash or reference.
+// POST /api/v1/payments/admin/withdrawals/:id/mark-paid
+func (h *Handler) MarkWithdrawalPaid(c *fiber.Ctx) error {
+	actor := c.Locals("userId").(string)
+	var body struct {
+		TxHash string `json:"txHash"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid request body"})
+	}
+	body.TxHash = strings.TrimSpace(body.TxHash)
+	if body.TxHash == "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "txHash is required"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rec, err := h.loadWithdrawal(ctx, bson.M{"_id": c.Params("id")})
+	if err != nil {
+		return h.reviewError(c, err)
+	}
+	// A PROCESSING withdrawal has a transfer in flight; its webhook settles it.
+	if rec.Status != withdrawal.Approved {
+		return h.reviewError(c, fmt.Errorf("%w: only APPROVED withdrawals can be marked paid", errIllegalTransition))
+	}
+	err = h.closeWithdrawal(ctx, rec, withdrawal.Completed, bson.M{
+		"paidBy":         actor,
+		"externalTxHash": body.TxHash,
+	}, withdrawalAudit{Action: "mark_paid", Actor: actor, TxHash: body.TxHash})
+	if err != nil {
+		return h.reviewError(c, err)
+	}
+	log.Printf("[payments][withdraw][%s] marked paid by %s tx=%s", rec.ID, actor, body.TxHash)
+	return c.JSON(fiber.Map{"withdrawalId": rec.ID, "status": rec.Status, "txHash": body.TxHash})
+}
+
+// AbandonWithdrawalPayout gives up on a crypto payout flagged stale, once
+// a reviewer has checked it can't land (e.g. its inputs were spent
+// elsewhere). The withdrawal returns to APPROVED, to be dispatched again
+// or rejected.
+// POST /api/v1/payments/admin/withdrawals/:id/abandon-payout
+func (h *Handler) AbandonWithdrawalPayout(c *fiber.Ctx) error {
+	actor := c.Locals("userId").(string)
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid request body"})
+	}
+	body.Reason = strings.TrimSpace(body.Reason)
+	if body.Reason == "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "reason is required"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rec, err := h.loadWithdrawal(ctx, bson.M{"_id": c.Params("id")})
+	if err != nil {
+		return h.reviewError(c, err)
+	}
+	if rec.Status != withdrawal.Processing || rec.PayoutTxID == "" || rec.PayoutStaleAt.IsZero() {
+		return h.reviewError(c, fmt.Errorf("%w: only stale crypto payouts can be abandoned", errIllegalTransition))
+	}
+	// The flag is only cleared by abandoning; make sure the transaction
+	// hasn't turned up since.
+	status, err := h.payout.Status(ctx, rec.PayoutNetwork, rec.PayoutTxID)
+	if err != nil {
+		return c.Status(http.StatusBadGateway).JSON(fiber.Map{"error": err.Error()})
+	}
+	if status.Found {
+		return h.reviewError(c, fmt.Errorf("%w: payout %s is on chain", errIllegalTransition, rec.PayoutTxID))
+	}
+	if err := h.abandonPayout(ctx, rec, actor, body.Reason); err != nil {
+		return h.reviewError(c, err)
+	}
+	return c.JSON(fiber.Map{"withdrawalId": rec.ID, "status": rec.Status})
+}
+
+// autoApproveWithdrawal approves a new withdrawal when the policy lets it
+// skip review. It returns the withdrawal's status afterwards.
+func (h *Handler) autoApproveWithdrawal(ctx context.Context, withdrawalID string) string {
+	rec, err := h.loadWithdrawal(ctx, bson.M{"_id": withdrawalID})
+	if err != nil {
+		log.Printf("[payments][withdraw][%s] auto-approve load failed: %v", withdrawalID, err)
+		return withdrawal.Pending
+	}
+	level, err := h.kycLevel(ctx, rec.UserID)
+	if err != nil || !h.cfg.WithdrawalPolicy.AutoApprove(rec.Amount, level) {
+		return rec.Status
+	}
+	if err := h.approveWithdrawal(ctx, rec, "system", "auto-approved by policy", h.autoPayout(rec)); err != nil {
+		log.Printf("[payments][withdraw][%s] auto-approve failed: %v", withdrawalID, err)
+	}
+	return rec.Status
+}
+
+// approveWithdrawal adds actor's approval to rec. Once enough distinct
+// reviewers have approved, rec becomes APPROVED, or PROCESSING with a
+// Flutterwave transfer or on-chain payout when dispatch is set.
+func (h *Handler) approveWithdrawal(ctx context.Context, rec *withdrawalRecord, actor, note string, dispatch bool) error {
+	from := rec.Status
+	now := time.Now()
+	if from == withdrawal.Approved {
+		if !dispatch {
+			return fmt.Errorf("%w: already approved", errIllegalTransition)
+		}
+	} else {
+		for _, a := range rec.Approvals {
+			if a.By == actor {
+				return errSecondApprover
+			}
+		}
+		to := withdrawal.Approved
+		if len(rec.Approvals)+1 < h.cfg.WithdrawalPolicy.ApprovalsRequired(rec.Amount) {
+			to = withdrawal.PendingSecondApproval
+		} else if dispatch {
+			to = withdrawal.Processing
+		}
+		set := bson.M{}
+		if to != withdrawal.PendingSecondApproval {
+			set["approvedBy"] = actor
+			set["approvedAt"] = now
+		}
+		approval := withdrawalApproval{By: actor, At: now, Note: note}
+		if err := h.transitionWithdrawal(ctx, rec, to, set, bson.M{"$push": bson.M{"approvals": approval}}); err != nil {
+			return err
+		}
+		rec.Approvals = append(rec.Approvals, approval)
+		h.recordWithdrawalAudit(ctx, withdrawalAudit{
+			WithdrawalID: rec.ID, Action: "approve", Actor: actor,
+			FromStatus: from, ToStatus: to, Reason: note,
+		})
+		log.Printf("[payments][withdraw][%s] approved by %s (%s -> %s)", rec.ID, actor, from, to)
+		if to != withdrawal.Processing {
+			return nil
+		}
+	}
+
+	if rec.Status == withdrawal.Approved {
+		if err := h.transitionWithdrawal(ctx, rec, withdrawal.Processing, nil, bson.M{"$unset": bson.M{"dispatchError": ""}}); err != nil {
+			return err
+		}
+		rec.DispatchError = ""
+		h.recordWithdrawalAudit(ctx, withdrawalAudit{
+			WithdrawalID: rec.ID, Action: "dispatch", Actor: actor,
+			FromStatus: withdrawal.Approved, ToStatus: withdrawal.Processing, Reason: note,
+		})
+	}
+	dispatchFn := h.dispatchMoMoTransfer
+	if rec.Coin != "" {
+		dispatchFn = h.dispatchCryptoPayout
+	}
+	if err := dispatchFn(ctx, rec); err != nil {
+		log.Printf("[payments][withdraw][%s] dispatch failed: %v", rec.ID, err)
+		if terr := h.transitionWithdrawal(ctx, rec, withdrawal.Approved, bson.M{"dispatchError": err.Error()}, nil); terr != nil {
+			log.Printf("[payments][withdraw][%s] could not return to APPROVED: %v", rec.ID, terr)
+		} else {
+			rec.DispatchError = err.Error()
+		}
+		h.recordWithdrawalAudit(ctx, withdrawalAudit{
+			WithdrawalID: rec.ID, Action: "dispatch_failed", Actor: actor,
+			FromStatus: withdrawal.Processing, ToStatus: withdrawal.Approved, Reason: err.Error(),
+		})
+		return errDispatch
+	}
+	return nil
+}
+
+// closeWithdrawal moves rec to a terminal status and, in the same
+// transaction, queues the release of its wallet reservation. The user is
+// notified once the wallet has applied the release.
+func (h *Handler) closeWithdrawal(ctx context.Context, rec *withdrawalRecord, to string, set bson.M, audit withdrawalAudit) error {
+	from := rec.Status
+	if set == nil {
+		set = bson.M{}
+	}
+	set["settledAt"] = time.Now()
+	success := to == withdrawal.Completed
+
+	event := bson.M{"type": "WITHDRAWAL_FAILED", "amount": rec.Amount, "withdrawalId": rec.ID, "status": to}
+	if success {
+		event["type"] = "WITHDRAWAL_COMPLETED"
+	}
+	if audit.Reason != "" {
+		event["reason"] = audit.Reason
+	}
+	if audit.TxHash != "" {
+		event["txHash"] = audit.TxHash
+	}
+
+	// Transition a copy so rec is untouched if the transaction aborts.
+	next := *rec
+	msgID := "release:" + rec.ID
+	err := h.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		if err := h.transitionWithdrawal(sc, &next, to, set, nil); err != nil {
+			return err
+		}
+		return h.enqueueWalletCommand(sc, msgID, topicWalletRelease, walletCommand{
+			Release: &walletRelease{UserID: rec.UserID, WithdrawalID: rec.ID, Success: success},
+			Notify:  event,
+		})
+	})
+	if err != nil {
+		return err
+	}
+	*rec = next
+
+	audit.WithdrawalID = rec.ID
+	audit.FromStatus = from
+	audit.ToStatus = to
+	h.recordWithdrawalAudit(ctx, audit)
+	h.outbox.Dispatch(ctx, msgID)
+	if !success {
+		h.releaseLimits(ctx, withdrawalLimitsRequest(rec))
+	}
+	return nil
+}
+
+// transitionWithdrawal moves rec to status if the state machine allows it
+// and the stored document is still at rec's version. It stamps
+// stateTimes.<status>, bumps the version and applies set plus any extra
+// update operators.
+func (h *Handler) transitionWithdrawal(ctx context.Context, rec *withdrawalRecord, to string, set bson.M, extra bson.M) error {
+	if !withdrawal.CanTransition(rec.Status, to) {
+		return fmt.Errorf("%w: %s -> %s", errIllegalTransition, rec.Status, to)
+	}
+	now := time.Now()
+	fields := bson.M{"status": to, "updatedAt": now, "stateTimes." + to: now}
+	for k, v := range set {
+		fields[k] = v
+	}
+	update := bson.M{"$set": fields, "$inc": bson.M{"version": 1}}
+	for op, v := range extra {
+		update[op] = v
+	}
+	res, err := h.db.Collection("withdrawals").UpdateOne(ctx,
+		bson.M{"_id": rec.ID, "status": rec.Status, "version": versionFilter(rec.Version)},
+		update,
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return errWithdrawalStateChanged
+	}
+	rec.Status = to
+	rec.Version++
+	return nil
+}
+
+// versionFilter matches version v; withdrawals created before versioning
+// have no version field and count as 0.
+func versionFilter(v int64) interface{} {
+	if v == 0 {
+		return bson.M{"$in": bson.A{0, nil}}
+	}
+	return v
+}
+
+func (h *Handler) loadWithdrawal(ctx context.Context, filter bson.M) (*withdrawalRecord, error) {
+	var rec withdrawalRecord
+	err := h.db.Collection("withdrawals").FindOne(ctx, filter).Decode(&rec)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, errWithdrawalNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// dispatchMoMoTransfer sends an approved mobile money withdrawal through
+// Flutterwave; the transfer webhook and poller settle it. An error means
+// Flutterwave refused the transfer and rec can go back to APPROVED. When
+// the outcome is unknown (a timeout or a 5xx), the transfer may exist, so
+// rec stays PROCESSING with the error recorded until Flutterwave settles it
+// or the poller finds it has no such transfer.
+func (h *Handler) dispatchMoMoTransfer(ctx context.Context, rec *withdrawalRecord) error {
+	ref := firstNonEmpty(rec.ProviderRef, rec.LegacyPaystackRef)
+	amount := rec.FinalAmount
+	if amount <= 0 {
+		amount = rec.Amount
+	}
+	if ref == "" || rec.Phone == "" || amount <= 0 {
+		return fmt.Errorf("withdrawal %s is missing payout details", rec.ID)
+	}
+
+	transfer, err := h.flutterClient.InitiateTransfer(ctx, flutterwave.TransferRequest{
+		Reference:     ref,
+		Amount:        amount,
+		Currency:      rec.Currency,
+		DebitCurrency: rec.Currency,
+		AccountBank:   networkFromChannel(rec.Channel),
+		AccountNumber: rec.Phone,
+		Narration:     "Glory Grid Wallet Withdrawal",
+		CallbackURL:   h.cfg.FlutterwaveTransferCallback,
+		Beneficiary:   fmt.Sprintf("GH %s", shortID(rec.UserID)),
+	}, ref)
+	now := time.Now()
+	if err != nil {
+		if errors.Is(err, flutterwave.ErrRejected) {
+			return err
+		}
+		log.Printf("[payments][withdraw][%s] transfer %s outcome unknown, left processing: %v", rec.ID, ref, err)
+		rec.DispatchError, rec.DispatchedAt = err.Error(), now
+		_, uerr := h.db.Collection("withdrawals").UpdateOne(ctx,
+			bson.M{"_id": rec.ID},
+			bson.M{"$set": bson.M{"dispatchError": rec.DispatchError, "dispatchedAt": now}},
+		)
+		if uerr != nil {
+			log.Printf("[payments][withdraw][%s] could not record dispatch error: %v", rec.ID, uerr)
+		}
+		return nil
+	}
+	_, err = h.db.Collection("withdrawals").UpdateOne(ctx,
+		bson.M{"_id": rec.ID},
+		bson.M{"$set": bson.M{"transferCode": transfer.FlwRef, "dispatchedAt": now}},
+	)
+	return err
+}
+
+// returnUndispatchedWithdrawal moves a PROCESSING mobile money withdrawal
+// whose dispatch had an unknown outcome back to APPROVED, once Flutterwave
+// has confirmed it holds no transfer under its reference.
+func (h *Handler) returnUndispatchedWithdrawal(ctx context.Context, rec *withdrawalRecord) error {
+	reason := rec.DispatchError + "; Flutterwave has no such transfer"
+	err := h.transitionWithdrawal(ctx, rec, withdrawal.Approved, bson.M{"dispatchError": reason}, bson.M{
+		"$unset": bson.M{"dispatchedAt": "", "transferCode": ""},
+	})
+	if err != nil {
+		return err
+	}
+	rec.DispatchError, rec.DispatchedAt = reason, time.Time{}
+	h.recordWithdrawalAudit(ctx, withdrawalAudit{
+		WithdrawalID: rec.ID, Action: "dispatch_failed", Actor: "system",
+		FromStatus: withdrawal.Processing, ToStatus: withdrawal.Approved, Reason: reason,
+	})
+	log.Printf("[payments][withdraw][%s] no transfer at Flutterwave, back to APPROVED", rec.ID)
+	return nil
+}
+
+// confirmNotDispatched checks that a mobile money withdrawal sent to
+// Flutterwave before has no transfer there that could still pay out, so
+// rejecting it doesn't also hand the funds back.
+func (h *Handler) confirmNotDispatched(ctx context.Context, rec *withdrawalRecord) error {
+	if rec.Coin != "" || (rec.DispatchError == "" && rec.DispatchedAt.IsZero()) {
+		return nil
+	}
+	ref := firstNonEmpty(rec.ProviderRef, rec.LegacyPaystackRef)
+	transfer, err := h.flutterClient.GetTransferByReference(ctx, ref, ref)
+	if errors.Is(err, flutterwave.ErrTransferNotFound) {
+		return nil
+	}
+	if err != nil {
+		log.Printf("[payments][withdraw][%s] transfer lookup failed: %v", rec.ID, err)
+		return errTransferLookup
+	}
+	if strings.EqualFold(transfer.Status, "failed") {
+		return nil
+	}
+	return fmt.Errorf("%w: Flutterwave transfer %s is %s", errIllegalTransition, ref, transfer.Status)
+}
+
+// RunWithdrawalSLASweeper cancels withdrawals that have waited for review
+// longer than the policy SLA and returns the funds. Approved withdrawals
+// are left to reviewers, who mark them paid or reject them.
+func (h *Handler) RunWithdrawalSLASweeper(ctx context.Context) {
+	if h.cfg.WithdrawalPolicy.SLA <= 0 {
+		return
+	}
+	ticker := time.NewTicker(10 * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.cancelStuckWithdrawals(ctx)
+		}
+	}
+}
+
+func (h *Handler) cancelStuckWithdrawals(ctx context.Context) {
+	policy := h.cfg.WithdrawalPolicy
+	cursor, err := h.db.Collection("withdrawals").Find(ctx, bson.M{
+		"status":    bson.M{"$in": withdrawal.Stuck},
+		"createdAt": bson.M{"$lt": time.Now().Add(-policy.SLA)},
+	})
+	if err != nil {
+		log.Printf("withdrawal SLA sweeper: query error: %v", err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var rec withdrawalRecord
+		if err := cursor.Decode(&rec); err != nil {
+			continue
+		}
+		reason := fmt.Sprintf("not reviewed within %s", policy.SLA)
+		err := h.closeWithdrawal(ctx, &rec, withdrawal.Cancelled, bson.M{"cancelReason": reason},
+			withdrawalAudit{Action: "sla_cancel", Actor: "system", Reason: reason})
+		if err != nil {
+			log.Printf("[payments][withdraw][%s] SLA cancel failed: %v", rec.ID, err)
+			continue
+		}
+		log.Printf("[payments][withdraw][%s] cancelled after SLA (%s)", rec.ID, policy.SLA)
+	}
+}
+
+func (h *Handler) recordWithdrawalAudit(ctx context.Context, entry withdrawalAudit) {
+	entry.CreatedAt = time.Now()
+	if _, err := h.db.Collection("withdrawal_audit").InsertOne(ctx, entry); err != nil {
+		log.Printf("[payments][withdraw][%s] audit write failed: %v", entry.WithdrawalID, err)
+	}
+}
+
+func (h *Handler) reviewError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, errWithdrawalNotFound):
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, errSecondApprover):
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": err.Error(), "code": "SECOND_APPROVER_REQUIRED"})
+	case errors.Is(err, errWithdrawalStateChanged), errors.Is(err, errIllegalTransition):
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, errDispatch), errors.Is(err, errTransferLookup):
+		return c.Status(http.StatusBadGateway).JSON(fiber.Map{"error": err.Error()})
+	}
+	return httpError(c, err)
+}
+
+// withdrawalQueueFilter builds the review queue query from request
+// parameters. status defaults to the statuses awaiting review plus
+// approved withdrawals whose dispatch failed; method is momo or crypto;
+// from and to bound createdAt (RFC 3339).
+func withdrawalQueueFilter(query func(key string, defaultValue ...string) string) (bson.M, error) {
+	filter := bson.M{}
+	statuses := []string{}
+	for _, s := range strings.Split(query("status"), ",") {
+		if s = strings.ToUpper(strings.TrimSpace(s)); s != "" {
+			statuses = append(statuses, s)
+		}
+	}
+	switch {
+	case len(statuses) == 0:
+		filter["$or"] = bson.A{
+			bson.M{"status": bson.M{"$in": withdrawal.AwaitingReview}},
+			bson.M{"status": withdrawal.Approved, "dispatchError": bson.M{"$exists": true}},
+		}
+	case statuses[0] != "ALL":
+		filter["status"] = bson.M{"$in": statuses}
+	}
+	if userID := strings.TrimSpace(query("userId")); userID != "" {
+		filter["userId"] = userID
+	}
+	switch strings.ToLower(strings.TrimSpace(query("method"))) {
+	case "":
+	case "momo":
+		filter["coin"] = bson.M{"$exists": false}
+	case "crypto":
+		filter["coin"] = bson.M{"$exists": true}
+	default:
+		return nil, errors.New("method must be momo or crypto")
+	}
+	created := bson.M{}
+	for key, op := range map[string]string{"from": "$gte", "to": "$lt"} {
+		raw := strings.TrimSpace(query(key))
+		if raw == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be an RFC 3339 timestamp", key)
+		}
+		created[op] = t
+	}
+	if len(created) > 0 {
+		filter["createdAt"] = created
+	}
+	return filter, nil
+}
+
+func floatOf(v interface{}) float64 {
+	switch n := v.(type) {
+	case float64:
+		return n
+	case int32:
+		return float64(n)
+	case int64:
+		return float64(n)
+	}
+	return 0
+}
+
+// settleWithdrawal applies a Flutterwave transfer outcome. Withdrawals that
+// were already settled, or never dispatched, are left alone.
+func (h *Handler) settleWithdrawal(ctx context.Context, providerRef string, success bool) error {
+	filter := bson.M{
+		"$or": []bson.M{
+			{"providerRef": providerRef},
+			{"paystackRef": providerRef},
+		},
+	}
+	to := withdrawal.Failed
+	if success {
+		to = withdrawal.Completed
+	}
+	// Retry when a reviewer touched the withdrawal between read and write.
+	for attempt := 0; attempt < 3; attempt++ {
+		rec, err := h.loadWithdrawal(ctx, filter)
+		if errors.Is(err, errWithdrawalNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !withdrawal.CanTransition(rec.Status, to) {
+			log.Printf("[payments][withdraw][%s] ignoring %s from provider in status %s", rec.ID, to, rec.Status)
+			return nil
+		}
+		err = h.closeWithdrawal(ctx, rec, to, nil, withdrawalAudit{Action: "provider_settle", Actor: "flutterwave"})
+		if !errors.Is(err, errWithdrawalStateChanged) {
+			return err
+		}
+	}
+	return errWithdrawalStateChanged
+}
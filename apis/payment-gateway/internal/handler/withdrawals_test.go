@@ -0,0 +1,305 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+
+	"gamehub/payment-gateway/internal/flutterwave"
+	"gamehub/payment-gateway/internal/flutterwavemock"
+	"gamehub/payment-gateway/internal/withdrawal"
+)
+
+func momoWithdrawal(status string) *withdrawalRecord {
+	created := time.Now().Add(-time.Hour)
+	return &withdrawalRecord{
+		ID: "w1", UserID: "u1", Phone: "0241234567", Channel: "mtn-gh", Amount: 50, FinalAmount: 47.5,
+		Currency: "GHS", ProviderRef: "WD-w1", Status: status, Version: 2,
+		CreatedAt: created, LimitsAt: created,
+	}
+}
+
+// review calls an admin review handler for withdrawal id as actor "admin"
+// and returns the response status.
+func review(t *testing.T, handler fiber.Handler, id, body string) int {
+	t.Helper()
+	app := fiber.New()
+	app.Post("/:id", func(c *fiber.Ctx) error {
+		c.Locals("userId", "admin")
+		return handler(c)
+	})
+	req := httptest.NewRequest(http.MethodPost, "/"+id, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestMoMoWithdrawalDispatchAndSettlement(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	// dispatch approves rec and sends its transfer to a Flutterwave mock.
+	dispatch := func(mt *mtest.T) (*testEnv, *flutterwavemock.Server, *withdrawalRecord) {
+		e := newTestEnv(mt)
+		fw, url := flutterwavemock.NewTestServer(mt.T, flutterwavemock.Config{SecretKey: "sk"})
+		e.h.flutterClient = flutterwave.NewClient("sk", url, "")
+		rec := momoWithdrawal(withdrawal.Approved)
+		if err := e.h.limits.Reserve(context.Background(), withdrawalLimitsRequest(rec)); err != nil {
+			mt.Fatal(err)
+		}
+		// To PROCESSING, audit, transfer code.
+		mt.AddMockResponses(updated(1), ok(), updated(1))
+		if err := e.h.approveWithdrawal(context.Background(), rec, "admin", "", true); err != nil {
+			mt.Fatal(err)
+		}
+		transfer, ok := fw.Transfer("WD-w1")
+		if !ok || transfer.Amount != 47.5 || transfer.AccountNumber != "0241234567" {
+			mt.Fatalf("transfer %+v", transfer)
+		}
+		if c := sent(mt)[2]; str(c.set().Lookup("transferCode")) != transfer.FlwRef {
+			mt.Fatalf("transfer code not stored: %s", c.statement())
+		}
+		mt.ClearEvents()
+		return e, fw, rec
+	}
+	// settle applies the provider's outcome to the PROCESSING withdrawal.
+	settle := func(e *testEnv, rec *withdrawalRecord, success bool) {
+		e.mt.AddMockResponses(found(mt.T, "withdrawals", rec), updated(1), updated(1), ok(), ok(), nothingDue())
+		if err := e.h.settleWithdrawal(context.Background(), rec.ProviderRef, success); err != nil {
+			mt.Fatal(err)
+		}
+	}
+
+	mt.Run("successful", func(mt *mtest.T) {
+		e, _, rec := dispatch(mt)
+		settle(e, rec, true)
+		cmds := sent(mt)
+		closing := find(cmds, "update", "withdrawals")
+		if str(closing.set().Lookup("status")) != withdrawal.Completed {
+			mt.Fatalf("close %s", closing.statement())
+		}
+		msg, cmd := enqueued(mt.T, cmds, "release:w1")
+		sameTransaction(mt.T, cmds, closing, msg)
+		if !cmd.Release.Success {
+			mt.Fatalf("queued %+v", cmd.Release)
+		}
+		if e.counters.total() == 0 {
+			mt.Fatal("a completed withdrawal gave back its limits")
+		}
+	})
+	mt.Run("failed", func(mt *mtest.T) {
+		e, _, rec := dispatch(mt)
+		settle(e, rec, false)
+		_, cmd := enqueued(mt.T, sent(mt), "release:w1")
+		if cmd.Release.Success {
+			mt.Fatalf("queued %+v", cmd.Release)
+		}
+		e.deliverQueued(mt.T, "release:w1", cmd)
+		if calls := e.wallet.Calls(); len(calls) != 1 || calls[0].Body["success"] != false {
+			mt.Fatalf("wallet calls %+v", calls)
+		}
+		if e.counters.total() != 0 {
+			mt.Fatalf("limits not released: %v", e.counters)
+		}
+	})
+	mt.Run("already settled", func(mt *mtest.T) {
+		e := newTestEnv(mt)
+		rec := momoWithdrawal(withdrawal.Completed)
+		mt.AddMockResponses(found(mt.T, "withdrawals", rec))
+		if err := e.h.settleWithdrawal(context.Background(), rec.ProviderRef, false); err != nil {
+			mt.Fatal(err)
+		}
+		if cmds := sent(mt); len(cmds) != 1 {
+			mt.Fatalf("a settled withdrawal was changed: %+v", cmds)
+		}
+	})
+}
+
+func TestMoMoWithdrawalDispatchOutcomeUnknown(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mockFlutterwave := func(e *testEnv) *flutterwavemock.Server {
+		fw, url := flutterwavemock.NewTestServer(e.mt.T, flutterwavemock.Config{SecretKey: "sk"})
+		e.h.flutterClient = flutterwave.NewClient("sk", url, "")
+		return fw
+	}
+
+	mt.Run("late success after a reject attempt", func(mt *mtest.T) {
+		e := newTestEnv(mt)
+		fw := mockFlutterwave(e)
+		rec := momoWithdrawal(withdrawal.Approved)
+		if err := e.h.limits.Reserve(context.Background(), withdrawalLimitsRequest(rec)); err != nil {
+			mt.Fatal(err)
+		}
+		// Every attempt, retries included, ends in a gateway error.
+		for i := 0; i < 3; i++ {
+			fw.FailNext(flutterwavemock.RouteTransfer, http.StatusBadGateway, "upstream timeout")
+		}
+		// To PROCESSING, audit, dispatch error.
+		mt.AddMockResponses(updated(1), ok(), updated(1))
+		if err := e.h.approveWithdrawal(context.Background(), rec, "admin", "", true); err != nil {
+			mt.Fatal(err)
+		}
+		if rec.Status != withdrawal.Processing || rec.DispatchError == "" {
+			mt.Fatalf("withdrawal %s, dispatch error %q", rec.Status, rec.DispatchError)
+		}
+		if c := sent(mt)[2]; str(c.set().Lookup("dispatchError")) == "" {
+			mt.Fatalf("dispatch error not stored: %s", c.statement())
+		}
+		mt.ClearEvents()
+
+		mt.AddMockResponses(found(mt.T, "withdrawals", rec))
+		if code := review(mt.T, e.h.RejectWithdrawal, rec.ID, `{"reason":"no payout"}`); code != http.StatusConflict {
+			mt.Fatalf("reject answered %d", code)
+		}
+		if cmds := sent(mt); len(cmds) != 1 {
+			mt.Fatalf("reject changed the withdrawal: %+v", cmds)
+		}
+		mt.ClearEvents()
+
+		// The transfer went out after all.
+		mt.AddMockResponses(found(mt.T, "withdrawals", rec), updated(1), updated(1), ok(), ok(), nothingDue())
+		if err := e.h.settleWithdrawal(context.Background(), rec.ProviderRef, true); err != nil {
+			mt.Fatal(err)
+		}
+		_, cmd := enqueued(mt.T, sent(mt), "release:w1")
+		if !cmd.Release.Success {
+			mt.Fatalf("queued %+v", cmd.Release)
+		}
+		if e.counters.total() == 0 {
+			mt.Fatal("a completed withdrawal gave back its limits")
+		}
+	})
+	mt.Run("refused", func(mt *mtest.T) {
+		e := newTestEnv(mt)
+		fw := mockFlutterwave(e)
+		rec := momoWithdrawal(withdrawal.Approved)
+		fw.FailNext(flutterwavemock.RouteTransfer, http.StatusBadRequest, "invalid account")
+		// To PROCESSING, audit, back to APPROVED, audit.
+		mt.AddMockResponses(updated(1), ok(), updated(1), ok())
+		if err := e.h.approveWithdrawal(context.Background(), rec, "admin", "", true); !errors.Is(err, errDispatch) {
+			mt.Fatalf("approve: %v", err)
+		}
+		if rec.Status != withdrawal.Approved || rec.DispatchError == "" {
+			mt.Fatalf("withdrawal %s, dispatch error %q", rec.Status, rec.DispatchError)
+		}
+	})
+	mt.Run("poller finds no transfer", func(mt *mtest.T) {
+		e := newTestEnv(mt)
+		mockFlutterwave(e)
+		rec := momoWithdrawal(withdrawal.Processing)
+		rec.DispatchError = "upstream timeout"
+		rec.DispatchedAt = time.Now().Add(-10 * time.Minute)
+		// Poll, back to APPROVED, audit.
+		mt.AddMockResponses(found(mt.T, "withdrawals", rec), updated(1), ok())
+		e.h.pollPendingWithdrawals(context.Background())
+		c := find(sent(mt), "update", "withdrawals")
+		if c == nil || str(c.set().Lookup("status")) != withdrawal.Approved {
+			mt.Fatalf("withdrawal not returned to APPROVED: %+v", sent(mt))
+		}
+	})
+	mt.Run("poller leaves an unconfirmed transfer", func(mt *mtest.T) {
+		e := newTestEnv(mt)
+		fw := mockFlutterwave(e)
+		rec := momoWithdrawal(withdrawal.Processing)
+		rec.DispatchError = "upstream timeout"
+		rec.DispatchedAt = time.Now().Add(-10 * time.Minute)
+		for i := 0; i < 3; i++ {
+			fw.FailNext(flutterwavemock.RouteTransferLookup, http.StatusBadGateway, "upstream timeout")
+		}
+		mt.AddMockResponses(found(mt.T, "withdrawals", rec))
+		e.h.pollPendingWithdrawals(context.Background())
+		if cmds := sent(mt); len(cmds) != 1 {
+			mt.Fatalf("withdrawal changed without a lookup: %+v", cmds)
+		}
+	})
+}
+
+func TestRejectDispatchedMoMoWithdrawal(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	// setup returns an APPROVED withdrawal whose dispatch failed, with
+	// Flutterwave mocked.
+	setup := func(mt *mtest.T) (*testEnv, *flutterwavemock.Server, *withdrawalRecord) {
+		e := newTestEnv(mt)
+		fw, url := flutterwavemock.NewTestServer(mt.T, flutterwavemock.Config{SecretKey: "sk"})
+		e.h.flutterClient = flutterwave.NewClient("sk", url, "")
+		rec := momoWithdrawal(withdrawal.Approved)
+		rec.DispatchError = "upstream timeout; Flutterwave has no such transfer"
+		return e, fw, rec
+	}
+
+	mt.Run("no transfer", func(mt *mtest.T) {
+		e, fw, rec := setup(mt)
+		mt.AddMockResponses(found(mt.T, "withdrawals", rec), updated(1), updated(1), ok(), ok(), nothingDue())
+		if code := review(mt.T, e.h.RejectWithdrawal, rec.ID, `{"reason":"bad account"}`); code != http.StatusOK {
+			mt.Fatalf("reject answered %d", code)
+		}
+		if n := len(fw.Requests(flutterwavemock.RouteTransferLookup)); n != 1 {
+			mt.Fatalf("%d transfer lookups", n)
+		}
+		_, cmd := enqueued(mt.T, sent(mt), "release:w1")
+		if cmd.Release.Success {
+			mt.Fatalf("queued %+v", cmd.Release)
+		}
+	})
+	mt.Run("transfer exists", func(mt *mtest.T) {
+		e, _, rec := setup(mt)
+		_, err := e.h.flutterClient.InitiateTransfer(context.Background(), flutterwave.TransferRequest{
+			Reference: rec.ProviderRef, Amount: rec.FinalAmount, Currency: rec.Currency,
+			AccountBank: "MTN", AccountNumber: rec.Phone,
+		}, rec.ProviderRef)
+		if err != nil {
+			mt.Fatal(err)
+		}
+		mt.AddMockResponses(found(mt.T, "withdrawals", rec))
+		if code := review(mt.T, e.h.RejectWithdrawal, rec.ID, `{"reason":"bad account"}`); code != http.StatusConflict {
+			mt.Fatalf("reject answered %d", code)
+		}
+		if cmds := sent(mt); len(cmds) != 1 {
+			mt.Fatalf("reject changed the withdrawal: %+v", cmds)
+		}
+	})
+	mt.Run("lookup fails", func(mt *mtest.T) {
+		e, fw, rec := setup(mt)
+		for i := 0; i < 3; i++ {
+			fw.FailNext(flutterwavemock.RouteTransferLookup, http.StatusBadGateway, "upstream timeout")
+		}
+		mt.AddMockResponses(found(mt.T, "withdrawals", rec))
+		if code := review(mt.T, e.h.RejectWithdrawal, rec.ID, `{"reason":"bad account"}`); code != http.StatusBadGateway {
+			mt.Fatalf("reject answered %d", code)
+		}
+		if cmds := sent(mt); len(cmds) != 1 {
+			mt.Fatalf("reject changed the withdrawal: %+v", cmds)
+		}
+	})
+}
+
+func TestWithdrawalQueueFilter(t *testing.T) {
+	query := func(params map[string]string) func(string, ...string) string {
+		return func(key string, _ ...string) string { return params[key] }
+	}
+	filter, err := withdrawalQueueFilter(query(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	or, _ := filter["$or"].(bson.A)
+	if len(or) != 2 || or[1].(bson.M)["status"] != withdrawal.Approved {
+		t.Fatalf("default filter %v", filter)
+	}
+	filter, err = withdrawalQueueFilter(query(map[string]string{"status": "approved"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := filter["$or"]; ok || filter["status"] == nil {
+		t.Fatalf("status filter %v", filter)
+	}
+}
@@ -0,0 +1,109 @@
+// Package kyc maps a user's verification level, set by auth-service on
+// approval, to the per-transaction amounts payment-gateway accepts.
+package kyc
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultLimits applies when KYC_TIER_LIMITS is unset or malformed:
+// unverified users get small amounts, ID-verified users more, and
+// address-verified users are uncapped.
+const DefaultLimits = "0=200/50,1=2000/1000,2=*/*"
+
+// Limit caps single deposits and withdrawals. math.Inf(1) means no cap; 0
+// blocks the operation.
+type Limit struct {
+	MaxDeposit    float64 `json:"maxDeposit"`
+	MaxWithdrawal float64 `json:"maxWithdrawal"`
+}
+
+// Limits are keyed by KYC level.
+type Limits map[int]Limit
+
+// ParseLimits reads comma-separated "level=deposit/withdrawal" pairs, e.g.
+// "0=200/50,1=2000/1000,2=*/*", where "*" means no cap.
+func ParseLimits(raw string) (Limits, error) {
+	limits := Limits{}
+	for _, pair := range strings.Split(raw, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		levelRaw, amounts, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("kyc limits: %q is not level=deposit/withdrawal", pair)
+		}
+		level, err := strconv.Atoi(strings.TrimSpace(levelRaw))
+		if err != nil || level < 0 {
+			return nil, fmt.Errorf("kyc limits: bad level in %q", pair)
+		}
+		depositRaw, withdrawalRaw, ok := strings.Cut(amounts, "/")
+		if !ok {
+			return nil, fmt.Errorf("kyc limits: %q is not level=deposit/withdrawal", pair)
+		}
+		deposit, err := parseAmount(depositRaw)
+		if err != nil {
+			return nil, fmt.Errorf("kyc limits: %q: %w", pair, err)
+		}
+		withdrawal, err := parseAmount(withdrawalRaw)
+		if err != nil {
+			return nil, fmt.Errorf("kyc limits: %q: %w", pair, err)
+		}
+		limits[level] = Limit{MaxDeposit: deposit, MaxWithdrawal: withdrawal}
+	}
+	if _, ok := limits[0]; !ok {
+		return nil, fmt.Errorf("kyc limits: level 0 must be configured")
+	}
+	return limits, nil
+}
+
+func parseAmount(raw string) (float64, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "*" {
+		return math.Inf(1), nil
+	}
+	amount, err := strconv.ParseFloat(raw, 64)
+	if err != nil || amount < 0 || math.IsNaN(amount) {
+		return 0, fmt.Errorf("bad amount %q", raw)
+	}
+	return amount, nil
+}
+
+// For returns the limit of the highest configured level not above level.
+func (l Limits) For(level int) Limit {
+	levels := make([]int, 0, len(l))
+	for configured := range l {
+		levels = append(levels, configured)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(levels)))
+	for _, configured := range levels {
+		if configured <= level {
+			return l[configured]
+		}
+	}
+	return Limit{}
+}
+
+// Operations checked against a Limit.
+const (
+	Deposit    = "deposit"
+	Withdrawal = "withdrawal"
+)
+
+// Max returns the cap for operation.
+func (l Limit) Max(operation string) float64 {
+	if operation == Deposit {
+		return l.MaxDeposit
+	}
+	return l.MaxWithdrawal
+}
+
+// Allows reports whether a single operation of amount fits under the cap.
+func (l Limit) Allows(operation string, amount float64) bool {
+	return amount <= l.Max(operation)
+}
@@ -0,0 +1,43 @@
+package kyc
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseLimits(t *testing.T) {
+	limits, err := ParseLimits(DefaultLimits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := limits.For(0); got.MaxDeposit != 200 || got.MaxWithdrawal != 50 {
+		t.Fatalf("level 0: %+v", got)
+	}
+	if got := limits.For(2); !math.IsInf(got.MaxWithdrawal, 1) {
+		t.Fatalf("level 2 should be uncapped: %+v", got)
+	}
+	// Levels above the highest configured one inherit it.
+	if got := limits.For(5); !math.IsInf(got.MaxDeposit, 1) {
+		t.Fatalf("level 5: %+v", got)
+	}
+
+	for _, raw := range []string{"", "1=10/10", "0=10", "0=-1/5", "x=1/1", "0=1/abc"} {
+		if _, err := ParseLimits(raw); err == nil {
+			t.Errorf("%q should be rejected", raw)
+		}
+	}
+}
+
+func TestLimitAllows(t *testing.T) {
+	limits, _ := ParseLimits("0=100/0,1=1000/500")
+	unverified := limits.For(0)
+	if !unverified.Allows(Deposit, 100) || unverified.Allows(Deposit, 100.01) {
+		t.Fatal("deposit cap is inclusive")
+	}
+	if unverified.Allows(Withdrawal, 1) {
+		t.Fatal("a zero cap blocks withdrawals")
+	}
+	if !limits.For(1).Allows(Withdrawal, 500) {
+		t.Fatal("level 1 withdrawal of 500 should pass")
+	}
+}
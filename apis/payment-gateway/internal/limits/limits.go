@@ -0,0 +1,487 @@
+// Package limits decides whether a deposit or withdrawal may go ahead:
+// per-transaction minimums and maximums, the KYC tier's single-transaction
+// ceiling, daily, weekly and monthly totals per user and per channel that
+// grow with the KYC tier, and a daily cap on all withdrawals. Totals are
+// counted in whole cents in Counters, keyed by calendar period (UTC, weeks
+// starting Monday), so a transaction is released into the period it was
+// counted in.
+package limits
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gamehub/payment-gateway/internal/kyc"
+)
+
+// Defaults apply when the corresponding setting is unset or malformed.
+// Velocity caps tighten for unverified users and lift at level 2;
+// transactions need at least 1 unit and nothing caps total outflow.
+const (
+	DefaultTransaction = "deposit=1/*,withdrawal=1/*"
+	DefaultVelocity    = "0:deposit=500/1500/3000,0:withdrawal=100/300/600," +
+		"1:deposit=10000/30000/60000,1:withdrawal=5000/15000/30000," +
+		"2:deposit=*/*/*,2:withdrawal=*/*/*"
+)
+
+// Windows, in the order they are reported.
+const (
+	Day   = "daily"
+	Week  = "weekly"
+	Month = "monthly"
+)
+
+var windows = []string{Day, Week, Month}
+
+// Violation codes.
+const (
+	CodeBelowMinimum = "AMOUNT_BELOW_MINIMUM"
+	CodeAboveMaximum = "AMOUNT_ABOVE_MAXIMUM"
+	CodeKYC          = "KYC_LIMIT_EXCEEDED"
+	CodeVelocity     = "VELOCITY_LIMIT_EXCEEDED"
+	CodeGlobal       = "GLOBAL_LIMIT_EXCEEDED"
+)
+
+// TxRule bounds single transactions of Operation. An empty Channel
+// matches every channel.
+type TxRule struct {
+	Operation string
+	Channel   string
+	Min       float64
+	Max       float64
+}
+
+// VelocityRule caps the total of Operation per window. An empty Channel
+// caps all channels together, "*" each channel on its own, and a name
+// that channel only.
+type VelocityRule struct {
+	Operation string
+	Channel   string
+	Daily     float64
+	Weekly    float64
+	Monthly   float64
+}
+
+func (r VelocityRule) max(window string) float64 {
+	switch window {
+	case Day:
+		return r.Daily
+	case Week:
+		return r.Weekly
+	default:
+		return r.Monthly
+	}
+}
+
+// Policy is the full set of limits. math.Inf(1) means no cap.
+type Policy struct {
+	Transaction []TxRule
+	// Velocity rules are keyed by KYC level; a user gets the rules of the
+	// highest configured level not above theirs.
+	Velocity map[int][]VelocityRule
+	KYC      kyc.Limits
+	// GlobalDailyOutflow caps the withdrawals of all users per day.
+	GlobalDailyOutflow float64
+}
+
+// ParseTransactionRules reads comma-separated "operation[:channel]=min/max"
+// rules, e.g. "deposit=1/*,withdrawal:crypto=20/5000", where "*" means no
+// cap.
+func ParseTransactionRules(raw string) ([]TxRule, error) {
+	var rules []TxRule
+	for _, pair := range splitRules(raw) {
+		scope, amounts, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("transaction limits: %q is not operation=min/max", pair)
+		}
+		op, channel, err := parseScope(scope)
+		if err != nil {
+			return nil, fmt.Errorf("transaction limits: %q: %w", pair, err)
+		}
+		values, err := parseAmounts(amounts, 2)
+		if err != nil {
+			return nil, fmt.Errorf("transaction limits: %q: %w", pair, err)
+		}
+		if math.IsInf(values[0], 1) || values[0] > values[1] {
+			return nil, fmt.Errorf("transaction limits: %q: min must not exceed max", pair)
+		}
+		rules = append(rules, TxRule{Operation: op, Channel: channel, Min: values[0], Max: values[1]})
+	}
+	return rules, nil
+}
+
+// ParseVelocityRules reads comma-separated
+// "level:operation[:channel]=daily/weekly/monthly" rules, e.g.
+// "0:withdrawal=100/300/600,0:withdrawal:*=50/150/300". Level 0 must be
+// configured unless there are no rules.
+func ParseVelocityRules(raw string) (map[int][]VelocityRule, error) {
+	rules := map[int][]VelocityRule{}
+	for _, pair := range splitRules(raw) {
+		scope, amounts, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("velocity limits: %q is not level:operation=daily/weekly/monthly", pair)
+		}
+		levelRaw, scope, ok := strings.Cut(scope, ":")
+		if !ok {
+			return nil, fmt.Errorf("velocity limits: %q has no level", pair)
+		}
+		level, err := strconv.Atoi(strings.TrimSpace(levelRaw))
+		if err != nil || level < 0 {
+			return nil, fmt.Errorf("velocity limits: bad level in %q", pair)
+		}
+		op, channel, err := parseScope(scope)
+		if err != nil {
+			return nil, fmt.Errorf("velocity limits: %q: %w", pair, err)
+		}
+		values, err := parseAmounts(amounts, 3)
+		if err != nil {
+			return nil, fmt.Errorf("velocity limits: %q: %w", pair, err)
+		}
+		rules[level] = append(rules[level], VelocityRule{
+			Operation: op, Channel: channel, Daily: values[0], Weekly: values[1], Monthly: values[2],
+		})
+	}
+	if _, ok := rules[0]; len(rules) > 0 && !ok {
+		return nil, fmt.Errorf("velocity limits: level 0 must be configured")
+	}
+	return rules, nil
+}
+
+// ParseAmount reads a cap, where "*" means none.
+func ParseAmount(raw string) (float64, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "*" {
+		return math.Inf(1), nil
+	}
+	amount, err := strconv.ParseFloat(raw, 64)
+	if err != nil || amount < 0 || math.IsNaN(amount) {
+		return 0, fmt.Errorf("bad amount %q", raw)
+	}
+	return amount, nil
+}
+
+func splitRules(raw string) []string {
+	var out []string
+	for _, pair := range strings.Split(raw, ",") {
+		if pair = strings.TrimSpace(pair); pair != "" {
+			out = append(out, pair)
+		}
+	}
+	return out
+}
+
+func parseScope(scope string) (string, string, error) {
+	op, channel, _ := strings.Cut(strings.TrimSpace(scope), ":")
+	op = strings.ToLower(strings.TrimSpace(op))
+	if op != kyc.Deposit && op != kyc.Withdrawal {
+		return "", "", fmt.Errorf("unknown operation %q", op)
+	}
+	return op, strings.ToLower(strings.TrimSpace(channel)), nil
+}
+
+func parseAmounts(raw string, n int) ([]float64, error) {
+	parts := strings.Split(raw, "/")
+	if len(parts) != n {
+		return nil, fmt.Errorf("want %d amounts separated by /", n)
+	}
+	out := make([]float64, n)
+	for i, p := range parts {
+		v, err := ParseAmount(p)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+// velocity returns the rules for a KYC level.
+func (p Policy) velocity(level int) []VelocityRule {
+	levels := make([]int, 0, len(p.Velocity))
+	for configured := range p.Velocity {
+		levels = append(levels, configured)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(levels)))
+	for _, configured := range levels {
+		if configured <= level {
+			return p.Velocity[configured]
+		}
+	}
+	return nil
+}
+
+// bounds returns the single-transaction range for operation on channel
+// at a KYC level, and the code to report when max is exceeded.
+func (p Policy) bounds(level int, operation, channel string) (min, max float64, maxCode string) {
+	max, maxCode = math.Inf(1), CodeAboveMaximum
+	for _, r := range p.Transaction {
+		if r.Operation != operation || (r.Channel != "" && r.Channel != "*" && r.Channel != channel) {
+			continue
+		}
+		min = math.Max(min, r.Min)
+		max = math.Min(max, r.Max)
+	}
+	if p.KYC != nil {
+		if ceiling := p.KYC.For(level).Max(operation); ceiling < max {
+			max, maxCode = ceiling, CodeKYC
+		}
+	}
+	return min, max, maxCode
+}
+
+// Request is a deposit or withdrawal to check or count.
+type Request struct {
+	UserID    string
+	Operation string // kyc.Deposit or kyc.Withdrawal
+	Channel   string // MoMo channel, or "crypto"
+	KYCLevel  int
+	Amount    float64
+	// At is when the transaction was made; zero means now. Release needs
+	// the original time to uncount it from the right periods.
+	At time.Time
+}
+
+// Violation is returned by Reserve when a request breaks a limit.
+type Violation struct {
+	Code string
+	// Window is the velocity window broken, if any; Channel is set when
+	// the cap was the channel's.
+	Window  string
+	Channel string
+	// Limit is the cap broken and Remaining what was left under it.
+	Limit     float64
+	Remaining float64
+	message   string
+}
+
+func (v *Violation) Error() string {
+	return v.message
+}
+
+// counter is one total a request counts towards, with its cap.
+type counter struct {
+	key     Key
+	max     float64
+	window  string
+	channel string // the channel whose own total this is
+	global  bool
+}
+
+// counters lists every total req counts towards. Totals are kept whether
+// capped or not, so a cap added later applies to the period's history.
+func (p Policy) counters(req Request, at time.Time) []counter {
+	rules := p.velocity(req.KYCLevel)
+	var out []counter
+	for _, w := range windows {
+		all := counter{key: periodKey(w, at, "user", req.UserID, req.Operation, "all"), max: math.Inf(1), window: w}
+		own := counter{key: periodKey(w, at, "user", req.UserID, req.Operation, "ch", req.Channel), max: math.Inf(1), window: w, channel: req.Channel}
+		for _, r := range rules {
+			if r.Operation != req.Operation {
+				continue
+			}
+			switch r.Channel {
+			case "":
+				all.max = math.Min(all.max, r.max(w))
+			case "*", req.Channel:
+				own.max = math.Min(own.max, r.max(w))
+			}
+		}
+		out = append(out, all)
+		if req.Channel != "" {
+			out = append(out, own)
+		}
+	}
+	if req.Operation == kyc.Withdrawal {
+		out = append(out, counter{key: periodKey(Day, at, "global", req.Operation), max: p.GlobalDailyOutflow, window: Day, global: true})
+	}
+	return out
+}
+
+// periodKey names a counter for window's period containing t. It
+// expires a day after the period ends.
+func periodKey(window string, t time.Time, parts ...string) Key {
+	t = t.UTC()
+	var start, end time.Time
+	var id string
+	switch window {
+	case Day:
+		start = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		end = start.AddDate(0, 0, 1)
+		id = start.Format("d20060102")
+	case Week:
+		offset := (int(t.Weekday()) + 6) % 7 // days since Monday
+		start = time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, time.UTC)
+		end = start.AddDate(0, 0, 7)
+		id = start.Format("w20060102")
+	default:
+		start = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+		end = start.AddDate(0, 1, 0)
+		id = start.Format("m200601")
+	}
+	return Key{
+		ID:        "limits:" + strings.Join(append(parts, id), ":"),
+		ExpiresAt: end.Add(24 * time.Hour),
+	}
+}
+
+// Engine applies a Policy, counting transactions in Counters.
+type Engine struct {
+	policy   Policy
+	counters Counters
+	now      func() time.Time
+}
+
+// NewEngine returns an engine enforcing p.
+func NewEngine(p Policy, c Counters) *Engine {
+	return &Engine{policy: p, counters: c, now: time.Now}
+}
+
+// Reserve checks req against the limits and, when it passes, counts it
+// towards the user's, channel's and global totals. It returns a
+// *Violation when a limit is broken. Call Release if the transaction
+// doesn't go ahead.
+func (e *Engine) Reserve(ctx context.Context, req Request) error {
+	if req.At.IsZero() {
+		req.At = e.now()
+	}
+	min, max, maxCode := e.policy.bounds(req.KYCLevel, req.Operation, req.Channel)
+	switch {
+	case req.Amount < min:
+		return &Violation{Code: CodeBelowMinimum, Limit: min,
+			message: fmt.Sprintf("the minimum %s is %.2f", req.Operation, min)}
+	case req.Amount > max && maxCode == CodeKYC && max == 0:
+		return &Violation{Code: CodeKYC, Limit: 0,
+			message: fmt.Sprintf("verify your identity to make a %s", req.Operation)}
+	case req.Amount > max && maxCode == CodeKYC:
+		return &Violation{Code: CodeKYC, Limit: max, Remaining: max,
+			message: fmt.Sprintf("%s exceeds your verification limit of %.2f", req.Operation, max)}
+	case req.Amount > max:
+		return &Violation{Code: CodeAboveMaximum, Limit: max, Remaining: max,
+			message: fmt.Sprintf("the maximum %s is %.2f", req.Operation, max)}
+	}
+
+	counters := e.policy.counters(req, req.At)
+	keys := make([]Key, len(counters))
+	for i, c := range counters {
+		keys[i] = c.key
+	}
+	delta := cents(req.Amount)
+	totals, err := e.counters.Add(ctx, keys, delta)
+	if err != nil {
+		return err
+	}
+	for i, c := range counters {
+		if totals[i] <= cents(c.max) {
+			continue
+		}
+		if _, err := e.counters.Add(ctx, keys, -delta); err != nil {
+			return fmt.Errorf("limits: undo %s for %s: %w", req.Operation, req.UserID, err)
+		}
+		return violation(req, c, units(totals[i]-delta))
+	}
+	return nil
+}
+
+// Release uncounts a reserved transaction that didn't go ahead.
+func (e *Engine) Release(ctx context.Context, req Request) error {
+	if req.At.IsZero() {
+		req.At = e.now()
+	}
+	counters := e.policy.counters(req, req.At)
+	keys := make([]Key, len(counters))
+	for i, c := range counters {
+		keys[i] = c.key
+	}
+	_, err := e.counters.Add(ctx, keys, -cents(req.Amount))
+	return err
+}
+
+func violation(req Request, c counter, used float64) *Violation {
+	v := &Violation{Code: CodeVelocity, Window: c.window, Channel: c.channel, Limit: c.max, Remaining: math.Max(0, c.max-used)}
+	switch {
+	case c.global:
+		v.Code = CodeGlobal
+		v.message = fmt.Sprintf("%ss are paused for today; please try again tomorrow", req.Operation)
+	case c.channel != "":
+		v.message = fmt.Sprintf("%s exceeds your %s limit of %.2f on %s (%.2f left)", req.Operation, c.window, c.max, c.channel, v.Remaining)
+	default:
+		v.message = fmt.Sprintf("%s exceeds your %s limit of %.2f (%.2f left)", req.Operation, c.window, c.max, v.Remaining)
+	}
+	return v
+}
+
+// Window is a velocity cap and how much of it is used.
+type Window struct {
+	Window    string
+	Channel   string
+	Limit     float64
+	Used      float64
+	Remaining float64
+}
+
+// Summary is what a user may still do for one operation. Max and
+// Available may be math.Inf(1).
+type Summary struct {
+	Operation string
+	Min       float64
+	Max       float64
+	// Available is the largest transaction that would pass now.
+	Available float64
+	// Windows lists the capped windows.
+	Windows []Window
+}
+
+// Remaining reports the limits of operation for a user at a KYC level,
+// with usage in the current periods. A non-empty channel adds that
+// channel's own caps.
+func (e *Engine) Remaining(ctx context.Context, userID string, level int, operation, channel string) (Summary, error) {
+	req := Request{UserID: userID, Operation: operation, Channel: channel, KYCLevel: level}
+	min, max, _ := e.policy.bounds(level, operation, channel)
+	s := Summary{Operation: operation, Min: min, Max: max, Available: max, Windows: []Window{}}
+
+	var counters []counter
+	for _, c := range e.policy.counters(req, e.now()) {
+		if !math.IsInf(c.max, 1) {
+			counters = append(counters, c)
+		}
+	}
+	keys := make([]Key, len(counters))
+	for i, c := range counters {
+		keys[i] = c.key
+	}
+	totals, err := e.counters.Get(ctx, keys)
+	if err != nil {
+		return s, err
+	}
+	for i, c := range counters {
+		used := units(totals[i])
+		left := math.Max(0, c.max-used)
+		s.Available = math.Min(s.Available, left)
+		if c.global {
+			continue // platform capacity isn't the user's to see
+		}
+		s.Windows = append(s.Windows, Window{Window: c.window, Channel: c.channel, Limit: c.max, Used: used, Remaining: left})
+	}
+	if s.Available < min {
+		s.Available = 0
+	}
+	return s, nil
+}
+
+// cents converts an amount to whole cents; an uncapped amount is the
+// largest count.
+func cents(amount float64) int64 {
+	if math.IsInf(amount, 1) || amount >= math.MaxInt64/100 {
+		return math.MaxInt64
+	}
+	return int64(math.Round(amount * 100))
+}
+
+func units(c int64) float64 {
+	return float64(c) / 100
+}
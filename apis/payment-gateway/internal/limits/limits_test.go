@@ -0,0 +1,172 @@
+package limits
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"gamehub/payment-gateway/internal/kyc"
+)
+
+type memCounters map[string]int64
+
+func (m memCounters) Add(_ context.Context, keys []Key, delta int64) ([]int64, error) {
+	out := make([]int64, len(keys))
+	for i, k := range keys {
+		m[k.ID] += delta
+		out[i] = m[k.ID]
+	}
+	return out, nil
+}
+
+func (m memCounters) Get(_ context.Context, keys []Key) ([]int64, error) {
+	out := make([]int64, len(keys))
+	for i, k := range keys {
+		out[i] = m[k.ID]
+	}
+	return out, nil
+}
+
+func testEngine(t *testing.T, tx, velocity string, global float64) (*Engine, memCounters) {
+	t.Helper()
+	txRules, err := ParseTransactionRules(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	velocityRules, err := ParseVelocityRules(velocity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kycLimits, _ := kyc.ParseLimits("0=200/50,1=2000/1000,2=*/*")
+	counters := memCounters{}
+	e := NewEngine(Policy{Transaction: txRules, Velocity: velocityRules, KYC: kycLimits, GlobalDailyOutflow: global}, counters)
+	e.now = func() time.Time { return time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC) } // a Wednesday
+	return e, counters
+}
+
+func code(err error) string {
+	var v *Violation
+	if errors.As(err, &v) {
+		return v.Code
+	}
+	return ""
+}
+
+func TestParseRules(t *testing.T) {
+	if _, err := ParseTransactionRules(DefaultTransaction); err != nil {
+		t.Fatal(err)
+	}
+	velocity, err := ParseVelocityRules(DefaultVelocity)
+	if err != nil || len(velocity) != 3 {
+		t.Fatalf("%v %v", velocity, err)
+	}
+	if rules, _ := ParseVelocityRules(""); len(rules) != 0 {
+		t.Fatal("no velocity rules means no caps")
+	}
+	for _, raw := range []string{"deposit=5", "transfer=1/2", "deposit=10/5", "deposit=*/*", "deposit=1/x"} {
+		if _, err := ParseTransactionRules(raw); err == nil {
+			t.Errorf("transaction %q should be rejected", raw)
+		}
+	}
+	for _, raw := range []string{"deposit=1/2/3", "1:deposit=1/2/3", "0:deposit=1/2", "x:deposit=1/2/3", "0:refund=1/2/3"} {
+		if _, err := ParseVelocityRules(raw); err == nil {
+			t.Errorf("velocity %q should be rejected", raw)
+		}
+	}
+}
+
+func TestTransactionBounds(t *testing.T) {
+	e, counters := testEngine(t, "deposit=1/*,withdrawal=5/*,withdrawal:crypto=20/500", "", math.Inf(1))
+	ctx := context.Background()
+
+	cases := []struct {
+		req  Request
+		want string
+	}{
+		{Request{UserID: "u", Operation: kyc.Deposit, Channel: "mtn-gh", Amount: 0.5}, CodeBelowMinimum},
+		{Request{UserID: "u", Operation: kyc.Deposit, Channel: "mtn-gh", Amount: 250}, CodeKYC},
+		{Request{UserID: "u", Operation: kyc.Withdrawal, Channel: "crypto", KYCLevel: 1, Amount: 10}, CodeBelowMinimum},
+		{Request{UserID: "u", Operation: kyc.Withdrawal, Channel: "crypto", KYCLevel: 2, Amount: 600}, CodeAboveMaximum},
+		{Request{UserID: "u", Operation: kyc.Withdrawal, Channel: "mtn-gh", KYCLevel: 2, Amount: 600}, ""},
+	}
+	for _, tc := range cases {
+		if got := code(e.Reserve(ctx, tc.req)); got != tc.want {
+			t.Errorf("%+v: got %q, want %q", tc.req, got, tc.want)
+		}
+	}
+	if err := e.Reserve(ctx, Request{UserID: "u", Operation: kyc.Withdrawal, Channel: "mtn-gh", Amount: 60}); code(err) != CodeKYC || err.Error() != "withdrawal exceeds your verification limit of 50.00" {
+		t.Fatalf("level 0 withdrawal: %v", err)
+	}
+	// Only the accepted withdrawal was counted.
+	for id, v := range counters {
+		if v != 0 && v != 60000 {
+			t.Fatalf("%s = %d", id, v)
+		}
+	}
+}
+
+func TestVelocityCaps(t *testing.T) {
+	e, counters := testEngine(t, "", "0:deposit=300/400/500,0:deposit:*=250/*/*,1:deposit=*/*/*", math.Inf(1))
+	ctx := context.Background()
+	deposit := func(channel string, amount float64) error {
+		return e.Reserve(ctx, Request{UserID: "u", Operation: kyc.Deposit, Channel: channel, Amount: amount})
+	}
+
+	if err := deposit("mtn-gh", 150); err != nil {
+		t.Fatal(err)
+	}
+	err := deposit("mtn-gh", 150)
+	var v *Violation
+	if !errors.As(err, &v) || v.Code != CodeVelocity || v.Window != Day || v.Channel != "mtn-gh" || v.Remaining != 100 {
+		t.Fatalf("per-channel daily cap: %+v", err)
+	}
+	if err := deposit("vodafone-gh", 150); err != nil {
+		t.Fatalf("another channel has its own cap: %v", err)
+	}
+	if err := deposit("airteltigo-gh", 0.01); !errors.As(err, &v) || v.Channel != "" || v.Window != Day || v.Remaining != 0 {
+		t.Fatalf("user daily cap: %+v", err)
+	}
+
+	// Rejected requests are rolled back and releases uncount.
+	s, _ := e.Remaining(ctx, "u", 0, kyc.Deposit, "mtn-gh")
+	if s.Available != 0 || len(s.Windows) != 4 || s.Windows[0].Used != 300 || s.Windows[1].Used != 150 {
+		t.Fatalf("summary %+v", s)
+	}
+	if err := e.Release(ctx, Request{UserID: "u", Operation: kyc.Deposit, Channel: "vodafone-gh", Amount: 150, At: e.now()}); err != nil {
+		t.Fatal(err)
+	}
+	s, _ = e.Remaining(ctx, "u", 0, kyc.Deposit, "mtn-gh")
+	if s.Available != 100 || s.Max != 200 {
+		t.Fatalf("after release %+v", s)
+	}
+
+	// Verified users are uncapped; their summary lists no windows.
+	if s, _ := e.Remaining(ctx, "u", 1, kyc.Deposit, ""); len(s.Windows) != 0 || s.Available != 2000 {
+		t.Fatalf("level 1 %+v", s)
+	}
+	if counters["limits:user:u:deposit:all:w20261012"] != 15000 {
+		t.Fatalf("weekly counter starts on Monday: %v", counters)
+	}
+}
+
+func TestGlobalOutflow(t *testing.T) {
+	e, _ := testEngine(t, "", "", 1000)
+	ctx := context.Background()
+	withdraw := func(user string, amount float64) error {
+		return e.Reserve(ctx, Request{UserID: user, Operation: kyc.Withdrawal, Channel: "crypto", KYCLevel: 2, Amount: amount})
+	}
+	if err := withdraw("a", 600); err != nil {
+		t.Fatal(err)
+	}
+	if err := withdraw("b", 500); code(err) != CodeGlobal {
+		t.Fatalf("global cap: %v", err)
+	}
+	if err := e.Reserve(ctx, Request{UserID: "b", Operation: kyc.Deposit, Channel: "mtn-gh", KYCLevel: 2, Amount: 5000}); err != nil {
+		t.Fatalf("deposits aren't outflow: %v", err)
+	}
+	if s, _ := e.Remaining(ctx, "b", 2, kyc.Withdrawal, "crypto"); s.Available != 400 || len(s.Windows) != 0 {
+		t.Fatalf("summary %+v", s)
+	}
+}
@@ -0,0 +1,229 @@
+package limits
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Key names a counter and when it may be forgotten.
+type Key struct {
+	ID        string
+	ExpiresAt time.Time
+}
+
+// Counters holds running totals in cents.
+type Counters interface {
+	// Add adds delta to every key and returns the new totals, in order.
+	Add(ctx context.Context, keys []Key, delta int64) ([]int64, error)
+	// Get returns the totals of keys; unknown keys are 0.
+	Get(ctx context.Context, keys []Key) ([]int64, error)
+}
+
+// addExisting increments keys that exist and answers "miss" for the
+// rest, so a counter lost from Redis is restored from Mongo rather than
+// restarted at zero.
+var addExisting = redis.NewScript(`
+local out = {}
+for i, key in ipairs(KEYS) do
+  if redis.call('EXISTS', key) == 1 then
+    out[i] = redis.call('INCRBY', key, ARGV[1])
+  else
+    out[i] = 'miss'
+  end
+end
+return out`)
+
+// Store keeps counters in Redis and writes every change through to the
+// limit_counters collection. Reads come from Redis; keys it has lost are
+// restored from Mongo, and Mongo answers alone while Redis is down. Keys
+// changed during an outage are dropped from Redis once it is back, so
+// they are restored with the changes it missed.
+type Store struct {
+	rdb  *redis.Client
+	coll *mongo.Collection
+
+	mu    sync.Mutex
+	stale map[string]bool
+}
+
+// NewStore returns a Store over rdb and coll.
+func NewStore(rdb *redis.Client, coll *mongo.Collection) *Store {
+	return &Store{rdb: rdb, coll: coll, stale: map[string]bool{}}
+}
+
+// EnsureIndexes expires Mongo counters after their period.
+func (s *Store) EnsureIndexes(ctx context.Context) error {
+	_, err := s.coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	return err
+}
+
+// Add applies delta in Mongo first, so the durable copy never lags, then
+// in Redis, whose totals decide.
+func (s *Store) Add(ctx context.Context, keys []Key, delta int64) ([]int64, error) {
+	models := make([]mongo.WriteModel, len(keys))
+	for i, k := range keys {
+		models[i] = mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": k.ID}).
+			SetUpdate(bson.M{"$inc": bson.M{"value": delta}, "$set": bson.M{"expiresAt": k.ExpiresAt}}).
+			SetUpsert(true)
+	}
+	if _, err := s.coll.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false)); err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, len(keys))
+	for i, k := range keys {
+		ids[i] = k.ID
+	}
+	s.dropStale(ctx)
+	raw, err := addExisting.Run(ctx, s.rdb, ids, delta).Slice()
+	if err != nil {
+		log.Printf("[limits] redis unavailable, counting from mongo: %v", err)
+		s.markStale(ids)
+		return s.mongoTotals(ctx, keys)
+	}
+
+	totals := make([]int64, len(keys))
+	var missed []Key
+	for i, v := range raw {
+		if n, ok := v.(int64); ok {
+			totals[i] = n
+		} else {
+			missed = append(missed, keys[i])
+		}
+	}
+	if len(missed) == 0 {
+		return totals, nil
+	}
+	restored, err := s.mongoTotals(ctx, missed)
+	if err != nil {
+		return nil, err
+	}
+	j := 0
+	for i, v := range raw {
+		if _, ok := v.(int64); ok {
+			continue
+		}
+		totals[i] = restored[j]
+		s.restore(ctx, keys[i], restored[j])
+		j++
+	}
+	return totals, nil
+}
+
+// Get reads totals from Redis, falling back to Mongo for keys Redis
+// doesn't have or when it is down.
+func (s *Store) Get(ctx context.Context, keys []Key) ([]int64, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	ids := make([]string, len(keys))
+	for i, k := range keys {
+		ids[i] = k.ID
+	}
+	s.dropStale(ctx)
+	raw, err := s.rdb.MGet(ctx, ids...).Result()
+	if err != nil {
+		log.Printf("[limits] redis unavailable, reading from mongo: %v", err)
+		return s.mongoTotals(ctx, keys)
+	}
+
+	totals := make([]int64, len(keys))
+	var missed []Key
+	var at []int
+	for i, v := range raw {
+		str, ok := v.(string)
+		if n, err := strconv.ParseInt(str, 10, 64); ok && err == nil {
+			totals[i] = n
+			continue
+		}
+		missed = append(missed, keys[i])
+		at = append(at, i)
+	}
+	if len(missed) == 0 {
+		return totals, nil
+	}
+	restored, err := s.mongoTotals(ctx, missed)
+	if err != nil {
+		return nil, err
+	}
+	for j, i := range at {
+		totals[i] = restored[j]
+	}
+	return totals, nil
+}
+
+func (s *Store) markStale(ids []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range ids {
+		s.stale[id] = true
+	}
+}
+
+// dropStale deletes keys that missed changes while Redis was down.
+func (s *Store) dropStale(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.stale) == 0 {
+		return
+	}
+	ids := make([]string, 0, len(s.stale))
+	for id := range s.stale {
+		ids = append(ids, id)
+	}
+	if err := s.rdb.Del(ctx, ids...).Err(); err != nil {
+		return // still down
+	}
+	s.stale = map[string]bool{}
+}
+
+// restore puts a Mongo total back into Redis unless another request got
+// there first.
+func (s *Store) restore(ctx context.Context, k Key, total int64) {
+	if err := s.rdb.SetArgs(ctx, k.ID, total, redis.SetArgs{Mode: "NX", ExpireAt: k.ExpiresAt}).Err(); err != nil && err != redis.Nil {
+		log.Printf("[limits] restore %s: %v", k.ID, err)
+	}
+}
+
+func (s *Store) mongoTotals(ctx context.Context, keys []Key) ([]int64, error) {
+	ids := make([]string, len(keys))
+	for i, k := range keys {
+		ids[i] = k.ID
+	}
+	cursor, err := s.coll.Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	found := map[string]int64{}
+	for cursor.Next(ctx) {
+		var doc struct {
+			ID    string `bson:"_id"`
+			Value int64  `bson:"value"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, err
+		}
+		found[doc.ID] = doc.Value
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+	totals := make([]int64, len(keys))
+	for i, id := range ids {
+		totals[i] = found[id]
+	}
+	return totals, nil
+}
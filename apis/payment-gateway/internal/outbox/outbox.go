@@ -0,0 +1,107 @@
+// Package outbox delivers commands to other services reliably. A message
+// is written in the same Mongo transaction as the state change that
+// causes it, so the command is never lost when the downstream call fails;
+// a Relay then delivers it with retries until it succeeds or gives up.
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Message statuses.
+const (
+	Pending   = "PENDING"
+	Delivered = "DELIVERED"
+	Dead      = "DEAD"
+	Cancelled = "CANCELLED"
+)
+
+// Message is a command waiting for delivery. ID doubles as the
+// idempotency key: enqueueing the same ID twice keeps the first message,
+// and the receiver is expected to treat repeated deliveries as one.
+type Message struct {
+	ID            string    `bson:"_id" json:"id"`
+	Topic         string    `bson:"topic" json:"topic"`
+	Payload       bson.Raw  `bson:"payload" json:"-"`
+	Status        string    `bson:"status" json:"status"`
+	Attempts      int       `bson:"attempts" json:"attempts"`
+	NextAttemptAt time.Time `bson:"nextAttemptAt" json:"nextAttemptAt"`
+	LockedUntil   time.Time `bson:"lockedUntil" json:"-"`
+	LastError     string    `bson:"lastError,omitempty" json:"lastError,omitempty"`
+	CreatedAt     time.Time `bson:"createdAt" json:"createdAt"`
+	DeliveredAt   time.Time `bson:"deliveredAt,omitempty" json:"deliveredAt,omitempty"`
+}
+
+// Decode unmarshals the message payload into v.
+func (m Message) Decode(v interface{}) error {
+	return bson.Unmarshal(m.Payload, v)
+}
+
+// Enqueue stores a pending message. Pass the transaction's
+// mongo.SessionContext as ctx so the message commits with the state
+// change. A message that already exists under id is left untouched.
+func Enqueue(ctx context.Context, coll *mongo.Collection, id, topic string, payload interface{}) error {
+	raw, err := bson.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	// An upsert rather than an insert: a duplicate key error would abort
+	// the surrounding transaction.
+	now := time.Now()
+	_, err = coll.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$setOnInsert": bson.M{
+		"topic":         topic,
+		"payload":       bson.Raw(raw),
+		"status":        Pending,
+		"attempts":      0,
+		"nextAttemptAt": now,
+		"lockedUntil":   now,
+		"createdAt":     now,
+	}}, options.Update().SetUpsert(true))
+	return err
+}
+
+// Cancel withdraws a message that was never delivered and is not being
+// delivered now, reporting whether it did. When it reports false the
+// message may have reached the receiver, and the caller has to undo its
+// effect instead. Pass the transaction's mongo.SessionContext as ctx so
+// the cancellation commits with the state change.
+func Cancel(ctx context.Context, coll *mongo.Collection, id string) (bool, error) {
+	now := time.Now()
+	res, err := coll.UpdateOne(ctx,
+		bson.M{
+			"_id":         id,
+			"status":      bson.M{"$in": bson.A{Pending, Dead}},
+			"lockedUntil": bson.M{"$lte": now},
+		},
+		bson.M{"$set": bson.M{"status": Cancelled, "lockedUntil": now}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount == 1, nil
+}
+
+// Backoff returns the wait before retry number attempt (1-based): 5s
+// doubling up to a 15 minute ceiling.
+func Backoff(attempt int) time.Duration {
+	const (
+		base    = 5 * time.Second
+		ceiling = 15 * time.Minute
+	)
+	if attempt < 1 {
+		attempt = 1
+	}
+	d := base
+	for i := 1; i < attempt; i++ {
+		d *= 2
+		if d >= ceiling {
+			return ceiling
+		}
+	}
+	return d
+}
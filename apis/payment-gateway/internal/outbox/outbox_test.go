@@ -0,0 +1,56 @@
+package outbox
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestBackoff(t *testing.T) {
+	cases := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{0, 5 * time.Second},
+		{1, 5 * time.Second},
+		{2, 10 * time.Second},
+		{4, 40 * time.Second},
+		{8, 640 * time.Second},
+		{9, 15 * time.Minute},
+		{50, 15 * time.Minute},
+	}
+	for _, tc := range cases {
+		if got := Backoff(tc.attempt); got != tc.want {
+			t.Errorf("Backoff(%d) = %s, want %s", tc.attempt, got, tc.want)
+		}
+	}
+}
+
+func TestMessageDecode(t *testing.T) {
+	type credit struct {
+		UserID string  `bson:"userId"`
+		Amount float64 `bson:"amount"`
+	}
+	raw, err := bson.Marshal(credit{UserID: "u1", Amount: 12.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got credit
+	if err := (Message{Payload: raw}).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.UserID != "u1" || got.Amount != 12.5 {
+		t.Fatalf("decoded %+v", got)
+	}
+}
+
+func TestNewRelayDefaults(t *testing.T) {
+	r := NewRelay(nil, nil, RelayConfig{MaxAttempts: 3})
+	if r.cfg.MaxAttempts != 3 {
+		t.Fatalf("MaxAttempts = %d, want 3", r.cfg.MaxAttempts)
+	}
+	if r.cfg.Interval != 5*time.Second || r.cfg.Lease != 30*time.Second || r.cfg.StuckAfter != 5*time.Minute {
+		t.Fatalf("defaults not applied: %+v", r.cfg)
+	}
+}
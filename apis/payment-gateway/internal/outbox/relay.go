@@ -0,0 +1,222 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DeliverFunc sends one message downstream. A nil error marks it
+// delivered; anything else schedules a retry.
+type DeliverFunc func(ctx context.Context, msg Message) error
+
+// RelayConfig tunes a Relay. Zero values take the defaults noted below.
+type RelayConfig struct {
+	// Interval between polls for due messages (default 5s).
+	Interval time.Duration
+	// MaxAttempts before a message is marked DEAD (default 20).
+	MaxAttempts int
+	// Lease is how long a claimed message is hidden from other relays
+	// while it is delivered (default 30s).
+	Lease time.Duration
+	// StuckAfter is the age at which a pending message counts as stuck in
+	// Stats (default 5m).
+	StuckAfter time.Duration
+}
+
+// Relay claims due messages and hands them to a DeliverFunc. Claims are
+// leased, so several replicas can run a relay over the same collection.
+type Relay struct {
+	coll    *mongo.Collection
+	deliver DeliverFunc
+	cfg     RelayConfig
+}
+
+// NewRelay returns a relay over coll.
+func NewRelay(coll *mongo.Collection, deliver DeliverFunc, cfg RelayConfig) *Relay {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 5 * time.Second
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 20
+	}
+	if cfg.Lease <= 0 {
+		cfg.Lease = 30 * time.Second
+	}
+	if cfg.StuckAfter <= 0 {
+		cfg.StuckAfter = 5 * time.Minute
+	}
+	return &Relay{coll: coll, deliver: deliver, cfg: cfg}
+}
+
+// Run delivers due messages until ctx is cancelled.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.drain(ctx)
+		}
+	}
+}
+
+// Dispatch tries to deliver message id right away, typically just after
+// the transaction that enqueued it commits. Failures are left for Run to
+// retry, so the error is informational.
+func (r *Relay) Dispatch(ctx context.Context, id string) error {
+	msg, err := r.claim(ctx, bson.M{"_id": id})
+	if err != nil || msg == nil {
+		return err
+	}
+	return r.attempt(ctx, msg)
+}
+
+func (r *Relay) drain(ctx context.Context) {
+	for ctx.Err() == nil {
+		msg, err := r.claim(ctx, bson.M{})
+		if err != nil {
+			log.Printf("[outbox] claim error: %v", err)
+			return
+		}
+		if msg == nil {
+			return
+		}
+		r.attempt(ctx, msg)
+	}
+}
+
+// claim leases the oldest due pending message matching filter, or
+// returns nil when there is none.
+func (r *Relay) claim(ctx context.Context, filter bson.M) (*Message, error) {
+	now := time.Now()
+	filter["status"] = Pending
+	filter["nextAttemptAt"] = bson.M{"$lte": now}
+	filter["lockedUntil"] = bson.M{"$lte": now}
+	var msg Message
+	err := r.coll.FindOneAndUpdate(ctx, filter,
+		bson.M{"$set": bson.M{"lockedUntil": now.Add(r.cfg.Lease)}, "$inc": bson.M{"attempts": 1}},
+		options.FindOneAndUpdate().
+			SetSort(bson.D{{Key: "nextAttemptAt", Value: 1}}).
+			SetReturnDocument(options.After),
+	).Decode(&msg)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+func (r *Relay) attempt(ctx context.Context, msg *Message) error {
+	deliverCtx, cancel := context.WithTimeout(ctx, r.cfg.Lease)
+	err := r.deliver(deliverCtx, *msg)
+	cancel()
+
+	now := time.Now()
+	if err == nil {
+		_, uerr := r.coll.UpdateByID(ctx, msg.ID, bson.M{
+			"$set":   bson.M{"status": Delivered, "deliveredAt": now, "lockedUntil": now},
+			"$unset": bson.M{"lastError": ""},
+		})
+		if uerr != nil {
+			log.Printf("[outbox][%s] delivered but not marked: %v", msg.ID, uerr)
+		}
+		return nil
+	}
+
+	set := bson.M{"lastError": err.Error(), "lockedUntil": now, "nextAttemptAt": now.Add(Backoff(msg.Attempts))}
+	if msg.Attempts >= r.cfg.MaxAttempts {
+		set["status"] = Dead
+		log.Printf("[outbox][%s] ✗ %s dead after %d attempts: %v", msg.ID, msg.Topic, msg.Attempts, err)
+	} else {
+		log.Printf("[outbox][%s] %s attempt %d failed: %v", msg.ID, msg.Topic, msg.Attempts, err)
+	}
+	if _, uerr := r.coll.UpdateByID(ctx, msg.ID, bson.M{"$set": set}); uerr != nil {
+		log.Printf("[outbox][%s] could not record failure: %v", msg.ID, uerr)
+	}
+	return err
+}
+
+// ErrNotDead is returned by Requeue for messages that are not dead.
+var ErrNotDead = errors.New("outbox: message is not dead")
+
+// Requeue gives a dead message a fresh set of attempts.
+func (r *Relay) Requeue(ctx context.Context, id string) error {
+	now := time.Now()
+	res, err := r.coll.UpdateOne(ctx,
+		bson.M{"_id": id, "status": Dead},
+		bson.M{"$set": bson.M{"status": Pending, "attempts": 0, "nextAttemptAt": now, "lockedUntil": now}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotDead
+	}
+	return nil
+}
+
+// Stats describes the undelivered backlog.
+type Stats struct {
+	Pending int64 `json:"pending"`
+	// Stuck counts pending messages older than RelayConfig.StuckAfter.
+	Stuck int64 `json:"stuck"`
+	Dead  int64 `json:"dead"`
+	// OldestPendingSeconds is the age of the oldest pending message.
+	OldestPendingSeconds float64 `json:"oldestPendingSeconds"`
+	// Failing lists the most recently failing messages, dead ones first.
+	Failing []Message `json:"failing"`
+}
+
+// Stats reports backlog counts for monitoring.
+func (r *Relay) Stats(ctx context.Context) (Stats, error) {
+	var s Stats
+	var err error
+	now := time.Now()
+	if s.Pending, err = r.coll.CountDocuments(ctx, bson.M{"status": Pending}); err != nil {
+		return s, err
+	}
+	if s.Stuck, err = r.coll.CountDocuments(ctx, bson.M{
+		"status":    Pending,
+		"createdAt": bson.M{"$lt": now.Add(-r.cfg.StuckAfter)},
+	}); err != nil {
+		return s, err
+	}
+	if s.Dead, err = r.coll.CountDocuments(ctx, bson.M{"status": Dead}); err != nil {
+		return s, err
+	}
+
+	var oldest Message
+	err = r.coll.FindOne(ctx, bson.M{"status": Pending},
+		options.FindOne().SetSort(bson.D{{Key: "createdAt", Value: 1}}),
+	).Decode(&oldest)
+	switch {
+	case err == nil:
+		s.OldestPendingSeconds = now.Sub(oldest.CreatedAt).Seconds()
+	case !errors.Is(err, mongo.ErrNoDocuments):
+		return s, err
+	}
+
+	cursor, err := r.coll.Find(ctx,
+		bson.M{"status": bson.M{"$in": bson.A{Pending, Dead}}, "lastError": bson.M{"$exists": true}},
+		options.Find().SetSort(bson.D{{Key: "status", Value: 1}, {Key: "nextAttemptAt", Value: -1}}).SetLimit(20),
+	)
+	if err != nil {
+		return s, err
+	}
+	s.Failing = []Message{}
+	if err := cursor.All(ctx, &s.Failing); err != nil {
+		return s, err
+	}
+	return s, nil
+}
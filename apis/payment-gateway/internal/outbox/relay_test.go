@@ -0,0 +1,255 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// The relay tests run against the driver's mock deployment: each test
+// queues the server's replies in order and then checks the commands the
+// relay sent.
+
+func claimed(msg bson.D) bson.D {
+	return bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: msg}}
+}
+
+func nothingDue() bson.D {
+	return bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: nil}}
+}
+
+func updated(n int) bson.D {
+	return mtest.CreateSuccessResponse(bson.E{Key: "n", Value: n}, bson.E{Key: "nModified", Value: n})
+}
+
+func message(id string, attempts int) bson.D {
+	return bson.D{
+		{Key: "_id", Value: id},
+		{Key: "topic", Value: "wallet.credit"},
+		{Key: "payload", Value: bson.D{{Key: "userId", Value: "u1"}}},
+		{Key: "status", Value: Pending},
+		{Key: "attempts", Value: attempts},
+	}
+}
+
+func str(v bson.RawValue) string {
+	s, _ := v.StringValueOK()
+	return s
+}
+
+func num(v bson.RawValue) int64 {
+	n, _ := v.AsInt64OK()
+	return n
+}
+
+func tm(v bson.RawValue) time.Time {
+	t, _ := v.TimeOK()
+	return t
+}
+
+// statement returns the first statement of an update command.
+func statement(cmd bson.Raw) bson.Raw {
+	return cmd.Lookup("updates").Array().Index(0).Value().Document()
+}
+
+// sent returns the commands the relay sent, by name, oldest first.
+func sent(mt *mtest.T) (names []string, cmds []bson.Raw) {
+	for _, e := range mt.GetAllStartedEvents() {
+		names = append(names, e.CommandName)
+		cmds = append(cmds, e.Command)
+	}
+	return names, cmds
+}
+
+func TestRelayClaimLeasesAndDelivers(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("dispatch", func(mt *mtest.T) {
+		var got []string
+		r := NewRelay(mt.Coll, func(_ context.Context, msg Message) error {
+			got = append(got, msg.ID)
+			return nil
+		}, RelayConfig{Lease: time.Minute})
+		mt.AddMockResponses(claimed(message("credit:1", 1)), updated(1))
+
+		before := time.Now()
+		if err := r.Dispatch(context.Background(), "credit:1"); err != nil {
+			mt.Fatal(err)
+		}
+		if len(got) != 1 || got[0] != "credit:1" {
+			mt.Fatalf("delivered %v", got)
+		}
+
+		names, cmds := sent(mt)
+		if len(names) != 2 || names[0] != "findAndModify" || names[1] != "update" {
+			mt.Fatalf("commands %v", names)
+		}
+		// Only a due, unleased, pending message is claimed, and claiming
+		// it takes the lease and counts the attempt.
+		query := cmds[0].Lookup("query").Document()
+		if str(query.Lookup("_id")) != "credit:1" || str(query.Lookup("status")) != Pending {
+			mt.Fatalf("claim query %s", query)
+		}
+		if _, err := query.LookupErr("lockedUntil", "$lte"); err != nil {
+			mt.Fatalf("claim ignores leases: %s", query)
+		}
+		if _, err := query.LookupErr("nextAttemptAt", "$lte"); err != nil {
+			mt.Fatalf("claim ignores backoff: %s", query)
+		}
+		update := cmds[0].Lookup("update").Document()
+		lease := tm(update.Lookup("$set", "lockedUntil"))
+		if lease.Before(before.Add(time.Minute-time.Second)) || num(update.Lookup("$inc", "attempts")) != 1 {
+			mt.Fatalf("claim update %s", update)
+		}
+
+		done := statement(cmds[1])
+		if str(done.Lookup("u", "$set", "status")) != Delivered {
+			mt.Fatalf("not marked delivered: %s", done)
+		}
+		if _, err := done.LookupErr("u", "$unset", "lastError"); err != nil {
+			mt.Fatalf("lastError kept: %s", done)
+		}
+	})
+	mt.Run("leased elsewhere", func(mt *mtest.T) {
+		r := NewRelay(mt.Coll, func(context.Context, Message) error {
+			mt.Fatal("a leased message was delivered")
+			return nil
+		}, RelayConfig{})
+		mt.AddMockResponses(nothingDue())
+		if err := r.Dispatch(context.Background(), "credit:1"); err != nil {
+			mt.Fatal(err)
+		}
+		if names, _ := sent(mt); len(names) != 1 {
+			mt.Fatalf("commands %v", names)
+		}
+	})
+}
+
+func TestRelayBacksOffThenGivesUp(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("retry", func(mt *mtest.T) {
+		failure := errors.New("wallet service 503")
+		r := NewRelay(mt.Coll, func(context.Context, Message) error { return failure }, RelayConfig{MaxAttempts: 3})
+
+		mt.AddMockResponses(claimed(message("credit:1", 2)), updated(1))
+		before := time.Now()
+		if err := r.Dispatch(context.Background(), "credit:1"); !errors.Is(err, failure) {
+			mt.Fatalf("Dispatch = %v", err)
+		}
+		_, cmds := sent(mt)
+		set := statement(cmds[1]).Lookup("u", "$set").Document()
+		if _, err := set.LookupErr("status"); err == nil {
+			mt.Fatalf("retryable failure changed status: %s", set)
+		}
+		next := tm(set.Lookup("nextAttemptAt"))
+		if next.Before(before.Add(Backoff(2)-time.Second)) || str(set.Lookup("lastError")) != failure.Error() {
+			mt.Fatalf("failure update %s", set)
+		}
+		// The lease is given back so the retry isn't held up by it.
+		if tm(set.Lookup("lockedUntil")).After(time.Now()) {
+			mt.Fatalf("lease kept after failure: %s", set)
+		}
+
+		mt.ClearEvents()
+		mt.AddMockResponses(claimed(message("credit:1", 3)), updated(1))
+		r.Dispatch(context.Background(), "credit:1")
+		_, cmds = sent(mt)
+		set = statement(cmds[1]).Lookup("u", "$set").Document()
+		if str(set.Lookup("status")) != Dead {
+			mt.Fatalf("not dead after MaxAttempts: %s", set)
+		}
+	})
+}
+
+func TestRelayDrainsUntilNothingIsDue(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("drain", func(mt *mtest.T) {
+		var got []string
+		r := NewRelay(mt.Coll, func(_ context.Context, msg Message) error {
+			got = append(got, msg.ID)
+			if msg.ID == "credit:2" {
+				return errors.New("down")
+			}
+			return nil
+		}, RelayConfig{})
+		mt.AddMockResponses(
+			claimed(message("credit:1", 1)), updated(1),
+			claimed(message("credit:2", 1)), updated(1),
+			nothingDue(),
+		)
+		r.drain(context.Background())
+		if len(got) != 2 || got[0] != "credit:1" || got[1] != "credit:2" {
+			mt.Fatalf("delivered %v", got)
+		}
+		_, cmds := sent(mt)
+		if len(cmds) != 5 {
+			mt.Fatalf("%d commands, want 5", len(cmds))
+		}
+		// The poll claims any due message, oldest first.
+		if q := cmds[0].Lookup("query").Document(); q.Lookup("_id").Type != 0 {
+			mt.Fatalf("drain claimed by id: %s", q)
+		}
+		if num(cmds[0].Lookup("sort", "nextAttemptAt")) != 1 {
+			mt.Fatalf("claim sort %s", cmds[0])
+		}
+	})
+}
+
+func TestRequeue(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("requeue", func(mt *mtest.T) {
+		r := NewRelay(mt.Coll, nil, RelayConfig{})
+		mt.AddMockResponses(updated(1), updated(0))
+
+		if err := r.Requeue(context.Background(), "credit:1"); err != nil {
+			mt.Fatal(err)
+		}
+		if err := r.Requeue(context.Background(), "credit:2"); !errors.Is(err, ErrNotDead) {
+			mt.Fatalf("requeue of a live message: %v", err)
+		}
+		_, cmds := sent(mt)
+		stmt := statement(cmds[0])
+		if str(stmt.Lookup("q", "status")) != Dead {
+			mt.Fatalf("requeue filter %s", stmt)
+		}
+		set := stmt.Lookup("u", "$set").Document()
+		if str(set.Lookup("status")) != Pending || num(set.Lookup("attempts")) != 0 {
+			mt.Fatalf("requeue update %s", set)
+		}
+	})
+}
+
+func TestEnqueueAndCancel(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("enqueue", func(mt *mtest.T) {
+		mt.AddMockResponses(updated(1), updated(1), updated(0))
+		if err := Enqueue(context.Background(), mt.Coll, "credit:1", "wallet.credit", bson.M{"userId": "u1"}); err != nil {
+			mt.Fatal(err)
+		}
+		if ok, err := Cancel(context.Background(), mt.Coll, "credit:1"); err != nil || !ok {
+			mt.Fatalf("Cancel = %v, %v", ok, err)
+		}
+		if ok, _ := Cancel(context.Background(), mt.Coll, "credit:1"); ok {
+			mt.Fatal("cancelled a message already delivered")
+		}
+
+		_, cmds := sent(mt)
+		// Re-enqueueing the same ID must not reset a message that is
+		// already in flight, so every field is set on insert only.
+		stmt := statement(cmds[0])
+		if !stmt.Lookup("upsert").Boolean() || str(stmt.Lookup("u", "$setOnInsert", "status")) != Pending {
+			mt.Fatalf("enqueue %s", stmt)
+		}
+		if _, err := stmt.LookupErr("u", "$set"); err == nil {
+			mt.Fatalf("enqueue overwrites: %s", stmt)
+		}
+		// A message being delivered right now can't be cancelled.
+		q := statement(cmds[1]).Lookup("q").Document()
+		if _, err := q.LookupErr("lockedUntil", "$lte"); err != nil {
+			mt.Fatalf("cancel ignores leases: %s", q)
+		}
+	})
+}
@@ -0,0 +1,113 @@
+package payout
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/base58"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ErrInvalidAddress is returned for destination addresses that don't
+// parse for the network.
+var ErrInvalidAddress = errors.New("invalid address for network")
+
+// ValidateAddress checks that address is well formed for network,
+// including its checksum. Testnet selects Bitcoin testnet prefixes.
+func ValidateAddress(network, address string, testnet bool) error {
+	var err error
+	switch NormalizeNetwork(network) {
+	case Bitcoin:
+		_, err = bitcoinScript(address, testnet)
+	case Ethereum:
+		_, err = ethereumAddress(address)
+	case Tron:
+		_, err = tronAddress(address)
+	default:
+		return fmt.Errorf("%w: unsupported network %q", ErrInvalidAddress, network)
+	}
+	return err
+}
+
+// ---------------------------------------------------------------------------
+// Ethereum
+// ---------------------------------------------------------------------------
+
+// ethereumAddress parses a 0x-prefixed address. Mixed-case addresses must
+// carry a valid EIP-55 checksum.
+func ethereumAddress(address string) (common.Address, error) {
+	if len(address) != 42 || !strings.HasPrefix(address, "0x") || !common.IsHexAddress(address) {
+		return common.Address{}, fmt.Errorf("%w: expected 0x followed by 40 hex digits", ErrInvalidAddress)
+	}
+	addr := common.HexToAddress(address)
+	body := address[2:]
+	if body != strings.ToLower(body) && body != strings.ToUpper(body) && addr.Hex() != address {
+		return common.Address{}, fmt.Errorf("%w: bad EIP-55 checksum", ErrInvalidAddress)
+	}
+	return addr, nil
+}
+
+// ---------------------------------------------------------------------------
+// Tron
+// ---------------------------------------------------------------------------
+
+const tronPrefix = 0x41
+
+// tronAddress parses a base58check T... address into its 21 raw bytes.
+func tronAddress(address string) ([]byte, error) {
+	payload, version, err := base58.CheckDecode(address)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAddress, err)
+	}
+	if version != tronPrefix || len(payload) != 20 {
+		return nil, fmt.Errorf("%w: not a Tron address", ErrInvalidAddress)
+	}
+	return append([]byte{tronPrefix}, payload...), nil
+}
+
+// tronAddressFromEthereum renders the Tron address sharing an Ethereum
+// address's key.
+func tronAddressFromEthereum(addr common.Address) string {
+	return base58.CheckEncode(addr.Bytes(), tronPrefix)
+}
+
+// ---------------------------------------------------------------------------
+// Bitcoin
+// ---------------------------------------------------------------------------
+
+func bitcoinParams(testnet bool) *chaincfg.Params {
+	if testnet {
+		return &chaincfg.TestNet3Params
+	}
+	return &chaincfg.MainNetParams
+}
+
+// bitcoinScript parses a P2PKH, P2SH or segwit address and returns the
+// output script that pays it.
+func bitcoinScript(address string, testnet bool) ([]byte, error) {
+	params := bitcoinParams(testnet)
+	addr, err := btcutil.DecodeAddress(address, params)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAddress, err)
+	}
+	switch addr.(type) {
+	case *btcutil.AddressPubKeyHash, *btcutil.AddressScriptHash,
+		*btcutil.AddressWitnessPubKeyHash, *btcutil.AddressWitnessScriptHash,
+		*btcutil.AddressTaproot:
+	default:
+		// Raw public keys also decode, but nobody pays to those.
+		return nil, fmt.Errorf("%w: not a Bitcoin address", ErrInvalidAddress)
+	}
+	if !addr.IsForNet(params) {
+		return nil, fmt.Errorf("%w: address is for another Bitcoin network", ErrInvalidAddress)
+	}
+	script, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAddress, err)
+	}
+	return script, nil
+}
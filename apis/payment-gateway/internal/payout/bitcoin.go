@@ -0,0 +1,137 @@
+package payout
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// bitcoinDust is the smallest change output worth creating.
+	bitcoinDust = 546
+	// Legacy P2PKH sizes in bytes, for fee estimation.
+	p2pkhInputSize  = 148
+	txOutputSize    = 34
+	txOverheadSize  = 10
+	maxBitcoinInput = 50
+)
+
+// buildBitcoin spends the from address's P2PKH outputs, largest first,
+// to pay to and returns any change above dust to the same address.
+func (s *Service) buildBitcoin(ctx context.Context, tx *SignedTx, from uint32, to string, amount *big.Int) error {
+	outScript, err := bitcoinScript(to, s.cfg.Testnet)
+	if err != nil {
+		return err
+	}
+	want := amount.Int64()
+	// Ask for enough to cover the fee of a ten-input spend.
+	margin := s.cfg.BitcoinFeeRate * int64(txOverheadSize+p2pkhInputSize*10+txOutputSize*2)
+	utxos, err := s.chain.BitcoinUTXOs(ctx, tx.From, want+margin)
+	if err != nil {
+		return err
+	}
+	sort.Slice(utxos, func(i, j int) bool { return utxos[i].Value > utxos[j].Value })
+
+	var inputs []UTXO
+	var total, fee int64
+	for _, u := range utxos {
+		if len(inputs) == maxBitcoinInput {
+			break
+		}
+		inputs = append(inputs, u)
+		total += u.Value
+		fee = s.bitcoinFee(len(inputs), 2)
+		if total >= want+fee {
+			break
+		}
+	}
+	if total < want+fee {
+		return fmt.Errorf("%s has %d sat, need %d plus %d fee", tx.From, total, want, fee)
+	}
+
+	outputs := []txOut{{value: want, script: outScript}}
+	if change := total - want - fee; change >= bitcoinDust {
+		outputs = append(outputs, txOut{value: change, script: nil})
+	}
+	return s.signBitcoin(ctx, tx, from, inputs, outputs)
+}
+
+// bitcoinFee estimates the fee of a P2PKH spend.
+func (s *Service) bitcoinFee(inputs, outputs int) int64 {
+	return s.cfg.BitcoinFeeRate * int64(txOverheadSize+p2pkhInputSize*inputs+txOutputSize*outputs)
+}
+
+// signBitcoin signs inputs, all paying address index from, into tx.
+// Outputs without a script pay back to that address.
+func (s *Service) signBitcoin(ctx context.Context, tx *SignedTx, from uint32, inputs []UTXO, outputs []txOut) error {
+	pub, err := s.signer.PublicKey(ctx, Bitcoin, from)
+	if err != nil {
+		return err
+	}
+	ours, err := btcutil.NewAddressPubKeyHash(btcutil.Hash160(pub), bitcoinParams(s.cfg.Testnet))
+	if err != nil {
+		return err
+	}
+	ourScript, err := txscript.PayToAddrScript(ours)
+	if err != nil {
+		return err
+	}
+
+	msg := wire.NewMsgTx(1)
+	for _, in := range inputs {
+		prev, err := chainhash.NewHashFromStr(in.TxID)
+		if err != nil {
+			return fmt.Errorf("utxo %s:%d: %w", in.TxID, in.Vout, err)
+		}
+		msg.AddTxIn(wire.NewTxIn(wire.NewOutPoint(prev, in.Vout), nil, nil))
+	}
+	for _, out := range outputs {
+		script := out.script
+		if script == nil {
+			script = ourScript
+		}
+		msg.AddTxOut(wire.NewTxOut(out.value, script))
+	}
+
+	for i := range inputs {
+		digest, err := txscript.CalcSignatureHash(ourScript, txscript.SigHashAll, msg, i)
+		if err != nil {
+			return err
+		}
+		sig, err := s.signer.Sign(ctx, Bitcoin, from, digest)
+		if err != nil {
+			return err
+		}
+		var r, sv btcec.ModNScalar
+		r.SetByteSlice(sig[:32])
+		sv.SetByteSlice(sig[32:64])
+		der := append(ecdsa.NewSignature(&r, &sv).Serialize(), byte(txscript.SigHashAll))
+		script, err := txscript.NewScriptBuilder().AddData(der).AddData(pub).Script()
+		if err != nil {
+			return err
+		}
+		msg.TxIn[i].SignatureScript = script
+	}
+
+	var raw bytes.Buffer
+	if err := msg.Serialize(&raw); err != nil {
+		return err
+	}
+	tx.Raw = raw.Bytes()
+	tx.TxID = msg.TxHash().String()
+	return nil
+}
+
+type txOut struct {
+	value  int64
+	script []byte
+}
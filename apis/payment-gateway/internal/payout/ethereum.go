@@ -0,0 +1,91 @@
+package payout
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// erc20Transfer is the selector of transfer(address,uint256), shared by
+// ERC-20 and TRC-20 tokens.
+var erc20Transfer = []byte{0xa9, 0x05, 0x9c, 0xbb}
+
+// buildEthereum builds and signs a legacy EIP-155 transaction paying
+// native ether or an ERC-20 token. A nil gasPrice offers the chain's
+// current price.
+func (s *Service) buildEthereum(ctx context.Context, tx *SignedTx, a asset, from uint32, to string, amount, gasPrice *big.Int) error {
+	recipient, err := ethereumAddress(to)
+	if err != nil {
+		return err
+	}
+	pending, err := s.chain.EthereumNonce(ctx, tx.From)
+	if err != nil {
+		return err
+	}
+	nonce, err := s.cfg.Nonces.Next(ctx, tx.From, pending)
+	if err != nil {
+		return err
+	}
+	if gasPrice == nil {
+		if gasPrice, err = s.chain.EthereumGasPrice(ctx); err != nil {
+			return err
+		}
+	}
+
+	target, value, gas, data := recipient, amount, uint64(21000), []byte(nil)
+	if a.contract != "" {
+		if target, err = ethereumAddress(a.contract); err != nil {
+			return err
+		}
+		value = new(big.Int)
+		gas = s.cfg.ERC20GasLimit
+		data = tokenTransferData(recipient.Bytes(), amount)
+	}
+
+	unsigned := types.NewTx(&types.LegacyTx{
+		Nonce:    nonce,
+		GasPrice: gasPrice,
+		Gas:      gas,
+		To:       &target,
+		Value:    value,
+		Data:     data,
+	})
+	chainSigner := types.NewEIP155Signer(big.NewInt(s.cfg.EthereumChainID))
+	digest := chainSigner.Hash(unsigned)
+	sig, err := s.signer.Sign(ctx, Ethereum, from, digest[:])
+	if err != nil {
+		return err
+	}
+	signed, err := unsigned.WithSignature(chainSigner, sig)
+	if err != nil {
+		return err
+	}
+	// A signature from the wrong key would broadcast fine and then fail
+	// for lack of funds; catch it here instead.
+	sender, err := types.Sender(chainSigner, signed)
+	if err != nil {
+		return err
+	}
+	if sender != common.HexToAddress(tx.From) {
+		return fmt.Errorf("payout: signature recovers to %s, not %s", sender.Hex(), tx.From)
+	}
+
+	if tx.Raw, err = signed.MarshalBinary(); err != nil {
+		return err
+	}
+	tx.TxID = signed.Hash().Hex()
+	return nil
+}
+
+// tokenTransferData ABI-encodes transfer(to, amount) for a 20-byte
+// recipient.
+func tokenTransferData(recipient []byte, amount *big.Int) []byte {
+	data := make([]byte, 4+32+32)
+	copy(data, erc20Transfer)
+	copy(data[4+12:36], recipient)
+	amount.FillBytes(data[36:])
+	return data
+}
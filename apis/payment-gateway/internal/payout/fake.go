@@ -0,0 +1,195 @@
+package payout
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"sync"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Fake is an in-memory chain for tests and local development. It serves
+// nonces, gas prices, a Tron block reference, balances and UTXOs, records
+// broadcast transactions, and reports whatever confirmations Confirm or
+// Revert set.
+type Fake struct {
+	mu        sync.Mutex
+	nonce     uint64
+	utxos     map[string][]UTXO
+	balances  map[string]*big.Int
+	status    map[string]TxStatus
+	broadcast []Broadcast
+	failNext  error
+	GasPrice  *big.Int
+}
+
+// Broadcast is a transaction handed to Fake.
+type Broadcast struct {
+	Network string
+	TxID    string
+	Raw     []byte
+}
+
+// NewFake returns an empty fake chain with a 20 gwei gas price.
+func NewFake() *Fake {
+	return &Fake{
+		utxos:    map[string][]UTXO{},
+		balances: map[string]*big.Int{},
+		status:   map[string]TxStatus{},
+		GasPrice: big.NewInt(20_000_000_000),
+	}
+}
+
+// AddUTXO funds a Bitcoin address.
+func (f *Fake) AddUTXO(address string, u UTXO) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.utxos[address] = append(f.utxos[address], u)
+}
+
+// SetBalance sets address's balance of contract, or of the native coin
+// when contract is empty, in atomic units. Bitcoin balances come from
+// AddUTXO instead.
+func (f *Fake) SetBalance(network, address, contract string, amount *big.Int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.balances[network+"/"+address+"/"+contract] = new(big.Int).Set(amount)
+}
+
+// FailNext makes the next Broadcast return err.
+func (f *Fake) FailNext(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failNext = err
+}
+
+// Confirm sets a broadcast transaction's confirmation count.
+func (f *Fake) Confirm(txID string, confirmations int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.status[txID] = TxStatus{Found: true, Confirmations: confirmations}
+}
+
+// Revert marks a transaction as mined but failed.
+func (f *Fake) Revert(txID string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.status[txID] = TxStatus{Found: true, Confirmations: 1, Failed: true}
+}
+
+// Broadcasts returns every transaction broadcast so far, in order.
+func (f *Fake) Broadcasts() []Broadcast {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Broadcast(nil), f.broadcast...)
+}
+
+// EthereumNonce implements ChainReader: one past the highest nonce
+// broadcast. Fake assumes a single hot wallet, so every address shares
+// one nonce.
+func (f *Fake) EthereumNonce(context.Context, string) (uint64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.nonce, nil
+}
+
+// EthereumGasPrice implements ChainReader.
+func (f *Fake) EthereumGasPrice(context.Context) (*big.Int, error) {
+	return new(big.Int).Set(f.GasPrice), nil
+}
+
+// TronBlockRef implements ChainReader.
+func (f *Fake) TronBlockRef(context.Context) (int64, []byte, error) {
+	id := sha256.Sum256([]byte("fake tron block"))
+	return 1000, id[:], nil
+}
+
+// BitcoinUTXOs implements ChainReader.
+func (f *Fake) BitcoinUTXOs(_ context.Context, address string, _ int64) ([]UTXO, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]UTXO(nil), f.utxos[address]...), nil
+}
+
+// Balance implements ChainReader.
+func (f *Fake) Balance(_ context.Context, network, address, contract string) (*big.Int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if network == Bitcoin {
+		total := new(big.Int)
+		for _, u := range f.utxos[address] {
+			total.Add(total, big.NewInt(u.Value))
+		}
+		return total, nil
+	}
+	if b, ok := f.balances[network+"/"+address+"/"+contract]; ok {
+		return new(big.Int).Set(b), nil
+	}
+	return new(big.Int), nil
+}
+
+// Broadcast implements Broadcaster. The same transaction broadcast twice
+// is recorded once, like a real node would accept it once.
+func (f *Fake) Broadcast(_ context.Context, network string, raw []byte) (string, error) {
+	txID, err := txIDOf(network, raw)
+	if err != nil {
+		return "", err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failNext; err != nil {
+		f.failNext = nil
+		return "", err
+	}
+	if _, ok := f.status[txID]; ok {
+		return txID, nil
+	}
+	f.status[txID] = TxStatus{Found: true}
+	f.broadcast = append(f.broadcast, Broadcast{Network: network, TxID: txID, Raw: raw})
+	if network == Ethereum {
+		var tx types.Transaction
+		if tx.UnmarshalBinary(raw) == nil && tx.Nonce() >= f.nonce {
+			f.nonce = tx.Nonce() + 1
+		}
+	}
+	return txID, nil
+}
+
+// Status implements Broadcaster.
+func (f *Fake) Status(_ context.Context, _ string, txID string) (TxStatus, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.status[txID], nil
+}
+
+// txIDOf derives a transaction ID from its raw bytes the way each chain
+// does.
+func txIDOf(network string, raw []byte) (string, error) {
+	switch network {
+	case Ethereum:
+		var tx types.Transaction
+		if err := tx.UnmarshalBinary(raw); err != nil {
+			return "", err
+		}
+		return tx.Hash().Hex(), nil
+	case Tron:
+		data, err := tronRawData(raw)
+		if err != nil {
+			return "", err
+		}
+		sum := sha256.Sum256(data)
+		return hex.EncodeToString(sum[:]), nil
+	case Bitcoin:
+		var tx wire.MsgTx
+		if err := tx.Deserialize(bytes.NewReader(raw)); err != nil {
+			return "", err
+		}
+		return tx.TxHash().String(), nil
+	}
+	return "", errors.New("payout: unknown network " + network)
+}
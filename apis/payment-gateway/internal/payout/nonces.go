@@ -0,0 +1,120 @@
+package payout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// nonceStaleAfter is how long a nonce signed into a transaction the node
+// never accepted stays taken. After that it is handed out again, so a
+// transaction that can't be broadcast doesn't leave a gap every later one
+// from the address waits behind. Whichever of the two is mined, the other
+// is reported by Dropped.
+const nonceStaleAfter = 30 * time.Minute
+
+// Nonces remembers the last nonce signed for each Ethereum address. The
+// node's pending nonce only counts transactions it has accepted, so
+// without this a payout whose broadcast failed would have its nonce
+// signed again by the next one, and only one of them could be mined.
+type Nonces interface {
+	// Next returns the nonce to sign address's next transaction with,
+	// given the node's pending nonce, and records it as taken.
+	Next(ctx context.Context, address string, pending uint64) (uint64, error)
+}
+
+// lastNonce is the last nonce signed for an address, and when.
+type lastNonce struct {
+	Nonce uint64
+	At    time.Time
+}
+
+// next returns the nonce after l: the node's pending nonce once it has
+// caught up or l is stale, else l.Nonce+1.
+func (l *lastNonce) next(pending uint64, t time.Time) uint64 {
+	if l == nil || pending > l.Nonce || t.Sub(l.At) > nonceStaleAfter {
+		return pending
+	}
+	return l.Nonce + 1
+}
+
+// MemoryNonces keeps nonces in memory, for a single process. It is the
+// default when Config.Nonces is nil.
+type MemoryNonces struct {
+	mu   sync.Mutex
+	last map[string]lastNonce
+}
+
+// NewMemoryNonces returns an empty MemoryNonces.
+func NewMemoryNonces() *MemoryNonces {
+	return &MemoryNonces{last: map[string]lastNonce{}}
+}
+
+// Next implements Nonces.
+func (m *MemoryNonces) Next(_ context.Context, address string, pending uint64) (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var last *lastNonce
+	if l, ok := m.last[address]; ok {
+		last = &l
+	}
+	t := now()
+	n := last.next(pending, t)
+	m.last[address] = lastNonce{Nonce: n, At: t}
+	return n, nil
+}
+
+// MongoNonces keeps nonces in a collection, one document per address, so
+// they survive restarts and are shared between replicas.
+type MongoNonces struct {
+	coll *mongo.Collection
+}
+
+// NewMongoNonces returns nonces stored in coll.
+func NewMongoNonces(coll *mongo.Collection) *MongoNonces {
+	return &MongoNonces{coll: coll}
+}
+
+// Next implements Nonces. The document is swapped only if it is unchanged
+// since it was read, retrying when another replica got there first.
+func (m *MongoNonces) Next(ctx context.Context, address string, pending uint64) (uint64, error) {
+	for attempt := 0; attempt < 5; attempt++ {
+		var doc struct {
+			Nonce int64     `bson:"nonce"`
+			At    time.Time `bson:"at"`
+		}
+		t := now().Truncate(time.Millisecond)
+		err := m.coll.FindOne(ctx, bson.M{"_id": address}).Decode(&doc)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			_, err = m.coll.InsertOne(ctx, bson.M{"_id": address, "nonce": int64(pending), "at": t})
+			if mongo.IsDuplicateKeyError(err) {
+				continue
+			}
+			if err != nil {
+				return 0, err
+			}
+			return pending, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		n := (&lastNonce{Nonce: uint64(doc.Nonce), At: doc.At}).next(pending, t)
+		res, err := m.coll.UpdateOne(ctx,
+			bson.M{"_id": address, "nonce": doc.Nonce, "at": doc.At},
+			bson.M{"$set": bson.M{"nonce": int64(n), "at": t}},
+		)
+		if err != nil {
+			return 0, err
+		}
+		if res.MatchedCount == 1 {
+			return n, nil
+		}
+	}
+	return 0, fmt.Errorf("payout: nonce for %s is contended", address)
+}
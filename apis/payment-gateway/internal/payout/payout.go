@@ -0,0 +1,382 @@
+// Package payout sends crypto withdrawals on chain and sweeps user deposit
+// addresses into treasury. Transactions are built here, signed through a
+// Signer holding the wallet keys, and
+// broadcast through a Broadcaster (Tatum in production, Fake in tests).
+// Building and broadcasting are separate steps so the caller can persist
+// the signed transaction first and rebroadcast exactly the same bytes
+// after a crash instead of paying twice.
+package payout
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Networks, named as in withdrawal requests.
+const (
+	Bitcoin  = "BTC"
+	Ethereum = "ERC20"
+	Tron     = "TRC20"
+)
+
+// NormalizeNetwork maps chain names and aliases (ETH, TRON, ...) to the
+// network constants.
+func NormalizeNetwork(network string) string {
+	switch n := strings.ToUpper(strings.TrimSpace(network)); n {
+	case "BTC", "BITCOIN":
+		return Bitcoin
+	case "ERC20", "ETH", "ETHEREUM":
+		return Ethereum
+	case "TRC20", "TRON", "TRX":
+		return Tron
+	default:
+		return n
+	}
+}
+
+// ChainReader supplies the chain state needed to build transactions.
+type ChainReader interface {
+	// EthereumNonce returns the next nonce for address, counting pending
+	// transactions.
+	EthereumNonce(ctx context.Context, address string) (uint64, error)
+	// EthereumGasPrice returns the gas price to offer, in wei.
+	EthereumGasPrice(ctx context.Context) (*big.Int, error)
+	// TronBlockRef returns a recent block's number and 32-byte ID for the
+	// transaction's reference block.
+	TronBlockRef(ctx context.Context) (int64, []byte, error)
+	// BitcoinUTXOs lists unspent outputs paying address, worth at least
+	// atLeast satoshis when the wallet holds that much.
+	BitcoinUTXOs(ctx context.Context, address string, atLeast int64) ([]UTXO, error)
+	// Balance returns address's confirmed balance in atomic units: of
+	// token contract, or of the native coin when contract is empty.
+	Balance(ctx context.Context, network, address, contract string) (*big.Int, error)
+}
+
+// Broadcaster submits signed transactions and reports on them.
+type Broadcaster interface {
+	// Broadcast submits raw and returns the transaction ID.
+	Broadcast(ctx context.Context, network string, raw []byte) (string, error)
+	// Status looks up a broadcast transaction.
+	Status(ctx context.Context, network, txID string) (TxStatus, error)
+}
+
+// UTXO is an unspent Bitcoin output.
+type UTXO struct {
+	TxID  string
+	Vout  uint32
+	Value int64 // satoshis
+}
+
+// TxStatus is what the chain says about a transaction.
+type TxStatus struct {
+	// Found is false while the transaction is unknown to the node.
+	Found         bool
+	Confirmations int
+	// Failed is set when the transaction was mined but reverted, so no
+	// funds moved.
+	Failed bool
+}
+
+// Config holds chain parameters. Zero values take mainnet defaults.
+type Config struct {
+	Testnet bool
+	// HotWalletIndex is the address index payouts are sent from and
+	// sweep gas is funded from. Deposit addresses must not use it.
+	HotWalletIndex uint32
+	// USDTTRC20Contract and USDTERC20Contract override the USDT token
+	// contracts (e.g. for Nile or Sepolia test tokens).
+	USDTTRC20Contract string
+	USDTERC20Contract string
+	// EthereumChainID is used for EIP-155 replay protection (1, or
+	// 11155111 on testnet).
+	EthereumChainID int64
+	// ERC20GasLimit caps gas for token transfers (default 100000).
+	ERC20GasLimit uint64
+	// TronFeeLimit caps energy spend in sun (default 20 TRX).
+	TronFeeLimit int64
+	// BitcoinFeeRate is the fee in sat/vbyte (default 10).
+	BitcoinFeeRate int64
+	// Nonces remembers the Ethereum nonces signed so far (default in
+	// memory).
+	Nonces Nonces
+}
+
+func (c Config) withDefaults() Config {
+	if c.USDTTRC20Contract == "" {
+		c.USDTTRC20Contract = "TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6t"
+	}
+	if c.USDTERC20Contract == "" {
+		c.USDTERC20Contract = "0xdAC17F958D2ee523a2206206994597C13D831ec7"
+	}
+	if c.EthereumChainID == 0 {
+		c.EthereumChainID = 1
+		if c.Testnet {
+			c.EthereumChainID = 11155111
+		}
+	}
+	if c.ERC20GasLimit == 0 {
+		c.ERC20GasLimit = 100000
+	}
+	if c.TronFeeLimit == 0 {
+		c.TronFeeLimit = 20_000_000
+	}
+	if c.BitcoinFeeRate == 0 {
+		c.BitcoinFeeRate = 10
+	}
+	if c.Nonces == nil {
+		c.Nonces = NewMemoryNonces()
+	}
+	return c
+}
+
+// asset describes what a coin is on a network.
+type asset struct {
+	network  string
+	contract string // token contract; empty for the native coin
+	decimals int
+}
+
+// ErrUnsupported is returned for coin/network pairs that can't be paid
+// out automatically.
+var ErrUnsupported = errors.New("unsupported payout asset")
+
+func (c Config) asset(coin, network string) (asset, error) {
+	network = NormalizeNetwork(network)
+	switch strings.ToUpper(coin) + "/" + network {
+	case "BTC/" + Bitcoin:
+		return asset{network: Bitcoin, decimals: 8}, nil
+	case "ETH/" + Ethereum:
+		return asset{network: Ethereum, decimals: 18}, nil
+	case "USDT/" + Ethereum:
+		return asset{network: Ethereum, contract: c.USDTERC20Contract, decimals: 6}, nil
+	case "USDT/" + Tron:
+		return asset{network: Tron, contract: c.USDTTRC20Contract, decimals: 6}, nil
+	}
+	return asset{}, fmt.Errorf("%w: %s on %s", ErrUnsupported, coin, network)
+}
+
+// Transfer is a payout to build.
+type Transfer struct {
+	// Reference identifies the payout in logs (the withdrawal ID).
+	Reference string
+	Coin      string
+	Network   string
+	To        string
+	// Amount is in whole coins (e.g. 12.5 USDT).
+	Amount float64
+}
+
+// SignedTx is a transaction ready to broadcast.
+type SignedTx struct {
+	Network string
+	From    string
+	TxID    string
+	Raw     []byte
+}
+
+// RawHex returns Raw hex encoded, as stored on the withdrawal.
+func (t *SignedTx) RawHex() string {
+	return hex.EncodeToString(t.Raw)
+}
+
+// Service builds, signs and broadcasts payouts.
+type Service struct {
+	signer      Signer
+	chain       ChainReader
+	broadcaster Broadcaster
+	cfg         Config
+}
+
+// New returns a payout service.
+func New(signer Signer, chain ChainReader, broadcaster Broadcaster, cfg Config) *Service {
+	return &Service{signer: signer, chain: chain, broadcaster: broadcaster, cfg: cfg.withDefaults()}
+}
+
+// Supports reports whether coin on network can be paid out.
+func (s *Service) Supports(coin, network string) bool {
+	_, err := s.cfg.asset(coin, network)
+	return err == nil
+}
+
+// HotWallet returns the address payouts on network are sent from.
+func (s *Service) HotWallet(ctx context.Context, network string) (string, error) {
+	return s.Address(ctx, network, s.cfg.HotWalletIndex)
+}
+
+// Address returns the address the signer holds at index on network.
+func (s *Service) Address(ctx context.Context, network string, index uint32) (string, error) {
+	network = NormalizeNetwork(network)
+	pub, err := s.signer.PublicKey(ctx, network, index)
+	if err != nil {
+		return "", err
+	}
+	return addressFromPublicKey(network, pub, s.cfg.Testnet)
+}
+
+// Build validates t, then builds and signs its transaction. Nothing is
+// sent.
+func (s *Service) Build(ctx context.Context, t Transfer) (*SignedTx, error) {
+	a, err := s.cfg.asset(t.Coin, t.Network)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidateAddress(a.network, t.To, s.cfg.Testnet); err != nil {
+		return nil, err
+	}
+	amount, err := atomicAmount(t.Amount, a.decimals)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := s.build(ctx, a, s.cfg.HotWalletIndex, t.To, amount)
+	if err != nil {
+		return nil, fmt.Errorf("payout %s: %w", t.Reference, err)
+	}
+	return tx, nil
+}
+
+// build signs a transfer of amount from address index from.
+func (s *Service) build(ctx context.Context, a asset, from uint32, to string, amount *big.Int) (*SignedTx, error) {
+	address, err := s.Address(ctx, a.network, from)
+	if err != nil {
+		return nil, err
+	}
+	tx := &SignedTx{Network: a.network, From: address}
+	switch a.network {
+	case Ethereum:
+		err = s.buildEthereum(ctx, tx, a, from, to, amount, nil)
+	case Tron:
+		err = s.buildTron(ctx, tx, a, from, to, amount)
+	case Bitcoin:
+		err = s.buildBitcoin(ctx, tx, from, to, amount)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// Broadcast submits tx. Rebroadcasting the same tx is harmless: the chain
+// accepts it once.
+func (s *Service) Broadcast(ctx context.Context, tx *SignedTx) error {
+	txID, err := s.broadcaster.Broadcast(ctx, tx.Network, tx.Raw)
+	if err != nil {
+		return err
+	}
+	if txID != "" && !strings.EqualFold(strings.TrimPrefix(txID, "0x"), strings.TrimPrefix(tx.TxID, "0x")) {
+		return fmt.Errorf("payout: broadcaster returned tx %s, expected %s", txID, tx.TxID)
+	}
+	return nil
+}
+
+// Status reports on a broadcast transaction.
+func (s *Service) Status(ctx context.Context, network, txID string) (TxStatus, error) {
+	return s.broadcaster.Status(ctx, NormalizeNetwork(network), txID)
+}
+
+// Dropped reports whether tx, which Status no longer finds, can never be
+// mined: an Ethereum transaction whose nonce the sender has since used, or
+// a Tron transaction past its expiration. Every transaction from our
+// addresses is ours, so a used nonce means another of ours took it and
+// the node holds that one instead. A Bitcoin transaction stays valid until
+// its inputs are spent, which the chain reader can't prove, so it is never
+// reported dropped.
+func (s *Service) Dropped(ctx context.Context, tx *SignedTx) (bool, error) {
+	switch tx.Network {
+	case Ethereum:
+		var signed types.Transaction
+		if err := signed.UnmarshalBinary(tx.Raw); err != nil {
+			return false, err
+		}
+		pending, err := s.chain.EthereumNonce(ctx, tx.From)
+		if err != nil {
+			return false, err
+		}
+		return pending > signed.Nonce(), nil
+	case Tron:
+		raw, err := tronRawData(tx.Raw)
+		if err != nil {
+			return false, err
+		}
+		expiration, err := tronExpiration(raw)
+		if err != nil {
+			return false, err
+		}
+		// Allow the indexer a minute to catch up with the last blocks.
+		return now().After(expiration.Add(time.Minute)), nil
+	}
+	return false, nil
+}
+
+// atomicAmount converts whole coins to the smallest unit, working from
+// the shortest decimal form of amount so 0.1 ETH is exactly 10^17 wei.
+// Digits beyond the coin's precision are dropped.
+func atomicAmount(amount float64, decimals int) (*big.Int, error) {
+	if amount <= 0 {
+		return nil, errors.New("payout: amount must be positive")
+	}
+	n, err := parseUnits(strconv.FormatFloat(amount, 'f', -1, 64), decimals)
+	if err != nil || n.Sign() <= 0 {
+		return nil, fmt.Errorf("payout: amount %v is too small", amount)
+	}
+	return n, nil
+}
+
+// parseUnits converts a decimal string of whole coins, as Tatum reports
+// balances, to the smallest unit. Digits beyond the coin's precision are
+// dropped.
+func parseUnits(amount string, decimals int) (*big.Int, error) {
+	whole, frac, _ := strings.Cut(strings.TrimSpace(amount), ".")
+	if whole == "" {
+		whole = "0"
+	}
+	if len(frac) > decimals {
+		frac = frac[:decimals]
+	}
+	frac += strings.Repeat("0", decimals-len(frac))
+	n, ok := new(big.Int).SetString(whole+frac, 10)
+	if !ok || n.Sign() < 0 {
+		return nil, fmt.Errorf("payout: bad amount %q", amount)
+	}
+	return n, nil
+}
+
+// wholeUnits converts an atomic amount back to whole coins, for
+// reporting.
+func wholeUnits(n *big.Int, decimals int) float64 {
+	f, _ := new(big.Float).Quo(new(big.Float).SetInt(n), new(big.Float).SetFloat64(math.Pow10(decimals))).Float64()
+	return f
+}
+
+// addressFromPublicKey renders the hot wallet address for a compressed
+// public key.
+func addressFromPublicKey(network string, pub []byte, testnet bool) (string, error) {
+	key, err := btcec.ParsePubKey(pub)
+	if err != nil {
+		return "", err
+	}
+	switch network {
+	case Ethereum:
+		return crypto.PubkeyToAddress(*key.ToECDSA()).Hex(), nil
+	case Tron:
+		return tronAddressFromEthereum(crypto.PubkeyToAddress(*key.ToECDSA())), nil
+	case Bitcoin:
+		addr, err := btcutil.NewAddressPubKeyHash(btcutil.Hash160(pub), bitcoinParams(testnet))
+		if err != nil {
+			return "", err
+		}
+		return addr.EncodeAddress(), nil
+	}
+	return "", fmt.Errorf("%w: network %s", ErrUnsupported, network)
+}
@@ -0,0 +1,483 @@
+package payout
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"gamehub/payment-gateway/internal/secrets"
+)
+
+// The BIP-39 test mnemonic; its BIP-44 addresses are published widely.
+const testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+func testSigner(t *testing.T) *LocalSigner {
+	t.Helper()
+	s := NewLocalSigner(false, nil)
+	for _, network := range []string{Bitcoin, Ethereum, Tron} {
+		if err := s.AddMnemonic(network, testMnemonic); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+func TestHotWalletAddresses(t *testing.T) {
+	svc := New(testSigner(t), NewFake(), NewFake(), Config{})
+	want := map[string]string{
+		Bitcoin:  "1LqBGSKuX5yYUonjxT5qGfpUsXKYYWeabA",
+		Ethereum: "0x9858EfFD232B4033E47d90003D41EC34EcaEda94",
+		Tron:     "TUEZSdKsoDHQMeZwihtdoBiN46zxhGWYdH",
+	}
+	for network, addr := range want {
+		got, err := svc.HotWallet(context.Background(), network)
+		if err != nil {
+			t.Fatalf("%s: %v", network, err)
+		}
+		if got != addr {
+			t.Errorf("%s hot wallet = %s, want %s", network, got, addr)
+		}
+	}
+}
+
+func TestDepositAddresses(t *testing.T) {
+	svc := New(testSigner(t), NewFake(), NewFake(), Config{})
+	got, err := svc.Address(context.Background(), "ETH", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "0x6Fac4D18c912343BF86fa7049364Dd4E424Ab9C0"; got != want {
+		t.Errorf("ETH address 1 = %s, want %s", got, want)
+	}
+	hot, _ := svc.HotWallet(context.Background(), Ethereum)
+	moved := New(testSigner(t), NewFake(), NewFake(), Config{HotWalletIndex: 1})
+	if got, _ := moved.HotWallet(context.Background(), Ethereum); got == hot {
+		t.Error("HotWalletIndex did not move the hot wallet")
+	}
+}
+
+func TestSignVerifies(t *testing.T) {
+	ctx := context.Background()
+	signer := testSigner(t)
+	digest := sha256.Sum256([]byte("payout"))
+
+	sig, err := signer.Sign(ctx, Ethereum, 0, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, _ := signer.PublicKey(ctx, Ethereum, 0)
+	if !verify(t, pub, digest[:], sig) {
+		t.Fatal("signature does not verify")
+	}
+	var s btcec.ModNScalar
+	s.SetByteSlice(sig[32:64])
+	if s.IsOverHalfOrder() {
+		t.Fatal("S is not normalised low")
+	}
+	// V recovers the key, as Ethereum and Tron need.
+	recovered, err := crypto.SigToPub(digest[:], sig)
+	if err != nil || !bytes.Equal(crypto.CompressPubkey(recovered), pub) {
+		t.Fatalf("recovery id does not recover the signing key: %v", err)
+	}
+	again, _ := signer.Sign(ctx, Ethereum, 0, digest[:])
+	if !bytes.Equal(sig, again) {
+		t.Fatal("RFC 6979 signatures should be deterministic")
+	}
+	other := sha256.Sum256([]byte("other"))
+	if verify(t, pub, other[:], sig) {
+		t.Fatal("signature verified for the wrong digest")
+	}
+}
+
+func TestAddMnemonicChecksFormat(t *testing.T) {
+	s := NewLocalSigner(false, nil)
+	for _, mnemonic := range []string{
+		// Last word changed: every word is valid but the checksum isn't.
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon",
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abuot",
+		"abandon abandon abandon about",
+	} {
+		if err := s.AddMnemonic(Ethereum, mnemonic); !errors.Is(err, ErrInvalidMnemonic) {
+			t.Errorf("AddMnemonic(%q) = %v, want ErrInvalidMnemonic", mnemonic, err)
+		}
+	}
+	// Extra whitespace is not a typo.
+	if err := s.AddMnemonic(Ethereum, "  "+strings.ReplaceAll(testMnemonic, " ", "\n")+"\n"); err != nil {
+		t.Errorf("mnemonic with extra whitespace: %v", err)
+	}
+}
+
+func verify(t *testing.T, pub, digest, sig []byte) bool {
+	t.Helper()
+	key, err := btcec.ParsePubKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r, s btcec.ModNScalar
+	r.SetByteSlice(sig[:32])
+	s.SetByteSlice(sig[32:64])
+	return ecdsa.NewSignature(&r, &s).Verify(digest, key)
+}
+
+func TestValidateAddress(t *testing.T) {
+	cases := []struct {
+		network, address string
+		testnet, ok      bool
+	}{
+		{Bitcoin, "1LqBGSKuX5yYUonjxT5qGfpUsXKYYWeabA", false, true},
+		{Bitcoin, "3J98t1WpEZ73CNmQviecrnyiWrnqRhWNLy", false, true},
+		{Bitcoin, "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4", false, true},
+		{Bitcoin, "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0", false, true},
+		{Bitcoin, "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t5", false, false},
+		{Bitcoin, "1LqBGSKuX5yYUonjxT5qGfpUsXKYYWeabB", false, false},
+		{Bitcoin, "1LqBGSKuX5yYUonjxT5qGfpUsXKYYWeabA", true, false},
+		{Bitcoin, "tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsx", true, true},
+		{"ETH", "0x9858EfFD232B4033E47d90003D41EC34EcaEda94", false, true},
+		{Ethereum, "0x9858effd232b4033e47d90003d41ec34ecaeda94", false, true},
+		{Ethereum, "0x9858EfFD232B4033E47d90003D41EC34EcaEda95", false, false},
+		{Ethereum, "0x9858EFFD232B4033E47d90003D41EC34EcaEda94", false, false},
+		{Ethereum, "9858effd232b4033e47d90003d41ec34ecaeda94", false, false},
+		{Tron, "TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6t", false, true},
+		{"TRON", "TUEZSdKsoDHQMeZwihtdoBiN46zxhGWYdH", false, true},
+		{Tron, "TUEZSdKsoDHQMeZwihtdoBiN46zxhGWYdh", false, false},
+		{Tron, "1LqBGSKuX5yYUonjxT5qGfpUsXKYYWeabA", false, false},
+		{"DOGE", "DH5yaieqoZN36fDVciNyRueRGvGLR3mr7L", false, false},
+	}
+	for _, tc := range cases {
+		err := ValidateAddress(tc.network, tc.address, tc.testnet)
+		if (err == nil) != tc.ok {
+			t.Errorf("ValidateAddress(%s, %s, testnet=%v) = %v, want ok=%v", tc.network, tc.address, tc.testnet, err, tc.ok)
+		}
+		if err != nil && !errors.Is(err, ErrInvalidAddress) {
+			t.Errorf("ValidateAddress(%s, %s) error %v is not ErrInvalidAddress", tc.network, tc.address, err)
+		}
+	}
+}
+
+func TestAtomicAmount(t *testing.T) {
+	cases := []struct {
+		amount   float64
+		decimals int
+		want     string
+	}{
+		{12.5, 6, "12500000"},
+		{0.1, 18, "100000000000000000"},
+		{0.00012345, 8, "12345"},
+		{19.99, 6, "19990000"},
+	}
+	for _, tc := range cases {
+		got, err := atomicAmount(tc.amount, tc.decimals)
+		if err != nil || got.String() != tc.want {
+			t.Errorf("atomicAmount(%v, %d) = %v, %v; want %s", tc.amount, tc.decimals, got, err, tc.want)
+		}
+	}
+	if _, err := atomicAmount(0.0000001, 6); err == nil {
+		t.Error("amount below one unit should fail")
+	}
+}
+
+func TestBuildAndBroadcastERC20(t *testing.T) {
+	fake := NewFake()
+	svc := New(testSigner(t), fake, fake, Config{})
+	ctx := context.Background()
+
+	tx, err := svc.Build(ctx, Transfer{Reference: "w1", Coin: "USDT", Network: "ERC20",
+		To: "0x52908400098527886E0F7030069857D2E4169EE7", Amount: 25})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.From != "0x9858EfFD232B4033E47d90003D41EC34EcaEda94" {
+		t.Fatalf("from = %s", tx.From)
+	}
+	data := tokenTransferData(mustHex(t, "52908400098527886e0f7030069857d2e4169ee7"), big.NewInt(25_000_000))
+	if !bytes.Contains(tx.Raw, data) {
+		t.Fatal("raw transaction does not carry the ERC-20 transfer call")
+	}
+	var decoded types.Transaction
+	if err := decoded.UnmarshalBinary(tx.Raw); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.To() == nil || decoded.To().Hex() != "0xdAC17F958D2ee523a2206206994597C13D831ec7" {
+		t.Fatalf("raw transaction is addressed to %v, not the USDT contract", decoded.To())
+	}
+	if decoded.ChainId().Int64() != 1 || decoded.Hash().Hex() != tx.TxID {
+		t.Fatalf("chain id %v, hash %s, txID %s", decoded.ChainId(), decoded.Hash().Hex(), tx.TxID)
+	}
+	sender, err := types.Sender(types.NewEIP155Signer(big.NewInt(1)), &decoded)
+	if err != nil || sender.Hex() != tx.From {
+		t.Fatalf("sender = %s, %v", sender.Hex(), err)
+	}
+
+	if err := svc.Broadcast(ctx, tx); err != nil {
+		t.Fatal(err)
+	}
+	// A rebroadcast after a crash must not send a second payment.
+	if err := svc.Broadcast(ctx, tx); err != nil {
+		t.Fatal(err)
+	}
+	if got := fake.Broadcasts(); len(got) != 1 || got[0].TxID != tx.TxID {
+		t.Fatalf("broadcasts = %+v", got)
+	}
+
+	fake.Confirm(tx.TxID, 12)
+	st, err := svc.Status(ctx, "ETH", tx.TxID)
+	if err != nil || !st.Found || st.Confirmations != 12 {
+		t.Fatalf("status = %+v, %v", st, err)
+	}
+
+	next, err := svc.Build(ctx, Transfer{Coin: "ETH", Network: "ERC20", To: "0x52908400098527886E0F7030069857D2E4169EE7", Amount: 0.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.TxID == tx.TxID {
+		t.Fatal("second payout reused the first transaction")
+	}
+}
+
+func TestBuildTRC20(t *testing.T) {
+	now = func() time.Time { return time.UnixMilli(1_700_000_000_000) }
+	defer func() { now = time.Now }()
+
+	fake := NewFake()
+	signer := testSigner(t)
+	svc := New(signer, fake, fake, Config{})
+	tx, err := svc.Build(context.Background(), Transfer{Coin: "USDT", Network: "TRC20",
+		To: "TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6t", Amount: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := tronRawData(tx.Raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(raw)
+	if hex.EncodeToString(sum[:]) != tx.TxID {
+		t.Fatal("Tron txID must be sha256(raw_data)")
+	}
+	sig := tx.Raw[len(tx.Raw)-65:]
+	if sig[64] != 27 && sig[64] != 28 {
+		t.Fatalf("Tron signature V = %d, want 27 or 28", sig[64])
+	}
+	pub, _ := signer.PublicKey(context.Background(), Tron, 0)
+	if !verify(t, pub, sum[:], sig) {
+		t.Fatal("Tron signature does not verify")
+	}
+	if !bytes.Contains(raw, []byte(tronTriggerTypeURL)) {
+		t.Fatal("raw_data is not a TriggerSmartContract call")
+	}
+}
+
+func TestBuildBitcoin(t *testing.T) {
+	fake := NewFake()
+	svc := New(testSigner(t), fake, fake, Config{BitcoinFeeRate: 5})
+	from := "1LqBGSKuX5yYUonjxT5qGfpUsXKYYWeabA"
+	fake.AddUTXO(from, UTXO{TxID: hex.EncodeToString(bytes.Repeat([]byte{1}, 32)), Vout: 0, Value: 30_000})
+	fake.AddUTXO(from, UTXO{TxID: hex.EncodeToString(bytes.Repeat([]byte{2}, 32)), Vout: 1, Value: 80_000})
+
+	tx, err := svc.Build(context.Background(), Transfer{Coin: "BTC", Network: "BTC",
+		To: "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4", Amount: 0.0005})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// One input (the larger) covers 50,000 sat plus fee; change returns
+	// to the hot wallet.
+	var msg wire.MsgTx
+	if err := msg.Deserialize(bytes.NewReader(tx.Raw)); err != nil {
+		t.Fatal(err)
+	}
+	if len(msg.TxIn) != 1 || msg.TxIn[0].PreviousOutPoint.Index != 1 {
+		t.Fatalf("inputs = %+v, want the 80,000 sat output", msg.TxIn)
+	}
+	fee := int64(5 * (txOverheadSize + p2pkhInputSize + 2*txOutputSize))
+	if len(msg.TxOut) != 2 || msg.TxOut[0].Value != 50_000 || msg.TxOut[1].Value != 80_000-50_000-fee {
+		t.Fatalf("outputs = %+v", msg.TxOut)
+	}
+	if msg.TxHash().String() != tx.TxID {
+		t.Fatalf("txID %s, want %s", tx.TxID, msg.TxHash())
+	}
+	// The signature script must satisfy the spent P2PKH script.
+	prevScript, _ := bitcoinScript(from, false)
+	prevOuts := txscript.NewCannedPrevOutputFetcher(prevScript, 80_000)
+	vm, err := txscript.NewEngine(prevScript, &msg, 0, txscript.StandardVerifyFlags, nil,
+		txscript.NewTxSigHashes(&msg, prevOuts), 80_000, prevOuts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.Execute(); err != nil {
+		t.Fatalf("input does not verify: %v", err)
+	}
+	if _, err := svc.Build(context.Background(), Transfer{Coin: "BTC", Network: "BTC",
+		To: "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4", Amount: 0.01}); err == nil {
+		t.Fatal("payout larger than the hot wallet balance should fail")
+	}
+}
+
+func TestBuildRejectsBadInput(t *testing.T) {
+	fake := NewFake()
+	svc := New(testSigner(t), fake, fake, Config{})
+	ctx := context.Background()
+	if _, err := svc.Build(ctx, Transfer{Coin: "USDT", Network: "TRC20", To: "0x52908400098527886E0F7030069857D2E4169EE7", Amount: 1}); !errors.Is(err, ErrInvalidAddress) {
+		t.Errorf("wrong-network address: %v", err)
+	}
+	if _, err := svc.Build(ctx, Transfer{Coin: "DOGE", Network: "DOGE", To: "x", Amount: 1}); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("unsupported coin: %v", err)
+	}
+	empty := New(NewLocalSigner(false, nil), fake, fake, Config{})
+	if _, err := empty.Build(ctx, Transfer{Coin: "ETH", Network: "ERC20", To: "0x52908400098527886E0F7030069857D2E4169EE7", Amount: 1}); !errors.Is(err, ErrNoKey) {
+		t.Errorf("missing key: %v", err)
+	}
+}
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestAddSealedMnemonic(t *testing.T) {
+	ctx := context.Background()
+	kms, err := secrets.CreateKeyFile(filepath.Join(t.TempDir(), "secrets.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	aad := secrets.MasterWalletMnemonic.AAD("USDT")
+	sealed, err := secrets.Seal(ctx, kms, []byte(testMnemonic), aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewLocalSigner(false, kms)
+	if err := s.AddSealedMnemonic(ctx, Tron, sealed, aad); err != nil {
+		t.Fatal(err)
+	}
+	addr, err := New(s, NewFake(), NewFake(), Config{}).HotWallet(ctx, Tron)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr != "TUEZSdKsoDHQMeZwihtdoBiN46zxhGWYdH" {
+		t.Errorf("hot wallet = %s", addr)
+	}
+	if err := s.AddSealedMnemonic(ctx, Tron, sealed, secrets.MasterWalletMnemonic.AAD("BTC")); err == nil {
+		t.Error("opened a mnemonic sealed for another document")
+	}
+	if err := NewLocalSigner(false, nil).AddSealedMnemonic(ctx, Tron, sealed, aad); err == nil {
+		t.Error("signer without a KMS accepted a sealed mnemonic")
+	}
+
+	typo, _ := secrets.Seal(ctx, kms, []byte(strings.Replace(testMnemonic, "about", "above", 1)), aad)
+	if err := s.AddSealedMnemonic(ctx, Tron, typo, aad); !errors.Is(err, ErrInvalidMnemonic) {
+		t.Errorf("mistyped sealed mnemonic: %v", err)
+	}
+	if addr, _ := New(s, NewFake(), NewFake(), Config{}).HotWallet(ctx, Tron); addr != "TUEZSdKsoDHQMeZwihtdoBiN46zxhGWYdH" {
+		t.Errorf("a rejected mnemonic replaced the wallet: %s", addr)
+	}
+}
+
+func TestNoncesSurviveFailedBroadcast(t *testing.T) {
+	clock := time.Unix(1_700_000_000, 0)
+	now = func() time.Time { return clock }
+	defer func() { now = time.Now }()
+
+	fake := NewFake()
+	svc := New(testSigner(t), fake, fake, Config{})
+	ctx := context.Background()
+	pay := func() (*SignedTx, uint64) {
+		t.Helper()
+		tx, err := svc.Build(ctx, Transfer{Coin: "ETH", Network: "ERC20", To: "0x52908400098527886E0F7030069857D2E4169EE7", Amount: 0.1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded types.Transaction
+		if err := decoded.UnmarshalBinary(tx.Raw); err != nil {
+			t.Fatal(err)
+		}
+		return tx, decoded.Nonce()
+	}
+
+	first, n := pay()
+	fake.FailNext(errors.New("node unavailable"))
+	if err := svc.Broadcast(ctx, first); err == nil || n != 0 {
+		t.Fatalf("first payout: nonce %d, %v", n, err)
+	}
+	// The node never saw the first payout, but the second mustn't reuse
+	// its nonce.
+	second, n := pay()
+	if n != 1 {
+		t.Fatalf("second payout nonce = %d, want 1", n)
+	}
+	if dropped, err := svc.Dropped(ctx, first); err != nil || dropped {
+		t.Fatalf("first payout dropped = %v, %v", dropped, err)
+	}
+
+	// Once stale, the first payout's nonce is handed out again, and the
+	// transaction that loses it is reported dropped.
+	clock = clock.Add(nonceStaleAfter + time.Minute)
+	third, n := pay()
+	if n != 0 {
+		t.Fatalf("payout after stale nonce = %d, want 0", n)
+	}
+	if err := svc.Broadcast(ctx, third); err != nil {
+		t.Fatal(err)
+	}
+	if dropped, _ := svc.Dropped(ctx, first); !dropped {
+		t.Fatal("first payout's nonce is used, so it should be dropped")
+	}
+	if dropped, _ := svc.Dropped(ctx, second); dropped {
+		t.Fatal("second payout can still be mined")
+	}
+	fourth, n := pay()
+	if n != 1 {
+		t.Fatalf("next nonce = %d, want the stale second payout's 1", n)
+	}
+	if err := svc.Broadcast(ctx, fourth); err != nil {
+		t.Fatal(err)
+	}
+	if dropped, _ := svc.Dropped(ctx, second); !dropped {
+		t.Fatal("second payout's nonce is used, so it should be dropped")
+	}
+	if _, n := pay(); n != 2 {
+		t.Fatalf("next nonce = %d, want 2", n)
+	}
+}
+
+func TestDroppedTron(t *testing.T) {
+	clock := time.UnixMilli(1_700_000_000_000)
+	now = func() time.Time { return clock }
+	defer func() { now = time.Now }()
+
+	fake := NewFake()
+	svc := New(testSigner(t), fake, fake, Config{})
+	tx, err := svc.Build(context.Background(), Transfer{Coin: "USDT", Network: "TRC20",
+		To: "TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6t", Amount: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := tronRawData(tx.Raw)
+	if exp, err := tronExpiration(raw); err != nil || !exp.Equal(clock.Add(tronExpiry)) {
+		t.Fatalf("expiration = %v, %v", exp, err)
+	}
+	if dropped, err := svc.Dropped(context.Background(), tx); err != nil || dropped {
+		t.Fatalf("fresh transaction dropped = %v, %v", dropped, err)
+	}
+	clock = clock.Add(tronExpiry + 2*time.Minute)
+	if dropped, _ := svc.Dropped(context.Background(), tx); !dropped {
+		t.Fatal("expired transaction should be dropped")
+	}
+}
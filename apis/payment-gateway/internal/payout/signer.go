@@ -0,0 +1,240 @@
+package payout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/tyler-smith/go-bip39"
+
+	"gamehub/payment-gateway/internal/secrets"
+)
+
+// Signer holds the wallet keys: the hot wallet and the user deposit
+// addresses derived from the same master mnemonic, told apart by address
+// index. Implementations may keep keys in memory (LocalSigner) or
+// delegate to an HSM or KMS; payout code only ever sees public keys and
+// signatures.
+type Signer interface {
+	// PublicKey returns the 33-byte compressed secp256k1 key at address
+	// index on network.
+	PublicKey(ctx context.Context, network string, index uint32) ([]byte, error)
+	// Sign signs a 32-byte digest with that key and returns R || S || V,
+	// S normalised low and V the 0/1 recovery id.
+	Sign(ctx context.Context, network string, index uint32, digest []byte) ([]byte, error)
+}
+
+// ErrNoKey is returned by a Signer that has no key for a network.
+var ErrNoKey = errors.New("no wallet key for network")
+
+// LocalSigner derives wallet keys from BIP-39 mnemonics and keeps them in
+// memory. Sealed mnemonics are decrypted here and nowhere else; only the
+// BIP-44 account key is kept, and address keys are derived from it on
+// demand.
+type LocalSigner struct {
+	mu       sync.RWMutex
+	accounts map[string]*hdkeychain.ExtendedKey
+	keys     map[string]*btcec.PrivateKey // derived address keys by network/index
+	kms      secrets.KMS
+	testnet  bool
+}
+
+// ErrInvalidMnemonic is returned for a mnemonic with unknown words, the
+// wrong length or a bad checksum, so a mistyped mnemonic is refused
+// rather than opening an empty wallet.
+var ErrInvalidMnemonic = errors.New("payout: invalid mnemonic")
+
+// NewLocalSigner returns an empty signer; add keys with AddSealedMnemonic
+// (or AddMnemonic in tests). kms opens sealed mnemonics and may be nil
+// when there are none. testnet selects the Bitcoin testnet derivation
+// path.
+func NewLocalSigner(testnet bool, kms secrets.KMS) *LocalSigner {
+	return &LocalSigner{
+		accounts: map[string]*hdkeychain.ExtendedKey{},
+		keys:     map[string]*btcec.PrivateKey{},
+		kms:      kms,
+		testnet:  testnet,
+	}
+}
+
+// AddMnemonic derives the network's account key from mnemonic, using the
+// BIP-44 path Tatum uses for the same chain, so address index i signs for
+// the deposit address Tatum derives at i.
+func (s *LocalSigner) AddMnemonic(network, mnemonic string) error {
+	seed, err := mnemonicSeed(mnemonic)
+	if err != nil {
+		return err
+	}
+	return s.addSeed(network, seed)
+}
+
+// AddSealedMnemonic opens a mnemonic sealed with aad and derives the
+// network's account key from it like AddMnemonic. The opened plaintext
+// and seed are wiped once the key is derived.
+func (s *LocalSigner) AddSealedMnemonic(ctx context.Context, network string, sealed *secrets.Sealed, aad []byte) error {
+	if s.kms == nil {
+		return errors.New("payout: no KMS to open sealed mnemonics")
+	}
+	mnemonic, err := secrets.Open(ctx, s.kms, sealed, aad)
+	if err != nil {
+		return err
+	}
+	defer wipe(mnemonic)
+	seed, err := mnemonicSeed(string(mnemonic))
+	if err != nil {
+		return fmt.Errorf("%s mnemonic: %w", network, err)
+	}
+	return s.addSeed(network, seed)
+}
+
+func (s *LocalSigner) addSeed(network string, seed []byte) error {
+	defer wipe(seed)
+	network = NormalizeNetwork(network)
+	path, ok := derivationPaths(s.testnet)[network]
+	if !ok {
+		return fmt.Errorf("payout: unsupported network %q", network)
+	}
+	account, err := deriveKey(seed, path)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.accounts[network] = account
+	for id := range s.keys {
+		if strings.HasPrefix(id, network+"/") {
+			delete(s.keys, id)
+		}
+	}
+	return nil
+}
+
+// PublicKey implements Signer.
+func (s *LocalSigner) PublicKey(_ context.Context, network string, index uint32) ([]byte, error) {
+	key, err := s.key(network, index)
+	if err != nil {
+		return nil, err
+	}
+	return key.PubKey().SerializeCompressed(), nil
+}
+
+// Sign implements Signer.
+func (s *LocalSigner) Sign(_ context.Context, network string, index uint32, digest []byte) ([]byte, error) {
+	if len(digest) != 32 {
+		return nil, errors.New("payout: digest must be 32 bytes")
+	}
+	key, err := s.key(network, index)
+	if err != nil {
+		return nil, err
+	}
+	// SignCompact returns V || R || S with V = 27 + recovery id + 4 for a
+	// compressed key; S is already low.
+	compact, err := ecdsa.SignCompact(key, digest, true)
+	if err != nil {
+		return nil, err
+	}
+	sig := make([]byte, 65)
+	copy(sig, compact[1:])
+	sig[64] = compact[0] - 27 - 4
+	return sig, nil
+}
+
+func (s *LocalSigner) key(network string, index uint32) (*btcec.PrivateKey, error) {
+	network = NormalizeNetwork(network)
+	id := fmt.Sprintf("%s/%d", network, index)
+	s.mu.RLock()
+	key, cached := s.keys[id]
+	account, ok := s.accounts[network]
+	s.mu.RUnlock()
+	if cached {
+		return key, nil
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w %s", ErrNoKey, network)
+	}
+	child, err := account.Derive(index)
+	if err != nil {
+		return nil, err
+	}
+	if key, err = child.ECPrivKey(); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.keys[id] = key
+	s.mu.Unlock()
+	return key, nil
+}
+
+// derivationPaths are the BIP-44 account paths per network, without the
+// address index.
+func derivationPaths(testnet bool) map[string]string {
+	btc := "m/44'/0'/0'/0"
+	if testnet {
+		btc = "m/44'/1'/0'/0"
+	}
+	return map[string]string{
+		Bitcoin:  btc,
+		Ethereum: "m/44'/60'/0'/0",
+		Tron:     "m/44'/195'/0'/0",
+	}
+}
+
+// ValidateMnemonic reports ErrInvalidMnemonic for a mnemonic AddMnemonic
+// would refuse, so it can be rejected before it is stored.
+func ValidateMnemonic(mnemonic string) error {
+	seed, err := mnemonicSeed(mnemonic)
+	wipe(seed)
+	return err
+}
+
+// mnemonicSeed checks a BIP-39 mnemonic against the English word list,
+// including its checksum, and returns its 64-byte seed.
+func mnemonicSeed(mnemonic string) ([]byte, error) {
+	normalized := strings.Join(strings.Fields(mnemonic), " ")
+	seed, err := bip39.NewSeedWithErrorChecking(normalized, "")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidMnemonic, err)
+	}
+	return seed, nil
+}
+
+func wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// deriveKey walks a BIP-32 path such as m/44'/60'/0'/0 from seed. The
+// chain parameters only affect how keys would be serialized, which they
+// never are.
+func deriveKey(seed []byte, path string) (*hdkeychain.ExtendedKey, error) {
+	key, err := hdkeychain.NewMaster(seed, &chaincfg.MainNetParams)
+	if err != nil {
+		return nil, err
+	}
+	parts := strings.Split(path, "/")
+	if len(parts) == 0 || parts[0] != "m" {
+		return nil, fmt.Errorf("payout: bad derivation path %q", path)
+	}
+	for _, part := range parts[1:] {
+		n, err := strconv.ParseUint(strings.TrimSuffix(part, "'"), 10, 31)
+		if err != nil {
+			return nil, fmt.Errorf("payout: bad derivation path %q", path)
+		}
+		index := uint32(n)
+		if strings.HasSuffix(part, "'") {
+			index += hdkeychain.HardenedKeyStart
+		}
+		if key, err = key.Derive(index); err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
@@ -0,0 +1,198 @@
+package payout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+)
+
+// ErrDust is returned by PlanSweep when a deposit address holds less than
+// the sweep minimum, or too little to pay for its own transfer.
+var ErrDust = errors.New("balance below sweep minimum")
+
+// Sweep is a deposit address to consolidate into treasury.
+type Sweep struct {
+	// Reference identifies the sweep in errors (the deposit address).
+	Reference string
+	Coin      string
+	Network   string
+	// Index is the deposit address's derivation index.
+	Index uint32
+	// To is the treasury address.
+	To string
+	// Min is the smallest balance worth sweeping, in whole coins.
+	Min float64
+}
+
+// SweepPlan is the next step of a sweep. Exactly one of Tx and TopUp is
+// set.
+type SweepPlan struct {
+	From string
+	// Amount is what reaches treasury, in whole coins.
+	Amount float64
+	// Fee is the estimated network fee in the chain's native coin.
+	Fee float64
+	// Tx moves the balance to treasury.
+	Tx *SignedTx
+	// TopUp sends the native coin from the hot wallet to a token deposit
+	// address that can't pay for its own transfer. Plan the sweep again
+	// once it has confirmed.
+	TopUp *SignedTx
+}
+
+// PlanSweep reads the deposit address's balance and builds the
+// transaction emptying it into s.To. Native coins pay their own fee out
+// of the balance; token transfers need gas at the deposit address and
+// get a TopUp from the hot wallet when it is short. Nothing is sent.
+func (s *Service) PlanSweep(ctx context.Context, sw Sweep) (*SweepPlan, error) {
+	a, err := s.cfg.asset(sw.Coin, sw.Network)
+	if err != nil {
+		return nil, err
+	}
+	if sw.Index == s.cfg.HotWalletIndex {
+		return nil, fmt.Errorf("sweep %s: index %d is the hot wallet", sw.Reference, sw.Index)
+	}
+	if err := ValidateAddress(a.network, sw.To, s.cfg.Testnet); err != nil {
+		return nil, err
+	}
+	from, err := s.Address(ctx, a.network, sw.Index)
+	if err != nil {
+		return nil, err
+	}
+	min, err := atomicAmount(sw.Min, a.decimals)
+	if err != nil {
+		min = new(big.Int)
+	}
+
+	var plan *SweepPlan
+	switch {
+	case a.network == Bitcoin:
+		plan, err = s.planBitcoinSweep(ctx, from, sw, min)
+	case a.contract == "":
+		plan, err = s.planEthereumSweep(ctx, from, sw, a, min)
+	default:
+		plan, err = s.planTokenSweep(ctx, from, sw, a, min)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("sweep %s: %w", sw.Reference, err)
+	}
+	return plan, nil
+}
+
+// planBitcoinSweep spends every UTXO of the deposit address into one
+// output, paying the fee out of it.
+func (s *Service) planBitcoinSweep(ctx context.Context, from string, sw Sweep, min *big.Int) (*SweepPlan, error) {
+	outScript, err := bitcoinScript(sw.To, s.cfg.Testnet)
+	if err != nil {
+		return nil, err
+	}
+	balance, err := s.chain.Balance(ctx, Bitcoin, from, "")
+	if err != nil {
+		return nil, err
+	}
+	if balance.Sign() <= 0 || balance.Cmp(min) < 0 {
+		return nil, ErrDust
+	}
+	utxos, err := s.chain.BitcoinUTXOs(ctx, from, balance.Int64())
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(utxos, func(i, j int) bool { return utxos[i].Value > utxos[j].Value })
+	if len(utxos) > maxBitcoinInput {
+		utxos = utxos[:maxBitcoinInput]
+	}
+	var total int64
+	for _, u := range utxos {
+		total += u.Value
+	}
+	fee := s.bitcoinFee(len(utxos), 1)
+	value := total - fee
+	if value < bitcoinDust || value < min.Int64() {
+		return nil, ErrDust
+	}
+
+	tx := &SignedTx{Network: Bitcoin, From: from}
+	if err := s.signBitcoin(ctx, tx, sw.Index, utxos, []txOut{{value: value, script: outScript}}); err != nil {
+		return nil, err
+	}
+	return &SweepPlan{From: from, Amount: wholeUnits(big.NewInt(value), 8), Fee: wholeUnits(big.NewInt(fee), 8), Tx: tx}, nil
+}
+
+// planEthereumSweep sends the whole ether balance less the transfer's
+// gas.
+func (s *Service) planEthereumSweep(ctx context.Context, from string, sw Sweep, a asset, min *big.Int) (*SweepPlan, error) {
+	balance, err := s.chain.Balance(ctx, Ethereum, from, "")
+	if err != nil {
+		return nil, err
+	}
+	if balance.Cmp(min) < 0 {
+		return nil, ErrDust
+	}
+	gasPrice, err := s.chain.EthereumGasPrice(ctx)
+	if err != nil {
+		return nil, err
+	}
+	fee := new(big.Int).Mul(gasPrice, big.NewInt(21000))
+	value := new(big.Int).Sub(balance, fee)
+	if value.Sign() <= 0 || value.Cmp(min) < 0 {
+		return nil, ErrDust
+	}
+
+	tx := &SignedTx{Network: Ethereum, From: from}
+	if err := s.buildEthereum(ctx, tx, a, sw.Index, sw.To, value, gasPrice); err != nil {
+		return nil, err
+	}
+	return &SweepPlan{From: from, Amount: wholeUnits(value, a.decimals), Fee: wholeUnits(fee, 18), Tx: tx}, nil
+}
+
+// planTokenSweep sends the whole token balance, or tops the address up
+// with enough of the native coin to pay for that first.
+func (s *Service) planTokenSweep(ctx context.Context, from string, sw Sweep, a asset, min *big.Int) (*SweepPlan, error) {
+	balance, err := s.chain.Balance(ctx, a.network, from, a.contract)
+	if err != nil {
+		return nil, err
+	}
+	if balance.Sign() <= 0 || balance.Cmp(min) < 0 {
+		return nil, ErrDust
+	}
+
+	native := asset{network: a.network, decimals: 18}
+	var gasPrice, fee *big.Int
+	if a.network == Tron {
+		native.decimals = 6
+		fee = big.NewInt(s.cfg.TronFeeLimit)
+	} else {
+		if gasPrice, err = s.chain.EthereumGasPrice(ctx); err != nil {
+			return nil, err
+		}
+		fee = new(big.Int).Mul(gasPrice, new(big.Int).SetUint64(s.cfg.ERC20GasLimit))
+	}
+	gas, err := s.chain.Balance(ctx, a.network, from, "")
+	if err != nil {
+		return nil, err
+	}
+	plan := &SweepPlan{From: from, Amount: wholeUnits(balance, a.decimals), Fee: wholeUnits(fee, native.decimals)}
+
+	if gas.Cmp(fee) < 0 {
+		topUp, err := s.build(ctx, native, s.cfg.HotWalletIndex, from, new(big.Int).Sub(fee, gas))
+		if err != nil {
+			return nil, fmt.Errorf("gas top-up: %w", err)
+		}
+		plan.TopUp = topUp
+		return plan, nil
+	}
+
+	tx := &SignedTx{Network: a.network, From: from}
+	if a.network == Tron {
+		err = s.buildTron(ctx, tx, a, sw.Index, sw.To, balance)
+	} else {
+		err = s.buildEthereum(ctx, tx, a, sw.Index, sw.To, balance, gasPrice)
+	}
+	if err != nil {
+		return nil, err
+	}
+	plan.Tx = tx
+	return plan, nil
+}
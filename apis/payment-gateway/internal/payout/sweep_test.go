@@ -0,0 +1,104 @@
+package payout
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+const treasuryETH = "0x52908400098527886E0F7030069857D2E4169EE7"
+
+func TestPlanSweepEthereum(t *testing.T) {
+	fake := NewFake()
+	svc := New(testSigner(t), fake, fake, Config{})
+	ctx := context.Background()
+	from, _ := svc.Address(ctx, Ethereum, 1)
+	fake.SetBalance(Ethereum, from, "", big.NewInt(10_000_000_000_000_000)) // 0.01 ETH
+
+	plan, err := svc.PlanSweep(ctx, Sweep{Coin: "ETH", Network: "ETH", Index: 1, To: treasuryETH, Min: 0.005})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.TopUp != nil || plan.Tx == nil || plan.Tx.From != from {
+		t.Fatalf("plan = %+v", plan)
+	}
+	// 21000 gas at the fake's 20 gwei comes out of the balance.
+	if plan.Fee != 0.00042 || plan.Amount != 0.00958 {
+		t.Errorf("amount %v fee %v", plan.Amount, plan.Fee)
+	}
+
+	if _, err := svc.PlanSweep(ctx, Sweep{Coin: "ETH", Network: "ETH", Index: 1, To: treasuryETH, Min: 0.05}); !errors.Is(err, ErrDust) {
+		t.Errorf("balance below minimum: %v", err)
+	}
+	if _, err := svc.PlanSweep(ctx, Sweep{Coin: "ETH", Network: "ETH", Index: 0, To: treasuryETH}); err == nil {
+		t.Error("swept the hot wallet")
+	}
+}
+
+func TestPlanSweepTRC20TopsUpGas(t *testing.T) {
+	fake := NewFake()
+	cfg := Config{}.withDefaults()
+	svc := New(testSigner(t), fake, fake, cfg)
+	ctx := context.Background()
+	from, _ := svc.Address(ctx, Tron, 3)
+	hot, _ := svc.HotWallet(ctx, Tron)
+	fake.SetBalance(Tron, from, cfg.USDTTRC20Contract, big.NewInt(10_000_000))
+	fake.SetBalance(Tron, from, "", big.NewInt(5_000_000))
+	sweep := Sweep{Coin: "USDT", Network: "TRC20", Index: 3, To: hot, Min: 5}
+
+	plan, err := svc.PlanSweep(ctx, sweep)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Tx != nil || plan.TopUp == nil || plan.TopUp.From != hot {
+		t.Fatalf("plan = %+v", plan)
+	}
+	raw, _ := tronRawData(plan.TopUp.Raw)
+	if !bytes.Contains(raw, []byte(tronTransferTypeURL)) {
+		t.Fatal("top-up is not a TRX transfer")
+	}
+	// The top-up covers the shortfall against the fee limit.
+	owed := protoVarint(nil, 3, uint64(cfg.TronFeeLimit-5_000_000))
+	if !bytes.Contains(raw, owed) {
+		t.Fatal("top-up amount is not the shortfall")
+	}
+
+	fake.SetBalance(Tron, from, "", big.NewInt(cfg.TronFeeLimit))
+	plan, err = svc.PlanSweep(ctx, sweep)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.TopUp != nil || plan.Tx == nil || plan.Tx.From != from || plan.Amount != 10 {
+		t.Fatalf("plan = %+v", plan)
+	}
+}
+
+func TestPlanSweepBitcoin(t *testing.T) {
+	fake := NewFake()
+	svc := New(testSigner(t), fake, fake, Config{BitcoinFeeRate: 5})
+	ctx := context.Background()
+	from, _ := svc.Address(ctx, Bitcoin, 2)
+	fake.AddUTXO(from, UTXO{TxID: hex.EncodeToString(bytes.Repeat([]byte{1}, 32)), Vout: 0, Value: 30_000})
+	fake.AddUTXO(from, UTXO{TxID: hex.EncodeToString(bytes.Repeat([]byte{2}, 32)), Vout: 1, Value: 80_000})
+
+	plan, err := svc.PlanSweep(ctx, Sweep{Coin: "BTC", Network: "BTC", Index: 2,
+		To: "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4", Min: 0.0005})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Tx.Raw[4] != 2 {
+		t.Fatalf("inputs = %d, want both", plan.Tx.Raw[4])
+	}
+	fee := int64(5 * (txOverheadSize + 2*p2pkhInputSize + txOutputSize))
+	if want := float64(110_000-fee) / 1e8; plan.Amount != want {
+		t.Errorf("amount = %v, want %v", plan.Amount, want)
+	}
+
+	if _, err := svc.PlanSweep(ctx, Sweep{Coin: "BTC", Network: "BTC", Index: 2,
+		To: "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4", Min: 0.01}); !errors.Is(err, ErrDust) {
+		t.Errorf("balance below minimum: %v", err)
+	}
+}
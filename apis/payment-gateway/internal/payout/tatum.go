@@ -0,0 +1,141 @@
+package payout
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+
+	"gamehub/payment-gateway/internal/tatum"
+)
+
+// Tatum implements ChainReader and Broadcaster over the Tatum API.
+type Tatum struct {
+	client *tatum.Client
+}
+
+// NewTatum wraps a Tatum client.
+func NewTatum(client *tatum.Client) *Tatum {
+	return &Tatum{client: client}
+}
+
+var tatumChains = map[string]string{
+	Bitcoin:  "bitcoin",
+	Ethereum: "ethereum",
+	Tron:     "tron",
+}
+
+// EthereumNonce implements ChainReader.
+func (t *Tatum) EthereumNonce(ctx context.Context, address string) (uint64, error) {
+	return t.client.EthereumNonce(ctx, address)
+}
+
+// EthereumGasPrice implements ChainReader.
+func (t *Tatum) EthereumGasPrice(ctx context.Context) (*big.Int, error) {
+	gwei, err := t.client.EthereumGasPriceGwei(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if gwei <= 0 {
+		return nil, fmt.Errorf("tatum returned gas price %v gwei", gwei)
+	}
+	wei, _ := new(big.Float).Mul(big.NewFloat(gwei), big.NewFloat(1e9)).Int(nil)
+	return wei, nil
+}
+
+// TronBlockRef implements ChainReader.
+func (t *Tatum) TronBlockRef(ctx context.Context) (int64, []byte, error) {
+	number, hash, err := t.client.TronLatestBlock(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+	id, err := hex.DecodeString(strings.TrimPrefix(hash, "0x"))
+	if err != nil {
+		return 0, nil, fmt.Errorf("tatum tron block hash: %w", err)
+	}
+	return number, id, nil
+}
+
+// BitcoinUTXOs implements ChainReader.
+func (t *Tatum) BitcoinUTXOs(ctx context.Context, address string, atLeast int64) ([]UTXO, error) {
+	utxos, err := t.client.BitcoinUTXOs(ctx, address, float64(atLeast)/1e8)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]UTXO, len(utxos))
+	for i, u := range utxos {
+		out[i] = UTXO{TxID: u.TxHash, Vout: u.Index, Value: int64(math.Round(u.Value * 1e8))}
+	}
+	return out, nil
+}
+
+// Balance implements ChainReader.
+func (t *Tatum) Balance(ctx context.Context, network, address, contract string) (*big.Int, error) {
+	switch network {
+	case Ethereum:
+		if contract != "" {
+			balance, err := t.client.EthereumTokenBalance(ctx, contract, address)
+			if err != nil {
+				return nil, err
+			}
+			return parseUnits(balance, 0)
+		}
+		balance, err := t.client.EthereumBalance(ctx, address)
+		if err != nil {
+			return nil, err
+		}
+		return parseUnits(balance, 18)
+	case Tron:
+		account, err := t.client.TronAccount(ctx, address)
+		if err != nil {
+			return nil, err
+		}
+		if contract == "" {
+			return big.NewInt(account.Balance), nil
+		}
+		return parseUnits(account.TRC20[contract], 0)
+	case Bitcoin:
+		balance, err := t.client.BitcoinBalance(ctx, address)
+		if err != nil {
+			return nil, err
+		}
+		return parseUnits(balance, 8)
+	}
+	return nil, fmt.Errorf("%w: network %s", ErrUnsupported, network)
+}
+
+// Broadcast implements Broadcaster.
+func (t *Tatum) Broadcast(ctx context.Context, network string, raw []byte) (string, error) {
+	chain, ok := tatumChains[network]
+	if !ok {
+		return "", fmt.Errorf("%w: network %s", ErrUnsupported, network)
+	}
+	data := hex.EncodeToString(raw)
+	if network == Ethereum {
+		data = "0x" + data
+	}
+	return t.client.Broadcast(ctx, chain, data)
+}
+
+// Status implements Broadcaster.
+func (t *Tatum) Status(ctx context.Context, network, txID string) (TxStatus, error) {
+	chain, ok := tatumChains[network]
+	if !ok {
+		return TxStatus{}, fmt.Errorf("%w: network %s", ErrUnsupported, network)
+	}
+	info, err := t.client.TransactionInfo(ctx, chain, txID)
+	if err != nil || !info.Found {
+		return TxStatus{}, err
+	}
+	st := TxStatus{Found: true, Failed: info.Failed}
+	if info.BlockNumber > 0 {
+		head, err := t.client.CurrentBlock(ctx, chain)
+		if err != nil {
+			return TxStatus{}, err
+		}
+		st.Confirmations = int(head - info.BlockNumber + 1)
+	}
+	return st, nil
+}
@@ -0,0 +1,149 @@
+package payout
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// Tron transactions are protobuf messages; only the handful of fields
+// TRX and TRC-20 transfers need are encoded here.
+const (
+	tronTransferContract     = 1
+	tronTransferTypeURL      = "type.googleapis.com/protocol.TransferContract"
+	tronTriggerSmartContract = 31
+	tronTriggerTypeURL       = "type.googleapis.com/protocol.TriggerSmartContract"
+	tronExpiry               = 10 * time.Minute
+)
+
+// now is replaced in tests for deterministic transactions.
+var now = time.Now
+
+// buildTron builds and signs a TRC-20 transfer, or a TRX transfer for
+// the native coin, referencing a recent block.
+func (s *Service) buildTron(ctx context.Context, tx *SignedTx, a asset, from uint32, to string, amount *big.Int) error {
+	recipient, err := tronAddress(to)
+	if err != nil {
+		return err
+	}
+	owner, err := tronAddress(tx.From)
+	if err != nil {
+		return err
+	}
+	if a.contract == "" {
+		transfer := protoBytes(nil, 1, owner)
+		transfer = protoBytes(transfer, 2, recipient)
+		transfer = protoVarint(transfer, 3, amount.Uint64())
+		return s.signTron(ctx, tx, from, tronTransferContract, tronTransferTypeURL, transfer, 0)
+	}
+	contract, err := tronAddress(a.contract)
+	if err != nil {
+		return err
+	}
+	trigger := protoBytes(nil, 1, owner)
+	trigger = protoBytes(trigger, 2, contract)
+	trigger = protoBytes(trigger, 4, tokenTransferData(recipient[1:], amount))
+	return s.signTron(ctx, tx, from, tronTriggerSmartContract, tronTriggerTypeURL, trigger, s.cfg.TronFeeLimit)
+}
+
+// signTron wraps a single contract call in a transaction and signs it.
+// feeLimit caps energy for smart contract calls and is left out when 0.
+func (s *Service) signTron(ctx context.Context, tx *SignedTx, from uint32, contractType uint64, typeURL string, body []byte, feeLimit int64) error {
+	number, blockID, err := s.chain.TronBlockRef(ctx)
+	if err != nil {
+		return err
+	}
+	if len(blockID) != 32 {
+		return errors.New("tron block id must be 32 bytes")
+	}
+
+	param := protoBytes(nil, 1, []byte(typeURL))
+	param = protoBytes(param, 2, body)
+
+	call := protoVarint(nil, 1, contractType)
+	call = protoBytes(call, 2, param)
+
+	refBytes := make([]byte, 8)
+	binary.BigEndian.PutUint64(refBytes, uint64(number))
+	ts := now().UnixMilli()
+
+	raw := protoBytes(nil, 1, refBytes[6:8])
+	raw = protoBytes(raw, 4, blockID[8:16])
+	raw = protoVarint(raw, 8, uint64(ts+tronExpiry.Milliseconds()))
+	raw = protoBytes(raw, 11, call)
+	raw = protoVarint(raw, 14, uint64(ts))
+	if feeLimit > 0 {
+		raw = protoVarint(raw, 18, uint64(feeLimit))
+	}
+
+	digest := sha256.Sum256(raw)
+	sig, err := s.signer.Sign(ctx, Tron, from, digest[:])
+	if err != nil {
+		return err
+	}
+	sig[64] += 27
+
+	signed := protoBytes(nil, 1, raw)
+	tx.Raw = protoBytes(signed, 2, sig)
+	tx.TxID = hex.EncodeToString(digest[:])
+	return nil
+}
+
+// tronRawData extracts raw_data from a serialized Transaction.
+func tronRawData(tx []byte) ([]byte, error) {
+	if len(tx) < 2 || tx[0] != 0x0a {
+		return nil, errors.New("payout: not a Tron transaction")
+	}
+	n, read := binary.Uvarint(tx[1:])
+	if read <= 0 || uint64(len(tx)-1-read) < n {
+		return nil, errors.New("payout: truncated Tron transaction")
+	}
+	return tx[1+read : 1+read+int(n)], nil
+}
+
+// tronExpiration reads raw_data's expiration field.
+func tronExpiration(raw []byte) (time.Time, error) {
+	for len(raw) > 0 {
+		key, n := binary.Uvarint(raw)
+		if n <= 0 {
+			break
+		}
+		raw = raw[n:]
+		switch key & 7 {
+		case 0:
+			v, n := binary.Uvarint(raw)
+			if n <= 0 {
+				return time.Time{}, errors.New("payout: truncated Tron transaction")
+			}
+			if key>>3 == 8 {
+				return time.UnixMilli(int64(v)), nil
+			}
+			raw = raw[n:]
+		case 2:
+			size, n := binary.Uvarint(raw)
+			if n <= 0 || uint64(len(raw)-n) < size {
+				return time.Time{}, errors.New("payout: truncated Tron transaction")
+			}
+			raw = raw[n+int(size):]
+		default:
+			return time.Time{}, fmt.Errorf("payout: unexpected wire type %d in Tron transaction", key&7)
+		}
+	}
+	return time.Time{}, errors.New("payout: Tron transaction has no expiration")
+}
+
+func protoVarint(b []byte, field int, v uint64) []byte {
+	b = binary.AppendUvarint(b, uint64(field)<<3)
+	return binary.AppendUvarint(b, v)
+}
+
+func protoBytes(b []byte, field int, v []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(field)<<3|2)
+	b = binary.AppendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
@@ -0,0 +1,170 @@
+package secrets
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// KeyFile is a KMS backed by a local JSON file of versioned AES-256 master
+// keys:
+//
+//	{"current": "v2", "keys": {"v1": "<base64>", "v2": "<base64>"}}
+//
+// Old versions stay in the file so values wrapped under them can still be
+// opened until they have been rotated.
+type KeyFile struct {
+	path    string
+	mu      sync.RWMutex
+	current string
+	keys    map[string][]byte
+}
+
+type keyFileJSON struct {
+	Current string            `json:"current"`
+	Keys    map[string]string `json:"keys"`
+}
+
+// OpenKeyFile loads the key file at path.
+func OpenKeyFile(path string) (*KeyFile, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc keyFileJSON
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("secrets: key file %s: %w", path, err)
+	}
+	k := &KeyFile{path: path, current: doc.Current, keys: map[string][]byte{}}
+	for id, encoded := range doc.Keys {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("secrets: key file %s: key %s is not a base64 256-bit key", path, id)
+		}
+		k.keys[id] = key
+	}
+	if _, ok := k.keys[k.current]; !ok {
+		return nil, fmt.Errorf("secrets: key file %s: current key %q missing", path, k.current)
+	}
+	return k, nil
+}
+
+// CreateKeyFile writes a new key file at path holding one key, v1. It
+// fails if the file exists.
+func CreateKeyFile(path string) (*KeyFile, error) {
+	if _, err := os.Stat(path); err == nil {
+		return nil, fmt.Errorf("secrets: key file %s already exists", path)
+	}
+	k := &KeyFile{path: path, keys: map[string][]byte{}}
+	if _, err := k.AddKey(); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// AddKey generates a new master key version, makes it current and saves
+// the file. Existing values keep their old version until rotated.
+func (k *KeyFile) AddKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	next := 0
+	for id := range k.keys {
+		if n, err := strconv.Atoi(strings.TrimPrefix(id, "v")); err == nil && n > next {
+			next = n
+		}
+	}
+	id := "v" + strconv.Itoa(next+1)
+	k.keys[id] = key
+	previous := k.current
+	k.current = id
+	if err := k.save(); err != nil {
+		delete(k.keys, id)
+		k.current = previous
+		return "", err
+	}
+	return id, nil
+}
+
+// save writes the file atomically, readable by its owner only.
+func (k *KeyFile) save() error {
+	doc := keyFileJSON{Current: k.current, Keys: map[string]string{}}
+	for id, key := range k.keys {
+		doc.Keys[id] = base64.StdEncoding.EncodeToString(key)
+	}
+	raw, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(k.path), ".keyfile-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(append(raw, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), k.path)
+}
+
+// CurrentKey implements KMS.
+func (k *KeyFile) CurrentKey() string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.current
+}
+
+// Wrap implements KMS. The key ID is authenticated with the data key.
+func (k *KeyFile) Wrap(_ context.Context, keyID string, dataKey []byte) ([]byte, error) {
+	master, err := k.key(keyID)
+	if err != nil {
+		return nil, err
+	}
+	nonce, ciphertext, err := gcmSeal(master, dataKey, []byte(keyID))
+	if err != nil {
+		return nil, err
+	}
+	return append(nonce, ciphertext...), nil
+}
+
+// Unwrap implements KMS.
+func (k *KeyFile) Unwrap(_ context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	master, err := k.key(keyID)
+	if err != nil {
+		return nil, err
+	}
+	const nonceSize = 12
+	if len(wrapped) < nonceSize {
+		return nil, ErrDecrypt
+	}
+	return gcmOpen(master, wrapped[:nonceSize], wrapped[nonceSize:], []byte(keyID))
+}
+
+func (k *KeyFile) key(id string) ([]byte, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	key, ok := k.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, id)
+	}
+	return key, nil
+}
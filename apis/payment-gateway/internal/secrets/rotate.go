@@ -0,0 +1,83 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// RotateResult counts what Rotate changed.
+type RotateResult struct {
+	// Rewrapped values moved to the current master key.
+	Rewrapped int
+	// Sealed plaintext values left from before encryption.
+	Sealed int
+}
+
+// Rotate brings every value of f under kms's current master key: sealed
+// values are rewrapped without being decrypted, and plaintext leftovers
+// are sealed and removed. With dryRun set it only counts.
+func Rotate(ctx context.Context, db *mongo.Database, f Field, kms KMS, dryRun bool) (RotateResult, error) {
+	var result RotateResult
+	coll := db.Collection(f.Collection)
+	current := kms.CurrentKey()
+	cursor, err := coll.Find(ctx, bson.M{"$or": bson.A{
+		bson.M{f.SealedName() + ".keyId": bson.M{"$exists": true, "$ne": current}},
+		bson.M{f.Name: bson.M{"$exists": true}},
+	}})
+	if err != nil {
+		return result, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var doc struct {
+			ID interface{} `bson:"_id"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return result, err
+		}
+		id := fmt.Sprint(doc.ID)
+		var sealed *Sealed
+		var plaintext bool
+
+		if value, err := cursor.Current.LookupErr(f.Name); err == nil {
+			if raw, ok := value.StringValueOK(); ok && raw != "" {
+				if sealed, err = Seal(ctx, kms, []byte(raw), f.AAD(id)); err != nil {
+					return result, fmt.Errorf("%s %s: %w", f.Collection, id, err)
+				}
+			}
+			plaintext = true
+		} else {
+			var old Sealed
+			if err := cursor.Current.Lookup(f.SealedName()).Unmarshal(&old); err != nil {
+				return result, fmt.Errorf("%s %s: %w", f.Collection, id, err)
+			}
+			if sealed, err = Rewrap(ctx, kms, &old); err != nil {
+				return result, fmt.Errorf("%s %s: %w", f.Collection, id, err)
+			}
+		}
+
+		if plaintext {
+			result.Sealed++
+		} else {
+			result.Rewrapped++
+		}
+		if dryRun {
+			continue
+		}
+		update := bson.M{}
+		if sealed != nil {
+			update["$set"] = bson.M{f.SealedName(): sealed}
+		}
+		if plaintext {
+			update["$unset"] = bson.M{f.Name: ""}
+		}
+		if _, err := coll.UpdateByID(ctx, doc.ID, update); err != nil {
+			return result, fmt.Errorf("%s %s: %w", f.Collection, id, err)
+		}
+	}
+	return result, cursor.Err()
+}
@@ -0,0 +1,167 @@
+// Package secrets seals values stored in Mongo with envelope encryption.
+// Each value is encrypted with its own AES-256-GCM data key, and the data
+// key is wrapped by a master key held in a KMS. The sealed value records
+// which master key version wrapped it, so master keys can be rotated by
+// rewrapping data keys without ever decrypting the values themselves.
+package secrets
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// KMS wraps and unwraps data keys with versioned master keys. KeyFile is
+// the local implementation; a cloud KMS or HSM can stand in for it.
+type KMS interface {
+	// CurrentKey returns the ID of the master key new data keys are
+	// wrapped with.
+	CurrentKey() string
+	// Wrap encrypts dataKey under master key keyID.
+	Wrap(ctx context.Context, keyID string, dataKey []byte) ([]byte, error)
+	// Unwrap decrypts a data key wrapped under keyID.
+	Unwrap(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+}
+
+var (
+	// ErrUnknownKey is returned when a master key version isn't available.
+	ErrUnknownKey = errors.New("secrets: unknown master key")
+	// ErrDecrypt is returned when a sealed value or wrapped key fails
+	// authentication: wrong key, wrong context or tampered data.
+	ErrDecrypt = errors.New("secrets: decryption failed")
+)
+
+// Sealed is an encrypted value as stored in Mongo. It is never
+// serialised to JSON.
+type Sealed struct {
+	KeyID      string `bson:"keyId" json:"-"`
+	WrappedKey []byte `bson:"wrappedKey" json:"-"`
+	Nonce      []byte `bson:"nonce" json:"-"`
+	Ciphertext []byte `bson:"ciphertext" json:"-"`
+}
+
+// String keeps sealed values out of logs.
+func (s Sealed) String() string {
+	return "sealed(" + s.KeyID + ")"
+}
+
+// Seal encrypts plaintext under a fresh data key wrapped with the KMS's
+// current master key. aad binds the value to where it is stored (see
+// Field.AAD), so it can't be copied to another document and opened there.
+func Seal(ctx context.Context, kms KMS, plaintext, aad []byte) (*Sealed, error) {
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+	defer wipe(dataKey)
+
+	nonce, ciphertext, err := gcmSeal(dataKey, plaintext, aad)
+	if err != nil {
+		return nil, err
+	}
+	keyID := kms.CurrentKey()
+	wrapped, err := kms.Wrap(ctx, keyID, dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Sealed{KeyID: keyID, WrappedKey: wrapped, Nonce: nonce, Ciphertext: ciphertext}, nil
+}
+
+// Open decrypts s. The caller should clear the plaintext once used.
+func Open(ctx context.Context, kms KMS, s *Sealed, aad []byte) ([]byte, error) {
+	dataKey, err := kms.Unwrap(ctx, s.KeyID, s.WrappedKey)
+	if err != nil {
+		return nil, err
+	}
+	defer wipe(dataKey)
+	return gcmOpen(dataKey, s.Nonce, s.Ciphertext, aad)
+}
+
+// Rewrap wraps s's data key under the current master key. The value
+// itself is not decrypted.
+func Rewrap(ctx context.Context, kms KMS, s *Sealed) (*Sealed, error) {
+	keyID := kms.CurrentKey()
+	if s.KeyID == keyID {
+		return s, nil
+	}
+	dataKey, err := kms.Unwrap(ctx, s.KeyID, s.WrappedKey)
+	if err != nil {
+		return nil, err
+	}
+	defer wipe(dataKey)
+	wrapped, err := kms.Wrap(ctx, keyID, dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Sealed{KeyID: keyID, WrappedKey: wrapped, Nonce: s.Nonce, Ciphertext: s.Ciphertext}, nil
+}
+
+// Field is a sealed field of a collection. The sealed value is stored
+// under <Name>Sealed; a plaintext value under Name is a leftover from
+// before encryption that Rotate seals.
+type Field struct {
+	Collection string
+	Name       string
+}
+
+// MasterWalletMnemonic is the per-coin master wallet mnemonic.
+var MasterWalletMnemonic = Field{Collection: "crypto_master_wallets", Name: "mnemonic"}
+
+// Fields lists every sealed field, for rotation. Register new stored
+// secrets (e.g. provider keys) here.
+var Fields = []Field{MasterWalletMnemonic}
+
+// SealedName is the document key holding the sealed value.
+func (f Field) SealedName() string {
+	return f.Name + "Sealed"
+}
+
+// AAD is the associated data binding a value to document id.
+func (f Field) AAD(id string) []byte {
+	return []byte(f.Collection + "/" + id + "/" + f.Name)
+}
+
+func gcmSeal(key, plaintext, aad []byte) (nonce, ciphertext []byte, err error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, err
+	}
+	return nonce, aead.Seal(nil, nonce, plaintext, aad), nil
+}
+
+func gcmOpen(key, nonce, ciphertext, aad []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	plaintext, err := aead.Open(nil, nonce, ciphertext, aad)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("secrets: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// wipe zeroes key material once it is no longer needed.
+func wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
@@ -0,0 +1,107 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testKeyFile(t *testing.T) *KeyFile {
+	t.Helper()
+	k, err := CreateKeyFile(filepath.Join(t.TempDir(), "secrets.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func TestSealOpen(t *testing.T) {
+	ctx := context.Background()
+	kms := testKeyFile(t)
+	aad := MasterWalletMnemonic.AAD("BTC")
+
+	sealed, err := Seal(ctx, kms, []byte("correct horse battery staple"), aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sealed.KeyID != "v1" {
+		t.Errorf("KeyID = %q, want v1", sealed.KeyID)
+	}
+	if bytes.Contains(sealed.Ciphertext, []byte("horse")) {
+		t.Error("ciphertext contains the plaintext")
+	}
+	got, err := Open(ctx, kms, sealed, aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "correct horse battery staple" {
+		t.Errorf("Open = %q", got)
+	}
+
+	// Bound to its document: another coin's AAD doesn't open it.
+	if _, err := Open(ctx, kms, sealed, MasterWalletMnemonic.AAD("ETH")); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("wrong AAD: err = %v, want ErrDecrypt", err)
+	}
+	tampered := *sealed
+	tampered.Ciphertext = append([]byte(nil), sealed.Ciphertext...)
+	tampered.Ciphertext[0] ^= 1
+	if _, err := Open(ctx, kms, &tampered, aad); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("tampered: err = %v, want ErrDecrypt", err)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	ctx := context.Background()
+	kms := testKeyFile(t)
+	aad := MasterWalletMnemonic.AAD("USDT")
+	sealed, err := Seal(ctx, kms, []byte("secret"), aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := kms.AddKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "v2" || kms.CurrentKey() != "v2" {
+		t.Fatalf("AddKey = %s, current %s; want v2", id, kms.CurrentKey())
+	}
+	// The file keeps both versions and is private.
+	reloaded, err := OpenKeyFile(kms.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := os.Stat(kms.path); info.Mode().Perm() != 0o600 {
+		t.Errorf("key file mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	rewrapped, err := Rewrap(ctx, reloaded, sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rewrapped.KeyID != "v2" || !bytes.Equal(rewrapped.Ciphertext, sealed.Ciphertext) {
+		t.Errorf("Rewrap changed the ciphertext or kept key %s", rewrapped.KeyID)
+	}
+	for _, s := range []*Sealed{sealed, rewrapped} {
+		got, err := Open(ctx, reloaded, s, aad)
+		if err != nil || string(got) != "secret" {
+			t.Errorf("Open under %s = %q, %v", s.KeyID, got, err)
+		}
+	}
+
+	// Dropping v1 leaves values still under it unreadable.
+	delete(reloaded.keys, "v1")
+	if _, err := Open(ctx, reloaded, sealed, aad); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("err = %v, want ErrUnknownKey", err)
+	}
+}
+
+func TestCreateKeyFileRefusesToOverwrite(t *testing.T) {
+	k := testKeyFile(t)
+	if _, err := CreateKeyFile(k.path); err == nil {
+		t.Fatal("CreateKeyFile overwrote an existing key file")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -53,31 +54,32 @@ type addressResponse struct {
 	Address string `json:"address"`
 }
 
-// GenerateAddress derives a blockchain address for the given coin at derivation
-// index.  For USDT we reuse the ETH xpub (ERC-20 shares the same address space).
-func (c *Client) GenerateAddress(ctx context.Context, coin string, index int64) (string, error) {
-	coin = strings.ToUpper(coin)
+// GenerateAddress derives the deposit address at index on a Tatum chain
+// (bitcoin, ethereum, tron, bsc, polygon, ...). EVM chains share the ETH
+// xpub, so an index gives the same address on each of them.
+func (c *Client) GenerateAddress(ctx context.Context, chain string, index int64) (string, error) {
+	chain = strings.ToLower(chain)
 
 	if c.ApiKey == "" {
-		addr := fmt.Sprintf("sim-%s-%d-%d", strings.ToLower(coin), index, time.Now().UnixMilli())
-		log.Printf("[tatum][sim] GenerateAddress coin=%s index=%d → %s", coin, index, addr)
+		addr := fmt.Sprintf("sim-%s-%d-%d", chain, index, time.Now().UnixMilli())
+		log.Printf("[tatum][sim] GenerateAddress chain=%s index=%d → %s", chain, index, addr)
 		return addr, nil
 	}
 
-	xpub, chainPath := c.chainParams(coin)
+	xpub := c.xpub(chain)
 	if xpub == "" {
-		return "", fmt.Errorf("tatum: no xpub configured for %s", coin)
+		return "", fmt.Errorf("tatum: no xpub configured for %s", chain)
 	}
 
-	endpoint := fmt.Sprintf("%s/v3/%s/address/%s/%d", c.BaseURL, chainPath, xpub, index)
+	endpoint := fmt.Sprintf("%s/v3/%s/address/%s/%d", c.BaseURL, chain, xpub, index)
 	var resp addressResponse
 	if err := c.doGet(ctx, endpoint, &resp); err != nil {
-		return "", fmt.Errorf("tatum GenerateAddress %s: %w", coin, err)
+		return "", fmt.Errorf("tatum GenerateAddress %s: %w", chain, err)
 	}
 	if resp.Address == "" {
-		return "", fmt.Errorf("tatum: empty address in response for %s index %d", coin, index)
+		return "", fmt.Errorf("tatum: empty address in response for %s index %d", chain, index)
 	}
-	log.Printf("[tatum] GenerateAddress coin=%s index=%d → %s", coin, index, resp.Address)
+	log.Printf("[tatum] GenerateAddress chain=%s index=%d → %s", chain, index, resp.Address)
 	return resp.Address, nil
 }
 
@@ -105,23 +107,33 @@ type Token struct {
 	Decimals int    `json:"decimals,omitempty"`
 }
 
-// GetTransactionsByAddress queries the Tatum explorer for recent transactions
-// at the given address for the specified coin.
-func (c *Client) GetTransactionsByAddress(ctx context.Context, coin, address string) ([]Transaction, error) {
-	coin = strings.ToUpper(coin)
+// Asset selects the transfers GetTransactionsByAddress looks up: the
+// chain's native coin, or the token at Contract. Decimals converts token
+// values reported in atomic units when the API omits them.
+type Asset struct {
+	Chain    string
+	Contract string
+	Decimals int
+}
+
+// GetTransactionsByAddress queries the Tatum explorer for recent
+// transactions of asset at the given address. Token transfers carry their
+// contract in Token.Address; on TRON every TRC-20 transfer is returned,
+// whatever its contract.
+func (c *Client) GetTransactionsByAddress(ctx context.Context, asset Asset, address string) ([]Transaction, error) {
+	chain := strings.ToLower(asset.Chain)
 
 	if c.ApiKey == "" {
-		log.Printf("[tatum][sim] GetTransactionsByAddress coin=%s addr=%s → []", coin, address)
+		log.Printf("[tatum][sim] GetTransactionsByAddress chain=%s addr=%s → []", chain, address)
 		return nil, nil
 	}
 
-	_, chainPath := c.chainParams(coin)
 	var endpoint string
 
 	var txs []Transaction
 
-	if chainPath == "tron" {
-		if coin == "USDT" || coin == "USDC" {
+	if chain == "tron" {
+		if asset.Contract != "" {
 			endpoint = fmt.Sprintf("%s/v3/tron/transaction/account/%s/trc20", c.BaseURL, address)
 		} else {
 			endpoint = fmt.Sprintf("%s/v3/tron/transaction/account/%s?pageSize=50", c.BaseURL, address)
@@ -135,21 +147,30 @@ func (c *Client) GetTransactionsByAddress(ctx context.Context, coin, address str
 				To            string `json:"to"`
 				Value         string `json:"value"`
 				Confirmations int    `json:"confirmations,omitempty"` // For TRC20 they might omit it, default to 0
-				TokenInfo     *Token `json:"tokenInfo,omitempty"`
+				TokenInfo     *struct {
+					Symbol   string `json:"symbol"`
+					Address  string `json:"address"`
+					Decimals int    `json:"decimals"`
+				} `json:"tokenInfo,omitempty"`
 			} `json:"transactions"`
 		}
 
 		if err := c.doGet(ctx, endpoint, &tronResp); err != nil {
-			return nil, fmt.Errorf("tatum GetTransactionsByAddress TRON %s/%s: %w", coin, address, err)
+			return nil, fmt.Errorf("tatum GetTransactionsByAddress tron/%s: %w", address, err)
 		}
 
 		txs = make([]Transaction, len(tronResp.Transactions))
 		for i, raw := range tronResp.Transactions {
 			// Convert raw token amount to human-readable float using decimals
 			humanValue := raw.Value
-			if raw.TokenInfo != nil && raw.TokenInfo.Decimals > 0 && raw.Value != "" {
-				if atomicVal, err := strconv.ParseFloat(raw.Value, 64); err == nil {
-					divisor := math.Pow(10, float64(raw.TokenInfo.Decimals))
+			var token *Token
+			if raw.TokenInfo != nil {
+				token = &Token{Symbol: raw.TokenInfo.Symbol, Address: raw.TokenInfo.Address, Decimals: raw.TokenInfo.Decimals}
+				if token.Decimals == 0 {
+					token.Decimals = asset.Decimals
+				}
+				if atomicVal, err := strconv.ParseFloat(raw.Value, 64); err == nil && token.Decimals > 0 {
+					divisor := math.Pow(10, float64(token.Decimals))
 					humanValue = fmt.Sprintf("%f", atomicVal/divisor)
 				}
 			}
@@ -167,26 +188,73 @@ func (c *Client) GetTransactionsByAddress(ctx context.Context, coin, address str
 				To:            raw.To,
 				Value:         humanValue,
 				Confirmations: confs, // Map to what our processCryptoTx expects
-				Token:         raw.TokenInfo,
+				Token:         token,
 			}
 		}
-	} else {
-		switch chainPath {
-		case "bitcoin":
-			endpoint = fmt.Sprintf("%s/v3/bitcoin/transaction/address/%s?pageSize=50", c.BaseURL, address)
-		case "ethereum":
-			endpoint = fmt.Sprintf("%s/v3/ethereum/account/transaction/%s?pageSize=50", c.BaseURL, address)
-		default:
-			return nil, fmt.Errorf("tatum: unsupported chain for tx lookup: %s", coin)
+	} else if chain == "bitcoin" {
+		endpoint = fmt.Sprintf("%s/v3/bitcoin/transaction/address/%s?pageSize=50", c.BaseURL, address)
+		if err := c.doGet(ctx, endpoint, &txs); err != nil {
+			return nil, fmt.Errorf("tatum GetTransactionsByAddress bitcoin/%s: %w", address, err)
 		}
+	} else if asset.Contract == "" {
+		// EVM native transfers
+		endpoint = fmt.Sprintf("%s/v3/%s/account/transaction/%s?pageSize=50", c.BaseURL, chain, address)
 		if err := c.doGet(ctx, endpoint, &txs); err != nil {
-			return nil, fmt.Errorf("tatum GetTransactionsByAddress %s/%s: %w", coin, address, err)
+			return nil, fmt.Errorf("tatum GetTransactionsByAddress %s/%s: %w", chain, address, err)
 		}
+	} else {
+		return c.evmTokenTransfers(ctx, chain, asset.Contract, address)
 	}
 
 	return txs, nil
 }
 
+// evmTokenTransfers lists incoming transfers of an ERC-20 style token
+// through the v4 data API, which reports amounts in whole tokens.
+func (c *Client) evmTokenTransfers(ctx context.Context, chain, contract, address string) ([]Transaction, error) {
+	var resp struct {
+		Result []struct {
+			Hash               string `json:"hash"`
+			Address            string `json:"address"`
+			CounterAddress     string `json:"counterAddress"`
+			Amount             string `json:"amount"`
+			TokenAddress       string `json:"tokenAddress"`
+			BlockNumber        int64  `json:"blockNumber"`
+			TransactionSubtype string `json:"transactionSubtype"`
+		} `json:"result"`
+	}
+	endpoint := fmt.Sprintf("%s/v4/data/transactions?chain=%s&addresses=%s&tokenAddress=%s&transactionTypes=fungible&pageSize=50",
+		c.BaseURL, c.dataChain(chain), address, contract)
+	if err := c.doGet(ctx, endpoint, &resp); err != nil {
+		return nil, fmt.Errorf("tatum GetTransactionsByAddress %s/%s: %w", chain, address, err)
+	}
+	head, err := c.CurrentBlock(ctx, chain)
+	if err != nil {
+		return nil, err
+	}
+
+	txs := make([]Transaction, 0, len(resp.Result))
+	for _, raw := range resp.Result {
+		if raw.TransactionSubtype != "incoming" {
+			continue
+		}
+		confs := 0
+		if raw.BlockNumber > 0 && head >= raw.BlockNumber {
+			confs = int(head-raw.BlockNumber) + 1
+		}
+		txs = append(txs, Transaction{
+			Hash:          raw.Hash,
+			From:          raw.CounterAddress,
+			To:            raw.Address,
+			Amount:        strings.TrimPrefix(raw.Amount, "-"),
+			Confirmations: confs,
+			BlockNumber:   raw.BlockNumber,
+			Token:         &Token{Address: raw.TokenAddress},
+		})
+	}
+	return txs, nil
+}
+
 // ---------------------------------------------------------------------------
 // Balance check
 // ---------------------------------------------------------------------------
@@ -199,19 +267,18 @@ type BalanceResponse struct {
 }
 
 // GetBalance retrieves the on-chain balance for the given address.
-func (c *Client) GetBalance(ctx context.Context, coin, address string) (*BalanceResponse, error) {
-	coin = strings.ToUpper(coin)
+func (c *Client) GetBalance(ctx context.Context, chain, address string) (*BalanceResponse, error) {
+	chain = strings.ToLower(chain)
 
 	if c.ApiKey == "" {
-		log.Printf("[tatum][sim] GetBalance coin=%s addr=%s → 0", coin, address)
+		log.Printf("[tatum][sim] GetBalance chain=%s addr=%s → 0", chain, address)
 		return &BalanceResponse{Balance: "0"}, nil
 	}
 
-	_, chainPath := c.chainParams(coin)
-	endpoint := fmt.Sprintf("%s/v3/%s/account/balance/%s", c.BaseURL, chainPath, address)
+	endpoint := fmt.Sprintf("%s/v3/%s/account/balance/%s", c.BaseURL, chain, address)
 	var resp BalanceResponse
 	if err := c.doGet(ctx, endpoint, &resp); err != nil {
-		return nil, fmt.Errorf("tatum GetBalance %s/%s: %w", coin, address, err)
+		return nil, fmt.Errorf("tatum GetBalance %s/%s: %w", chain, address, err)
 	}
 	return &resp, nil
 }
@@ -232,51 +299,290 @@ type SubscriptionResponse struct {
 }
 
 // CreateAddressSubscription registers a Tatum webhook that fires when
-// any transaction hits the given address.
-func (c *Client) CreateAddressSubscription(ctx context.Context, coin, address, webhookURL string) (string, error) {
-	coin = strings.ToUpper(coin)
+// any transaction hits the given address on chain.
+func (c *Client) CreateAddressSubscription(ctx context.Context, chain, address, webhookURL string) (string, error) {
+	chain = strings.ToLower(chain)
 
 	if c.ApiKey == "" {
-		subID := fmt.Sprintf("sim-sub-%s-%s", strings.ToLower(coin), address[:8])
-		log.Printf("[tatum][sim] CreateAddressSubscription coin=%s addr=%s → %s", coin, address, subID)
+		subID := fmt.Sprintf("sim-sub-%s-%s", chain, address[:8])
+		log.Printf("[tatum][sim] CreateAddressSubscription chain=%s addr=%s → %s", chain, address, subID)
 		return subID, nil
 	}
 
-	_, chainPath := c.chainParams(coin)
 	payload := SubscriptionRequest{
 		Type: "ADDRESS_TRANSACTION",
 		Attr: map[string]interface{}{
 			"address": address,
-			"chain":   strings.ToUpper(chainPath),
+			"chain":   strings.ToUpper(chain),
 			"url":     webhookURL,
 		},
 	}
 
 	var resp SubscriptionResponse
 	if err := c.doPost(ctx, c.BaseURL+"/v3/subscription", payload, &resp); err != nil {
-		return "", fmt.Errorf("tatum CreateAddressSubscription %s/%s: %w", coin, address, err)
+		return "", fmt.Errorf("tatum CreateAddressSubscription %s/%s: %w", chain, address, err)
 	}
-	log.Printf("[tatum] CreateAddressSubscription coin=%s addr=%s → sub=%s", coin, address, resp.ID)
+	log.Printf("[tatum] CreateAddressSubscription chain=%s addr=%s → sub=%s", chain, address, resp.ID)
 	return resp.ID, nil
 }
 
+// ---------------------------------------------------------------------------
+// Payouts (chain state for building transactions, broadcast, status)
+// ---------------------------------------------------------------------------
+
+// UTXO is an unspent Bitcoin output from GET /v3/data/utxos.
+type UTXO struct {
+	TxHash string  `json:"txHash"`
+	Index  uint32  `json:"index"`
+	Value  float64 `json:"value"` // BTC
+}
+
+// TxInfo is a transaction's on-chain state.
+type TxInfo struct {
+	Found       bool
+	BlockNumber int64
+	// Failed is set for mined transactions that reverted.
+	Failed bool
+}
+
+// EthereumNonce returns the next nonce for address.
+func (c *Client) EthereumNonce(ctx context.Context, address string) (uint64, error) {
+	var nonce uint64
+	if err := c.doGet(ctx, fmt.Sprintf("%s/v3/ethereum/transaction/count/%s", c.BaseURL, address), &nonce); err != nil {
+		return 0, fmt.Errorf("tatum EthereumNonce %s: %w", address, err)
+	}
+	return nonce, nil
+}
+
+// EthereumGasPriceGwei returns the current medium gas price estimate.
+func (c *Client) EthereumGasPriceGwei(ctx context.Context) (float64, error) {
+	var resp struct {
+		Medium json.Number `json:"medium"`
+	}
+	if err := c.doGet(ctx, c.BaseURL+"/v3/blockchain/fee/ETH", &resp); err != nil {
+		return 0, fmt.Errorf("tatum EthereumGasPrice: %w", err)
+	}
+	return resp.Medium.Float64()
+}
+
+// TronLatestBlock returns the latest Tron block number and hash.
+func (c *Client) TronLatestBlock(ctx context.Context) (int64, string, error) {
+	var resp struct {
+		BlockNumber int64  `json:"blockNumber"`
+		Hash        string `json:"hash"`
+	}
+	if err := c.doGet(ctx, c.BaseURL+"/v3/tron/info", &resp); err != nil {
+		return 0, "", fmt.Errorf("tatum TronLatestBlock: %w", err)
+	}
+	return resp.BlockNumber, resp.Hash, nil
+}
+
+// BitcoinUTXOs lists unspent outputs of address worth at least totalBTC.
+func (c *Client) BitcoinUTXOs(ctx context.Context, address string, totalBTC float64) ([]UTXO, error) {
+	chain := "bitcoin"
+	if c.Testnet {
+		chain = "bitcoin-testnet"
+	}
+	endpoint := fmt.Sprintf("%s/v3/data/utxos?chain=%s&address=%s&totalValue=%s",
+		c.BaseURL, chain, address, strconv.FormatFloat(totalBTC, 'f', 8, 64))
+	var utxos []UTXO
+	if err := c.doGet(ctx, endpoint, &utxos); err != nil {
+		return nil, fmt.Errorf("tatum BitcoinUTXOs %s: %w", address, err)
+	}
+	return utxos, nil
+}
+
+// EthereumBalance returns address's ether balance as a decimal string.
+func (c *Client) EthereumBalance(ctx context.Context, address string) (string, error) {
+	var resp struct {
+		Balance string `json:"balance"`
+	}
+	if err := c.doGet(ctx, fmt.Sprintf("%s/v3/ethereum/account/balance/%s", c.BaseURL, address), &resp); err != nil {
+		return "", fmt.Errorf("tatum EthereumBalance %s: %w", address, err)
+	}
+	return resp.Balance, nil
+}
+
+// EthereumTokenBalance returns address's balance of an ERC-20 token in
+// the token's smallest unit.
+func (c *Client) EthereumTokenBalance(ctx context.Context, contract, address string) (string, error) {
+	var resp struct {
+		Balance string `json:"balance"`
+	}
+	endpoint := fmt.Sprintf("%s/v3/blockchain/token/balance/ETH/%s/%s", c.BaseURL, contract, address)
+	if err := c.doGet(ctx, endpoint, &resp); err != nil {
+		return "", fmt.Errorf("tatum EthereumTokenBalance %s: %w", address, err)
+	}
+	return resp.Balance, nil
+}
+
+// TronAccount is a Tron account's balances: TRX in sun and TRC-20 tokens
+// in their smallest unit, keyed by contract address.
+type TronAccount struct {
+	Balance int64
+	TRC20   map[string]string
+}
+
+// TronAccount looks up a Tron account. Addresses that have never
+// received TRX are not activated and report zero balances.
+func (c *Client) TronAccount(ctx context.Context, address string) (TronAccount, error) {
+	var resp struct {
+		Balance int64               `json:"balance"`
+		TRC20   []map[string]string `json:"trc20"`
+	}
+	err := c.doGet(ctx, fmt.Sprintf("%s/v3/tron/account/%s", c.BaseURL, address), &resp)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		return TronAccount{TRC20: map[string]string{}}, nil
+	}
+	if err != nil {
+		return TronAccount{}, fmt.Errorf("tatum TronAccount %s: %w", address, err)
+	}
+	account := TronAccount{Balance: resp.Balance, TRC20: map[string]string{}}
+	for _, tokens := range resp.TRC20 {
+		for contract, balance := range tokens {
+			account.TRC20[contract] = balance
+		}
+	}
+	return account, nil
+}
+
+// BitcoinBalance returns address's confirmed balance in BTC as a
+// decimal string.
+func (c *Client) BitcoinBalance(ctx context.Context, address string) (string, error) {
+	var resp struct {
+		Incoming json.Number `json:"incoming"`
+		Outgoing json.Number `json:"outgoing"`
+	}
+	if err := c.doGet(ctx, fmt.Sprintf("%s/v3/bitcoin/address/balance/%s", c.BaseURL, address), &resp); err != nil {
+		return "", fmt.Errorf("tatum BitcoinBalance %s: %w", address, err)
+	}
+	in, _ := resp.Incoming.Float64()
+	out, _ := resp.Outgoing.Float64()
+	return strconv.FormatFloat(in-out, 'f', 8, 64), nil
+}
+
+// Broadcast submits a signed transaction on chain ("bitcoin",
+// "ethereum" or "tron") and returns its ID. Tron takes the serialized
+// protobuf through the node gateway's broadcasthex.
+func (c *Client) Broadcast(ctx context.Context, chain, txData string) (string, error) {
+	if chain == "tron" {
+		node := "tron-mainnet"
+		if c.Testnet {
+			node = "tron-testnet"
+		}
+		var resp struct {
+			Result  bool   `json:"result"`
+			TxID    string `json:"txid"`
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		}
+		endpoint := fmt.Sprintf("%s/v3/blockchain/node/%s/wallet/broadcasthex", c.BaseURL, node)
+		if err := c.doPost(ctx, endpoint, map[string]string{"transaction": txData}, &resp); err != nil {
+			return "", fmt.Errorf("tatum Broadcast tron: %w", err)
+		}
+		// A rebroadcast of an accepted transaction reports DUP_TRANSACTION_ERROR.
+		if !resp.Result && resp.Code != "DUP_TRANSACTION_ERROR" {
+			return "", fmt.Errorf("tatum Broadcast tron: %s %s", resp.Code, resp.Message)
+		}
+		return resp.TxID, nil
+	}
+	var resp struct {
+		TxID string `json:"txId"`
+	}
+	if err := c.doPost(ctx, fmt.Sprintf("%s/v3/%s/broadcast", c.BaseURL, chain), map[string]string{"txData": txData}, &resp); err != nil {
+		return "", fmt.Errorf("tatum Broadcast %s: %w", chain, err)
+	}
+	return resp.TxID, nil
+}
+
+// TransactionInfo looks up a transaction by hash on chain.
+func (c *Client) TransactionInfo(ctx context.Context, chain, hash string) (TxInfo, error) {
+	var resp struct {
+		BlockNumber *int64 `json:"blockNumber"`
+		Status      *bool  `json:"status"` // ethereum receipt status
+		Ret         []struct {
+			ContractRet string `json:"contractRet"`
+		} `json:"ret"` // tron
+	}
+	err := c.doGet(ctx, fmt.Sprintf("%s/v3/%s/transaction/%s", c.BaseURL, chain, hash), &resp)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		return TxInfo{}, nil
+	}
+	if err != nil {
+		return TxInfo{}, fmt.Errorf("tatum TransactionInfo %s/%s: %w", chain, hash, err)
+	}
+	info := TxInfo{Found: true}
+	if resp.BlockNumber != nil {
+		info.BlockNumber = *resp.BlockNumber
+	}
+	if resp.Status != nil && !*resp.Status {
+		info.Failed = true
+	}
+	if len(resp.Ret) > 0 && resp.Ret[0].ContractRet != "" && resp.Ret[0].ContractRet != "SUCCESS" {
+		info.Failed = true
+	}
+	return info, nil
+}
+
+// CurrentBlock returns the chain's latest block number. Chains other
+// than tron and bitcoin are taken to be EVM.
+func (c *Client) CurrentBlock(ctx context.Context, chain string) (int64, error) {
+	switch chain {
+	case "tron":
+		n, _, err := c.TronLatestBlock(ctx)
+		return n, err
+	case "bitcoin":
+		var resp struct {
+			Blocks int64 `json:"blocks"`
+		}
+		if err := c.doGet(ctx, c.BaseURL+"/v3/bitcoin/info", &resp); err != nil {
+			return 0, fmt.Errorf("tatum CurrentBlock bitcoin: %w", err)
+		}
+		return resp.Blocks, nil
+	}
+	var n int64
+	if err := c.doGet(ctx, fmt.Sprintf("%s/v3/%s/block/current", c.BaseURL, chain), &n); err != nil {
+		return 0, fmt.Errorf("tatum CurrentBlock %s: %w", chain, err)
+	}
+	return n, nil
+}
+
 // ---------------------------------------------------------------------------
 // Internal helpers
 // ---------------------------------------------------------------------------
 
-// chainParams returns the xpub and Tatum REST path segment for a coin.
-func (c *Client) chainParams(coin string) (xpub string, chainPath string) {
-	switch strings.ToUpper(coin) {
-	case "BTC":
-		return c.BTCXpub, "bitcoin"
-	case "ETH":
-		return c.ETHXpub, "ethereum"
-	case "USDT":
-		// USDT as TRC-20 on the Tron network (low fees, fast confirmations)
-		return c.TRONXpub, "tron"
+// xpub returns the master xpub addresses on chain derive from: bitcoin
+// and tron have their own, every other chain is EVM.
+func (c *Client) xpub(chain string) string {
+	switch chain {
+	case "bitcoin":
+		return c.BTCXpub
+	case "tron":
+		return c.TRONXpub
 	default:
-		return "", ""
+		return c.ETHXpub
+	}
+}
+
+// dataChains maps chain paths to the v4 data API's mainnet and testnet
+// names.
+var dataChains = map[string][2]string{
+	"ethereum": {"ethereum-mainnet", "ethereum-sepolia"},
+	"bsc":      {"bsc-mainnet", "bsc-testnet"},
+	"polygon":  {"polygon-mainnet", "polygon-amoy"},
+}
+
+func (c *Client) dataChain(chain string) string {
+	names, ok := dataChains[chain]
+	if !ok {
+		names = [2]string{chain + "-mainnet", chain + "-testnet"}
 	}
+	if c.Testnet {
+		return names[1]
+	}
+	return names[0]
 }
 
 func (c *Client) doGet(ctx context.Context, url string, out interface{}) error {
@@ -319,7 +625,7 @@ func (c *Client) execute(req *http.Request, out interface{}) error {
 	}
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return fmt.Errorf("tatum HTTP %d: %s", resp.StatusCode, string(respBody))
+		return &APIError{StatusCode: resp.StatusCode, Body: string(respBody)}
 	}
 
 	if out != nil {
@@ -327,3 +633,13 @@ func (c *Client) execute(req *http.Request, out interface{}) error {
 	}
 	return nil
 }
+
+// APIError is a non-2xx response from Tatum.
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("tatum HTTP %d: %s", e.StatusCode, e.Body)
+}
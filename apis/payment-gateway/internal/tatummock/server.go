@@ -0,0 +1,705 @@
+// Package tatummock is a local stand-in for the Tatum v3 API. It serves the
+// address-derivation, transaction-listing, transaction lookup, chain head,
+// balance and subscription endpoints used by tatum.Client, keeps a
+// scripted ledger of incoming transactions, and sends webhooks signed with
+// X-Tatum-Signature to subscribed URLs as blocks are mined.
+package tatummock
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config controls authentication, signing and block production.
+type Config struct {
+	// APIKey is the accepted x-api-key. Empty accepts any non-empty key.
+	APIKey string
+	// WebhookSecret signs webhook bodies (HMAC-SHA256, hex) in
+	// X-Tatum-Signature. Empty sends unsigned webhooks.
+	WebhookSecret string
+	// BlockInterval mines a block this often, adding a confirmation to every
+	// transaction below TargetConfirmations. 0 mines only on MineBlocks.
+	BlockInterval time.Duration
+	// TargetConfirmations stops confirmation webhooks for a transaction once
+	// reached. Defaults to 12.
+	TargetConfirmations int
+	// Latency is added before every response.
+	Latency time.Duration
+	// TRC20Contract is reported as the contract of TRON deposits. Defaults
+	// to mainnet USDT.
+	TRC20Contract string
+	// BlockWebhookURL receives {"chain","blockNumber"} for every chain
+	// each time a block is mined. Empty sends none.
+	BlockWebhookURL string
+}
+
+// Tx is an incoming transaction to a derived address.
+type Tx struct {
+	Hash          string  `json:"hash"`
+	Coin          string  `json:"coin"`
+	Chain         string  `json:"chain"`
+	From          string  `json:"from"`
+	To            string  `json:"to"`
+	Amount        float64 `json:"amount"`
+	AmountUsd     float64 `json:"amountUsd"`
+	Confirmations int     `json:"confirmations"`
+	BlockNumber   int64   `json:"blockNumber"`
+}
+
+// Subscription is a registered ADDRESS_TRANSACTION webhook.
+type Subscription struct {
+	ID      string `json:"id"`
+	Address string `json:"address"`
+	Chain   string `json:"chain"`
+	URL     string `json:"url"`
+}
+
+// Route names used by FailNext and Requests.
+const (
+	RouteAddress      = "address"
+	RouteTransactions = "transactions"
+	RouteBalance      = "balance"
+	RouteSubscription = "subscription"
+	RouteTransaction  = "transaction"
+	RouteBlock        = "block"
+)
+
+// chains are the chains the mock mines; they share one block height.
+var chains = []string{"bitcoin", "ethereum", "tron"}
+
+// tokenDecimals is used to report TRC-20 values in atomic units, as Tatum
+// does.
+const tokenDecimals = 6
+
+type fault struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
+
+// Server implements http.Handler. /v3/ paths speak the Tatum API; POST
+// /_mock/ paths add deposits, mine blocks and script faults.
+type Server struct {
+	cfg    Config
+	client *http.Client
+
+	mu            sync.Mutex
+	block         int64
+	nextID        int64
+	txs           map[string][]*Tx
+	subscriptions map[string]Subscription
+	faults        map[string][]fault
+	requests      map[string][]string
+	stop          chan struct{}
+}
+
+// New creates a Server and starts block production when BlockInterval is
+// set. Close stops it.
+func New(cfg Config) *Server {
+	if cfg.TargetConfirmations <= 0 {
+		cfg.TargetConfirmations = 12
+	}
+	if cfg.TRC20Contract == "" {
+		cfg.TRC20Contract = "TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6t"
+	}
+	s := &Server{
+		cfg:           cfg,
+		client:        &http.Client{Timeout: 10 * time.Second},
+		block:         1_000_000,
+		txs:           make(map[string][]*Tx),
+		subscriptions: make(map[string]Subscription),
+		faults:        make(map[string][]fault),
+		requests:      make(map[string][]string),
+		stop:          make(chan struct{}),
+	}
+	if cfg.BlockInterval > 0 {
+		go s.mine()
+	}
+	return s
+}
+
+// Close stops block production.
+func (s *Server) Close() {
+	select {
+	case <-s.stop:
+	default:
+		close(s.stop)
+	}
+}
+
+// FailNext makes the next request to route answer with HTTP status.
+func (s *Server) FailNext(route string, status int, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults[route] = append(s.faults[route], fault{Status: status, Message: message})
+}
+
+// Requests returns the request paths received on route, oldest first.
+func (s *Server) Requests(route string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]string, len(s.requests[route]))
+	copy(out, s.requests[route])
+	return out
+}
+
+// Subscriptions returns the registered webhooks.
+func (s *Server) Subscriptions() []Subscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Subscription, 0, len(s.subscriptions))
+	for _, sub := range s.subscriptions {
+		out = append(out, sub)
+	}
+	return out
+}
+
+// Deposit records an incoming transaction of amount coin to address with
+// the given confirmations and notifies any subscription. amountUsd defaults
+// to amount. The transaction is placed in the block that gives it those
+// confirmations, or the next block when it has none.
+func (s *Server) Deposit(coin, address string, amount, amountUsd float64, confirmations int) (Tx, error) {
+	coin = strings.ToUpper(coin)
+	chain := chainFor(coin)
+	if chain == "" {
+		return Tx{}, fmt.Errorf("tatummock: unsupported coin %q", coin)
+	}
+	if amountUsd <= 0 {
+		amountUsd = amount
+	}
+	s.mu.Lock()
+	s.nextID++
+	tx := &Tx{
+		Hash:          txHash(chain, address, s.nextID),
+		Coin:          coin,
+		Chain:         chain,
+		From:          deriveAddress(chain, "faucet", s.nextID),
+		To:            address,
+		Amount:        amount,
+		AmountUsd:     amountUsd,
+		Confirmations: confirmations,
+		BlockNumber:   s.block + 1 - int64(confirmations),
+	}
+	s.txs[address] = append(s.txs[address], tx)
+	snapshot := *tx
+	s.mu.Unlock()
+
+	s.notify(snapshot)
+	return snapshot, nil
+}
+
+// Reorg drops the transaction with hash from the chain, as a
+// reorganization would, reporting whether it existed. Afterwards it is
+// neither listed nor found by hash.
+func (s *Server) Reorg(hash string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for address, list := range s.txs {
+		for i, tx := range list {
+			if tx.Hash == hash {
+				s.txs[address] = append(list[:i:i], list[i+1:]...)
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// MineBlocks adds n confirmations to every transaction below
+// TargetConfirmations and sends a webhook for each change, plus a block
+// notification per chain when BlockWebhookURL is set.
+func (s *Server) MineBlocks(n int) {
+	for i := 0; i < n; i++ {
+		var changed []Tx
+		s.mu.Lock()
+		s.block++
+		block := s.block
+		for _, list := range s.txs {
+			for _, tx := range list {
+				if tx.Confirmations < s.cfg.TargetConfirmations {
+					tx.Confirmations++
+					changed = append(changed, *tx)
+				}
+			}
+		}
+		s.mu.Unlock()
+		for _, tx := range changed {
+			s.notify(tx)
+		}
+		if s.cfg.BlockWebhookURL != "" {
+			for _, chain := range chains {
+				s.post(s.cfg.BlockWebhookURL, "block "+chain, map[string]interface{}{
+					"chain":       strings.ToUpper(chain),
+					"blockNumber": block,
+				})
+			}
+		}
+	}
+}
+
+func (s *Server) mine() {
+	ticker := time.NewTicker(s.cfg.BlockInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.MineBlocks(1)
+		}
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, "/_mock/") {
+		s.serveAdmin(w, r)
+		return
+	}
+	key := r.Header.Get("x-api-key")
+	if key == "" || (s.cfg.APIKey != "" && key != s.cfg.APIKey) {
+		writeError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) < 2 || parts[0] != "v3" {
+		writeError(w, http.StatusNotFound, "Not found")
+		return
+	}
+	parts = parts[1:]
+
+	route := ""
+	switch {
+	case r.Method == http.MethodPost && len(parts) == 1 && parts[0] == "subscription":
+		route = RouteSubscription
+	case r.Method == http.MethodGet && len(parts) == 4 && parts[1] == "address":
+		route = RouteAddress
+	case r.Method == http.MethodGet && len(parts) == 4 && parts[1] == "account" && parts[2] == "balance":
+		route = RouteBalance
+	case r.Method == http.MethodGet && transactionAddress(parts) != "":
+		route = RouteTransactions
+	case r.Method == http.MethodGet && len(parts) == 3 && parts[1] == "transaction":
+		route = RouteTransaction
+	case r.Method == http.MethodGet && isHeadPath(parts):
+		route = RouteBlock
+	default:
+		writeError(w, http.StatusNotFound, "Not found")
+		return
+	}
+
+	f, latency := s.record(route, r.URL.RequestURI())
+	if latency > 0 {
+		time.Sleep(latency)
+	}
+	if f != nil {
+		writeError(w, f.Status, f.Message)
+		return
+	}
+
+	switch route {
+	case RouteSubscription:
+		s.handleSubscription(w, r)
+	case RouteAddress:
+		index, err := strconv.ParseInt(parts[3], 10, 64)
+		if err != nil || index < 0 {
+			writeError(w, http.StatusBadRequest, "index must be a non-negative integer")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"address": deriveAddress(parts[0], parts[2], index)})
+	case RouteBalance:
+		s.handleBalance(w, parts[3])
+	case RouteTransactions:
+		s.handleTransactions(w, parts)
+	case RouteTransaction:
+		s.handleTransaction(w, parts[0], parts[2])
+	case RouteBlock:
+		s.handleHead(w, parts[0])
+	}
+}
+
+// handleTransaction answers a lookup by hash in the shape of each chain's
+// Tatum response; blockNumber is null until the transaction is mined.
+func (s *Server) handleTransaction(w http.ResponseWriter, chain, hash string) {
+	s.mu.Lock()
+	var found *Tx
+	for _, list := range s.txs {
+		for _, tx := range list {
+			if tx.Hash == hash && tx.Chain == chain {
+				c := *tx
+				found = &c
+			}
+		}
+	}
+	s.mu.Unlock()
+	if found == nil {
+		writeError(w, http.StatusNotFound, "Transaction not found")
+		return
+	}
+	out := map[string]interface{}{"hash": found.Hash, "blockNumber": nil}
+	if found.Confirmations > 0 {
+		out["blockNumber"] = found.BlockNumber
+	}
+	switch chain {
+	case "ethereum":
+		out["status"] = true
+	case "tron":
+		out["ret"] = []map[string]string{{"contractRet": "SUCCESS"}}
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// handleHead reports the current block in the shape of each chain's
+// Tatum endpoint.
+func (s *Server) handleHead(w http.ResponseWriter, chain string) {
+	s.mu.Lock()
+	block := s.block
+	s.mu.Unlock()
+	switch chain {
+	case "bitcoin":
+		writeJSON(w, http.StatusOK, map[string]interface{}{"chain": "main", "blocks": block})
+	case "tron":
+		writeJSON(w, http.StatusOK, map[string]interface{}{"blockNumber": block, "hash": fmt.Sprintf("%064x", block)})
+	default:
+		writeJSON(w, http.StatusOK, block)
+	}
+}
+
+func (s *Server) handleSubscription(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Type string `json:"type"`
+		Attr struct {
+			Address string `json:"address"`
+			Chain   string `json:"chain"`
+			URL     string `json:"url"`
+		} `json:"attr"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+	if req.Type != "ADDRESS_TRANSACTION" || req.Attr.Address == "" || req.Attr.URL == "" {
+		writeError(w, http.StatusBadRequest, "type ADDRESS_TRANSACTION with attr.address and attr.url is required")
+		return
+	}
+	s.mu.Lock()
+	for _, sub := range s.subscriptions {
+		if sub.Address == req.Attr.Address {
+			s.mu.Unlock()
+			writeError(w, http.StatusBadRequest, "Subscription for this address already exists")
+			return
+		}
+	}
+	s.nextID++
+	sub := Subscription{
+		ID:      fmt.Sprintf("%024x", s.nextID),
+		Address: req.Attr.Address,
+		Chain:   strings.ToUpper(req.Attr.Chain),
+		URL:     req.Attr.URL,
+	}
+	s.subscriptions[sub.ID] = sub
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]string{"id": sub.ID})
+}
+
+func (s *Server) handleBalance(w http.ResponseWriter, address string) {
+	s.mu.Lock()
+	total := 0.0
+	for _, tx := range s.txs[address] {
+		total += tx.Amount
+	}
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]string{
+		"balance":  strconv.FormatFloat(total, 'f', -1, 64),
+		"incoming": strconv.FormatFloat(total, 'f', -1, 64),
+		"outgoing": "0",
+	})
+}
+
+func (s *Server) handleTransactions(w http.ResponseWriter, parts []string) {
+	chain := parts[0]
+	address := transactionAddress(parts)
+	s.mu.Lock()
+	var txs []Tx
+	for _, tx := range s.txs[address] {
+		if tx.Chain == chain {
+			txs = append(txs, *tx)
+		}
+	}
+	s.mu.Unlock()
+
+	if chain != "tron" {
+		out := make([]map[string]interface{}, 0, len(txs))
+		for _, tx := range txs {
+			item := map[string]interface{}{
+				"hash":          tx.Hash,
+				"from":          tx.From,
+				"to":            tx.To,
+				"confirmations": tx.Confirmations,
+				"blockNumber":   tx.BlockNumber,
+			}
+			if chain == "ethereum" {
+				item["value"] = formatAmount(tx.Amount)
+			} else {
+				item["amount"] = formatAmount(tx.Amount)
+			}
+			out = append(out, item)
+		}
+		writeJSON(w, http.StatusOK, out)
+		return
+	}
+
+	// TRON wraps transactions in an object and reports TRC-20 values in
+	// atomic units.
+	out := make([]map[string]interface{}, 0, len(txs))
+	for _, tx := range txs {
+		out = append(out, map[string]interface{}{
+			"txID":          tx.Hash,
+			"from":          tx.From,
+			"to":            tx.To,
+			"value":         strconv.FormatFloat(math.Round(tx.Amount*math.Pow10(tokenDecimals)), 'f', 0, 64),
+			"confirmations": tx.Confirmations,
+			"tokenInfo":     map[string]interface{}{"symbol": tx.Coin, "address": s.cfg.TRC20Contract, "decimals": tokenDecimals},
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"transactions": out})
+}
+
+func (s *Server) serveAdmin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	switch r.URL.Path {
+	case "/_mock/deposits":
+		var req struct {
+			Coin          string  `json:"coin"`
+			Address       string  `json:"address"`
+			Amount        float64 `json:"amount"`
+			AmountUsd     float64 `json:"amountUsd"`
+			Confirmations int     `json:"confirmations"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Address == "" || req.Amount <= 0 {
+			http.Error(w, "coin, address and amount are required", http.StatusBadRequest)
+			return
+		}
+		tx, err := s.Deposit(req.Coin, req.Address, req.Amount, req.AmountUsd, req.Confirmations)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, http.StatusOK, tx)
+	case "/_mock/blocks":
+		var req struct {
+			Count int `json:"count"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Count <= 0 {
+			req.Count = 1
+		}
+		s.MineBlocks(req.Count)
+		w.WriteHeader(http.StatusNoContent)
+	case "/_mock/faults":
+		var req struct {
+			Route   string `json:"route"`
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Route == "" || req.Code == 0 {
+			http.Error(w, "route and code are required", http.StatusBadRequest)
+			return
+		}
+		s.FailNext(req.Route, req.Code, req.Message)
+		w.WriteHeader(http.StatusNoContent)
+	case "/_mock/reorg":
+		var req struct {
+			Hash string `json:"hash"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Hash == "" {
+			http.Error(w, "hash is required", http.StatusBadRequest)
+			return
+		}
+		if !s.Reorg(req.Hash) {
+			http.Error(w, "unknown transaction", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// notify sends the transaction to the subscription watching its address.
+// The payload carries Tatum's ADDRESS_TRANSACTION fields plus the coin,
+// amountUsd and confirmations payment-gateway reads.
+func (s *Server) notify(tx Tx) {
+	s.mu.Lock()
+	var sub *Subscription
+	for _, candidate := range s.subscriptions {
+		if candidate.Address == tx.To {
+			c := candidate
+			sub = &c
+			break
+		}
+	}
+	s.mu.Unlock()
+	if sub == nil {
+		return
+	}
+
+	// Like Tatum, token transfers name their contract as the asset.
+	asset := tx.Coin
+	if tx.Chain == "tron" {
+		asset = s.cfg.TRC20Contract
+	}
+	s.post(sub.URL, tx.Hash, map[string]interface{}{
+		"subscriptionType": "ADDRESS_TRANSACTION",
+		"txId":             tx.Hash,
+		"address":          tx.To,
+		"counterAddress":   tx.From,
+		"chain":            strings.ToUpper(tx.Chain),
+		"asset":            asset,
+		"coin":             tx.Coin,
+		"amount":           tx.Amount,
+		"amountUsd":        tx.AmountUsd,
+		"blockNumber":      tx.BlockNumber,
+		"confirmations":    tx.Confirmations,
+	})
+}
+
+// post sends a webhook, signed when WebhookSecret is set. what names it
+// in logs.
+func (s *Server) post(url, what string, body map[string]interface{}) {
+	payload, _ := json.Marshal(body)
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		log.Printf("tatummock: webhook %s: %v", what, err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.cfg.WebhookSecret != "" {
+		req.Header.Set("X-Tatum-Signature", Sign(payload, s.cfg.WebhookSecret))
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		log.Printf("tatummock: webhook %s: %v", what, err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Printf("tatummock: webhook %s answered %d", what, resp.StatusCode)
+	}
+}
+
+// Sign returns the X-Tatum-Signature value for body.
+func Sign(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *Server) record(route, uri string) (*fault, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests[route] = append(s.requests[route], uri)
+	queue := s.faults[route]
+	if len(queue) == 0 {
+		return nil, s.cfg.Latency
+	}
+	f := queue[0]
+	s.faults[route] = queue[1:]
+	return &f, s.cfg.Latency
+}
+
+// transactionAddress extracts the address from the transaction-listing
+// paths tatum.Client uses, or returns "".
+func transactionAddress(parts []string) string {
+	switch {
+	case len(parts) == 4 && parts[0] == "bitcoin" && parts[1] == "transaction" && parts[2] == "address":
+		return parts[3]
+	case len(parts) == 4 && parts[0] == "ethereum" && parts[1] == "account" && parts[2] == "transaction":
+		return parts[3]
+	case len(parts) >= 4 && parts[0] == "tron" && parts[1] == "transaction" && parts[2] == "account":
+		if len(parts) == 4 || (len(parts) == 5 && parts[4] == "trc20") {
+			return parts[3]
+		}
+	}
+	return ""
+}
+
+// isHeadPath reports whether parts is a chain head path tatum.Client
+// uses: /v3/bitcoin/info, /v3/tron/info or /v3/ethereum/block/current.
+func isHeadPath(parts []string) bool {
+	switch {
+	case len(parts) == 2 && (parts[0] == "bitcoin" || parts[0] == "tron") && parts[1] == "info":
+		return true
+	case len(parts) == 3 && parts[0] == "ethereum" && parts[1] == "block" && parts[2] == "current":
+		return true
+	}
+	return false
+}
+
+func chainFor(coin string) string {
+	switch coin {
+	case "BTC":
+		return "bitcoin"
+	case "ETH":
+		return "ethereum"
+	case "USDT":
+		return "tron"
+	}
+	return ""
+}
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// deriveAddress returns a stable, chain-shaped fake address for xpub/index.
+func deriveAddress(chain, xpub string, index int64) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s/%s/%d", chain, xpub, index)))
+	digest := hex.EncodeToString(sum[:])
+	switch chain {
+	case "ethereum":
+		return "0x" + digest[:40]
+	case "tron":
+		var sb strings.Builder
+		sb.WriteByte('T')
+		for i := 0; i < 33; i++ {
+			b := sum[i%len(sum)] + byte(i)
+			sb.WriteByte(base58Alphabet[int(b)%len(base58Alphabet)])
+		}
+		return sb.String()
+	default:
+		return "bc1q" + digest[:38]
+	}
+}
+
+func txHash(chain, address string, n int64) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("tx/%s/%s/%d", chain, address, n)))
+	if chain == "ethereum" {
+		return "0x" + hex.EncodeToString(sum[:])
+	}
+	return hex.EncodeToString(sum[:])
+}
+
+func formatAmount(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]interface{}{"statusCode": status, "errorCode": "mock.error", "message": message})
+}
@@ -0,0 +1,200 @@
+package tatummock_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"gamehub/payment-gateway/internal/config"
+	"gamehub/payment-gateway/internal/middleware"
+	"gamehub/payment-gateway/internal/tatum"
+	"gamehub/payment-gateway/internal/tatummock"
+)
+
+type webhook struct {
+	TxID          string  `json:"txId"`
+	Address       string  `json:"address"`
+	Coin          string  `json:"coin"`
+	Amount        float64 `json:"amount"`
+	Confirmations int     `json:"confirmations"`
+}
+
+// receiver serves payment-gateway's crypto webhook route behind the real
+// X-Tatum-Signature middleware and forwards accepted events.
+func receiver(t *testing.T, secret string) (string, <-chan webhook) {
+	t.Helper()
+	events := make(chan webhook, 16)
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Post("/webhooks/payment/crypto", middleware.VerifyTatumHMAC(&config.Config{TatumWebhookSecret: secret}), func(c *fiber.Ctx) error {
+		var evt webhook
+		if err := json.Unmarshal(c.Body(), &evt); err != nil {
+			return c.SendStatus(http.StatusBadRequest)
+		}
+		events <- evt
+		return c.SendStatus(http.StatusOK)
+	})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go app.Listener(ln)
+	t.Cleanup(func() { app.Shutdown() })
+	return "http://" + ln.Addr().String() + "/webhooks/payment/crypto", events
+}
+
+func TestGenerateAddressIsStablePerChain(t *testing.T) {
+	_, url := tatummock.NewTestServer(t, tatummock.Config{})
+	client := tatum.NewClient("key", url, "xpub-btc", "xpub-eth", "xpub-tron", false)
+	ctx := context.Background()
+
+	prefixes := map[string]string{"bitcoin": "bc1q", "ethereum": "0x", "tron": "T"}
+	for chain, prefix := range prefixes {
+		first, err := client.GenerateAddress(ctx, chain, 7)
+		if err != nil {
+			t.Fatalf("%s: %v", chain, err)
+		}
+		again, _ := client.GenerateAddress(ctx, chain, 7)
+		next, _ := client.GenerateAddress(ctx, chain, 8)
+		if !strings.HasPrefix(first, prefix) || first != again || first == next {
+			t.Fatalf("%s: unexpected addresses %q %q %q", chain, first, again, next)
+		}
+	}
+}
+
+func TestDepositWebhooksFollowConfirmations(t *testing.T) {
+	hook, events := receiver(t, "tatum-secret")
+	mock, url := tatummock.NewTestServer(t, tatummock.Config{WebhookSecret: "tatum-secret", TargetConfirmations: 2})
+	client := tatum.NewClient("key", url, "", "", "xpub-tron", false)
+	ctx := context.Background()
+
+	address, err := client.GenerateAddress(ctx, "tron", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.CreateAddressSubscription(ctx, "tron", address, hook); err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := mock.Deposit("USDT", address, 25, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock.MineBlocks(3)
+	for want := 0; want <= 2; want++ {
+		evt := <-events
+		if evt.TxID != tx.Hash || evt.Address != address || evt.Coin != "USDT" || evt.Amount != 25 || evt.Confirmations != want {
+			t.Fatalf("webhook %d: %+v", want, evt)
+		}
+	}
+	select {
+	case evt := <-events:
+		t.Fatalf("no webhook expected past TargetConfirmations, got %+v", evt)
+	default:
+	}
+
+	usdt := tatum.Asset{Chain: "tron", Contract: "TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6t", Decimals: 6}
+	txs, err := client.GetTransactionsByAddress(ctx, usdt, address)
+	if err != nil || len(txs) != 1 {
+		t.Fatalf("transactions: %+v %v", txs, err)
+	}
+	if txs[0].Hash != tx.Hash || txs[0].Value != "25.000000" || txs[0].Confirmations != 2 || txs[0].Token.Address != usdt.Contract {
+		t.Fatalf("unexpected tron tx %+v", txs[0])
+	}
+}
+
+func TestUnsignedWebhookIsRejected(t *testing.T) {
+	hook, events := receiver(t, "tatum-secret")
+	mock, url := tatummock.NewTestServer(t, tatummock.Config{})
+	client := tatum.NewClient("key", url, "xpub-btc", "", "", false)
+	ctx := context.Background()
+
+	address, _ := client.GenerateAddress(ctx, "bitcoin", 0)
+	if _, err := client.CreateAddressSubscription(ctx, "bitcoin", address, hook); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mock.Deposit("BTC", address, 0.01, 600, 3); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case evt := <-events:
+		t.Fatalf("unsigned webhook passed verification: %+v", evt)
+	default:
+	}
+
+	txs, err := client.GetTransactionsByAddress(ctx, tatum.Asset{Chain: "bitcoin"}, address)
+	if err != nil || len(txs) != 1 || txs[0].Amount != "0.01" || txs[0].Confirmations != 3 {
+		t.Fatalf("bitcoin transactions: %+v %v", txs, err)
+	}
+}
+
+func TestAPIKeyAndFaults(t *testing.T) {
+	mock, url := tatummock.NewTestServer(t, tatummock.Config{APIKey: "right"})
+	ctx := context.Background()
+	if _, err := tatum.NewClient("wrong", url, "xpub", "", "", false).GenerateAddress(ctx, "bitcoin", 0); err == nil {
+		t.Fatal("wrong API key must be rejected")
+	}
+	client := tatum.NewClient("right", url, "xpub", "", "", false)
+	mock.FailNext(tatummock.RouteBalance, http.StatusTooManyRequests, "rate limited")
+	if _, err := client.GetBalance(ctx, "bitcoin", "bc1qexample"); err == nil || !strings.Contains(err.Error(), "429") {
+		t.Fatalf("expected scripted 429, got %v", err)
+	}
+	if bal, err := client.GetBalance(ctx, "bitcoin", "bc1qexample"); err != nil || bal.Balance != "0" {
+		t.Fatalf("balance: %+v %v", bal, err)
+	}
+}
+
+func TestReorgAndChainHead(t *testing.T) {
+	blocks := make(chan map[string]interface{}, 8)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		blocks <- body
+	}))
+	defer hook.Close()
+	mock, url := tatummock.NewTestServer(t, tatummock.Config{BlockWebhookURL: hook.URL})
+	client := tatum.NewClient("key", url, "", "xpub-eth", "", false)
+	ctx := context.Background()
+
+	address, _ := client.GenerateAddress(ctx, "ethereum", 3)
+	tx, err := mock.Deposit("ETH", address, 0.5, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	head, err := client.CurrentBlock(ctx, "ethereum")
+	if err != nil || head != tx.BlockNumber+1 {
+		t.Fatalf("head %d for tx in block %d: %v", head, tx.BlockNumber, err)
+	}
+	for _, chain := range []string{"bitcoin", "tron"} {
+		if n, err := client.CurrentBlock(ctx, chain); err != nil || n != head {
+			t.Fatalf("%s head %d: %v", chain, n, err)
+		}
+	}
+	info, err := client.TransactionInfo(ctx, "ethereum", tx.Hash)
+	if err != nil || !info.Found || info.Failed || info.BlockNumber != tx.BlockNumber {
+		t.Fatalf("transaction info %+v: %v", info, err)
+	}
+
+	mock.MineBlocks(1)
+	if len(blocks) != 3 {
+		t.Fatalf("expected a block notification per chain, got %d", len(blocks))
+	}
+	if b := <-blocks; b["blockNumber"] != float64(head+1) {
+		t.Fatalf("block notification %v", b)
+	}
+
+	if !mock.Reorg(tx.Hash) || mock.Reorg(tx.Hash) {
+		t.Fatal("reorg must drop the transaction once")
+	}
+	if info, err := client.TransactionInfo(ctx, "ethereum", tx.Hash); err != nil || info.Found {
+		t.Fatalf("reorged transaction still found: %+v %v", info, err)
+	}
+	if txs, err := client.GetTransactionsByAddress(ctx, tatum.Asset{Chain: "ethereum"}, address); err != nil || len(txs) != 0 {
+		t.Fatalf("reorged transaction still listed: %+v %v", txs, err)
+	}
+}
@@ -0,0 +1,19 @@
+package tatummock
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+// NewTestServer starts a mock on a random local port for the duration of the
+// test and returns it together with its base URL.
+func NewTestServer(tb testing.TB, cfg Config) (*Server, string) {
+	tb.Helper()
+	srv := New(cfg)
+	ts := httptest.NewServer(srv)
+	tb.Cleanup(func() {
+		srv.Close()
+		ts.Close()
+	})
+	return srv, ts.URL
+}
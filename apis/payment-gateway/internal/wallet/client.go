@@ -39,6 +39,13 @@ type CreditRequest struct {
 	Reference string  `json:"reference"`
 }
 
+// ReversalRequest takes back the deposit credit made under Reference.
+type ReversalRequest struct {
+	UserID    string `json:"userId"`
+	Reference string `json:"reference"`
+	Reason    string `json:"reason"`
+}
+
 type ReservationRequest struct {
 	UserID       string  `json:"userId"`
 	WithdrawalID string  `json:"withdrawalId"`
@@ -66,6 +73,10 @@ func (c *HTTPClient) CreditDeposit(ctx context.Context, req CreditRequest) error
 	return c.post(ctx, "/internal/ledger/credit", req, nil)
 }
 
+func (c *HTTPClient) ReverseCredit(ctx context.Context, req ReversalRequest) error {
+	return c.post(ctx, "/internal/ledger/reverse-credit", req, nil)
+}
+
 func (c *HTTPClient) ReserveWithdrawal(ctx context.Context, req ReservationRequest) error {
 	return c.post(ctx, "/internal/ledger/reserve-withdrawal", req, nil)
 }
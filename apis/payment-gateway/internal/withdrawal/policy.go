@@ -0,0 +1,44 @@
+package withdrawal
+
+import "time"
+
+// Policy decides how much review a withdrawal needs.
+type Policy struct {
+	// AutoApproveMax approves withdrawals at or below this amount without
+	// review. 0 disables.
+	AutoApproveMax float64
+	// AutoApproveKYCLevel approves withdrawals from users at or above this
+	// KYC level without review. 0 disables.
+	AutoApproveKYCLevel int
+	// DualApprovalAbove requires two distinct approvers for amounts above
+	// it, and is never auto-approved. 0 disables.
+	DualApprovalAbove float64
+	// SLA is how long a withdrawal may wait for review before it is
+	// cancelled and the funds are returned. 0 disables.
+	SLA time.Duration
+}
+
+// ApprovalsRequired returns how many distinct reviewers must approve.
+func (p Policy) ApprovalsRequired(amount float64) int {
+	if p.DualApprovalAbove > 0 && amount > p.DualApprovalAbove {
+		return 2
+	}
+	return 1
+}
+
+// AutoApprove reports whether a withdrawal skips manual review.
+func (p Policy) AutoApprove(amount float64, kycLevel int) bool {
+	if p.ApprovalsRequired(amount) > 1 {
+		return false
+	}
+	if p.AutoApproveMax > 0 && amount <= p.AutoApproveMax {
+		return true
+	}
+	return p.AutoApproveKYCLevel > 0 && kycLevel >= p.AutoApproveKYCLevel
+}
+
+// Expired reports whether a withdrawal created at createdAt has outlived
+// the SLA.
+func (p Policy) Expired(createdAt, now time.Time) bool {
+	return p.SLA > 0 && now.Sub(createdAt) > p.SLA
+}
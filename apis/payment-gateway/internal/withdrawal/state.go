@@ -0,0 +1,57 @@
+// Package withdrawal defines the withdrawal lifecycle: which status changes
+// are legal, and the review policy deciding when a withdrawal is approved
+// automatically and when it needs two approvers.
+package withdrawal
+
+// Withdrawal statuses.
+const (
+	Pending               = "PENDING"
+	PendingSecondApproval = "PENDING_SECOND_APPROVAL"
+	Approved              = "APPROVED"
+	Processing            = "PROCESSING"
+	Completed             = "COMPLETED"
+	Failed                = "FAILED"
+	Rejected              = "REJECTED"
+	Cancelled             = "CANCELLED"
+)
+
+// transitions lists the statuses each status may move to. PROCESSING can
+// fall back to APPROVED only once it is known no transfer went out: the
+// provider refused it, or has no record of it. Until then it stays
+// PROCESSING, where it can't be rejected or cancelled.
+var transitions = map[string][]string{
+	Pending:               {PendingSecondApproval, Approved, Processing, Rejected, Cancelled},
+	PendingSecondApproval: {Approved, Processing, Rejected, Cancelled},
+	Approved:              {Processing, Completed, Rejected, Cancelled},
+	Processing:            {Approved, Completed, Failed},
+}
+
+// CanTransition reports whether a withdrawal may move from one status to
+// another.
+func CanTransition(from, to string) bool {
+	for _, next := range transitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsTerminal reports whether status is final; the wallet reservation has
+// been settled by then.
+func IsTerminal(status string) bool {
+	_, open := transitions[status]
+	return !open
+}
+
+// AwaitingReview lists the statuses a reviewer still has to act on.
+var AwaitingReview = []string{Pending, PendingSecondApproval}
+
+// Open lists the statuses still holding reserved funds.
+var Open = []string{Pending, PendingSecondApproval, Approved, Processing}
+
+// Stuck lists the statuses the SLA sweeper may cancel: those still
+// awaiting review. An APPROVED withdrawal may already have been paid by
+// hand, and a PROCESSING one has a transfer in flight, so cancelling
+// either could pay the user twice.
+var Stuck = AwaitingReview
@@ -0,0 +1,82 @@
+package withdrawal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCanTransition(t *testing.T) {
+	legal := [][2]string{
+		{Pending, Approved},
+		{Pending, PendingSecondApproval},
+		{PendingSecondApproval, Processing},
+		{Approved, Completed},
+		{Processing, Approved},
+		{Processing, Failed},
+	}
+	for _, tc := range legal {
+		if !CanTransition(tc[0], tc[1]) {
+			t.Errorf("%s -> %s should be legal", tc[0], tc[1])
+		}
+	}
+	illegal := [][2]string{
+		{Pending, Completed},
+		{Processing, Rejected},
+		{Processing, Cancelled},
+		{Completed, Failed},
+		{Rejected, Approved},
+		{"", Pending},
+	}
+	for _, tc := range illegal {
+		if CanTransition(tc[0], tc[1]) {
+			t.Errorf("%s -> %s should be illegal", tc[0], tc[1])
+		}
+	}
+	if !IsTerminal(Cancelled) || IsTerminal(Approved) {
+		t.Fatal("unexpected terminal statuses")
+	}
+	for _, status := range Open {
+		if IsTerminal(status) {
+			t.Errorf("%s is open but terminal", status)
+		}
+	}
+	for _, status := range Stuck {
+		if status == Approved || status == Processing {
+			t.Errorf("the SLA sweeper must not cancel %s withdrawals", status)
+		}
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	p := Policy{AutoApproveMax: 20, AutoApproveKYCLevel: 2, DualApprovalAbove: 500, SLA: time.Hour}
+	cases := []struct {
+		amount    float64
+		level     int
+		auto      bool
+		approvals int
+	}{
+		{20, 0, true, 1},
+		{21, 1, false, 1},
+		{300, 2, true, 1},
+		{501, 2, false, 2},
+	}
+	for _, tc := range cases {
+		if got := p.AutoApprove(tc.amount, tc.level); got != tc.auto {
+			t.Errorf("AutoApprove(%v, %d) = %t", tc.amount, tc.level, got)
+		}
+		if got := p.ApprovalsRequired(tc.amount); got != tc.approvals {
+			t.Errorf("ApprovalsRequired(%v) = %d", tc.amount, got)
+		}
+	}
+	if (Policy{}).AutoApprove(1, 5) {
+		t.Fatal("zero policy must not auto-approve")
+	}
+
+	now := time.Now()
+	if !p.Expired(now.Add(-2*time.Hour), now) || p.Expired(now.Add(-time.Minute), now) {
+		t.Fatal("unexpected SLA expiry")
+	}
+	if (Policy{}).Expired(now.Add(-1000*time.Hour), now) {
+		t.Fatal("zero SLA must never expire")
+	}
+}
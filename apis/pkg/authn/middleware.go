@@ -0,0 +1,137 @@
+package authn
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
+)
+
+// Fiber locals set by RequireAuth and UpgradeWS.
+const (
+	LocalUserID = "userId"
+	LocalRole   = "role"
+	LocalClaims = "claims"
+)
+
+// RequireAuth validates incoming JWT access tokens and exposes the claims on the Fiber context.
+func RequireAuth(validator *Validator) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		claims, err := validator.FromHeader(c.Get("Authorization"))
+		if err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+		}
+		setClaims(c, claims)
+		return c.Next()
+	}
+}
+
+// UpgradeWS authenticates a WebSocket upgrade from the ?token= query
+// parameter (browsers cannot set headers on upgrades) or the Authorization header.
+func UpgradeWS(validator *Validator) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !websocket.IsWebSocketUpgrade(c) {
+			return fiber.ErrUpgradeRequired
+		}
+		var (
+			claims *Claims
+			err    error
+		)
+		token := strings.TrimSpace(c.Query("token"))
+		if token != "" {
+			claims, err = validator.FromString(token)
+		} else {
+			claims, err = validator.FromHeader(c.Get("Authorization"))
+		}
+		if err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "missing or invalid token"})
+		}
+		setClaims(c, claims)
+		return c.Next()
+	}
+}
+
+// RequireRole allows the request when the caller holds one of roles. It must
+// run after RequireAuth.
+func RequireRole(roles ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		claims := ClaimsFrom(c)
+		if claims == nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+		}
+		for _, role := range roles {
+			if claims.HasRole(role) {
+				return c.Next()
+			}
+		}
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "forbidden"})
+	}
+}
+
+// RequireScope allows the request when the token grants every scope. It must
+// run after RequireAuth.
+func RequireScope(scopes ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		claims := ClaimsFrom(c)
+		if claims == nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+		}
+		for _, scope := range scopes {
+			if !claims.HasScope(scope) {
+				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "forbidden", "missingScope": scope})
+			}
+		}
+		return c.Next()
+	}
+}
+
+// RequireElevated allows the request only with a step-up token from
+// auth-service's /2fa/step-up. It must run after RequireAuth.
+func RequireElevated() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		claims := ClaimsFrom(c)
+		if claims == nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+		}
+		if !claims.Elevated {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "two-factor step-up required",
+				"code":  "STEP_UP_REQUIRED",
+			})
+		}
+		return c.Next()
+	}
+}
+
+// RequireInternalKey guards service-to-service routes with the shared
+// X-Internal-Key header.
+func RequireInternalKey(key string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !VerifyInternalKey(c.Get("X-Internal-Key"), key) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "forbidden"})
+		}
+		return c.Next()
+	}
+}
+
+// VerifyInternalKey compares keys in constant time. An unconfigured key
+// never matches.
+func VerifyInternalKey(provided, expected string) bool {
+	if expected == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(provided), []byte(expected)) == 1
+}
+
+// ClaimsFrom returns the claims stored by RequireAuth or UpgradeWS, or nil.
+func ClaimsFrom(c *fiber.Ctx) *Claims {
+	claims, _ := c.Locals(LocalClaims).(*Claims)
+	return claims
+}
+
+func setClaims(c *fiber.Ctx, claims *Claims) {
+	c.Locals(LocalUserID, claims.UserID)
+	c.Locals(LocalRole, claims.Role)
+	c.Locals(LocalClaims, claims)
+}
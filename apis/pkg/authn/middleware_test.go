@@ -0,0 +1,87 @@
+package authn
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func withClaims(claims *Claims) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if claims != nil {
+			setClaims(c, claims)
+		}
+		return c.Next()
+	}
+}
+
+func ok(c *fiber.Ctx) error {
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func status(t *testing.T, app *fiber.App, path string, headers map[string]string) int {
+	t.Helper()
+	req := httptest.NewRequest("GET", path, nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("request %s: %v", path, err)
+	}
+	return resp.StatusCode
+}
+
+func TestRequireRoleAndScope(t *testing.T) {
+	support := &Claims{UserID: "u1", Role: "user", Roles: []string{"support"}, Scopes: []string{"users:read"}}
+
+	app := fiber.New()
+	app.Get("/anon", withClaims(nil), RequireRole("admin"), ok)
+	app.Get("/admin", withClaims(support), RequireRole("admin"), ok)
+	app.Get("/staff", withClaims(support), RequireRole("admin", "support"), ok)
+	app.Get("/read", withClaims(support), RequireScope("users:read"), ok)
+	app.Get("/write", withClaims(support), RequireScope("users:read", "users:write"), ok)
+
+	cases := map[string]int{
+		"/anon":  fiber.StatusUnauthorized,
+		"/admin": fiber.StatusForbidden,
+		"/staff": fiber.StatusNoContent,
+		"/read":  fiber.StatusNoContent,
+		"/write": fiber.StatusForbidden,
+	}
+	for path, want := range cases {
+		if got := status(t, app, path, nil); got != want {
+			t.Errorf("%s: expected %d, got %d", path, want, got)
+		}
+	}
+}
+
+func TestRequireInternalKey(t *testing.T) {
+	app := fiber.New()
+	app.Get("/internal", RequireInternalKey("secret"), ok)
+	app.Get("/unset", RequireInternalKey(""), ok)
+
+	if got := status(t, app, "/internal", map[string]string{"X-Internal-Key": "secret"}); got != fiber.StatusNoContent {
+		t.Fatalf("expected 204 with key, got %d", got)
+	}
+	if got := status(t, app, "/internal", map[string]string{"X-Internal-Key": "wrong"}); got != fiber.StatusForbidden {
+		t.Fatalf("expected 403 with wrong key, got %d", got)
+	}
+	if got := status(t, app, "/unset", nil); got != fiber.StatusForbidden {
+		t.Fatalf("expected 403 when no key is configured, got %d", got)
+	}
+}
+
+func TestRequireElevated(t *testing.T) {
+	app := fiber.New()
+	app.Get("/plain", withClaims(&Claims{UserID: "u1", Role: "user"}), RequireElevated(), ok)
+	app.Get("/elevated", withClaims(&Claims{UserID: "u1", Role: "user", Elevated: true}), RequireElevated(), ok)
+
+	if got := status(t, app, "/plain", nil); got != fiber.StatusForbidden {
+		t.Fatalf("expected 403 without step-up, got %d", got)
+	}
+	if got := status(t, app, "/elevated", nil); got != fiber.StatusNoContent {
+		t.Fatalf("expected 204 with step-up, got %d", got)
+	}
+}
@@ -0,0 +1,90 @@
+// Package rbac defines the Glory Grid roles and the permission scopes each
+// one grants. auth-service embeds the scopes in access tokens; services check
+// them with authn.RequireScope.
+package rbac
+
+import "sort"
+
+// Base roles every account has exactly one of.
+const (
+	RoleGuest = "guest"
+	RoleUser  = "user"
+)
+
+// Staff roles are granted on top of the base role and stored on the user
+// document by auth-service.
+const (
+	RoleAdmin   = "admin"
+	RoleSupport = "support"
+	RoleFinance = "finance"
+)
+
+// Permission scopes.
+const (
+	ScopeUsersRead         = "users:read"
+	ScopeUsersWrite        = "users:write"
+	ScopeRolesManage       = "roles:manage"
+	ScopeAuthLocks         = "auth:locks"
+	ScopeWalletRead        = "wallet:read"
+	ScopePaymentsRead      = "payments:read"
+	ScopeWithdrawalsReview = "withdrawals:review"
+	ScopeGamesRead         = "games:read"
+	ScopeKYCReview         = "kyc:review"
+)
+
+// StaffRoles lists the roles that may be granted through the admin API.
+var StaffRoles = []string{RoleAdmin, RoleSupport, RoleFinance}
+
+var roleScopes = map[string][]string{
+	RoleSupport: {
+		ScopeUsersRead,
+		ScopeAuthLocks,
+		ScopeWalletRead,
+		ScopePaymentsRead,
+		ScopeGamesRead,
+		ScopeKYCReview,
+	},
+	RoleFinance: {
+		ScopeWalletRead,
+		ScopePaymentsRead,
+		ScopeWithdrawalsReview,
+	},
+	RoleAdmin: {
+		ScopeUsersRead,
+		ScopeUsersWrite,
+		ScopeRolesManage,
+		ScopeAuthLocks,
+		ScopeWalletRead,
+		ScopePaymentsRead,
+		ScopeWithdrawalsReview,
+		ScopeGamesRead,
+		ScopeKYCReview,
+	},
+}
+
+// IsStaffRole reports whether role can be granted through the admin API.
+func IsStaffRole(role string) bool {
+	for _, staff := range StaffRoles {
+		if role == staff {
+			return true
+		}
+	}
+	return false
+}
+
+// ScopesFor returns the sorted union of scopes granted by roles. Unknown
+// roles grant nothing.
+func ScopesFor(roles ...string) []string {
+	seen := make(map[string]struct{})
+	for _, role := range roles {
+		for _, scope := range roleScopes[role] {
+			seen[scope] = struct{}{}
+		}
+	}
+	scopes := make([]string, 0, len(seen))
+	for scope := range seen {
+		scopes = append(scopes, scope)
+	}
+	sort.Strings(scopes)
+	return scopes
+}
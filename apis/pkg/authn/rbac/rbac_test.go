@@ -0,0 +1,28 @@
+package rbac
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestScopesFor(t *testing.T) {
+	if got := ScopesFor(RoleUser, RoleGuest); len(got) != 0 {
+		t.Fatalf("base roles should grant no scopes, got %v", got)
+	}
+	got := ScopesFor(RoleFinance, RoleSupport)
+	want := []string{ScopeAuthLocks, ScopeGamesRead, ScopeKYCReview, ScopePaymentsRead, ScopeUsersRead, ScopeWalletRead, ScopeWithdrawalsReview}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected scopes:\n got %v\nwant %v", got, want)
+	}
+	for _, scope := range ScopesFor(RoleSupport) {
+		if scope == ScopeRolesManage || scope == ScopeWithdrawalsReview {
+			t.Fatalf("support must not hold %s", scope)
+		}
+	}
+}
+
+func TestIsStaffRole(t *testing.T) {
+	if !IsStaffRole(RoleFinance) || IsStaffRole(RoleUser) || IsStaffRole("root") {
+		t.Fatal("unexpected staff role classification")
+	}
+}
@@ -0,0 +1,339 @@
+// Package authn verifies Glory Grid access tokens and provides the Fiber
+// middleware shared by every service: bearer and WebSocket auth, role and
+// scope checks, and internal-key verification.
+package authn
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/sync/singleflight"
+)
+
+// ErrMissingToken signals that no bearer token was supplied.
+var ErrMissingToken = errors.New("missing bearer token")
+
+// Claims captures the minimal fields we expect on Glory Grid access tokens.
+type Claims struct {
+	UserID string   `json:"uid"`
+	Role   string   `json:"role"`
+	Roles  []string `json:"roles,omitempty"` // staff roles granted on top of Role
+	Scopes []string `json:"scopes,omitempty"`
+	// Elevated is set on short-lived tokens minted by a 2FA step-up.
+	Elevated bool `json:"elevated,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// HasRole reports whether role is the base role or one of the staff roles.
+func (c *Claims) HasRole(role string) bool {
+	if c.Role == role {
+		return true
+	}
+	for _, granted := range c.Roles {
+		if granted == role {
+			return true
+		}
+	}
+	return false
+}
+
+// HasScope reports whether the token grants scope.
+func (c *Claims) HasScope(scope string) bool {
+	for _, granted := range c.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// jwksCacheTTL bounds how long fetched keys are trusted; jwksMinRefresh
+// throttles refetches triggered by unknown kids.
+const (
+	jwksCacheTTL   = 10 * time.Minute
+	jwksMinRefresh = 30 * time.Second
+)
+
+// Validator verifies RS256 JWTs emitted by the auth service. Keys come from
+// the auth service's JWKS when a URL is configured, with the static public key
+// as a fallback for tokens without a kid or when the JWKS is unreachable.
+type Validator struct {
+	staticKey  *rsa.PublicKey
+	issuer     string
+	jwksURL    string
+	httpClient *http.Client
+
+	// fetches collapses concurrent refreshes into one request, made
+	// without holding mu so lookups of cached keys never wait on it.
+	fetches singleflight.Group
+
+	mu          sync.Mutex
+	keys        map[string]*rsa.PublicKey
+	fetchedAt   time.Time
+	lastAttempt time.Time
+}
+
+// NewValidator loads the RSA public key from disk and, when jwksURL is set,
+// fetches rotating keys from it on demand. Either source alone is enough.
+func NewValidator(publicKeyPath, jwksURL, issuer string) (*Validator, error) {
+	v := &Validator{
+		issuer:     issuer,
+		jwksURL:    strings.TrimSpace(jwksURL),
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+		keys:       make(map[string]*rsa.PublicKey),
+	}
+	if v.jwksURL != "" && !staticKeyConfigured(publicKeyPath) {
+		return v, nil
+	}
+	data, err := loadPublicKeyPEM(publicKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("read public key: %w", err)
+	}
+	key, err := jwt.ParseRSAPublicKeyFromPEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse public key: %w", err)
+	}
+	v.staticKey = key
+	return v, nil
+}
+
+// Parse validates a raw JWT string and returns the embedded claims.
+func (v *Validator) Parse(token string) (*Claims, error) {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return nil, ErrMissingToken
+	}
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}),
+	}
+	if v.issuer != "" {
+		opts = append(opts, jwt.WithIssuer(v.issuer))
+	}
+	parsed, err := jwt.ParseWithClaims(token, &Claims{}, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return v.keyFor(kid)
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	claims, ok := parsed.Claims.(*Claims)
+	if !ok || !parsed.Valid {
+		return nil, errors.New("invalid token claims")
+	}
+	if claims.UserID == "" {
+		claims.UserID = claims.Subject
+	}
+	if claims.UserID == "" {
+		return nil, errors.New("user id missing in token")
+	}
+	return claims, nil
+}
+
+// keyFor resolves the verification key for kid, refetching the JWKS when the
+// cache is stale or the kid is unknown (a freshly rotated key).
+func (v *Validator) keyFor(kid string) (*rsa.PublicKey, error) {
+	if kid != "" && v.jwksURL != "" {
+		key, ok, refresh := v.cachedKey(kid)
+		if refresh {
+			v.fetches.Do("jwks", func() (interface{}, error) {
+				v.refresh()
+				return nil, nil
+			})
+			key, ok, _ = v.cachedKey(kid)
+		}
+		if ok {
+			return key, nil
+		}
+	}
+	if v.staticKey != nil {
+		return v.staticKey, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// cachedKey looks kid up and reports whether the JWKS should be refetched:
+// kid is unknown or the keys are stale, and no fetch was tried within
+// jwksMinRefresh.
+func (v *Validator) cachedKey(kid string) (key *rsa.PublicKey, ok, refresh bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	key, ok = v.keys[kid]
+	stale := time.Since(v.fetchedAt) > jwksCacheTTL
+	return key, ok, (!ok || stale) && time.Since(v.lastAttempt) >= jwksMinRefresh
+}
+
+// refresh refetches the JWKS and swaps in its keys. The cached keys are
+// kept when the fetch fails or yields none, so an auth-service outage or a
+// bad deploy doesn't lock everyone out.
+func (v *Validator) refresh() {
+	v.mu.Lock()
+	if time.Since(v.lastAttempt) < jwksMinRefresh {
+		// A fetch finished while this caller was queued behind it.
+		v.mu.Unlock()
+		return
+	}
+	v.lastAttempt = time.Now()
+	v.mu.Unlock()
+
+	keys, err := v.fetchKeys()
+	if err == nil && len(keys) == 0 {
+		err = errors.New("no usable RSA keys")
+	}
+	if err != nil {
+		log.Printf("⚠️  JWKS refresh from %s failed, keeping cached keys: %v", v.jwksURL, err)
+		return
+	}
+	v.mu.Lock()
+	v.keys = keys
+	v.fetchedAt = time.Now()
+	v.mu.Unlock()
+}
+
+func (v *Validator) fetchKeys() (map[string]*rsa.PublicKey, error) {
+	req, err := http.NewRequest(http.MethodGet, v.jwksURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := v.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Kty != "RSA" || jwk.Kid == "" {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(jwk.N)
+		e, errE := base64.RawURLEncoding.DecodeString(jwk.E)
+		if errN != nil || errE != nil {
+			continue
+		}
+		keys[jwk.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	return keys, nil
+}
+
+// FromHeader extracts the bearer token from an Authorization header and validates it.
+func (v *Validator) FromHeader(header string) (*Claims, error) {
+	token := extractToken(header)
+	if token == "" {
+		return nil, ErrMissingToken
+	}
+	return v.Parse(token)
+}
+
+// FromString validates a token string directly (useful for query params).
+func (v *Validator) FromString(token string) (*Claims, error) {
+	return v.Parse(token)
+}
+
+func extractToken(header string) string {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return ""
+	}
+	if strings.HasPrefix(header, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+	}
+	return header
+}
+
+func staticKeyConfigured(path string) bool {
+	return strings.TrimSpace(os.Getenv("JWT_PUBLIC_KEY_PEM")) != "" || strings.TrimSpace(path) != ""
+}
+
+func loadPublicKeyPEM(path string) ([]byte, error) {
+	if pem := strings.TrimSpace(os.Getenv("JWT_PUBLIC_KEY_PEM")); pem != "" {
+		return []byte(strings.ReplaceAll(pem, `\n`, "\n")), nil
+	}
+	if strings.TrimSpace(path) == "" {
+		return nil, fmt.Errorf("JWT_PUBLIC_KEY_PEM or JWT_PUBLIC_KEY_PATH must be set")
+	}
+	data, err := readPEMFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("set JWT_PUBLIC_KEY_PEM or point JWT_PUBLIC_KEY_PATH to a readable PEM file: %w", err)
+	}
+	return data, nil
+}
+
+func readPEMFile(path string) ([]byte, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return nil, fmt.Errorf("no PEM file path configured")
+	}
+	data, err := os.ReadFile(path)
+	if err == nil {
+		return data, nil
+	}
+
+	info, statErr := os.Stat(path)
+	if statErr != nil || !info.IsDir() {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	preferredName := filepath.Base(filepath.Clean(path))
+	var firstPEM string
+	var loneFile string
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		fullPath := filepath.Join(path, entry.Name())
+		if entry.Name() == preferredName {
+			return os.ReadFile(fullPath)
+		}
+
+		if strings.HasSuffix(strings.ToLower(entry.Name()), ".pem") && firstPEM == "" {
+			firstPEM = fullPath
+		}
+
+		if loneFile == "" {
+			loneFile = fullPath
+		} else {
+			loneFile = "-"
+		}
+	}
+
+	if firstPEM != "" {
+		return os.ReadFile(firstPEM)
+	}
+	if loneFile != "" && loneFile != "-" {
+		return os.ReadFile(loneFile)
+	}
+
+	return nil, fmt.Errorf("%s is a directory and does not contain a readable PEM file", path)
+}
@@ -0,0 +1,292 @@
+package authn
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestLoadPublicKeyPEMFromRailwayStyleDirectoryMount(t *testing.T) {
+	t.Parallel()
+
+	dir := filepath.Join(t.TempDir(), "jwt_public.pem")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+
+	want := []byte("test-public-key")
+	if err := os.WriteFile(filepath.Join(dir, "jwt_public.pem"), want, 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	got, err := loadPublicKeyPEM(dir)
+	if err != nil {
+		t.Fatalf("loadPublicKeyPEM: %v", err)
+	}
+	if string(got) != string(want) {
+		t.Fatalf("unexpected content: got %q want %q", got, want)
+	}
+}
+
+func signTestToken(t *testing.T, key *rsa.PrivateKey, kid, issuer string) string {
+	t.Helper()
+	tok := jwt.NewWithClaims(jwt.SigningMethodRS256, Claims{
+		UserID: "user-1",
+		Role:   "user",
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    issuer,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+	})
+	if kid != "" {
+		tok.Header["kid"] = kid
+	}
+	signed, err := tok.SignedString(key)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return signed
+}
+
+func TestValidatorFetchesRotatedKeysFromJWKS(t *testing.T) {
+	first, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	second, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	var mu sync.Mutex
+	published := map[string]*rsa.PrivateKey{"k1": first}
+	fetches := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		fetches++
+		keys := []map[string]string{}
+		for kid, key := range published {
+			keys = append(keys, map[string]string{
+				"kty": "RSA",
+				"kid": kid,
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+	}))
+	defer srv.Close()
+
+	v, err := NewValidator("", srv.URL, "gamehub-auth")
+	if err != nil {
+		t.Fatalf("NewValidator: %v", err)
+	}
+	if _, err := v.Parse(signTestToken(t, first, "k1", "gamehub-auth")); err != nil {
+		t.Fatalf("Parse k1: %v", err)
+	}
+
+	// A new kid forces a refetch once the throttle window has passed.
+	mu.Lock()
+	published["k2"] = second
+	mu.Unlock()
+	v.mu.Lock()
+	v.lastAttempt = time.Time{}
+	v.mu.Unlock()
+	if _, err := v.Parse(signTestToken(t, second, "k2", "gamehub-auth")); err != nil {
+		t.Fatalf("Parse k2: %v", err)
+	}
+	if fetches != 2 {
+		t.Fatalf("expected 2 JWKS fetches, got %d", fetches)
+	}
+
+	// Without a static key, tokens lacking a kid cannot be verified.
+	if _, err := v.Parse(signTestToken(t, first, "", "gamehub-auth")); err == nil {
+		t.Fatal("expected kid-less token to be rejected without a static key")
+	}
+}
+
+func TestValidatorRefreshesJWKSOutsideTheLock(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	jwk := map[string]string{
+		"kty": "RSA",
+		"kid": "k1",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+
+	var mu sync.Mutex
+	fetches, empty := 0, false
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		fetches++
+		n, serveEmpty := fetches, empty
+		mu.Unlock()
+		if n > 1 && !serveEmpty {
+			<-release
+		}
+		keys := []map[string]string{jwk}
+		if serveEmpty {
+			keys = nil
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+	}))
+	defer srv.Close()
+
+	v, err := NewValidator("", srv.URL, "gamehub-auth")
+	if err != nil {
+		t.Fatalf("NewValidator: %v", err)
+	}
+	known := signTestToken(t, key, "k1", "gamehub-auth")
+	if _, err := v.Parse(known); err != nil {
+		t.Fatalf("Parse k1: %v", err)
+	}
+
+	// Tokens with an unknown kid wait on a single slow fetch, while tokens
+	// with cached keys keep verifying.
+	v.mu.Lock()
+	v.lastAttempt = time.Time{}
+	v.mu.Unlock()
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = v.Parse(signTestToken(t, key, "unknown", "gamehub-auth"))
+		}()
+	}
+	for {
+		mu.Lock()
+		n := fetches
+		mu.Unlock()
+		if n == 2 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := v.Parse(known); err != nil {
+		t.Fatalf("cached key blocked by a refresh: %v", err)
+	}
+	close(release)
+	wg.Wait()
+	if fetches != 2 {
+		t.Fatalf("expected 2 JWKS fetches, got %d", fetches)
+	}
+
+	// An empty key set doesn't replace the cached keys.
+	mu.Lock()
+	empty = true
+	mu.Unlock()
+	v.mu.Lock()
+	v.lastAttempt, v.fetchedAt = time.Time{}, time.Time{}
+	v.mu.Unlock()
+	if _, err := v.Parse(known); err != nil {
+		t.Fatalf("empty JWKS dropped the cached key: %v", err)
+	}
+	if fetches != 3 {
+		t.Fatalf("expected a third JWKS fetch, got %d", fetches)
+	}
+}
+
+func newStaticValidator(t *testing.T) (*Validator, *rsa.PrivateKey) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("marshal public key: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "jwt_public.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o644); err != nil {
+		t.Fatalf("write public key: %v", err)
+	}
+	v, err := NewValidator(path, "", "gamehub-auth")
+	if err != nil {
+		t.Fatalf("NewValidator: %v", err)
+	}
+	return v, key
+}
+
+func TestValidatorRejectsBadTokens(t *testing.T) {
+	v, key := newStaticValidator(t)
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	sign := func(method jwt.SigningMethod, signingKey interface{}, claims Claims) string {
+		t.Helper()
+		signed, err := jwt.NewWithClaims(method, claims).SignedString(signingKey)
+		if err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+		return signed
+	}
+	valid := func() Claims {
+		return Claims{
+			UserID: "user-1",
+			Role:   "user",
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    "gamehub-auth",
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+			},
+		}
+	}
+
+	if _, err := v.Parse(sign(jwt.SigningMethodRS256, key, valid())); err != nil {
+		t.Fatalf("valid token rejected: %v", err)
+	}
+
+	expired := valid()
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+	wrongIssuer := valid()
+	wrongIssuer.Issuer = "someone-else"
+	missingUID := valid()
+	missingUID.UserID = ""
+	pubDER, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+
+	cases := map[string]string{
+		"expired":      sign(jwt.SigningMethodRS256, key, expired),
+		"wrong issuer": sign(jwt.SigningMethodRS256, key, wrongIssuer),
+		"missing uid":  sign(jwt.SigningMethodRS256, key, missingUID),
+		"wrong key":    sign(jwt.SigningMethodRS256, other, valid()),
+		// HS256 keyed with the public key is the classic alg-confusion attack.
+		"wrong alg": sign(jwt.SigningMethodHS256, pubDER, valid()),
+		"alg none":  sign(jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, valid()),
+	}
+	for name, tok := range cases {
+		if _, err := v.Parse(tok); err == nil {
+			t.Errorf("%s: expected rejection", name)
+		}
+	}
+
+	if _, err := v.FromHeader(""); err != ErrMissingToken {
+		t.Fatalf("expected ErrMissingToken, got %v", err)
+	}
+}
+
+func TestNewValidatorRequiresAKeySource(t *testing.T) {
+	t.Setenv("JWT_PUBLIC_KEY_PEM", "")
+	if _, err := NewValidator("", "", "gamehub-auth"); err == nil {
+		t.Fatal("expected error without a public key or JWKS URL")
+	}
+}
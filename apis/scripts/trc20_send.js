@@ -1,34 +1,50 @@
 /**
  * TRC20 USDT Transfer — uses triggerSmartContract directly
  * to avoid the "account does not exist" sync issue on trongrid fullNode.
+ *
+ * Manual payout path for when automatic crypto payouts are off
+ * (CRYPTO_AUTO_PAYOUT unset). The mnemonic is read from the environment
+ * and never written down:
+ *
+ *   TRON_MNEMONIC='...' node trc20_send.js <to-address> <amount-usdt> [index]
+ *
+ * index is the BIP-44 address index to send from (default 0, the hot
+ * wallet). Set TRON_FULL_HOST for Nile and USDT_CONTRACT for its token.
  */
 const { TronWeb } = require('tronweb');
 
-const MNEMONIC = 'village attend leader across direct artwork rival near remember edge basic buzz surge fan hint silver vapor rebel identify harvest object best used deny';
-const FROM_ADDRESS = 'TKRRiqco4M5ryEb1YmjtzwdRZyAS1mArQk';
-const TO_ADDRESS = 'TYnTHdrVK1h6RoVwAmNEhYHXJu4Fhf7c8W';
-const USDT_CONTRACT = 'TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6t';
-const AMOUNT_USDT = 4;
+const MNEMONIC = process.env.TRON_MNEMONIC;
+const FULL_HOST = process.env.TRON_FULL_HOST || 'https://api.trongrid.io';
+const USDT_CONTRACT = process.env.USDT_CONTRACT || 'TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6t';
+const [TO_ADDRESS, AMOUNT_ARG, INDEX_ARG = '0'] = process.argv.slice(2);
+const AMOUNT_USDT = Number(AMOUNT_ARG);
+const INDEX = Number(INDEX_ARG);
 
 async function main() {
-    // Derive key at index 1
-    const node = TronWeb.fromMnemonic(MNEMONIC, `m/44'/195'/0'/0/1`);
+    if (!MNEMONIC) {
+        throw new Error('TRON_MNEMONIC is not set');
+    }
+    if (!TO_ADDRESS || !TronWeb.isAddress(TO_ADDRESS)) {
+        throw new Error('usage: node trc20_send.js <to-address> <amount-usdt> [index]');
+    }
+    if (!(AMOUNT_USDT > 0) || !Number.isInteger(INDEX) || INDEX < 0) {
+        throw new Error('amount must be positive and index a non-negative integer');
+    }
+
+    const node = TronWeb.fromMnemonic(MNEMONIC, `m/44'/195'/0'/0/${INDEX}`);
     const pk = node.privateKey.startsWith('0x') ? node.privateKey.slice(2) : node.privateKey;
 
-    const tronWeb = new TronWeb({ fullHost: 'https://api.trongrid.io', privateKey: pk });
+    const tronWeb = new TronWeb({ fullHost: FULL_HOST, privateKey: pk });
+    const FROM_ADDRESS = tronWeb.address.fromPrivateKey(pk);
 
     const hexTo = tronWeb.address.toHex(TO_ADDRESS).replace(/^0x/, '41');
-    console.log(`From : ${FROM_ADDRESS}`);
+    console.log(`From : ${FROM_ADDRESS} (index ${INDEX})`);
     console.log(`To   : ${TO_ADDRESS} (hex: ${hexTo})`);
 
-    const atomicAmount = AMOUNT_USDT * 1_000_000;
-    // Pad to 32 bytes
-    const paddedTo = hexTo.replace(/^41/, '000000000000000000000000').padStart(64, '0').slice(-64);
-    const paddedAmount = atomicAmount.toString(16).padStart(64, '0');
+    const atomicAmount = Math.round(AMOUNT_USDT * 1_000_000);
 
     // ABI-encoded call: transfer(address,uint256)
     const functionSelector = 'transfer(address,uint256)';
-    const parameter = paddedTo + paddedAmount;
 
     console.log(`\n🚀 Sending ${AMOUNT_USDT} USDT via triggerSmartContract…`);
 
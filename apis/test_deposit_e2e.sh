@@ -10,6 +10,13 @@
 # Prerequisites:
 #   - Backend container running (docker compose up --build -d)
 #   - curl and jq installed
+#
+# Without provider sandboxes, run the stand-ins and point payment-gateway
+# at them (FLUTTERWAVE_BASE_URL=http://127.0.0.1:8096,
+# TATUM_BASE_URL=http://127.0.0.1:8097):
+#   (cd payment-gateway && go run ./cmd/paymentmock)
+# Client and webhook-signature coverage runs hermetically with
+#   (cd payment-gateway && go test ./internal/flutterwavemock ./internal/tatummock)
 # =============================================================================
 
 set -euo pipefail
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"gamehub/trader-pool/internal/derivmock"
+)
+
+// Standalone Deriv stand-in for local development. Point the trader-pool at it
+// with DERIV_WS_URL=ws://127.0.0.1:8095 DERIV_APP_ID=1 DERIV_ACCOUNT_1_TOKEN=mock.
+func main() {
+	log.SetOutput(os.Stdout)
+
+	addr := flag.String("addr", envOr("DERIV_MOCK_ADDR", ":8095"), "listen address")
+	winRate := flag.Float64("win-rate", 0.45, "probability an unscripted contract wins")
+	payout := flag.Float64("payout-multiplier", 1.95, "quoted payout as a multiple of stake")
+	ticks := flag.Int("ticks", 5, "open-contract updates before settlement")
+	tickInterval := flag.Duration("tick-interval", 500*time.Millisecond, "delay between contract updates")
+	latency := flag.Duration("latency", 0, "delay added before every response")
+	tokens := flag.String("tokens", "", "comma-separated accepted tokens (empty accepts any)")
+	flag.Parse()
+
+	var accepted []string
+	for _, t := range strings.Split(*tokens, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			accepted = append(accepted, t)
+		}
+	}
+
+	srv := derivmock.New(derivmock.Config{
+		PayoutMultiplier: *payout,
+		WinRate:          *winRate,
+		Ticks:            *ticks,
+		TickInterval:     *tickInterval,
+		Latency:          *latency,
+		Tokens:           accepted,
+	})
+
+	log.Printf("Deriv mock on %s (POST /_mock/outcomes, /_mock/faults, /_mock/latency to script)", *addr)
+	if err := http.ListenAndServe(*addr, srv); err != nil {
+		log.Fatalf("Server error: %v", err)
+	}
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
@@ -14,8 +14,11 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/recover"
 	"github.com/redis/go-redis/v9"
 
+	"gamehub/pkg/authn"
 	"gamehub/trader-pool/internal/config"
+	"gamehub/trader-pool/internal/handler"
 	"gamehub/trader-pool/internal/pool"
+	"gamehub/trader-pool/internal/ticks"
 	"gamehub/trader-pool/internal/wallet"
 )
 
@@ -40,6 +43,9 @@ func main() {
 	defer cancel()
 	go mgr.Start(ctx)
 
+	// --- Tick feed (one Deriv stream per symbol, fanned out over Redis) ---
+	go ticks.NewFeed(rdb, cfg).Start(ctx)
+
 	// --- Fiber App (admin/health only — not in public gateway) ---
 	app := fiber.New(fiber.Config{
 		AppName:      "Glory Grid Trader Pool",
@@ -54,6 +60,11 @@ func main() {
 		return c.JSON(fiber.Map{"status": "ok", "service": "trader-pool"})
 	})
 
+	// --- Internal routes (game-session → trader-pool) ---
+	h := handler.New(mgr)
+	internal := app.Group("/internal", authn.RequireInternalKey(cfg.InternalKey))
+	internal.Post("/quotes", h.CreateQuote)
+
 	// --- Graceful Shutdown ---
 	go func() {
 		log.Printf("Trader pool on :%s", cfg.Port)
@@ -27,6 +27,31 @@ type Config struct {
 	DerivSymbol      string
 	DerivTokens      []string
 
+	// Settlement routing
+	// SettlementProvider is the default provider ("deriv" or "simulation").
+	// Deriv falls back to simulation when its credentials are missing.
+	SettlementProvider string
+	// SettlementRoutes overrides the provider per GameType,
+	// e.g. SETTLEMENT_ROUTES=DIGIT_DASH=simulation,VELOCITY_VECTOR=deriv.
+	SettlementRoutes map[string]string
+
+	// Quotes
+	// QuotePrefix:<quoteId> holds a GET_QUOTE preview for QuoteTTLSec. An
+	// order placed against a quote is refunded when the fresh Deriv proposal
+	// pays out less (or costs more) than quoted by more than QuoteTolerance.
+	QuotePrefix    string
+	QuoteTTLSec    int
+	QuoteTolerance float64
+
+	// Tick feed
+	// TickSymbols are streamed from Deriv once each and fanned out over Redis
+	// on TickChannelPrefix:<symbol>, keeping the last TickHistorySize ticks.
+	TickSymbols       []string
+	TickChannelPrefix string
+	TickHistorySize   int
+	// TickIntervalMs paces synthetic ticks when Deriv is not configured.
+	TickIntervalMs int
+
 	// Bounce system
 	// BounceRate is the fraction of bets NOT forwarded to Deriv (0.0–1.0).
 	// e.g. 0.2 means 20% of stakes are kept by the house as a forced LOSS.
@@ -46,25 +71,34 @@ type Config struct {
 
 func Load() *Config {
 	return &Config{
-		Port:             getEnv("PORT", "8005"),
-		RedisAddr:        resolveRedisAddr(),
-		RedisPassword:    resolveRedisPassword(),
-		WalletServiceURL: getEnv("WALLET_SERVICE_URL", "http://127.0.0.1:8004"),
-		InternalKey:      getEnv("INTERNAL_SERVICE_KEY", "dev-internal-key"),
-		OrderQueue:       getEnv("TRADE_ORDER_QUEUE", "trade:orders"),
-		OutcomePrefix:    getEnv("GAME_OUTCOME_PREFIX", "game:outcome"),
-		MinSettleMs:      getEnvInt("MIN_SETTLE_MS", 1500),
-		MaxSettleMs:      getEnvInt("MAX_SETTLE_MS", 4500),
-		DerivAppID:       getEnv("DERIV_APP_ID", ""),
-		DerivWSURL:       getEnv("DERIV_WS_URL", "wss://ws.binaryws.com/websockets/v3"),
-		DerivLanguage:    getEnv("DERIV_LANGUAGE", "en"),
-		DerivOrigin:      getEnv("DERIV_ORIGIN", "https://gamehub.local"),
-		DerivSymbol:      getEnv("DERIV_SYMBOL", "R_50"),
-		DerivTokens:      loadDerivTokens(),
-		BounceRate:       getEnvFloat("BOUNCE_RATE", 0.0),
-		ProfitTargetUsd:  getEnvFloat("PROFIT_TARGET_USD", 0.0),
-		PayoutMultiplier: getEnvFloat("PAYOUT_MULTIPLIER", 1.9),
-		WinRakeRate:      getEnvFloat("WIN_RAKE_RATE", 0.0),
+		Port:               getEnv("PORT", "8005"),
+		RedisAddr:          resolveRedisAddr(),
+		RedisPassword:      resolveRedisPassword(),
+		WalletServiceURL:   getEnv("WALLET_SERVICE_URL", "http://127.0.0.1:8004"),
+		InternalKey:        getEnv("INTERNAL_SERVICE_KEY", "dev-internal-key"),
+		OrderQueue:         getEnv("TRADE_ORDER_QUEUE", "trade:orders"),
+		OutcomePrefix:      getEnv("GAME_OUTCOME_PREFIX", "game:outcome"),
+		MinSettleMs:        getEnvInt("MIN_SETTLE_MS", 1500),
+		MaxSettleMs:        getEnvInt("MAX_SETTLE_MS", 4500),
+		DerivAppID:         getEnv("DERIV_APP_ID", ""),
+		DerivWSURL:         getEnv("DERIV_WS_URL", "wss://ws.binaryws.com/websockets/v3"),
+		DerivLanguage:      getEnv("DERIV_LANGUAGE", "en"),
+		DerivOrigin:        getEnv("DERIV_ORIGIN", "https://gamehub.local"),
+		DerivSymbol:        getEnv("DERIV_SYMBOL", "R_50"),
+		DerivTokens:        loadDerivTokens(),
+		SettlementProvider: getEnv("SETTLEMENT_PROVIDER", "deriv"),
+		SettlementRoutes:   parseRoutes(os.Getenv("SETTLEMENT_ROUTES")),
+		QuotePrefix:        getEnv("TRADE_QUOTE_PREFIX", "trade:quote"),
+		QuoteTTLSec:        getEnvInt("QUOTE_TTL_SECONDS", 15),
+		QuoteTolerance:     getEnvFloat("QUOTE_PRICE_TOLERANCE", 0.02),
+		TickSymbols:        parseList(getEnv("TICK_SYMBOLS", getEnv("DERIV_SYMBOL", "R_50"))),
+		TickChannelPrefix:  getEnv("TICK_CHANNEL_PREFIX", "ticks"),
+		TickHistorySize:    getEnvInt("TICK_HISTORY_SIZE", 300),
+		TickIntervalMs:     getEnvInt("TICK_INTERVAL_MS", 1000),
+		BounceRate:         getEnvFloat("BOUNCE_RATE", 0.0),
+		ProfitTargetUsd:    getEnvFloat("PROFIT_TARGET_USD", 0.0),
+		PayoutMultiplier:   getEnvFloat("PAYOUT_MULTIPLIER", 1.9),
+		WinRakeRate:        getEnvFloat("WIN_RAKE_RATE", 0.0),
 	}
 }
 
@@ -135,6 +169,36 @@ func loadDerivTokens() []string {
 	return tokens
 }
 
+// parseList splits a comma-separated value, dropping blanks and duplicates.
+func parseList(raw string) []string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(raw, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" || seen[item] {
+			continue
+		}
+		seen[item] = true
+		out = append(out, item)
+	}
+	return out
+}
+
+// parseRoutes reads comma-separated KEY=value pairs, ignoring malformed entries.
+func parseRoutes(raw string) map[string]string {
+	routes := make(map[string]string)
+	for _, entry := range strings.Split(raw, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		key = strings.ToUpper(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		if !ok || key == "" || value == "" {
+			continue
+		}
+		routes[key] = value
+	}
+	return routes
+}
+
 func resolveRedisAddr() string {
 	if addr, _, ok := redisFromURL(os.Getenv("REDIS_URL")); ok {
 		return addr
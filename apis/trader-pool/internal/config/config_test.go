@@ -49,3 +49,16 @@ func TestResolveRedisFromBareHostURLValue(t *testing.T) {
 		t.Fatalf("resolveRedisPassword() = %q, want %q", got, "shared-secret")
 	}
 }
+
+func TestParseRoutes(t *testing.T) {
+	routes := parseRoutes(" digit_dash=simulation, VELOCITY_VECTOR = deriv ,bad,=deriv,EMPTY=")
+	if len(routes) != 2 {
+		t.Fatalf("parseRoutes() returned %d routes, want 2: %v", len(routes), routes)
+	}
+	if got := routes["DIGIT_DASH"]; got != "simulation" {
+		t.Fatalf("routes[DIGIT_DASH] = %q, want %q", got, "simulation")
+	}
+	if got := routes["VELOCITY_VECTOR"]; got != "deriv" {
+		t.Fatalf("routes[VELOCITY_VECTOR] = %q, want %q", got, "deriv")
+	}
+}
@@ -0,0 +1,806 @@
+// Package derivmock is a local stand-in for the Deriv WebSocket API. It speaks
+// the authorize/proposal/buy/proposal_open_contract/sell/ticks/forget subset
+// used by the trader-pool so the real Deriv paths can run without credentials.
+package derivmock
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/google/uuid"
+)
+
+// Outcome scripts how the next purchased contract settles.
+type Outcome struct {
+	// Result is WIN, LOSS or REFUND.
+	Result string `json:"result"`
+	// Ticks is the number of open-contract updates streamed before settlement.
+	Ticks int `json:"ticks,omitempty"`
+	// Payout overrides the sell price of a WIN (defaults to the quoted payout).
+	Payout float64 `json:"payout,omitempty"`
+	// SellFor is the price an early sell (cashout) returns (defaults to the buy price).
+	SellFor float64 `json:"sellFor,omitempty"`
+}
+
+// Config controls the behaviour of unscripted contracts.
+type Config struct {
+	// PayoutMultiplier is applied to the stake to quote a payout.
+	PayoutMultiplier float64
+	// WinRate is the probability an unscripted contract settles as a WIN.
+	WinRate float64
+	// Ticks is the default number of open-contract updates per contract.
+	Ticks int
+	// TickInterval is the delay between open-contract and tick stream updates.
+	TickInterval time.Duration
+	// Latency is added before every response.
+	Latency time.Duration
+	// Tokens lists accepted authorize tokens. Empty accepts any token.
+	Tokens []string
+	// StartSpot seeds the simulated spot price of every symbol.
+	StartSpot float64
+	// Balance is the virtual account balance reported to clients.
+	Balance float64
+}
+
+type fault struct {
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+	Disconnect bool   `json:"disconnect"`
+}
+
+type quote struct {
+	id           string
+	contractType string
+	symbol       string
+	currency     string
+	askPrice     float64
+	payout       float64
+	spot         float64
+}
+
+type contract struct {
+	id        int
+	quote     quote
+	outcome   Outcome
+	buyPrice  float64
+	entrySpot float64
+	startTime int64
+	subID     string
+	sold      bool
+	soldFor   float64
+}
+
+// Server implements http.Handler. WebSocket upgrades speak the Deriv protocol;
+// plain HTTP requests under /_mock/ script outcomes and faults.
+type Server struct {
+	cfg Config
+
+	mu             sync.Mutex
+	rng            *rand.Rand
+	spots          map[string]float64
+	balance        float64
+	outcomes       []Outcome
+	faults         map[string][]fault
+	quotes         map[string]quote
+	contracts      map[int]*contract
+	nextContractID int
+	requests       map[string][]map[string]interface{}
+}
+
+// New creates a Server, filling unset config fields with sensible defaults.
+func New(cfg Config) *Server {
+	if cfg.PayoutMultiplier <= 1 {
+		cfg.PayoutMultiplier = 1.95
+	}
+	if cfg.Ticks <= 0 {
+		cfg.Ticks = 5
+	}
+	if cfg.TickInterval <= 0 {
+		cfg.TickInterval = 200 * time.Millisecond
+	}
+	if cfg.StartSpot <= 0 {
+		cfg.StartSpot = 1000
+	}
+	if cfg.Balance <= 0 {
+		cfg.Balance = 10000
+	}
+	return &Server{
+		cfg:            cfg,
+		rng:            rand.New(rand.NewSource(time.Now().UnixNano())),
+		spots:          make(map[string]float64),
+		balance:        cfg.Balance,
+		faults:         make(map[string][]fault),
+		quotes:         make(map[string]quote),
+		contracts:      make(map[int]*contract),
+		nextContractID: 100000,
+		requests:       make(map[string][]map[string]interface{}),
+	}
+}
+
+// QueueOutcome scripts the settlement of the next purchased contracts, in order.
+func (s *Server) QueueOutcome(outcomes ...Outcome) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.outcomes = append(s.outcomes, outcomes...)
+}
+
+// FailNext makes the next request of msgType fail with the given Deriv error.
+func (s *Server) FailNext(msgType, code, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults[msgType] = append(s.faults[msgType], fault{Code: code, Message: message})
+}
+
+// DisconnectNext drops the connection when the next request of msgType arrives.
+func (s *Server) DisconnectNext(msgType string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults[msgType] = append(s.faults[msgType], fault{Disconnect: true})
+}
+
+// SetLatency changes the delay added before every response.
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cfg.Latency = d
+}
+
+// Requests returns the payloads received for msgType, oldest first.
+func (s *Server) Requests(msgType string) []map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]map[string]interface{}, len(s.requests[msgType]))
+	copy(out, s.requests[msgType])
+	return out
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, "/_mock/") {
+		s.serveAdmin(w, r)
+		return
+	}
+	ws, err := websocket.Accept(w, r, &websocket.AcceptOptions{InsecureSkipVerify: true})
+	if err != nil {
+		return
+	}
+	c := &conn{srv: s, ws: ws, subs: make(map[string]struct{})}
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+	defer c.cancel()
+	c.serve()
+}
+
+func (s *Server) serveAdmin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	switch r.URL.Path {
+	case "/_mock/outcomes":
+		var outcomes []Outcome
+		if err := json.NewDecoder(r.Body).Decode(&outcomes); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.QueueOutcome(outcomes...)
+	case "/_mock/faults":
+		var req struct {
+			fault
+			MsgType string `json:"msgType"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.MsgType == "" {
+			http.Error(w, "msgType is required", http.StatusBadRequest)
+			return
+		}
+		s.mu.Lock()
+		s.faults[req.MsgType] = append(s.faults[req.MsgType], req.fault)
+		s.mu.Unlock()
+	case "/_mock/latency":
+		var req struct {
+			Ms int `json:"ms"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.SetLatency(time.Duration(req.Ms) * time.Millisecond)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// record stores the request and pops any queued fault for msgType.
+func (s *Server) record(msgType string, req map[string]interface{}) (*fault, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests[msgType] = append(s.requests[msgType], req)
+	latency := s.cfg.Latency
+	queue := s.faults[msgType]
+	if len(queue) == 0 {
+		return nil, latency
+	}
+	f := queue[0]
+	s.faults[msgType] = queue[1:]
+	return &f, latency
+}
+
+func (s *Server) nextOutcome() Outcome {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.outcomes) > 0 {
+		o := s.outcomes[0]
+		s.outcomes = s.outcomes[1:]
+		return o
+	}
+	if s.rng.Float64() < s.cfg.WinRate {
+		return Outcome{Result: "WIN"}
+	}
+	return Outcome{Result: "LOSS"}
+}
+
+// nextSpot advances the random walk of symbol and returns the new spot.
+func (s *Server) nextSpot(symbol string) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	spot, ok := s.spots[symbol]
+	if !ok {
+		spot = s.cfg.StartSpot
+	}
+	spot += (s.rng.Float64() - 0.5) * spot * 0.001
+	s.spots[symbol] = spot
+	return roundSpot(spot)
+}
+
+func (s *Server) validToken(token string) bool {
+	if len(s.cfg.Tokens) == 0 {
+		return token != ""
+	}
+	for _, t := range s.cfg.Tokens {
+		if t == token {
+			return true
+		}
+	}
+	return false
+}
+
+// conn is a single client connection.
+type conn struct {
+	srv        *Server
+	ws         *websocket.Conn
+	ctx        context.Context
+	cancel     context.CancelFunc
+	writeMu    sync.Mutex
+	subsMu     sync.Mutex
+	subs       map[string]struct{}
+	authorized bool
+}
+
+func (c *conn) serve() {
+	defer c.ws.Close(websocket.StatusNormalClosure, "")
+	for {
+		_, data, err := c.ws.Read(c.ctx)
+		if err != nil {
+			return
+		}
+		var req map[string]interface{}
+		if err := json.Unmarshal(data, &req); err != nil {
+			continue
+		}
+		if !c.handle(req) {
+			return
+		}
+	}
+}
+
+var msgTypes = []string{"authorize", "proposal_open_contract", "proposal", "buy", "sell", "ticks", "forget", "ping"}
+
+// handle dispatches one request. It returns false when the connection must close.
+func (c *conn) handle(req map[string]interface{}) bool {
+	msgType := ""
+	for _, t := range msgTypes {
+		if _, ok := req[t]; ok {
+			msgType = t
+			break
+		}
+	}
+	if msgType == "" {
+		c.replyError(req, "unknown", "UnrecognisedRequest", "Unrecognised request.")
+		return true
+	}
+
+	f, latency := c.srv.record(msgType, req)
+	if latency > 0 {
+		time.Sleep(latency)
+	}
+	if f != nil {
+		if f.Disconnect {
+			return false
+		}
+		c.replyError(req, msgType, f.Code, f.Message)
+		return true
+	}
+
+	switch msgType {
+	case "authorize":
+		c.handleAuthorize(req)
+	case "proposal":
+		c.handleProposal(req)
+	case "buy":
+		c.handleBuy(req)
+	case "proposal_open_contract":
+		c.handleOpenContract(req)
+	case "sell":
+		c.handleSell(req)
+	case "ticks":
+		c.handleTicks(req)
+	case "forget":
+		c.handleForget(req)
+	case "ping":
+		c.reply(req, "ping", map[string]interface{}{"ping": "pong"})
+	}
+	return true
+}
+
+func (c *conn) handleAuthorize(req map[string]interface{}) {
+	token, _ := req["authorize"].(string)
+	if !c.srv.validToken(token) {
+		c.replyError(req, "authorize", "InvalidToken", "The token is invalid.")
+		return
+	}
+	c.authorized = true
+	c.srv.mu.Lock()
+	balance := c.srv.balance
+	c.srv.mu.Unlock()
+	c.reply(req, "authorize", map[string]interface{}{
+		"authorize": map[string]interface{}{
+			"loginid":    "VRTC0000001",
+			"currency":   "USD",
+			"balance":    balance,
+			"email":      "mock@deriv.local",
+			"fullname":   "Deriv Mock",
+			"is_virtual": 1,
+		},
+	})
+}
+
+func (c *conn) handleProposal(req map[string]interface{}) {
+	contractType := strings.ToUpper(readString(req, "contract_type"))
+	symbol := readString(req, "symbol")
+	currency := readString(req, "currency")
+	amount, _ := readFloat(req, "amount")
+	if contractType == "" || symbol == "" || currency == "" {
+		c.replyError(req, "proposal", "InputValidationFailed", "Input validation failed: contract_type, symbol and currency are required.")
+		return
+	}
+	if amount <= 0 {
+		c.replyError(req, "proposal", "ContractCreationFailure", "Please enter a stake amount greater than zero.")
+		return
+	}
+	if msg := validateDuration(contractType, req); msg != "" {
+		c.replyError(req, "proposal", "ContractCreationFailure", msg)
+		return
+	}
+
+	spot := c.srv.nextSpot(symbol)
+	q := quote{
+		id:           uuid.NewString(),
+		contractType: contractType,
+		symbol:       symbol,
+		currency:     currency,
+		askPrice:     roundMoney(amount),
+		payout:       roundMoney(amount * c.srv.cfg.PayoutMultiplier),
+		spot:         spot,
+	}
+	c.srv.mu.Lock()
+	c.srv.quotes[q.id] = q
+	c.srv.mu.Unlock()
+
+	now := time.Now().Unix()
+	c.reply(req, "proposal", map[string]interface{}{
+		"proposal": map[string]interface{}{
+			"id":            q.id,
+			"ask_price":     q.askPrice,
+			"payout":        q.payout,
+			"spot":          spot,
+			"spot_time":     now,
+			"date_start":    now,
+			"display_value": strconv.FormatFloat(q.askPrice, 'f', 2, 64),
+			"longcode":      fmt.Sprintf("Win payout if %s %s on %s.", symbol, strings.ToLower(contractType), currency),
+		},
+	})
+}
+
+func (c *conn) handleBuy(req map[string]interface{}) {
+	if !c.authorized {
+		c.replyError(req, "buy", "AuthorizationRequired", "Please log in.")
+		return
+	}
+	proposalID := readString(req, "buy")
+	price, _ := readFloat(req, "price")
+
+	c.srv.mu.Lock()
+	q, ok := c.srv.quotes[proposalID]
+	if ok {
+		delete(c.srv.quotes, proposalID)
+	}
+	c.srv.mu.Unlock()
+	if !ok {
+		c.replyError(req, "buy", "InvalidContractProposal", "Unknown contract proposal.")
+		return
+	}
+	if price+0.00001 < q.askPrice {
+		c.replyError(req, "buy", "PriceMoved", fmt.Sprintf("The contract price has moved from %.2f to %.2f.", price, q.askPrice))
+		return
+	}
+
+	outcome := c.srv.nextOutcome()
+	if outcome.Ticks <= 0 {
+		outcome.Ticks = c.srv.cfg.Ticks
+	}
+	now := time.Now().Unix()
+
+	c.srv.mu.Lock()
+	c.srv.nextContractID++
+	ct := &contract{
+		id:        c.srv.nextContractID,
+		quote:     q,
+		outcome:   outcome,
+		buyPrice:  q.askPrice,
+		entrySpot: q.spot,
+		startTime: now,
+		subID:     uuid.NewString(),
+	}
+	c.srv.contracts[ct.id] = ct
+	c.srv.balance -= ct.buyPrice
+	balance := c.srv.balance
+	c.srv.mu.Unlock()
+
+	reqID := readInt(req, "req_id")
+	resp := map[string]interface{}{
+		"buy": map[string]interface{}{
+			"balance_after":  roundMoney(balance),
+			"buy_price":      ct.buyPrice,
+			"contract_id":    ct.id,
+			"longcode":       fmt.Sprintf("Mock %s contract on %s.", q.contractType, q.symbol),
+			"payout":         q.payout,
+			"purchase_time":  now,
+			"shortcode":      fmt.Sprintf("%s_%s_%d", q.contractType, q.symbol, now),
+			"start_time":     now,
+			"transaction_id": ct.id * 2,
+		},
+	}
+	subscribed := readInt(req, "subscribe") == 1
+	if subscribed {
+		resp["subscription"] = map[string]interface{}{"id": ct.subID}
+		c.addSub(ct.subID)
+	}
+	c.reply(req, "buy", resp)
+	if subscribed {
+		go c.stream(ct, reqID)
+	}
+}
+
+// stream emits open-contract updates until the contract settles, is sold or
+// the subscription is forgotten.
+func (c *conn) stream(ct *contract, reqID int) {
+	echo := map[string]interface{}{"proposal_open_contract": 1, "contract_id": ct.id, "subscribe": 1}
+	for tick := 1; ; tick++ {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-time.After(c.srv.cfg.TickInterval):
+		}
+		if !c.subscribed(ct.subID) {
+			return
+		}
+		spot := c.srv.nextSpot(ct.quote.symbol)
+
+		c.srv.mu.Lock()
+		if ct.sold {
+			c.srv.mu.Unlock()
+			return
+		}
+		final := tick >= ct.outcome.Ticks
+		if final {
+			ct.sold = true
+			ct.soldFor = settlementPrice(ct)
+			c.srv.balance += ct.soldFor
+		}
+		poc := ct.openContract(spot)
+		c.srv.mu.Unlock()
+
+		c.write(map[string]interface{}{
+			"echo_req":               echo,
+			"msg_type":               "proposal_open_contract",
+			"req_id":                 reqID,
+			"proposal_open_contract": poc,
+			"subscription":           map[string]interface{}{"id": ct.subID},
+		})
+		if final {
+			return
+		}
+	}
+}
+
+func (c *conn) addSub(subID string) {
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+	c.subs[subID] = struct{}{}
+}
+
+func (c *conn) subscribed(subID string) bool {
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+	_, ok := c.subs[subID]
+	return ok
+}
+
+func (c *conn) handleOpenContract(req map[string]interface{}) {
+	if !c.authorized {
+		c.replyError(req, "proposal_open_contract", "AuthorizationRequired", "Please log in.")
+		return
+	}
+	contractID := readInt(req, "contract_id")
+	c.srv.mu.Lock()
+	ct, ok := c.srv.contracts[contractID]
+	var poc map[string]interface{}
+	if ok {
+		poc = ct.openContract(c.srv.spots[ct.quote.symbol])
+	}
+	c.srv.mu.Unlock()
+	if !ok {
+		c.replyError(req, "proposal_open_contract", "InvalidContractId", "Contract not found.")
+		return
+	}
+	c.reply(req, "proposal_open_contract", map[string]interface{}{"proposal_open_contract": poc})
+}
+
+func (c *conn) handleSell(req map[string]interface{}) {
+	if !c.authorized {
+		c.replyError(req, "sell", "AuthorizationRequired", "Please log in.")
+		return
+	}
+	contractID := readInt(req, "sell")
+	c.srv.mu.Lock()
+	ct, ok := c.srv.contracts[contractID]
+	if !ok || ct.sold {
+		c.srv.mu.Unlock()
+		c.replyError(req, "sell", "InvalidSellContractProposal", "This contract is not open.")
+		return
+	}
+	soldFor := ct.outcome.SellFor
+	if soldFor <= 0 {
+		soldFor = ct.buyPrice
+	}
+	ct.sold = true
+	ct.soldFor = roundMoney(soldFor)
+	c.srv.balance += ct.soldFor
+	balance := c.srv.balance
+	c.srv.mu.Unlock()
+
+	c.reply(req, "sell", map[string]interface{}{
+		"sell": map[string]interface{}{
+			"balance_after":  roundMoney(balance),
+			"contract_id":    ct.id,
+			"reference_id":   ct.id * 2,
+			"sold_for":       ct.soldFor,
+			"transaction_id": ct.id*2 + 1,
+		},
+	})
+}
+
+func (c *conn) handleTicks(req map[string]interface{}) {
+	symbol := readString(req, "ticks")
+	if symbol == "" {
+		c.replyError(req, "tick", "InputValidationFailed", "Input validation failed: ticks.")
+		return
+	}
+	resp := map[string]interface{}{"tick": c.tick(symbol, "")}
+	if readInt(req, "subscribe") != 1 {
+		c.reply(req, "tick", resp)
+		return
+	}
+	subID := uuid.NewString()
+	resp["tick"] = c.tick(symbol, subID)
+	resp["subscription"] = map[string]interface{}{"id": subID}
+	c.addSub(subID)
+	c.reply(req, "tick", resp)
+	go c.streamTicks(req, symbol, subID)
+}
+
+// streamTicks emits a tick for symbol every TickInterval until forgotten.
+func (c *conn) streamTicks(req map[string]interface{}, symbol, subID string) {
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-time.After(c.srv.cfg.TickInterval):
+		}
+		if !c.subscribed(subID) {
+			return
+		}
+		c.reply(req, "tick", map[string]interface{}{
+			"tick":         c.tick(symbol, subID),
+			"subscription": map[string]interface{}{"id": subID},
+		})
+	}
+}
+
+func (c *conn) tick(symbol, subID string) map[string]interface{} {
+	quote := c.srv.nextSpot(symbol)
+	tick := map[string]interface{}{
+		"symbol":   symbol,
+		"quote":    quote,
+		"ask":      roundSpot(quote + 0.01),
+		"bid":      roundSpot(quote - 0.01),
+		"epoch":    time.Now().Unix(),
+		"pip_size": 4,
+	}
+	if subID != "" {
+		tick["id"] = subID
+	}
+	return tick
+}
+
+func (c *conn) handleForget(req map[string]interface{}) {
+	subID := readString(req, "forget")
+	c.subsMu.Lock()
+	_, ok := c.subs[subID]
+	delete(c.subs, subID)
+	c.subsMu.Unlock()
+	forgotten := 0
+	if ok {
+		forgotten = 1
+	}
+	c.reply(req, "forget", map[string]interface{}{"forget": forgotten})
+}
+
+func (c *conn) reply(req map[string]interface{}, msgType string, body map[string]interface{}) {
+	body["echo_req"] = req
+	body["msg_type"] = msgType
+	if reqID, ok := req["req_id"]; ok {
+		body["req_id"] = reqID
+	}
+	c.write(body)
+}
+
+func (c *conn) replyError(req map[string]interface{}, msgType, code, message string) {
+	c.reply(req, msgType, map[string]interface{}{
+		"error": map[string]interface{}{"code": code, "message": message},
+	})
+}
+
+func (c *conn) write(msg map[string]interface{}) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("derivmock: marshal response: %v", err)
+		return
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if err := c.ws.Write(c.ctx, websocket.MessageText, data); err != nil {
+		c.cancel()
+	}
+}
+
+// openContract renders the proposal_open_contract payload. Callers hold srv.mu.
+func (ct *contract) openContract(spot float64) map[string]interface{} {
+	poc := map[string]interface{}{
+		"contract_id":   ct.id,
+		"contract_type": ct.quote.contractType,
+		"underlying":    ct.quote.symbol,
+		"currency":      ct.quote.currency,
+		"buy_price":     ct.buyPrice,
+		"payout":        ct.quote.payout,
+		"entry_spot":    ct.entrySpot,
+		"current_spot":  spot,
+		"date_start":    ct.startTime,
+		"is_sold":       0,
+		"status":        "open",
+		"bid_price":     ct.buyPrice,
+		"profit":        0.0,
+	}
+	if ct.sold {
+		profit := roundMoney(ct.soldFor - ct.buyPrice)
+		status := "sold"
+		switch {
+		case ct.soldFor == 0:
+			status = "lost"
+		case profit > 0 && ct.soldFor == settlementPrice(ct):
+			status = "won"
+		}
+		poc["is_sold"] = 1
+		poc["status"] = status
+		poc["sell_price"] = ct.soldFor
+		poc["bid_price"] = ct.soldFor
+		poc["profit"] = profit
+		poc["exit_tick"] = spot
+	}
+	return poc
+}
+
+// settlementPrice is the sell price of a contract held to expiry.
+func settlementPrice(ct *contract) float64 {
+	switch strings.ToUpper(ct.outcome.Result) {
+	case "WIN":
+		if ct.outcome.Payout > 0 {
+			return roundMoney(ct.outcome.Payout)
+		}
+		return ct.quote.payout
+	case "REFUND":
+		return ct.buyPrice
+	default:
+		return 0
+	}
+}
+
+// validateDuration mirrors the Deriv rules the trader-pool normalises against.
+func validateDuration(contractType string, req map[string]interface{}) string {
+	_, hasDuration := req["duration"]
+	if strings.HasPrefix(contractType, "MULT") {
+		if hasDuration {
+			return "Multiplier contracts do not accept a duration."
+		}
+		if _, ok := readFloat(req, "multiplier"); !ok {
+			return "Please specify a multiplier."
+		}
+		return ""
+	}
+	duration := readInt(req, "duration")
+	if duration <= 0 {
+		return "Please specify a contract duration."
+	}
+	switch readString(req, "duration_unit") {
+	case "t", "s", "m", "h", "d":
+	default:
+		return "Invalid duration unit."
+	}
+	return ""
+}
+
+func readString(m map[string]interface{}, key string) string {
+	switch v := m[key].(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return ""
+	}
+}
+
+func readFloat(m map[string]interface{}, key string) (float64, bool) {
+	switch v := m[key].(type) {
+	case float64:
+		return v, true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+func readInt(m map[string]interface{}, key string) int {
+	f, _ := readFloat(m, key)
+	return int(f)
+}
+
+func roundMoney(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+func roundSpot(v float64) float64 {
+	return math.Round(v*10000) / 10000
+}
@@ -0,0 +1,17 @@
+package derivmock
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// NewTestServer starts a mock on a random local port for the duration of the
+// test and returns it together with its ws:// URL.
+func NewTestServer(tb testing.TB, cfg Config) (*Server, string) {
+	tb.Helper()
+	srv := New(cfg)
+	ts := httptest.NewServer(srv)
+	tb.Cleanup(ts.Close)
+	return srv, "ws" + strings.TrimPrefix(ts.URL, "http")
+}
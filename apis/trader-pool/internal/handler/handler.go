@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"gamehub/trader-pool/internal/pool"
+)
+
+type Handler struct {
+	mgr *pool.Manager
+}
+
+func New(mgr *pool.Manager) *Handler {
+	return &Handler{mgr: mgr}
+}
+
+// CreateQuote prices an order without placing it. Called by game-session for
+// GET_QUOTE.
+func (h *Handler) CreateQuote(c *fiber.Ctx) error {
+	var req pool.QuoteRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid body"})
+	}
+	if req.UserID == "" || req.GameType == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "userId and gameType are required"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	quote, err := h.mgr.Quote(ctx, req)
+	if err != nil {
+		if errors.Is(err, pool.ErrInvalidQuote) || errors.Is(err, pool.ErrQuoteUnavailable) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		log.Printf("[trace=%s] quote failed: %v", req.TraceID, err)
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": "quote unavailable"})
+	}
+	return c.JSON(quote)
+}
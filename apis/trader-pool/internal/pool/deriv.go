@@ -2,6 +2,7 @@ package pool
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"math"
@@ -16,6 +17,87 @@ import (
 	"gamehub/trader-pool/internal/config"
 )
 
+var errNoDerivAccounts = errors.New("no deriv accounts")
+
+// derivProvider settles orders as real Deriv contracts, spreading load across
+// the configured accounts.
+type derivProvider struct {
+	cfg      *config.Config
+	accounts []*derivAccount
+	quotes   *derivQuotePool
+}
+
+// newDerivProvider returns nil when Deriv credentials are missing so the
+// router falls back to simulation.
+func newDerivProvider(cfg *config.Config) SettlementProvider {
+	if len(cfg.DerivTokens) == 0 || cfg.DerivAppID == "" {
+		log.Println("⚠️  Deriv credentials missing — deriv settlement provider disabled")
+		return nil
+	}
+	p := &derivProvider{cfg: cfg, quotes: newDerivQuotePool(cfg)}
+	for idx, token := range cfg.DerivTokens {
+		accountID := fmt.Sprintf("acct-%d", idx+1)
+		p.accounts = append(p.accounts, newDerivAccount(accountID, token, cfg))
+	}
+	return p
+}
+
+func (p *derivProvider) Name() string {
+	return providerDeriv
+}
+
+func (p *derivProvider) Execute(ctx context.Context, order tradeOrder, active *activeTrade) (*tradeSettlement, error) {
+	account := p.selectAccount()
+	if account == nil {
+		return nil, errNoDerivAccounts
+	}
+	if active != nil {
+		active.accountID = account.id
+	}
+	return account.execute(ctx, order, active)
+}
+
+func (p *derivProvider) Cashout(active *activeTrade, req cashoutRequest) error {
+	return forwardCashout(active, req)
+}
+
+// Recover looks up a contract bought before Execute failed and settles it from
+// its final state, selling it first if it is still open. Orders that never got
+// a contract are refunded.
+func (p *derivProvider) Recover(ctx context.Context, order tradeOrder, active *activeTrade, cause error) (*tradeSettlement, error) {
+	if active == nil || active.contractID == 0 {
+		return refundSettlement(order), nil
+	}
+	var account *derivAccount
+	for _, acc := range p.accounts {
+		if acc.id == active.accountID {
+			account = acc
+			break
+		}
+	}
+	if account == nil {
+		return nil, fmt.Errorf("recover contract %d: unknown account %q: %w", active.contractID, active.accountID, cause)
+	}
+	settlement, err := account.recover(ctx, order, active.contractID)
+	if err != nil {
+		return nil, fmt.Errorf("recover contract %d: %w", active.contractID, err)
+	}
+	return settlement, nil
+}
+
+func (p *derivProvider) selectAccount() *derivAccount {
+	if len(p.accounts) == 0 {
+		return nil
+	}
+	var best *derivAccount
+	for _, acc := range p.accounts {
+		if best == nil || acc.inFlight() < best.inFlight() {
+			best = acc
+		}
+	}
+	return best
+}
+
 type derivAccount struct {
 	id     string
 	token  string
@@ -35,10 +117,8 @@ func (a *derivAccount) inFlight() int64 {
 	return atomic.LoadInt64(&a.active)
 }
 
-func (a *derivAccount) execute(ctx context.Context, order tradeOrder, active *activeTrade) (*tradeSettlement, error) {
-	atomic.AddInt64(&a.active, 1)
-	defer atomic.AddInt64(&a.active, -1)
-
+// connect opens an authorized Deriv connection. Callers must Disconnect it.
+func (a *derivAccount) connect(ctx context.Context) (*deriv.Client, error) {
 	appID, err := strconv.Atoi(a.cfg.DerivAppID)
 	if err != nil {
 		return nil, fmt.Errorf("invalid DERIV_APP_ID: %w", err)
@@ -47,11 +127,22 @@ func (a *derivAccount) execute(ctx context.Context, order tradeOrder, active *ac
 	if err != nil {
 		return nil, fmt.Errorf("deriv connect: %w", err)
 	}
-	defer api.Disconnect()
-
 	if _, err := api.Authorize(ctx, schema.Authorize{Authorize: a.token}); err != nil {
+		api.Disconnect()
 		return nil, fmt.Errorf("deriv authorize: %w", err)
 	}
+	return api, nil
+}
+
+func (a *derivAccount) execute(ctx context.Context, order tradeOrder, active *activeTrade) (*tradeSettlement, error) {
+	atomic.AddInt64(&a.active, 1)
+	defer atomic.AddInt64(&a.active, -1)
+
+	api, err := a.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer api.Disconnect()
 	log.Printf("[trace=%s][%s] authorized with Deriv", order.TraceID, a.id)
 
 	req, err := buildDerivProposal(order, a.cfg)
@@ -83,6 +174,10 @@ func (a *derivAccount) execute(ctx context.Context, order tradeOrder, active *ac
 		req.Barrier,
 		req.Barrier2,
 	)
+	if err := checkQuote(order.Quote, resp.Proposal, a.cfg.QuoteTolerance); err != nil {
+		log.Printf("[trace=%s][%s] ⚠️ quote %s rejected: %v", order.TraceID, a.id, order.Quote.QuoteID, err)
+		return nil, err
+	}
 
 	buyReq := schema.Buy{
 		Buy:   resp.Proposal.Id,
@@ -104,6 +199,9 @@ func (a *derivAccount) execute(ctx context.Context, order tradeOrder, active *ac
 		contractIDInt = buyResp.Buy.ContractId
 		contractID = strconv.Itoa(contractIDInt)
 	}
+	if active != nil {
+		active.contractID = contractIDInt
+	}
 	var cashoutCh <-chan cashoutRequest
 	if active != nil {
 		cashoutCh = active.cashoutCh
@@ -159,6 +257,9 @@ func (a *derivAccount) execute(ctx context.Context, order tradeOrder, active *ac
 			if oc.ContractId != nil {
 				contractIDInt = *oc.ContractId
 				contractID = strconv.Itoa(*oc.ContractId)
+				if active != nil {
+					active.contractID = contractIDInt
+				}
 			}
 			if pendingCashout {
 				if settlement, ok := sellActiveContract("pending"); ok {
@@ -177,35 +278,76 @@ func (a *derivAccount) execute(ctx context.Context, order tradeOrder, active *ac
 				)
 				continue
 			}
-			profit := 0.0
-			if oc.Profit != nil {
-				profit = *oc.Profit
-			}
-			sellPrice := 0.0
-			if oc.SellPrice != nil {
-				sellPrice = *oc.SellPrice
-			}
+			settlement := soldSettlement(order, contractID, oc)
+			log.Printf("[trace=%s][%s] contract=%s outcome=%s payout=%.2f", order.TraceID, a.id, contractID, settlement.Outcome, settlement.PayoutUsd)
+			return settlement, nil
+		}
+	}
+}
 
-			outcome := "LOSS"
-			payout := 0.0
-			if profit > 0 || sellPrice > order.StakeUsd {
-				outcome = "WIN"
-				if sellPrice > 0 {
-					payout = sellPrice
-				} else {
-					payout = order.StakeUsd + profit
-				}
-			} else if profit >= -0.00001 {
-				outcome = "REFUND"
-				payout = order.StakeUsd
-			}
-			log.Printf("[trace=%s][%s] contract=%s outcome=%s profit=%.2f payout=%.2f", order.TraceID, a.id, contractID, outcome, profit, payout)
-			return &tradeSettlement{
-				Outcome:    outcome,
-				PayoutUsd:  payout,
-				ContractID: contractID,
-			}, nil
+// recover settles a previously bought contract from its current state,
+// selling it at market if it is still open.
+func (a *derivAccount) recover(ctx context.Context, order tradeOrder, contractID int) (*tradeSettlement, error) {
+	api, err := a.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer api.Disconnect()
+
+	resp, err := api.ProposalOpenContract(ctx, schema.ProposalOpenContract{ProposalOpenContract: 1, ContractId: &contractID})
+	if err != nil {
+		return nil, fmt.Errorf("deriv proposal_open_contract: %w", err)
+	}
+	oc := resp.ProposalOpenContract
+	if oc == nil {
+		return nil, fmt.Errorf("deriv proposal_open_contract missing payload")
+	}
+	id := strconv.Itoa(contractID)
+	if oc.IsSold != nil && *oc.IsSold == 1 {
+		settlement := soldSettlement(order, id, oc)
+		log.Printf("[trace=%s][%s] recovered sold contract=%s outcome=%s payout=%.2f", order.TraceID, a.id, id, settlement.Outcome, settlement.PayoutUsd)
+		return settlement, nil
+	}
+
+	sellResp, err := api.Sell(ctx, schema.Sell{Sell: contractID, Price: 0})
+	if err != nil {
+		return nil, fmt.Errorf("deriv sell: %w", err)
+	}
+	if sellResp.Sell == nil || sellResp.Sell.SoldFor == nil {
+		return nil, fmt.Errorf("deriv sell missing sold_for")
+	}
+	log.Printf("[trace=%s][%s] recovered open contract=%s sold_for=%.2f", order.TraceID, a.id, id, *sellResp.Sell.SoldFor)
+	return cashoutSettlement(order, id, *sellResp.Sell.SoldFor), nil
+}
+
+// soldSettlement maps a sold contract's profit and sell price to an outcome.
+func soldSettlement(order tradeOrder, contractID string, oc *schema.ProposalOpenContractRespProposalOpenContract) *tradeSettlement {
+	profit := 0.0
+	if oc.Profit != nil {
+		profit = *oc.Profit
+	}
+	sellPrice := 0.0
+	if oc.SellPrice != nil {
+		sellPrice = *oc.SellPrice
+	}
+
+	outcome := "LOSS"
+	payout := 0.0
+	if profit > 0 || sellPrice > order.StakeUsd {
+		outcome = "WIN"
+		if sellPrice > 0 {
+			payout = sellPrice
+		} else {
+			payout = order.StakeUsd + profit
 		}
+	} else if profit >= -0.00001 {
+		outcome = "REFUND"
+		payout = order.StakeUsd
+	}
+	return &tradeSettlement{
+		Outcome:    outcome,
+		PayoutUsd:  payout,
+		ContractID: contractID,
 	}
 }
 
@@ -0,0 +1,190 @@
+package pool
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"gamehub/trader-pool/internal/config"
+	"gamehub/trader-pool/internal/derivmock"
+)
+
+func newMockAccount(t *testing.T) (*derivmock.Server, *derivAccount) {
+	t.Helper()
+	mock, url := derivmock.NewTestServer(t, derivmock.Config{
+		Ticks:        3,
+		TickInterval: 10 * time.Millisecond,
+		Tokens:       []string{"test-token"},
+	})
+	cfg := &config.Config{
+		DerivAppID:    "1",
+		DerivWSURL:    url,
+		DerivLanguage: "en",
+		DerivOrigin:   "https://gamehub.local",
+		DerivSymbol:   "R_50",
+	}
+	return mock, newDerivAccount("acct-1", "test-token", cfg)
+}
+
+func mockOrder() tradeOrder {
+	return tradeOrder{
+		SessionID:  "session-1",
+		UserID:     "user-1",
+		GameType:   "DUAL_DIMENSION_FLIP",
+		StakeUsd:   10,
+		Prediction: map[string]interface{}{"direction": "PUT", "durationTicks": 3},
+		TraceID:    "trace-1",
+	}
+}
+
+func TestExecuteSettlesScriptedOutcomes(t *testing.T) {
+	mock, account := newMockAccount(t)
+	mock.QueueOutcome(
+		derivmock.Outcome{Result: "WIN"},
+		derivmock.Outcome{Result: "LOSS"},
+		derivmock.Outcome{Result: "REFUND"},
+	)
+
+	want := []struct {
+		outcome string
+		payout  float64
+	}{
+		{"WIN", 19.5},
+		{"LOSS", 0},
+		{"REFUND", 10},
+	}
+	for _, w := range want {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		settlement, err := account.execute(ctx, mockOrder(), nil)
+		cancel()
+		if err != nil {
+			t.Fatalf("execute: %v", err)
+		}
+		if settlement.Outcome != w.outcome || settlement.PayoutUsd != w.payout {
+			t.Fatalf("settlement = %s/%.2f, want %s/%.2f", settlement.Outcome, settlement.PayoutUsd, w.outcome, w.payout)
+		}
+		if settlement.ContractID == "" {
+			t.Fatal("expected a contract id")
+		}
+	}
+
+	proposals := mock.Requests("proposal")
+	if len(proposals) != 3 {
+		t.Fatalf("expected 3 proposals, got %d", len(proposals))
+	}
+	if got := proposals[0]["contract_type"]; got != "PUT" {
+		t.Fatalf("contract_type = %v, want PUT", got)
+	}
+	if got := proposals[0]["duration_unit"]; got != "t" {
+		t.Fatalf("duration_unit = %v, want t", got)
+	}
+}
+
+func TestExecuteSellsOnCashout(t *testing.T) {
+	mock, account := newMockAccount(t)
+	mock.QueueOutcome(derivmock.Outcome{Result: "LOSS", Ticks: 500, SellFor: 14.25})
+
+	active := &activeTrade{order: mockOrder(), cashoutCh: make(chan cashoutRequest, 1)}
+	active.cashoutCh <- cashoutRequest{SessionID: "session-1", TraceID: "trace-1", Multiplier: 1.4}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	settlement, err := account.execute(ctx, mockOrder(), active)
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if settlement.Outcome != "WIN" || settlement.PayoutUsd != 14.25 {
+		t.Fatalf("settlement = %s/%.2f, want WIN/14.25", settlement.Outcome, settlement.PayoutUsd)
+	}
+	if len(mock.Requests("sell")) != 1 {
+		t.Fatalf("expected one sell request, got %d", len(mock.Requests("sell")))
+	}
+}
+
+func TestExecuteMultiplierOmitsDuration(t *testing.T) {
+	mock, account := newMockAccount(t)
+	mock.QueueOutcome(derivmock.Outcome{Result: "WIN"})
+
+	order := mockOrder()
+	order.GameType = "VELOCITY_VECTOR"
+	order.Prediction = map[string]interface{}{"direction": "UP", "multiplier": 100, "durationTicks": 5}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := account.execute(ctx, order, nil); err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if _, ok := mock.Requests("proposal")[0]["duration"]; ok {
+		t.Fatal("expected multiplier proposal without a duration")
+	}
+}
+
+func TestExecuteSurfacesInjectedErrors(t *testing.T) {
+	mock, account := newMockAccount(t)
+	mock.FailNext("buy", "InsufficientBalance", "Your account balance is insufficient.")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := account.execute(ctx, mockOrder(), nil)
+	if err == nil || !strings.Contains(err.Error(), "deriv buy") {
+		t.Fatalf("expected deriv buy error, got %v", err)
+	}
+
+	mock.DisconnectNext("proposal")
+	if _, err := account.execute(ctx, mockOrder(), nil); err == nil {
+		t.Fatal("expected error after disconnect")
+	}
+}
+
+func TestExecuteRejectsUnknownToken(t *testing.T) {
+	_, account := newMockAccount(t)
+	account.token = "wrong"
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := account.execute(ctx, mockOrder(), nil)
+	if err == nil || !strings.Contains(err.Error(), "deriv authorize") {
+		t.Fatalf("expected authorize error, got %v", err)
+	}
+}
+
+func TestRecoverSellsContractLeftOpen(t *testing.T) {
+	mock, account := newMockAccount(t)
+	mock.QueueOutcome(derivmock.Outcome{Result: "WIN", Ticks: 500, SellFor: 12})
+	provider := &derivProvider{accounts: []*derivAccount{account}}
+
+	active := &activeTrade{order: mockOrder(), cashoutCh: make(chan cashoutRequest, 1)}
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	_, err := provider.Execute(ctx, mockOrder(), active)
+	cancel()
+	if err == nil {
+		t.Fatal("expected execute to time out")
+	}
+	if active.contractID == 0 || active.accountID != "acct-1" {
+		t.Fatalf("expected contract to be tracked, got id=%d account=%q", active.contractID, active.accountID)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	settlement, err := provider.Recover(ctx, mockOrder(), active, err)
+	if err != nil {
+		t.Fatalf("recover: %v", err)
+	}
+	if settlement.Outcome != "WIN" || settlement.PayoutUsd != 12 {
+		t.Fatalf("settlement = %s/%.2f, want WIN/12.00", settlement.Outcome, settlement.PayoutUsd)
+	}
+}
+
+func TestRecoverRefundsWithoutContract(t *testing.T) {
+	_, account := newMockAccount(t)
+	provider := &derivProvider{accounts: []*derivAccount{account}}
+
+	settlement, err := provider.Recover(context.Background(), mockOrder(), &activeTrade{}, context.DeadlineExceeded)
+	if err != nil {
+		t.Fatalf("recover: %v", err)
+	}
+	if settlement.Outcome != "REFUND" || settlement.PayoutUsd != 10 {
+		t.Fatalf("settlement = %s/%.2f, want REFUND/10.00", settlement.Outcome, settlement.PayoutUsd)
+	}
+}
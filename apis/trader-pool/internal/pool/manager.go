@@ -3,7 +3,6 @@ package pool
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"log"
 	"math/rand"
@@ -22,8 +21,7 @@ type Manager struct {
 	rdb             *redis.Client
 	wallet          *wallet.Client
 	cfg             *config.Config
-	accounts        []*derivAccount
-	simulate        bool
+	router          *providerRouter
 	rng             *rand.Rand
 	bounceTracker   *BounceTracker
 	activeMu        sync.Mutex
@@ -38,6 +36,8 @@ type tradeOrder struct {
 	StakeUsd   float64                `json:"stakeUsd"`
 	Prediction map[string]interface{} `json:"prediction"`
 	TraceID    string                 `json:"traceId"`
+	// Quote holds the terms the player accepted via GET_QUOTE, if any.
+	Quote *Quote `json:"quote,omitempty"`
 }
 
 type tradeSettlement struct {
@@ -57,7 +57,12 @@ type cashoutRequest struct {
 
 type activeTrade struct {
 	order     tradeOrder
+	provider  SettlementProvider
 	cashoutCh chan cashoutRequest
+	// accountID and contractID are set by the Deriv provider once a contract
+	// is bought, so Recover can find it after a failed Execute.
+	accountID  string
+	contractID int
 }
 
 func NewManager(rdb *redis.Client, walletClient *wallet.Client, cfg *config.Config) *Manager {
@@ -77,21 +82,10 @@ func NewManager(rdb *redis.Client, walletClient *wallet.Client, cfg *config.Conf
 			cfg.BounceRate*100, cfg.ProfitTargetUsd)
 	}
 
-	if len(cfg.DerivTokens) == 0 || cfg.DerivAppID == "" {
-		log.Println("⚠️  Deriv credentials missing — trader-pool running in simulation mode")
-		mgr.simulate = true
-		return mgr
-	}
-
-	for idx, token := range cfg.DerivTokens {
-		accountID := fmt.Sprintf("acct-%d", idx+1)
-		mgr.accounts = append(mgr.accounts, newDerivAccount(accountID, token, cfg))
-	}
-	if len(mgr.accounts) == 0 {
-		log.Println("⚠️  No Deriv accounts initialised; falling back to simulation mode")
-		mgr.simulate = true
-	}
-
+	mgr.router = newProviderRouter(cfg,
+		newSimulationProvider(cfg, rng),
+		newDerivProvider(cfg),
+	)
 	return mgr
 }
 
@@ -140,75 +134,25 @@ func (m *Manager) processOrder(order tradeOrder) {
 		return
 	}
 
-	if m.simulate {
-		active := m.registerActive(order)
-		defer m.unregisterActive(order.SessionID)
-		m.simulateOrder(order, active)
-		return
-	}
-
-	account := m.selectAccount()
-	if account == nil {
-		log.Printf("[trace=%s] no Deriv account available, issuing refund", order.TraceID)
-		m.refundOrder(order, errors.New("no deriv accounts"))
-		return
-	}
-
-	active := m.registerActive(order)
+	provider := m.router.route(order)
+	active := m.registerActive(order, provider)
 	defer m.unregisterActive(order.SessionID)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
-	defer cancel()
-	settlement, err := account.execute(ctx, order, active)
+	settlement, err := provider.Execute(ctx, order, active)
+	cancel()
 	if err != nil {
-		log.Printf("[trace=%s][%s] deriv execution failed: %v", order.TraceID, account.id, err)
-		m.refundOrder(order, err)
-		return
-	}
-	if err := m.finalize(order, settlement); err != nil {
-		log.Printf("[trace=%s] finalize failed: %v", order.TraceID, err)
-	}
-}
-
-func (m *Manager) simulateOrder(order tradeOrder, active *activeTrade) {
-	delay := m.randomDelay()
-	timer := time.NewTimer(delay)
-	defer timer.Stop()
-
-	select {
-	case req := <-active.cashoutCh:
-		multiplier := req.Multiplier
-		if multiplier < 1.01 {
-			multiplier = 1.01
-		}
-		if multiplier > m.cfg.PayoutMultiplier {
-			multiplier = m.cfg.PayoutMultiplier
-		}
-		settlement := &tradeSettlement{
-			Outcome:    "WIN",
-			PayoutUsd:  order.StakeUsd * multiplier,
-			ContractID: "SIMULATED_CASHOUT",
-		}
-		if err := m.finalize(order, settlement); err != nil {
-			log.Printf("[trace=%s] simulated cashout finalize failed: %v", order.TraceID, err)
+		log.Printf("[trace=%s][%s] execution failed: %v", order.TraceID, provider.Name(), err)
+		recoverCtx, recoverCancel := context.WithTimeout(context.Background(), 30*time.Second)
+		settlement, err = provider.Recover(recoverCtx, order, active, err)
+		recoverCancel()
+		if err != nil {
+			m.refundOrder(order, err)
+			return
 		}
-		return
-	case <-timer.C:
-	}
-
-	outcome := "LOSS"
-	payout := 0.0
-	if m.rng.Intn(100) < 45 {
-		outcome = "WIN"
-		payout = order.StakeUsd * m.cfg.PayoutMultiplier
-	}
-	settlement := &tradeSettlement{
-		Outcome:    outcome,
-		PayoutUsd:  payout,
-		ContractID: "SIMULATED",
 	}
 	if err := m.finalize(order, settlement); err != nil {
-		log.Printf("[trace=%s] simulated finalize failed: %v", order.TraceID, err)
+		log.Printf("[trace=%s] finalize failed: %v", order.TraceID, err)
 	}
 }
 
@@ -244,9 +188,10 @@ func (m *Manager) cashoutQueue() string {
 	return m.cfg.OrderQueue + ":cashout"
 }
 
-func (m *Manager) registerActive(order tradeOrder) *activeTrade {
+func (m *Manager) registerActive(order tradeOrder, provider SettlementProvider) *activeTrade {
 	active := &activeTrade{
 		order:     order,
+		provider:  provider,
 		cashoutCh: make(chan cashoutRequest, 1),
 	}
 	m.activeMu.Lock()
@@ -257,11 +202,9 @@ func (m *Manager) registerActive(order tradeOrder) *activeTrade {
 	}
 	m.activeMu.Unlock()
 	if hasPending {
-		select {
-		case active.cashoutCh <- pending:
+		if err := provider.Cashout(active, pending); err == nil {
 			log.Printf("[trace=%s] applied pending cashout session=%s multiplier=%.2f",
 				pending.TraceID, pending.SessionID, pending.Multiplier)
-		default:
 		}
 	}
 	return active
@@ -285,13 +228,12 @@ func (m *Manager) requestCashout(req cashoutRequest) {
 		go m.expirePendingCashout(req)
 		return
 	}
-	select {
-	case active.cashoutCh <- req:
-		log.Printf("[trace=%s] cashout requested session=%s multiplier=%.2f",
-			req.TraceID, req.SessionID, req.Multiplier)
-	default:
-		log.Printf("[trace=%s] duplicate cashout ignored session=%s", req.TraceID, req.SessionID)
+	if err := active.provider.Cashout(active, req); err != nil {
+		log.Printf("[trace=%s] cashout ignored session=%s: %v", req.TraceID, req.SessionID, err)
+		return
 	}
+	log.Printf("[trace=%s] cashout requested session=%s multiplier=%.2f",
+		req.TraceID, req.SessionID, req.Multiplier)
 }
 
 func (m *Manager) expirePendingCashout(req cashoutRequest) {
@@ -325,12 +267,7 @@ func (m *Manager) bouncedSettle(order tradeOrder) {
 
 func (m *Manager) refundOrder(order tradeOrder, cause error) {
 	log.Printf("[trace=%s] refunding session=%s: %v", order.TraceID, order.SessionID, cause)
-	settlement := &tradeSettlement{
-		Outcome:    "REFUND",
-		PayoutUsd:  order.StakeUsd,
-		ContractID: "REFUND",
-	}
-	if err := m.finalize(order, settlement); err != nil {
+	if err := m.finalize(order, refundSettlement(order)); err != nil {
 		log.Printf("[trace=%s] refund finalize failed: %v", order.TraceID, err)
 	}
 }
@@ -340,8 +277,7 @@ func (m *Manager) finalize(order tradeOrder, settlement *tradeSettlement) error
 	defer cancel()
 
 	// Apply win rake: deduct a % of net profit before crediting the user.
-	// This runs on every WIN regardless of whether the bet was settled by
-	// Deriv, simulated, or any future provider.
+	// This runs on every WIN regardless of which SettlementProvider settled it.
 	rakeAmount := 0.0
 	if strings.EqualFold(settlement.Outcome, "WIN") && m.cfg.WinRakeRate > 0 {
 		profit := settlement.PayoutUsd - order.StakeUsd
@@ -399,24 +335,16 @@ func (m *Manager) finalize(order tradeOrder, settlement *tradeSettlement) error
 	return nil
 }
 
-func (m *Manager) selectAccount() *derivAccount {
-	if len(m.accounts) == 0 {
-		return nil
-	}
-	var best *derivAccount
-	for _, acc := range m.accounts {
-		if best == nil || acc.inFlight() < best.inFlight() {
-			best = acc
-		}
-	}
-	return best
+func (m *Manager) randomDelay() time.Duration {
+	return randomDelay(m.cfg, m.rng)
 }
 
-func (m *Manager) randomDelay() time.Duration {
-	min := m.cfg.MinSettleMs
-	max := m.cfg.MaxSettleMs
+// randomDelay returns a realistic settlement delay within the configured bounds.
+func randomDelay(cfg *config.Config, rng *rand.Rand) time.Duration {
+	min := cfg.MinSettleMs
+	max := cfg.MaxSettleMs
 	if max <= min {
 		max = min + 1000
 	}
-	return time.Duration(min+m.rng.Intn(max-min)) * time.Millisecond
+	return time.Duration(min+rng.Intn(max-min)) * time.Millisecond
 }
@@ -0,0 +1,97 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+
+	"gamehub/trader-pool/internal/config"
+)
+
+const (
+	providerDeriv      = "deriv"
+	providerSimulation = "simulation"
+)
+
+var errDuplicateCashout = errors.New("cashout already requested")
+
+// SettlementProvider settles trade orders. The order loop only decides whether
+// to bounce and which provider to route to; everything else lives here.
+type SettlementProvider interface {
+	// Name identifies the provider in routing rules and logs.
+	Name() string
+	// Execute places the order and blocks until it settles. Cashout requests
+	// forwarded through Cashout arrive on active.cashoutCh while it runs.
+	Execute(ctx context.Context, order tradeOrder, active *activeTrade) (*tradeSettlement, error)
+	// Cashout hands an early-exit request to an order that is executing.
+	Cashout(active *activeTrade, req cashoutRequest) error
+	// Recover resolves an order whose Execute failed, e.g. by looking up a
+	// contract that was bought before the connection dropped. Returning an
+	// error makes the order loop refund the stake.
+	Recover(ctx context.Context, order tradeOrder, active *activeTrade, cause error) (*tradeSettlement, error)
+}
+
+// providerRouter picks a SettlementProvider per GameType, falling back to the
+// configured default provider.
+type providerRouter struct {
+	providers map[string]SettlementProvider
+	routes    map[string]string
+	fallback  string
+}
+
+func newProviderRouter(cfg *config.Config, providers ...SettlementProvider) *providerRouter {
+	r := &providerRouter{
+		providers: make(map[string]SettlementProvider),
+		routes:    make(map[string]string),
+	}
+	for _, p := range providers {
+		if p != nil {
+			r.providers[p.Name()] = p
+		}
+	}
+
+	r.fallback = strings.ToLower(cfg.SettlementProvider)
+	if r.fallback == "" {
+		r.fallback = providerDeriv
+	}
+	if _, ok := r.providers[r.fallback]; !ok {
+		log.Printf("⚠️  Settlement provider %q unavailable — defaulting to %s", r.fallback, providerSimulation)
+		r.fallback = providerSimulation
+	}
+
+	for gameType, name := range cfg.SettlementRoutes {
+		name = strings.ToLower(name)
+		if _, ok := r.providers[name]; !ok {
+			log.Printf("⚠️  Ignoring settlement route %s=%s: provider unavailable", gameType, name)
+			continue
+		}
+		r.routes[strings.ToUpper(gameType)] = name
+	}
+	return r
+}
+
+func (r *providerRouter) route(order tradeOrder) SettlementProvider {
+	if name, ok := r.routes[strings.ToUpper(order.GameType)]; ok {
+		return r.providers[name]
+	}
+	return r.providers[r.fallback]
+}
+
+// forwardCashout delivers a cashout to the executing order without blocking.
+func forwardCashout(active *activeTrade, req cashoutRequest) error {
+	select {
+	case active.cashoutCh <- req:
+		return nil
+	default:
+		return errDuplicateCashout
+	}
+}
+
+func refundSettlement(order tradeOrder) *tradeSettlement {
+	return &tradeSettlement{
+		Outcome:    "REFUND",
+		PayoutUsd:  order.StakeUsd,
+		ContractID: "REFUND",
+	}
+}
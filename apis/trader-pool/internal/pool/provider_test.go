@@ -0,0 +1,69 @@
+package pool
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+
+	"gamehub/trader-pool/internal/config"
+)
+
+type stubProvider struct{ name string }
+
+func (p *stubProvider) Name() string { return p.name }
+
+func (p *stubProvider) Execute(ctx context.Context, order tradeOrder, active *activeTrade) (*tradeSettlement, error) {
+	return &tradeSettlement{Outcome: "LOSS", ContractID: p.name}, nil
+}
+
+func (p *stubProvider) Cashout(active *activeTrade, req cashoutRequest) error {
+	return forwardCashout(active, req)
+}
+
+func (p *stubProvider) Recover(ctx context.Context, order tradeOrder, active *activeTrade, cause error) (*tradeSettlement, error) {
+	return refundSettlement(order), nil
+}
+
+func TestRouterRoutesByGameType(t *testing.T) {
+	cfg := &config.Config{
+		SettlementProvider: "deriv",
+		SettlementRoutes:   map[string]string{"DIGIT_DASH": "rng", "NEON_PERIMETER": "missing"},
+	}
+	router := newProviderRouter(cfg,
+		newSimulationProvider(cfg, rand.New(rand.NewSource(1))),
+		&stubProvider{name: "deriv"},
+		&stubProvider{name: "rng"},
+	)
+
+	cases := map[string]string{
+		"digit_dash":      "rng",
+		"NEON_PERIMETER":  "deriv",
+		"VELOCITY_VECTOR": "deriv",
+	}
+	for gameType, want := range cases {
+		if got := router.route(tradeOrder{GameType: gameType}).Name(); got != want {
+			t.Fatalf("route(%s) = %s, want %s", gameType, got, want)
+		}
+	}
+}
+
+func TestRouterFallsBackToSimulationWithoutDeriv(t *testing.T) {
+	cfg := &config.Config{SettlementProvider: "deriv"}
+	router := newProviderRouter(cfg,
+		newSimulationProvider(cfg, rand.New(rand.NewSource(1))),
+		newDerivProvider(cfg),
+	)
+	if got := router.route(tradeOrder{GameType: "DIGIT_DASH"}).Name(); got != providerSimulation {
+		t.Fatalf("route() = %s, want %s", got, providerSimulation)
+	}
+}
+
+func TestForwardCashoutRejectsDuplicates(t *testing.T) {
+	active := &activeTrade{cashoutCh: make(chan cashoutRequest, 1)}
+	if err := forwardCashout(active, cashoutRequest{SessionID: "s"}); err != nil {
+		t.Fatalf("first cashout: %v", err)
+	}
+	if err := forwardCashout(active, cashoutRequest{SessionID: "s"}); err != errDuplicateCashout {
+		t.Fatalf("second cashout err = %v, want %v", err, errDuplicateCashout)
+	}
+}
@@ -0,0 +1,207 @@
+package pool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ksysoev/deriv-api"
+	"github.com/ksysoev/deriv-api/schema"
+
+	"gamehub/trader-pool/internal/config"
+)
+
+var (
+	ErrInvalidQuote     = errors.New("stake must be greater than zero")
+	ErrQuoteUnavailable = errors.New("quotes are not available for this game")
+	errPriceMoved       = errors.New("price moved beyond tolerance")
+)
+
+// QuoteRequest asks for the terms an order would be placed at.
+type QuoteRequest struct {
+	UserID     string                 `json:"userId"`
+	GameType   string                 `json:"gameType"`
+	StakeUsd   float64                `json:"stakeUsd"`
+	Prediction map[string]interface{} `json:"prediction"`
+	TraceID    string                 `json:"traceId,omitempty"`
+}
+
+// Quote is a short-lived preview of contract terms. game-session stores it in
+// Redis and embeds it in the order when PLACE_BET references its QuoteID.
+type Quote struct {
+	QuoteID      string                 `json:"quoteId"`
+	UserID       string                 `json:"userId"`
+	GameType     string                 `json:"gameType"`
+	StakeUsd     float64                `json:"stakeUsd"`
+	Prediction   map[string]interface{} `json:"prediction,omitempty"`
+	Provider     string                 `json:"provider"`
+	ContractType string                 `json:"contractType,omitempty"`
+	Symbol       string                 `json:"symbol,omitempty"`
+	AskPrice     float64                `json:"askPrice"`
+	PayoutUsd    float64                `json:"payoutUsd"`
+	Spot         float64                `json:"spot,omitempty"`
+	Barrier      string                 `json:"barrier,omitempty"`
+	Barrier2     string                 `json:"barrier2,omitempty"`
+	Longcode     string                 `json:"longcode,omitempty"`
+	ExpiresAt    int64                  `json:"expiresAt"`
+}
+
+// quoter is implemented by providers that can price an order up front.
+type quoter interface {
+	Quote(ctx context.Context, order tradeOrder) (*Quote, error)
+}
+
+// Quote prices an order with the provider it would be routed to and stores
+// the result under QuotePrefix:<quoteId> for QuoteTTLSec.
+func (m *Manager) Quote(ctx context.Context, req QuoteRequest) (*Quote, error) {
+	if req.StakeUsd <= 0 {
+		return nil, ErrInvalidQuote
+	}
+	if req.Prediction == nil {
+		req.Prediction = map[string]interface{}{}
+	}
+	order := tradeOrder{
+		UserID:     req.UserID,
+		GameType:   req.GameType,
+		StakeUsd:   req.StakeUsd,
+		Prediction: req.Prediction,
+		TraceID:    req.TraceID,
+	}
+	provider := m.router.route(order)
+	q, ok := provider.(quoter)
+	if !ok {
+		return nil, ErrQuoteUnavailable
+	}
+	quote, err := q.Quote(ctx, order)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := time.Duration(m.cfg.QuoteTTLSec) * time.Second
+	quote.QuoteID = uuid.NewString()
+	quote.UserID = req.UserID
+	quote.GameType = req.GameType
+	quote.StakeUsd = req.StakeUsd
+	quote.Prediction = req.Prediction
+	quote.Provider = provider.Name()
+	quote.ExpiresAt = time.Now().Add(ttl).UnixMilli()
+
+	payload, _ := json.Marshal(quote)
+	if err := m.rdb.Set(ctx, m.cfg.QuotePrefix+":"+quote.QuoteID, payload, ttl).Err(); err != nil {
+		return nil, fmt.Errorf("store quote: %w", err)
+	}
+	return quote, nil
+}
+
+// checkQuote rejects a fresh proposal whose terms moved against the player by
+// more than tolerance since the quote was issued.
+func checkQuote(quote *Quote, proposal *schema.ProposalRespProposal, tolerance float64) error {
+	if quote == nil || proposal == nil {
+		return nil
+	}
+	if quote.PayoutUsd > 0 && proposal.Payout < quote.PayoutUsd*(1-tolerance) {
+		return fmt.Errorf("%w: payout %.2f -> %.2f", errPriceMoved, quote.PayoutUsd, proposal.Payout)
+	}
+	if quote.AskPrice > 0 && proposal.AskPrice > quote.AskPrice*(1+tolerance) {
+		return fmt.Errorf("%w: ask %.2f -> %.2f", errPriceMoved, quote.AskPrice, proposal.AskPrice)
+	}
+	return nil
+}
+
+// derivQuotePool keeps one unauthenticated Deriv connection open for pricing.
+// Proposals need no account, so quotes never compete with trades for tokens.
+type derivQuotePool struct {
+	cfg *config.Config
+	mu  sync.Mutex
+	api *deriv.Client
+}
+
+func newDerivQuotePool(cfg *config.Config) *derivQuotePool {
+	return &derivQuotePool{cfg: cfg}
+}
+
+func (p *derivQuotePool) client() (*deriv.Client, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.api != nil {
+		return p.api, nil
+	}
+	appID, err := strconv.Atoi(p.cfg.DerivAppID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DERIV_APP_ID: %w", err)
+	}
+	api, err := deriv.NewDerivAPI(p.cfg.DerivWSURL, appID, p.cfg.DerivLanguage, p.cfg.DerivOrigin, deriv.KeepAlive)
+	if err != nil {
+		return nil, fmt.Errorf("deriv connect: %w", err)
+	}
+	p.api = api
+	return api, nil
+}
+
+// drop discards a connection after an error so the next quote reconnects.
+func (p *derivQuotePool) drop(api *deriv.Client) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.api == api {
+		p.api = nil
+	}
+	api.Disconnect()
+}
+
+func (p *derivQuotePool) proposal(ctx context.Context, req schema.Proposal) (*schema.ProposalRespProposal, error) {
+	api, err := p.client()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := api.Proposal(ctx, req)
+	if err != nil {
+		var apiErr *deriv.APIError
+		if !errors.As(err, &apiErr) {
+			p.drop(api)
+		}
+		return nil, fmt.Errorf("deriv proposal: %w", err)
+	}
+	if resp.Proposal == nil {
+		return nil, fmt.Errorf("deriv proposal missing payload")
+	}
+	return resp.Proposal, nil
+}
+
+func (p *derivProvider) Quote(ctx context.Context, order tradeOrder) (*Quote, error) {
+	req, err := buildDerivProposal(order, p.cfg)
+	if err != nil {
+		return nil, err
+	}
+	proposal, err := p.quotes.proposal(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	quote := &Quote{
+		ContractType: string(req.ContractType),
+		Symbol:       req.Symbol,
+		AskPrice:     proposal.AskPrice,
+		PayoutUsd:    proposal.Payout,
+		Spot:         proposal.Spot,
+		Longcode:     proposal.Longcode,
+	}
+	if req.Barrier != nil {
+		quote.Barrier = *req.Barrier
+	}
+	if req.Barrier2 != nil {
+		quote.Barrier2 = *req.Barrier2
+	}
+	return quote, nil
+}
+
+func (p *simulationProvider) Quote(ctx context.Context, order tradeOrder) (*Quote, error) {
+	return &Quote{
+		AskPrice:  order.StakeUsd,
+		PayoutUsd: math.Round(order.StakeUsd*p.cfg.PayoutMultiplier*100) / 100,
+	}, nil
+}
@@ -0,0 +1,65 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gamehub/trader-pool/internal/derivmock"
+)
+
+func TestDerivQuoteUsesProposal(t *testing.T) {
+	mock, account := newMockAccount(t)
+	provider := &derivProvider{cfg: account.cfg, quotes: newDerivQuotePool(account.cfg)}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for i := 0; i < 2; i++ {
+		quote, err := provider.Quote(ctx, mockOrder())
+		if err != nil {
+			t.Fatalf("quote: %v", err)
+		}
+		if quote.ContractType != "PUT" || quote.AskPrice != 10 || quote.PayoutUsd != 19.5 {
+			t.Fatalf("quote = %s ask=%.2f payout=%.2f, want PUT ask=10.00 payout=19.50", quote.ContractType, quote.AskPrice, quote.PayoutUsd)
+		}
+	}
+	if len(mock.Requests("authorize")) != 0 {
+		t.Fatal("quotes should not authorize")
+	}
+
+	mock.DisconnectNext("proposal")
+	if _, err := provider.Quote(ctx, mockOrder()); err == nil {
+		t.Fatal("expected error after disconnect")
+	}
+	if _, err := provider.Quote(ctx, mockOrder()); err != nil {
+		t.Fatalf("quote after reconnect: %v", err)
+	}
+}
+
+func TestExecuteRejectsMovedQuote(t *testing.T) {
+	mock, account := newMockAccount(t)
+	account.cfg.QuoteTolerance = 0.02
+	mock.QueueOutcome(derivmock.Outcome{Result: "WIN"})
+
+	order := mockOrder()
+	order.Quote = &Quote{QuoteID: "q-1", AskPrice: 10, PayoutUsd: 21}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := account.execute(ctx, order, nil); !errors.Is(err, errPriceMoved) {
+		t.Fatalf("expected errPriceMoved, got %v", err)
+	}
+	if len(mock.Requests("buy")) != 0 {
+		t.Fatal("expected no buy after price moved")
+	}
+
+	order.Quote.PayoutUsd = 19.8
+	settlement, err := account.execute(ctx, order, nil)
+	if err != nil {
+		t.Fatalf("execute within tolerance: %v", err)
+	}
+	if settlement.Outcome != "WIN" {
+		t.Fatalf("outcome = %s, want WIN", settlement.Outcome)
+	}
+}
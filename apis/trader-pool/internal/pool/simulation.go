@@ -0,0 +1,77 @@
+package pool
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"gamehub/trader-pool/internal/config"
+)
+
+// simulationProvider settles orders locally with a fixed win probability. It
+// is the fallback whenever Deriv credentials are missing.
+type simulationProvider struct {
+	cfg *config.Config
+	rng *rand.Rand
+}
+
+func newSimulationProvider(cfg *config.Config, rng *rand.Rand) *simulationProvider {
+	return &simulationProvider{cfg: cfg, rng: rng}
+}
+
+func (p *simulationProvider) Name() string {
+	return providerSimulation
+}
+
+func (p *simulationProvider) Execute(ctx context.Context, order tradeOrder, active *activeTrade) (*tradeSettlement, error) {
+	timer := time.NewTimer(randomDelay(p.cfg, p.rng))
+	defer timer.Stop()
+
+	var cashoutCh <-chan cashoutRequest
+	if active != nil {
+		cashoutCh = active.cashoutCh
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case req := <-cashoutCh:
+		multiplier := req.Multiplier
+		if multiplier < 1.01 {
+			multiplier = 1.01
+		}
+		if multiplier > p.cfg.PayoutMultiplier {
+			multiplier = p.cfg.PayoutMultiplier
+		}
+		return &tradeSettlement{
+			Outcome:    "WIN",
+			PayoutUsd:  order.StakeUsd * multiplier,
+			ContractID: "SIMULATED_CASHOUT",
+		}, nil
+	case <-timer.C:
+	}
+
+	outcome := "LOSS"
+	payout := 0.0
+	if p.rng.Intn(100) < 45 {
+		outcome = "WIN"
+		payout = order.StakeUsd * p.cfg.PayoutMultiplier
+		if order.Quote != nil && order.Quote.PayoutUsd > 0 {
+			payout = order.Quote.PayoutUsd
+		}
+	}
+	return &tradeSettlement{
+		Outcome:    outcome,
+		PayoutUsd:  payout,
+		ContractID: "SIMULATED",
+	}, nil
+}
+
+func (p *simulationProvider) Cashout(active *activeTrade, req cashoutRequest) error {
+	return forwardCashout(active, req)
+}
+
+// Recover refunds: a simulated order has no external state to look up.
+func (p *simulationProvider) Recover(ctx context.Context, order tradeOrder, active *activeTrade, cause error) (*tradeSettlement, error) {
+	return refundSettlement(order), nil
+}
@@ -0,0 +1,185 @@
+// Package ticks streams live Deriv prices to game clients. One Deriv
+// subscription per symbol is fanned out over Redis pub/sub, and a short
+// rolling history is kept so new subscribers can draw a chart immediately.
+package ticks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/ksysoev/deriv-api"
+	"github.com/ksysoev/deriv-api/schema"
+	"github.com/redis/go-redis/v9"
+
+	"gamehub/trader-pool/internal/config"
+)
+
+// Tick is the payload published on <prefix>:<symbol> and stored in
+// <prefix>:<symbol>:history (newest first).
+type Tick struct {
+	Symbol  string  `json:"symbol"`
+	Quote   float64 `json:"quote"`
+	Epoch   int64   `json:"epoch"`
+	PipSize int     `json:"pipSize"`
+	Source  string  `json:"source"`
+}
+
+type Feed struct {
+	rdb *redis.Client
+	cfg *config.Config
+}
+
+func NewFeed(rdb *redis.Client, cfg *config.Config) *Feed {
+	return &Feed{rdb: rdb, cfg: cfg}
+}
+
+// Start runs one stream per configured symbol until ctx is cancelled. Without
+// Deriv credentials it publishes a synthetic random walk instead.
+func (f *Feed) Start(ctx context.Context) {
+	if len(f.cfg.TickSymbols) == 0 {
+		return
+	}
+	simulate := f.cfg.DerivAppID == ""
+	if simulate {
+		log.Println("⚠️  DERIV_APP_ID missing — tick feed publishing synthetic prices")
+	}
+	for _, symbol := range f.cfg.TickSymbols {
+		if simulate {
+			go f.simulate(ctx, symbol)
+			continue
+		}
+		go f.run(ctx, symbol)
+	}
+}
+
+// run keeps a Deriv tick subscription for symbol alive, reconnecting with
+// backoff whenever the stream drops.
+func (f *Feed) run(ctx context.Context, symbol string) {
+	backoff := time.Second
+	for {
+		started := time.Now()
+		err := f.stream(ctx, symbol, func(t Tick) { f.publish(ctx, t) })
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Since(started) > time.Minute {
+			backoff = time.Second
+		}
+		log.Printf("[ticks][%s] stream ended: %v (reconnecting in %s)", symbol, err, backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+// stream subscribes to symbol on Deriv and calls emit for every tick until the
+// stream closes or ctx is cancelled.
+func (f *Feed) stream(ctx context.Context, symbol string, emit func(Tick)) error {
+	appID, err := strconv.Atoi(f.cfg.DerivAppID)
+	if err != nil {
+		return fmt.Errorf("invalid DERIV_APP_ID: %w", err)
+	}
+	api, err := deriv.NewDerivAPI(f.cfg.DerivWSURL, appID, f.cfg.DerivLanguage, f.cfg.DerivOrigin, deriv.KeepAlive)
+	if err != nil {
+		return fmt.Errorf("deriv connect: %w", err)
+	}
+	defer api.Disconnect()
+
+	first, sub, err := api.SubscribeTicks(ctx, schema.Ticks{Ticks: symbol})
+	if err != nil {
+		return fmt.Errorf("deriv ticks: %w", err)
+	}
+	defer sub.Forget()
+	log.Printf("[ticks][%s] subscribed to Deriv ticks", symbol)
+
+	if t, ok := fromDeriv(symbol, first.Tick); ok {
+		emit(t)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case msg, ok := <-sub.Stream:
+			if !ok {
+				return fmt.Errorf("deriv stream closed")
+			}
+			if t, ok := fromDeriv(symbol, msg.Tick); ok {
+				emit(t)
+			}
+		}
+	}
+}
+
+func (f *Feed) simulate(ctx context.Context, symbol string) {
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	quote := 1000.0
+	interval := time.Duration(f.cfg.TickIntervalMs) * time.Millisecond
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			quote += (rng.Float64() - 0.5) * quote * 0.001
+			f.publish(ctx, Tick{
+				Symbol:  symbol,
+				Quote:   math.Round(quote*10000) / 10000,
+				Epoch:   now.Unix(),
+				PipSize: 4,
+				Source:  "simulated",
+			})
+		}
+	}
+}
+
+func (f *Feed) publish(ctx context.Context, t Tick) {
+	payload, err := json.Marshal(t)
+	if err != nil {
+		return
+	}
+	channel := f.cfg.TickChannelPrefix + ":" + t.Symbol
+	history := channel + ":history"
+	pipe := f.rdb.TxPipeline()
+	pipe.Publish(ctx, channel, payload)
+	pipe.LPush(ctx, history, payload)
+	pipe.LTrim(ctx, history, 0, int64(f.cfg.TickHistorySize-1))
+	pipe.Expire(ctx, history, time.Hour)
+	if _, err := pipe.Exec(ctx); err != nil && ctx.Err() == nil {
+		log.Printf("[ticks][%s] publish failed: %v", t.Symbol, err)
+	}
+}
+
+func fromDeriv(symbol string, tick *schema.TicksRespTick) (Tick, bool) {
+	if tick == nil || tick.Quote == nil {
+		return Tick{}, false
+	}
+	t := Tick{
+		Symbol:  symbol,
+		Quote:   *tick.Quote,
+		Epoch:   time.Now().Unix(),
+		PipSize: int(tick.PipSize),
+		Source:  "deriv",
+	}
+	if tick.Symbol != nil && *tick.Symbol != "" {
+		t.Symbol = *tick.Symbol
+	}
+	if tick.Epoch != nil {
+		t.Epoch = int64(*tick.Epoch)
+	}
+	return t, true
+}
@@ -0,0 +1,58 @@
+package ticks
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gamehub/trader-pool/internal/config"
+	"gamehub/trader-pool/internal/derivmock"
+)
+
+func TestStreamEmitsDerivTicks(t *testing.T) {
+	mock, url := derivmock.NewTestServer(t, derivmock.Config{TickInterval: 10 * time.Millisecond})
+	feed := NewFeed(nil, &config.Config{
+		DerivAppID:    "1",
+		DerivWSURL:    url,
+		DerivLanguage: "en",
+		DerivOrigin:   "https://gamehub.local",
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var got []Tick
+	err := feed.stream(ctx, "R_50", func(tick Tick) {
+		got = append(got, tick)
+		if len(got) == 3 {
+			cancel()
+		}
+	})
+	if err != context.Canceled {
+		t.Fatalf("stream() err = %v, want %v", err, context.Canceled)
+	}
+	for _, tick := range got {
+		if tick.Symbol != "R_50" || tick.Quote <= 0 || tick.PipSize != 4 || tick.Source != "deriv" {
+			t.Fatalf("unexpected tick %+v", tick)
+		}
+	}
+	if n := len(mock.Requests("ticks")); n != 1 {
+		t.Fatalf("expected a single ticks subscription, got %d", n)
+	}
+}
+
+func TestStreamReportsSubscriptionErrors(t *testing.T) {
+	mock, url := derivmock.NewTestServer(t, derivmock.Config{})
+	mock.FailNext("ticks", "MarketIsClosed", "This market is presently closed.")
+	feed := NewFeed(nil, &config.Config{
+		DerivAppID:    "1",
+		DerivWSURL:    url,
+		DerivLanguage: "en",
+		DerivOrigin:   "https://gamehub.local",
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := feed.stream(ctx, "R_50", func(Tick) {}); err == nil {
+		t.Fatal("expected subscription error")
+	}
+}
@@ -17,11 +17,11 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 
-	"gamehub/wallet-service/internal/auth"
+	"gamehub/pkg/authn"
+	"gamehub/pkg/authn/rbac"
 	"gamehub/wallet-service/internal/config"
 	"gamehub/wallet-service/internal/handler"
 	"gamehub/wallet-service/internal/ledger"
-	"gamehub/wallet-service/internal/middleware"
 )
 
 func main() {
@@ -29,7 +29,7 @@ func main() {
 
 	cfg := config.Load()
 
-	tokenValidator, err := auth.NewValidator(cfg.JWTPublicKeyPath, cfg.JWTIssuer)
+	tokenValidator, err := authn.NewValidator(cfg.JWTPublicKeyPath, cfg.JWKSURL, cfg.JWTIssuer)
 	if err != nil {
 		log.Fatalf("JWT validator init failed: %v", err)
 	}
@@ -55,7 +55,7 @@ func main() {
 		Keys:    bson.D{{Key: "userId", Value: 1}},
 		Options: options.Index().SetUnique(true),
 	})
-	db.Collection("withdrawals").Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+	db.Collection("withdrawal_holds").Indexes().CreateMany(context.Background(), []mongo.IndexModel{
 		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: -1}}},
 		{Keys: bson.D{{Key: "status", Value: 1}}},
 	})
@@ -91,24 +91,32 @@ func main() {
 	// ==========================================================================
 	// PUBLIC ROUTES — require user JWT
 	// ==========================================================================
-	v1 := app.Group("/api/v1/wallet", middleware.RequireAuth(tokenValidator))
+	v1 := app.Group("/api/v1/wallet", authn.RequireAuth(tokenValidator))
 	v1.Get("/balance", h.GetBalance)
 	v1.Get("/ledger", h.GetLedger) // Paginated transaction history
 	v1.Get("/withdrawals", h.GetWithdrawals)
 
+	// Staff views — scope-checked, no INTERNAL_SERVICE_KEY needed
+	admin := v1.Group("/admin", authn.RequireScope(rbac.ScopeWalletRead))
+	admin.Get("/users/:userId/balance", h.AdminGetBalance)
+	admin.Get("/users/:userId/ledger", h.AdminGetLedger)
+
 	// Leaderboard lives here (uses Redis ZSETs populated by game outcomes)
-	app.Get("/api/v1/leaderboard/global", middleware.RequireAuth(tokenValidator), h.GlobalLeaderboard)
-	app.Get("/api/v1/leaderboard/friends", middleware.RequireAuth(tokenValidator), h.FriendsLeaderboard)
+	app.Get("/api/v1/leaderboard/global", authn.RequireAuth(tokenValidator), h.GlobalLeaderboard)
+	app.Get("/api/v1/leaderboard/friends", authn.RequireAuth(tokenValidator), h.FriendsLeaderboard)
 
 	// ==========================================================================
 	// INTERNAL ROUTES — called by payment-gateway and game-session-service
 	// These are protected by an internal service key, NOT a user JWT
 	// ==========================================================================
-	internal := app.Group("/internal", middleware.RequireInternalKey(cfg))
+	internal := app.Group("/internal", authn.RequireInternalKey(cfg.InternalServiceKey))
 
 	// Called by payment-gateway when deposit confirmed
 	internal.Post("/ledger/credit", h.InternalCreditDeposit)
 
+	// Called by payment-gateway when a credited crypto deposit is dropped by a reorg
+	internal.Post("/ledger/reverse-credit", h.InternalReverseCredit)
+
 	// Called by payment-gateway: locks funds before initiating Flutterwave withdrawal
 	internal.Post("/ledger/reserve-withdrawal", h.InternalReserveWithdrawal)
 
@@ -121,6 +129,9 @@ func main() {
 	// Called by trader-pool after Deriv settles a contract
 	internal.Post("/ledger/settle-game", h.InternalSettleGame)
 
+	// Called by auth-service when a guest links an identity owned by another account
+	internal.Post("/ledger/merge-accounts", h.InternalMergeAccounts)
+
 	// --- Graceful Shutdown ---
 	go func() {
 		log.Printf("Wallet service on :%s", cfg.Port)
@@ -19,6 +19,7 @@ type Config struct {
 	AppEnv             string
 	JWTPublicKeyPath   string
 	JWTIssuer          string
+	JWKSURL            string // auth-service JWKS endpoint for rotated keys
 	StartingBalanceUsd float64
 }
 
@@ -33,6 +34,7 @@ func Load() *Config {
 		AppEnv:             getEnv("APP_ENV", "development"),
 		JWTPublicKeyPath:   getEnv("JWT_PUBLIC_KEY_PATH", ""),
 		JWTIssuer:          getEnv("JWT_ISSUER", "gamehub-auth"),
+		JWKSURL:            getEnv("AUTH_JWKS_URL", "http://127.0.0.1:"+getEnv("AUTH_SERVICE_PORT", "8001")+"/.well-known/jwks.json"),
 		StartingBalanceUsd: getEnvFloat("STARTING_BALANCE_USD", 0.0),
 	}
 	return cfg
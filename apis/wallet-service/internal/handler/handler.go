@@ -30,7 +30,15 @@ func New(rdb *redis.Client, svc *ledger.Service, cfg *config.Config) *Handler {
 }
 
 func (h *Handler) GetBalance(c *fiber.Ctx) error {
-	userID := c.Locals("userId").(string)
+	return h.balance(c, c.Locals("userId").(string))
+}
+
+// AdminGetBalance returns any user's balance for support and finance staff.
+func (h *Handler) AdminGetBalance(c *fiber.Ctx) error {
+	return h.balance(c, c.Params("userId"))
+}
+
+func (h *Handler) balance(c *fiber.Ctx, userID string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	bal, err := h.svc.GetBalance(ctx, userID)
@@ -47,7 +55,15 @@ func (h *Handler) GetBalance(c *fiber.Ctx) error {
 }
 
 func (h *Handler) GetLedger(c *fiber.Ctx) error {
-	userID := c.Locals("userId").(string)
+	return h.ledger(c, c.Locals("userId").(string))
+}
+
+// AdminGetLedger returns any user's ledger for support and finance staff.
+func (h *Handler) AdminGetLedger(c *fiber.Ctx) error {
+	return h.ledger(c, c.Params("userId"))
+}
+
+func (h *Handler) ledger(c *fiber.Ctx, userID string) error {
 	limit := parseInt(c.Query("limit"), 25)
 	page := parseInt(c.Query("page"), 1)
 	if limit <= 0 || limit > 100 {
@@ -161,6 +177,31 @@ func (h *Handler) InternalCreditDeposit(c *fiber.Ctx) error {
 	return c.JSON(balanceResponse(bal))
 }
 
+func (h *Handler) InternalReverseCredit(c *fiber.Ctx) error {
+	var body struct {
+		UserID    string `json:"userId"`
+		Reference string `json:"reference"`
+		Reason    string `json:"reason"`
+	}
+	if err := c.BodyParser(&body); err != nil || body.UserID == "" || body.Reference == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid payload"})
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	bal, err := h.svc.ReverseCredit(ctx, ledger.ReversalRequest{
+		UserID:    body.UserID,
+		Reference: body.Reference,
+		Reason:    body.Reason,
+	})
+	if err != nil {
+		if errors.Is(err, ledger.ErrCreditNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		}
+		return fiberErr(c, err)
+	}
+	return c.JSON(balanceResponse(bal))
+}
+
 func (h *Handler) InternalReserveWithdrawal(c *fiber.Ctx) error {
 	var body struct {
 		UserID       string  `json:"userId"`
@@ -279,6 +320,31 @@ func (h *Handler) InternalSettleGame(c *fiber.Ctx) error {
 	return c.JSON(balanceResponse(bal))
 }
 
+// InternalMergeAccounts folds a guest wallet into the account it was linked to.
+func (h *Handler) InternalMergeAccounts(c *fiber.Ctx) error {
+	var body struct {
+		FromUserID string `json:"fromUserId"`
+		ToUserID   string `json:"toUserId"`
+	}
+	if err := c.BodyParser(&body); err != nil || body.FromUserID == "" || body.ToUserID == "" || body.FromUserID == body.ToUserID {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid payload"})
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	bal, err := h.svc.MergeAccounts(ctx, ledger.MergeRequest{
+		FromUserID: body.FromUserID,
+		ToUserID:   body.ToUserID,
+	})
+	if err != nil {
+		if errors.Is(err, ledger.ErrReservedFunds) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+		}
+		return fiberErr(c, err)
+	}
+	log.Printf("[wallet] merged account from=%s to=%s available=%.2f", body.FromUserID, body.ToUserID, bal.AvailableUsd)
+	return c.JSON(balanceResponse(bal))
+}
+
 func balanceResponse(b *ledger.Balance) fiber.Map {
 	return fiber.Map{
 		"userId":       b.UserID,
@@ -20,6 +20,10 @@ var (
 	ErrInsufficientFunds = errors.New("insufficient balance")
 	// ErrReservationNotFound indicates the referenced withdrawal/bet reservation does not exist.
 	ErrReservationNotFound = errors.New("reservation not found")
+	// ErrReservedFunds indicates an account with held funds was asked to merge.
+	ErrReservedFunds = errors.New("account has reserved funds")
+	// ErrCreditNotFound indicates a reversal named a deposit credit that was never applied.
+	ErrCreditNotFound = errors.New("credit not found")
 )
 
 type Service struct {
@@ -27,6 +31,7 @@ type Service struct {
 	balances           *mongo.Collection
 	entries            *mongo.Collection
 	withdrawals        *mongo.Collection
+	legacyHolds        *mongo.Collection
 	bets               *mongo.Collection
 	rdb                *redis.Client
 	startingBalanceUsd float64
@@ -37,11 +42,15 @@ func NewService(db *mongo.Database, rdb *redis.Client, startingBalanceUsd ...flo
 	if len(startingBalanceUsd) > 0 && startingBalanceUsd[0] > 0 {
 		initialGrant = startingBalanceUsd[0]
 	}
+	// Withdrawal holds are kept apart from payment-gateway's "withdrawals"
+	// documents, which share the ID and carry their own status;
+	// legacyHolds is where holds lived before that.
 	return &Service{
 		client:             db.Client(),
 		balances:           db.Collection("wallet_balances"),
 		entries:            db.Collection("ledger_entries"),
-		withdrawals:        db.Collection("withdrawals"),
+		withdrawals:        db.Collection("withdrawal_holds"),
+		legacyHolds:        db.Collection("withdrawals"),
 		bets:               db.Collection("bet_reservations"),
 		rdb:                rdb,
 		startingBalanceUsd: initialGrant,
@@ -84,6 +93,12 @@ type CreditRequest struct {
 	Metadata  bson.M
 }
 
+type ReversalRequest struct {
+	UserID    string
+	Reference string
+	Reason    string
+}
+
 type WithdrawalReserveRequest struct {
 	UserID       string
 	WithdrawalID string
@@ -114,6 +129,11 @@ type GameSettlementRequest struct {
 	TraceID   string
 }
 
+type MergeRequest struct {
+	FromUserID string
+	ToUserID   string
+}
+
 func (s *Service) GetBalance(ctx context.Context, userID string) (*Balance, error) {
 	if err := s.grantStartingBalanceIfNeeded(ctx, userID); err != nil {
 		return nil, err
@@ -206,6 +226,63 @@ func (s *Service) CreditDeposit(ctx context.Context, req CreditRequest) (*Balanc
 	return result, err
 }
 
+// ReverseCredit takes back a deposit credit whose funds never settled,
+// such as a crypto deposit dropped by a chain reorganization. The full
+// credit is debited even when that leaves the balance negative, so funds
+// that never arrived can't be spent. Reversing a credit twice is a no-op.
+func (s *Service) ReverseCredit(ctx context.Context, req ReversalRequest) (*Balance, error) {
+	var result *Balance
+	err := s.executeTx(ctx, func(tx mongo.SessionContext) error {
+		reference := req.Reference + ":reversal"
+		count, err := s.entries.CountDocuments(tx, bson.M{"reference": reference})
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			bal, err := s.GetBalance(tx, req.UserID)
+			result = bal
+			return err
+		}
+
+		var credit LedgerEntry
+		err = s.entries.FindOne(tx, bson.M{
+			"userId":    req.UserID,
+			"reference": req.Reference,
+			"type":      "DEPOSIT_CONFIRMED",
+		}).Decode(&credit)
+		if err == mongo.ErrNoDocuments {
+			return ErrCreditNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		bal, err := s.incrementAvailable(tx, req.UserID, -credit.AmountUsd)
+		if err != nil {
+			return err
+		}
+		entry := LedgerEntry{
+			UserID:           req.UserID,
+			Type:             "DEPOSIT_REVERSED",
+			AmountUsd:        -credit.AmountUsd,
+			Reference:        reference,
+			Metadata:         bson.M{"reason": req.Reason},
+			BalanceAvailable: bal.AvailableUsd,
+			BalanceReserved:  bal.ReservedUsd,
+			CreatedAt:        time.Now(),
+		}
+		if _, err := s.entries.InsertOne(tx, entry); err != nil {
+			return err
+		}
+		if bal.AvailableUsd < 0 {
+			log.Printf("[ledger] ⚠️ reversal %s left user %s at %.2f USD", req.Reference, req.UserID, bal.AvailableUsd)
+		}
+		result = bal
+		return nil
+	})
+	return result, err
+}
+
 func (s *Service) ReserveWithdrawal(ctx context.Context, req WithdrawalReserveRequest) (*Balance, error) {
 	var result *Balance
 	err := s.executeTx(ctx, func(tx mongo.SessionContext) error {
@@ -263,7 +340,12 @@ func (s *Service) ReleaseWithdrawal(ctx context.Context, req WithdrawalReleaseRe
 	var result *Balance
 	err := s.executeTx(ctx, func(tx mongo.SessionContext) error {
 		var doc WithdrawalRecord
-		if err := s.withdrawals.FindOne(tx, bson.M{"_id": req.WithdrawalID}).Decode(&doc); err != nil {
+		err := s.withdrawals.FindOne(tx, bson.M{"_id": req.WithdrawalID}).Decode(&doc)
+		if err == mongo.ErrNoDocuments {
+			// Holds taken before withdrawal_holds existed.
+			err = s.legacyHolds.FindOne(tx, bson.M{"_id": req.WithdrawalID, "status": "HELD"}).Decode(&doc)
+		}
+		if err != nil {
 			if err == mongo.ErrNoDocuments {
 				return ErrReservationNotFound
 			}
@@ -276,7 +358,6 @@ func (s *Service) ReleaseWithdrawal(ctx context.Context, req WithdrawalReleaseRe
 		}
 
 		var bal *Balance
-		var err error
 		if req.Success {
 			bal, err = s.burnReserved(tx, req.UserID, doc.AmountUsd)
 			doc.Status = "COMPLETED"
@@ -289,7 +370,7 @@ func (s *Service) ReleaseWithdrawal(ctx context.Context, req WithdrawalReleaseRe
 		}
 
 		doc.UpdatedAt = time.Now()
-		if _, err := s.withdrawals.UpdateByID(tx, doc.ID, bson.M{"$set": bson.M{"status": doc.Status, "updatedAt": doc.UpdatedAt}}); err != nil {
+		if _, err := s.withdrawals.ReplaceOne(tx, bson.M{"_id": doc.ID}, doc, options.Replace().SetUpsert(true)); err != nil {
 			return err
 		}
 
@@ -304,7 +385,7 @@ func (s *Service) ReleaseWithdrawal(ctx context.Context, req WithdrawalReleaseRe
 			UserID:           req.UserID,
 			Type:             entryType,
 			AmountUsd:        amount,
-			Reference:        req.WithdrawalID,
+			Reference:        req.WithdrawalID + ":release",
 			BalanceAvailable: bal.AvailableUsd,
 			BalanceReserved:  bal.ReservedUsd,
 			CreatedAt:        time.Now(),
@@ -460,6 +541,94 @@ func (s *Service) SettleGame(ctx context.Context, req GameSettlementRequest) (*B
 	return result, err
 }
 
+// MergeAccounts moves a guest's available balance into the account it is
+// being merged into. The guest's starting grant stays behind so a merge can't
+// hand out a second one, and an account with open bets or withdrawals is
+// rejected. Repeating the merge returns the target balance unchanged.
+func (s *Service) MergeAccounts(ctx context.Context, req MergeRequest) (*Balance, error) {
+	var result *Balance
+	outRef := fmt.Sprintf("merge:%s:%s:out", req.FromUserID, req.ToUserID)
+	inRef := fmt.Sprintf("merge:%s:%s:in", req.FromUserID, req.ToUserID)
+	err := s.executeTx(ctx, func(tx mongo.SessionContext) error {
+		count, err := s.entries.CountDocuments(tx, bson.M{"reference": outRef})
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			bal, err := s.GetBalance(tx, req.ToUserID)
+			result = bal
+			return err
+		}
+
+		var from balanceDoc
+		err = s.balances.FindOne(tx, bson.M{"userId": req.FromUserID}).Decode(&from)
+		if err != nil && err != mongo.ErrNoDocuments {
+			return err
+		}
+		if from.Reserved > 0 {
+			return ErrReservedFunds
+		}
+
+		amount := mergeableAmount(from, s.startingBalanceUsd)
+		if amount <= 0 {
+			bal, err := s.GetBalance(tx, req.ToUserID)
+			result = bal
+			return err
+		}
+
+		now := time.Now()
+		fromBal, err := s.incrementAvailable(tx, req.FromUserID, -amount)
+		if err != nil {
+			return err
+		}
+		toBal, err := s.incrementAvailable(tx, req.ToUserID, amount)
+		if err != nil {
+			return err
+		}
+		entries := []interface{}{
+			LedgerEntry{
+				UserID:           req.FromUserID,
+				Type:             "ACCOUNT_MERGE_OUT",
+				AmountUsd:        -amount,
+				Reference:        outRef,
+				Metadata:         bson.M{"toUserId": req.ToUserID},
+				BalanceAvailable: fromBal.AvailableUsd,
+				BalanceReserved:  fromBal.ReservedUsd,
+				CreatedAt:        now,
+			},
+			LedgerEntry{
+				UserID:           req.ToUserID,
+				Type:             "ACCOUNT_MERGE_IN",
+				AmountUsd:        amount,
+				Reference:        inRef,
+				Metadata:         bson.M{"fromUserId": req.FromUserID},
+				BalanceAvailable: toBal.AvailableUsd,
+				BalanceReserved:  toBal.ReservedUsd,
+				CreatedAt:        now,
+			},
+		}
+		if _, err := s.entries.InsertMany(tx, entries); err != nil {
+			return err
+		}
+		result = toBal
+		return nil
+	})
+	return result, err
+}
+
+// mergeableAmount is what a merged account hands over: its available balance
+// less any starting grant it was given.
+func mergeableAmount(from balanceDoc, startingBalanceUsd float64) float64 {
+	amount := from.Available
+	if from.StartingBalanceGranted {
+		amount -= startingBalanceUsd
+	}
+	if amount < 0 {
+		return 0
+	}
+	return amount
+}
+
 func (s *Service) executeTx(ctx context.Context, fn func(mongo.SessionContext) error) error {
 	session, err := s.client.StartSession()
 	if err != nil {